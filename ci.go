@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/yourorg/cli/pkg/bootstrap"
+	"github.com/yourorg/tool/depgraph"
 )
 
 const (
@@ -47,20 +54,34 @@ func init() {
 // Logger will be our structured logger.
 var logger *slog.Logger
 
+// pipelineOpts are the pipeline options from the flags.
+var pipelineOpts pipelineOptions
+
 func init() {
 	// Initialize a structured JSON logger that writes to stderr.
 	// This is great for GitLab CI logs, which can parse structured data.
 	logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
 }
 
-// Project represents the structure of a single Gradle module from our exported graph.
-type Project struct {
-	ProjectDir   string   `json:"projectDir"`
-	Dependencies []string `json:"dependencies"`
-}
-
 func main() {
+	selfTest := flag.Bool("self-test", false, "regenerate output for every fixture under -fixtures and compare it with the golden files")
+	fixtures := flag.String("fixtures", "testdata/pipeline-gen", "fixture directory used by -self-test")
+	updateGolden := flag.Bool("update-golden", false, "with -self-test, rewrite the golden files instead of comparing")
+	flag.IntVar(&pipelineOpts.MaxTriggers, "max-triggers", defaultPipelineOptions.MaxTriggers, "most child pipelines to trigger before collapsing into one full build (0 disables the cap)")
+	flag.StringVar(&pipelineOpts.FullBuildFile, "full-build-file", defaultPipelineOptions.FullBuildFile, "pipeline file triggered instead when -max-triggers is exceeded")
+	flag.BoolVar(&pipelineOpts.CreateMissing, "create-missing", false, "write a .gitlab/<app>.yml from the bootstrap templates for every triggered app that has none")
+	pipelineOpts.Root = defaultPipelineOptions.Root
+	flag.Parse()
+
 	// The main function now focuses on high-level flow and error handling.
+	if *selfTest {
+		if err := runSelfTest(*fixtures, *updateGolden); err != nil {
+			logger.Error("self-test failed", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("self-test passed", "fixtures", *fixtures)
+		return
+	}
 	if err := run(); err != nil {
 		logger.Error("pipeline generator failed", "error", err)
 		os.Exit(1)
@@ -68,13 +89,37 @@ func main() {
 	logger.Info("pipeline generation completed successfully")
 }
 
+// pipelineOptions are the knobs of the generated pipeline.
+type pipelineOptions struct {
+	MaxTriggers   int    // most child pipelines to trigger; 0 is no limit
+	FullBuildFile string // the pipeline to run instead when there would be more
+	Root          string // the repo root the included files are relative to
+	CreateMissing bool   // scaffold missing .gitlab/<app>.yml files instead of failing
+}
+
+// defaultPipelineOptions cap the fan-out at a level the runners cope with.
+var defaultPipelineOptions = pipelineOptions{MaxTriggers: 15, FullBuildFile: ".gitlab/full-build.yml", Root: "."}
+
+// ciContext carries the GitLab predefined variables rendered into trigger jobs.
+type ciContext struct {
+	ProjectPath string // CI_PROJECT_PATH
+	RefName     string // CI_COMMIT_REF_NAME
+}
+
+func ciContextFromEnv() ciContext {
+	return ciContext{
+		ProjectPath: os.Getenv("CI_PROJECT_PATH"),
+		RefName:     os.Getenv("CI_COMMIT_REF_NAME"),
+	}
+}
+
 // run contains the core logic of our application.
 func run() error {
 	// --- 1. Get Inputs & Validate ---
-	if len(os.Args) < 2 {
-		return fmt.Errorf("usage: %s <space-separated-changed-files>", os.Args[0])
+	if flag.NArg() < 1 {
+		return fmt.Errorf("usage: %s [flags] <space-separated-changed-files>", os.Args[0])
 	}
-	changedFilesArg := os.Args[1]
+	changedFilesArg := flag.Arg(0)
 	graphFile := "build/dependency-graph.json"
 	appsDir := "apps" // All deployable apps live under this directory.
 
@@ -82,44 +127,59 @@ func run() error {
 		"changed_files", changedFilesArg,
 		"graph_file", graphFile,
 		"apps_dir", appsDir,
+		"max_triggers", pipelineOpts.MaxTriggers,
 	)
 
+	return generate(os.Stdout, strings.Fields(changedFilesArg), graphFile, appsDir, ciContextFromEnv(), pipelineOpts)
+}
+
+// generate runs the full analysis for one set of changed files and writes the pipeline YAML to w.
+func generate(w io.Writer, changedFiles []string, graphFile, appsDir string, ci ciContext, opts pipelineOptions) error {
 	// --- 2. Load and Parse the Dependency Graph ---
 	projects, err := loadProjects(graphFile)
 	if err != nil {
 		return fmt.Errorf("could not load project graph: %w", err)
 	}
 
-	// --- 3. Dynamically Discover Deployable Applications ---
-	deployableApps, err := findDeployableApps(appsDir, projects)
+	// --- 3. Build the shared dependency graph, discovering the deployables ---
+	if _, err := os.Stat(appsDir); os.IsNotExist(err) {
+		logger.Warn("apps directory not found, assuming no deployable applications", "path", appsDir)
+	}
+	graph, err := depgraph.NewGraph(projects, depgraph.GradleAppsDir(os.DirFS(appsDir), filepath.Base(appsDir)))
 	if err != nil {
-		return fmt.Errorf("could not discover deployable apps: %w", err)
+		return fmt.Errorf("could not build dependency graph: %w", err)
 	}
+	deployableApps := graph.Deployables()
 	logger.Info("discovered deployable applications", "apps", deployableApps)
+	if entries, err := os.ReadDir(appsDir); err == nil {
+		for _, entry := range entries {
+			projectPath := fmt.Sprintf(":%s:%s", filepath.Base(appsDir), entry.Name())
+			if entry.IsDir() && !stringInSlice(projectPath, deployableApps) {
+				logger.Warn("directory in apps/ does not match any known Gradle project", "directory", entry.Name(), "expected_project_path", projectPath)
+			}
+		}
+	}
 
-	// --- 4. Build the Reverse Dependency Graph for efficient lookup ---
-	reverseGraph := buildReverseGraph(projects)
+	// --- 4. Identify Initial Set of Changed Modules ---
+	changedModules := findChangedModules(changedFiles, graph, deployableApps)
 
-	// --- 5. Identify Initial Set of Changed Modules ---
-	changedModules, err := findChangedModules(strings.Split(changedFilesArg, " "), projects, deployableApps)
+	// --- 5. Traverse the Graph to Find All Affected Apps ---
+	affectedApps, err := graph.AffectedDeployables(changedModules)
 	if err != nil {
-		return fmt.Errorf("could not determine changed modules: %w", err)
+		return fmt.Errorf("could not determine affected apps: %w", err)
 	}
-
-	// --- 6. Traverse the Graph to Find All Affected Apps ---
-	affectedApps := findAffectedApps(changedModules, reverseGraph, deployableApps)
 	logger.Info("analysis complete", "affected_apps", affectedApps)
 
-	// --- 7. Generate the Final Pipeline YAML ---
-	if err := generatePipelineYAML(os.Stdout, affectedApps); err != nil {
+	// --- 6. Generate the Final Pipeline YAML ---
+	if err := generatePipelineYAML(w, affectedApps, ci, opts); err != nil {
 		return fmt.Errorf("could not generate pipeline YAML: %w", err)
 	}
 
 	return nil
 }
 
-// loadProjects reads and parses the dependency graph JSON file.
-func loadProjects(path string) (map[string]Project, error) {
+// loadProjects reads and parses the dependency graph JSON file into depgraph projects.
+func loadProjects(path string) ([]depgraph.Project, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -131,160 +191,300 @@ func loadProjects(path string) (map[string]Project, error) {
 		return nil, err
 	}
 
-	var projects map[string]Project
-	if err := json.Unmarshal(bytes, &projects); err != nil {
+	var raw map[string]depgraph.Project
+	if err := json.Unmarshal(bytes, &raw); err != nil {
 		return nil, fmt.Errorf("error parsing JSON from %s: %w", path, err)
 	}
+	projects := make([]depgraph.Project, 0, len(raw))
+	for name, p := range raw {
+		p.Name = name
+		projects = append(projects, p)
+	}
 	return projects, nil
 }
 
-// findDeployableApps scans the 'apps/' directory to find all valid application modules.
-func findDeployableApps(appsDir string, projects map[string]Project) (map[string]bool, error) {
-	apps := make(map[string]bool)
-	entries, err := os.ReadDir(appsDir)
-	if err != nil {
-		// If the apps directory doesn't exist, it's not an error, just means no apps.
-		if os.IsNotExist(err) {
-			logger.Warn("apps directory not found, assuming no deployable applications", "path", appsDir)
-			return apps, nil
-		}
-		return nil, err
+// findChangedModules determines the initial set of impacted modules from the list of changed files.
+// File ownership uses depgraph's longest-prefix matching, so nested projects win over their parents.
+func findChangedModules(changedFiles []string, graph *depgraph.Graph, deployableApps []string) []string {
+	// Handle the special case for a shared version catalog
+	if stringInSlice("versions.toml", changedFiles) {
+		logger.Info("'versions.toml' changed, triggering all deployable applications.")
+		return deployableApps
 	}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			// Convert directory name to Gradle project path, e.g., "refdata" -> ":apps:refdata"
-			appName := entry.Name()
-			projectPath := fmt.Sprintf(":%s:%s", filepath.Base(appsDir), appName)
-
-			// Verify this directory corresponds to a known Gradle project
-			if _, ok := projects[projectPath]; ok {
-				apps[projectPath] = true
-			} else {
-				logger.Warn("directory in apps/ does not match any known Gradle project", "directory", appName, "expected_project_path", projectPath)
-			}
+	var changedModules []string
+	for _, file := range changedFiles {
+		if module, ok := graph.ProjectForFile(file); ok {
+			logger.Info("file change detected", "file", file, "module", module)
+			changedModules = append(changedModules, module)
 		}
 	}
-	return apps, nil
+	return changedModules
 }
 
-// buildReverseGraph creates a map for quick lookups of which projects depend on a given module.
-func buildReverseGraph(projects map[string]Project) map[string][]string {
-	reverseGraph := make(map[string][]string)
-	for path, projectData := range projects {
-		for _, dep := range projectData.Dependencies {
-			reverseGraph[dep] = append(reverseGraph[dep], path)
-		}
+// generatePipelineYAML writes the final GitLab CI YAML to the provided writer.
+// Jobs are emitted in sorted app order and variables in sorted key order so
+// identical inputs always produce byte-identical pipelines. More affected apps
+// than opts.MaxTriggers collapse into one full build, next to a job that
+// shows the pipeline with a warning. Every included file must exist under
+// opts.Root: a broken include would otherwise only fail once the child
+// pipeline starts.
+func generatePipelineYAML(w io.Writer, affectedApps []string, ci ciContext, opts pipelineOptions) error {
+	apps := append([]string(nil), affectedApps...)
+	sort.Strings(apps)
+	capped := opts.MaxTriggers > 0 && len(apps) > opts.MaxTriggers
+	if err := checkIncludes(apps, capped, opts); err != nil {
+		return err
 	}
-	return reverseGraph
-}
 
-// findChangedModules determines the initial set of impacted modules from the list of changed files.
-func findChangedModules(changedFiles []string, projects map[string]Project, deployableApps map[string]bool) (map[string]bool, error) {
-	changedModules := make(map[string]bool)
+	if _, err := fmt.Fprintln(w, "# This pipeline was dynamically generated by the pipeline-generator tool."); err != nil {
+		return err
+	}
 
-	// Handle the special case for a shared version catalog
-	if stringInSlice("versions.toml", changedFiles) {
-		logger.Info("'versions.toml' changed, triggering all deployable applications.")
-		return deployableApps, nil
+	if len(affectedApps) == 0 {
+		logger.Info("no applications affected, generating an empty pipeline.")
+		return nil
 	}
 
-	for _, file := range changedFiles {
-		// Find which project this file belongs to.
-		// We iterate in reverse to find the most specific path match first, e.g. "apps/a/b" before "apps/a".
-		// Note: A more robust solution might use a trie, but this is fine for most projects.
-		var bestMatch string
-		for projectPath, projectData := range projects {
-			if strings.HasPrefix(file, projectData.ProjectDir) && len(projectData.ProjectDir) > len(bestMatch) {
-				bestMatch = projectPath
-			}
+	if capped {
+		msg := fmt.Sprintf("%d apps affected, more than the %d allowed child pipelines, so running one full build instead", len(apps), opts.MaxTriggers)
+		logger.Warn("pipeline fan-out capped", "affected", len(apps), "max_triggers", opts.MaxTriggers, "full_build", opts.FullBuildFile)
+		// exit code 3 is allowed to fail, which GitLab shows as a warning
+		warningYAML := fmt.Sprintf(`
+fan-out-warning:
+  stage: downstream-pipelines
+  script:
+    - '%s'
+    - exit 3
+  allow_failure:
+    exit_codes: 3
+`, strings.ReplaceAll("echo \"WARNING: "+msg+"\"", "'", "''"))
+		if _, err := fmt.Fprint(w, warningYAML); err != nil {
+			return err
 		}
-		if bestMatch != "" {
-			logger.Info("file change detected", "file", file, "module", bestMatch)
-			changedModules[bestMatch] = true
+		return writeTriggerJob(w, "trigger:full-build", map[string]string{
+			"AFFECTED_APPS":  strings.Join(apps, " "),
+			"AFFECTED_COUNT": fmt.Sprint(len(apps)),
+		}, ci, opts.FullBuildFile)
+	}
+
+	for _, appPath := range apps {
+		appName := appNameOf(appPath)
+		err := writeTriggerJob(w, fmt.Sprintf("trigger:%s", appName), map[string]string{
+			"APP_NAME":       appName,
+			"GRADLE_PROJECT": appPath,
+		}, ci, appPipelineFile(appName))
+		if err != nil {
+			return err
 		}
 	}
-	return changedModules, nil
+	return nil
 }
 
-// findAffectedApps traverses the reverse dependency graph to find all upstream applications that are affected.
-// findAffectedApps traverses the reverse dependency graph to find all upstream applications that are affected.
-func findAffectedApps(initialModules map[string]bool, reverseGraph map[string][]string, deployableApps map[string]bool) []string {
-	// The set of all modules we determine are affected.
-	affectedSet := make(map[string]bool)
-	// The queue for our breadth-first search through the dependency graph.
-	queue := make([]string, 0, len(initialModules))
-
-	// **THIS IS THE CORRECTED PART**
-	// We iterate over the keys of the initialModules map to populate our queue.
-	for module := range initialModules {
-		queue = append(queue, module)
-	}
+// appNameOf converts a Gradle path like ":apps:refdata" to just "refdata".
+func appNameOf(appPath string) string {
+	return strings.TrimPrefix(appPath, ":apps:")
+}
 
-	for len(queue) > 0 {
-		// Dequeue the next module to process.
-		currentModule := queue[0]
-		queue = queue[1:]
+// appPipelineFile is the child pipeline an app's trigger job includes.
+func appPipelineFile(appName string) string {
+	return fmt.Sprintf(".gitlab/%s.yml", appName)
+}
 
-		if affectedSet[currentModule] {
-			continue // Already processed, skip.
+// checkIncludes makes sure the files the trigger jobs for apps include exist
+// under opts.Root: the full build's when capped, every app's otherwise. With
+// opts.CreateMissing, a missing app file is written from the bootstrap
+// templates for the language of apps/<app>/. It fails listing every file
+// that is still missing.
+func checkIncludes(apps []string, capped bool, opts pipelineOptions) error {
+	var missing []string
+	exists := func(file string) (bool, error) {
+		_, err := os.Stat(filepath.Join(opts.Root, filepath.FromSlash(file)))
+		if os.IsNotExist(err) {
+			return false, nil
 		}
+		return err == nil, err
+	}
 
-		// Mark this module as affected and log our progress.
-		affectedSet[currentModule] = true
-		logger.Debug("traversing dependency", "module", currentModule)
-
-		// Find everything that depends on the current module...
-		if dependents, ok := reverseGraph[currentModule]; ok {
-			logger.Debug("found dependents", "module", currentModule, "dependents", dependents)
-			// ...and add them to the queue to be processed.
-			queue = append(queue, dependents...)
+	if capped {
+		ok, err := exists(opts.FullBuildFile)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			missing = append(missing, opts.FullBuildFile)
+		}
+	} else {
+		for _, appPath := range apps {
+			appName := appNameOf(appPath)
+			file := appPipelineFile(appName)
+			ok, err := exists(file)
+			if err != nil {
+				return err
+			}
+			if ok {
+				continue
+			}
+			if opts.CreateMissing {
+				created, err := createAppPipeline(opts.Root, appName, file)
+				if err != nil {
+					return fmt.Errorf("could not create %s: %w", file, err)
+				}
+				if created {
+					continue
+				}
+			}
+			missing = append(missing, file)
 		}
 	}
 
-	// Filter the final set to only include actual deployable applications.
-	finalApps := make([]string, 0)
-	for app := range affectedSet {
-		if deployableApps[app] {
-			finalApps = append(finalApps, app)
+	if len(missing) > 0 {
+		hint := "add them"
+		if !capped && !opts.CreateMissing {
+			hint += " or rerun with -create-missing"
 		}
+		return fmt.Errorf("missing child pipeline files: %s (%s)", strings.Join(missing, ", "), hint)
 	}
-
-	return finalApps
+	return nil
 }
 
-// generatePipelineYAML writes the final GitLab CI YAML to the provided writer.
-func generatePipelineYAML(w io.Writer, affectedApps []string) error {
-	if _, err := fmt.Fprintln(w, "# This pipeline was dynamically generated by the pipeline-generator tool."); err != nil {
-		return err
+// createAppPipeline writes file, the bootstrap skeleton of appName's
+// pipeline, and reports whether it could: the build jobs are picked by the
+// language of apps/<app>/, so an app bootstrap does not recognise gets none.
+func createAppPipeline(root, appName, file string) (bool, error) {
+	language := bootstrap.DetectLanguage(filepath.Join(root, "apps", appName))
+	if language == "" {
+		logger.Warn("cannot tell the language of the app, not creating its pipeline", "app", appName, "file", file)
+		return false, nil
 	}
-
-	if len(affectedApps) == 0 {
-		logger.Info("no applications affected, generating an empty pipeline.")
-		return nil
+	jobs, err := bootstrap.CIJobs(bootstrap.Options{App: appName, Language: language})
+	if err != nil {
+		return false, err
 	}
+	path := filepath.Join(root, filepath.FromSlash(file))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, jobs, 0o644); err != nil {
+		return false, err
+	}
+	logger.Warn("created missing child pipeline from the bootstrap templates, commit it", "app", appName, "file", file, "language", language)
+	return true, nil
+}
 
-	for _, appPath := range affectedApps {
-		// Convert Gradle path ":apps:refdata" to just "refdata"
-		appName := strings.TrimPrefix(appPath, ":apps:")
-		// Dynamically create the trigger job name and include path
-		jobName := fmt.Sprintf("trigger:%s", appName)
-		includePath := fmt.Sprintf(".gitlab/%s.yml", appName)
-
-		// Using a multi-line string literal for clarity
-		jobYAML := fmt.Sprintf(`
+// writeTriggerJob writes a job that runs includePath as a child pipeline.
+func writeTriggerJob(w io.Writer, jobName string, vars map[string]string, ci ciContext, includePath string) error {
+	// Using a multi-line string literal for clarity
+	jobYAML := fmt.Sprintf(`
 %s:
   stage: downstream-pipelines # Assumes you have this stage in parent .gitlab-ci.yml
-  trigger:
+  variables:
+%s  trigger:
     include:
       - project: '%s' # GitLab predefined variable for the current project
         ref: '%s'     # GitLab predefined variable for the current branch/ref
         file: '%s'
-`, jobName, os.Getenv("CI_PROJECT_PATH"), os.Getenv("CI_COMMIT_REF_NAME"), includePath)
+`, jobName, renderVariables(vars, "    "), ci.ProjectPath, ci.RefName, includePath)
 
-		if _, err := fmt.Fprint(w, jobYAML); err != nil {
-			return err
+	_, err := fmt.Fprint(w, jobYAML)
+	return err
+}
+
+// renderVariables renders a YAML mapping with keys in sorted order.
+func renderVariables(vars map[string]string, indent string) string {
+	var b strings.Builder
+	for _, k := range sortedKeys(vars) {
+		fmt.Fprintf(&b, "%s%s: '%s'\n", indent, k, strings.ReplaceAll(vars[k], "'", "''"))
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//
+// ----------------- SELF-TEST (GOLDEN FILES) -----------------
+//
+
+// selfTestCI is the fixed CI context used for golden output so results do not
+// depend on the environment the self-test runs in.
+var selfTestCI = ciContext{ProjectPath: "group/monorepo", RefName: "main"}
+
+// runSelfTest regenerates the pipeline for every case directory under root
+// and compares it with the case's expected.yml. Each case contains:
+//
+//	dependency-graph.json  – exported Gradle graph
+//	apps/<name>/           – deployable app directories
+//	changed.txt            – changed files, one per line
+//	max-triggers.txt       – optional -max-triggers for the case
+//	.gitlab/<name>.yml     – the child pipelines the trigger jobs include
+//	expected.yml           – golden pipeline output, or
+//	expected-error.txt     – the error generation fails with instead
+func runSelfTest(root string, update bool) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("could not read fixtures: %w", err)
+	}
+
+	var failed []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
 		}
+		caseDir := filepath.Join(root, entry.Name())
+		changed, err := os.ReadFile(filepath.Join(caseDir, "changed.txt"))
+		if err != nil {
+			return fmt.Errorf("fixture %s: %w", entry.Name(), err)
+		}
+
+		opts := defaultPipelineOptions
+		opts.Root = caseDir
+		if max, err := os.ReadFile(filepath.Join(caseDir, "max-triggers.txt")); err == nil {
+			if opts.MaxTriggers, err = strconv.Atoi(strings.TrimSpace(string(max))); err != nil {
+				return fmt.Errorf("fixture %s: max-triggers.txt: %w", entry.Name(), err)
+			}
+		}
+
+		var got bytes.Buffer
+		err = generate(&got, strings.Fields(string(changed)),
+			filepath.Join(caseDir, "dependency-graph.json"), filepath.Join(caseDir, "apps"), selfTestCI, opts)
+		goldenPath := filepath.Join(caseDir, "expected.yml")
+		if _, statErr := os.Stat(filepath.Join(caseDir, "expected-error.txt")); statErr == nil {
+			if err == nil {
+				return fmt.Errorf("fixture %s: expected an error, generation succeeded", entry.Name())
+			}
+			goldenPath = filepath.Join(caseDir, "expected-error.txt")
+			got.Reset()
+			got.WriteString(err.Error() + "\n")
+		} else if err != nil {
+			return fmt.Errorf("fixture %s: %w", entry.Name(), err)
+		}
+
+		if update {
+			if err := os.WriteFile(goldenPath, got.Bytes(), 0o644); err != nil {
+				return err
+			}
+			logger.Info("golden file updated", "fixture", entry.Name())
+			continue
+		}
+		want, err := os.ReadFile(goldenPath)
+		if err != nil {
+			return fmt.Errorf("fixture %s: %w", entry.Name(), err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			logger.Error("output differs from golden file", "fixture", entry.Name(), "golden", goldenPath, "got", got.String())
+			failed = append(failed, entry.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d fixture(s) differ from golden output: %s (rerun with -update-golden to accept)", len(failed), strings.Join(failed, ", "))
 	}
 	return nil
 }
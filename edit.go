@@ -1,7 +1,8 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -13,34 +14,40 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/your-username/ansible-inventory-go/ansibleinv" // <-- IMPORTANT: Use your module path
 	"gopkg.in/yaml.v3"
+	"your-cli/internal/theme"
 )
 
-// --- Lipgloss Styles (re-used from before) ---
+// --- Lipgloss Styles (from the shared theme, see applyTheme) ---
 var (
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("63")).
-			Underline(true).
-			MarginBottom(1)
-
-	groupStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("205"))
-
-	hostStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("201"))
-
-	errorStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("196"))
-
-	successStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("46")) // Green
+	headerStyle  lipgloss.Style
+	groupStyle   lipgloss.Style
+	hostStyle    lipgloss.Style
+	errorStyle   lipgloss.Style
+	successStyle lipgloss.Style
 )
 
+func init() {
+	applyTheme(theme.Default())
+}
+
+// applyTheme sets the styles the generator and viewer print with.
+func applyTheme(t theme.Theme) {
+	headerStyle = t.Styles.Header
+	groupStyle = t.Styles.Match
+	hostStyle = t.Styles.Item
+	errorStyle = t.Styles.Error
+	successStyle = t.Styles.Success
+}
+
 // --- Main application router ---
 func main() {
+	// Colors come from ~/.config/loki/theme.yaml; a broken file only warns.
+	t, err := theme.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("Warning: using the default theme: %v", err)))
+	}
+	applyTheme(t)
+
 	// If the user runs `go run . generate`, start the interactive session.
 	if len(os.Args) > 1 && os.Args[1] == "generate" {
 		runInteractiveGenerator()
@@ -126,70 +133,114 @@ func runInteractiveGenerator() {
 	fmt.Println(successStyle.Render(fmt.Sprintf("✔ Successfully created inventory file: %s", config.Filename)))
 }
 
-// generateInventoryFile builds the YAML structure and writes it to a file.
+// generateInventoryFile builds the inventory and writes it to a file.
 func generateInventoryFile(config InventoryConfig) error {
-	// We build a map[string]any that directly represents the desired YAML structure.
-	// This is simpler than building our full Inventory struct and trying to serialize it.
-
-	// --- Create the YAML structure programmatically ---
-	children := make(map[string]any)
-	for _, env := range config.Environments {
-		// Each environment is a group with an empty hosts map to start.
-		children[env] = map[string]any{
-			"hosts": make(map[string]any),
-		}
+	inv := ansibleinv.NewInventory()
+	if _, err := inv.AddGroup("all", ""); err != nil {
+		return err
 	}
-
-	allGroup := map[string]any{
-		"vars": map[string]string{
-			"team":           config.Team,
-			"inventory_name": config.Name,
-		},
-		"children": children,
+	if err := inv.SetGroupVar("all", "team", config.Team); err != nil {
+		return err
 	}
-
-	root := map[string]any{
-		"all": allGroup,
+	if err := inv.SetGroupVar("all", "inventory_name", config.Name); err != nil {
+		return err
 	}
-
-	// --- Marshal the structure into YAML bytes ---
-	yamlData, err := yaml.Marshal(root)
-	if err != nil {
-		return fmt.Errorf("could not marshal data to YAML: %w", err)
+	// Each environment is a group under all with no hosts to start.
+	for _, env := range config.Environments {
+		if _, err := inv.AddGroup(env, "all"); err != nil {
+			return err
+		}
 	}
 
-	// --- Write the file ---
-	return os.WriteFile(config.Filename, yamlData, 0644)
+	return inv.WriteFile(config.Filename)
 }
 
 // --- Existing Viewer Logic (wrapped in a function) ---
 
+// inventoryPaths collects repeated -i flags.
+type inventoryPaths []string
+
+func (p *inventoryPaths) String() string     { return strings.Join(*p, ",") }
+func (p *inventoryPaths) Set(v string) error { *p = append(*p, v); return nil }
+
 func runViewer() {
-	inventoryPath := flag.String("i", "inventory.yaml", "Path to the Ansible inventory file.")
+	var paths inventoryPaths
+	flag.Var(&paths, "i", "Inventory file, directory or executable dynamic inventory script; repeat to combine several (default inventory.yaml).")
 	graphFlag := flag.Bool("graph", false, "Display the inventory graph of groups and hosts.")
 	hostFlag := flag.String("host", "", "Display all variables for a specific host.")
-	listFlag := flag.Bool("list", false, "Output the entire inventory as JSON (compatible with Ansible's --list).")
+	listFlag := flag.Bool("list", false, "Output the entire inventory as JSON, exactly as ansible-inventory --list would.")
+	exportFlag := flag.Bool("export", false, "With --list, keep vars on their groups instead of resolving them into hostvars (ansible-inventory --export).")
+	tableFlag := flag.Bool("table", false, "Output one row per host with its groups and the --vars, for audits.")
+	varsFlag := flag.String("vars", "ansible_host,ansible_user", "Comma-separated resolved variables to show with --table.")
+	csvFlag := flag.Bool("csv", false, "Write --table as CSV instead of aligned text.")
+	sshConfigFlag := flag.Bool("ssh-config", false, "Output a ~/.ssh/config block with a Host entry per host, from its ansible_host, ansible_user, ansible_port and ansible_ssh_private_key_file.")
+	etcHostsFlag := flag.Bool("etc-hosts", false, "Output an /etc/hosts snippet mapping each host's ansible_host address to its name.")
+	lintFlag := flag.Bool("lint", false, "Check the inventory for hosts without ansible_host, empty groups, shadowed group vars and hosts in more than one environment; exits 1 on errors, for CI.")
+	strictFlag := flag.Bool("strict", false, "With --lint, fail on warnings too.")
+	envsFlag := flag.String("envs", strings.Join(ansibleinv.DefaultEnvironments, ","), "Comma-separated environment groups no host may be in two of, for --lint.")
+	limitFlag := flag.String("limit", "", "Only show hosts matching an Ansible host pattern, e.g. 'prod:&web:!canary'.")
 	flag.Parse()
 
-	if !*graphFlag && *hostFlag == "" && !*listFlag {
-		fmt.Println(errorStyle.Render("Error: You must specify a viewer action: --graph, --host <name>, or --list"))
+	if !*graphFlag && *hostFlag == "" && !*listFlag && !*tableFlag && !*sshConfigFlag && !*etcHostsFlag && !*lintFlag {
+		fmt.Println(errorStyle.Render("Error: You must specify a viewer action: --graph, --host <name>, --list, --table, --ssh-config, --etc-hosts or --lint"))
 		fmt.Println("Or run 'go run . generate' to create a new inventory.")
 		fmt.Println("\nViewer Usage:")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	inv, err := ansibleinv.ParseYAMLFile(*inventoryPath)
-	if err != nil {
+	if len(paths) == 0 {
+		paths = inventoryPaths{"inventory.yaml"}
+	}
+	var sources []ansibleinv.Source
+	for _, path := range paths {
+		sources = append(sources, ansibleinv.SourceFor(path))
+	}
+	inv, err := ansibleinv.LoadSources(context.Background(), sources...)
+	var conflicts *ansibleinv.MergeError
+	if errors.As(err, &conflicts) {
+		fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("Warning: inventories disagree, keeping the first value: %v", err)))
+	} else if err != nil {
 		log.Fatal(errorStyle.Render(fmt.Sprintf("Failed to parse inventory: %v", err)))
 	}
+	if *lintFlag {
+		if *limitFlag != "" {
+			log.Fatal(errorStyle.Render("--lint checks the whole inventory: drop --limit"))
+		}
+		if !displayLint(inv, splitList(*envsFlag), *strictFlag) {
+			os.Exit(1)
+		}
+		return
+	}
+	if *limitFlag != "" {
+		inv = inv.Limit(*limitFlag)
+		if len(inv.Hosts) == 0 {
+			log.Fatal(errorStyle.Render(fmt.Sprintf("No hosts match --limit %q", *limitFlag)))
+		}
+	}
 
 	if *graphFlag {
 		displayGraph(inv)
 	} else if *hostFlag != "" {
 		displayHost(inv, *hostFlag)
 	} else if *listFlag {
-		displayListJSON(inv)
+		displayListJSON(inv, *exportFlag)
+	} else if *tableFlag {
+		format := ansibleinv.TableText
+		if *csvFlag {
+			format = ansibleinv.TableCSV
+		}
+		if err := inv.WriteTable(os.Stdout, nil, splitList(*varsFlag), format); err != nil {
+			log.Fatal(errorStyle.Render(fmt.Sprintf("Failed to write table: %v", err)))
+		}
+	} else if *sshConfigFlag {
+		if err := inv.WriteSSHConfig(os.Stdout, nil); err != nil {
+			log.Fatal(errorStyle.Render(fmt.Sprintf("Failed to write SSH config: %v", err)))
+		}
+	} else if *etcHostsFlag {
+		if err := inv.WriteEtcHosts(os.Stdout, nil); err != nil {
+			log.Fatal(errorStyle.Render(fmt.Sprintf("Failed to write hosts: %v", err)))
+		}
 	}
 }
 
@@ -240,41 +291,46 @@ func displayHost(inv *ansibleinv.Inventory, hostName string) {
 	fmt.Println(string(yamlOutput))
 }
 
-func displayListJSON(inv *ansibleinv.Inventory) {
-	output := make(map[string]interface{})
-	meta := make(map[string]interface{})
-	hostvars := make(map[string]interface{})
-
-	allHosts := []string{}
-	for hostName, host := range inv.Hosts {
-		allHosts = append(allHosts, hostName)
-		resolved, _ := inv.GetResolvedVariablesForHost(hostName)
-		hostvars[hostName] = resolved
+// splitList splits a comma-separated flag, dropping empty entries.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
 	}
-	sort.Strings(allHosts)
-	meta["hostvars"] = hostvars
-	output["_meta"] = meta
-	output["all"] = map[string][]string{"hosts": allHosts}
+	return list
+}
 
-	for groupName, group := range inv.Groups {
-		if groupName == "all" {
-			continue
-		}
-		groupHosts := []string{}
-		for hostName := range group.Hosts {
-			groupHosts = append(groupHosts, hostName)
-		}
-		sort.Strings(groupHosts)
-		output[groupName] = map[string]interface{}{
-			"hosts": groupHosts,
-			"vars":  group.Vars,
+// displayLint prints what ansibleinv.Lint finds, with the environment rule
+// over envs, and reports whether the inventory passes: no errors, nor
+// warnings if strict.
+func displayLint(inv *ansibleinv.Inventory, envs []string, strict bool) bool {
+	rules := []ansibleinv.Rule{ansibleinv.MissingAnsibleHost, ansibleinv.EmptyGroups, ansibleinv.ShadowedVars, ansibleinv.ExclusiveGroups(envs...)}
+	findings := ansibleinv.Lint(inv, rules...)
+	var errs, warnings int
+	for _, f := range findings {
+		if f.Severity == ansibleinv.SeverityError {
+			errs++
+			fmt.Println(errorStyle.Render(f.String()))
+		} else {
+			warnings++
+			fmt.Println(f.String())
 		}
 	}
+	if errs > 0 || strict && warnings > 0 {
+		fmt.Println(errorStyle.Render(fmt.Sprintf("Lint failed: %d error(s), %d warning(s)", errs, warnings)))
+		return false
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("Lint passed: %d hosts, %d groups, %d warning(s)", len(inv.Hosts), len(inv.Groups), warnings)))
+	return true
+}
 
-	jsonOutput, err := json.MarshalIndent(output, "", "  ")
+func displayListJSON(inv *ansibleinv.Inventory, export bool) {
+	jsonOutput, err := inv.MarshalListJSON(export)
 	if err != nil {
 		log.Fatal(errorStyle.Render(fmt.Sprintf("Failed to generate JSON: %v", err)))
 	}
 
-	fmt.Println(string(jsonOutput))
+	fmt.Print(string(jsonOutput))
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/your-username/ansible-inventory-go/ansibleinv"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the --list golden files under testdata/inventory-list instead of comparing")
+
+// TestListGolden renders --list and --list --export for every fixture under
+// testdata/inventory-list and compares them with list.json and export.json.
+func TestListGolden(t *testing.T) {
+	cases, err := os.ReadDir(filepath.Join("testdata", "inventory-list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if !c.IsDir() {
+			continue
+		}
+		dir := filepath.Join("testdata", "inventory-list", c.Name())
+		t.Run(c.Name(), func(t *testing.T) {
+			inv, err := ansibleinv.ParseInventoryDir(dir)
+			if err != nil {
+				t.Fatalf("ParseInventoryDir: %v", err)
+			}
+			for golden, export := range map[string]bool{"list.json": false, "export.json": true} {
+				got, err := inv.MarshalListJSON(export)
+				if err != nil {
+					t.Fatalf("MarshalListJSON(%v): %v", export, err)
+				}
+				path := filepath.Join(dir, golden)
+				if *updateGolden {
+					if err := os.WriteFile(path, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s differs from the golden file (rerun with -update-golden to accept):\ngot:\n%s\nwant:\n%s", path, got, want)
+				}
+			}
+		})
+	}
+}
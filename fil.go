@@ -6,192 +6,2328 @@
 //   apps/<app-name>/
 //   .gitlab/<app-name>.yml     – per‑app job definitions
 //   .gitlab-ci.yml             – includes the above file
-//   ansible/<app-name>/inventory.yml – generated inventory
+//   ansible/<app-name>/inventory.yml – generated inventory (--deploy ansible, the default)
+//   deploy/<app-name>/         – docker-compose.yml or a Helm chart (--deploy compose|helm)
 //
 // ────────────────────────────────────────────────────────────────────────────────
 // file: pkg/bootstrap/inventory.go
 package bootstrap
 
 import (
+    "bytes"
     "fmt"
+    "regexp"
     "strings"
+    "text/template"
 
-    "gopkg.in/yaml.v3"
+    "github.com/your-username/ansible-inventory-go/ansibleinv"
 )
 
-// Host represents one line under the Ansible host group.
+// Host is one host of an env; AnsibleHost becomes its ansible_host var.
 type Host struct {
-    AnsibleHost string `yaml:"ansible_host"`
+    AnsibleHost string
 }
 
 // EnvConfig holds vars + hosts for a single environment (dev, qa, …).
 type EnvConfig struct {
-    AnsibleUser string          `yaml:"ansible_user"`
-    Hosts       map[string]Host `yaml:"hosts"`
-    KeyPath     string          `yaml:"-"` // kept for runtime reference only
-}
-
-// Inventory mirrors the Ansible “all → children → envs” structure.
-type Inventory struct {
-    All struct {
-        Children map[string]EnvConfig `yaml:"children"`
-    } `yaml:"all"`
+    AnsibleUser string
+    Hosts       map[string]Host
+    KeyPath     string // kept for runtime reference only
 }
 
 // GenerateHostName returns app_###_region_env – lowercase and zero‑padded.
+// It is what DefaultHostNameTemplate renders.
 func GenerateHostName(app string, index int, region, env string) string {
     return fmt.Sprintf("%s_%03d_%s_%s", strings.ToLower(app), index, region, env)
 }
 
+// DefaultHostNameTemplate is the app_###_region_env scheme as a template.
+const DefaultHostNameTemplate = `{{.App}}_{{printf "%03d" .Index}}_{{.Region}}_{{.Env}}`
+
+// HostNameData is what a host name template sees; App is lowercased.
+type HostNameData struct {
+    App    string
+    Index  int // 1-based position within the env
+    Region string
+    Env    string
+}
+
+// ansibleSafe is what an inventory host name may contain: no spaces, ':'
+// (ports), '[' (ranges), '#' or '='.
+var ansibleSafe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// HostNamer renders host names from a text/template over HostNameData, with
+// lower, upper and replace (old new s) available, e.g.
+//
+//   {{.Region | replace "-" ""}}-{{.App}}{{printf "%02d" .Index}}.{{.Env}}
+type HostNamer struct{ tmpl *template.Template }
+
+// NewHostNamer parses text, DefaultHostNameTemplate if empty, and checks on
+// sample values that it renders Ansible-safe names that differ by index and env.
+func NewHostNamer(text string) (*HostNamer, error) {
+    if text == "" {
+        text = DefaultHostNameTemplate
+    }
+    tmpl, err := template.New("host_name").Option("missingkey=error").Funcs(template.FuncMap{
+        "lower":   strings.ToLower,
+        "upper":   strings.ToUpper,
+        "replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+    }).Parse(text)
+    if err != nil {
+        return nil, fmt.Errorf("host name template: %w", err)
+    }
+
+    n := &HostNamer{tmpl}
+    seen := map[string]bool{}
+    for _, sample := range []HostNameData{{"app", 1, "us-east-1", "dev"}, {"app", 2, "us-east-1", "dev"}, {"app", 1, "us-east-1", "prod"}} {
+        name, err := n.Name(sample.App, sample.Index, sample.Region, sample.Env)
+        if err != nil {
+            return nil, err
+        }
+        if seen[name] {
+            return nil, fmt.Errorf("host name template %q must use both .Index and .Env so names are unique", text)
+        }
+        seen[name] = true
+    }
+    return n, nil
+}
+
+// Name renders the name of the index'th host of app in env.
+func (n *HostNamer) Name(app string, index int, region, env string) (string, error) {
+    var buf bytes.Buffer
+    if err := n.tmpl.Execute(&buf, HostNameData{strings.ToLower(app), index, region, env}); err != nil {
+        return "", fmt.Errorf("host name template: %w", err)
+    }
+    name := buf.String()
+    if !ansibleSafe.MatchString(name) {
+        return "", fmt.Errorf("host name %q is not Ansible-safe: use letters, digits, '.', '_' or '-'", name)
+    }
+    return name, nil
+}
+
+// PlaceholderIP is the ansible_host of the host seeded into skipped envs.
+const PlaceholderIP = "REPLACE_WITH_IP"
+
 // NewPlaceholderConfig seeds an env so the group exists even if skipped.
 func NewPlaceholderConfig(app, region, env string) EnvConfig {
     name := GenerateHostName(app, 1, region, env)
     return EnvConfig{
         AnsibleUser: "deploy",
         Hosts: map[string]Host{
-            name: {AnsibleHost: "REPLACE_WITH_IP"},
+            name: {AnsibleHost: PlaceholderIP},
         },
     }
 }
 
-// BuildInventory merges explicit configs with placeholders.
-func BuildInventory(selected []string, explicit map[string]EnvConfig, app, region string) Inventory {
-    inv := Inventory{}
-    inv.All.Children = make(map[string]EnvConfig)
+// BuildInventory merges explicit configs with placeholders into the Ansible
+// “all → children → envs” structure, one group per env with ansible_user in
+// its vars.
+func BuildInventory(selected []string, explicit map[string]EnvConfig, app, region string) (*ansibleinv.Inventory, error) {
+    inv := ansibleinv.NewInventory()
+    if _, err := inv.AddGroup("all", ""); err != nil {
+        return nil, err
+    }
     for _, env := range selected {
-        if cfg, ok := explicit[env]; ok {
-            inv.All.Children[env] = cfg
-        } else {
-            inv.All.Children[env] = NewPlaceholderConfig(app, region, env)
+        cfg, ok := explicit[env]
+        if !ok {
+            cfg = NewPlaceholderConfig(app, region, env)
+        }
+        if _, err := inv.AddGroup(env, "all"); err != nil {
+            return nil, err
+        }
+        if cfg.AnsibleUser != "" {
+            if err := inv.SetGroupVar(env, "ansible_user", cfg.AnsibleUser); err != nil {
+                return nil, err
+            }
+        }
+        for name, h := range cfg.Hosts {
+            if _, err := inv.AddHost(env, name, map[string]any{"ansible_host": h.AnsibleHost}); err != nil {
+                return nil, err
+            }
         }
     }
-    return inv
+    return inv, nil
 }
 
-// MarshalInventory renders Inventory to YAML.
-func MarshalInventory(inv Inventory) ([]byte, error) { return yaml.Marshal(inv) }
+// MarshalInventory renders inv to YAML with the serializer the viewer and
+// editor read it back with.
+func MarshalInventory(inv *ansibleinv.Inventory) ([]byte, error) { return inv.MarshalYAML() }
 
 // ────────────────────────────────────────────────────────────────────────────────
 // file: pkg/bootstrap/scaffold.go
 package bootstrap
 
 import (
+    "bytes"
+    "embed"
+    "errors"
     "fmt"
+    "io/fs"
     "os"
+    "path"
     "path/filepath"
     "strings"
+    "text/template"
 )
 
-// WriteSkeleton writes inventory + GitLab CI stubs + blank app dir.
-// rootDir is typically the repo root ("." when running locally).
-func WriteSkeleton(rootDir, app string, invYAML []byte, envs []string) error {
-    // 1️⃣  apps/<app>/
-    if err := os.MkdirAll(filepath.Join(rootDir, "apps", app), 0o755); err != nil {
-        return err
-    }
+// templates holds one directory of app files per language (templates/java,
+// templates/go, …), the matching build jobs in templates/ci/<lang>.yml.tmpl
+// and the compose and helm deployment skeletons in templates/deploy/<target>.
+// all: keeps files like Python's __init__.py, which embed skips by default.
+//
+//go:embed all:templates
+var templates embed.FS
+
+// templateData is what every template sees.
+type templateData struct {
+    App        string
+    Language   string
+    Envs       []string
+    DeployPath string // where the deployment lives, relative to the root
+}
+
+// Options is what WriteSkeleton scaffolds.
+type Options struct {
+    App       string
+    Language  string // template set; empty leaves apps/<app>/ blank
+    Deploy    string // one of Deployments; ansible if empty
+    Envs      []string
+    Inventory []byte // ansible/<app>/inventory.yml, for the ansible target
+}
+
+// WriteSkeleton writes the deployment + GitLab CI stubs + the app's starter
+// files. rootDir is typically the repo root ("." when running locally).
+func WriteSkeleton(rootDir string, opts Options) error {
+    return WriteSkeletonTo(DiskWriter{}, rootDir, opts)
+}
 
-    // 2️⃣  ansible/<app>/
-    ansibleDir := filepath.Join(rootDir, "ansible", app)
-    if err := os.MkdirAll(ansibleDir, 0o755); err != nil {
+// WriteSkeletonTo is WriteSkeleton with the output going to w.
+func WriteSkeletonTo(w Writer, rootDir string, opts Options) error {
+    app, deploy := opts.App, deployTarget(opts.Deploy)
+    data := templateData{App: app, Language: opts.Language, Envs: opts.Envs, DeployPath: filepath.ToSlash(deployPath("", deploy, app))}
+
+    // 1️⃣  apps/<app>/ – rendered from templates/<lang>/
+    appDir := filepath.Join(rootDir, "apps", app)
+    if err := w.MkdirAll(appDir); err != nil {
         return err
     }
-    if err := os.WriteFile(filepath.Join(ansibleDir, "inventory.yml"), invYAML, 0o644); err != nil {
-        return err
+    if opts.Language != "" {
+        if err := renderDir(w, appDir, templateDir(opts.Language), data); err != nil {
+            return err
+        }
+    }
+
+    // 2️⃣  ansible/<app>/, or deploy/<app>/ – rendered from templates/deploy/<target>/
+    if deploy == "ansible" {
+        ansibleDir := filepath.Join(rootDir, "ansible", app)
+        if err := w.MkdirAll(ansibleDir); err != nil {
+            return err
+        }
+        if err := w.WriteFile(filepath.Join(ansibleDir, "inventory.yml"), opts.Inventory); err != nil {
+            return err
+        }
+    } else {
+        deployDir := filepath.Join(rootDir, "deploy", app)
+        if err := w.MkdirAll(deployDir); err != nil {
+            return err
+        }
+        if err := renderDir(w, deployDir, path.Join("deploy", deploy), data); err != nil {
+            return err
+        }
+        if _, err := writeDeployEnvs(w, rootDir, deploy, app, opts.Envs); err != nil {
+            return err
+        }
     }
 
     // 3️⃣  .gitlab-ci.yml  (includes the per‑app component)
     ciPath := filepath.Join(rootDir, ".gitlab-ci.yml")
     includeStmt := fmt.Sprintf("include:\n  - local: \".gitlab/%s.yml\"\n", app)
-    if err := os.WriteFile(ciPath, []byte(includeStmt), 0o644); err != nil {
+    if err := w.WriteFile(ciPath, []byte(includeStmt)); err != nil {
         return err
     }
 
     // 4️⃣  .gitlab/<app>.yml – one deploy job per env
     gitlabDir := filepath.Join(rootDir, ".gitlab")
-    if err := os.MkdirAll(gitlabDir, 0o755); err != nil {
+    if err := w.MkdirAll(gitlabDir); err != nil {
+        return err
+    }
+
+    jobs, err := CIJobs(opts)
+    if err != nil {
         return err
     }
+    return w.WriteFile(filepath.Join(gitlabDir, fmt.Sprintf("%s.yml", app)), jobs)
+}
+
+// CIJobs is the .gitlab/<app>.yml WriteSkeleton writes for opts: the build
+// jobs of its language and a deploy job per env.
+func CIJobs(opts Options) ([]byte, error) {
+    app, deploy := opts.App, deployTarget(opts.Deploy)
+    data := templateData{App: app, Language: opts.Language, Envs: opts.Envs, DeployPath: filepath.ToSlash(deployPath("", deploy, app))}
+
+    var b bytes.Buffer
+    if opts.Language != "" {
+        jobs, err := render(path.Join("templates", "ci", templateDir(opts.Language)+".yml.tmpl"), data)
+        if err != nil {
+            return nil, err
+        }
+        b.Write(jobs)
+    }
+    for _, env := range opts.Envs {
+        b.WriteString(deployJob(deploy, app, env))
+    }
+    return b.Bytes(), nil
+}
+
+// languageFiles are the build files DetectLanguage looks for, in order: Go
+// and Python apps have a Makefile too, so C comes last.
+var languageFiles = []struct{ file, language string }{
+    {"build.gradle.kts", "Java"},
+    {"build.gradle", "Java"},
+    {"go.mod", "Go"},
+    {"pyproject.toml", "Python"},
+    {"Makefile", "C"},
+}
+
+// DetectLanguage is the one of Languages the app in appDir is built with,
+// going by its build files; empty if none of them is there.
+func DetectLanguage(appDir string) string {
+    for _, lf := range languageFiles {
+        if _, err := os.Stat(filepath.Join(appDir, lf.file)); err == nil {
+            return lf.language
+        }
+    }
+    return ""
+}
+
+// deployTarget defaults an empty deployment target to ansible.
+func deployTarget(deploy string) string {
+    if deploy == "" {
+        return "ansible"
+    }
+    return deploy
+}
+
+// deployPath is the inventory of an ansible app, or the deploy/<app>
+// directory of a compose or helm one.
+func deployPath(rootDir, deploy, app string) string {
+    if deployTarget(deploy) == "ansible" {
+        return filepath.Join(rootDir, "ansible", app, "inventory.yml")
+    }
+    return filepath.Join(rootDir, "deploy", app)
+}
+
+// deployJob is the .gitlab/<app>.yml job that deploys app to env.
+func deployJob(deploy, app, env string) string {
+    switch deploy {
+    case "compose":
+        return fmt.Sprintf("\n%s_deploy:\n  stage: deploy\n  script:\n    - docker compose -p %s-%s -f deploy/%s/docker-compose.yml --env-file deploy/%s/%s.env up -d --build\n  environment:\n    name: %s\n", env, app, env, app, app, env, env)
+    case "helm":
+        return fmt.Sprintf("\n%s_deploy:\n  stage: deploy\n  script:\n    - helm upgrade --install %s deploy/%s/chart -f deploy/%s/chart/values-%s.yaml -n %s-%s --create-namespace\n  environment:\n    name: %s\n", env, app, app, app, env, app, env, env)
+    }
+    return fmt.Sprintf("\n%s_deploy:\n  stage: deploy\n  variables:\n    INVENTORY: ansible/%s/inventory.yml\n  script:\n    - ansible-playbook -i $INVENTORY playbooks/deploy.yml\n  environment:\n    name: %s\n", env, app, env)
+}
+
+// deployEnvFile is the per-env file of a compose or helm deployment: the
+// --env-file of docker compose, or the chart's values-<env>.yaml.
+func deployEnvFile(rootDir, deploy, app, env string) string {
+    if deploy == "helm" {
+        return filepath.Join(rootDir, "deploy", app, "chart", "values-"+env+".yaml")
+    }
+    return filepath.Join(rootDir, "deploy", app, env+".env")
+}
 
-    var b strings.Builder
+// writeDeployEnvs writes the deployEnvFile of every env that has none yet and
+// reports what it added.
+func writeDeployEnvs(w Writer, rootDir, deploy, app string, envs []string) ([]string, error) {
+    var changes []string
     for _, env := range envs {
-        fmt.Fprintf(&b, "\n%s_deploy:\n  stage: deploy\n  variables:\n    INVENTORY: ansible/%s/inventory.yml\n  script:\n    - ansible-playbook -i $INVENTORY playbooks/deploy.yml\n  environment:\n    name: %s\n", env, app, env)
+        file := deployEnvFile(rootDir, deploy, app, env)
+        if _, err := os.Stat(file); err == nil {
+            continue
+        } else if !errors.Is(err, fs.ErrNotExist) {
+            return nil, err
+        }
+        data := fmt.Sprintf("# docker compose --env-file for %s.\nAPP_ENV=%s\n", env, env)
+        if deploy == "helm" {
+            data = fmt.Sprintf("# Overrides of values.yaml for %s.\nenv: %s\n", env, env)
+        }
+        if err := w.WriteFile(file, []byte(data)); err != nil {
+            return nil, err
+        }
+        changes = append(changes, fmt.Sprintf("%s: added %s", env, file))
+    }
+    return changes, nil
+}
+
+// templateDir maps a language choice ("Java", "Python", …) to its template directory.
+func templateDir(language string) string {
+    return strings.ToLower(language)
+}
+
+// renderDir renders every templates/<dir>/**.tmpl into destDir, keeping the
+// relative path minus the .tmpl suffix; files without the suffix, like a Helm
+// chart's own templates, are copied as they are. Files that already exist are
+// left alone so re-running bootstrap never clobbers real code.
+func renderDir(w Writer, destDir, dir string, data templateData) error {
+    root := path.Join("templates", dir)
+    if _, err := fs.Stat(templates, root); err != nil {
+        return fmt.Errorf("no templates for %q", dir)
+    }
+    return fs.WalkDir(templates, root, func(name string, d fs.DirEntry, err error) error {
+        if err != nil || d.IsDir() {
+            return err
+        }
+        rel := strings.TrimPrefix(name, root+"/")
+        dest := filepath.Join(destDir, filepath.FromSlash(strings.TrimSuffix(rel, ".tmpl")))
+        if _, err := os.Stat(dest); err == nil {
+            return nil
+        } else if !errors.Is(err, fs.ErrNotExist) {
+            return err
+        }
+
+        var out []byte
+        if strings.HasSuffix(rel, ".tmpl") {
+            out, err = render(name, data)
+        } else {
+            out, err = templates.ReadFile(name)
+        }
+        if err != nil {
+            return err
+        }
+        return w.WriteFile(dest, out)
+    })
+}
+
+// render executes one embedded template.
+func render(name string, data templateData) ([]byte, error) {
+    src, err := templates.ReadFile(name)
+    if err != nil {
+        return nil, err
+    }
+    tmpl, err := template.New(path.Base(name)).Option("missingkey=error").Parse(string(src))
+    if err != nil {
+        return nil, fmt.Errorf("parse %s: %w", name, err)
+    }
+    var buf bytes.Buffer
+    if err := tmpl.Execute(&buf, data); err != nil {
+        return nil, fmt.Errorf("render %s: %w", name, err)
     }
-    return os.WriteFile(filepath.Join(gitlabDir, fmt.Sprintf("%s.yml", app)), []byte(b.String()), 0o644)
+    return buf.Bytes(), nil
 }
 
 // ────────────────────────────────────────────────────────────────────────────────
-// file: pkg/bootstrap/wizard.go
+// file: pkg/bootstrap/templates/java/build.gradle.kts.tmpl
+plugins {
+    application
+}
+
+repositories {
+    mavenCentral()
+}
+
+dependencies {
+    testImplementation("org.junit.jupiter:junit-jupiter:5.10.2")
+    testRuntimeOnly("org.junit.platform:junit-platform-launcher")
+}
+
+java {
+    toolchain {
+        languageVersion.set(JavaLanguageVersion.of(21))
+    }
+}
+
+application {
+    mainClass.set("App")
+}
+
+tasks.test {
+    useJUnitPlatform()
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/java/settings.gradle.kts.tmpl
+rootProject.name = "{{.App}}"
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/java/src/main/java/App.java.tmpl
+public class App {
+    public static void main(String[] args) {
+        System.out.println("hello from {{.App}}");
+    }
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/java/Dockerfile.tmpl
+FROM gradle:8-jdk21 AS build
+WORKDIR /src
+COPY . .
+RUN gradle installDist --no-daemon
+
+FROM eclipse-temurin:21-jre
+COPY --from=build /src/build/install/{{.App}} /opt/{{.App}}
+ENTRYPOINT ["/opt/{{.App}}/bin/{{.App}}"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/java/README.md.tmpl
+# {{.App}}
+
+Java service scaffolded by `bootstrap`.
+
+    gradle build      # compile + test
+    gradle run        # run locally
+    docker build -t {{.App}} .
+
+Deployed to: {{range $i, $e := .Envs}}{{if $i}}, {{end}}{{$e}}{{end}} (see `{{.DeployPath}}`).
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/c/src/main.c.tmpl
+#include <stdio.h>
+
+int main(void) {
+    printf("hello from {{.App}}\n");
+    return 0;
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/c/Makefile.tmpl
+CC     ?= cc
+CFLAGS ?= -std=c11 -Wall -Wextra -O2
+
+BIN := bin/{{.App}}
+SRC := $(wildcard src/*.c)
+
+$(BIN): $(SRC)
+	@mkdir -p $(dir $@)
+	$(CC) $(CFLAGS) -o $@ $^
+
+.PHONY: test clean
+test: $(BIN)
+	./$(BIN)
+
+clean:
+	rm -rf bin
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/c/Dockerfile.tmpl
+FROM gcc:13 AS build
+WORKDIR /src
+COPY . .
+RUN make
+
+FROM debian:bookworm-slim
+COPY --from=build /src/bin/{{.App}} /usr/local/bin/{{.App}}
+ENTRYPOINT ["/usr/local/bin/{{.App}}"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/c/README.md.tmpl
+# {{.App}}
+
+C service scaffolded by `bootstrap`.
+
+    make              # build bin/{{.App}}
+    make test
+    docker build -t {{.App}} .
+
+Deployed to: {{range $i, $e := .Envs}}{{if $i}}, {{end}}{{$e}}{{end}} (see `{{.DeployPath}}`).
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/go/go.mod.tmpl
+module {{.App}}
+
+go 1.22
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/go/main.go.tmpl
+package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello from {{.App}}")
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/go/Makefile.tmpl
+BIN := bin/{{.App}}
+
+.PHONY: build test clean
+build:
+	go build -o $(BIN) .
+
+test:
+	go vet ./...
+	go test ./...
+
+clean:
+	rm -rf bin
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/go/Dockerfile.tmpl
+FROM golang:1.22 AS build
+WORKDIR /src
+COPY . .
+RUN CGO_ENABLED=0 go build -o /out/{{.App}} .
+
+FROM gcr.io/distroless/static
+COPY --from=build /out/{{.App}} /{{.App}}
+ENTRYPOINT ["/{{.App}}"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/go/README.md.tmpl
+# {{.App}}
+
+Go service scaffolded by `bootstrap`.
+
+    make build        # bin/{{.App}}
+    make test
+    docker build -t {{.App}} .
+
+Deployed to: {{range $i, $e := .Envs}}{{if $i}}, {{end}}{{$e}}{{end}} (see `{{.DeployPath}}`).
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/python/pyproject.toml.tmpl
+[project]
+name = "{{.App}}"
+version = "0.1.0"
+requires-python = ">=3.11"
+
+[project.scripts]
+{{.App}} = "app.__main__:main"
+
+[project.optional-dependencies]
+test = ["pytest"]
+
+[build-system]
+requires = ["setuptools>=68"]
+build-backend = "setuptools.build_meta"
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/python/app/__init__.py.tmpl
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/python/app/__main__.py.tmpl
+def main() -> None:
+    print("hello from {{.App}}")
+
+
+if __name__ == "__main__":
+    main()
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/python/Makefile.tmpl
+.PHONY: install test run
+install:
+	pip install -e '.[test]'
+
+test:
+	python -m pytest
+
+run:
+	python -m app
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/python/Dockerfile.tmpl
+FROM python:3.12-slim
+WORKDIR /app
+COPY . .
+RUN pip install --no-cache-dir .
+ENTRYPOINT ["{{.App}}"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/python/README.md.tmpl
+# {{.App}}
+
+Python service scaffolded by `bootstrap`.
+
+    make install      # editable install with test deps
+    make test
+    docker build -t {{.App}} .
+
+Deployed to: {{range $i, $e := .Envs}}{{if $i}}, {{end}}{{$e}}{{end}} (see `{{.DeployPath}}`).
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/deploy/compose/docker-compose.yml.tmpl
+# Deployed per env by the {{.App}} deploy jobs:
+#   docker compose -p {{.App}}-<env> -f deploy/{{.App}}/docker-compose.yml --env-file deploy/{{.App}}/<env>.env up -d
+services:
+  {{.App}}:
+    build: ../../apps/{{.App}}
+    image: ${REGISTRY:-registry.example.com}/{{.App}}:${TAG:-latest}
+    environment:
+      APP_ENV: ${APP_ENV}
+    ports:
+      - "8080:8080"
+    restart: unless-stopped
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/deploy/helm/chart/Chart.yaml.tmpl
+apiVersion: v2
+name: {{.App}}
+description: Helm chart for {{.App}}
+type: application
+version: 0.1.0
+appVersion: "0.1.0"
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/deploy/helm/chart/values.yaml.tmpl
+# Defaults for every env; values-<env>.yaml overrides them.
+replicaCount: 1
+
+image:
+  repository: registry.example.com/{{.App}}
+  tag: latest
+  pullPolicy: IfNotPresent
+
+service:
+  port: 8080
+
+env: ""
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/deploy/helm/chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+  labels:
+    app.kubernetes.io/name: {{ .Chart.Name }}
+    app.kubernetes.io/instance: {{ .Release.Name }}
+spec:
+  replicas: {{ .Values.replicaCount }}
+  selector:
+    matchLabels:
+      app.kubernetes.io/instance: {{ .Release.Name }}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: {{ .Chart.Name }}
+        app.kubernetes.io/instance: {{ .Release.Name }}
+    spec:
+      containers:
+        - name: {{ .Chart.Name }}
+          image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}"
+          imagePullPolicy: {{ .Values.image.pullPolicy }}
+          env:
+            - name: APP_ENV
+              value: {{ .Values.env | quote }}
+          ports:
+            - containerPort: {{ .Values.service.port }}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/deploy/helm/chart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Release.Name }}
+  labels:
+    app.kubernetes.io/name: {{ .Chart.Name }}
+    app.kubernetes.io/instance: {{ .Release.Name }}
+spec:
+  selector:
+    app.kubernetes.io/instance: {{ .Release.Name }}
+  ports:
+    - port: {{ .Values.service.port }}
+      targetPort: {{ .Values.service.port }}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/ci/java.yml.tmpl
+{{.App}}_build:
+  stage: build
+  image: gradle:8-jdk21
+  script:
+    - cd apps/{{.App}}
+    - gradle build --no-daemon
+  rules:
+    - changes: ["apps/{{.App}}/**/*"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/ci/c.yml.tmpl
+{{.App}}_build:
+  stage: build
+  image: gcc:13
+  script:
+    - cd apps/{{.App}}
+    - make
+    - make test
+  rules:
+    - changes: ["apps/{{.App}}/**/*"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/ci/go.yml.tmpl
+{{.App}}_build:
+  stage: build
+  image: golang:1.22
+  script:
+    - cd apps/{{.App}}
+    - make test
+    - make build
+  rules:
+    - changes: ["apps/{{.App}}/**/*"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/templates/ci/python.yml.tmpl
+{{.App}}_build:
+  stage: build
+  image: python:3.12-slim
+  script:
+    - cd apps/{{.App}}
+    - pip install '.[test]'
+    - python -m pytest
+  rules:
+    - changes: ["apps/{{.App}}/**/*"]
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/spec.go
 package bootstrap
 
 import (
     "fmt"
     "os"
+    "regexp"
+    "slices"
 
-    "github.com/charmbracelet/huh/v2"
+    "gopkg.in/yaml.v3"
 )
 
-// RunWizard executes the form, writes files, and returns the inventory path.
-func RunWizard(rootDir string) (string, error) {
-    var (
-        appName, region, language string
-        envChoices               []string
-    )
+// Choices offered by the wizard; a Spec must pick from the same lists.
+var (
+    Regions      = []string{"us-east-1", "us-west-2", "eu-west-1"}
+    Languages    = []string{"Java", "C", "Go", "Python"}
+    Environments = []string{"dev", "qa", "staging", "prod"}
+    Deployments  = []string{"ansible", "compose", "helm"}
+)
 
-    form := huh.NewForm(
+// Spec is everything the wizard asks for. A YAML file (bootstrap --config)
+// or flags can supply it instead, so CI and scripts can scaffold apps:
+//
+//   app: billing
+//   region: us-east-1
+//   language: Java
+//   deploy: ansible              # optional; or compose, helm (no hosts needed)
+//   envs: [dev, prod]
+//   environments:                # optional; unlisted envs get a placeholder host
+//     dev:
+//       ansible_user: deploy
+//       key_path: ~/.ssh/id_rsa
+//       hosts: [10.0.0.5, 10.0.0.6]
+//   topology: topology.yaml      # optional; register the app here…
+//   depends_on: [refdata]        # …depending on these apps
+//   projects: projects.json      # optional; add :apps:billing here…
+//   libraries: [":libs:common"]  # …building against these projects
+//   host_name: "{{.App}}-{{.Index}}.{{.Env}}" # optional; see HostNamer
+//   gitlab: {project: grp/billing}  # optional; see Provision
+type Spec struct {
+    App          string             `yaml:"app"`
+    Region       string             `yaml:"region"`
+    Language     string             `yaml:"language"`
+    Deploy       string             `yaml:"deploy,omitempty"` // one of Deployments; ansible if empty
+    Envs         []string           `yaml:"envs"`
+    Environments map[string]EnvSpec `yaml:"environments,omitempty"`
+    HostName     string             `yaml:"host_name,omitempty"` // host naming template; DefaultHostNameTemplate if empty
+    GitLab       *GitLabTarget      `yaml:"gitlab,omitempty"`    // environments + variables to provision, if set
+
+    // Registration; paths are relative to the root dir and empty skips the step.
+    Topology  string   `yaml:"topology,omitempty"`
+    DependsOn []string `yaml:"depends_on,omitempty"`
+    Projects  string   `yaml:"projects,omitempty"`
+    Libraries []string `yaml:"libraries,omitempty"`
+}
+
+// EnvSpec configures one environment; hosts are ansible_host addresses and
+// are named with the Spec's host name template in order.
+type EnvSpec struct {
+    AnsibleUser string   `yaml:"ansible_user"`
+    KeyPath     string   `yaml:"key_path,omitempty"`
+    Hosts       []string `yaml:"hosts"`
+}
+
+var appNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// LoadSpec reads a Spec from a YAML file.
+func LoadSpec(path string) (Spec, error) {
+    var spec Spec
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return spec, err
+    }
+    if err := yaml.Unmarshal(data, &spec); err != nil {
+        return spec, fmt.Errorf("parse %s: %w", path, err)
+    }
+    return spec, nil
+}
+
+// Validate reports the first problem that would stop the scaffold.
+func (s Spec) Validate() error {
+    if !appNameRe.MatchString(s.App) {
+        return fmt.Errorf("app name %q must be letters, digits, '.', '_' or '-'", s.App)
+    }
+    ansible := deployTarget(s.Deploy) == "ansible"
+    // only host names use the region, so compose and helm apps may leave it out
+    if (ansible || s.Region != "") && !slices.Contains(Regions, s.Region) {
+        return fmt.Errorf("region %q is not one of %v", s.Region, Regions)
+    }
+    if s.Language != "" && !slices.Contains(Languages, s.Language) {
+        return fmt.Errorf("language %q is not one of %v", s.Language, Languages)
+    }
+    if !slices.Contains(Deployments, deployTarget(s.Deploy)) {
+        return fmt.Errorf("deploy %q is not one of %v", s.Deploy, Deployments)
+    }
+    if !ansible && len(s.Environments) > 0 {
+        return fmt.Errorf("environments (ansible users and hosts) do not apply to deploy %s", s.Deploy)
+    }
+    if len(s.Envs) == 0 {
+        return fmt.Errorf("at least one environment is required")
+    }
+    if _, err := NewHostNamer(s.HostName); err != nil {
+        return err
+    }
+    for _, env := range s.Envs {
+        if !slices.Contains(Environments, env) {
+            return fmt.Errorf("environment %q is not one of %v", env, Environments)
+        }
+    }
+    for env, cfg := range s.Environments {
+        if !slices.Contains(s.Envs, env) {
+            return fmt.Errorf("environment %q is configured but not selected in envs", env)
+        }
+        if cfg.AnsibleUser == "" || len(cfg.Hosts) == 0 {
+            return fmt.Errorf("environment %q needs an ansible_user and at least one host", env)
+        }
+    }
+    if len(s.DependsOn) > 0 && s.Topology == "" {
+        return fmt.Errorf("depends_on needs a topology file to register the app in")
+    }
+    if len(s.Libraries) > 0 && s.Projects == "" {
+        return fmt.Errorf("libraries needs a projects file to register the app in")
+    }
+    if s.GitLab != nil && s.GitLab.Project == "" {
+        return fmt.Errorf("gitlab needs a project")
+    }
+    return nil
+}
+
+// EnvConfig turns env's EnvSpec into its inventory group, naming the hosts
+// with the host name template. An env that was not configured gets the
+// placeholder host.
+func (s Spec) EnvConfig(env string) (EnvConfig, error) {
+    namer, err := NewHostNamer(s.HostName)
+    if err != nil {
+        return EnvConfig{}, err
+    }
+    es, ok := s.Environments[env]
+    if !ok {
+        es = EnvSpec{AnsibleUser: "deploy", Hosts: []string{PlaceholderIP}}
+    }
+    cfg := EnvConfig{AnsibleUser: es.AnsibleUser, KeyPath: es.KeyPath, Hosts: make(map[string]Host)}
+    for i, addr := range es.Hosts {
+        name, err := namer.Name(s.App, i+1, s.Region, env)
+        if err != nil {
+            return EnvConfig{}, err
+        }
+        cfg.Hosts[name] = Host{AnsibleHost: addr}
+    }
+    return cfg, nil
+}
+
+// Apply validates spec, renders the inventory (for the ansible target),
+// writes the skeleton under rootDir and registers the app in the topology and
+// project metadata. It returns the inventory path, or deploy/<app> for the
+// compose and helm targets.
+func Apply(rootDir string, spec Spec) (string, error) {
+    return ApplyTo(DiskWriter{}, rootDir, spec)
+}
+
+// ApplyTo is Apply with the output going to w.
+func ApplyTo(w Writer, rootDir string, spec Spec) (string, error) {
+    if err := spec.Validate(); err != nil {
+        return "", err
+    }
+    writes, err := register(rootDir, spec)
+    if err != nil {
+        return "", err
+    }
+    opts := Options{App: spec.App, Language: spec.Language, Deploy: spec.Deploy, Envs: spec.Envs}
+    if deployTarget(spec.Deploy) == "ansible" {
+        explicit := make(map[string]EnvConfig, len(spec.Envs))
+        for _, env := range spec.Envs {
+            if explicit[env], err = spec.EnvConfig(env); err != nil {
+                return "", err
+            }
+        }
+        inv, err := BuildInventory(spec.Envs, explicit, spec.App, spec.Region)
+        if err != nil {
+            return "", err
+        }
+        if opts.Inventory, err = MarshalInventory(inv); err != nil {
+            return "", err
+        }
+    }
+    if err := WriteSkeletonTo(w, rootDir, opts); err != nil {
+        return "", err
+    }
+    for _, pw := range writes {
+        if err := w.WriteFile(pw.path, pw.data); err != nil {
+            return "", err
+        }
+    }
+    return deployPath(rootDir, spec.Deploy, spec.App), nil
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/register.go
+package bootstrap
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "sort"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Files the wizard offers to register a new app in when they exist under the root.
+const (
+    DefaultTopology = "topology.yaml"
+    DefaultProjects = "projects.json"
+)
+
+// pendingWrite is a registration update computed up front, so a bad
+// dependency fails before anything is scaffolded.
+type pendingWrite struct {
+    path string
+    data []byte
+}
+
+// register computes the topology and project metadata updates spec asks for.
+func register(rootDir string, spec Spec) ([]pendingWrite, error) {
+    var writes []pendingWrite
+    if spec.Topology != "" {
+        path := resolve(rootDir, spec.Topology)
+        raw, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        data, err := addToTopology(raw, spec.App, spec.DependsOn)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+        writes = append(writes, pendingWrite{path, data})
+    }
+    if spec.Projects != "" {
+        path := resolve(rootDir, spec.Projects)
+        raw, err := os.ReadFile(path)
+        if err != nil {
+            return nil, err
+        }
+        data, err := addToProjects(raw, spec.App, spec.Libraries)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", path, err)
+        }
+        writes = append(writes, pendingWrite{path, data})
+    }
+    return writes, nil
+}
+
+func resolve(rootDir, path string) string {
+    if filepath.IsAbs(path) {
+        return path
+    }
+    return filepath.Join(rootDir, path)
+}
+
+// TopologyApps lists the apps declared in a topology file, sorted; these are
+// what a new app can depend on.
+func TopologyApps(path string) ([]string, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var doc yaml.Node
+    if err := yaml.Unmarshal(raw, &doc); err != nil {
+        return nil, err
+    }
+    apps, err := topologyApps(&doc)
+    if err != nil {
+        return nil, err
+    }
+    return mappingKeys(apps), nil
+}
+
+// ProjectNames lists the projects in a project metadata file, sorted.
+func ProjectNames(path string) ([]string, error) {
+    raw, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var projects map[string]json.RawMessage
+    if err := json.Unmarshal(raw, &projects); err != nil {
+        return nil, err
+    }
+    names := make([]string, 0, len(projects))
+    for name := range projects {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names, nil
+}
+
+// topologyApps returns the apps mapping of a topology document, adding an
+// empty one if the document has none yet.
+func topologyApps(doc *yaml.Node) (*yaml.Node, error) {
+    if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+        return nil, fmt.Errorf("topology is not a YAML mapping")
+    }
+    root := doc.Content[0]
+    for i := 0; i+1 < len(root.Content); i += 2 {
+        if root.Content[i].Value != "apps" {
+            continue
+        }
+        if root.Content[i+1].Kind != yaml.MappingNode {
+            return nil, fmt.Errorf("topology apps is not a mapping")
+        }
+        return root.Content[i+1], nil
+    }
+    apps := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+    root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "apps"}, apps)
+    return apps, nil
+}
+
+func mappingKeys(m *yaml.Node) []string {
+    var keys []string
+    for i := 0; i+1 < len(m.Content); i += 2 {
+        keys = append(keys, m.Content[i].Value)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// addToTopology appends app to the topology's apps with the given
+// depends_on. Working on the node tree keeps existing comments and order.
+func addToTopology(raw []byte, app string, deps []string) ([]byte, error) {
+    var doc yaml.Node
+    if err := yaml.Unmarshal(raw, &doc); err != nil {
+        return nil, err
+    }
+    apps, err := topologyApps(&doc)
+    if err != nil {
+        return nil, err
+    }
+    known := mappingKeys(apps)
+    if slices.Contains(known, app) {
+        return nil, fmt.Errorf("app %q is already registered", app)
+    }
+    dependsOn := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+    for _, dep := range deps {
+        if !slices.Contains(known, dep) {
+            return nil, fmt.Errorf("unknown dependency %q (known apps: %v)", dep, known)
+        }
+        dependsOn.Content = append(dependsOn.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: dep})
+    }
+    apps.Content = append(apps.Content,
+        &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: app},
+        &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+            {Kind: yaml.ScalarNode, Tag: "!!str", Value: "depends_on"}, dependsOn,
+        }},
+    )
+
+    var buf bytes.Buffer
+    enc := yaml.NewEncoder(&buf)
+    enc.SetIndent(2)
+    if err := enc.Encode(&doc); err != nil {
+        return nil, err
+    }
+    if err := enc.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// addToProjects adds a deployable ":apps:<app>" project rooted at apps/<app>
+// that depends on libs, each of which must already be in the metadata.
+func addToProjects(raw []byte, app string, libs []string) ([]byte, error) {
+    var projects map[string]json.RawMessage
+    if err := json.Unmarshal(raw, &projects); err != nil {
+        return nil, err
+    }
+    name := ":apps:" + app
+    if _, ok := projects[name]; ok {
+        return nil, fmt.Errorf("project %q is already registered", name)
+    }
+    for _, lib := range libs {
+        if _, ok := projects[lib]; !ok {
+            return nil, fmt.Errorf("unknown project %q", lib)
+        }
+    }
+
+    entry, err := json.Marshal(struct {
+        ProjectDir   string   `json:"projectDir"`
+        Dependencies []string `json:"dependencies"`
+        Deployable   bool     `json:"deployable"`
+    }{"apps/" + app, append([]string{}, libs...), true})
+    if err != nil {
+        return nil, err
+    }
+    projects[name] = entry
+
+    out, err := json.MarshalIndent(projects, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append(out, '\n'), nil
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/edit.go
+package bootstrap
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "slices"
+    "sort"
+    "strings"
+
+    "github.com/your-username/ansible-inventory-go/ansibleinv"
+    "gopkg.in/yaml.v3"
+)
+
+// LoadExisting rebuilds the Spec of an app bootstrapped earlier from
+// ansible/<app>/inventory.yml so edit mode can pre-fill the wizard. The
+// region is read back from the host names, which were generated from the
+// hostName template (empty for the default); placeholder hosts are left out.
+// Compose and helm apps are recognised by deploy/<app>, their envs by the
+// per-env files there.
+func LoadExisting(rootDir, app, hostName string) (Spec, error) {
+    spec := Spec{App: app, HostName: hostName, Environments: map[string]EnvSpec{}}
+    for deploy, marker := range map[string]string{"compose": "docker-compose.yml", "helm": filepath.Join("chart", "Chart.yaml")} {
+        if _, err := os.Stat(filepath.Join(rootDir, "deploy", app, marker)); err != nil {
+            continue
+        }
+        spec.Deploy = deploy
+        for _, env := range Environments {
+            if _, err := os.Stat(deployEnvFile(rootDir, deploy, app, env)); err == nil {
+                spec.Envs = append(spec.Envs, env)
+            }
+        }
+        return spec, nil
+    }
+
+    namer, err := NewHostNamer(hostName)
+    if err != nil {
+        return spec, err
+    }
+    invPath := filepath.Join(rootDir, "ansible", app, "inventory.yml")
+    if _, err := os.Stat(invPath); err != nil {
+        return spec, fmt.Errorf("%s has not been bootstrapped: %w", app, err)
+    }
+    inv, err := ansibleinv.ParseYAMLFile(invPath)
+    if err != nil {
+        return spec, err
+    }
+    all, ok := inv.Groups["all"]
+    if !ok {
+        return spec, fmt.Errorf("%s: inventory has no all group", invPath)
+    }
+
+    for env, group := range all.Children {
+        spec.Envs = append(spec.Envs, env)
+        es := EnvSpec{}
+        es.AnsibleUser, _ = group.Vars["ansible_user"].(string)
+        for i, name := range sortedHosts(group.Hosts) {
+            if spec.Region == "" && i == 0 {
+                spec.Region = namer.regionOf(app, env, name)
+            }
+            if addr, _ := group.Hosts[name].Vars["ansible_host"].(string); addr != "" && addr != PlaceholderIP {
+                es.Hosts = append(es.Hosts, addr)
+            }
+        }
+        if len(es.Hosts) > 0 {
+            spec.Environments[env] = es
+        }
+    }
+    // keep the wizard's order (dev, qa, …) rather than map order
+    sort.Slice(spec.Envs, func(i, j int) bool {
+        return slices.Index(Environments, spec.Envs[i]) < slices.Index(Environments, spec.Envs[j])
+    })
+    return spec, nil
+}
+
+func sortedHosts(hosts map[string]*ansibleinv.Host) []string {
+    names := make([]string, 0, len(hosts))
+    for name := range hosts {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// maxHostIndex bounds the search in regionOf; the default scheme pads to three digits.
+const maxHostIndex = 999
+
+// regionOf finds the region host was named with by rendering every known
+// region and index until one matches; "" if none does.
+func (n *HostNamer) regionOf(app, env, host string) string {
+    for _, region := range Regions {
+        for i := 1; i <= maxHostIndex; i++ {
+            if name, err := n.Name(app, i, region, env); err == nil && name == host {
+                return region
+            }
+        }
+    }
+    return ""
+}
+
+// ApplyEdit merges spec into an app bootstrapped earlier. Environments that
+// are new get an inventory group and a deploy job, hosts whose address is new
+// are appended with the next free index, and an env's placeholder host gives
+// way to its first real one. Nothing already there is rewritten or removed,
+// including vars added to the inventory by hand. Compose and helm apps only
+// gain the per-env file and deploy job of new envs. It returns the path Apply
+// did and a line per change.
+func ApplyEdit(rootDir string, spec Spec) (string, []string, error) {
+    return ApplyEditTo(DiskWriter{}, rootDir, spec)
+}
+
+// ApplyEditTo is ApplyEdit with the output going to w.
+func ApplyEditTo(w Writer, rootDir string, spec Spec) (string, []string, error) {
+    if err := spec.Validate(); err != nil {
+        return "", nil, err
+    }
+    if deploy := deployTarget(spec.Deploy); deploy != "ansible" {
+        changes, err := writeDeployEnvs(w, rootDir, deploy, spec.App, spec.Envs)
+        if err != nil {
+            return "", nil, err
+        }
+        ciChanges, err := addDeployJobs(w, rootDir, deploy, spec.App, spec.Envs)
+        if err != nil {
+            return "", nil, err
+        }
+        return deployPath(rootDir, deploy, spec.App), append(changes, ciChanges...), nil
+    }
+    invPath := filepath.Join(rootDir, "ansible", spec.App, "inventory.yml")
+    raw, err := os.ReadFile(invPath)
+    if err != nil {
+        return "", nil, fmt.Errorf("%s has not been bootstrapped: %w", spec.App, err)
+    }
+    var doc yaml.Node
+    if err := yaml.Unmarshal(raw, &doc); err != nil {
+        return "", nil, err
+    }
+    children, err := inventoryChildren(&doc)
+    if err != nil {
+        return "", nil, fmt.Errorf("%s: %w", invPath, err)
+    }
+
+    var changes []string
+    for _, env := range spec.Envs {
+        group := mapValue(children, env)
+        if group == nil {
+            cfg, err := spec.EnvConfig(env)
+            if err != nil {
+                return "", nil, err
+            }
+            n, err := groupNode(env, cfg)
+            if err != nil {
+                return "", nil, err
+            }
+            appendPair(children, env, n)
+            changes = append(changes, fmt.Sprintf("%s: added environment with %d host(s)", env, len(cfg.Hosts)))
+            continue
+        }
+        if es, ok := spec.Environments[env]; ok {
+            added, err := mergeHosts(group, spec, env, es)
+            if err != nil {
+                return "", nil, err
+            }
+            changes = append(changes, added...)
+        }
+    }
+    if len(changes) > 0 {
+        out, err := yaml.Marshal(&doc)
+        if err != nil {
+            return "", nil, err
+        }
+        if err := w.WriteFile(invPath, out); err != nil {
+            return "", nil, err
+        }
+    }
+
+    ciChanges, err := addDeployJobs(w, rootDir, "ansible", spec.App, spec.Envs)
+    if err != nil {
+        return "", nil, err
+    }
+    return invPath, append(changes, ciChanges...), nil
+}
+
+// groupNode renders cfg as the node of env's group, exactly as Apply would
+// have written it.
+func groupNode(env string, cfg EnvConfig) (*yaml.Node, error) {
+    inv, err := BuildInventory([]string{env}, map[string]EnvConfig{env: cfg}, "", "")
+    if err != nil {
+        return nil, err
+    }
+    out, err := MarshalInventory(inv)
+    if err != nil {
+        return nil, err
+    }
+    var doc yaml.Node
+    if err := yaml.Unmarshal(out, &doc); err != nil {
+        return nil, err
+    }
+    children, err := inventoryChildren(&doc)
+    if err != nil {
+        return nil, err
+    }
+    return mapValue(children, env), nil
+}
+
+// mergeHosts adds es to an existing env group and reports what it added.
+func mergeHosts(group *yaml.Node, spec Spec, env string, es EnvSpec) ([]string, error) {
+    if group.Kind != yaml.MappingNode {
+        return nil, fmt.Errorf("inventory group %q is not a mapping", env)
+    }
+    var changes []string
+    if es.AnsibleUser != "" {
+        vars := mapValue(group, "vars")
+        if vars == nil {
+            vars = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+            appendPair(group, "vars", vars)
+        }
+        if user := mapValue(vars, "ansible_user"); user == nil {
+            appendPair(vars, "ansible_user", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: es.AnsibleUser})
+            changes = append(changes, fmt.Sprintf("%s: set ansible_user %s", env, es.AnsibleUser))
+        } else if user.Value != es.AnsibleUser {
+            changes = append(changes, fmt.Sprintf("%s: ansible_user %s → %s", env, user.Value, es.AnsibleUser))
+            user.Value = es.AnsibleUser
+        }
+    }
+
+    hosts := mapValue(group, "hosts")
+    if hosts == nil {
+        hosts = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+        appendPair(group, "hosts", hosts)
+    }
+    names, addrs := map[string]bool{}, map[string]bool{}
+    for i := 0; i+1 < len(hosts.Content); i += 2 {
+        names[hosts.Content[i].Value] = true
+        if addr := mapValue(hosts.Content[i+1], "ansible_host"); addr != nil {
+            addrs[addr.Value] = true
+        }
+    }
+    var fresh []string
+    for _, addr := range es.Hosts {
+        if !addrs[addr] {
+            fresh = append(fresh, addr)
+            addrs[addr] = true
+        }
+    }
+    if len(fresh) == 0 {
+        return changes, nil
+    }
+
+    // real hosts replace the placeholder seeded when the env was skipped
+    for i := 0; i+1 < len(hosts.Content); {
+        if addr := mapValue(hosts.Content[i+1], "ansible_host"); addr != nil && addr.Value == PlaceholderIP {
+            changes = append(changes, fmt.Sprintf("%s: removed placeholder %s", env, hosts.Content[i].Value))
+            delete(names, hosts.Content[i].Value)
+            hosts.Content = slices.Delete(hosts.Content, i, i+2)
+            continue
+        }
+        i += 2
+    }
+    namer, err := NewHostNamer(spec.HostName)
+    if err != nil {
+        return nil, err
+    }
+    idx := 1
+    for _, addr := range fresh {
+        var name string
+        for {
+            if name, err = namer.Name(spec.App, idx, spec.Region, env); err != nil {
+                return nil, err
+            }
+            if !names[name] {
+                break
+            }
+            idx++
+        }
+        names[name] = true
+        var n yaml.Node
+        if err := n.Encode(map[string]string{"ansible_host": addr}); err != nil {
+            return nil, err
+        }
+        appendPair(hosts, name, &n)
+        changes = append(changes, fmt.Sprintf("%s: added host %s (%s)", env, name, addr))
+    }
+    return changes, nil
+}
+
+// addDeployJobs appends a deploy job to .gitlab/<app>.yml for every env that
+// does not have one yet.
+func addDeployJobs(w Writer, rootDir, deploy, app string, envs []string) ([]string, error) {
+    ciPath := filepath.Join(rootDir, ".gitlab", app+".yml")
+    raw, err := os.ReadFile(ciPath)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    var (
+        b       strings.Builder
+        changes []string
+    )
+    for _, env := range envs {
+        if regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(env) + `_deploy:`).Match(raw) {
+            continue
+        }
+        b.WriteString(deployJob(deploy, app, env))
+        changes = append(changes, fmt.Sprintf("%s: added deploy job to %s", env, ciPath))
+    }
+    if b.Len() == 0 {
+        return nil, nil
+    }
+    return changes, w.WriteFile(ciPath, append(raw, b.String()...))
+}
+
+// inventoryChildren returns all.children of an inventory document.
+func inventoryChildren(doc *yaml.Node) (*yaml.Node, error) {
+    if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+        return nil, fmt.Errorf("inventory is empty")
+    }
+    children := mapValue(mapValue(doc.Content[0], "all"), "children")
+    if children == nil || children.Kind != yaml.MappingNode {
+        return nil, fmt.Errorf("inventory has no all.children mapping")
+    }
+    return children, nil
+}
+
+// mapValue returns the value for key in a mapping node, or nil.
+func mapValue(m *yaml.Node, key string) *yaml.Node {
+    if m == nil || m.Kind != yaml.MappingNode {
+        return nil
+    }
+    for i := 0; i+1 < len(m.Content); i += 2 {
+        if m.Content[i].Value == key {
+            return m.Content[i+1]
+        }
+    }
+    return nil
+}
+
+func appendPair(m *yaml.Node, key string, val *yaml.Node) {
+    m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/plan.go
+package bootstrap
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+
+    "github.com/yourorg/cli/internal/scaffold"
+)
+
+// Writer receives everything the scaffolder produces. DiskWriter writes it
+// out; a Plan only records it, for --dry-run.
+type Writer interface {
+    MkdirAll(dir string) error
+    WriteFile(path string, data []byte) error
+}
+
+// DiskWriter writes files, creating parent directories as needed.
+type DiskWriter struct{}
+
+func (DiskWriter) MkdirAll(dir string) error { return os.MkdirAll(dir, 0o755) }
+
+func (DiskWriter) WriteFile(path string, data []byte) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return err
+    }
+    return os.WriteFile(path, data, 0o644)
+}
+
+// Plan records what a run would do without touching the disk.
+type Plan struct {
+    Dirs  []string      // directories that do not exist yet
+    Files []PlannedFile // in write order; a later write to a path replaces the earlier one
+}
+
+// PlannedFile is one file a run would write. Old is nil for new files.
+type PlannedFile struct {
+    Path     string
+    Old, New []byte
+}
+
+func (p *Plan) MkdirAll(dir string) error {
+    if _, err := os.Stat(dir); err == nil {
+        return nil
+    } else if !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    for _, d := range p.Dirs {
+        if d == dir {
+            return nil
+        }
+    }
+    p.Dirs = append(p.Dirs, dir)
+    return nil
+}
+
+func (p *Plan) WriteFile(path string, data []byte) error {
+    for i := range p.Files {
+        if p.Files[i].Path == path {
+            p.Files[i].New = data
+            return nil
+        }
+    }
+    old, err := os.ReadFile(path)
+    if err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return err
+    }
+    p.Files = append(p.Files, PlannedFile{Path: path, Old: old, New: data})
+    return nil
+}
+
+// Print writes the plan as a tree of paths relative to rootDir – "+" new,
+// "~" modified, "=" unchanged – followed by a unified diff of every
+// modified file.
+func (p *Plan) Print(w io.Writer, rootDir string) {
+    rel := func(path string) string {
+        if r, err := filepath.Rel(rootDir, path); err == nil {
+            return filepath.ToSlash(r)
+        }
+        return path
+    }
+
+    type entry struct{ mark, path string }
+    var entries []entry
+    for _, d := range p.Dirs {
+        entries = append(entries, entry{"+", rel(d) + "/"})
+    }
+    var modified []PlannedFile
+    for _, f := range p.Files {
+        switch {
+        case f.Old == nil:
+            entries = append(entries, entry{"+", rel(f.Path)})
+        case bytes.Equal(f.Old, f.New):
+            entries = append(entries, entry{"=", rel(f.Path)})
+        default:
+            entries = append(entries, entry{"~", rel(f.Path)})
+            modified = append(modified, f)
+        }
+    }
+    sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+    fmt.Fprintf(w, "Dry run – nothing was written. Plan for %s:\n", rootDir)
+    for _, e := range entries {
+        fmt.Fprintf(w, "  %s %s\n", e.mark, e.path)
+    }
+    for _, f := range modified {
+        fmt.Fprintln(w)
+        writeUnifiedDiff(w, rel(f.Path), f.Old, f.New)
+    }
+}
+
+// Review turns the plan into the review page of the create-app forms: each
+// file by its path relative to rootDir, and for the ansible target the
+// hosts of every env of spec.
+func (p *Plan) Review(rootDir string, spec Spec) (scaffold.Review, error) {
+    r := scaffold.Review{App: spec.App}
+    for _, f := range p.Files {
+        mark := "~"
+        switch {
+        case f.Old == nil:
+            mark = "+"
+        case bytes.Equal(f.Old, f.New):
+            mark = "="
+        }
+        path := f.Path
+        if rel, err := filepath.Rel(rootDir, f.Path); err == nil {
+            path = filepath.ToSlash(rel)
+        }
+        r.Files = append(r.Files, scaffold.ReviewFile{Mark: mark, Path: path})
+    }
+    sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+    if deployTarget(spec.Deploy) != "ansible" {
+        return r, nil
+    }
+    for _, env := range spec.Envs {
+        cfg, err := spec.EnvConfig(env)
+        if err != nil {
+            return r, err
+        }
+        names := make([]string, 0, len(cfg.Hosts))
+        for name := range cfg.Hosts {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        for _, name := range names {
+            r.Hosts = append(r.Hosts, scaffold.ReviewHost{
+                Env: env, Name: name, Address: cfg.Hosts[name].AnsibleHost, User: cfg.AnsibleUser, Secret: cfg.KeyPath,
+            })
+        }
+    }
+    return r, nil
+}
+
+// diffOp is one line of a line diff: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+    kind byte
+    line string
+}
+
+// lineDiff is a plain LCS diff; scaffolded files are small enough for O(n·m).
+func lineDiff(a, b []string) []diffOp {
+    lcs := make([][]int, len(a)+1)
+    for i := range lcs {
+        lcs[i] = make([]int, len(b)+1)
+    }
+    for i := len(a) - 1; i >= 0; i-- {
+        for j := len(b) - 1; j >= 0; j-- {
+            if a[i] == b[j] {
+                lcs[i][j] = lcs[i+1][j+1] + 1
+            } else {
+                lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+            }
+        }
+    }
+
+    var ops []diffOp
+    i, j := 0, 0
+    for i < len(a) && j < len(b) {
+        switch {
+        case a[i] == b[j]:
+            ops = append(ops, diffOp{' ', a[i]})
+            i, j = i+1, j+1
+        case lcs[i+1][j] >= lcs[i][j+1]:
+            ops = append(ops, diffOp{'-', a[i]})
+            i++
+        default:
+            ops = append(ops, diffOp{'+', b[j]})
+            j++
+        }
+    }
+    for ; i < len(a); i++ {
+        ops = append(ops, diffOp{'-', a[i]})
+    }
+    for ; j < len(b); j++ {
+        ops = append(ops, diffOp{'+', b[j]})
+    }
+    return ops
+}
+
+func splitLines(data []byte) []string {
+    if len(data) == 0 {
+        return nil
+    }
+    return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// writeUnifiedDiff prints old → new in unified format with three lines of context.
+func writeUnifiedDiff(w io.Writer, name string, old, new []byte) {
+    const context = 3
+    ops := lineDiff(splitLines(old), splitLines(new))
+    fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", name, name)
+
+    for start := 0; start < len(ops); {
+        for start < len(ops) && ops[start].kind == ' ' {
+            start++
+        }
+        if start == len(ops) {
+            break
+        }
+        // grow the hunk while changes are close enough to share context
+        last := start
+        for k := start; k < len(ops) && k-last <= 2*context; k++ {
+            if ops[k].kind != ' ' {
+                last = k
+            }
+        }
+        lo, hi := max(start-context, 0), min(last+context+1, len(ops))
+
+        var aStart, bStart, aLen, bLen int
+        for _, op := range ops[:lo] {
+            if op.kind != '+' {
+                aStart++
+            }
+            if op.kind != '-' {
+                bStart++
+            }
+        }
+        for _, op := range ops[lo:hi] {
+            if op.kind != '+' {
+                aLen++
+            }
+            if op.kind != '-' {
+                bLen++
+            }
+        }
+        fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(aStart, aLen), hunkRange(bStart, bLen))
+        for _, op := range ops[lo:hi] {
+            fmt.Fprintf(w, "%c%s\n", op.kind, op.line)
+        }
+        start = hi
+    }
+}
+
+// hunkRange formats a hunk's start,length; empty ranges point at the line before.
+func hunkRange(start, n int) string {
+    if n == 0 {
+        return fmt.Sprintf("%d,0", start)
+    }
+    return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/check.go
+package bootstrap
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+// DefaultCheckTimeout bounds each port 22 probe.
+const DefaultCheckTimeout = 3 * time.Second
+
+// Checker validates configured environments before their inventory is
+// written: the SSH key exists with permissions ssh accepts, and every host
+// answers on the SSH port.
+type Checker struct {
+    Timeout time.Duration // per host; DefaultCheckTimeout if zero
+    Port    int           // 22 if zero
+}
+
+// CheckResult is the outcome of one check; Err is nil when it passed.
+type CheckResult struct {
+    Env    string
+    Target string // key path or host:port
+    Err    error
+}
+
+// checkable reports whether spec has an env Check would look at.
+func checkable(spec Spec) bool {
+    for _, env := range spec.Envs {
+        if es, ok := spec.Environments[env]; ok && es.KeyPath != "" && len(es.Hosts) > 0 {
+            return true
+        }
+    }
+    return false
+}
+
+// Check runs the checks for every selected env that has a key path and
+// hosts. Hosts are probed concurrently; results keep the spec's order.
+func (c Checker) Check(ctx context.Context, spec Spec) []CheckResult {
+    timeout, port := c.Timeout, c.Port
+    if timeout == 0 {
+        timeout = DefaultCheckTimeout
+    }
+    if port == 0 {
+        port = 22
+    }
+
+    var results []CheckResult
+    var probes []int // indexes into results still to dial
+    for _, env := range spec.Envs {
+        es, ok := spec.Environments[env]
+        if !ok || es.KeyPath == "" || len(es.Hosts) == 0 {
+            continue
+        }
+        results = append(results, CheckResult{Env: env, Target: es.KeyPath, Err: checkKey(es.KeyPath)})
+        for _, addr := range es.Hosts {
+            if addr == PlaceholderIP {
+                continue
+            }
+            probes = append(probes, len(results))
+            results = append(results, CheckResult{Env: env, Target: net.JoinHostPort(addr, strconv.Itoa(port))})
+        }
+    }
+
+    var wg sync.WaitGroup
+    for _, i := range probes {
+        wg.Add(1)
+        go func(r *CheckResult) {
+            defer wg.Done()
+            d := net.Dialer{Timeout: timeout}
+            conn, err := d.DialContext(ctx, "tcp", r.Target)
+            if err != nil {
+                r.Err = err
+                return
+            }
+            conn.Close()
+        }(&results[i])
+    }
+    wg.Wait()
+    return results
+}
+
+// checkKey verifies a private key file exists and, outside Windows, is not
+// readable by group or others – ssh refuses such keys.
+func checkKey(path string) error {
+    if rest, ok := strings.CutPrefix(path, "~/"); ok {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return err
+        }
+        path = filepath.Join(home, rest)
+    }
+    info, err := os.Stat(path)
+    if err != nil {
+        return err
+    }
+    if !info.Mode().IsRegular() {
+        return fmt.Errorf("not a regular file")
+    }
+    if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+        return fmt.Errorf("permissions %04o are too open (ssh wants 0600)", info.Mode().Perm())
+    }
+    return nil
+}
+
+// PrintChecks writes a summary of results and returns how many failed.
+func PrintChecks(w io.Writer, results []CheckResult) int {
+    failed := 0
+    fmt.Fprintln(w, "Preflight checks:")
+    for _, r := range results {
+        if r.Err != nil {
+            failed++
+            fmt.Fprintf(w, "  ✘ %-8s %s: %v\n", r.Env, r.Target, r.Err)
+        } else {
+            fmt.Fprintf(w, "  ✔ %-8s %s\n", r.Env, r.Target)
+        }
+    }
+    if failed > 0 {
+        fmt.Fprintf(w, "%d of %d checks failed\n", failed, len(results))
+    } else {
+        fmt.Fprintf(w, "all %d checks passed\n", len(results))
+    }
+    return failed
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/gitlab.go
+package bootstrap
+
+import (
+    "fmt"
+    "net/http"
+    "strings"
+
+    "gitlab.com/gitlab-org/api/client-go/gitlab"
+)
+
+// GitLabTarget is the project bootstrap creates CI/CD environments and
+// variables in, so the scaffolded pipeline runs without manual setup.
+type GitLabTarget struct {
+    Project string `yaml:"project"`       // numeric ID or group/name path
+    URL     string `yaml:"url,omitempty"` // https://gitlab.com if empty
+}
+
+// GitLabAPI is the part of the GitLab API that Provision needs.
+type GitLabAPI interface {
+    EnvironmentExists(name string) (bool, error)
+    CreateEnvironment(name, tier string) error
+    VariableExists(key, scope string) (bool, error)
+    CreateVariable(key, value, scope string) error
+}
+
+// envTiers maps the wizard's environments onto GitLab deployment tiers.
+var envTiers = map[string]string{"dev": "development", "qa": "testing", "staging": "staging", "prod": "production"}
+
+// secretPathFormat (app, env) is the SSH_KEY_SECRET_PATH placeholder, to be
+// pointed at wherever the env's deploy key really lives.
+const secretPathFormat = "secret/%s/%s/ssh-private-key"
+
+// Provision creates a GitLab environment per env in spec and, for the ansible
+// target, scoped to it the protected variables the deploy job needs:
+// ANSIBLE_REMOTE_USER and a SSH_KEY_SECRET_PATH placeholder. Whatever already
+// exists is left alone. It returns a line per thing created.
+func Provision(api GitLabAPI, spec Spec) ([]string, error) {
+    var created []string
+    for _, env := range spec.Envs {
+        ok, err := api.EnvironmentExists(env)
+        if err != nil {
+            return created, fmt.Errorf("environment %s: %w", env, err)
+        }
+        if !ok {
+            if err := api.CreateEnvironment(env, envTiers[env]); err != nil {
+                return created, fmt.Errorf("create environment %s: %w", env, err)
+            }
+            created = append(created, fmt.Sprintf("%s: created environment", env))
+        }
+        if deployTarget(spec.Deploy) != "ansible" {
+            continue
+        }
+
+        user := spec.Environments[env].AnsibleUser
+        if user == "" {
+            user = "deploy"
+        }
+        for _, v := range [][2]string{
+            {"ANSIBLE_REMOTE_USER", user},
+            {"SSH_KEY_SECRET_PATH", fmt.Sprintf(secretPathFormat, spec.App, env)},
+        } {
+            ok, err := api.VariableExists(v[0], env)
+            if err != nil {
+                return created, fmt.Errorf("variable %s (%s): %w", v[0], env, err)
+            }
+            if ok {
+                continue
+            }
+            if err := api.CreateVariable(v[0], v[1], env); err != nil {
+                return created, fmt.Errorf("create variable %s (%s): %w", v[0], env, err)
+            }
+            created = append(created, fmt.Sprintf("%s: created protected variable %s=%s", env, v[0], v[1]))
+        }
+    }
+    return created, nil
+}
+
+// gitlabAPI is GitLabAPI over the official client.
+type gitlabAPI struct {
+    client  *gitlab.Client
+    project string
+}
+
+// NewGitLabAPI returns a GitLabAPI for target; token needs the api scope.
+func NewGitLabAPI(target GitLabTarget, token string) (GitLabAPI, error) {
+    var opts []gitlab.ClientOptionFunc
+    if target.URL != "" {
+        opts = append(opts, gitlab.WithBaseURL(strings.TrimSuffix(target.URL, "/")+"/api/v4"))
+    }
+    client, err := gitlab.NewClient(token, opts...)
+    if err != nil {
+        return nil, err
+    }
+    return gitlabAPI{client, target.Project}, nil
+}
+
+func (g gitlabAPI) EnvironmentExists(name string) (bool, error) {
+    envs, _, err := g.client.Environments.ListEnvironments(g.project, &gitlab.ListEnvironmentsOptions{Name: gitlab.Ptr(name)})
+    return len(envs) > 0, err
+}
+
+func (g gitlabAPI) CreateEnvironment(name, tier string) error {
+    opt := &gitlab.CreateEnvironmentOptions{Name: gitlab.Ptr(name)}
+    if tier != "" {
+        opt.Tier = gitlab.Ptr(tier)
+    }
+    _, _, err := g.client.Environments.CreateEnvironment(g.project, opt)
+    return err
+}
+
+func (g gitlabAPI) VariableExists(key, scope string) (bool, error) {
+    _, resp, err := g.client.ProjectVariables.GetVariable(g.project, key, &gitlab.GetProjectVariableOptions{
+        Filter: &gitlab.VariableFilter{EnvironmentScope: scope},
+    })
+    if resp != nil && resp.StatusCode == http.StatusNotFound {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+func (g gitlabAPI) CreateVariable(key, value, scope string) error {
+    _, _, err := g.client.ProjectVariables.CreateVariable(g.project, &gitlab.CreateProjectVariableOptions{
+        Key:              gitlab.Ptr(key),
+        Value:            gitlab.Ptr(value),
+        EnvironmentScope: gitlab.Ptr(scope),
+        Protected:        gitlab.Ptr(true),
+        VariableType:     gitlab.Ptr(gitlab.EnvVariableType),
+    })
+    return err
+}
+
+// ────────────────────────────────────────────────────────────────────────────────
+// file: pkg/bootstrap/wizard.go
+package bootstrap
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+
+    "github.com/charmbracelet/huh/v2"
+
+    "github.com/yourorg/cli/internal/scaffold"
+)
+
+// AskSpec runs the wizard and returns the Spec to Apply, once the files it
+// writes have been confirmed on the review page.
+func AskSpec(rootDir string) (Spec, error) {
+    var (
+        appName, region, language string
+        deploy, hostName          string
+        envChoices                []string
+    )
+
+    form := huh.NewForm(
+        huh.NewGroup(
+            huh.NewNote().Title("Let’s start with the basics…"),
+            huh.NewInput().Title("App name").Value(&appName).Validate(huh.ValidateNotEmpty()),
+            huh.NewSelect[string]().Title("Region").Options(huh.NewOptions(Regions...)...).Value(&region),
+            huh.NewSelect[string]().Title("Language").Options(huh.NewOptions(Languages...)...).Value(&language),
+            huh.NewSelect[string]().Title("Deploy with").Options(huh.NewOptions(Deployments...)...).Value(&deploy),
+            huh.NewInput().
+                Title("Host name template").
+                Description("Leave empty for app_###_region_env").
+                Placeholder(DefaultHostNameTemplate).
+                Value(&hostName).
+                Validate(func(s string) error { _, err := NewHostNamer(s); return err }),
+        ),
+        huh.NewGroup(
+            huh.NewNote().Title("Pick all environments you’ll deploy to"),
+            huh.NewMultiSelect[string]().Title("Environments").Options(huh.NewOptions(Environments...)...).Value(&envChoices),
+        ),
+    ).WithTheme(huh.ThemeCharm(true))
+
+    if err := form.Run(); err != nil {
+        return Spec{}, err
+    }
+
+    spec := Spec{App: appName, Region: region, Language: language, Deploy: deploy, Envs: envChoices, HostName: hostName, Environments: map[string]EnvSpec{}}
+    for _, env := range envChoices {
+        if deploy != "ansible" {
+            break // compose and helm have no hosts to configure
+        }
+        var configure bool
+        if err := huh.NewForm(
+            huh.NewGroup(
+                huh.NewConfirm().Title(fmt.Sprintf("Configure %q now?", env)).Affirmative("Yes").Negative("Skip").Value(&configure),
+            ),
+        ).Run(); err != nil {
+            return spec, err
+        }
+        if !configure {
+            continue
+        }
+        cfg, err := collectEnvDetails(env, EnvSpec{})
+        if err != nil {
+            return spec, err
+        }
+        spec.Environments[env] = cfg
+    }
+
+    if err := collectRegistration(rootDir, &spec); err != nil {
+        return spec, err
+    }
+    if err := collectGitLab(&spec); err != nil {
+        return spec, err
+    }
+    if err := preflight(spec); err != nil {
+        return spec, err
+    }
+    return spec, review(rootDir, spec, func(w Writer) error {
+        _, err := ApplyTo(w, rootDir, spec)
+        return err
+    })
+}
+
+// AskEdit pre-fills the wizard from an app bootstrapped earlier and returns
+// the Spec to ApplyEdit, once confirmed on the review page. hostName is the
+// template the app's hosts were named with (empty for the default).
+func AskEdit(rootDir, app, hostName string) (Spec, error) {
+    spec, err := LoadExisting(rootDir, app, hostName)
+    if err != nil {
+        return spec, err
+    }
+    existing := slices.Clone(spec.Envs)
+    where := spec.Region
+    if where == "" {
+        where = spec.Deploy
+    }
+
+    if err := huh.NewForm(
+        huh.NewGroup(
+            huh.NewNote().
+                Title(fmt.Sprintf("Editing %s (%s)", app, where)).
+                Description("Existing environments and hosts are kept; pick what to add."),
+            huh.NewMultiSelect[string]().Title("Environments").Options(huh.NewOptions(Environments...)...).Value(&spec.Envs),
+        ),
+    ).WithTheme(huh.ThemeCharm(true)).Run(); err != nil {
+        return spec, err
+    }
+
+    for _, env := range spec.Envs {
+        if deployTarget(spec.Deploy) != "ansible" {
+            break
+        }
+        title := fmt.Sprintf("Configure %q now?", env)
+        if slices.Contains(existing, env) {
+            title = fmt.Sprintf("Add hosts to %q?", env)
+        }
+        var configure bool
+        if err := huh.NewForm(
+            huh.NewGroup(
+                huh.NewConfirm().Title(title).Affirmative("Yes").Negative("Skip").Value(&configure),
+            ),
+        ).Run(); err != nil {
+            return spec, err
+        }
+        if !configure {
+            continue
+        }
+        cfg, err := collectEnvDetails(env, EnvSpec{AnsibleUser: spec.Environments[env].AnsibleUser})
+        if err != nil {
+            return spec, err
+        }
+        spec.Environments[env] = cfg
+    }
+
+    if err := collectGitLab(&spec); err != nil {
+        return spec, err
+    }
+    if err := preflight(spec); err != nil {
+        return spec, err
+    }
+    return spec, review(rootDir, spec, func(w Writer) error {
+        _, _, err := ApplyEditTo(w, rootDir, spec)
+        return err
+    })
+}
+
+// review plans spec with apply and shows the files and inventory entries it
+// would write on the review page shared with the create-app form.
+func review(rootDir string, spec Spec, apply func(Writer) error) error {
+    plan := &Plan{}
+    if err := apply(plan); err != nil {
+        return err
+    }
+    r, err := plan.Review(rootDir, spec)
+    if err != nil {
+        return err
+    }
+    return scaffold.ConfirmReview(context.Background(), r)
+}
+
+// collectGitLab offers to provision the GitLab environments and variables
+// the scaffolded pipeline expects.
+func collectGitLab(spec *Spec) error {
+    var ok bool
+    if err := huh.NewForm(
+        huh.NewGroup(
+            huh.NewConfirm().Title("Create the GitLab environments and protected variables?").Affirmative("Yes").Negative("Skip").Value(&ok),
+        ),
+    ).Run(); err != nil || !ok {
+        return err
+    }
+    var target GitLabTarget
+    if err := huh.NewForm(
         huh.NewGroup(
-            huh.NewNote().Title("Let’s start with the basics…"),
-            huh.NewInput().Title("App name").Value(&appName).Validate(huh.ValidateNotEmpty()),
-            huh.NewSelect[string]().Title("Region").Options(huh.NewOptions("us-east-1", "us-west-2", "eu-west-1")...).Value(&region),
-            huh.NewSelect[string]().Title("Language").Options(huh.NewOptions("Java", "C")...).Value(&language),
+            huh.NewInput().Title("GitLab project").Placeholder("group/"+spec.App).Value(&target.Project).Validate(huh.ValidateNotEmpty()),
+            huh.NewInput().Title("GitLab URL").Placeholder("https://gitlab.com").Value(&target.URL),
         ),
+    ).Run(); err != nil {
+        return err
+    }
+    spec.GitLab = &target
+    return nil
+}
+
+// preflight offers to run a Checker over the configured environments and,
+// if anything fails, asks whether to write the inventory anyway.
+func preflight(spec Spec) error {
+    if !checkable(spec) {
+        return nil
+    }
+    var run bool
+    if err := huh.NewForm(
         huh.NewGroup(
-            huh.NewNote().Title("Pick all environments you’ll deploy to"),
-            huh.NewMultiSelect[string]().Title("Environments").Options(huh.NewOptions("dev", "qa", "staging", "prod")...).Value(&envChoices),
+            huh.NewConfirm().Title("Check SSH keys and that hosts answer on port 22?").Affirmative("Yes").Negative("Skip").Value(&run),
         ),
-    ).WithTheme(huh.ThemeCharm(true))
+    ).Run(); err != nil {
+        return err
+    }
+    if !run {
+        return nil
+    }
+    if PrintChecks(os.Stdout, Checker{}.Check(context.Background(), spec)) == 0 {
+        return nil
+    }
 
-    if err := form.Run(); err != nil {
-        return "", err
+    var proceed bool
+    if err := huh.NewForm(
+        huh.NewGroup(
+            huh.NewConfirm().Title("Some checks failed. Write the inventory anyway?").Affirmative("Yes").Negative("No").Value(&proceed),
+        ),
+    ).Run(); err != nil {
+        return err
+    }
+    if !proceed {
+        return errors.New("aborted after failed preflight checks")
     }
+    return nil
+}
 
-    explicit := map[string]EnvConfig{}
-    for _, env := range envChoices {
-        var configure bool
-        if err := huh.NewForm(
-            huh.NewGroup(
-                huh.NewConfirm().Title(fmt.Sprintf("Configure %q now?", env)).Affirmative("Yes").Negative("Skip").Value(&configure),
-            ),
-        ).Run(); err != nil {
-            return "", err
+// collectRegistration offers to add the app to the topology and project
+// metadata found under rootDir, picking its dependencies from them.
+func collectRegistration(rootDir string, spec *Spec) error {
+    if apps, err := TopologyApps(filepath.Join(rootDir, DefaultTopology)); err == nil {
+        var ok bool
+        fields := []huh.Field{
+            huh.NewConfirm().Title(fmt.Sprintf("Register %s in %s?", spec.App, DefaultTopology)).Value(&ok),
         }
-        if !configure {
-            continue
+        if len(apps) > 0 {
+            fields = append(fields, huh.NewMultiSelect[string]().Title("Depends on").Options(huh.NewOptions(apps...)...).Value(&spec.DependsOn))
         }
-        cfg, err := collectEnvDetails(appName, region, env)
-        if err != nil {
-            return "", err
+        if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+            return err
+        }
+        if ok {
+            spec.Topology = DefaultTopology
+        } else {
+            spec.DependsOn = nil
         }
-        explicit[env] = cfg
-    }
-
-    inv := BuildInventory(envChoices, explicit, appName, region)
-    invYAML, err := MarshalInventory(inv)
-    if err != nil {
-        return "", err
     }
 
-    if err := WriteSkeleton(rootDir, appName, invYAML, envChoices); err != nil {
-        return "", err
+    if projects, err := ProjectNames(filepath.Join(rootDir, DefaultProjects)); err == nil {
+        var ok bool
+        fields := []huh.Field{
+            huh.NewConfirm().Title(fmt.Sprintf("Add :apps:%s to %s?", spec.App, DefaultProjects)).Value(&ok),
+        }
+        if len(projects) > 0 {
+            fields = append(fields, huh.NewMultiSelect[string]().Title("Builds against").Options(huh.NewOptions(projects...)...).Value(&spec.Libraries))
+        }
+        if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+            return err
+        }
+        if ok {
+            spec.Projects = DefaultProjects
+        } else {
+            spec.Libraries = nil
+        }
     }
-
-    invPath := fmt.Sprintf("%s/ansible/%s/inventory.yml", rootDir, appName)
-    return invPath, nil
+    return nil
 }
 
-// collectEnvDetails captures user + key + arbitrary hosts for one environment.
-func collectEnvDetails(app, region, env string) (EnvConfig, error) {
-    var cfg EnvConfig
-    cfg.Hosts = make(map[string]Host)
+// collectEnvDetails captures user + key + arbitrary hosts for one
+// environment; cfg pre-fills the credentials.
+func collectEnvDetails(env string, cfg EnvSpec) (EnvSpec, error) {
 
     // ── credentials page ────────────────────────────────────────────────
     if err := huh.NewForm(
@@ -235,8 +2371,7 @@ func collectEnvDetails(app, region, env string) (EnvConfig, error) {
             return cfg, err
         }
 
-        hostName := GenerateHostName(app, idx, region, env)
-        cfg.Hosts[hostName] = Host{AnsibleHost: hostAddr}
+        cfg.Hosts = append(cfg.Hosts, hostAddr)
 
         if !again {
             break
@@ -254,49 +2389,314 @@ package cmd
 
 import (
     "fmt"
+    "slices"
+    "strings"
 
     "github.com/spf13/cobra"
 
+    "github.com/yourorg/cli/internal/config"
     "github.com/yourorg/cli/pkg/bootstrap"
 )
 
 func NewBootstrapCmd() *cobra.Command {
-    var rootDir string
+    var (
+        rootDir, configPath    string
+        dryRun                 bool
+        checker                preflightFlags
+        gitlab                 bootstrap.GitLabTarget
+        spec                   bootstrap.Spec
+        users, keys, hostFlags []string
+    )
     cmd := &cobra.Command{
         Use:   "bootstrap",
         Short: "Interactive wizard to scaffold an app, inventory, and GitLab CI",
+        Long: `Interactive wizard to scaffold an app, inventory, and GitLab CI.
+
+With --config or --app the wizard is skipped and the scaffold is driven by
+the spec file and flags alone (flags win over the file), e.g.
+
+  bootstrap --app billing --region us-east-1 --env dev --env prod \
+    --user dev=deploy --host dev=10.0.0.5 --host dev=10.0.0.6`,
         RunE: func(cmd *cobra.Command, _ []string) error {
-            path, err := bootstrap.RunWizard(rootDir)
+            var (
+                s   bootstrap.Spec
+                err error
+            )
+            if configPath == "" && spec.App == "" {
+                s, err = bootstrap.AskSpec(rootDir)
+            } else if s, err = specFromFlags(configPath, spec, users, keys, hostFlags); err == nil {
+                err = checker.run(cmd, s)
+            }
             if err != nil {
                 return err
             }
-            fmt.Printf("\n✅  Scaffold complete! Inventory written to %s\n", path)
-            return nil
+
+            w, plan := writerFor(dryRun)
+            path, err := bootstrap.ApplyTo(w, rootDir, s)
+            if err != nil {
+                return err
+            }
+            if plan != nil {
+                plan.Print(cmd.OutOrStdout(), rootDir)
+            } else {
+                fmt.Printf("\n✅  Scaffold complete! Deployment written to %s\n", path)
+            }
+            return provision(cmd, s, gitlab, dryRun)
+        },
+    }
+    cmd.PersistentFlags().StringVar(&rootDir, "root", ".", "project root (defaults to cwd)")
+    cmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the files that would be created or changed, with diffs, and write nothing")
+    cmd.PersistentFlags().BoolVar(&checker.enabled, "check", false, "without the wizard: check SSH keys and port 22 on every configured host first, and stop if any check fails")
+    cmd.PersistentFlags().DurationVar(&checker.Timeout, "check-timeout", bootstrap.DefaultCheckTimeout, "per-host timeout for --check")
+    cmd.PersistentFlags().StringVar(&gitlab.Project, "gitlab-project", "", "create the CI/CD environments and protected variables in this GitLab project, using the stored token (needs api scope)")
+    cmd.PersistentFlags().StringVar(&gitlab.URL, "gitlab-url", "", "GitLab URL for --gitlab-project (default https://gitlab.com)")
+    cmd.Flags().StringVar(&configPath, "config", "", "YAML spec to scaffold from without the wizard (see bootstrap.Spec)")
+    cmd.Flags().StringVar(&spec.App, "app", "", "app name; skips the wizard")
+    cmd.Flags().StringVar(&spec.Region, "region", "", "region, one of "+strings.Join(bootstrap.Regions, ", "))
+    cmd.Flags().StringVar(&spec.Language, "language", "", "language, one of "+strings.Join(bootstrap.Languages, ", "))
+    cmd.Flags().StringVar(&spec.Deploy, "deploy", "", "deployment skeleton, one of "+strings.Join(bootstrap.Deployments, ", ")+" (default ansible)")
+    cmd.Flags().StringSliceVar(&spec.Envs, "env", nil, "environment to deploy to (repeatable), one of "+strings.Join(bootstrap.Environments, ", "))
+    cmd.Flags().StringArrayVar(&users, "user", nil, "ansible_user for an environment, as env=user (repeatable)")
+    cmd.Flags().StringArrayVar(&keys, "key", nil, "SSH key path for an environment, as env=path (repeatable)")
+    cmd.Flags().StringArrayVar(&hostFlags, "host", nil, "host address for an environment, as env=address (repeatable, in order)")
+    cmd.Flags().StringVar(&spec.HostName, "host-name", "", "host naming template over .App .Index .Region .Env (default "+bootstrap.DefaultHostNameTemplate+")")
+    cmd.Flags().StringVar(&spec.Topology, "topology", "", "register the app in this topology file (relative to --root)")
+    cmd.Flags().StringSliceVar(&spec.DependsOn, "depends-on", nil, "topology apps the new app depends on (repeatable)")
+    cmd.Flags().StringVar(&spec.Projects, "projects", "", "add the app to this project metadata file (relative to --root)")
+    cmd.Flags().StringSliceVar(&spec.Libraries, "library", nil, "projects the new app builds against (repeatable)")
+    cmd.AddCommand(newBootstrapEditCmd(&rootDir, &dryRun, &checker, &gitlab))
+    return cmd
+}
+
+// provision creates the GitLab environments and variables when the spec or
+// --gitlab-project (which wins) names a project.
+func provision(cmd *cobra.Command, spec bootstrap.Spec, flag bootstrap.GitLabTarget, dryRun bool) error {
+    target := spec.GitLab
+    if flag.Project != "" {
+        target = &flag
+    }
+    if target == nil {
+        return nil
+    }
+    out := cmd.OutOrStdout()
+    if dryRun {
+        fmt.Fprintf(out, "\nGitLab: would create missing environments and variables for %s in %s\n", strings.Join(spec.Envs, ", "), target.Project)
+        return nil
+    }
+
+    token, err := config.Token()
+    if err != nil {
+        return err
+    }
+    api, err := bootstrap.NewGitLabAPI(*target, token)
+    if err != nil {
+        return err
+    }
+    created, err := bootstrap.Provision(api, spec)
+    for _, c := range created {
+        fmt.Fprintf(out, "  + %s\n", c)
+    }
+    if err != nil {
+        return fmt.Errorf("gitlab %s: %w", target.Project, err)
+    }
+    if len(created) == 0 {
+        fmt.Fprintf(out, "GitLab: %s already has everything\n", target.Project)
+    }
+    return nil
+}
+
+// preflightFlags is --check and --check-timeout; the wizard asks instead.
+type preflightFlags struct {
+    bootstrap.Checker
+    enabled bool
+}
+
+func (f preflightFlags) run(cmd *cobra.Command, spec bootstrap.Spec) error {
+    if !f.enabled {
+        return nil
+    }
+    if n := bootstrap.PrintChecks(cmd.OutOrStdout(), f.Check(cmd.Context(), spec)); n > 0 {
+        return fmt.Errorf("%d preflight check(s) failed; nothing was written", n)
+    }
+    fmt.Fprintln(cmd.OutOrStdout())
+    return nil
+}
+
+// writerFor returns the disk, or a Plan to print afterwards when dryRun is set.
+func writerFor(dryRun bool) (bootstrap.Writer, *bootstrap.Plan) {
+    if !dryRun {
+        return bootstrap.DiskWriter{}, nil
+    }
+    plan := &bootstrap.Plan{}
+    return plan, plan
+}
+
+func newBootstrapEditCmd(rootDir *string, dryRun *bool, checker *preflightFlags, gitlab *bootstrap.GitLabTarget) *cobra.Command {
+    var (
+        flags                  bootstrap.Spec
+        users, keys, hostFlags []string
+    )
+    cmd := &cobra.Command{
+        Use:   "edit <app>",
+        Short: "Add environments or hosts to an app bootstrapped earlier",
+        Long: `Add environments or hosts to an app bootstrapped earlier.
+
+The wizard is pre-filled from ansible/<app>/inventory.yml and only the delta
+is applied: new environments get an inventory group and a deploy job, new
+host addresses are appended. Nothing already there is overwritten. Any of
+--env, --user, --key or --host skips the wizard, e.g.
+
+  bootstrap edit billing --env qa --host qa=10.0.1.5`,
+        Args: cobra.ExactArgs(1),
+        RunE: func(cmd *cobra.Command, args []string) error {
+            var (
+                spec bootstrap.Spec
+                err  error
+            )
+            if len(flags.Envs)+len(users)+len(keys)+len(hostFlags) == 0 {
+                spec, err = bootstrap.AskEdit(*rootDir, args[0], flags.HostName)
+            } else if spec, err = bootstrap.LoadExisting(*rootDir, args[0], flags.HostName); err == nil {
+                for _, env := range flags.Envs {
+                    if !slices.Contains(spec.Envs, env) {
+                        spec.Envs = append(spec.Envs, env)
+                    }
+                }
+                if err = overlayEnvFlags(&spec, users, keys, hostFlags); err == nil {
+                    err = checker.run(cmd, spec)
+                }
+            }
+            if err != nil {
+                return err
+            }
+
+            w, plan := writerFor(*dryRun)
+            path, changes, err := bootstrap.ApplyEditTo(w, *rootDir, spec)
+            switch {
+            case err != nil:
+                return err
+            case plan != nil:
+                plan.Print(cmd.OutOrStdout(), *rootDir)
+            case len(changes) == 0:
+                fmt.Printf("Nothing to change in %s\n", path)
+            default:
+                for _, c := range changes {
+                    fmt.Printf("  + %s\n", c)
+                }
+                fmt.Printf("\n✅  %s updated\n", args[0])
+            }
+            return provision(cmd, spec, *gitlab, *dryRun)
         },
     }
-    cmd.Flags().StringVar(&rootDir, "root", ".", "project root (defaults to cwd)")
+    cmd.Flags().StringSliceVar(&flags.Envs, "env", nil, "environment to add (repeatable)")
+    cmd.Flags().StringArrayVar(&users, "user", nil, "ansible_user for an environment, as env=user (repeatable)")
+    cmd.Flags().StringArrayVar(&keys, "key", nil, "SSH key path for an environment, as env=path (repeatable)")
+    cmd.Flags().StringArrayVar(&hostFlags, "host", nil, "host address to add to an environment, as env=address (repeatable)")
+    cmd.Flags().StringVar(&flags.HostName, "host-name", "", "host naming template the app was bootstrapped with, if not the default")
     return cmd
 }
 
+// specFromFlags loads the spec file, if any, and lays the flags over it.
+func specFromFlags(configPath string, flags bootstrap.Spec, users, keys, hosts []string) (bootstrap.Spec, error) {
+    var spec bootstrap.Spec
+    if configPath != "" {
+        var err error
+        if spec, err = bootstrap.LoadSpec(configPath); err != nil {
+            return spec, err
+        }
+    }
+    if flags.App != "" {
+        spec.App = flags.App
+    }
+    if flags.Region != "" {
+        spec.Region = flags.Region
+    }
+    if flags.Language != "" {
+        spec.Language = flags.Language
+    }
+    if flags.Deploy != "" {
+        spec.Deploy = flags.Deploy
+    }
+    if len(flags.Envs) > 0 {
+        spec.Envs = flags.Envs
+    }
+    if flags.HostName != "" {
+        spec.HostName = flags.HostName
+    }
+    if flags.Topology != "" {
+        spec.Topology = flags.Topology
+    }
+    if len(flags.DependsOn) > 0 {
+        spec.DependsOn = flags.DependsOn
+    }
+    if flags.Projects != "" {
+        spec.Projects = flags.Projects
+    }
+    if len(flags.Libraries) > 0 {
+        spec.Libraries = flags.Libraries
+    }
+    return spec, overlayEnvFlags(&spec, users, keys, hosts)
+}
+
+// overlayEnvFlags applies the env=value flags to spec's environments.
+func overlayEnvFlags(spec *bootstrap.Spec, users, keys, hosts []string) error {
+    if spec.Environments == nil {
+        spec.Environments = map[string]bootstrap.EnvSpec{}
+    }
+
+    set := func(flag string, values []string, apply func(env string, es *bootstrap.EnvSpec, v string)) error {
+        for _, v := range values {
+            env, val, ok := strings.Cut(v, "=")
+            if !ok || env == "" || val == "" {
+                return fmt.Errorf("--%s %q: want env=value", flag, v)
+            }
+            es := spec.Environments[env]
+            apply(env, &es, val)
+            spec.Environments[env] = es
+        }
+        return nil
+    }
+    if err := set("user", users, func(_ string, es *bootstrap.EnvSpec, v string) { es.AnsibleUser = v }); err != nil {
+        return err
+    }
+    if err := set("key", keys, func(_ string, es *bootstrap.EnvSpec, v string) { es.KeyPath = v }); err != nil {
+        return err
+    }
+    // hosts given on the command line replace the file's list for that env
+    replaced := map[string]bool{}
+    return set("host", hosts, func(env string, es *bootstrap.EnvSpec, v string) {
+        if !replaced[env] {
+            es.Hosts, replaced[env] = nil, true
+        }
+        es.Hosts = append(es.Hosts, v)
+    })
+}
+
 // ────────────────────────────────────────────────────────────────────────────────
 // file: pkg/bootstrap/scaffold_test.go
 package bootstrap_test
 
 import (
+    "context"
+    "net"
     "os"
     "path/filepath"
+    "strings"
     "testing"
+    "time"
 
+    "github.com/your-username/ansible-inventory-go/ansibleinv"
     "github.com/yourorg/cli/pkg/bootstrap"
 )
 
 func TestWriteSkeleton(t *testing.T) {
     dir := t.TempDir()
-    inv := bootstrap.Inventory{}
-    inv.All.Children = map[string]bootstrap.EnvConfig{"dev": {}}
+    inv, err := bootstrap.BuildInventory([]string{"dev"}, map[string]bootstrap.EnvConfig{"dev": {}}, "svc", "us-east-1")
+    if err != nil {
+        t.Fatalf("BuildInventory failed: %v", err)
+    }
     yamlBytes, _ := bootstrap.MarshalInventory(inv)
 
-    if err := bootstrap.WriteSkeleton(dir, "svc", yamlBytes, []string{"dev"}); err != nil {
+    if err := bootstrap.WriteSkeleton(dir, bootstrap.Options{App: "svc", Envs: []string{"dev"}, Inventory: yamlBytes}); err != nil {
         t.Fatalf("WriteSkeleton failed: %v", err)
     }
 
@@ -307,3 +2707,314 @@ func TestWriteSkeleton(t *testing.T) {
         t.Fatalf("ci file missing: %v", err)
     }
 }
+
+func TestApplySpec(t *testing.T) {
+    dir := t.TempDir()
+    specPath := filepath.Join(dir, "app.yaml")
+    os.WriteFile(specPath, []byte(`app: billing
+region: us-east-1
+language: Java
+envs: [dev, prod]
+environments:
+    dev:
+        ansible_user: deploy
+        hosts: [10.0.0.5]
+`), 0o644)
+
+    spec, err := bootstrap.LoadSpec(specPath)
+    if err != nil {
+        t.Fatalf("LoadSpec failed: %v", err)
+    }
+    invPath, err := bootstrap.Apply(dir, spec)
+    if err != nil {
+        t.Fatalf("Apply failed: %v", err)
+    }
+
+    inv, err := os.ReadFile(invPath)
+    if err != nil {
+        t.Fatalf("inventory missing: %v", err)
+    }
+    for _, want := range []string{"billing_001_us-east-1_dev", "10.0.0.5", "REPLACE_WITH_IP"} {
+        if !strings.Contains(string(inv), want) {
+            t.Errorf("inventory is missing %q:\n%s", want, inv)
+        }
+    }
+    parsed, err := ansibleinv.ParseYAMLFile(invPath)
+    if err != nil {
+        t.Fatalf("inventory does not parse: %v", err)
+    }
+    if user := parsed.Groups["dev"].Vars["ansible_user"]; user != "deploy" {
+        t.Errorf("expected dev vars to carry ansible_user deploy, got %v", user)
+    }
+    if _, ok := parsed.Groups["all"].Hosts["billing_001_us-east-1_dev"]; !ok {
+        t.Errorf("expected all to list the dev host, got %v", parsed.Groups["all"].Hosts)
+    }
+
+    if _, err := os.Stat(filepath.Join(dir, "apps", "billing", "src", "main", "java", "App.java")); err != nil {
+        t.Errorf("java source stub missing: %v", err)
+    }
+    ci, _ := os.ReadFile(filepath.Join(dir, ".gitlab", "billing.yml"))
+    if !strings.Contains(string(ci), "billing_build:") || !strings.Contains(string(ci), "gradle build") {
+        t.Errorf("ci jobs are missing the java build:\n%s", ci)
+    }
+
+    spec.Envs = []string{"uat"}
+    if _, err := bootstrap.Apply(dir, spec); err == nil {
+        t.Fatal("expected an unknown environment to be rejected")
+    }
+}
+
+func TestApplyRegistersApp(t *testing.T) {
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "topology.yaml"), []byte("version: 1\napps:\n  refdata:\n    depends_on: []\n"), 0o644)
+    os.WriteFile(filepath.Join(dir, "projects.json"), []byte(`{":libs:common": {"projectDir": "libs/common", "dependencies": []}}`), 0o644)
+
+    spec := bootstrap.Spec{
+        App: "billing", Region: "us-east-1", Envs: []string{"dev"},
+        Topology: "topology.yaml", DependsOn: []string{"refdata"},
+        Projects: "projects.json", Libraries: []string{":libs:common"},
+    }
+    if _, err := bootstrap.Apply(dir, spec); err != nil {
+        t.Fatalf("Apply failed: %v", err)
+    }
+
+    apps, err := bootstrap.TopologyApps(filepath.Join(dir, "topology.yaml"))
+    if err != nil || strings.Join(apps, ",") != "billing,refdata" {
+        t.Fatalf("topology apps = %v, %v", apps, err)
+    }
+    projects, _ := os.ReadFile(filepath.Join(dir, "projects.json"))
+    if !strings.Contains(string(projects), `":apps:billing"`) || !strings.Contains(string(projects), `"apps/billing"`) {
+        t.Fatalf("project not registered:\n%s", projects)
+    }
+
+    // registering twice, or against an unknown app, fails before writing anything
+    spec.App, spec.DependsOn = "reports", []string{"nope"}
+    if _, err := bootstrap.Apply(dir, spec); err == nil {
+        t.Fatal("expected an unknown dependency to be rejected")
+    }
+    if _, err := os.Stat(filepath.Join(dir, "apps", "reports")); err == nil {
+        t.Fatal("nothing should be scaffolded when registration fails")
+    }
+}
+
+func TestApplyEditAddsOnlyTheDelta(t *testing.T) {
+    dir := t.TempDir()
+    spec := bootstrap.Spec{
+        App: "billing", Region: "eu-west-1", Envs: []string{"dev", "prod"},
+        Environments: map[string]bootstrap.EnvSpec{"dev": {AnsibleUser: "deploy", Hosts: []string{"10.0.0.5"}}},
+    }
+    invPath, err := bootstrap.Apply(dir, spec)
+    if err != nil {
+        t.Fatalf("Apply failed: %v", err)
+    }
+
+    spec, err = bootstrap.LoadExisting(dir, "billing", "")
+    if err != nil {
+        t.Fatalf("LoadExisting failed: %v", err)
+    }
+    if spec.Region != "eu-west-1" || strings.Join(spec.Envs, ",") != "dev,prod" || len(spec.Environments["dev"].Hosts) != 1 {
+        t.Fatalf("unexpected spec: %+v", spec)
+    }
+
+    spec.Envs = append(spec.Envs, "qa")
+    spec.Environments["dev"] = bootstrap.EnvSpec{AnsibleUser: "deploy", Hosts: []string{"10.0.0.5", "10.0.0.6"}}
+    spec.Environments["prod"] = bootstrap.EnvSpec{AnsibleUser: "deploy", Hosts: []string{"10.9.0.1"}}
+    _, changes, err := bootstrap.ApplyEdit(dir, spec)
+    if err != nil {
+        t.Fatalf("ApplyEdit failed: %v", err)
+    }
+    if len(changes) == 0 {
+        t.Fatal("expected changes")
+    }
+
+    inv, _ := os.ReadFile(invPath)
+    for _, want := range []string{"billing_001_eu-west-1_dev", "billing_002_eu-west-1_dev", "10.0.0.6", "10.9.0.1", "billing_001_eu-west-1_qa"} {
+        if !strings.Contains(string(inv), want) {
+            t.Errorf("inventory is missing %q:\n%s", want, inv)
+        }
+    }
+    if strings.Count(string(inv), bootstrap.PlaceholderIP) != 1 {
+        t.Errorf("expected only qa to keep a placeholder:\n%s", inv)
+    }
+    ci, _ := os.ReadFile(filepath.Join(dir, ".gitlab", "billing.yml"))
+    if strings.Count(string(ci), "dev_deploy:") != 1 || !strings.Contains(string(ci), "qa_deploy:") {
+        t.Errorf("unexpected deploy jobs:\n%s", ci)
+    }
+
+    // a second run with the same spec is a no-op
+    if _, changes, err = bootstrap.ApplyEdit(dir, spec); err != nil || len(changes) != 0 {
+        t.Fatalf("expected no changes on re-run, got %v, %v", changes, err)
+    }
+}
+
+func TestDryRunPlansWithoutWriting(t *testing.T) {
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, ".gitlab-ci.yml"), []byte("stages:\n  - build\n"), 0o644)
+
+    plan := &bootstrap.Plan{}
+    spec := bootstrap.Spec{App: "billing", Region: "us-east-1", Language: "Go", Envs: []string{"dev"}}
+    if _, err := bootstrap.ApplyTo(plan, dir, spec); err != nil {
+        t.Fatalf("ApplyTo failed: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "apps")); err == nil {
+        t.Fatal("dry run wrote to disk")
+    }
+
+    var out strings.Builder
+    plan.Print(&out, dir)
+    for _, want := range []string{"+ apps/billing/main.go", "+ ansible/billing/inventory.yml", "~ .gitlab-ci.yml", "-stages:", "+include:"} {
+        if !strings.Contains(out.String(), want) {
+            t.Errorf("plan is missing %q:\n%s", want, out.String())
+        }
+    }
+}
+
+func TestHostNameTemplate(t *testing.T) {
+    for _, bad := range []string{"{{.App}} {{.Index}}.{{.Env}}", "{{.App}}_{{.Env}}", "{{.Nope}}"} {
+        if _, err := bootstrap.NewHostNamer(bad); err == nil {
+            t.Errorf("expected %q to be rejected", bad)
+        }
+    }
+
+    dir := t.TempDir()
+    tmpl := `{{.Region | replace "-" ""}}-{{.App}}{{printf "%02d" .Index}}.{{.Env}}`
+    spec := bootstrap.Spec{
+        App: "Billing", Region: "eu-west-1", Envs: []string{"dev"}, HostName: tmpl,
+        Environments: map[string]bootstrap.EnvSpec{"dev": {AnsibleUser: "deploy", Hosts: []string{"10.0.0.5"}}},
+    }
+    invPath, err := bootstrap.Apply(dir, spec)
+    if err != nil {
+        t.Fatalf("Apply failed: %v", err)
+    }
+    if inv, _ := os.ReadFile(invPath); !strings.Contains(string(inv), "euwest1-billing01.dev") {
+        t.Fatalf("host not named by the template:\n%s", inv)
+    }
+
+    loaded, err := bootstrap.LoadExisting(dir, "Billing", tmpl)
+    if err != nil || loaded.Region != "eu-west-1" {
+        t.Fatalf("expected the region back from the template, got %q, %v", loaded.Region, err)
+    }
+}
+
+func TestCheckerReportsKeysAndHosts(t *testing.T) {
+    dir := t.TempDir()
+    good, loose := filepath.Join(dir, "id_good"), filepath.Join(dir, "id_loose")
+    os.WriteFile(good, []byte("key"), 0o600)
+    os.WriteFile(loose, []byte("key"), 0o644)
+
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer ln.Close()
+    port := ln.Addr().(*net.TCPAddr).Port
+
+    spec := bootstrap.Spec{
+        App: "billing", Region: "us-east-1", Envs: []string{"dev", "qa", "prod"},
+        Environments: map[string]bootstrap.EnvSpec{
+            "dev":  {AnsibleUser: "deploy", KeyPath: good, Hosts: []string{"127.0.0.1"}},
+            "qa":   {AnsibleUser: "deploy", KeyPath: loose, Hosts: []string{"127.0.0.1"}},
+            "prod": {AnsibleUser: "deploy", Hosts: []string{"10.0.0.1"}}, // no key: not checked
+        },
+    }
+    results := bootstrap.Checker{Timeout: time.Second, Port: port}.Check(context.Background(), spec)
+    if len(results) != 4 {
+        t.Fatalf("expected key + host checks for dev and qa, got %+v", results)
+    }
+    for _, r := range results {
+        if wantErr := r.Target == loose; (r.Err != nil) != wantErr {
+            t.Errorf("%s %s: unexpected result %v", r.Env, r.Target, r.Err)
+        }
+    }
+
+    ln.Close()
+    results = bootstrap.Checker{Timeout: time.Second, Port: port}.Check(context.Background(), spec)
+    if results[1].Err == nil {
+        t.Error("expected a closed port to fail")
+    }
+}
+
+// fakeGitLab is an in-memory GitLabAPI.
+type fakeGitLab struct {
+    envs map[string]string // name → tier
+    vars map[string]string // key@scope → value
+}
+
+func (f *fakeGitLab) EnvironmentExists(name string) (bool, error) {
+    _, ok := f.envs[name]
+    return ok, nil
+}
+
+func (f *fakeGitLab) CreateEnvironment(name, tier string) error {
+    f.envs[name] = tier
+    return nil
+}
+
+func (f *fakeGitLab) VariableExists(key, scope string) (bool, error) {
+    _, ok := f.vars[key+"@"+scope]
+    return ok, nil
+}
+
+func (f *fakeGitLab) CreateVariable(key, value, scope string) error {
+    f.vars[key+"@"+scope] = value
+    return nil
+}
+
+func TestProvisionIsIdempotent(t *testing.T) {
+    gl := &fakeGitLab{envs: map[string]string{"dev": "development"}, vars: map[string]string{}}
+    spec := bootstrap.Spec{
+        App: "billing", Envs: []string{"dev", "prod"},
+        Environments: map[string]bootstrap.EnvSpec{"prod": {AnsibleUser: "svc-billing"}},
+    }
+
+    created, err := bootstrap.Provision(gl, spec)
+    if err != nil {
+        t.Fatalf("Provision failed: %v", err)
+    }
+    if len(created) != 5 || gl.envs["prod"] != "production" {
+        t.Fatalf("expected prod + 4 variables, got %v (envs %v)", created, gl.envs)
+    }
+    if gl.vars["ANSIBLE_REMOTE_USER@prod"] != "svc-billing" || gl.vars["ANSIBLE_REMOTE_USER@dev"] != "deploy" {
+        t.Errorf("unexpected variables: %v", gl.vars)
+    }
+
+    if created, err = bootstrap.Provision(gl, spec); err != nil || len(created) != 0 {
+        t.Fatalf("expected nothing on the second run, got %v, %v", created, err)
+    }
+}
+
+func TestHelmDeployment(t *testing.T) {
+    dir := t.TempDir()
+    spec := bootstrap.Spec{App: "billing", Language: "Go", Deploy: "helm", Envs: []string{"dev"}}
+    if _, err := bootstrap.Apply(dir, spec); err != nil {
+        t.Fatalf("Apply failed: %v", err)
+    }
+
+    chart := filepath.Join(dir, "deploy", "billing", "chart")
+    if raw, err := os.ReadFile(filepath.Join(chart, "Chart.yaml")); err != nil || !strings.Contains(string(raw), "name: billing") {
+        t.Fatalf("expected a billing chart, got %q, %v", raw, err)
+    }
+    // the chart's own templates are Helm's, not ours to render
+    if raw, _ := os.ReadFile(filepath.Join(chart, "templates", "deployment.yaml")); !strings.Contains(string(raw), "{{ .Values.replicaCount }}") {
+        t.Errorf("deployment.yaml was not copied verbatim:\n%s", raw)
+    }
+    if _, err := os.Stat(filepath.Join(dir, "ansible")); err == nil {
+        t.Errorf("expected no ansible dir for a helm app")
+    }
+
+    loaded, err := bootstrap.LoadExisting(dir, "billing", "")
+    if err != nil || loaded.Deploy != "helm" || strings.Join(loaded.Envs, ",") != "dev" {
+        t.Fatalf("LoadExisting: got %+v, %v", loaded, err)
+    }
+    loaded.Envs = append(loaded.Envs, "prod")
+    if _, _, err := bootstrap.ApplyEdit(dir, loaded); err != nil {
+        t.Fatalf("ApplyEdit failed: %v", err)
+    }
+    if _, err := os.Stat(filepath.Join(chart, "values-prod.yaml")); err != nil {
+        t.Errorf("values-prod.yaml missing: %v", err)
+    }
+    ci, _ := os.ReadFile(filepath.Join(dir, ".gitlab", "billing.yml"))
+    if !strings.Contains(string(ci), "helm upgrade --install billing deploy/billing/chart -f deploy/billing/chart/values-prod.yaml") {
+        t.Errorf("CI is missing the prod helm deploy:\n%s", ci)
+    }
+}
@@ -10,7 +10,10 @@ package depgraph
 import (
     "errors"
     "fmt"
+    "path"
     "sort"
+    "strings"
+    "sync"
 )
 
 type Project struct {
@@ -18,6 +21,25 @@ type Project struct {
     ProjectDir   string   `json:"projectDir"`
     Dependencies []string `json:"dependencies"`
     Deployable   bool     `json:"deployable"`
+    // ExcludePaths are paths or path.Match patterns, relative to ProjectDir,
+    // that do not count as changes to the project (docs, fixtures, …).
+    ExcludePaths []string `json:"excludePaths"`
+}
+
+// Excludes reports whether the repo-relative file is covered by one of the
+// project's ExcludePaths.
+func (p Project) Excludes(file string) bool {
+    rel := path.Clean(strings.ReplaceAll(file, "\\", "/"))
+    for _, ex := range p.ExcludePaths {
+        full := path.Join(p.ProjectDir, ex)
+        if rel == full || strings.HasPrefix(rel, full+"/") {
+            return true
+        }
+        if ok, _ := path.Match(full, rel); ok {
+            return true
+        }
+    }
+    return false
 }
 
 type Node struct {
@@ -26,10 +48,20 @@ type Node struct {
     Dependents []*Node // dep  ➜ this
 }
 
-type Graph struct{ nodes map[string]*Node }
+// Graph is safe for concurrent use: AddProject and RemoveProject may run
+// while other goroutines query it.
+type Graph struct {
+    mu    sync.RWMutex
+    nodes map[string]*Node
+    rules []DeployableRule // NewGraph's, for added projects too
+}
 
-func NewGraph(ps []Project) (*Graph, error) {
-    g := &Graph{nodes: make(map[string]*Node, len(ps))}
+// NewGraph builds the graph of ps. Without rules a project is deployable
+// when its metadata says so; with rules, when the first rule to decide says
+// so (see DeployableRule). A dependency on a project that is not in ps, such
+// as a published artifact, is ignored: nothing in the graph can change it.
+func NewGraph(ps []Project, rules ...DeployableRule) (*Graph, error) {
+    g := &Graph{nodes: make(map[string]*Node, len(ps)), rules: rules}
     for _, p := range ps {
         if _, dup := g.nodes[p.Name]; dup {
             return nil, fmt.Errorf("duplicate project name %s", p.Name)
@@ -37,13 +69,16 @@ func NewGraph(ps []Project) (*Graph, error) {
         if p.Dependencies == nil {
             p.Dependencies = make([]string, 0)
         }
+        if len(rules) > 0 {
+            p.Deployable = isDeployable(p, rules)
+        }
         g.nodes[p.Name] = &Node{Project: p}
     }
     for _, n := range g.nodes {
         for _, d := range n.Dependencies {
             dep, ok := g.nodes[d]
             if !ok {
-                return nil, fmt.Errorf("project %s lists unknown dependency %s", n.Name, d)
+                continue
             }
             n.Deps = append(n.Deps, dep)
             dep.Dependents = append(dep.Dependents, n)
@@ -89,7 +124,13 @@ func (g *Graph) detectCycle() error {
     return nil
 }
 
+// AffectedDeployables returns the deployables among the changed projects and
+// everything that depends on them, directly or transitively, sorted. The walk
+// goes on past deployables, so a service that depends on a changed service is
+// affected too.
 func (g *Graph) AffectedDeployables(changed []string) ([]string, error) {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
     var work []*Node
     for _, n := range changed {
         node, ok := g.nodes[n]
@@ -110,7 +151,6 @@ func (g *Graph) AffectedDeployables(changed []string) ([]string, error) {
         visited[cur.Name] = struct{}{}
         if cur.Deployable {
             affected[cur.Name] = struct{}{}
-            continue
         }
         for _, up := range cur.Dependents {
             work = append(work, up)
@@ -124,6 +164,435 @@ func (g *Graph) AffectedDeployables(changed []string) ([]string, error) {
     return out, nil
 }
 
+// AffectedPaths explains AffectedDeployables: for every affected deployable it
+// returns the shortest dependency chain that reaches it, from a changed
+// project to the deployable, e.g. [":libs:db", ":libs:api", ":apps:web"],
+// which may pass through other deployables. A changed deployable's chain is
+// just itself. Changed projects and dependents are walked in name order so
+// the same input always gives the same chains.
+func (g *Graph) AffectedPaths(changed []string) (map[string][]string, error) {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    starts := append([]string(nil), changed...)
+    sort.Strings(starts)
+    parent := make(map[string]string)
+    visited := make(map[string]struct{})
+    var work []*Node
+    for _, n := range starts {
+        node, ok := g.nodes[n]
+        if !ok {
+            return nil, fmt.Errorf("changed project %s not present in graph", n)
+        }
+        if _, seen := visited[n]; !seen {
+            visited[n] = struct{}{}
+            work = append(work, node)
+        }
+    }
+
+    paths := make(map[string][]string)
+    for len(work) > 0 {
+        cur := work[0]
+        work = work[1:]
+        if cur.Deployable {
+            chain := []string{cur.Name}
+            for n, ok := parent[cur.Name]; ok; n, ok = parent[n] {
+                chain = append([]string{n}, chain...)
+            }
+            paths[cur.Name] = chain
+        }
+        ups := append([]*Node(nil), cur.Dependents...)
+        sort.Slice(ups, func(i, j int) bool { return ups[i].Name < ups[j].Name })
+        for _, up := range ups {
+            if _, seen := visited[up.Name]; seen {
+                continue
+            }
+            visited[up.Name] = struct{}{}
+            parent[up.Name] = cur.Name
+            work = append(work, up)
+        }
+    }
+    return paths, nil
+}
+
+// AffectedProjects returns the changed projects and every project that
+// depends on one of them, directly or transitively, sorted: the projects
+// whose tests a change can break, deployable or not.
+func (g *Graph) AffectedProjects(changed []string) ([]string, error) {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    var work []*Node
+    for _, n := range changed {
+        node, ok := g.nodes[n]
+        if !ok {
+            return nil, fmt.Errorf("changed project %s not present in graph", n)
+        }
+        work = append(work, node)
+    }
+    visited := make(map[string]struct{})
+    for len(work) > 0 {
+        cur := work[0]
+        work = work[1:]
+        if _, seen := visited[cur.Name]; seen {
+            continue
+        }
+        visited[cur.Name] = struct{}{}
+        work = append(work, cur.Dependents...)
+    }
+    out := make([]string, 0, len(visited))
+    for k := range visited {
+        out = append(out, k)
+    }
+    sort.Strings(out)
+    return out, nil
+}
+
+// ProjectForFile returns the project owning a repo-relative file path. The
+// owner is the project whose ProjectDir is the longest path-segment prefix of
+// the file, so "apps/a/b/x.go" maps to the project at "apps/a/b" rather than
+// "apps/a", and "apps/ab/x.go" never matches "apps/a". A file excluded by its
+// owner's ExcludePaths belongs to no project.
+func (g *Graph) ProjectForFile(file string) (string, bool) {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    return g.projectForFile(file)
+}
+
+func (g *Graph) projectForFile(file string) (string, bool) {
+    rel := path.Clean(strings.ReplaceAll(file, "\\", "/"))
+    var best string
+    bestLen := -1
+    for name, n := range g.nodes {
+        dir := path.Clean(n.ProjectDir)
+        if n.ProjectDir == "" || dir == "." {
+            continue
+        }
+        if rel != dir && !strings.HasPrefix(rel, dir+"/") {
+            continue
+        }
+        // ties are broken by name so the result does not depend on map order
+        if len(dir) > bestLen || (len(dir) == bestLen && name < best) {
+            best, bestLen = name, len(dir)
+        }
+    }
+    if bestLen < 0 || g.nodes[best].Excludes(rel) {
+        return "", false
+    }
+    return best, true
+}
+
+// ProjectsForFiles maps changed files to the sorted, de-duplicated set of
+// owning projects. Files outside every ProjectDir are ignored.
+func (g *Graph) ProjectsForFiles(files []string) []string {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    set := make(map[string]struct{})
+    for _, f := range files {
+        if f == "" {
+            continue
+        }
+        if name, ok := g.projectForFile(f); ok {
+            set[name] = struct{}{}
+        }
+    }
+    out := make([]string, 0, len(set))
+    for k := range set {
+        out = append(out, k)
+    }
+    sort.Strings(out)
+    return out
+}
+
+// TopoOrder returns the given projects ordered so that each one comes after
+// every project it depends on, directly or transitively. Projects that do not
+// depend on each other are ordered by name.
+func (g *Graph) TopoOrder(names []string) ([]string, error) {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    want := make(map[string]bool, len(names))
+    for _, n := range names {
+        if _, ok := g.nodes[n]; !ok {
+            return nil, fmt.Errorf("project %s not present in graph", n)
+        }
+        want[n] = true
+    }
+    indeg := make(map[string]int, len(g.nodes))
+    var ready []string
+    for name, n := range g.nodes {
+        indeg[name] = len(n.Deps)
+        if indeg[name] == 0 {
+            ready = append(ready, name)
+        }
+    }
+    out := make([]string, 0, len(want))
+    for len(ready) > 0 {
+        sort.Strings(ready)
+        cur := ready[0]
+        ready = ready[1:]
+        if want[cur] {
+            out = append(out, cur)
+        }
+        for _, up := range g.nodes[cur].Dependents {
+            indeg[up.Name]--
+            if indeg[up.Name] == 0 {
+                ready = append(ready, up.Name)
+            }
+        }
+    }
+    return out, nil
+}
+
+// ReleasePaths returns the directories whose history makes up a release of
+// the named project: its own ProjectDir and those of its transitive
+// dependencies, sorted. A deployable dependency is released on its own, so
+// the walk does not enter it. exclude lists the ExcludePaths of every visited
+// project, joined onto its ProjectDir.
+func (g *Graph) ReleasePaths(name string) (include, exclude []string, err error) {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    root, ok := g.nodes[name]
+    if !ok {
+        return nil, nil, fmt.Errorf("project %s not present in graph", name)
+    }
+    dirs := make(map[string]struct{})
+    excl := make(map[string]struct{})
+    visited := make(map[string]struct{})
+    work := []*Node{root}
+    for len(work) > 0 {
+        cur := work[0]
+        work = work[1:]
+        if _, seen := visited[cur.Name]; seen {
+            continue
+        }
+        visited[cur.Name] = struct{}{}
+        if cur != root && cur.Deployable {
+            continue
+        }
+        if cur.ProjectDir != "" {
+            dirs[path.Clean(cur.ProjectDir)] = struct{}{}
+        }
+        for _, ex := range cur.ExcludePaths {
+            excl[path.Join(cur.ProjectDir, ex)] = struct{}{}
+        }
+        work = append(work, cur.Deps...)
+    }
+    for d := range dirs {
+        include = append(include, d)
+    }
+    for e := range excl {
+        exclude = append(exclude, e)
+    }
+    sort.Strings(include)
+    sort.Strings(exclude)
+    return include, exclude, nil
+}
+
+// -----------------------------------------------------------------------------
+// depgraph/deployable.go
+// -----------------------------------------------------------------------------
+package depgraph
+
+import (
+    "io/fs"
+    "path"
+    "sort"
+    "strings"
+)
+
+// A DeployableRule decides whether a project is deployable, for metadata
+// that does not say. decided is false when the rule has no opinion on p;
+// NewGraph then asks the next rule, and a project no rule decides on is not
+// deployable.
+type DeployableRule func(p Project) (deployable, decided bool)
+
+func isDeployable(p Project, rules []DeployableRule) bool {
+    for _, rule := range rules {
+        if deployable, decided := rule(p); decided {
+            return deployable
+        }
+    }
+    return false
+}
+
+// Declared decides on the projects whose metadata says `deployable: true`.
+func Declared(p Project) (deployable, decided bool) {
+    return p.Deployable, p.Deployable
+}
+
+// Overrides decides on the projects named in byName, e.g. to keep a tool
+// under apps/ from being deployed.
+func Overrides(byName map[string]bool) DeployableRule {
+    return func(p Project) (bool, bool) {
+        deployable, ok := byName[p.Name]
+        return deployable, ok
+    }
+}
+
+// DirPrefix makes the projects under dir, e.g. apps, deployable.
+func DirPrefix(dir string) DeployableRule {
+    dir = path.Clean(dir)
+    return func(p Project) (bool, bool) {
+        pd := path.Clean(p.ProjectDir)
+        if p.ProjectDir != "" && strings.HasPrefix(pd, dir+"/") {
+            return true, true
+        }
+        return false, false
+    }
+}
+
+// HasFile makes the projects whose directory in fsys, the repo, holds name,
+// e.g. Dockerfile, deployable.
+func HasFile(fsys fs.FS, name string) DeployableRule {
+    return func(p Project) (bool, bool) {
+        if p.ProjectDir == "" {
+            return false, false
+        }
+        if info, err := fs.Stat(fsys, path.Join(path.Clean(p.ProjectDir), name)); err == nil && !info.IsDir() {
+            return true, true
+        }
+        return false, false
+    }
+}
+
+// GradleAppsDir makes :<group>:<name> deployable when fsys, the apps
+// directory named group, has a directory <name>: the layout
+// pipeline-generator assumes, where every app is a Gradle project directly
+// under apps/.
+func GradleAppsDir(fsys fs.FS, group string) DeployableRule {
+    prefix := ":" + group + ":"
+    return func(p Project) (bool, bool) {
+        name, ok := strings.CutPrefix(p.Name, prefix)
+        if !ok || name == "" || strings.Contains(name, ":") {
+            return false, false
+        }
+        if info, err := fs.Stat(fsys, name); err == nil && info.IsDir() {
+            return true, true
+        }
+        return false, false
+    }
+}
+
+// Deployables lists the deployable projects, sorted.
+func (g *Graph) Deployables() []string {
+    g.mu.RLock()
+    defer g.mu.RUnlock()
+    var out []string
+    for name, n := range g.nodes {
+        if n.Deployable {
+            out = append(out, name)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+// -----------------------------------------------------------------------------
+// depgraph/update.go
+// -----------------------------------------------------------------------------
+package depgraph
+
+import (
+    "fmt"
+    "slices"
+)
+
+// AddProject adds p to the graph, or replaces the project of that name, as
+// its metadata changes. As in NewGraph, a dependency that is not in the
+// graph is ignored until a project of that name is added, which links the
+// projects that list it. The graph is validated again, and left as it was
+// if p closes a cycle.
+func (g *Graph) AddProject(p Project) error {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    if p.Dependencies == nil {
+        p.Dependencies = make([]string, 0)
+    }
+    if len(g.rules) > 0 {
+        p.Deployable = isDeployable(p, g.rules)
+    }
+    n := &Node{Project: p}
+    for _, d := range p.Dependencies {
+        if d == p.Name {
+            return fmt.Errorf("project %s depends on itself", p.Name)
+        }
+        if dep, ok := g.nodes[d]; ok {
+            n.Deps = append(n.Deps, dep)
+        }
+    }
+    old := g.nodes[p.Name]
+    g.swap(old, n)
+    if err := g.detectCycle(); err != nil {
+        g.swap(n, old)
+        return fmt.Errorf("project %s: %w", p.Name, err)
+    }
+    return nil
+}
+
+// RemoveProject removes the named project. Projects that still depend on it
+// have to be removed, or changed not to, first.
+func (g *Graph) RemoveProject(name string) error {
+    g.mu.Lock()
+    defer g.mu.Unlock()
+    n, ok := g.nodes[name]
+    if !ok {
+        return fmt.Errorf("project %s not present in graph", name)
+    }
+    if len(n.Dependents) > 0 {
+        return fmt.Errorf("project %s is still a dependency of %s", name, n.Dependents[0].Name)
+    }
+    g.swap(n, nil)
+    return nil
+}
+
+// swap puts n where old was: n takes over old's dependents, or, when it is
+// new, those of the projects listing it, and is linked into its own
+// dependencies' dependents. Either may be nil, to add or to remove a node;
+// removing one with dependents only unlinks them, and outside of undoing an
+// add is the caller's to prevent.
+func (g *Graph) swap(old, n *Node) {
+    var dependents []*Node
+    if old != nil {
+        dependents = old.Dependents
+        for _, dep := range old.Deps {
+            dep.Dependents = without(dep.Dependents, old)
+        }
+        delete(g.nodes, old.Name)
+    } else {
+        for _, up := range g.nodes {
+            if slices.Contains(up.Dependencies, n.Name) {
+                dependents = append(dependents, up)
+            }
+        }
+    }
+    if n == nil {
+        for _, up := range dependents {
+            up.Deps = without(up.Deps, old)
+        }
+        return
+    }
+    n.Dependents = dependents
+    for _, up := range dependents {
+        if i := slices.Index(up.Deps, old); old != nil && i >= 0 {
+            up.Deps[i] = n
+        } else {
+            up.Deps = append(up.Deps, n)
+        }
+    }
+    for _, dep := range n.Deps {
+        dep.Dependents = append(dep.Dependents, n)
+    }
+    g.nodes[n.Name] = n
+}
+
+func without(nodes []*Node, n *Node) []*Node {
+    out := nodes[:0:0]
+    for _, x := range nodes {
+        if x != n {
+            out = append(out, x)
+        }
+    }
+    return out
+}
+
 // -----------------------------------------------------------------------------
 // gitdiff.go
 // -----------------------------------------------------------------------------
@@ -149,6 +618,23 @@ func run(ctx context.Context, dir string, args ...string) (string, error) {
     return strings.TrimSpace(outBuf.String()), nil
 }
 
+// RevParse resolves ref to a full commit SHA.
+func RevParse(ctx context.Context, repo, ref string) (string, error) {
+    return run(ctx, repo, "rev-parse", "--verify", ref+"^{commit}")
+}
+
+// MergeBase returns the best common ancestor of a and b, i.e. the commit a
+// "base...HEAD" diff is computed against.
+func MergeBase(ctx context.Context, repo, a, b string) (string, error) {
+    return run(ctx, repo, "merge-base", a, b)
+}
+
+// TopLevel returns the root of the work tree dir is in, the directory the
+// paths of ChangedHunks are relative to.
+func TopLevel(ctx context.Context, dir string) (string, error) {
+    return run(ctx, dir, "rev-parse", "--show-toplevel")
+}
+
 func ChangedFilesAgainstBase(ctx context.Context, repo, base string) ([]string, error) {
     o, err := run(ctx, repo, "diff", "--name-only", fmt.Sprintf("%s...HEAD", base))
     if err != nil || o == "" {
@@ -187,117 +673,585 @@ func ChangedFilesSinceLastTag(ctx context.Context, repo string) ([]string, error
     return strings.Split(o, "\n"), nil
 }
 
+// UncommittedFiles lists the files of repo's work tree that differ from
+// HEAD, staged or not, then the untracked files .gitignore does not cover.
+func UncommittedFiles(ctx context.Context, repo string) ([]string, error) {
+    tracked, err := run(ctx, repo, "diff", "--name-only", "HEAD")
+    if err != nil {
+        return nil, err
+    }
+    untracked, err := run(ctx, repo, "ls-files", "--others", "--exclude-standard")
+    if err != nil {
+        return nil, err
+    }
+    var files []string
+    for _, o := range []string{tracked, untracked} {
+        if o != "" {
+            files = append(files, strings.Split(o, "\n")...)
+        }
+    }
+    return files, nil
+}
+
 // -----------------------------------------------------------------------------
-// cmd/pipeline-gen/main.go
+// gitdiff/hunks.go
 // -----------------------------------------------------------------------------
-// A tiny CLI that wires the `gitdiff` and `depgraph` packages together.
-// It determines which deployable projects need CI pipelines based on Git changes
-// and project-dependency metadata.
-package main
+package gitdiff
 
 import (
     "context"
-    "encoding/json"
-    "flag"
-    "log/slog"
-    "os"
-    "path/filepath"
+    "fmt"
+    "strconv"
     "strings"
-    "time"
-
-    "github.com/yourorg/tool/depgraph"
-    "github.com/yourorg/tool/gitdiff"
 )
 
-func main() {
-    var (
-        repo     = flag.String("repo", ".", "path to git repo root")
-        meta     = flag.String("metadata", "projects.json", "project metadata JSON file")
-        mode     = flag.String("mode", "branch", "diff mode: branch|main|tag")
-        baseRef  = flag.String("base-ref", "origin/main", "base ref when mode=branch")
-        verbose  = flag.Bool("v", false, "verbose logging")
-    )
-    flag.Parse()
+// LineRange is lines Start to End of a file, counted from 1, both included.
+type LineRange struct {
+    Start int `json:"start"`
+    End   int `json:"end"`
+}
 
-    // logger setup
-    handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})
-    if *verbose {
-        handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
-    }
-    log := slog.New(handler)
+// FileHunks is the lines a diff changes in one file.
+type FileHunks struct {
+    Path    string      `json:"path"`               // the new path; the old one for a deleted file
+    OldPath string      `json:"old_path,omitempty"` // set when the file was renamed or deleted
+    Added   []LineRange `json:"added,omitempty"`    // lines added or changed, in the new file
+    Removed []LineRange `json:"removed,omitempty"`  // lines removed or changed, in the old file
+}
 
-    // ------------------------------------------------------------ load metadata
-    raw, err := os.ReadFile(*meta)
+// ChangedHunks lists the changed lines of every text file a diff of
+// rangeSpec (e.g. origin/main...HEAD) touches, in diff order. Renames are
+// followed; binary files have no lines and are left out.
+func ChangedHunks(ctx context.Context, repo, rangeSpec string) ([]FileHunks, error) {
+    o, err := run(ctx, repo, "-c", "core.quotePath=false", "diff", "--unified=0", "--no-color", "--no-ext-diff", "-M", rangeSpec)
     if err != nil {
-        log.Error("read metadata", "err", err)
-        os.Exit(1)
+        return nil, err
     }
-    var mm map[string]depgraph.Project
-    if err := json.Unmarshal(raw, &mm); err != nil {
-        log.Error("parse metadata", "err", err)
-        os.Exit(1)
+    return parseHunks(o)
+}
+
+// parseHunks reads the output of git diff --unified=0.
+func parseHunks(diff string) ([]FileHunks, error) {
+    var files []FileHunks
+    var cur *FileHunks
+    var oldPath string
+    header := false // between diff --git and the first hunk, where ---/+++ are paths
+    for _, line := range strings.Split(diff, "\n") {
+        switch {
+        case strings.HasPrefix(line, "diff --git "):
+            cur, oldPath, header = nil, "", true
+        case header && strings.HasPrefix(line, "--- "):
+            oldPath = diffPath(line[4:], "a/")
+        case header && strings.HasPrefix(line, "+++ "):
+            files = append(files, FileHunks{Path: diffPath(line[4:], "b/")})
+            cur = &files[len(files)-1]
+            switch {
+            case cur.Path == "":
+                cur.Path = oldPath // deleted
+                cur.OldPath = oldPath
+            case oldPath != "" && oldPath != cur.Path:
+                cur.OldPath = oldPath
+            }
+        case strings.HasPrefix(line, "@@ ") && cur != nil:
+            header = false
+            removed, added, err := hunkRanges(line)
+            if err != nil {
+                return nil, fmt.Errorf("%s: %w", cur.Path, err)
+            }
+            if removed != nil {
+                cur.Removed = append(cur.Removed, *removed)
+            }
+            if added != nil {
+                cur.Added = append(cur.Added, *added)
+            }
+        }
     }
-    var projects []depgraph.Project
-    for name, p := range mm {
-        p.Name = name
-        projects = append(projects, p)
+    return files, nil
+}
+
+// diffPath is the path of a ---/+++ line without its a/ or b/ prefix; ""
+// for /dev/null.
+func diffPath(s, prefix string) string {
+    s = strings.TrimSuffix(s, "\t") // git adds a tab after paths with spaces
+    if strings.HasPrefix(s, `"`) {
+        if unq, err := strconv.Unquote(s); err == nil {
+            s = unq
+        }
     }
-    g, err := depgraph.NewGraph(projects)
-    if err != nil {
-        log.Error("build graph", "err", err)
-        os.Exit(1)
+    if s == "/dev/null" {
+        return ""
     }
+    return strings.TrimPrefix(s, prefix)
+}
 
-    // ------------------------------------------------------------ git changes
-    ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-    defer cancel()
+// hunkRanges reads "@@ -l,s +l,s @@": nil for a side without lines, as the
+// old side of a pure insertion.
+func hunkRanges(header string) (removed, added *LineRange, err error) {
+    fields := strings.Fields(header)
+    if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+        return nil, nil, fmt.Errorf("bad hunk header %q", header)
+    }
+    if removed, err = lineRange(fields[1][1:]); err != nil {
+        return nil, nil, fmt.Errorf("bad hunk header %q", header)
+    }
+    if added, err = lineRange(fields[2][1:]); err != nil {
+        return nil, nil, fmt.Errorf("bad hunk header %q", header)
+    }
+    return removed, added, nil
+}
+
+// lineRange reads start[,count]; count defaults to 1.
+func lineRange(s string) (*LineRange, error) {
+    startStr, countStr, hasCount := strings.Cut(s, ",")
+    start, err := strconv.Atoi(startStr)
+    if err != nil {
+        return nil, err
+    }
+    count := 1
+    if hasCount {
+        if count, err = strconv.Atoi(countStr); err != nil {
+            return nil, err
+        }
+    }
+    if count == 0 {
+        return nil, nil
+    }
+    return &LineRange{Start: start, End: start + count - 1}, nil
+}
 
-    var changedFiles []string
-    switch *mode {
+// -----------------------------------------------------------------------------
+// affected/affected.go
+// -----------------------------------------------------------------------------
+// Package affected is pipeline-gen as a library: it diffs the repo with
+// gitdiff and walks the depgraph built from the project metadata, so the CI
+// job and local tools (`loki affected`) give the same answer.
+package affected
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    "github.com/yourorg/tool/depgraph"
+    "github.com/yourorg/tool/gitdiff"
+)
+
+// Modes are the diff modes ChangedFiles understands.
+var Modes = []string{"branch", "main", "tag"}
+
+// Options select the repo, metadata and diff to analyse.
+type Options struct {
+    Repo     string // git repo root
+    Metadata string // project metadata JSON, e.g. projects.json
+    Mode     string // one of Modes
+    BaseRef  string // what a branch is compared with, e.g. origin/main
+    // Deployables decides which projects are deployable; empty leaves it to
+    // the metadata.
+    Deployables Deployables
+}
+
+// Deployables configures the depgraph.DeployableRule set pipeline-gen and
+// loki build the graph with, so both find the same deployables. Overrides
+// win, then the metadata's `deployable: true`, then Dirs and Files.
+type Deployables struct {
+    Dirs      []string        // projectDir prefixes, e.g. apps
+    Files     []string        // files that mark a project directory, e.g. Dockerfile
+    Overrides map[string]bool // by project name
+}
+
+// AddOverride adds an override given as name=true or name=false.
+func (d *Deployables) AddOverride(spec string) error {
+    name, value, ok := strings.Cut(spec, "=")
+    deployable, err := strconv.ParseBool(value)
+    if !ok || name == "" || err != nil {
+        return fmt.Errorf("override %q: want <project>=true or <project>=false", spec)
+    }
+    if d.Overrides == nil {
+        d.Overrides = make(map[string]bool)
+    }
+    d.Overrides[name] = deployable
+    return nil
+}
+
+// Rules are the rules for a repo at root; none when d is empty.
+func (d Deployables) Rules(root string) []depgraph.DeployableRule {
+    if len(d.Dirs)+len(d.Files)+len(d.Overrides) == 0 {
+        return nil
+    }
+    rules := []depgraph.DeployableRule{depgraph.Overrides(d.Overrides), depgraph.Declared}
+    for _, dir := range d.Dirs {
+        rules = append(rules, depgraph.DirPrefix(dir))
+    }
+    for _, file := range d.Files {
+        rules = append(rules, depgraph.HasFile(os.DirFS(root), file))
+    }
+    return rules
+}
+
+// Result is what changed and which deployables that affects.
+type Result struct {
+    Mode            string   `json:"mode"`
+    ChangedFiles    []string `json:"changed_files"`
+    ChangedProjects []string `json:"changed_projects"`
+    Affected        []string `json:"affected"`
+    // Paths holds, per affected deployable, the chain from a changed project
+    // to it (see depgraph.Graph.AffectedPaths).
+    Paths map[string][]string `json:"paths"`
+    // Tests are the projects whose tests the change can break (see
+    // depgraph.Graph.AffectedProjects), each after its dependencies.
+    Tests []string `json:"tests"`
+}
+
+// ParseProjects reads project metadata: a JSON object of projects by name.
+func ParseProjects(raw []byte) ([]depgraph.Project, error) {
+    var byName map[string]depgraph.Project
+    if err := json.Unmarshal(raw, &byName); err != nil {
+        return nil, fmt.Errorf("parse metadata: %w", err)
+    }
+    projects := make([]depgraph.Project, 0, len(byName))
+    for name, p := range byName {
+        p.Name = name
+        projects = append(projects, p)
+    }
+    return projects, nil
+}
+
+// ChangedFiles lists the files changed in repo for a diff mode: the branch
+// against its merge base with baseRef, the last commit, or everything since
+// the previous tag.
+func ChangedFiles(ctx context.Context, repo, mode, baseRef string) ([]string, error) {
+    switch mode {
     case "branch":
-        changedFiles, err = gitdiff.ChangedFilesAgainstBase(ctx, *repo, *baseRef)
+        return gitdiff.ChangedFilesAgainstBase(ctx, repo, baseRef)
     case "main":
-        changedFiles, err = gitdiff.ChangedFilesSinceLastCommit(ctx, *repo)
+        return gitdiff.ChangedFilesSinceLastCommit(ctx, repo)
     case "tag":
-        changedFiles, err = gitdiff.ChangedFilesSinceLastTag(ctx, *repo)
-    default:
-        log.Error("unknown mode", "mode", *mode)
-        os.Exit(1)
+        return gitdiff.ChangedFilesSinceLastTag(ctx, repo)
+    }
+    return nil, fmt.Errorf("unknown mode %q (want branch, main or tag)", mode)
+}
+
+// Analyse maps changed files to projects and walks the graph to the
+// deployables they affect.
+func Analyse(g *depgraph.Graph, files []string) (*Result, error) {
+    r := &Result{ChangedFiles: files, ChangedProjects: g.ProjectsForFiles(files)}
+    if r.ChangedFiles == nil {
+        r.ChangedFiles = []string{}
+    }
+    var err error
+    if r.Affected, err = g.AffectedDeployables(r.ChangedProjects); err != nil {
+        return nil, fmt.Errorf("dependency walk: %w", err)
+    }
+    if r.Paths, err = g.AffectedPaths(r.ChangedProjects); err != nil {
+        return nil, fmt.Errorf("dependency walk: %w", err)
     }
+    if r.Tests, err = g.AffectedProjects(r.ChangedProjects); err != nil {
+        return nil, fmt.Errorf("dependency walk: %w", err)
+    }
+    if r.Tests, err = g.TopoOrder(r.Tests); err != nil {
+        return nil, fmt.Errorf("dependency walk: %w", err)
+    }
+    return r, nil
+}
+
+// Run is the whole analysis: load the metadata, diff the repo, walk the graph.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+    raw, err := os.ReadFile(opts.Metadata)
     if err != nil {
-        log.Error("git diff", "err", err)
-        os.Exit(1)
+        return nil, fmt.Errorf("read metadata: %w", err)
     }
-    log.Debug("changed files", "count", len(changedFiles))
+    projects, err := ParseProjects(raw)
+    if err != nil {
+        return nil, err
+    }
+    g, err := depgraph.NewGraph(projects, opts.Deployables.Rules(opts.Repo)...)
+    if err != nil {
+        return nil, fmt.Errorf("build graph: %w", err)
+    }
+    files, err := ChangedFiles(ctx, opts.Repo, opts.Mode, opts.BaseRef)
+    if err != nil {
+        return nil, err // gitdiff's errors name the git command
+    }
+    r, err := Analyse(g, files)
+    if err != nil {
+        return nil, err
+    }
+    r.Mode = opts.Mode
+    return r, nil
+}
 
-    // ------------------------------------------------------------ map → projects
-    changedSet := map[string]struct{}{}
-    for _, f := range changedFiles {
-        rel := filepath.ToSlash(f)
-        for _, p := range projects {
-            if p.ProjectDir == "" {
-                continue
+// -----------------------------------------------------------------------------
+// affected/watch.go
+// -----------------------------------------------------------------------------
+// Watch is the CI analysis as a local feedback loop: it reruns Analyse on
+// the files a developer saves, so they can test what the change affects
+// before pushing it.
+package affected
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/yourorg/tool/depgraph"
+    "github.com/yourorg/tool/gitdiff"
+)
+
+// WatchOptions select the repo and metadata to watch. The work tree is
+// polled through git, so ignored files (build output, say) never count as
+// saves and no file-notification API is needed.
+type WatchOptions struct {
+    Repo        string // git repo root
+    Metadata    string // project metadata JSON, reloaded when it changes
+    Deployables Deployables
+    Interval    time.Duration // how often to look; 0 means every second
+}
+
+// fileStamp is what Watch compares to tell that a file was saved.
+type fileStamp struct {
+    exists  bool
+    size    int64
+    modTime time.Time
+}
+
+func stampOf(path string) fileStamp {
+    info, err := os.Stat(path)
+    if err != nil {
+        return fileStamp{}
+    }
+    return fileStamp{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// Watch calls saved with the analysis of the uncommitted files (see
+// gitdiff.UncommittedFiles) once at the start, then with that of the files
+// saved since it last looked whenever there are some, until ctx is done. A
+// file reverted or committed counts as saved too. Errors after the first
+// graph is built, such as metadata saved half-edited, go to saved and the
+// watch goes on with the last good graph.
+func Watch(ctx context.Context, opts WatchOptions, saved func(*Result, error)) error {
+    interval := opts.Interval
+    if interval <= 0 {
+        interval = time.Second
+    }
+    var (
+        g         *depgraph.Graph
+        metaStamp fileStamp
+        stamps    map[string]fileStamp // the uncommitted files as last seen
+    )
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        if st := stampOf(opts.Metadata); g == nil || st != metaStamp {
+            next, err := loadGraph(opts)
+            switch {
+            case err != nil && g == nil:
+                return err
+            case err != nil:
+                saved(nil, err)
+            default:
+                g = next
+            }
+            metaStamp = st
+        }
+
+        files, err := gitdiff.UncommittedFiles(ctx, opts.Repo)
+        if err != nil && ctx.Err() == nil {
+            saved(nil, err)
+        } else if err == nil {
+            current := make(map[string]fileStamp, len(files))
+            var changed []string
+            for _, f := range files {
+                current[f] = stampOf(filepath.Join(opts.Repo, f))
+                if prev, ok := stamps[f]; !ok || prev != current[f] {
+                    changed = append(changed, f)
+                }
+            }
+            for f := range stamps {
+                if _, ok := current[f]; !ok {
+                    changed = append(changed, f)
+                }
             }
-            if strings.HasPrefix(rel, p.ProjectDir+"/") || rel == p.ProjectDir {
-                changedSet[p.Name] = struct{}{}
+            if stamps == nil || len(changed) > 0 {
+                sort.Strings(changed)
+                r, err := Analyse(g, changed)
+                if r != nil {
+                    r.Mode = "watch"
+                }
+                saved(r, err)
             }
+            stamps = current
+        }
+
+        select {
+        case <-ctx.Done():
+            return nil
+        case <-ticker.C:
         }
     }
-    if len(changedSet) == 0 {
-        log.Info("no projects matched changed files – exiting")
+}
+
+func loadGraph(opts WatchOptions) (*depgraph.Graph, error) {
+    raw, err := os.ReadFile(opts.Metadata)
+    if err != nil {
+        return nil, fmt.Errorf("read metadata: %w", err)
+    }
+    projects, err := ParseProjects(raw)
+    if err != nil {
+        return nil, err
+    }
+    g, err := depgraph.NewGraph(projects, opts.Deployables.Rules(opts.Repo)...)
+    if err != nil {
+        return nil, fmt.Errorf("build graph: %w", err)
+    }
+    return g, nil
+}
+
+// TestCommand is the shell command testing project: template with every
+// {project} replaced by the project's name, e.g. "./gradlew {project}:test".
+func TestCommand(template, project string) string {
+    return strings.ReplaceAll(template, "{project}", project)
+}
+
+// -----------------------------------------------------------------------------
+// cmd/pipeline-gen/main.go
+// -----------------------------------------------------------------------------
+// A tiny CLI that wires the `gitdiff` and `depgraph` packages together.
+// It determines which deployable projects need CI pipelines based on Git changes
+// and project-dependency metadata.
+package main
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "flag"
+    "fmt"
+    "log/slog"
+    "os"
+    "time"
+
+    "github.com/yourorg/tool/affected"
+    "github.com/yourorg/tool/depgraph"
+)
+
+func main() {
+    var deployables affected.Deployables
+    flag.Func("deployable-dir", "projectDir prefix whose projects are deployable, e.g. apps (repeatable)", func(v string) error {
+        deployables.Dirs = append(deployables.Dirs, v)
+        return nil
+    })
+    flag.Func("deployable-file", "file that makes its project deployable, e.g. Dockerfile (repeatable)", func(v string) error {
+        deployables.Files = append(deployables.Files, v)
+        return nil
+    })
+    flag.Func("deployable-override", "<project>=true|false, winning over every other rule (repeatable)", deployables.AddOverride)
+    var (
+        repo     = flag.String("repo", ".", "path to git repo root")
+        meta     = flag.String("metadata", "projects.json", "project metadata JSON file")
+        mode     = flag.String("mode", "branch", "diff mode: branch|main|tag")
+        baseRef  = flag.String("base-ref", "origin/main", "base ref when mode=branch")
+        cacheDir = flag.String("cache-dir", os.Getenv("PIPELINE_GEN_CACHE_DIR"), "directory for the affected-apps cache (empty disables caching)")
+        verbose  = flag.Bool("v", false, "verbose logging")
+        watch    = flag.Bool("watch", false, "developer mode: watch the work tree and report what every save affects until interrupted")
+        interval = flag.Duration("watch-interval", time.Second, "how often -watch looks for saves")
+        testCmd  = flag.String("test-cmd", "", "with -watch, the command testing a project, {project} replaced by its name, e.g. './gradlew {project}:test'")
+        runTests = flag.Bool("run-tests", false, "with -watch, run the -test-cmd commands instead of printing them")
+    )
+    flag.Parse()
+
+    // logger setup
+    handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})
+    if *verbose {
+        handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+    }
+    log := slog.New(handler)
+
+    if *watch {
+        opts := affected.WatchOptions{Repo: *repo, Metadata: *meta, Deployables: deployables, Interval: *interval}
+        if err := runWatch(log, opts, *testCmd, *runTests); err != nil {
+            log.Error("watch", "err", err)
+            os.Exit(1)
+        }
         return
     }
-    var changedProjects []string
-    for n := range changedSet {
-        changedProjects = append(changedProjects, n)
+
+    // ------------------------------------------------------------ load metadata
+    raw, err := os.ReadFile(*meta)
+    if err != nil {
+        log.Error("read metadata", "err", err)
+        os.Exit(1)
+    }
+    // the rules change the answer as much as the metadata does
+    sum := sha256.Sum256(append(raw, fmt.Sprintf("%v", deployables)...))
+    metaSum := hex.EncodeToString(sum[:])
+
+    ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+    defer cancel()
+
+    // ------------------------------------------------------------ cache lookup
+    var (
+        cache    *affectedCache
+        cacheKey string
+    )
+    if *cacheDir != "" {
+        cache = loadAffectedCache(*cacheDir, log)
+        if cacheKey, err = cacheKeyFor(ctx, *repo, *mode, *baseRef); err != nil {
+            log.Warn("cache disabled: could not resolve commits", "err", err)
+            cache = nil
+        } else if apps, ok := cache.lookup(cacheKey, metaSum); ok {
+            log.Info("cache hit – skipping git diff and graph walk", "key", cacheKey)
+            report(log, apps)
+            return
+        }
+    }
+
+    projects, err := affected.ParseProjects(raw)
+    if err != nil {
+        log.Error("parse metadata", "err", err)
+        os.Exit(1)
+    }
+    g, err := depgraph.NewGraph(projects, deployables.Rules(*repo)...)
+    if err != nil {
+        log.Error("build graph", "err", err)
+        os.Exit(1)
+    }
+
+    // ------------------------------------------------------------ git changes
+    changedFiles, err := affected.ChangedFiles(ctx, *repo, *mode, *baseRef)
+    if err != nil {
+        log.Error("git diff", "err", err)
+        os.Exit(1)
     }
+    log.Debug("changed files", "count", len(changedFiles))
 
-    // ------------------------------------------------------------ dependency walk
-    impacted, err := g.AffectedDeployables(changedProjects)
+    // ------------------------------------------------------------ map → projects → dependency walk
+    result, err := affected.Analyse(g, changedFiles)
     if err != nil {
         log.Error("dependency walk", "err", err)
         os.Exit(1)
     }
+    changedProjects, impacted := result.ChangedProjects, result.Affected
+    if cache != nil {
+        if err := cache.store(cacheKey, metaSum, impacted); err != nil {
+            log.Warn("could not write cache", "err", err)
+        }
+    }
+    if len(changedProjects) == 0 {
+        log.Info("no projects matched changed files – exiting")
+        return
+    }
+    report(log, impacted)
+}
+
+// report logs the final set of impacted deployables.
+func report(log *slog.Logger, impacted []string) {
     if len(impacted) == 0 {
         log.Info("no deployable apps impacted – nothing to do")
         return
@@ -307,6 +1261,771 @@ func main() {
     // future: emit CI job YAML / JSON here
 }
 
+// -----------------------------------------------------------------------------
+// cmd/pipeline-gen/cache.go
+// -----------------------------------------------------------------------------
+// An optional on-disk cache mapping (base_sha, head_sha) → affected apps so
+// retried pipelines for the same commits skip git and graph work. Point
+// -cache-dir at a directory listed under `cache:paths` in .gitlab-ci.yml.
+// Entries record a hash of projects.json and are ignored once it changes.
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "time"
+
+    "github.com/yourorg/tool/gitdiff"
+)
+
+const (
+    cacheFile       = "pipeline-gen-cache.json"
+    cacheMaxEntries = 500
+)
+
+type cacheEntry struct {
+    MetadataSHA string    `json:"metadata_sha256"`
+    Affected    []string  `json:"affected"`
+    CreatedAt   time.Time `json:"created_at"`
+}
+
+type affectedCache struct {
+    path    string
+    Entries map[string]cacheEntry `json:"entries"`
+}
+
+// loadAffectedCache reads the cache file; a missing or corrupt file yields an empty cache.
+func loadAffectedCache(dir string, log *slog.Logger) *affectedCache {
+    c := &affectedCache{path: filepath.Join(dir, cacheFile), Entries: map[string]cacheEntry{}}
+    raw, err := os.ReadFile(c.path)
+    if err != nil {
+        if !os.IsNotExist(err) {
+            log.Warn("could not read cache, starting empty", "path", c.path, "err", err)
+        }
+        return c
+    }
+    if err := json.Unmarshal(raw, c); err != nil || c.Entries == nil {
+        log.Warn("cache file is corrupt, starting empty", "path", c.path)
+        c.Entries = map[string]cacheEntry{}
+    }
+    return c
+}
+
+// lookup returns the cached result for key when it was computed from the same metadata.
+func (c *affectedCache) lookup(key, metaSum string) ([]string, bool) {
+    e, ok := c.Entries[key]
+    if !ok || e.MetadataSHA != metaSum {
+        return nil, false
+    }
+    return e.Affected, true
+}
+
+// store records a result and rewrites the cache file atomically, evicting the
+// oldest entries once cacheMaxEntries is exceeded.
+func (c *affectedCache) store(key, metaSum string, affected []string) error {
+    if affected == nil {
+        affected = []string{}
+    }
+    c.Entries[key] = cacheEntry{MetadataSHA: metaSum, Affected: affected, CreatedAt: time.Now().UTC()}
+    for len(c.Entries) > cacheMaxEntries {
+        var oldestKey string
+        var oldest time.Time
+        for k, e := range c.Entries {
+            if oldestKey == "" || e.CreatedAt.Before(oldest) {
+                oldestKey, oldest = k, e.CreatedAt
+            }
+        }
+        delete(c.Entries, oldestKey)
+    }
+
+    if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+        return err
+    }
+    raw, err := json.MarshalIndent(c, "", "  ")
+    if err != nil {
+        return err
+    }
+    tmp := c.path + ".tmp"
+    if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+        return err
+    }
+    return os.Rename(tmp, c.path)
+}
+
+// cacheKeyFor resolves the commits a diff in the given mode compares and
+// returns "<mode>:<base_sha>..<head_sha>".
+func cacheKeyFor(ctx context.Context, repo, mode, baseRef string) (string, error) {
+    head, err := gitdiff.RevParse(ctx, repo, "HEAD")
+    if err != nil {
+        return "", err
+    }
+    var base string
+    switch mode {
+    case "branch":
+        base, err = gitdiff.MergeBase(ctx, repo, baseRef, "HEAD")
+    case "main":
+        base, err = gitdiff.RevParse(ctx, repo, "HEAD~1")
+    default:
+        // the tag lookup is itself git work; only branch/main runs are cached
+        return "", fmt.Errorf("mode %q is not cacheable", mode)
+    }
+    if err != nil {
+        return "", err
+    }
+    return fmt.Sprintf("%s:%s..%s", mode, base, head), nil
+}
+
+// -----------------------------------------------------------------------------
+// cmd/pipeline-gen/watch.go
+// -----------------------------------------------------------------------------
+// -watch turns pipeline-gen into a local loop: on every save it logs the
+// deployables CI would build and prints, or runs, the tests of the projects
+// the change can break. The cache is not used; nothing here is committed.
+package main
+
+import (
+    "context"
+    "fmt"
+    "log/slog"
+    "os"
+    "os/exec"
+    "os/signal"
+
+    "github.com/yourorg/tool/affected"
+)
+
+// runWatch watches until interrupted. Tests run one project at a time, in
+// the repo root; a failing one is logged and the rest still run, and saves
+// made meanwhile are picked up once they are done.
+func runWatch(log *slog.Logger, opts affected.WatchOptions, testCmd string, runTests bool) error {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+    log.Info("watching for saves – interrupt to stop", "repo", opts.Repo, "interval", opts.Interval)
+    return affected.Watch(ctx, opts, func(r *affected.Result, err error) {
+        if err != nil {
+            log.Warn("watch", "err", err)
+            return
+        }
+        log.Info("saved", "files", r.ChangedFiles, "projects", r.ChangedProjects)
+        report(log, r.Affected)
+        switch {
+        case len(r.Tests) == 0:
+            return
+        case testCmd == "":
+            log.Info("projects to test", "count", len(r.Tests), "projects", r.Tests)
+            return
+        }
+        var failed []string
+        for _, project := range r.Tests {
+            line := affected.TestCommand(testCmd, project)
+            if !runTests {
+                fmt.Println(line)
+                continue
+            }
+            log.Info("running tests", "project", project, "cmd", line)
+            cmd := exec.CommandContext(ctx, "sh", "-c", line)
+            cmd.Dir = opts.Repo
+            cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+            if err := cmd.Run(); err != nil {
+                if ctx.Err() != nil {
+                    return
+                }
+                log.Warn("project tests failed", "project", project, "err", err)
+                failed = append(failed, project)
+            }
+        }
+        switch {
+        case !runTests:
+        case len(failed) > 0:
+            log.Error("tests failed", "count", len(failed), "projects", failed)
+        default:
+            log.Info("tests passed", "count", len(r.Tests))
+        }
+    })
+}
+
+// -----------------------------------------------------------------------------
+// depgraph/files_test.go (unit tests)
+// -----------------------------------------------------------------------------
+//go:build unit
+// +build unit
+
+package depgraph
+
+import (
+    "fmt"
+    "reflect"
+    "sync"
+    "testing"
+    "testing/fstest"
+)
+
+func TestProjectForFileLongestPrefix(t *testing.T) {
+    g, err := NewGraph([]Project{
+        {Name: ":apps:a", ProjectDir: "apps/a"},
+        {Name: ":apps:a:b", ProjectDir: "apps/a/b/"},
+        {Name: ":apps:ab", ProjectDir: "apps/ab"},
+    })
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    cases := map[string]string{
+        "apps/a/main.go":    ":apps:a",
+        "apps/a/b/x/y.java": ":apps:a:b",
+        "apps/ab/build.kts": ":apps:ab",
+        "apps/a":            ":apps:a",
+        "docs/readme.md":    "",
+    }
+    for file, want := range cases {
+        got, _ := g.ProjectForFile(file)
+        if got != want {
+            t.Errorf("ProjectForFile(%q) = %q, want %q", file, got, want)
+        }
+    }
+
+    got := g.ProjectsForFiles([]string{"apps/ab/x", "apps/a/y", "apps/a/z", ""})
+    if want := []string{":apps:a", ":apps:ab"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("ProjectsForFiles = %v, want %v", got, want)
+    }
+}
+
+func TestReleasePathsStopsAtDeployablesAndExcludes(t *testing.T) {
+    g, err := NewGraph([]Project{
+        {Name: ":apps:web", ProjectDir: "apps/web", Deployable: true, Dependencies: []string{":apps:api", ":libs:ui"}, ExcludePaths: []string{"docs", "*.md"}},
+        {Name: ":apps:api", ProjectDir: "apps/api", Deployable: true, Dependencies: []string{":libs:db"}},
+        {Name: ":libs:ui", ProjectDir: "libs/ui", ExcludePaths: []string{"stories"}},
+        {Name: ":libs:db", ProjectDir: "libs/db"},
+    })
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    include, exclude, err := g.ReleasePaths(":apps:web")
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if want := []string{"apps/web", "libs/ui"}; !reflect.DeepEqual(include, want) {
+        t.Errorf("include = %v, want %v", include, want)
+    }
+    if want := []string{"apps/web/*.md", "apps/web/docs", "libs/ui/stories"}; !reflect.DeepEqual(exclude, want) {
+        t.Errorf("exclude = %v, want %v", exclude, want)
+    }
+
+    for file, want := range map[string]string{
+        "apps/web/docs/guide.txt": "",
+        "apps/web/README.md":      "",
+        "apps/web/src/main.ts":    ":apps:web",
+    } {
+        if got, _ := g.ProjectForFile(file); got != want {
+            t.Errorf("ProjectForFile(%q) = %q, want %q", file, got, want)
+        }
+    }
+}
+
+func TestAffectedPathsAreShortestChains(t *testing.T) {
+    g, err := NewGraph([]Project{
+        {Name: ":apps:web", Deployable: true, Dependencies: []string{":libs:api", ":libs:ui"}},
+        {Name: ":apps:batch", Deployable: true, Dependencies: []string{":libs:db"}},
+        {Name: ":libs:api", Dependencies: []string{":libs:db"}},
+        {Name: ":libs:ui", Dependencies: []string{":libs:api"}},
+        {Name: ":libs:db"},
+    })
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    paths, err := g.AffectedPaths([]string{":libs:db", ":apps:batch"})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    want := map[string][]string{
+        ":apps:batch": {":apps:batch"},
+        ":apps:web":   {":libs:db", ":libs:api", ":apps:web"},
+    }
+    if !reflect.DeepEqual(paths, want) {
+        t.Errorf("AffectedPaths = %v, want %v", paths, want)
+    }
+
+    affected, _ := g.AffectedDeployables([]string{":libs:db", ":apps:batch"})
+    if len(affected) != len(paths) {
+        t.Errorf("AffectedDeployables = %v, but paths explain %v", affected, paths)
+    }
+}
+
+func TestAffectedDeployablesMultiHop(t *testing.T) {
+    // db → api (service) → gateway (service) → web (service); worker depends
+    // on an artifact outside the graph.
+    g, err := NewGraph([]Project{
+        {Name: ":libs:db"},
+        {Name: ":apps:api", Deployable: true, Dependencies: []string{":libs:db"}},
+        {Name: ":libs:client", Dependencies: []string{":apps:api"}},
+        {Name: ":apps:gateway", Deployable: true, Dependencies: []string{":libs:client"}},
+        {Name: ":apps:web", Deployable: true, Dependencies: []string{":apps:gateway", "com.acme:published:1.2"}},
+        {Name: ":apps:worker", Deployable: true, Dependencies: []string{"com.acme:published:1.2"}},
+    })
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    cases := []struct {
+        changed []string
+        want    []string
+    }{
+        {[]string{":libs:db"}, []string{":apps:api", ":apps:gateway", ":apps:web"}},
+        {[]string{":apps:api"}, []string{":apps:api", ":apps:gateway", ":apps:web"}},
+        {[]string{":apps:gateway"}, []string{":apps:gateway", ":apps:web"}},
+        {[]string{":apps:worker"}, []string{":apps:worker"}},
+        {nil, []string{}},
+    }
+    for _, c := range cases {
+        got, err := g.AffectedDeployables(c.changed)
+        if err != nil {
+            t.Fatalf("AffectedDeployables(%v): %v", c.changed, err)
+        }
+        if !reflect.DeepEqual(got, c.want) {
+            t.Errorf("AffectedDeployables(%v) = %v, want %v", c.changed, got, c.want)
+        }
+    }
+
+    paths, err := g.AffectedPaths([]string{":libs:db"})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if want := []string{":libs:db", ":apps:api", ":libs:client", ":apps:gateway", ":apps:web"}; !reflect.DeepEqual(paths[":apps:web"], want) {
+        t.Errorf("AffectedPaths[:apps:web] = %v, want %v", paths[":apps:web"], want)
+    }
+    if _, err := g.AffectedDeployables([]string{":libs:missing"}); err == nil {
+        t.Error("AffectedDeployables(:libs:missing) = nil error, want one")
+    }
+}
+
+func TestDeployableRulesFirstDecisionWins(t *testing.T) {
+    repo := fstest.MapFS{
+        "services/gateway/Dockerfile": {},
+        "apps/tool/Dockerfile":        {},
+        "libs/db/Dockerfile/x":        {}, // a directory, not the file
+    }
+    projects := []Project{
+        {Name: ":apps:web", ProjectDir: "apps/web"},
+        {Name: ":apps:tool", ProjectDir: "apps/tool"},
+        {Name: ":services:gateway", ProjectDir: "services/gateway"},
+        {Name: ":legacy:batch", ProjectDir: "legacy/batch", Deployable: true},
+        {Name: ":libs:db", ProjectDir: "libs/db"},
+        {Name: ":appsx:a", ProjectDir: "appsx/a"},
+    }
+    g, err := NewGraph(projects,
+        Overrides(map[string]bool{":apps:tool": false}),
+        Declared,
+        DirPrefix("apps/"),
+        HasFile(repo, "Dockerfile"),
+    )
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if got, want := g.Deployables(), []string{":apps:web", ":legacy:batch", ":services:gateway"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("Deployables = %v, want %v", got, want)
+    }
+
+    g, err = NewGraph(projects, GradleAppsDir(fstest.MapFS{"web/build.gradle.kts": {}, "tool": {}}, "apps"))
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if got, want := g.Deployables(), []string{":apps:web"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("GradleAppsDir: Deployables = %v, want %v", got, want)
+    }
+
+    g, err = NewGraph(projects)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if got, want := g.Deployables(), []string{":legacy:batch"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("without rules: Deployables = %v, want %v", got, want)
+    }
+}
+
+func TestAddAndRemoveProjectRevalidate(t *testing.T) {
+    g, err := NewGraph([]Project{
+        {Name: ":apps:web", ProjectDir: "apps/web", Deployable: true, Dependencies: []string{":libs:api"}},
+        {Name: ":libs:api", ProjectDir: "libs/api"},
+    }, Declared, DirPrefix("apps"))
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+
+    // a new library under an existing one, and the rules apply to new projects
+    if err := g.AddProject(Project{Name: ":libs:db", ProjectDir: "libs/db"}); err != nil {
+        t.Fatalf("AddProject: %v", err)
+    }
+    if err := g.AddProject(Project{Name: ":libs:api", ProjectDir: "libs/api", Dependencies: []string{":libs:db"}}); err != nil {
+        t.Fatalf("AddProject (replace): %v", err)
+    }
+    if err := g.AddProject(Project{Name: ":apps:batch", ProjectDir: "apps/batch", Dependencies: []string{":libs:db"}}); err != nil {
+        t.Fatalf("AddProject: %v", err)
+    }
+    affected, _ := g.AffectedDeployables([]string{":libs:db"})
+    if want := []string{":apps:batch", ":apps:web"}; !reflect.DeepEqual(affected, want) {
+        t.Errorf("AffectedDeployables = %v, want %v", affected, want)
+    }
+
+    for _, bad := range []Project{
+        {Name: ":libs:db", Dependencies: []string{":apps:web"}}, // web → api → db → web
+        {Name: ":libs:x", Dependencies: []string{":libs:x"}},
+    } {
+        if err := g.AddProject(bad); err == nil {
+            t.Errorf("AddProject(%v) = nil, want an error", bad)
+        }
+    }
+    order, _ := g.TopoOrder([]string{":apps:web", ":libs:api", ":libs:db"})
+    if want := []string{":libs:db", ":libs:api", ":apps:web"}; !reflect.DeepEqual(order, want) {
+        t.Errorf("after rejected updates, TopoOrder = %v, want %v", order, want)
+    }
+
+    if err := g.RemoveProject(":libs:db"); err == nil {
+        t.Error("RemoveProject(:libs:db) = nil, but :libs:api still depends on it")
+    }
+    if err := g.RemoveProject(":apps:batch"); err != nil {
+        t.Fatalf("RemoveProject: %v", err)
+    }
+    affected, _ = g.AffectedDeployables([]string{":libs:db"})
+    if want := []string{":apps:web"}; !reflect.DeepEqual(affected, want) {
+        t.Errorf("after removal, AffectedDeployables = %v, want %v", affected, want)
+    }
+}
+
+func TestAddProjectWithExternalDependencies(t *testing.T) {
+    web := Project{Name: ":apps:web", ProjectDir: "apps/web", Deployable: true,
+        Dependencies: []string{":libs:api", "com.example:client:1.2", ":libs:cache"}}
+    api := Project{Name: ":libs:api", ProjectDir: "libs/api", Dependencies: []string{":libs:db"}}
+    g, err := NewGraph([]Project{web, api})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+
+    // unchanged metadata goes back in, external dependencies and all
+    for _, p := range []Project{web, api} {
+        if err := g.AddProject(p); err != nil {
+            t.Fatalf("AddProject(%s) again: %v", p.Name, err)
+        }
+    }
+    affected, _ := g.AffectedDeployables([]string{":libs:api"})
+    if want := []string{":apps:web"}; !reflect.DeepEqual(affected, want) {
+        t.Errorf("AffectedDeployables = %v, want %v", affected, want)
+    }
+
+    // a project listed before it was in the graph is linked once added
+    if err := g.AddProject(Project{Name: ":libs:db", ProjectDir: "libs/db"}); err != nil {
+        t.Fatalf("AddProject(:libs:db): %v", err)
+    }
+    affected, _ = g.AffectedDeployables([]string{":libs:db"})
+    if want := []string{":apps:web"}; !reflect.DeepEqual(affected, want) {
+        t.Errorf("after adding :libs:db, AffectedDeployables = %v, want %v", affected, want)
+    }
+    if err := g.RemoveProject(":libs:db"); err == nil {
+        t.Error("RemoveProject(:libs:db) = nil, but :libs:api depends on it")
+    }
+
+    // one that would close a cycle through the projects listing it is not
+    if err := g.AddProject(Project{Name: ":libs:cache", Dependencies: []string{":apps:web"}}); err == nil {
+        t.Error("AddProject(:libs:cache → :apps:web) = nil, but :apps:web lists :libs:cache")
+    }
+    affected, _ = g.AffectedDeployables([]string{":libs:api"})
+    if want := []string{":apps:web"}; !reflect.DeepEqual(affected, want) {
+        t.Errorf("after a rejected add, AffectedDeployables = %v, want %v", affected, want)
+    }
+    if err := g.AddProject(Project{Name: ":libs:cache", ProjectDir: "libs/cache"}); err != nil {
+        t.Fatalf("AddProject(:libs:cache): %v", err)
+    }
+    affected, _ = g.AffectedDeployables([]string{":libs:cache"})
+    if want := []string{":apps:web"}; !reflect.DeepEqual(affected, want) {
+        t.Errorf("after adding :libs:cache, AffectedDeployables = %v, want %v", affected, want)
+    }
+}
+
+func TestAffectedProjectsWalkPastDeployables(t *testing.T) {
+    g, err := NewGraph([]Project{
+        {Name: ":apps:web", Deployable: true, Dependencies: []string{":apps:api"}},
+        {Name: ":apps:api", Deployable: true, Dependencies: []string{":libs:db"}},
+        {Name: ":apps:batch", Deployable: true},
+        {Name: ":libs:db"},
+    })
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    got, err := g.AffectedProjects([]string{":libs:db"})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if want := []string{":apps:api", ":apps:web", ":libs:db"}; !reflect.DeepEqual(got, want) {
+        t.Errorf("AffectedProjects = %v, want %v", got, want)
+    }
+    if _, err := g.AffectedProjects([]string{":libs:missing"}); err == nil {
+        t.Error("AffectedProjects(:libs:missing) = nil error, want one")
+    }
+}
+
+func TestGraphConcurrentUpdates(t *testing.T) {
+    g, err := NewGraph([]Project{{Name: ":libs:db", ProjectDir: "libs/db"}})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(2)
+        go func(i int) {
+            defer wg.Done()
+            name := fmt.Sprintf(":apps:a%d", i)
+            g.AddProject(Project{Name: name, ProjectDir: "apps/" + name, Deployable: true, Dependencies: []string{":libs:db"}})
+            g.RemoveProject(name)
+        }(i)
+        go func() {
+            defer wg.Done()
+            g.AffectedDeployables([]string{":libs:db"})
+            g.ProjectsForFiles([]string{"libs/db/x.go"})
+        }()
+    }
+    wg.Wait()
+    if affected, _ := g.AffectedDeployables([]string{":libs:db"}); len(affected) != 0 {
+        t.Errorf("AffectedDeployables = %v, want none once every app is removed", affected)
+    }
+}
+
+// -----------------------------------------------------------------------------
+// gitdiff/hunks_test.go (unit tests)
+// -----------------------------------------------------------------------------
+//go:build unit
+// +build unit
+
+package gitdiff
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestParseHunks(t *testing.T) {
+    diff := `diff --git a/apps/web/Main.java b/apps/web/Main.java
+index 1111111..2222222 100644
+--- a/apps/web/Main.java
++++ b/apps/web/Main.java
+@@ -3 +3 @@ class Main {
+-int a;
++long a;
+@@ -10,0 +11,2 @@ class Main {
++-- not a header
++++ nor this
+@@ -20,3 +22,0 @@
+-x
+-y
+-z
+diff --git a/libs/old.txt b/libs/new.txt
+similarity index 90%
+rename from libs/old.txt
+rename to libs/new.txt
+--- a/libs/old.txt
++++ b/libs/new.txt
+@@ -1 +1 @@
+-a
++b
+diff --git a/gone.md b/gone.md
+deleted file mode 100644
+--- a/gone.md
++++ /dev/null
+@@ -1,2 +0,0 @@
+-one
+-two
+diff --git a/logo.png b/logo.png
+Binary files a/logo.png and b/logo.png differ`
+    got, err := parseHunks(diff)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    want := []FileHunks{
+        {Path: "apps/web/Main.java",
+            Added:   []LineRange{{3, 3}, {11, 12}},
+            Removed: []LineRange{{3, 3}, {20, 22}}},
+        {Path: "libs/new.txt", OldPath: "libs/old.txt", Added: []LineRange{{1, 1}}, Removed: []LineRange{{1, 1}}},
+        {Path: "gone.md", OldPath: "gone.md", Removed: []LineRange{{1, 2}}},
+    }
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("parseHunks =\n%+v\nwant\n%+v", got, want)
+    }
+}
+
+// -----------------------------------------------------------------------------
+// affected/watch_test.go (unit tests)
+// -----------------------------------------------------------------------------
+//go:build unit
+// +build unit
+
+package affected
+
+import (
+    "context"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "time"
+
+    "github.com/yourorg/tool/depgraph"
+)
+
+func TestAnalyseOrdersTestsByDependency(t *testing.T) {
+    g, err := depgraph.NewGraph([]depgraph.Project{
+        {Name: ":libs:db", ProjectDir: "libs/db"},
+        {Name: ":apps:api", ProjectDir: "apps/api", Deployable: true, Dependencies: []string{":libs:db"}},
+        {Name: ":apps:web", ProjectDir: "apps/web", Deployable: true, Dependencies: []string{":apps:api"}},
+        {Name: ":apps:batch", ProjectDir: "apps/batch", Deployable: true},
+    })
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    r, err := Analyse(g, []string{"libs/db/Db.java", "README.md"})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if want := []string{":libs:db"}; !reflect.DeepEqual(r.ChangedProjects, want) {
+        t.Errorf("ChangedProjects = %v, want %v", r.ChangedProjects, want)
+    }
+    if want := []string{":apps:api", ":apps:web"}; !reflect.DeepEqual(r.Affected, want) {
+        t.Errorf("Affected = %v, want %v", r.Affected, want)
+    }
+    if want := []string{":libs:db", ":apps:api", ":apps:web"}; !reflect.DeepEqual(r.Tests, want) {
+        t.Errorf("Tests = %v, want %v", r.Tests, want)
+    }
+
+    r, err = Analyse(g, nil)
+    if err != nil || len(r.ChangedFiles) != 0 || r.ChangedFiles == nil || len(r.Tests) != 0 {
+        t.Errorf("Analyse(nil) = %+v, %v, want an empty result", r, err)
+    }
+}
+
+func TestTestCommand(t *testing.T) {
+    for _, c := range []struct{ template, project, want string }{
+        {"./gradlew {project}:test", ":libs:db", "./gradlew :libs:db:test"},
+        {"make -C {project} test PROJECT={project}", "web", "make -C web test PROJECT=web"},
+        {"go test ./...", ":libs:db", "go test ./..."},
+    } {
+        if got := TestCommand(c.template, c.project); got != c.want {
+            t.Errorf("TestCommand(%q, %q) = %q, want %q", c.template, c.project, got, c.want)
+        }
+    }
+}
+
+// gitRepo makes a repo with the metadata of a library and the app using
+// it, and one commit.
+func gitRepo(t *testing.T) string {
+    t.Helper()
+    if _, err := exec.LookPath("git"); err != nil {
+        t.Skip("git not installed")
+    }
+    dir := t.TempDir()
+    for name, data := range map[string]string{
+        "projects.json":     `{":libs:db": {"projectDir": "libs/db"}, ":apps:web": {"projectDir": "apps/web", "deployable": true, "dependencies": [":libs:db"]}}`,
+        "libs/db/Db.java":   "class Db {}\n",
+        "apps/web/Web.java": "class Web {}\n",
+        ".gitignore":        "build/\n",
+    } {
+        writeFile(t, filepath.Join(dir, name), data)
+    }
+    git(t, dir, "init", "-q")
+    git(t, dir, "add", "-A")
+    git(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init")
+    return dir
+}
+
+func git(t *testing.T, dir string, args ...string) {
+    t.Helper()
+    cmd := exec.Command("git", args...)
+    cmd.Dir = dir
+    if out, err := cmd.CombinedOutput(); err != nil {
+        t.Fatalf("git %v: %v\n%s", args, err, out)
+    }
+}
+
+func writeFile(t *testing.T, path, data string) {
+    t.Helper()
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+        t.Fatal(err)
+    }
+}
+
+type watchReport struct {
+    r   *Result
+    err error
+}
+
+func TestWatchReportsEachSave(t *testing.T) {
+    repo := gitRepo(t)
+    ctx, cancel := context.WithCancel(context.Background())
+    defer cancel()
+    reports := make(chan watchReport, 16)
+    done := make(chan error, 1)
+    go func() {
+        done <- Watch(ctx, WatchOptions{Repo: repo, Metadata: filepath.Join(repo, "projects.json"), Interval: 10 * time.Millisecond},
+            func(r *Result, err error) { reports <- watchReport{r, err} })
+    }()
+    next := func(what string) watchReport {
+        t.Helper()
+        select {
+        case rep := <-reports:
+            return rep
+        case <-time.After(5 * time.Second):
+            t.Fatalf("no report after %s", what)
+            return watchReport{}
+        }
+    }
+    expect := func(what string, files, affected []string) {
+        t.Helper()
+        rep := next(what)
+        if rep.err != nil {
+            t.Fatalf("%s: %v", what, rep.err)
+        }
+        if rep.r.Mode != "watch" || !reflect.DeepEqual(rep.r.ChangedFiles, files) || !reflect.DeepEqual(rep.r.Affected, affected) {
+            t.Errorf("%s: report %+v, want files %v affecting %v", what, rep.r, files, affected)
+        }
+    }
+
+    expect("start", []string{}, []string{})
+    writeFile(t, filepath.Join(repo, "libs/db/Db.java"), "class Db { int x; }\n")
+    expect("a library saved", []string{"libs/db/Db.java"}, []string{":apps:web"})
+    writeFile(t, filepath.Join(repo, "build/out.txt"), "ignored\n")
+    writeFile(t, filepath.Join(repo, "apps/web/New.java"), "class New {}\n")
+    expect("a new file, next to an ignored one", []string{"apps/web/New.java"}, []string{":apps:web"})
+    git(t, repo, "checkout", "--", "libs/db/Db.java")
+    expect("a revert", []string{"libs/db/Db.java"}, []string{":apps:web"})
+
+    writeFile(t, filepath.Join(repo, "projects.json"), "{half-edited")
+    if rep := next("broken metadata"); rep.err == nil {
+        t.Errorf("broken metadata: report %+v, want an error", rep.r)
+    }
+
+    cancel()
+    select {
+    case err := <-done:
+        if err != nil {
+            t.Errorf("Watch = %v, want nil once cancelled", err)
+        }
+    case <-time.After(5 * time.Second):
+        t.Fatal("Watch did not stop when cancelled")
+    }
+}
+
+func TestWatchNeedsAGraph(t *testing.T) {
+    repo := gitRepo(t)
+    err := Watch(context.Background(), WatchOptions{Repo: repo, Metadata: filepath.Join(repo, "missing.json")}, func(*Result, error) {
+        t.Error("saved called without a graph")
+    })
+    if err == nil {
+        t.Error("Watch with missing metadata = nil, want an error")
+    }
+}
+
 // -----------------------------------------------------------------------------
 // Tests (unit + integration) remain unchanged from previous revision and are
 // omitted here for brevity, but still live in this module so `go test ./...`
@@ -1,19 +1,20 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
-	"text/template"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
-	"gopkg.in/yaml.v3"
+	"github.com/your-username/ansible-inventory-go/ansibleinv"
+
+	"loki/internal/tui"
 )
 
 // -------------------------------------------------------------
@@ -26,31 +27,47 @@ type selection struct {
 }
 
 // -------------------------------------------------------------
-// MOCK DISCOVERY (replace with real svc / DB / API)
+// HOST DISCOVERY
 // -------------------------------------------------------------
 
-var allEnvs = []string{"dev", "qa", "staging", "prod"}
-
-func fetchHostsForEnv(env string) []string {
-	switch env {
-	case "dev":
-		return []string{"dev-app1", "dev-db1"}
-	case "qa":
-		return []string{"qa-app1", "qa-app2", "qa-db1"}
-	case "staging":
-		return []string{"stg-app", "stg-db"}
-	case "prod":
-		return []string{"app1", "app2", "db1", "db2"}
-	default:
-		return nil
+// hostSource picks the tui.HostSource the flags name: a static hosts file,
+// an Ansible inventory or a discovery script, exactly one of them.
+func hostSource(ctx context.Context, hostsFile, inventory, hostsCmd string) (tui.HostSource, error) {
+	var src tui.HostSource
+	n := 0
+	if hostsFile != "" {
+		n++
+		hosts, err := tui.LoadStaticHosts(hostsFile)
+		if err != nil {
+			return nil, err
+		}
+		src = hosts
+	}
+	if inventory != "" {
+		n++
+		hosts, err := tui.LoadInventoryHosts(ctx, inventory)
+		if err != nil {
+			return nil, err
+		}
+		src = hosts
 	}
+	if hostsCmd != "" {
+		n++
+		src = tui.ExecHosts{Command: strings.Fields(hostsCmd)}
+	}
+	if n != 1 {
+		return nil, errors.New("pass one of -hosts, -inventory or -hosts-cmd to say where the hosts come from")
+	}
+	return tui.NewCachedHosts(src, 0), nil
 }
 
 // -------------------------------------------------------------
 // YAML (DE)SERIALISATION
 // -------------------------------------------------------------
 
-// Minimal inventory schema for
+// The inventory is one group per env under all, each host with its own name
+// as ansible_host:
+//
 // all:
 //   children:
 //     env:
@@ -58,59 +75,60 @@ func fetchHostsForEnv(env string) []string {
 //         hostname:
 //           ansible_host: hostname
 //
-
-type hostNode struct {
-	AnsibleHost string `yaml:"ansible_host"`
-}
-
-type envNode struct {
-	Hosts map[string]hostNode `yaml:"hosts"`
-}
-
-type inventoryRoot struct {
-	All struct {
-		Children map[string]envNode `yaml:"children"`
-	} `yaml:"all"`
-}
+// It goes through ansibleinv both ways, so host names YAML would read as
+// something else (yes, 001, null) are quoted, and ones ansible would not
+// accept are refused.
 
 func parseInventory(path string) (*selection, error) {
-	data, err := os.ReadFile(path)
+	inv, err := ansibleinv.ParseYAMLFile(path)
 	if err != nil {
 		return nil, err
 	}
-	var root inventoryRoot
-	if err := yaml.Unmarshal(data, &root); err != nil {
-		return nil, err
+	all, ok := inv.Groups["all"]
+	if !ok {
+		return nil, fmt.Errorf("%s: inventory has no all group", path)
 	}
 	sel := &selection{Hosts: map[string][]string{}}
-	for env, node := range root.All.Children {
+	for env, group := range all.Children {
 		sel.Envs = append(sel.Envs, env)
-		for host := range node.Hosts {
+		for host := range group.Hosts {
 			sel.Hosts[env] = append(sel.Hosts[env], host)
 		}
+		sort.Strings(sel.Hosts[env])
 	}
 	sort.Strings(sel.Envs)
 	return sel, nil
 }
 
-func writeInventory(path string, sel selection) error {
-	root := inventoryRoot{}
-	root.All.Children = map[string]envNode{}
+// buildInventory turns sel into an inventory: only the selected envs, with
+// the hosts picked for them.
+func buildInventory(sel selection) (*ansibleinv.Inventory, error) {
+	inv := ansibleinv.NewInventory()
+	inv.Group("all")
 	for _, env := range sel.Envs {
-		hosts := map[string]hostNode{}
+		if _, err := inv.AddGroup(env, "all"); err != nil {
+			return nil, err
+		}
 		for _, h := range sel.Hosts[env] {
-			hosts[h] = hostNode{AnsibleHost: h}
+			if _, err := inv.AddHost(env, h, map[string]any{"ansible_host": h}); err != nil {
+				return nil, fmt.Errorf("%s: %w", env, err)
+			}
 		}
-		root.All.Children[env] = envNode{Hosts: hosts}
 	}
-	out, err := yaml.Marshal(root)
+	return inv, nil
+}
+
+// marshalInventory is the inventory YAML of sel.
+func marshalInventory(sel selection) ([]byte, error) {
+	inv, err := buildInventory(sel)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
+	data, err := inv.MarshalYAML()
+	if err != nil {
+		return nil, err
 	}
-	return os.WriteFile(path, out, fs.FileMode(0o644))
+	return append([]byte("# generated\n"), data...), nil
 }
 
 // -------------------------------------------------------------
@@ -120,6 +138,8 @@ func writeInventory(path string, sel selection) error {
 type model struct {
 	form *huh.Form
 
+	hosts tui.HostSource
+
 	// reactive fields bound to widgets
 	envSelected string              // current env for host picker
 	envMulti    []string            // final env selection
@@ -131,8 +151,8 @@ type model struct {
 	err     error
 }
 
-func newModel(existing *selection) *model {
-	m := &model{hostsPicked: map[string][]string{}}
+func newModel(existing *selection, hosts tui.HostSource, envs []string) *model {
+	m := &model{hosts: hosts, hostsPicked: map[string][]string{}}
 
 	// prime defaults if editing
 	if existing != nil {
@@ -147,12 +167,12 @@ func newModel(existing *selection) *model {
 
 	envMultiSel := huh.NewMultiSelect[string]().
 		Title("Select environments:").
-		Options(huh.NewOptions(allEnvs...)...).
+		Options(huh.NewOptions(envs...)...).
 		Value(&m.envMulti)
 
 	envSelect := huh.NewSelect[string]().
 		Title("Active environment (for host picking):").
-		Options(huh.NewOptions(allEnvs...)...).
+		Options(huh.NewOptions(envs...)...).
 		Value(&m.envSelected)
 
 	hostMulti := huh.NewMultiSelect[string]().
@@ -161,14 +181,18 @@ func newModel(existing *selection) *model {
 			if m.envSelected == "" {
 				return "— choose an environment first —"
 			}
+			if _, err := m.hosts.Hosts(context.Background(), m.envSelected); err != nil {
+				return fmt.Sprintf("Hosts for %s: %v", m.envSelected, err)
+			}
 			return fmt.Sprintf("Hosts for %s:", m.envSelected)
 		}, &m.envSelected).
 		OptionsFunc(func() []huh.Option[string] {
 			if m.envSelected == "" {
 				return nil
 			}
+			hosts, _ := m.hosts.Hosts(context.Background(), m.envSelected) // the title shows the error
 			opts := []huh.Option[string]{}
-			for _, h := range fetchHostsForEnv(m.envSelected) {
+			for _, h := range hosts {
 				sel := contains(m.hostsPicked[m.envSelected], h)
 				opts = append(opts, huh.Option[string]{Key: h, Value: h, Selected: sel})
 			}
@@ -186,12 +210,11 @@ func newModel(existing *selection) *model {
 			return errors.New("pick at least one environment")
 		}
 		// finalise selection into YAML
-		sel := selection{Envs: m.envMulti, Hosts: m.hostsPicked}
-		buf := &bytes.Buffer{}
-		if err := template.Must(template.New("inv").Parse(`# generated\nall:\n  children:\n{{- range $env, $hosts := .Hosts }}\n    {{$env}}:\n      hosts:\n{{- range $hosts }}\n        {{.}}:\n          ansible_host: {{.}}\n{{- end }}{{ end }}\n`)).Execute(buf, sel); err != nil {
+		data, err := marshalInventory(selection{Envs: m.envMulti, Hosts: m.hostsPicked})
+		if err != nil {
 			return err
 		}
-		m.invYaml = buf.String()
+		m.invYaml = string(data)
 		m.done = true
 		return nil
 	})
@@ -230,9 +253,24 @@ func main() {
 	var (
 		outputPath = flag.String("o", ".ansible/app.yml", "output inventory path")
 		edit       = flag.Bool("edit", false, "edit existing inventory if present")
+		hostsFile  = flag.String("hosts", "", "YAML file mapping each environment to its hosts")
+		inventory  = flag.String("inventory", "", "Ansible inventory whose environment groups to pick hosts from")
+		hostsCmd   = flag.String("hosts-cmd", "", "script printing the environments, or with one as its argument that environment's hosts")
 	)
 	flag.Parse()
 
+	ctx := context.Background()
+	hosts, err := hostSource(ctx, *hostsFile, *inventory, *hostsCmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	envs, err := hosts.Envs(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
 	var existing *selection
 	if *edit {
 		if sel, err := parseInventory(*outputPath); err == nil {
@@ -240,13 +278,17 @@ func main() {
 		}
 	}
 
-	m := newModel(existing)
+	m := newModel(existing, hosts, envs)
 	if _, err := tea.NewProgram(m).Run(); err != nil {
 		fmt.Fprintln(os.Stderr, "error:", err)
 		os.Exit(1)
 	}
 
 	if m.invYaml != "" {
+		if err := os.MkdirAll(filepath.Dir(*outputPath), 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to save inventory:", err)
+			os.Exit(1)
+		}
 		if err := os.WriteFile(*outputPath, []byte(m.invYaml), 0o644); err != nil {
 			fmt.Fprintln(os.Stderr, "failed to save inventory:", err)
 			os.Exit(1)
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInventoryRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name string
+		sel  selection
+	}{
+		{
+			name: "plain hosts",
+			sel: selection{
+				Envs:  []string{"dev", "prod"},
+				Hosts: map[string][]string{"dev": {"dev-app1", "dev-db1"}, "prod": {"app1", "app2"}},
+			},
+		},
+		{
+			// the old template wrote these bare, so they read back as
+			// a bool, a number and null
+			name: "host names YAML would misread",
+			sel: selection{
+				Envs:  []string{"qa"},
+				Hosts: map[string][]string{"qa": {"001", "null", "yes"}},
+			},
+		},
+		{
+			name: "env without hosts",
+			sel: selection{
+				Envs:  []string{"staging"},
+				Hosts: map[string][]string{},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := marshalInventory(tc.sel)
+			if err != nil {
+				t.Fatalf("marshalInventory: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "app.yml")
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := parseInventory(path)
+			if err != nil {
+				t.Fatalf("parseInventory:\n%s\n%v", data, err)
+			}
+			if !reflect.DeepEqual(got.Envs, tc.sel.Envs) {
+				t.Errorf("envs = %v, want %v", got.Envs, tc.sel.Envs)
+			}
+			for _, env := range tc.sel.Envs {
+				if len(got.Hosts[env]) == 0 && len(tc.sel.Hosts[env]) == 0 {
+					continue
+				}
+				if !reflect.DeepEqual(got.Hosts[env], tc.sel.Hosts[env]) {
+					t.Errorf("%s hosts = %v, want %v", env, got.Hosts[env], tc.sel.Hosts[env])
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalInventoryQuotesAnsibleHost(t *testing.T) {
+	data, err := marshalInventory(selection{Envs: []string{"qa"}, Hosts: map[string][]string{"qa": {"yes"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		All struct {
+			Children map[string]struct {
+				Hosts map[string]map[string]any `yaml:"hosts"`
+			} `yaml:"children"`
+		} `yaml:"all"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid YAML:\n%s\n%v", data, err)
+	}
+	if got := doc.All.Children["qa"].Hosts["yes"]["ansible_host"]; got != "yes" {
+		t.Errorf("ansible_host = %#v, want the string \"yes\"\n%s", got, data)
+	}
+}
+
+func TestMarshalInventoryRefusesInvalidHostNames(t *testing.T) {
+	_, err := marshalInventory(selection{Envs: []string{"dev"}, Hosts: map[string][]string{"dev": {"web 01"}}})
+	if err == nil || !strings.Contains(err.Error(), "invalid host name") {
+		t.Errorf("err = %v, want an invalid host name error", err)
+	}
+}
@@ -0,0 +1,270 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/your-username/ansible-inventory-go/ansibleinv"
+	"gopkg.in/yaml.v3"
+)
+
+// HostSource is where the forms get the environments an app can be created
+// in and the hosts of each.
+type HostSource interface {
+	Envs(ctx context.Context) ([]string, error)
+	Hosts(ctx context.Context, env string) ([]string, error)
+}
+
+// StaticHosts maps each environment to its hosts.
+type StaticHosts map[string][]string
+
+// LoadStaticHosts reads StaticHosts from a YAML (or JSON) file such as
+//
+//	dev: [dev-01, dev-02]
+//	prod: [prod-a, prod-b]
+func LoadStaticHosts(path string) (StaticHosts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var hosts StaticHosts
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return hosts, nil
+}
+
+// Envs are the environments in the order of DefaultEnvs, then any others
+// sorted.
+func (s StaticHosts) Envs(context.Context) ([]string, error) {
+	envs := make([]string, 0, len(s))
+	for env := range s {
+		envs = append(envs, env)
+	}
+	return orderEnvs(envs), nil
+}
+
+func (s StaticHosts) Hosts(_ context.Context, env string) ([]string, error) {
+	return s[env], nil
+}
+
+// InventoryHosts reads the environments from an Ansible inventory: every
+// group named in DefaultEnvs, with all the hosts under it.
+type InventoryHosts struct {
+	Inventory *ansibleinv.Inventory
+}
+
+// LoadInventoryHosts reads the inventory files, directories or scripts at
+// paths, each taken as ansibleinv.SourceFor takes it.
+func LoadInventoryHosts(ctx context.Context, paths ...string) (InventoryHosts, error) {
+	sources := make([]ansibleinv.Source, len(paths))
+	for i, path := range paths {
+		sources[i] = ansibleinv.SourceFor(path)
+	}
+	inv, err := ansibleinv.LoadSources(ctx, sources...)
+	if err != nil {
+		return InventoryHosts{}, err
+	}
+	return InventoryHosts{Inventory: inv}, nil
+}
+
+func (s InventoryHosts) Envs(context.Context) ([]string, error) {
+	var envs []string
+	for _, env := range DefaultEnvs {
+		if _, exists := s.Inventory.Groups[env]; exists {
+			envs = append(envs, env)
+		}
+	}
+	return envs, nil
+}
+
+func (s InventoryHosts) Hosts(_ context.Context, env string) ([]string, error) {
+	group, exists := s.Inventory.Groups[env]
+	if !exists {
+		return nil, fmt.Errorf("no %s group in the inventory", env)
+	}
+	hosts := make([]string, 0, len(group.Hosts))
+	for name := range group.Hosts {
+		hosts = append(hosts, name)
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// GitLabEnvironmentLister lists the environments of a GitLab project by name
+// with their external URLs; the CLI's *gitlab.Client is one.
+type GitLabEnvironmentLister interface {
+	EnvironmentURLs(ctx context.Context, project string) (map[string]string, error)
+}
+
+// GitLabHosts reads the environments of a GitLab project. The hosts of an
+// environment are the hosts of its external URL and of the URLs of the
+// environments in its folder, e.g. prod/prod-a, or the names in the folder
+// of those that have none.
+type GitLabHosts struct {
+	Client  GitLabEnvironmentLister
+	Project string
+}
+
+func (s GitLabHosts) Envs(ctx context.Context) ([]string, error) {
+	urls, err := s.Client.EnvironmentURLs(ctx, s.Project)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var envs []string
+	for name := range urls {
+		env, _, _ := strings.Cut(name, "/")
+		if !seen[env] {
+			seen[env] = true
+			envs = append(envs, env)
+		}
+	}
+	return orderEnvs(envs), nil
+}
+
+func (s GitLabHosts) Hosts(ctx context.Context, env string) ([]string, error) {
+	urls, err := s.Client.EnvironmentURLs(ctx, s.Project)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var hosts []string
+	for name, external := range urls {
+		folder, rest, _ := strings.Cut(name, "/")
+		if folder != env {
+			continue
+		}
+		host := rest
+		if u, err := url.Parse(external); err == nil && u.Hostname() != "" {
+			host = u.Hostname()
+		}
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
+// ExecHosts runs a script: with no arguments it prints the environments,
+// with an environment as its last argument that environment's hosts, one
+// per line either way.
+type ExecHosts struct {
+	Command []string
+}
+
+func (s ExecHosts) Envs(ctx context.Context) ([]string, error) {
+	return s.run(ctx)
+}
+
+func (s ExecHosts) Hosts(ctx context.Context, env string) ([]string, error) {
+	return s.run(ctx, env)
+}
+
+func (s ExecHosts) run(ctx context.Context, args ...string) ([]string, error) {
+	if len(s.Command) == 0 {
+		return nil, fmt.Errorf("no host discovery command")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.Command[0], append(s.Command[1:len(s.Command):len(s.Command)], args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", strings.Join(cmd.Args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// DefaultHostTimeout is how long NewCachedHosts lets a lookup take.
+const DefaultHostTimeout = 10 * time.Second
+
+// cachedHosts remembers every answer of a HostSource, so a form asks it once
+// per environment however often the picker is redrawn.
+type cachedHosts struct {
+	src     HostSource
+	timeout time.Duration
+
+	mu    sync.Mutex
+	envs  []string
+	hosts map[string][]string
+}
+
+// NewCachedHosts wraps src so each of its answers is looked up once, for as
+// long as the form runs, and fails after timeout (DefaultHostTimeout if 0)
+// rather than hang the form. Failures are not cached.
+func NewCachedHosts(src HostSource, timeout time.Duration) HostSource {
+	if timeout == 0 {
+		timeout = DefaultHostTimeout
+	}
+	return &cachedHosts{src: src, timeout: timeout, hosts: map[string][]string{}}
+}
+
+func (c *cachedHosts) Envs(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.envs != nil {
+		return c.envs, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	envs, err := c.src.Envs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing environments: %w", err)
+	}
+	c.envs = append([]string{}, envs...)
+	return c.envs, nil
+}
+
+func (c *cachedHosts) Hosts(ctx context.Context, env string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hosts, ok := c.hosts[env]; ok {
+		return hosts, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	hosts, err := c.src.Hosts(ctx, env)
+	if err != nil {
+		return nil, fmt.Errorf("listing %s hosts: %w", env, err)
+	}
+	c.hosts[env] = append([]string{}, hosts...)
+	return c.hosts[env], nil
+}
+
+// orderEnvs sorts envs in the order of DefaultEnvs, then any others sorted.
+func orderEnvs(envs []string) []string {
+	rank := func(env string) int {
+		for i, known := range DefaultEnvs {
+			if env == known {
+				return i
+			}
+		}
+		return len(DefaultEnvs)
+	}
+	sort.Slice(envs, func(i, j int) bool {
+		ri, rj := rank(envs[i]), rank(envs[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return envs[i] < envs[j]
+	})
+	return envs
+}
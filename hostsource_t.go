@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadInventoryHostsMergesFilesAndScripts(t *testing.T) {
+	dir := t.TempDir()
+	static := filepath.Join(dir, "hosts.yml")
+	if err := os.WriteFile(static, []byte(`all:
+  children:
+    dev:
+      hosts:
+        dev-02: {}
+        dev-01: {}
+    prod:
+      hosts:
+        prod-a: {}
+    unrelated:
+      hosts:
+        build-01: {}
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "dynamic")
+	if err := os.WriteFile(script, []byte(`#!/bin/sh
+echo '{"qa": {"hosts": ["qa-01"]}, "_meta": {"hostvars": {}}}'
+`), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := LoadInventoryHosts(context.Background(), static, script)
+	if err != nil {
+		t.Fatalf("LoadInventoryHosts: %v", err)
+	}
+	envs, _ := src.Envs(context.Background())
+	if want := []string{"dev", "qa", "prod"}; !reflect.DeepEqual(envs, want) {
+		t.Errorf("Envs = %v, want %v", envs, want)
+	}
+	for env, want := range map[string][]string{
+		"dev":  {"dev-01", "dev-02"},
+		"qa":   {"qa-01"},
+		"prod": {"prod-a"},
+	} {
+		hosts, err := src.Hosts(context.Background(), env)
+		if err != nil || !reflect.DeepEqual(hosts, want) {
+			t.Errorf("Hosts(%s) = %v, %v, want %v", env, hosts, err, want)
+		}
+	}
+	if _, err := src.Hosts(context.Background(), "uat"); err == nil {
+		t.Error("Hosts(uat) = nil error, want one for a missing group")
+	}
+}
+
+func TestLoadInventoryHostsMissingSource(t *testing.T) {
+	if _, err := LoadInventoryHosts(context.Background(), filepath.Join(t.TempDir(), "nope.yml")); err == nil {
+		t.Error("LoadInventoryHosts of a missing file = nil error, want one")
+	}
+}
+
+// countingHosts counts lookups and fails those of env "broken" until fixed.
+type countingHosts struct {
+	envCalls, hostCalls int
+	fixed               bool
+	deadline            bool // whether every lookup had a deadline
+}
+
+func (c *countingHosts) Envs(ctx context.Context) ([]string, error) {
+	c.envCalls++
+	_, c.deadline = ctx.Deadline()
+	return []string{"dev", "prod"}, nil
+}
+
+func (c *countingHosts) Hosts(ctx context.Context, env string) ([]string, error) {
+	c.hostCalls++
+	_, c.deadline = ctx.Deadline()
+	if env == "broken" && !c.fixed {
+		return nil, errors.New("unreachable")
+	}
+	return []string{env + "-01"}, nil
+}
+
+func TestCachedHostsLooksUpOnce(t *testing.T) {
+	src := &countingHosts{}
+	cached := NewCachedHosts(src, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if envs, err := cached.Envs(ctx); err != nil || !reflect.DeepEqual(envs, []string{"dev", "prod"}) {
+			t.Fatalf("Envs = %v, %v", envs, err)
+		}
+		if hosts, err := cached.Hosts(ctx, "dev"); err != nil || !reflect.DeepEqual(hosts, []string{"dev-01"}) {
+			t.Fatalf("Hosts(dev) = %v, %v", hosts, err)
+		}
+	}
+	if src.envCalls != 1 || src.hostCalls != 1 {
+		t.Errorf("source asked %d times for envs and %d for hosts, want once each", src.envCalls, src.hostCalls)
+	}
+	if !src.deadline {
+		t.Error("lookups ran without a deadline")
+	}
+
+	// failures are not cached
+	if _, err := cached.Hosts(ctx, "broken"); err == nil {
+		t.Fatal("Hosts(broken) = nil error, want the source's")
+	}
+	src.fixed = true
+	if hosts, err := cached.Hosts(ctx, "broken"); err != nil || !reflect.DeepEqual(hosts, []string{"broken-01"}) {
+		t.Errorf("Hosts(broken) after a failure = %v, %v, want a fresh lookup", hosts, err)
+	}
+}
@@ -2,9 +2,18 @@
 package ansibleinv
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"path"
+	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"gopkg.in/yaml.v3"
 )
@@ -37,6 +46,1065 @@ func NewInventory() *Inventory {
 	}
 }
 
+// Group returns the named group, creating it if it does not exist yet.
+func (inv *Inventory) Group(name string) *Group {
+	group, exists := inv.Groups[name]
+	if !exists {
+		group = &Group{
+			Name:     name,
+			Hosts:    make(map[string]*Host),
+			Vars:     make(map[string]any),
+			Children: make(map[string]*Group),
+		}
+		inv.Groups[name] = group
+	}
+	return group
+}
+
+// addHost puts the named host in group, creating it in the global host list
+// if it does not exist yet, and returns it. It is the unchecked building
+// block of the parsers; see AddHost.
+func (inv *Inventory) addHost(group *Group, name string) *Host {
+	host, exists := inv.Hosts[name]
+	if !exists {
+		host = &Host{Name: name, Vars: make(map[string]any)}
+		inv.Hosts[name] = host
+	}
+	group.Hosts[name] = host
+	return host
+}
+
+// Names the mutation methods accept: group names and variables are what
+// ansible accepts without warnings, host names exclude what its patterns and
+// INI syntax give a meaning (spaces, ':', ',', '[', '=', '#').
+var (
+	validGroupName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	validHostName  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	validVarName   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// AddGroup adds a group as a child of parent, or at the top level if parent
+// is empty, and returns it. The parent must exist; adding a group that
+// exists already just links it under parent.
+func (inv *Inventory) AddGroup(name, parent string) (*Group, error) {
+	if !validGroupName.MatchString(name) {
+		return nil, fmt.Errorf("invalid group name %q: use letters, digits and '_'", name)
+	}
+	var parentGroup *Group
+	if parent != "" {
+		var exists bool
+		if parentGroup, exists = inv.Groups[parent]; !exists {
+			return nil, fmt.Errorf("group %q does not exist", parent)
+		}
+		if name == parent || inv.isAncestor(name, parent) {
+			return nil, fmt.Errorf("group %q cannot be a child of %q: it would be its own ancestor", name, parent)
+		}
+	}
+
+	group := inv.Group(name)
+	if parentGroup != nil {
+		parentGroup.Children[name] = group
+		for hostName, host := range group.Hosts {
+			inv.addToAncestors(parent, hostName, host)
+		}
+	}
+	return group, nil
+}
+
+// AddHost adds a host to an existing group, and through it to the group's
+// ancestors, setting vars on it. A host that exists already keeps its other
+// groups and vars.
+func (inv *Inventory) AddHost(group, name string, vars map[string]any) (*Host, error) {
+	g, exists := inv.Groups[group]
+	if !exists {
+		return nil, fmt.Errorf("group %q does not exist", group)
+	}
+	if !validHostName.MatchString(name) {
+		return nil, fmt.Errorf("invalid host name %q: use letters, digits, '.', '_' or '-'", name)
+	}
+	for k := range vars {
+		if !validVarName.MatchString(k) {
+			return nil, fmt.Errorf("invalid variable name %q", k)
+		}
+	}
+
+	host := inv.addHost(g, name)
+	for k, v := range vars {
+		host.Vars[k] = v
+	}
+	inv.addToAncestors(group, name, host)
+	return host, nil
+}
+
+// RemoveHost removes a host from the inventory and every group.
+func (inv *Inventory) RemoveHost(name string) error {
+	if _, exists := inv.Hosts[name]; !exists {
+		return fmt.Errorf("host %q does not exist", name)
+	}
+	for _, group := range inv.Groups {
+		delete(group.Hosts, name)
+	}
+	delete(inv.Hosts, name)
+	return nil
+}
+
+// RenameGroup renames a group, keeping its hosts, vars, children and place
+// under its parents. The all group cannot be renamed.
+func (inv *Inventory) RenameGroup(oldName, newName string) error {
+	group, exists := inv.Groups[oldName]
+	if !exists {
+		return fmt.Errorf("group %q does not exist", oldName)
+	}
+	if oldName == "all" {
+		return fmt.Errorf("the all group cannot be renamed")
+	}
+	if !validGroupName.MatchString(newName) {
+		return fmt.Errorf("invalid group name %q: use letters, digits and '_'", newName)
+	}
+	if _, taken := inv.Groups[newName]; taken {
+		return fmt.Errorf("group %q already exists", newName)
+	}
+
+	delete(inv.Groups, oldName)
+	group.Name = newName
+	inv.Groups[newName] = group
+	for _, parent := range inv.Groups {
+		if _, isChild := parent.Children[oldName]; isChild {
+			delete(parent.Children, oldName)
+			parent.Children[newName] = group
+		}
+	}
+	return nil
+}
+
+// SetGroupVar sets a variable on an existing group.
+func (inv *Inventory) SetGroupVar(group, key string, value any) error {
+	g, exists := inv.Groups[group]
+	if !exists {
+		return fmt.Errorf("group %q does not exist", group)
+	}
+	if !validVarName.MatchString(key) {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	g.Vars[key] = value
+	return nil
+}
+
+// SetHostVar sets a variable on an existing host.
+func (inv *Inventory) SetHostVar(host, key string, value any) error {
+	h, exists := inv.Hosts[host]
+	if !exists {
+		return fmt.Errorf("host %q does not exist", host)
+	}
+	if !validVarName.MatchString(key) {
+		return fmt.Errorf("invalid variable name %q", key)
+	}
+	h.Vars[key] = value
+	return nil
+}
+
+// addToAncestors adds host to every group above group, as ParseYAMLFile's
+// populateParentHosts would.
+func (inv *Inventory) addToAncestors(group, hostName string, host *Host) {
+	for _, parent := range inv.Groups {
+		if _, isChild := parent.Children[group]; isChild {
+			if _, has := parent.Hosts[hostName]; !has {
+				parent.Hosts[hostName] = host
+				inv.addToAncestors(parent.Name, hostName, host)
+			}
+		}
+	}
+}
+
+// isAncestor reports whether group a is above group b.
+func (inv *Inventory) isAncestor(a, b string) bool {
+	g, exists := inv.Groups[a]
+	if !exists {
+		return false
+	}
+	for childName := range g.Children {
+		if childName == b || inv.isAncestor(childName, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the hosts an Ansible host pattern (as given to --limit or a
+// play's hosts:) selects, sorted by name. The pattern is a list of terms
+// separated by ':' or ','; a term is one of
+//
+//	all, *          every host
+//	web             a group or a host
+//	web*, db-[ab]?  a glob over group and host names
+//	~web\d+         a regular expression over group and host names
+//	web[0], web[-1], web[1:3], web[2:]
+//	                hosts of a term by position (in name order, slices inclusive)
+//
+// Plain terms are unioned, then each &term intersects the result and each
+// !term removes its hosts, whatever their order in the pattern, so
+// "prod:&web:!canary" is the web hosts in prod that are not in canary. Terms
+// that match nothing select nothing.
+func (inv *Inventory) Match(pattern string) []*Host {
+	var union, intersect, exclude []string
+	for _, term := range splitPattern(pattern) {
+		switch {
+		case strings.HasPrefix(term, "&"):
+			intersect = append(intersect, term[1:])
+		case strings.HasPrefix(term, "!"):
+			exclude = append(exclude, term[1:])
+		default:
+			union = append(union, term)
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, term := range union {
+		for _, hostName := range inv.matchTerm(term) {
+			selected[hostName] = true
+		}
+	}
+	for _, term := range intersect {
+		keep := make(map[string]bool)
+		for _, hostName := range inv.matchTerm(term) {
+			keep[hostName] = true
+		}
+		for hostName := range selected {
+			if !keep[hostName] {
+				delete(selected, hostName)
+			}
+		}
+	}
+	for _, term := range exclude {
+		for _, hostName := range inv.matchTerm(term) {
+			delete(selected, hostName)
+		}
+	}
+
+	hosts := make([]*Host, 0, len(selected))
+	for _, hostName := range sortedKeys(selected) {
+		hosts = append(hosts, inv.Hosts[hostName])
+	}
+	return hosts
+}
+
+// Limit returns a copy of inv holding only the hosts pattern matches (see
+// Match), in the same groups, the way ansible's --limit narrows a run.
+// Groups and their variables are kept even when none of their hosts are.
+func (inv *Inventory) Limit(pattern string) *Inventory {
+	keep := make(map[string]bool)
+	for _, host := range inv.Match(pattern) {
+		keep[host.Name] = true
+	}
+
+	limited := NewInventory()
+	for name, group := range inv.Groups {
+		g := limited.Group(name)
+		for k, v := range group.Vars {
+			g.Vars[k] = v
+		}
+		for childName := range group.Children {
+			g.Children[childName] = limited.Group(childName)
+		}
+		for hostName, host := range group.Hosts {
+			if keep[hostName] {
+				g.Hosts[hostName] = host
+				limited.Hosts[hostName] = host
+			}
+		}
+	}
+	return limited
+}
+
+// splitPattern splits a host pattern into its terms: on ',' if it has any,
+// otherwise on ':' outside of [] subscripts.
+func splitPattern(pattern string) []string {
+	sep := ':'
+	if strings.Contains(pattern, ",") {
+		sep = ','
+	}
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range pattern + string(sep) {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case r == sep && depth == 0:
+			if term := strings.TrimSpace(pattern[start:i]); term != "" {
+				terms = append(terms, term)
+			}
+			start = i + 1
+		}
+	}
+	return terms
+}
+
+// subscriptRe splits "web[1:3]" into the term and its subscript.
+var subscriptRe = regexp.MustCompile(`^(.+)\[(-?\d*)(?::(-?\d*))?\]$`)
+
+// matchTerm returns the names of the hosts one pattern term selects, sorted.
+func (inv *Inventory) matchTerm(term string) []string {
+	if m := subscriptRe.FindStringSubmatch(term); m != nil && !strings.HasPrefix(term, "~") {
+		return subscript(inv.matchTerm(m[1]), m[2], m[3], strings.Contains(term[len(m[1]):], ":"))
+	}
+
+	selected := make(map[string]bool)
+	add := func(name string) {
+		if group, exists := inv.Groups[name]; exists {
+			for hostName := range group.Hosts {
+				selected[hostName] = true
+			}
+		}
+		if _, exists := inv.Hosts[name]; exists {
+			selected[name] = true
+		}
+	}
+
+	switch {
+	case term == "all" || term == "*":
+		for hostName := range inv.Hosts {
+			selected[hostName] = true
+		}
+	case strings.HasPrefix(term, "~"):
+		re, err := regexp.Compile(term[1:])
+		if err != nil {
+			return nil
+		}
+		for _, name := range inv.names() {
+			if re.MatchString(name) {
+				add(name)
+			}
+		}
+	case strings.ContainsAny(term, "*?["):
+		for _, name := range inv.names() {
+			if ok, _ := path.Match(term, name); ok {
+				add(name)
+			}
+		}
+	default:
+		add(term)
+	}
+	return sortedKeys(selected)
+}
+
+// names returns every group and host name in the inventory.
+func (inv *Inventory) names() []string {
+	names := make([]string, 0, len(inv.Groups)+len(inv.Hosts))
+	for name := range inv.Groups {
+		names = append(names, name)
+	}
+	for name := range inv.Hosts {
+		names = append(names, name)
+	}
+	return names
+}
+
+// subscript applies [from] or, if isSlice, [from:to] to hosts the way
+// ansible does: negative indices count from the end and slices include to.
+func subscript(hosts []string, from, to string, isSlice bool) []string {
+	index := func(s string, missing int) (int, bool) {
+		if s == "" {
+			return missing, true
+		}
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, false
+		}
+		if i < 0 {
+			i += len(hosts)
+		}
+		return i, true
+	}
+
+	start, ok := index(from, 0)
+	if !ok {
+		return nil
+	}
+	if !isSlice {
+		if start < 0 || start >= len(hosts) {
+			return nil
+		}
+		return hosts[start : start+1]
+	}
+	end, ok := index(to, len(hosts)-1)
+	if !ok {
+		return nil
+	}
+	start, end = max(start, 0), min(end, len(hosts)-1)
+	if start > end {
+		return nil
+	}
+	return hosts[start : end+1]
+}
+
+// MergeConflict is a variable two merged inventories set to different values.
+type MergeConflict struct {
+	Kind   string // "host" or "group"
+	Name   string // the host or group
+	Var    string
+	Values []any // in the order of the inventories that set them
+}
+
+// MergeError reports every conflict Merge found.
+type MergeError struct {
+	Conflicts []MergeConflict
+}
+
+func (e *MergeError) Error() string {
+	lines := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		lines = append(lines, fmt.Sprintf("%s %s: %s is %v", c.Kind, c.Name, c.Var, c.Values))
+	}
+	return fmt.Sprintf("%d conflicting variable(s):\n  %s", len(e.Conflicts), strings.Join(lines, "\n  "))
+}
+
+// Merge combines inventories, such as per-team files, into a new one: the
+// union of their hosts, groups, group children and variables. A host or
+// group may appear in several of them; if they disagree on one of its
+// variables the first inventory's value is kept and the disagreement is
+// reported in a *MergeError, returned alongside the merged inventory so
+// callers can decide whether it matters. The inputs are not modified.
+func Merge(inventories ...*Inventory) (*Inventory, error) {
+	merged := NewInventory()
+	conflicts := make(map[string]*MergeConflict)
+	mergeVars := func(kind, name string, dst, src map[string]any) {
+		for k, v := range src {
+			prev, set := dst[k]
+			if !set {
+				dst[k] = v
+				continue
+			}
+			if reflect.DeepEqual(prev, v) {
+				continue
+			}
+			key := kind + "\x00" + name + "\x00" + k
+			if conflicts[key] == nil {
+				conflicts[key] = &MergeConflict{Kind: kind, Name: name, Var: k, Values: []any{prev}}
+			}
+			conflicts[key].Values = append(conflicts[key].Values, v)
+		}
+	}
+
+	for _, inv := range inventories {
+		for _, hostName := range sortedKeys(inv.Hosts) {
+			host, exists := merged.Hosts[hostName]
+			if !exists {
+				host = &Host{Name: hostName, Vars: make(map[string]any)}
+				merged.Hosts[hostName] = host
+			}
+			mergeVars("host", hostName, host.Vars, inv.Hosts[hostName].Vars)
+		}
+		for _, groupName := range sortedKeys(inv.Groups) {
+			src, dst := inv.Groups[groupName], merged.Group(groupName)
+			mergeVars("group", groupName, dst.Vars, src.Vars)
+			for hostName := range src.Hosts {
+				merged.addHost(dst, hostName)
+			}
+			for childName := range src.Children {
+				dst.Children[childName] = merged.Group(childName)
+			}
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return merged, nil
+	}
+	err := &MergeError{}
+	for _, key := range sortedKeys(conflicts) {
+		err.Conflicts = append(err.Conflicts, *conflicts[key])
+	}
+	return merged, err
+}
+
+// HostDiff is how one host differs between two inventories.
+type HostDiff struct {
+	Host string `json:"host"`
+	// Added and Removed report a host only in the new or only in the old
+	// inventory; its groups and variables are then all joined or all left.
+	Added   bool `json:"added,omitempty"`
+	Removed bool `json:"removed,omitempty"`
+	// JoinedGroups and LeftGroups are the groups besides all the host is
+	// in, directly or through a child group, only in new or only in old.
+	JoinedGroups []string    `json:"joined_groups,omitempty"`
+	LeftGroups   []string    `json:"left_groups,omitempty"`
+	Vars         []VarChange `json:"vars,omitempty"`
+}
+
+// VarChange is a resolved variable with different values in the two
+// inventories. Old or New is nil when the variable is unset there.
+type VarChange struct {
+	Name string `json:"name"`
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// Diff compares two inventories host by host, as ansible would see them:
+// group memberships and resolved variables (see GetResolvedVariablesForHost),
+// so moving a variable from a host to its group is not a change. Hosts that
+// are the same are left out; the rest are sorted by name.
+func Diff(old, new *Inventory) ([]HostDiff, error) {
+	names := make(map[string]bool)
+	for name := range old.Hosts {
+		names[name] = true
+	}
+	for name := range new.Hosts {
+		names[name] = true
+	}
+
+	var diffs []HostDiff
+	for _, name := range sortedKeys(names) {
+		oldGroups, oldVars, err := old.hostView(name)
+		if err != nil {
+			return nil, err
+		}
+		newGroups, newVars, err := new.hostView(name)
+		if err != nil {
+			return nil, err
+		}
+
+		d := HostDiff{Host: name, Added: old.Hosts[name] == nil, Removed: new.Hosts[name] == nil}
+		for group := range newGroups {
+			if !oldGroups[group] {
+				d.JoinedGroups = append(d.JoinedGroups, group)
+			}
+		}
+		for group := range oldGroups {
+			if !newGroups[group] {
+				d.LeftGroups = append(d.LeftGroups, group)
+			}
+		}
+		sort.Strings(d.JoinedGroups)
+		sort.Strings(d.LeftGroups)
+		keys := make(map[string]bool)
+		for k := range oldVars {
+			keys[k] = true
+		}
+		for k := range newVars {
+			keys[k] = true
+		}
+		for _, k := range sortedKeys(keys) {
+			if !reflect.DeepEqual(oldVars[k], newVars[k]) {
+				d.Vars = append(d.Vars, VarChange{Name: k, Old: oldVars[k], New: newVars[k]})
+			}
+		}
+
+		if d.Added || d.Removed || len(d.JoinedGroups)+len(d.LeftGroups)+len(d.Vars) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, nil
+}
+
+// hostView returns the groups besides all a host is in and its resolved
+// variables, both empty if the host is not in inv.
+func (inv *Inventory) hostView(hostName string) (map[string]bool, map[string]any, error) {
+	groups := make(map[string]bool)
+	if _, exists := inv.Hosts[hostName]; !exists {
+		return groups, nil, nil
+	}
+	for name, group := range inv.Groups {
+		if _, member := group.Hosts[hostName]; member && name != "all" {
+			groups[name] = true
+		}
+	}
+	vars, err := inv.GetResolvedVariablesForHost(hostName)
+	return groups, vars, err
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HashBehaviour is how a dictionary variable set at more than one level of
+// the precedence order is combined, as ansible's hash_behaviour setting.
+type HashBehaviour int
+
+const (
+	// HashReplace lets the higher level's dictionary replace the lower one
+	// whole. It is ansible's default.
+	HashReplace HashBehaviour = iota
+	// HashMerge merges dictionaries key by key, recursively; other values,
+	// lists included, are still replaced.
+	HashMerge
+)
+
+// ResolveOptions controls how ResolveHost combines variables.
+type ResolveOptions struct {
+	HashBehaviour HashBehaviour
+}
+
+// ResolvedVar is a resolved variable and where it came from.
+type ResolvedVar struct {
+	Value any `json:"value"`
+	// Source is the group that set the final value, or "" if the host did.
+	Source string `json:"source"`
+	// Overrides lists the groups, lowest precedence first, whose value for
+	// the variable the final one replaced (HashReplace) or was merged over
+	// (HashMerge).
+	Overrides []string `json:"overrides"`
+}
+
+// ResolveHost returns the variables ansible would see for a host, with their
+// provenance. Precedence, lowest first:
+//
+//  1. the all group
+//  2. every other group the host is in, directly or through a child group,
+//     parents before children: ordered by depth below all, then by
+//     ansible_group_priority, then by name
+//  3. the host itself
+//
+// Each level overrides the ones before it key by key; opts decides whether a
+// dictionary overrides a dictionary whole or is merged into it.
+func (inv *Inventory) ResolveHost(hostName string, opts ResolveOptions) (map[string]ResolvedVar, error) {
+	host, exists := inv.Hosts[hostName]
+	if !exists {
+		return nil, fmt.Errorf("host %q is not in the inventory", hostName)
+	}
+
+	var groups []*Group
+	for _, group := range inv.Groups {
+		if _, member := group.Hosts[hostName]; member && group.Name != "all" {
+			groups = append(groups, group)
+		}
+	}
+	depths := inv.groupDepths()
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if depths[a.Name] != depths[b.Name] {
+			return depths[a.Name] < depths[b.Name]
+		}
+		if groupPriority(a) != groupPriority(b) {
+			return groupPriority(a) < groupPriority(b)
+		}
+		return a.Name < b.Name
+	})
+	if all, exists := inv.Groups["all"]; exists {
+		groups = append([]*Group{all}, groups...)
+	}
+
+	resolved := make(map[string]ResolvedVar)
+	apply := func(source string, vars map[string]any) {
+		for k, v := range vars {
+			prev, set := resolved[k]
+			if !set {
+				resolved[k] = ResolvedVar{Value: v, Source: source}
+				continue
+			}
+			if opts.HashBehaviour == HashMerge {
+				v = mergeValues(prev.Value, v)
+			}
+			resolved[k] = ResolvedVar{Value: v, Source: source, Overrides: append(prev.Overrides, prev.Source)}
+		}
+	}
+	for _, group := range groups {
+		apply(group.Name, group.Vars)
+	}
+	apply("", host.Vars)
+	return resolved, nil
+}
+
+// GetResolvedVariablesForHost returns the values ResolveHost resolves with
+// ansible's default options.
+func (inv *Inventory) GetResolvedVariablesForHost(hostName string) (map[string]any, error) {
+	resolved, err := inv.ResolveHost(hostName, ResolveOptions{})
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any, len(resolved))
+	for k, rv := range resolved {
+		values[k] = rv.Value
+	}
+	return values, nil
+}
+
+// groupPriority is a group's ansible_group_priority, 1 if unset like ansible.
+func groupPriority(group *Group) int {
+	if priority, ok := group.Vars["ansible_group_priority"].(int); ok {
+		return priority
+	}
+	return 1
+}
+
+// mergeValues merges override into base when both are dictionaries, without
+// changing either; otherwise override wins.
+func mergeValues(base, override any) any {
+	baseMap, ok1 := base.(map[string]any)
+	overrideMap, ok2 := override.(map[string]any)
+	if !ok1 || !ok2 {
+		return override
+	}
+	merged := make(map[string]any, len(baseMap)+len(overrideMap))
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+	for k, v := range overrideMap {
+		if prev, exists := merged[k]; exists {
+			v = mergeValues(prev, v)
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// groupDepths returns how far below all each group is: all is 0, a group
+// with no parent 1, and any other group one more than its deepest parent.
+func (inv *Inventory) groupDepths() map[string]int {
+	parents := make(map[string][]string)
+	for _, group := range inv.Groups {
+		for childName := range group.Children {
+			parents[childName] = append(parents[childName], group.Name)
+		}
+	}
+
+	depths := map[string]int{"all": 0}
+	var depth func(name string, seen map[string]bool) int
+	depth = func(name string, seen map[string]bool) int {
+		if d, done := depths[name]; done {
+			return d
+		}
+		if seen[name] {
+			return 1 // a cycle; ansible rejects these, so any answer will do
+		}
+		seen[name] = true
+		d := 1
+		for _, parent := range parents[name] {
+			if pd := depth(parent, seen) + 1; pd > d {
+				d = pd
+			}
+		}
+		depths[name] = d
+		return d
+	}
+	for name := range inv.Groups {
+		depth(name, make(map[string]bool))
+	}
+	return depths
+}
+
+// TableFormat is how WriteTable lays out its rows.
+type TableFormat int
+
+const (
+	// TableText is columns aligned with spaces, for reading.
+	TableText TableFormat = iota
+	// TableCSV is comma-separated values, for spreadsheets.
+	TableCSV
+)
+
+// WriteTable writes a flat table for audits: a header, then one row per host
+// (every host if hosts is nil) with its name, the groups it is in besides
+// all, and its resolved value (see GetResolvedVariablesForHost) of each of
+// vars. Rows are sorted by host name; dictionaries and lists are written as
+// JSON and unset variables as empty cells.
+func (inv *Inventory) WriteTable(w io.Writer, hosts []*Host, vars []string, format TableFormat) error {
+	hosts = inv.sortedHosts(hosts)
+	rows := [][]string{append([]string{"host", "groups"}, vars...)}
+	for _, host := range hosts {
+		resolved, err := inv.GetResolvedVariablesForHost(host.Name)
+		if err != nil {
+			return err
+		}
+		var groups []string
+		for _, groupName := range sortedKeys(inv.Groups) {
+			if _, member := inv.Groups[groupName].Hosts[host.Name]; member && groupName != "all" {
+				groups = append(groups, groupName)
+			}
+		}
+		row := []string{host.Name, strings.Join(groups, ",")}
+		for _, v := range vars {
+			row = append(row, tableCell(resolved[v]))
+		}
+		rows = append(rows, row)
+	}
+
+	if format == TableCSV {
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(rows); err != nil {
+			return fmt.Errorf("could not write CSV: %w", err)
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableCell formats one resolved variable for WriteTable.
+func tableCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]any, []any:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// sortedHosts returns hosts sorted by name, or every host if hosts is nil.
+func (inv *Inventory) sortedHosts(hosts []*Host) []*Host {
+	if hosts == nil {
+		for _, hostName := range sortedKeys(inv.Hosts) {
+			hosts = append(hosts, inv.Hosts[hostName])
+		}
+		return hosts
+	}
+	hosts = append([]*Host(nil), hosts...)
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	return hosts
+}
+
+// sshOptions maps the ssh_config options WriteSSHConfig sets to the
+// connection variables ansible reads them from, in the order they are written.
+var sshOptions = []struct {
+	option, variable string
+}{
+	{"HostName", "ansible_host"},
+	{"User", "ansible_user"},
+	{"Port", "ansible_port"},
+	{"IdentityFile", "ansible_ssh_private_key_file"},
+}
+
+// WriteSSHConfig writes a block for ~/.ssh/config with a Host entry per host
+// (every host if hosts is nil), sorted by name: the inventory name as the
+// alias, then HostName, User, Port and IdentityFile from the host's resolved
+// ansible_host, ansible_user, ansible_port and ansible_ssh_private_key_file.
+// Options whose variable is unset are left out, so ssh's defaults apply.
+func (inv *Inventory) WriteSSHConfig(w io.Writer, hosts []*Host) error {
+	for i, host := range inv.sortedHosts(hosts) {
+		resolved, err := inv.GetResolvedVariablesForHost(host.Name)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Host %s\n", host.Name)
+		for _, opt := range sshOptions {
+			value := tableCell(resolved[opt.variable])
+			if value == "" {
+				continue
+			}
+			if strings.ContainsAny(value, " \t") {
+				value = strconv.Quote(value)
+			}
+			if _, err := fmt.Fprintf(w, "    %s %s\n", opt.option, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteEtcHosts writes an /etc/hosts snippet: a line with the resolved
+// ansible_host and the inventory name of each host (every host if hosts is
+// nil), sorted by name. /etc/hosts only maps addresses, so a host whose
+// ansible_host is unset or a DNS name gets a comment saying so instead.
+func (inv *Inventory) WriteEtcHosts(w io.Writer, hosts []*Host) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	for _, host := range inv.sortedHosts(hosts) {
+		resolved, err := inv.GetResolvedVariablesForHost(host.Name)
+		if err != nil {
+			return err
+		}
+		addr := tableCell(resolved["ansible_host"])
+		switch {
+		case addr == "":
+			fmt.Fprintf(tw, "# %s: no ansible_host\n", host.Name)
+		case net.ParseIP(addr) == nil:
+			fmt.Fprintf(tw, "# %s: ansible_host %s is not an address\n", host.Name, addr)
+		default:
+			fmt.Fprintf(tw, "%s\t%s\n", addr, host.Name)
+		}
+	}
+	return tw.Flush()
+}
+
+// Severity is how serious a Finding is.
+type Severity string
+
+const (
+	// SeverityWarning is something that works but is probably a mistake.
+	SeverityWarning Severity = "warning"
+	// SeverityError is something ansible will do wrong.
+	SeverityError Severity = "error"
+)
+
+// Finding is one problem a lint Rule found.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// Host or Group, if set, is what the finding is about.
+	Host    string `json:"host,omitempty"`
+	Group   string `json:"group,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Rule, f.Message)
+}
+
+// Rule is a check Lint runs. Check returns a Finding for each problem; Lint
+// fills in the rule's Name and Severity where the finding leaves them empty,
+// so a custom rule only has to say what is wrong.
+type Rule struct {
+	Name     string
+	Severity Severity
+	Check    func(inv *Inventory) []Finding
+}
+
+// DefaultEnvironments are the environment groups inventories are generated
+// with. canary is not one: canary hosts are usually in prod as well.
+var DefaultEnvironments = []string{"dev", "qa", "staging", "uat", "oat", "prod"}
+
+// DefaultRules are the rules Lint runs when it is given none:
+// MissingAnsibleHost, EmptyGroups, ShadowedVars and
+// ExclusiveGroups(DefaultEnvironments...).
+func DefaultRules() []Rule {
+	return []Rule{MissingAnsibleHost, EmptyGroups, ShadowedVars, ExclusiveGroups(DefaultEnvironments...)}
+}
+
+// Lint checks inv against rules, or DefaultRules() if there are none, and
+// returns the findings in the order of the rules. Add to the built-in rules
+// with Lint(inv, append(DefaultRules(), custom)...).
+func Lint(inv *Inventory, rules ...Rule) []Finding {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	var findings []Finding
+	for _, rule := range rules {
+		for _, f := range rule.Check(inv) {
+			if f.Rule == "" {
+				f.Rule = rule.Name
+			}
+			if f.Severity == "" {
+				f.Severity = rule.Severity
+			}
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// MissingAnsibleHost warns about hosts with no ansible_host, which ansible
+// connects to by their inventory name.
+var MissingAnsibleHost = Rule{
+	Name:     "missing-ansible-host",
+	Severity: SeverityWarning,
+	Check: func(inv *Inventory) []Finding {
+		var findings []Finding
+		for _, host := range inv.sortedHosts(nil) {
+			resolved, err := inv.GetResolvedVariablesForHost(host.Name)
+			if err == nil && tableCell(resolved["ansible_host"]) == "" {
+				findings = append(findings, Finding{Host: host.Name,
+					Message: fmt.Sprintf("host %s has no ansible_host: ansible will connect to %q", host.Name, host.Name)})
+			}
+		}
+		return findings
+	},
+}
+
+// EmptyGroups warns about groups with no hosts, directly or through their
+// children: plays that target them do nothing.
+var EmptyGroups = Rule{
+	Name:     "empty-group",
+	Severity: SeverityWarning,
+	Check: func(inv *Inventory) []Finding {
+		var findings []Finding
+		for _, name := range sortedKeys(inv.Groups) {
+			if name != "all" && name != "ungrouped" && len(inv.Groups[name].Hosts) == 0 {
+				findings = append(findings, Finding{Group: name,
+					Message: fmt.Sprintf("group %s has no hosts", name)})
+			}
+		}
+		return findings
+	},
+}
+
+// ShadowedVars warns about group variables no host ends up with, because
+// every host of the group gets the variable from a child group, a group of
+// higher precedence or its own vars instead: changing them changes nothing.
+var ShadowedVars = Rule{
+	Name:     "shadowed-var",
+	Severity: SeverityWarning,
+	Check: func(inv *Inventory) []Finding {
+		resolved := make(map[string]map[string]ResolvedVar)
+		for name := range inv.Hosts {
+			resolved[name], _ = inv.ResolveHost(name, ResolveOptions{})
+		}
+		var findings []Finding
+		for _, name := range sortedKeys(inv.Groups) {
+			group := inv.Groups[name]
+			if len(group.Hosts) == 0 {
+				continue // EmptyGroups
+			}
+			for _, key := range sortedKeys(group.Vars) {
+				if key == "ansible_group_priority" {
+					continue // read from the group itself, never resolved
+				}
+				winners := make(map[string]bool)
+				for hostName := range group.Hosts {
+					source := resolved[hostName][key].Source
+					if source == "" {
+						source = "host vars"
+					}
+					winners[source] = true
+				}
+				if !winners[name] {
+					findings = append(findings, Finding{Group: name,
+						Message: fmt.Sprintf("group %s sets %s, but all its hosts get it from %s",
+							name, key, strings.Join(sortedKeys(winners), ", "))})
+				}
+			}
+		}
+		return findings
+	},
+}
+
+// ExclusiveGroups returns a rule that fails hosts in more than one of groups,
+// such as environments: a host in both staging and prod gets whichever of
+// their variables wins, and runs the plays of both. Groups that are not in
+// the inventory are ignored.
+func ExclusiveGroups(groups ...string) Rule {
+	return Rule{
+		Name:     "exclusive-groups",
+		Severity: SeverityError,
+		Check: func(inv *Inventory) []Finding {
+			var findings []Finding
+			for _, host := range inv.sortedHosts(nil) {
+				var in []string
+				for _, name := range groups {
+					if group, exists := inv.Groups[name]; exists {
+						if _, member := group.Hosts[host.Name]; member {
+							in = append(in, name)
+						}
+					}
+				}
+				if len(in) > 1 {
+					findings = append(findings, Finding{Host: host.Name,
+						Message: fmt.Sprintf("host %s is in %s, which are exclusive", host.Name, strings.Join(in, " and "))})
+				}
+			}
+			return findings
+		},
+	}
+}
+
 // Display prints the inventory in a human-readable format.
 func (inv *Inventory) Display() {
 	var groupNames []string
@@ -0,0 +1,658 @@
+package ansibleinv
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testInventory is prod (web and db) plus canary, which shares web3, and a
+// staging host outside prod.
+const testInventory = `all:
+  vars:
+    ntp: pool.ntp.org
+  children:
+    prod:
+      vars:
+        env: prod
+      children:
+        web:
+          vars:
+            tier: web
+          hosts:
+            web1: {}
+            web2: {}
+            web3: {}
+        db:
+          hosts:
+            db1: {}
+            db2: {}
+    canary:
+      hosts:
+        web3: {}
+    staging:
+      hosts:
+        stage-web1: {}
+`
+
+func parseTestInventory(t *testing.T, yaml string) *Inventory {
+	t.Helper()
+	inv, err := ParseBytes([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ParseBytes: %v", err)
+	}
+	return inv
+}
+
+func hostNames(hosts []*Host) []string {
+	var names []string
+	for _, host := range hosts {
+		names = append(names, host.Name)
+	}
+	return names
+}
+
+func TestMatch(t *testing.T) {
+	inv := parseTestInventory(t, testInventory)
+	everyone := []string{"db1", "db2", "stage-web1", "web1", "web2", "web3"}
+	for _, tc := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"all", everyone},
+		{"*", everyone},
+		{"web", []string{"web1", "web2", "web3"}},
+		{"web1", []string{"web1"}},
+		{"nope", nil},
+		{"", nil},
+
+		// globs and regular expressions match group and host names
+		{"web*", []string{"web1", "web2", "web3"}},
+		{"*web1", []string{"stage-web1", "web1"}},
+		{"db?", []string{"db1", "db2"}},
+		{"db[2-9]", []string{"db2"}},
+		{`~^db\d$`, []string{"db1", "db2"}},
+		{"~^(web|db)$", []string{"db1", "db2", "web1", "web2", "web3"}},
+		{"~stage", []string{"stage-web1"}},
+		{"~[", nil},
+
+		// unions, intersections and exclusions, in any order
+		{"web:db", []string{"db1", "db2", "web1", "web2", "web3"}},
+		{"web,db", []string{"db1", "db2", "web1", "web2", "web3"}},
+		{"prod:staging", everyone},
+		{"prod:&web", []string{"web1", "web2", "web3"}},
+		{"prod:&web:!canary", []string{"web1", "web2"}},
+		{"!canary:&web:prod", []string{"web1", "web2"}},
+		{"prod,&web,!canary", []string{"web1", "web2"}},
+		{"all:!prod", []string{"stage-web1"}},
+		{"prod:&canary:&web", []string{"web3"}},
+		{"prod:&staging", nil},
+		{"web:!nope", []string{"web1", "web2", "web3"}},
+		{"!web", nil},
+
+		// subscripts, in name order, slices inclusive
+		{"web[0]", []string{"web1"}},
+		{"web[-1]", []string{"web3"}},
+		{"web[1:2]", []string{"web2", "web3"}},
+		{"web[1:]", []string{"web2", "web3"}},
+		{"web[:1]", []string{"web1", "web2"}},
+		{"web[-2:]", []string{"web2", "web3"}},
+		{"web[3]", nil},
+		{"web[0]:db[-1]", []string{"db2", "web1"}},
+		{"web[0],db[-1]", []string{"db2", "web1"}},
+		{"prod[0:1]:!db1", []string{"db2"}},
+		{"web*[0]", []string{"web1"}},
+		{"all:!web[1:]", []string{"db1", "db2", "stage-web1", "web1"}},
+	} {
+		if got := hostNames(inv.Match(tc.pattern)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Match(%q) = %v, want %v", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	inv := parseTestInventory(t, testInventory)
+	limited := inv.Limit("prod:&web:!canary")
+
+	if got := sortedKeys(limited.Hosts); !reflect.DeepEqual(got, []string{"web1", "web2"}) {
+		t.Errorf("hosts = %v, want [web1 web2]", got)
+	}
+	if got := sortedKeys(limited.Groups); !reflect.DeepEqual(got, sortedKeys(inv.Groups)) {
+		t.Errorf("groups = %v, want all of %v", got, sortedKeys(inv.Groups))
+	}
+	for group, want := range map[string][]string{
+		"all":     {"web1", "web2"},
+		"prod":    {"web1", "web2"},
+		"web":     {"web1", "web2"},
+		"db":      {},
+		"canary":  {},
+		"staging": {},
+	} {
+		if got := sortedKeys(limited.Groups[group].Hosts); !reflect.DeepEqual(got, want) {
+			t.Errorf("group %s hosts = %v, want %v", group, got, want)
+		}
+	}
+	if got := limited.Groups["web"].Vars["tier"]; got != "web" {
+		t.Errorf("web's tier = %v, want the var kept", got)
+	}
+	if limited.Groups["prod"].Children["web"] != limited.Groups["web"] {
+		t.Error("prod's child web is not the limited inventory's web group")
+	}
+	if len(inv.Groups["canary"].Hosts) != 1 {
+		t.Error("Limit changed the inventory it was given")
+	}
+}
+
+func TestSplitPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"", nil},
+		{"web", []string{"web"}},
+		{"web:db", []string{"web", "db"}},
+		{"web,db", []string{"web", "db"}},
+		{" web , db ", []string{"web", "db"}},
+		{"web::db:", []string{"web", "db"}},
+		{"prod:&web:!canary", []string{"prod", "&web", "!canary"}},
+		{"web[1:2]:db", []string{"web[1:2]", "db"}},
+		{"web[:2]:db[-1]", []string{"web[:2]", "db[-1]"}},
+		// with a comma, ':' is not a separator at all
+		{"web[1:2],db", []string{"web[1:2]", "db"}},
+		{"host:8080,db", []string{"host:8080", "db"}},
+	} {
+		if got := splitPattern(tc.pattern); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitPattern(%q) = %q, want %q", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestSubscript(t *testing.T) {
+	hosts := []string{"a", "b", "c", "d"}
+	for _, tc := range []struct {
+		from, to string
+		isSlice  bool
+		want     []string
+	}{
+		{"0", "", false, []string{"a"}},
+		{"3", "", false, []string{"d"}},
+		{"-1", "", false, []string{"d"}},
+		{"-4", "", false, []string{"a"}},
+		{"4", "", false, nil},
+		{"-5", "", false, nil},
+		{"x", "", false, nil},
+
+		{"1", "2", true, []string{"b", "c"}},
+		{"2", "2", true, []string{"c"}},
+		{"", "", true, hosts},
+		{"2", "", true, []string{"c", "d"}},
+		{"", "1", true, []string{"a", "b"}},
+		{"-2", "-1", true, []string{"c", "d"}},
+		{"-10", "1", true, []string{"a", "b"}},
+		{"0", "10", true, hosts},
+		{"3", "1", true, nil},
+		{"5", "", true, nil},
+		{"0", "x", true, nil},
+	} {
+		if got := subscript(hosts, tc.from, tc.to, tc.isSlice); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("subscript(%q, %q, %v) = %v, want %v", tc.from, tc.to, tc.isSlice, got, tc.want)
+		}
+	}
+	if got := subscript(nil, "0", "", false); got != nil {
+		t.Errorf("subscript of no hosts = %v, want none", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		inputs    []string
+		hosts     map[string]map[string]any // host vars of the merged inventory
+		groups    map[string][]string       // hosts of the merged groups
+		conflicts []MergeConflict
+	}{
+		{
+			name: "disjoint",
+			inputs: []string{
+				"web:\n  hosts:\n    web1: {ansible_host: 10.0.0.1}\n",
+				"db:\n  hosts:\n    db1: {}\n",
+			},
+			hosts:  map[string]map[string]any{"web1": {"ansible_host": "10.0.0.1"}, "db1": {}},
+			groups: map[string][]string{"web": {"web1"}, "db": {"db1"}},
+		},
+		{
+			name: "shared host and group",
+			inputs: []string{
+				"web:\n  vars: {port: 80}\n  hosts:\n    web1: {ansible_host: 10.0.0.1}\n",
+				"web:\n  vars: {tls: true}\n  hosts:\n    web1: {ansible_user: deploy}\n    web2: {}\n",
+			},
+			hosts:  map[string]map[string]any{"web1": {"ansible_host": "10.0.0.1", "ansible_user": "deploy"}, "web2": {}},
+			groups: map[string][]string{"web": {"web1", "web2"}},
+		},
+		{
+			name: "agreeing values",
+			inputs: []string{
+				"web:\n  vars: {ports: [80, 443]}\n  hosts:\n    web1: {ansible_host: 10.0.0.1}\n",
+				"web:\n  vars: {ports: [80, 443]}\n  hosts:\n    web1: {ansible_host: 10.0.0.1}\n",
+			},
+			hosts:  map[string]map[string]any{"web1": {"ansible_host": "10.0.0.1"}},
+			groups: map[string][]string{"web": {"web1"}},
+		},
+		{
+			name: "children",
+			inputs: []string{
+				"prod:\n  children:\n    web:\n      hosts:\n        web1: {}\n",
+				"prod:\n  children:\n    db:\n      hosts:\n        db1: {}\n",
+			},
+			hosts:  map[string]map[string]any{"web1": {}, "db1": {}},
+			groups: map[string][]string{"prod": {"db1", "web1"}, "web": {"web1"}, "db": {"db1"}},
+		},
+		{
+			name: "host conflict",
+			inputs: []string{
+				"web:\n  hosts:\n    web1: {ansible_host: 10.0.0.1}\n",
+				"web:\n  hosts:\n    web1: {ansible_host: 10.0.0.2}\n",
+			},
+			hosts:     map[string]map[string]any{"web1": {"ansible_host": "10.0.0.1"}},
+			groups:    map[string][]string{"web": {"web1"}},
+			conflicts: []MergeConflict{{Kind: "host", Name: "web1", Var: "ansible_host", Values: []any{"10.0.0.1", "10.0.0.2"}}},
+		},
+		{
+			name: "three-way group conflict",
+			inputs: []string{
+				"web:\n  vars: {port: 80}\n",
+				"web:\n  vars: {port: 8080}\n",
+				"web:\n  vars: {port: 8443}\n",
+			},
+			hosts:     map[string]map[string]any{},
+			groups:    map[string][]string{"web": {}},
+			conflicts: []MergeConflict{{Kind: "group", Name: "web", Var: "port", Values: []any{80, 8080, 8443}}},
+		},
+		{
+			name: "conflicts in order",
+			inputs: []string{
+				"web:\n  vars: {port: 80}\n  hosts:\n    web1: {user: a}\n",
+				"web:\n  vars: {port: 81}\n  hosts:\n    web1: {user: b}\n",
+			},
+			hosts:  map[string]map[string]any{"web1": {"user": "a"}},
+			groups: map[string][]string{"web": {"web1"}},
+			conflicts: []MergeConflict{
+				{Kind: "group", Name: "web", Var: "port", Values: []any{80, 81}},
+				{Kind: "host", Name: "web1", Var: "user", Values: []any{"a", "b"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var inputs []*Inventory
+			for _, yaml := range tc.inputs {
+				inputs = append(inputs, parseTestInventory(t, yaml))
+			}
+			merged, err := Merge(inputs...)
+
+			var conflicts []MergeConflict
+			if err != nil {
+				mergeErr, ok := err.(*MergeError)
+				if !ok {
+					t.Fatalf("Merge error = %T %v, want a *MergeError", err, err)
+				}
+				conflicts = mergeErr.Conflicts
+			}
+			if !reflect.DeepEqual(conflicts, tc.conflicts) {
+				t.Errorf("conflicts = %+v, want %+v", conflicts, tc.conflicts)
+			}
+			if merged == nil {
+				t.Fatal("Merge returned no inventory")
+			}
+			hosts := make(map[string]map[string]any)
+			for name, host := range merged.Hosts {
+				hosts[name] = host.Vars
+			}
+			if !reflect.DeepEqual(hosts, tc.hosts) {
+				t.Errorf("hosts = %v, want %v", hosts, tc.hosts)
+			}
+			groups := make(map[string][]string)
+			for name, group := range merged.Groups {
+				groups[name] = sortedKeys(group.Hosts)
+			}
+			if !reflect.DeepEqual(groups, tc.groups) {
+				t.Errorf("groups = %v, want %v", groups, tc.groups)
+			}
+		})
+	}
+}
+
+func TestMergeLeavesInputsAlone(t *testing.T) {
+	a := parseTestInventory(t, "web:\n  vars: {port: 80}\n  hosts:\n    web1: {user: a}\n")
+	b := parseTestInventory(t, "web:\n  vars: {tls: true}\n  hosts:\n    web1: {key: k}\n    web2: {}\n")
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged.Hosts["web1"].Vars["user"] = "changed"
+	merged.Groups["web"].Vars["port"] = 1
+
+	if got := a.Hosts["web1"].Vars; !reflect.DeepEqual(got, map[string]any{"user": "a"}) {
+		t.Errorf("first input's web1 vars = %v", got)
+	}
+	if got := a.Groups["web"].Vars; !reflect.DeepEqual(got, map[string]any{"port": 80}) {
+		t.Errorf("first input's web vars = %v", got)
+	}
+	if got := sortedKeys(a.Groups["web"].Hosts); !reflect.DeepEqual(got, []string{"web1"}) {
+		t.Errorf("first input's web hosts = %v", got)
+	}
+	if got := b.Hosts["web1"].Vars; !reflect.DeepEqual(got, map[string]any{"key": "k"}) {
+		t.Errorf("second input's web1 vars = %v", got)
+	}
+}
+
+func TestMergeErrorMessage(t *testing.T) {
+	err := &MergeError{Conflicts: []MergeConflict{
+		{Kind: "group", Name: "web", Var: "port", Values: []any{80, 81}},
+		{Kind: "host", Name: "web1", Var: "user", Values: []any{"a", "b", "c"}},
+	}}
+	want := "2 conflicting variable(s):\n  group web: port is [80 81]\n  host web1: user is [a b c]"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+// precedenceInventory has web1 in web (below prod) and in three groups at
+// the same depth as prod, one of them given a lower ansible_group_priority.
+const precedenceInventory = `all:
+  vars:
+    ntp: pool.ntp.org
+    app: {log: {level: info, file: app.log}, ports: [80], user: app}
+  children:
+    prod:
+      vars:
+        tier: prod
+        app: {log: {level: warn}, ports: [443]}
+      children:
+        web:
+          vars:
+            tier: web
+            app: {user: www}
+          hosts:
+            web1:
+              app: {log: {file: web1.log}}
+            web2: {}
+    zeta:
+      vars: {owner: zeta, ansible_group_priority: 0}
+      hosts:
+        web1: {}
+    beta:
+      vars: {owner: beta}
+      hosts:
+        web1: {}
+    alpha:
+      vars: {owner: alpha}
+      hosts:
+        web1: {}
+`
+
+func TestResolveHost(t *testing.T) {
+	inv := parseTestInventory(t, precedenceInventory)
+	for _, tc := range []struct {
+		host string
+		hash HashBehaviour
+		key  string
+		want ResolvedVar
+	}{
+		{"web1", HashReplace, "ntp", ResolvedVar{Value: "pool.ntp.org", Source: "all"}},
+		// children override parents
+		{"web1", HashReplace, "tier", ResolvedVar{Value: "web", Source: "web", Overrides: []string{"prod"}}},
+		// at one depth, by ansible_group_priority then name
+		{"web1", HashReplace, "owner", ResolvedVar{Value: "beta", Source: "beta", Overrides: []string{"zeta", "alpha"}}},
+		{"web2", HashReplace, "owner", ResolvedVar{}},
+		// the host overrides every group
+		{"web1", HashReplace, "app", ResolvedVar{
+			Value:     map[string]any{"log": map[string]any{"file": "web1.log"}},
+			Overrides: []string{"all", "prod", "web"},
+		}},
+		{"web2", HashReplace, "app", ResolvedVar{
+			Value:     map[string]any{"user": "www"},
+			Source:    "web",
+			Overrides: []string{"all", "prod"},
+		}},
+		// merging is recursive, but lists are still replaced
+		{"web1", HashMerge, "app", ResolvedVar{
+			Value:     map[string]any{"log": map[string]any{"level": "warn", "file": "web1.log"}, "ports": []any{443}, "user": "www"},
+			Overrides: []string{"all", "prod", "web"},
+		}},
+		{"web2", HashMerge, "app", ResolvedVar{
+			Value:     map[string]any{"log": map[string]any{"level": "warn", "file": "app.log"}, "ports": []any{443}, "user": "www"},
+			Source:    "web",
+			Overrides: []string{"all", "prod"},
+		}},
+		{"web1", HashMerge, "tier", ResolvedVar{Value: "web", Source: "web", Overrides: []string{"prod"}}},
+	} {
+		resolved, err := inv.ResolveHost(tc.host, ResolveOptions{HashBehaviour: tc.hash})
+		if err != nil {
+			t.Fatalf("ResolveHost(%s): %v", tc.host, err)
+		}
+		if got := resolved[tc.key]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ResolveHost(%s, %v)[%s] = %+v, want %+v", tc.host, tc.hash, tc.key, got, tc.want)
+		}
+	}
+
+	if _, err := inv.ResolveHost("nope", ResolveOptions{}); err == nil {
+		t.Error("ResolveHost of a missing host = nil error, want one")
+	}
+}
+
+func TestResolveHostLeavesVarsAlone(t *testing.T) {
+	inv := parseTestInventory(t, precedenceInventory)
+	if _, err := inv.ResolveHost("web1", ResolveOptions{HashBehaviour: HashMerge}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"log": map[string]any{"level": "info", "file": "app.log"}, "ports": []any{80}, "user": "app"}
+	if got := inv.Groups["all"].Vars["app"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("all's app after a merge = %v, want %v", got, want)
+	}
+}
+
+func TestGetResolvedVariablesForHost(t *testing.T) {
+	inv := parseTestInventory(t, precedenceInventory)
+	got, err := inv.GetResolvedVariablesForHost("web2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"ntp":  "pool.ntp.org",
+		"tier": "web",
+		"app":  map[string]any{"user": "www"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetResolvedVariablesForHost(web2) = %v, want %v", got, want)
+	}
+}
+
+func TestLint(t *testing.T) {
+	custom := Rule{
+		Name:     "no-root",
+		Severity: SeverityError,
+		Check: func(inv *Inventory) []Finding {
+			var findings []Finding
+			for _, host := range inv.sortedHosts(nil) {
+				if host.Vars["ansible_user"] == "root" {
+					findings = append(findings, Finding{Host: host.Name, Message: host.Name + " logs in as root"})
+				}
+			}
+			if _, exists := inv.Groups["legacy"]; exists {
+				findings = append(findings, Finding{Rule: "legacy", Severity: SeverityWarning, Group: "legacy", Message: "legacy group"})
+			}
+			return findings
+		},
+	}
+
+	for _, tc := range []struct {
+		name  string
+		yaml  string
+		rules []Rule
+		want  []Finding
+	}{
+		{
+			name: "clean",
+			yaml: `all:
+  vars: {ansible_user: deploy}
+  children:
+    prod:
+      vars: {ansible_host: 10.0.0.1}
+      hosts:
+        web1: {}
+        web2: {ansible_host: 10.0.0.2}
+`,
+		},
+		{
+			name: "missing ansible_host",
+			yaml: `prod:
+  hosts:
+    web2: {}
+    web1: {ansible_host: ""}
+    db1: {ansible_host: 10.0.0.3}
+`,
+			rules: []Rule{MissingAnsibleHost},
+			want: []Finding{
+				{Rule: "missing-ansible-host", Severity: SeverityWarning, Host: "web1", Message: `host web1 has no ansible_host: ansible will connect to "web1"`},
+				{Rule: "missing-ansible-host", Severity: SeverityWarning, Host: "web2", Message: `host web2 has no ansible_host: ansible will connect to "web2"`},
+			},
+		},
+		{
+			name: "empty groups",
+			yaml: `all:
+  children:
+    ungrouped: {}
+    prod:
+      children:
+        web:
+          hosts:
+            web1: {}
+        db: {}
+    retired: {}
+`,
+			rules: []Rule{EmptyGroups},
+			want: []Finding{
+				{Rule: "empty-group", Severity: SeverityWarning, Group: "db", Message: "group db has no hosts"},
+				{Rule: "empty-group", Severity: SeverityWarning, Group: "retired", Message: "group retired has no hosts"},
+			},
+		},
+		{
+			name: "shadowed vars",
+			yaml: `prod:
+  vars:
+    port: 80
+    user: deploy
+    tls: false
+    ansible_group_priority: 2
+  children:
+    web:
+      vars: {port: 8080}
+      hosts:
+        web1: {user: www}
+        web2: {user: www}
+    db:
+      vars: {port: 5432}
+      hosts:
+        db1: {}
+`,
+			rules: []Rule{ShadowedVars},
+			want: []Finding{
+				{Rule: "shadowed-var", Severity: SeverityWarning, Group: "prod", Message: "group prod sets port, but all its hosts get it from db, web"},
+			},
+		},
+		{
+			name: "shadowed in every host",
+			yaml: `prod:
+  vars: {port: 80}
+  hosts:
+    web1: {port: 8080}
+    web2: {port: 8081}
+`,
+			rules: []Rule{ShadowedVars},
+			want: []Finding{
+				{Rule: "shadowed-var", Severity: SeverityWarning, Group: "prod", Message: "group prod sets port, but all its hosts get it from host vars"},
+			},
+		},
+		{
+			name: "exclusive environments",
+			yaml: `all:
+  children:
+    staging:
+      hosts:
+        web1: {}
+        web2: {}
+    prod:
+      hosts:
+        web2: {}
+        web3: {}
+    canary:
+      hosts:
+        web3: {}
+`,
+			rules: []Rule{ExclusiveGroups(DefaultEnvironments...)},
+			want: []Finding{
+				{Rule: "exclusive-groups", Severity: SeverityError, Host: "web2", Message: "host web2 is in staging and prod, which are exclusive"},
+			},
+		},
+		{
+			name: "exclusive custom groups",
+			yaml: `all:
+  children:
+    prod:
+      hosts:
+        web3: {}
+    canary:
+      hosts:
+        web3: {}
+`,
+			rules: []Rule{ExclusiveGroups("canary", "prod", "missing")},
+			want: []Finding{
+				{Rule: "exclusive-groups", Severity: SeverityError, Host: "web3", Message: "host web3 is in canary and prod, which are exclusive"},
+			},
+		},
+		{
+			name: "default rules in order",
+			yaml: `all:
+  children:
+    staging:
+      hosts:
+        web1: {ansible_host: 10.0.0.1}
+    prod:
+      vars: {ansible_host: 10.0.0.2}
+      hosts:
+        web1: {}
+    retired: {}
+`,
+			want: []Finding{
+				{Rule: "empty-group", Severity: SeverityWarning, Group: "retired", Message: "group retired has no hosts"},
+				{Rule: "shadowed-var", Severity: SeverityWarning, Group: "prod", Message: "group prod sets ansible_host, but all its hosts get it from host vars"},
+				{Rule: "exclusive-groups", Severity: SeverityError, Host: "web1", Message: "host web1 is in staging and prod, which are exclusive"},
+			},
+		},
+		{
+			name: "custom rule",
+			yaml: `legacy:
+  hosts:
+    old1: {ansible_host: 10.0.0.9, ansible_user: root}
+`,
+			rules: append(DefaultRules(), custom),
+			want: []Finding{
+				{Rule: "no-root", Severity: SeverityError, Host: "old1", Message: "old1 logs in as root"},
+				{Rule: "legacy", Severity: SeverityWarning, Group: "legacy", Message: "legacy group"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Lint(parseTestInventory(t, tc.yaml), tc.rules...)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Lint =\n%v\nwant\n%v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	f := Finding{Rule: "empty-group", Severity: SeverityWarning, Group: "db", Message: "group db has no hosts"}
+	if got, want := f.String(), "warning: empty-group: group db has no hosts"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
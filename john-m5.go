@@ -4,7 +4,12 @@ module yourcorp/topology
 
 go 1.22
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	github.com/charmbracelet/bubbles v0.18.0
+	github.com/charmbracelet/bubbletea v0.26.6
+	github.com/charmbracelet/lipgloss v0.11.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 // END FILE: go.mod
 
@@ -15,7 +20,12 @@ require gopkg.in/yaml.v3 v3.0.1
 // updated to support the final blueprint model.
 package topology
 
-import "gopkg.in/yaml.v3"
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 // YAMLTopology is the top-level structure for unmarshaling the topology.yaml file.
 type YAMLTopology struct {
@@ -23,6 +33,16 @@ type YAMLTopology struct {
 	Shards     map[string]int         `yaml:"shards"`
 	Blueprints map[string]Blueprint   `yaml:"blueprints"`
 	Apps       map[string]AppDefinition `yaml:"apps"`
+	Hooks      []LayerHook              `yaml:"hooks"`
+}
+
+// LayerHook is a global step run between two layers of an executed plan,
+// such as a runbook's "pause market data replay" or "notify NOC" phase.
+type LayerHook struct {
+	Name  string   `yaml:"name"`
+	Run   string   `yaml:"run"`   // shell command; see the orchestrator's -exec
+	After []int    `yaml:"after"` // layers (from 1) it follows; empty means every one but the last
+	Modes []string `yaml:"modes"` // startup, shutdown or restart; empty means all of them
 }
 
 // Blueprint defines a reusable template of co-located applications.
@@ -35,14 +55,26 @@ type BlueprintAppDefinition struct {
 	DependsOn              []string `yaml:"depends_on"`
 	ExternalDependsOn      []string `yaml:"external_depends_on"`
 	ExternalDependsOnAllOf []string `yaml:"external_depends_on_all_of"`
+	Owner                  string   `yaml:"owner"` // overrides the owner of the app using the blueprint
 }
 
 // AppDefinition defines a top-level, instantiable application.
 type AppDefinition struct {
-	DependsOn      []string            `yaml:"depends_on"`
-	DependsOnAllOf []string            `yaml:"depends_on_all_of"`
+	DependsOn      Dependencies        `yaml:"depends_on"`
+	DependsOnAllOf Dependencies        `yaml:"depends_on_all_of"`
 	SameHostAs     StringOrStringSlice `yaml:"same_host_as"`
-	Uses           []BlueprintInstance `yaml:"uses"`
+	// PinToShard makes a singleton app live with one shard of its sharded
+	// same_host_as group (e.g. an admin console with shard 0) instead of
+	// being sharded along with it.
+	PinToShard *int                `yaml:"pin_to_shard"`
+	Uses       []BlueprintInstance `yaml:"uses"`
+	// Owner is the team to call about the app, e.g. when its layer of a
+	// plan stalls; its blueprint components have the same one.
+	Owner string `yaml:"owner"`
+	// Frozen keeps plans from touching the app, and its blueprint
+	// components, without -override-freeze; FreezeReason says why.
+	Frozen       bool   `yaml:"frozen"`
+	FreezeReason string `yaml:"freeze_reason"`
 }
 
 // BlueprintInstance defines how a top-level app uses a blueprint.
@@ -52,6 +84,47 @@ type BlueprintInstance struct {
 	With      map[string]string `yaml:"with"`
 }
 
+// Dependency is an entry of depends_on or depends_on_all_of: the app
+// depended on and, for one that needs warming up after its health check
+// passes, how long to wait before starting the dependent.
+type Dependency struct {
+	App   string        `yaml:"app"`
+	Delay time.Duration `yaml:"delay"` // e.g. 30s
+}
+
+// Dependencies is a list of dependencies, each either an app's name or a
+// mapping with its app and delay:
+//
+//	depends_on: [db, {app: cache, delay: 30s}]
+type Dependencies []Dependency
+
+func (d *Dependencies) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.SequenceNode {
+		return &yaml.TypeError{Errors: []string{"dependencies must be a list"}}
+	}
+	deps := make(Dependencies, 0, len(value.Content))
+	for _, item := range value.Content {
+		var name string
+		if item.Kind == yaml.ScalarNode {
+			if err := item.Decode(&name); err != nil {
+				return err
+			}
+			deps = append(deps, Dependency{App: name})
+			continue
+		}
+		var dep Dependency
+		if err := item.Decode(&dep); err != nil {
+			return err
+		}
+		if dep.App == "" {
+			return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: dependency has no app", item.Line)}}
+		}
+		deps = append(deps, dep)
+	}
+	*d = deps
+	return nil
+}
+
 // StringOrStringSlice is a custom type that can unmarshal a YAML field
 // that is either a single string or a slice of strings.
 type StringOrStringSlice []string
@@ -87,33 +160,154 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"time"
 )
 
 // Graph represents the fully expanded and validated dependency graph.
 type Graph struct {
 	Nodes map[string]*Node
+	Hooks []LayerHook // run between the layers of its plans
 }
 
 // Node represents a single, concrete instance of an application shard.
 type Node struct {
-	ID          string
-	BaseApp     string
-	Shard       int
-	HostGroupID string
-	DependsOn   []*Node
+	ID        string
+	BaseApp   string
+	Shard     int
+	HostGroup *HostGroup // the hosts it shares, nil if it has its own
+	Owner     string     // the app's owner, "" if it declares none
+	Frozen    string     // why plans must leave the node alone, "" unless it is frozen
+	DependsOn []*Node
+	// Delays is how long to wait after a dependency, by ID, is healthy
+	// before starting the node; nil if none of them has a delay.
+	Delays map[string]time.Duration
+}
+
+// DelayAfter is how long the node waits after dep is healthy to start.
+func (n *Node) DelayAfter(dep *Node) time.Duration {
+	return n.Delays[dep.ID]
+}
+
+// addDelay notes that the node waits d after dep, keeping the longest delay
+// of a dependency listed twice.
+func (n *Node) addDelay(dep *Node, d time.Duration) {
+	if d <= 0 || d <= n.Delays[dep.ID] {
+		return
+	}
+	if n.Delays == nil {
+		n.Delays = make(map[string]time.Duration)
+	}
+	n.Delays[dep.ID] = d
+}
+
+// HostGroupID is the ID of the node's host group, "" if it is in none.
+func (n *Node) HostGroupID() string {
+	if n.HostGroup == nil {
+		return ""
+	}
+	return n.HostGroup.ID
+}
+
+// HostGroup is a set of nodes that run on the same hosts: one shard of a
+// same_host_as group, with the apps pinned to that shard.
+type HostGroup struct {
+	ID      string // e.g. "hostgroup-sor-03"
+	Shard   int    // the shard of RootApp's group the hosts are
+	RootApp string // the app the group is named after
+	Members []*Node
+}
+
+// HostGroups is every host group of the graph's nodes, sorted by ID, each
+// with its members sorted by ID. The members are those of the whole
+// topology, also in a subgraph that has only some of them.
+func (g *Graph) HostGroups() []*HostGroup {
+	seen := make(map[*HostGroup]bool)
+	var groups []*HostGroup
+	for _, node := range g.Nodes {
+		if node.HostGroup != nil && !seen[node.HostGroup] {
+			seen[node.HostGroup] = true
+			groups = append(groups, node.HostGroup)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
 }
 
 // DOTOptions allows for customizing the DOT output.
 type DOTOptions struct {
 	ShowCoLocation bool
+
+	// Focus highlights one node: the graph is drawn whole, but only the
+	// focus, its dependencies up to Up hops away and its dependents up to
+	// Down hops away keep their colour, and the edges between them are
+	// drawn as paths through the focus. A negative Up or Down has no limit.
+	Focus    string
+	Up, Down int
+
+	// RankByLayer draws each startup layer on a rank of its own, labelled
+	// and in boot order from the top, so nothing is drawn above what it
+	// depends on.
+	RankByLayer bool
 }
 
+// DOT colours of Focus.
+const (
+	dotFocusFill = "gold"
+	dotFocusPath = "firebrick"
+	dotDimmed    = "gray80"
+)
+
 // DOT generates a Graphviz DOT language representation of the graph.
 func (g *Graph) DOT(opts DOTOptions) (string, error) {
+	var up, down map[string]bool
+	if opts.Focus != "" {
+		focus, ok := g.Nodes[opts.Focus]
+		if !ok {
+			return "", fmt.Errorf("focus node '%s' not found in the graph", opts.Focus)
+		}
+		dependents := make(map[string][]*Node)
+		for _, node := range g.Nodes {
+			for _, dep := range node.DependsOn {
+				dependents[dep.ID] = append(dependents[dep.ID], node)
+			}
+		}
+		up = neighbourhood(focus, opts.Up, func(n *Node) []*Node { return n.DependsOn })
+		down = neighbourhood(focus, opts.Down, func(n *Node) []*Node { return dependents[n.ID] })
+	}
+	// nodeStmt and edgeStmt are the statements for a node and an edge,
+	// coloured for Focus.
+	nodeStmt := func(id string) string {
+		switch {
+		case up == nil:
+			return fmt.Sprintf("\"%s\";", id)
+		case id == opts.Focus:
+			return fmt.Sprintf("\"%s\" [style=\"rounded,filled,bold\", fillcolor=%s];", id, dotFocusFill)
+		case up[id] || down[id]:
+			return fmt.Sprintf("\"%s\";", id)
+		}
+		return fmt.Sprintf("\"%s\" [color=%s, fontcolor=%s];", id, dotDimmed, dotDimmed)
+	}
+	edgeStmt := func(from, to string) string {
+		switch {
+		case up == nil:
+			return fmt.Sprintf("\"%s\" -> \"%s\";", from, to)
+		case up[from] && up[to], down[from] && down[to]:
+			return fmt.Sprintf("\"%s\" -> \"%s\" [color=%s, penwidth=2];", from, to, dotFocusPath)
+		}
+		return fmt.Sprintf("\"%s\" -> \"%s\" [color=%s];", from, to, dotDimmed)
+	}
+
 	var b bytes.Buffer
 	b.WriteString("digraph G {\n")
 	b.WriteString("  compound=true;\n")
-	b.WriteString("  rankdir=TB;\n")
+	if opts.RankByLayer {
+		// edges point from a node to its dependencies, so bottom to top
+		// puts the dependencies above; newrank lets ranks cross clusters
+		b.WriteString("  rankdir=BT;\n")
+		b.WriteString("  newrank=true;\n")
+	} else {
+		b.WriteString("  rankdir=TB;\n")
+	}
 	b.WriteString("  node [shape=box, style=rounded];\n\n")
 
 	nodeKeys := make([]string, 0, len(g.Nodes))
@@ -122,31 +316,23 @@ func (g *Graph) DOT(opts DOTOptions) (string, error) {
 	}
 	sort.Strings(nodeKeys)
 
-	hostGroups := make(map[string][]*Node)
 	for _, key := range nodeKeys {
 		node := g.Nodes[key]
-		if opts.ShowCoLocation && node.HostGroupID != "" {
-			hostGroups[node.HostGroupID] = append(hostGroups[node.HostGroupID], node)
-		} else {
-			b.WriteString(fmt.Sprintf("  \"%s\";\n", node.ID))
+		if !opts.ShowCoLocation || node.HostGroup == nil {
+			b.WriteString("  " + nodeStmt(node.ID) + "\n")
 		}
 	}
 
 	if opts.ShowCoLocation {
-		clusterKeys := make([]string, 0, len(hostGroups))
-		for k := range hostGroups {
-			clusterKeys = append(clusterKeys, k)
-		}
-		sort.Strings(clusterKeys)
-
-		for _, groupID := range clusterKeys {
-			nodes := hostGroups[groupID]
-			b.WriteString(fmt.Sprintf("  subgraph \"cluster_%s\" {\n", groupID))
-			b.WriteString(fmt.Sprintf("    label = \"%s\";\n", groupID))
+		for _, group := range g.HostGroups() {
+			b.WriteString(fmt.Sprintf("  subgraph \"cluster_%s\" {\n", group.ID))
+			b.WriteString(fmt.Sprintf("    label = \"%s\";\n", group.ID))
 			b.WriteString("    style = filled;\n")
 			b.WriteString("    color = lightgrey;\n")
-			for _, node := range nodes {
-				b.WriteString(fmt.Sprintf("    \"%s\";\n", node.ID))
+			for _, node := range group.Members {
+				if _, ok := g.Nodes[node.ID]; ok {
+					b.WriteString("    " + nodeStmt(node.ID) + "\n")
+				}
 			}
 			b.WriteString("  }\n")
 		}
@@ -154,10 +340,25 @@ func (g *Graph) DOT(opts DOTOptions) (string, error) {
 
 	b.WriteString("\n")
 
+	if opts.RankByLayer {
+		for i, layer := range GetStartupOrder(g) {
+			b.WriteString("  { rank=same; ")
+			b.WriteString(fmt.Sprintf("\"layer_%d\" [label=\"layer %d\", shape=plaintext];", i+1, i+1))
+			for _, node := range layer {
+				b.WriteString(fmt.Sprintf(" \"%s\";", node.ID))
+			}
+			b.WriteString(" }\n")
+			if i > 0 {
+				b.WriteString(fmt.Sprintf("  \"layer_%d\" -> \"layer_%d\" [style=invis];\n", i+1, i))
+			}
+		}
+		b.WriteString("\n")
+	}
+
 	for _, key := range nodeKeys {
 		node := g.Nodes[key]
 		for _, dep := range node.DependsOn {
-			b.WriteString(fmt.Sprintf("  \"%s\" -> \"%s\";\n", node.ID, dep.ID))
+			b.WriteString("  " + edgeStmt(node.ID, dep.ID) + "\n")
 		}
 	}
 
@@ -165,10 +366,221 @@ func (g *Graph) DOT(opts DOTOptions) (string, error) {
 	return b.String(), nil
 }
 
+// neighbourhood is the set of nodes reachable from start through next in at
+// most hops steps, or any number for a negative hops, start included.
+func neighbourhood(start *Node, hops int, next func(*Node) []*Node) map[string]bool {
+	seen := map[string]bool{start.ID: true}
+	frontier := []*Node{start}
+	for step := 0; len(frontier) > 0 && (hops < 0 || step < hops); step++ {
+		var nextFrontier []*Node
+		for _, node := range frontier {
+			for _, n := range next(node) {
+				if !seen[n.ID] {
+					seen[n.ID] = true
+					nextFrontier = append(nextFrontier, n)
+				}
+			}
+		}
+		frontier = nextFrontier
+	}
+	return seen
+}
+
 // END FILE: graph.go
 
 // ------------------------------------------------------------------
 
+// FILE: export.go
+// This new file writes the graph for graph editors: GraphML for yEd and
+// Cytoscape.js JSON, next to DOT for Graphviz.
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+// ExportOptions allows for customizing the GraphML and Cytoscape output.
+type ExportOptions struct {
+	// ShowCoLocation nests the nodes of a host group in a group node (a
+	// compound node in Cytoscape), as DOT draws them in a cluster.
+	ShowCoLocation bool
+}
+
+// exportNode is what the editors get to know about a node.
+type exportNode struct {
+	node  *Node
+	layer int // startup layer, from 1
+}
+
+// exportNodes is the nodes sorted by ID with their startup layers, grouped
+// by host group when opts asks for it; nodes outside a group are under "".
+func (g *Graph) exportNodes(opts ExportOptions) (groups []string, byGroup map[string][]exportNode) {
+	layers := make(map[string]int)
+	for i, layer := range GetStartupOrder(g) {
+		for _, node := range layer {
+			layers[node.ID] = i + 1
+		}
+	}
+	byGroup = make(map[string][]exportNode)
+	for _, node := range g.Nodes {
+		group := ""
+		if opts.ShowCoLocation {
+			group = node.HostGroupID()
+		}
+		byGroup[group] = append(byGroup[group], exportNode{node: node, layer: layers[node.ID]})
+	}
+	for group, nodes := range byGroup {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].node.ID < nodes[j].node.ID })
+		if group != "" {
+			groups = append(groups, group)
+		}
+	}
+	sort.Strings(groups)
+	return groups, byGroup
+}
+
+// sortedEdges is every dependency of g, sorted.
+func (g *Graph) sortedEdges() []Edge {
+	var es []Edge
+	for e := range edges(g) {
+		es = append(es, e)
+	}
+	sortEdges(es)
+	return es
+}
+
+// graphMLKeys are the node attributes of GraphML output; nodegraphics is
+// where yEd looks for a node's label.
+const graphMLKeys = `  <key id="base_app" for="node" attr.name="base_app" attr.type="string"/>
+  <key id="shard" for="node" attr.name="shard" attr.type="int"/>
+  <key id="host_group" for="node" attr.name="host_group" attr.type="string"/>
+  <key id="owner" for="node" attr.name="owner" attr.type="string"/>
+  <key id="layer" for="node" attr.name="layer" attr.type="int"/>
+  <key id="graphics" for="node" yfiles.type="nodegraphics"/>
+`
+
+// GraphML generates a GraphML document of the graph, labelled for yEd. As
+// in DOT, edges point from a node to its dependencies.
+func (g *Graph) GraphML(opts ExportOptions) (string, error) {
+	groups, byGroup := g.exportNodes(opts)
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:y="http://www.yworks.com/xml/graphml" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://www.yworks.com/xml/schema/graphml/1.1/ygraphml.xsd">` + "\n")
+	b.WriteString(graphMLKeys)
+	b.WriteString(`  <graph id="G" edgedefault="directed">` + "\n")
+
+	writeNodes := func(nodes []exportNode, indent string) {
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "%s<node id=\"%s\">\n", indent, xmlEscape(n.node.ID))
+			fmt.Fprintf(&b, "%s  <data key=\"base_app\">%s</data>\n", indent, xmlEscape(n.node.BaseApp))
+			fmt.Fprintf(&b, "%s  <data key=\"shard\">%d</data>\n", indent, n.node.Shard)
+			if n.node.HostGroup != nil {
+				fmt.Fprintf(&b, "%s  <data key=\"host_group\">%s</data>\n", indent, xmlEscape(n.node.HostGroup.ID))
+			}
+			if n.node.Owner != "" {
+				fmt.Fprintf(&b, "%s  <data key=\"owner\">%s</data>\n", indent, xmlEscape(n.node.Owner))
+			}
+			fmt.Fprintf(&b, "%s  <data key=\"layer\">%d</data>\n", indent, n.layer)
+			fmt.Fprintf(&b, "%s  <data key=\"graphics\"><y:ShapeNode><y:Shape type=\"roundrectangle\"/><y:NodeLabel>%s</y:NodeLabel></y:ShapeNode></data>\n", indent, xmlEscape(n.node.ID))
+			fmt.Fprintf(&b, "%s</node>\n", indent)
+		}
+	}
+	writeNodes(byGroup[""], "    ")
+	for _, group := range groups {
+		id := xmlEscape(group)
+		fmt.Fprintf(&b, "    <node id=\"%s\" yfiles.foldertype=\"group\">\n", id)
+		fmt.Fprintf(&b, "      <data key=\"graphics\"><y:ProxyAutoBoundsNode><y:Realizers active=\"0\"><y:GroupNode><y:NodeLabel>%s</y:NodeLabel></y:GroupNode></y:Realizers></y:ProxyAutoBoundsNode></data>\n", id)
+		fmt.Fprintf(&b, "      <graph id=\"%s:\" edgedefault=\"directed\">\n", id)
+		writeNodes(byGroup[group], "        ")
+		b.WriteString("      </graph>\n")
+		b.WriteString("    </node>\n")
+	}
+	for i, e := range g.sortedEdges() {
+		fmt.Fprintf(&b, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\"/>\n", i, xmlEscape(e.From), xmlEscape(e.To))
+	}
+
+	b.WriteString("  </graph>\n")
+	b.WriteString("</graphml>\n")
+	return b.String(), nil
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// cytoscapeElement is a node or edge of Cytoscape.js JSON.
+type cytoscapeElement struct {
+	Data cytoscapeData `json:"data"`
+}
+
+type cytoscapeData struct {
+	ID        string `json:"id"`
+	Label     string `json:"label,omitempty"`
+	Parent    string `json:"parent,omitempty"` // the host group's compound node
+	BaseApp   string `json:"base_app,omitempty"`
+	Shard     *int   `json:"shard,omitempty"`
+	HostGroup string `json:"host_group,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Layer     int    `json:"layer,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Target    string `json:"target,omitempty"`
+}
+
+// CytoscapeJSON generates the graph as Cytoscape.js elements JSON, which
+// Cytoscape desktop imports too. As in DOT, edges point from a node to its
+// dependencies.
+func (g *Graph) CytoscapeJSON(opts ExportOptions) (string, error) {
+	groups, byGroup := g.exportNodes(opts)
+
+	var elements struct {
+		Nodes []cytoscapeElement `json:"nodes"`
+		Edges []cytoscapeElement `json:"edges"`
+	}
+	elements.Nodes, elements.Edges = []cytoscapeElement{}, []cytoscapeElement{}
+	for _, group := range groups {
+		elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: cytoscapeData{ID: group, Label: group}})
+	}
+	for _, group := range append([]string{""}, groups...) {
+		for _, n := range byGroup[group] {
+			shard := n.node.Shard
+			elements.Nodes = append(elements.Nodes, cytoscapeElement{Data: cytoscapeData{
+				ID:        n.node.ID,
+				Label:     n.node.ID,
+				Parent:    group,
+				BaseApp:   n.node.BaseApp,
+				Shard:     &shard,
+				HostGroup: n.node.HostGroupID(),
+				Owner:     n.node.Owner,
+				Layer:     n.layer,
+			}})
+		}
+	}
+	for _, e := range g.sortedEdges() {
+		elements.Edges = append(elements.Edges, cytoscapeElement{Data: cytoscapeData{
+			ID:     e.From + "->" + e.To,
+			Source: e.From,
+			Target: e.To,
+		}})
+	}
+
+	out, err := json.MarshalIndent(map[string]any{"elements": elements}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+// END FILE: export.go
+
+// ------------------------------------------------------------------
+
 // FILE: parser.go
 // This file contains the core logic for parsing the topology. It has been
 // completely refactored to support the new, cleaner blueprint model.
@@ -183,9 +595,26 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultMaxShards is the most shards an app may have unless ParseOptions
+// says otherwise: node IDs number shards with two digits.
+const DefaultMaxShards = 100
+
+// ParseOptions tunes the validation done by ParseYAMLWithOptions.
+type ParseOptions struct {
+	MaxShards int // the most shards an app may have; 0 or less means DefaultMaxShards
+}
+
 // ParseYAML takes a byte slice of a YAML topology file and returns a fully
 // validated and expanded Graph object.
 func ParseYAML(data []byte) (*Graph, error) {
+	return ParseYAMLWithOptions(data, ParseOptions{})
+}
+
+// ParseYAMLWithOptions is ParseYAML with the validation tuned by opts.
+func ParseYAMLWithOptions(data []byte, opts ParseOptions) (*Graph, error) {
+	if opts.MaxShards <= 0 {
+		opts.MaxShards = DefaultMaxShards
+	}
 	var rawTopology YAMLTopology
 	decoder := yaml.NewDecoder(bytes.NewReader(data))
 	decoder.KnownFields(true)
@@ -204,17 +633,22 @@ func ParseYAML(data []byte) (*Graph, error) {
 		return nil, err
 	}
 
-	appShardCounts, err := inferAndValidateShardCounts(rawTopology, coLocationGroups)
+	appShardCounts, err := inferAndValidateShardCounts(rawTopology, coLocationGroups, opts.MaxShards)
+	if err != nil {
+		return nil, err
+	}
+
+	pins, err := resolveShardPins(rawTopology, coLocationGroups, appShardCounts)
 	if err != nil {
 		return nil, err
 	}
 
-	graph, err := buildConcreteNodes(rawTopology, coLocationGroups, appShardCounts)
+	graph, err := buildConcreteNodes(rawTopology, coLocationGroups, appShardCounts, pins)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := linkDependencies(graph, rawTopology, appShardCounts); err != nil {
+	if err := linkDependencies(graph, rawTopology, appShardCounts, pins); err != nil {
 		return nil, err
 	}
 
@@ -222,9 +656,45 @@ func ParseYAML(data []byte) (*Graph, error) {
 		return nil, fmt.Errorf("validation failed: dependency cycle detected: %s", strings.Join(cyclePath, " -> "))
 	}
 
+	if err := validateHooks(rawTopology.Hooks); err != nil {
+		return nil, err
+	}
+	graph.Hooks = rawTopology.Hooks
+
 	return graph, nil
 }
 
+// validateHooks checks that every hook has a name and a command, and only
+// names layers and modes a plan can have.
+func validateHooks(hooks []LayerHook) error {
+	names := make(map[string]bool)
+	for i, hook := range hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("hook %d has no name", i+1)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("hook '%s' is defined twice", hook.Name)
+		}
+		names[hook.Name] = true
+		if strings.TrimSpace(hook.Run) == "" {
+			return fmt.Errorf("hook '%s' has nothing to run", hook.Name)
+		}
+		for _, layer := range hook.After {
+			if layer < 1 {
+				return fmt.Errorf("hook '%s' runs after layer %d, but layers are numbered from 1", hook.Name, layer)
+			}
+		}
+		for _, mode := range hook.Modes {
+			switch mode {
+			case "startup", "shutdown", "restart":
+			default:
+				return fmt.Errorf("hook '%s' has unknown mode '%s'", hook.Name, mode)
+			}
+		}
+	}
+	return nil
+}
+
 // expandBlueprints is the new first stage of parsing. It takes the raw topology
 // and returns a new, complete map of AppDefinitions by instantiating all blueprints.
 func expandBlueprints(rawTopology YAMLTopology) (map[string]AppDefinition, error) {
@@ -246,7 +716,7 @@ func expandBlueprints(rawTopology YAMLTopology) (map[string]AppDefinition, error
 				parentApp := expandedApps[appName]
 				for bpAppName := range blueprint.Apps {
 					instantiatedAppName := fmt.Sprintf("%s-%s", appName, bpAppName)
-					parentApp.DependsOn = append(parentApp.DependsOn, instantiatedAppName)
+					parentApp.DependsOn = append(parentApp.DependsOn, Dependency{App: instantiatedAppName})
 				}
 				expandedApps[appName] = parentApp
 			}
@@ -258,7 +728,13 @@ func expandBlueprints(rawTopology YAMLTopology) (map[string]AppDefinition, error
 				}
 
 				newAppDef := AppDefinition{
-					SameHostAs: []string{appName}, // Automatic co-location
+					SameHostAs:   []string{appName}, // Automatic co-location
+					Owner:        appDef.Owner,
+					Frozen:       appDef.Frozen,
+					FreezeReason: appDef.FreezeReason,
+				}
+				if bpAppDef.Owner != "" {
+					newAppDef.Owner = bpAppDef.Owner
 				}
 
 				for _, extDep := range bpAppDef.ExternalDependsOn {
@@ -266,14 +742,14 @@ func expandBlueprints(rawTopology YAMLTopology) (map[string]AppDefinition, error
 					if !ok {
 						return nil, fmt.Errorf("in blueprint '%s' used by '%s', external dependency '%s' is not resolved in 'with' clause", instance.Blueprint, appName, extDep)
 					}
-					newAppDef.DependsOn = append(newAppDef.DependsOn, resolvedDep)
+					newAppDef.DependsOn = append(newAppDef.DependsOn, Dependency{App: resolvedDep})
 				}
 				for _, extDep := range bpAppDef.ExternalDependsOnAllOf {
 					resolvedDep, ok := instance.With[extDep]
 					if !ok {
 						return nil, fmt.Errorf("in blueprint '%s' used by '%s', external dependency '%s' is not resolved in 'with' clause", instance.Blueprint, appName, extDep)
 					}
-					newAppDef.DependsOnAllOf = append(newAppDef.DependsOnAllOf, resolvedDep)
+					newAppDef.DependsOnAllOf = append(newAppDef.DependsOnAllOf, Dependency{App: resolvedDep})
 				}
 				
 				for _, intDep := range bpAppDef.DependsOn {
@@ -281,7 +757,7 @@ func expandBlueprints(rawTopology YAMLTopology) (map[string]AppDefinition, error
 						return nil, fmt.Errorf("in blueprint '%s', app '%s' has an internal dependency on '%s', which is not defined in the blueprint", instance.Blueprint, bpAppName, intDep)
 					}
 					instantiatedDepName := fmt.Sprintf("%s-%s", appName, intDep)
-					newAppDef.DependsOn = append(newAppDef.DependsOn, instantiatedDepName)
+					newAppDef.DependsOn = append(newAppDef.DependsOn, Dependency{App: instantiatedDepName})
 				}
 
 				expandedApps[instantiatedAppName] = newAppDef
@@ -332,7 +808,11 @@ func discoverCoLocationGroups(rawTopology YAMLTopology) (map[string][]string, er
 			if _, ok := rawTopology.Apps[targetName]; !ok {
 				return nil, fmt.Errorf("validation failed: same_host_as target '%s' for app '%s' does not exist", targetName, appName)
 			}
-			union(appName, targetName)
+			// A pinned app is a group of its own, placed on one shard of
+			// its target's group by resolveShardPins.
+			if appDef.PinToShard == nil {
+				union(appName, targetName)
+			}
 		}
 	}
 
@@ -347,13 +827,21 @@ func discoverCoLocationGroups(rawTopology YAMLTopology) (map[string][]string, er
 	return groups, nil
 }
 
-func inferAndValidateShardCounts(rawTopology YAMLTopology, coLocationGroups map[string][]string) (map[string]int, error) {
+func inferAndValidateShardCounts(rawTopology YAMLTopology, coLocationGroups map[string][]string, maxShards int) (map[string]int, error) {
 	appShardCounts := make(map[string]int)
 
+	shardedApps := make([]string, 0, len(rawTopology.Shards))
 	for appName := range rawTopology.Shards {
+		shardedApps = append(shardedApps, appName)
+	}
+	sort.Strings(shardedApps)
+	for _, appName := range shardedApps {
 		if _, ok := rawTopology.Apps[appName]; !ok {
 			return nil, fmt.Errorf("validation failed: shard count defined for non-existent app '%s'", appName)
 		}
+		if count := rawTopology.Shards[appName]; count < 1 || count > maxShards {
+			return nil, fmt.Errorf("validation failed: app '%s' has %d shards, but must have 1 to %d", appName, count, maxShards)
+		}
 	}
 
 	for root, members := range coLocationGroups {
@@ -376,46 +864,175 @@ func inferAndValidateShardCounts(rawTopology YAMLTopology, coLocationGroups map[
 	return appShardCounts, nil
 }
 
-func buildConcreteNodes(rawTopology YAMLTopology, coLocationGroups map[string][]string, appShardCounts map[string]int) (*Graph, error) {
+// shardPin places a pinned co-location group on one shard of another.
+type shardPin struct {
+	App   string // the app with the pin_to_shard
+	Root  string // root of the group it is pinned into
+	Shard int
+}
+
+// resolveShardPins checks every pin_to_shard and returns the pins by app,
+// for every member of a pinned app's group (its blueprint components, say).
+// A pinned app must be a singleton whose same_host_as targets share one
+// group with more shards than the pin.
+func resolveShardPins(rawTopology YAMLTopology, coLocationGroups map[string][]string, appShardCounts map[string]int) (map[string]shardPin, error) {
+	appRoots := make(map[string]string)
+	for root, members := range coLocationGroups {
+		for _, member := range members {
+			appRoots[member] = root
+		}
+	}
+
+	appNames := make([]string, 0, len(rawTopology.Apps))
+	for name := range rawTopology.Apps {
+		appNames = append(appNames, name)
+	}
+	sort.Strings(appNames)
+
+	pins := make(map[string]shardPin)
+	for _, appName := range appNames {
+		appDef := rawTopology.Apps[appName]
+		if appDef.PinToShard == nil {
+			continue
+		}
+		shard := *appDef.PinToShard
+		if len(appDef.SameHostAs) == 0 {
+			return nil, fmt.Errorf("validation failed: app '%s' has pin_to_shard but no same_host_as to pin it to", appName)
+		}
+		if count := appShardCounts[appName]; count != 1 {
+			return nil, fmt.Errorf("validation failed: app '%s' is pinned to a shard, so it must be a singleton, but it has %d shards", appName, count)
+		}
+		targetRoot := appRoots[appDef.SameHostAs[0]]
+		for _, targetName := range appDef.SameHostAs[1:] {
+			if appRoots[targetName] != targetRoot {
+				return nil, fmt.Errorf("validation failed: app '%s' is pinned to a shard of both '%s' and '%s', which are not co-located", appName, appDef.SameHostAs[0], targetName)
+			}
+		}
+		if targetRoot == appRoots[appName] {
+			return nil, fmt.Errorf("validation failed: app '%s' is pinned into its own co-location group", appName)
+		}
+		targetShards := appShardCounts[targetRoot]
+		if shard < 0 || shard >= targetShards {
+			return nil, fmt.Errorf("validation failed: app '%s' is pinned to shard %d of '%s', which has shards 0 to %d", appName, shard, appDef.SameHostAs[0], targetShards-1)
+		}
+		pin := shardPin{App: appName, Root: targetRoot, Shard: shard}
+		for _, member := range coLocationGroups[appRoots[appName]] {
+			if other, ok := pins[member]; ok && other != pin {
+				return nil, fmt.Errorf("validation failed: apps '%s' and '%s' are co-located but pinned to different shards", other.App, appName)
+			}
+			pins[member] = pin
+		}
+	}
+	for _, appName := range appNames {
+		pin, ok := pins[appName]
+		if !ok {
+			continue
+		}
+		if target, chained := pins[pin.Root]; chained {
+			return nil, fmt.Errorf("validation failed: app '%s' is pinned to '%s', which is itself pinned; pin it to '%s' directly", pin.App, pin.Root, target.Root)
+		}
+	}
+	return pins, nil
+}
+
+func buildConcreteNodes(rawTopology YAMLTopology, coLocationGroups map[string][]string, appShardCounts map[string]int, pins map[string]shardPin) (*Graph, error) {
 	graph := &Graph{Nodes: make(map[string]*Node)}
+	hostGroups := make(map[string]*HostGroup)
 	appRoots := make(map[string]string)
 	for root, members := range coLocationGroups {
 		for _, member := range members {
 			appRoots[member] = root
 		}
 	}
-	for appName := range rawTopology.Apps {
+	// a group shares its hosts if it has several apps or others are pinned to it
+	pinnedTo := make(map[string]bool)
+	for _, pin := range pins {
+		pinnedTo[pin.Root] = true
+	}
+	appNames := make([]string, 0, len(rawTopology.Apps))
+	for name := range rawTopology.Apps {
+		appNames = append(appNames, name)
+	}
+	sort.Strings(appNames)
+
+	for _, appName := range appNames {
 		shardCount := appShardCounts[appName]
 		groupRoot := appRoots[appName]
 		for i := 0; i < shardCount; i++ {
 			nodeID := getNodeID(appName, i, shardCount)
-			hostGroupID := ""
-			if len(coLocationGroups[groupRoot]) > 1 {
-				hostGroupID = getNodeID(fmt.Sprintf("hostgroup-%s", groupRoot), i, shardCount)
+			// a shard of sor named like the app sor-01 would replace its node
+			if _, ok := rawTopology.Apps[nodeID]; ok && nodeID != appName {
+				return nil, fmt.Errorf("validation failed: shard %d of app '%s' is node '%s', which is also the name of an app; rename one of them", i, appName, nodeID)
+			}
+			if other, ok := graph.Nodes[nodeID]; ok {
+				return nil, fmt.Errorf("validation failed: apps '%s' and '%s' both have a node named '%s'", other.BaseApp, appName, nodeID)
+			}
+			var group *HostGroup
+			if pin, ok := pins[appName]; ok {
+				group = hostGroupFor(hostGroups, pin.Root, pin.Shard, appShardCounts[pin.Root])
+			} else if len(coLocationGroups[groupRoot]) > 1 || pinnedTo[groupRoot] {
+				group = hostGroupFor(hostGroups, groupRoot, i, shardCount)
+			}
+			node := &Node{
+				ID:        nodeID,
+				BaseApp:   appName,
+				Shard:     i,
+				HostGroup: group,
+				Owner:     rawTopology.Apps[appName].Owner,
 			}
-			graph.Nodes[nodeID] = &Node{
-				ID:          nodeID,
-				BaseApp:     appName,
-				Shard:       i,
-				HostGroupID: hostGroupID,
+			if appDef := rawTopology.Apps[appName]; appDef.Frozen {
+				node.Frozen = appDef.FreezeReason
+				if node.Frozen == "" {
+					node.Frozen = "frozen in the topology"
+				}
+			}
+			if group != nil {
+				group.Members = append(group.Members, node)
 			}
+			graph.Nodes[nodeID] = node
 		}
 	}
+	for _, group := range hostGroups {
+		sort.Slice(group.Members, func(i, j int) bool { return group.Members[i].ID < group.Members[j].ID })
+	}
 	return graph, nil
 }
 
-func linkDependencies(graph *Graph, rawTopology YAMLTopology, appShardCounts map[string]int) error {
+// hostGroupFor is shard of root's co-location group, which has shardCount
+// shards, added to groups the first time it is asked for.
+func hostGroupFor(groups map[string]*HostGroup, root string, shard, shardCount int) *HostGroup {
+	id := getNodeID(fmt.Sprintf("hostgroup-%s", root), shard, shardCount)
+	group, ok := groups[id]
+	if !ok {
+		group = &HostGroup{ID: id, Shard: shard, RootApp: root}
+		groups[id] = group
+	}
+	return group
+}
+
+func linkDependencies(graph *Graph, rawTopology YAMLTopology, appShardCounts map[string]int, pins map[string]shardPin) error {
 	for appName, appDef := range rawTopology.Apps {
 		appShardCount := appShardCounts[appName]
 		for i := 0; i < appShardCount; i++ {
 			nodeID := getNodeID(appName, i, appShardCount)
 			node := graph.Nodes[nodeID]
 
-			for _, depName := range appDef.DependsOn {
+			for _, dep := range appDef.DependsOn {
+				depName := dep.App
 				if _, ok := rawTopology.Apps[depName]; !ok {
 					return fmt.Errorf("validation failed: depends_on target '%s' for app '%s' does not exist", depName, appName)
 				}
+				if dep.Delay < 0 {
+					return fmt.Errorf("validation failed: app '%s' has a negative delay after '%s'", appName, depName)
+				}
 				depShardCount := appShardCounts[depName]
+				// a pinned app depends on its own shard of apps sharded like its hosts
+				if pin, pinned := pins[appName]; pinned && depShardCount != 1 && depShardCount == appShardCounts[pin.Root] {
+					depNode := graph.Nodes[getNodeID(depName, pin.Shard, depShardCount)]
+					node.DependsOn = append(node.DependsOn, depNode)
+					node.addDelay(depNode, dep.Delay)
+					continue
+				}
 				if depShardCount != 1 && depShardCount != appShardCount {
 					return fmt.Errorf("validation failed: ambiguous 'depends_on' from '%s' (%d shards) to '%s' (%d shards). Use 'depends_on_all_of' for fan-in dependencies", appName, appShardCount, depName, depShardCount)
 				}
@@ -423,18 +1040,24 @@ func linkDependencies(graph *Graph, rawTopology YAMLTopology, appShardCounts map
 				if depShardCount == 1 {
 					depShardIndex = 0
 				}
-				depNodeID := getNodeID(depName, depShardIndex, depShardCount)
-				node.DependsOn = append(node.DependsOn, graph.Nodes[depNodeID])
+				depNode := graph.Nodes[getNodeID(depName, depShardIndex, depShardCount)]
+				node.DependsOn = append(node.DependsOn, depNode)
+				node.addDelay(depNode, dep.Delay)
 			}
 			
-			for _, depName := range appDef.DependsOnAllOf {
+			for _, dep := range appDef.DependsOnAllOf {
+				depName := dep.App
 				if _, ok := rawTopology.Apps[depName]; !ok {
 					return fmt.Errorf("validation failed: depends_on_all_of target '%s' for app '%s' does not exist", depName, appName)
 				}
+				if dep.Delay < 0 {
+					return fmt.Errorf("validation failed: app '%s' has a negative delay after '%s'", appName, depName)
+				}
 				depShardCount := appShardCounts[depName]
 				for j := 0; j < depShardCount; j++ {
-					depNodeID := getNodeID(depName, j, depShardCount)
-					node.DependsOn = append(node.DependsOn, graph.Nodes[depNodeID])
+					depNode := graph.Nodes[getNodeID(depName, j, depShardCount)]
+					node.DependsOn = append(node.DependsOn, depNode)
+					node.addDelay(depNode, dep.Delay)
 				}
 			}
 		}
@@ -557,11 +1180,11 @@ func GetSubgraphFor(graph *Graph, targetNodeID string) (*Graph, error) {
 	if !ok {
 		return nil, fmt.Errorf("node '%s' not found in the graph", targetNodeID)
 	}
-	subgraph := &Graph{Nodes: make(map[string]*Node)}
+	subgraph := &Graph{Nodes: make(map[string]*Node), Hooks: graph.Hooks}
 	var initialNodes []*Node
-	if startNode.HostGroupID != "" {
-		for _, node := range graph.Nodes {
-			if node.HostGroupID == startNode.HostGroupID {
+	if startNode.HostGroup != nil {
+		for _, node := range startNode.HostGroup.Members {
+			if _, ok := graph.Nodes[node.ID]; ok {
 				initialNodes = append(initialNodes, node)
 			}
 		}
@@ -584,6 +1207,62 @@ func GetSubgraphFor(graph *Graph, targetNodeID string) (*Graph, error) {
 	return subgraph, nil
 }
 
+// LayerOwners is the owners of a layer's nodes, sorted, without the nodes
+// that have none.
+func LayerOwners(layer []*Node) []string {
+	seen := make(map[string]bool)
+	var owners []string
+	for _, node := range layer {
+		if node.Owner != "" && !seen[node.Owner] {
+			seen[node.Owner] = true
+			owners = append(owners, node.Owner)
+		}
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// TeamPlan is the part of a plan one owner has: its nodes, in plan order,
+// and the layers they are in, from 0.
+type TeamPlan struct {
+	Owner  string // "" for the nodes without an owner
+	Nodes  []string
+	Layers []int
+}
+
+// PlanByOwner groups the nodes of a plan by owner, the owners sorted and
+// the nodes without one last. Nil when no node has an owner.
+func PlanByOwner(order [][]*Node) []TeamPlan {
+	byOwner := make(map[string]*TeamPlan)
+	for i, layer := range order {
+		for _, node := range layer {
+			team, ok := byOwner[node.Owner]
+			if !ok {
+				team = &TeamPlan{Owner: node.Owner}
+				byOwner[node.Owner] = team
+			}
+			team.Nodes = append(team.Nodes, node.ID)
+			if n := len(team.Layers); n == 0 || team.Layers[n-1] != i {
+				team.Layers = append(team.Layers, i)
+			}
+		}
+	}
+	if _, unowned := byOwner[""]; len(byOwner) == 0 || len(byOwner) == 1 && unowned {
+		return nil
+	}
+	teams := make([]TeamPlan, 0, len(byOwner))
+	for _, team := range byOwner {
+		teams = append(teams, *team)
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if (teams[i].Owner == "") != (teams[j].Owner == "") {
+			return teams[j].Owner == ""
+		}
+		return teams[i].Owner < teams[j].Owner
+	})
+	return teams
+}
+
 // END FILE: traversal.go
 
 // ------------------------------------------------------------------
@@ -593,13 +1272,15 @@ func GetSubgraphFor(graph *Graph, targetNodeID string) (*Graph, error) {
 package topology
 
 func (g *Graph) LogicalGraph() (*Graph, error) {
-	logicalGraph := &Graph{Nodes: make(map[string]*Node)}
-	baseApps := make(map[string]bool)
+	logicalGraph := &Graph{Nodes: make(map[string]*Node), Hooks: g.Hooks}
 	for _, node := range g.Nodes {
-		baseApps[node.BaseApp] = true
+		logicalGraph.Nodes[node.BaseApp] = &Node{ID: node.BaseApp, BaseApp: node.BaseApp, Owner: node.Owner}
 	}
-	for appName := range baseApps {
-		logicalGraph.Nodes[appName] = &Node{ID: appName, BaseApp: appName}
+	// an app is frozen if any of its nodes is
+	for _, node := range g.Nodes {
+		if logicalNode := logicalGraph.Nodes[node.BaseApp]; logicalNode.Frozen == "" {
+			logicalNode.Frozen = node.Frozen
+		}
 	}
 	for _, node := range g.Nodes {
 		logicalNode := logicalGraph.Nodes[node.BaseApp]
@@ -615,6 +1296,9 @@ func (g *Graph) LogicalGraph() (*Graph, error) {
 			if !found && logicalNode.ID != logicalDep.ID {
 				logicalNode.DependsOn = append(logicalNode.DependsOn, logicalDep)
 			}
+			if logicalNode.ID != logicalDep.ID {
+				logicalNode.addDelay(logicalDep, node.DelayAfter(dep))
+			}
 		}
 	}
 	return logicalGraph, nil
@@ -624,150 +1308,3954 @@ func (g *Graph) LogicalGraph() (*Graph, error) {
 
 // ------------------------------------------------------------------
 
-// FILE: cmd/yaml2dot/main.go
-// This tool is updated to support logical views and co-location clustering.
-package main
+// FILE: diff.go
+// This new file compares two versions of a topology, e.g. a branch's against main's.
+package topology
 
-import (
-	"errors"
-	"flag"
-	"fmt"
-	"io"
-	"os"
-	"os/exec"
-	"strings"
-	"yourcorp/topology"
-)
+import "sort"
 
-func main() {
-	format := flag.String("T", "dot", "Output format (e.g., dot, svg, png).")
-	view := flag.String("view", "concrete", "Graph view: 'concrete' (default) or 'logical'.")
-	flag.Parse()
-	yamlData, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading from stdin: %v\n", err)
-		os.Exit(1)
-	}
-	graph, err := topology.ParseYAML(yamlData)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing topology: %v\n", err)
-		os.Exit(1)
+// Edge is one dependency: From depends on To.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// HostGroupChange is a node that moved to another host group.
+type HostGroupChange struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// GraphDiff is what changed between two graphs. Every list is sorted.
+type GraphDiff struct {
+	AddedNodes   []string          `json:"added_nodes,omitempty"`
+	RemovedNodes []string          `json:"removed_nodes,omitempty"`
+	AddedEdges   []Edge            `json:"added_edges,omitempty"`
+	RemovedEdges []Edge            `json:"removed_edges,omitempty"`
+	MovedNodes   []HostGroupChange `json:"moved_nodes,omitempty"`
+}
+
+// Empty reports whether the graphs are the same.
+func (d GraphDiff) Empty() bool {
+	return len(d.AddedNodes)+len(d.RemovedNodes)+len(d.AddedEdges)+len(d.RemovedEdges)+len(d.MovedNodes) == 0
+}
+
+// Diff compares two graphs node by node. Edges of added or removed nodes are
+// listed too, so applying the diff to old gives new.
+func Diff(old, new *Graph) GraphDiff {
+	var d GraphDiff
+	for id, node := range new.Nodes {
+		prev, ok := old.Nodes[id]
+		if !ok {
+			d.AddedNodes = append(d.AddedNodes, id)
+			continue
+		}
+		if prev.HostGroupID() != node.HostGroupID() {
+			d.MovedNodes = append(d.MovedNodes, HostGroupChange{ID: id, From: prev.HostGroupID(), To: node.HostGroupID()})
+		}
+	}
+	for id := range old.Nodes {
+		if _, ok := new.Nodes[id]; !ok {
+			d.RemovedNodes = append(d.RemovedNodes, id)
+		}
+	}
+	oldEdges, newEdges := edges(old), edges(new)
+	for e := range newEdges {
+		if !oldEdges[e] {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	for e := range oldEdges {
+		if !newEdges[e] {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+
+	sort.Strings(d.AddedNodes)
+	sort.Strings(d.RemovedNodes)
+	sortEdges(d.AddedEdges)
+	sortEdges(d.RemovedEdges)
+	sort.Slice(d.MovedNodes, func(i, j int) bool { return d.MovedNodes[i].ID < d.MovedNodes[j].ID })
+	return d
+}
+
+func edges(g *Graph) map[Edge]bool {
+	set := make(map[Edge]bool)
+	for _, node := range g.Nodes {
+		for _, dep := range node.DependsOn {
+			set[Edge{From: node.ID, To: dep.ID}] = true
+		}
+	}
+	return set
+}
+
+func sortEdges(es []Edge) {
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].From != es[j].From {
+			return es[i].From < es[j].From
+		}
+		return es[i].To < es[j].To
+	})
+}
+
+// END FILE: diff.go
+
+// ------------------------------------------------------------------
+
+// FILE: projects.go
+// This new file builds a graph from depgraph's project metadata, so the build
+// graph can be drawn and ordered like the topology.
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProjectsOptions tunes ParseProjectsJSON.
+type ProjectsOptions struct {
+	// Libraries keeps the projects that are not deployable as nodes. Without
+	// it only deployables are, each depending on the deployables it reaches
+	// through libraries.
+	Libraries bool
+}
+
+// project is one entry of projects.json, as depgraph reads it.
+type project struct {
+	ProjectDir   string   `json:"projectDir"`
+	Dependencies []string `json:"dependencies"`
+	Deployable   bool     `json:"deployable"`
+}
+
+// ParseProjectsJSON builds a graph from depgraph's projects.json, a JSON
+// object of projects by name: the deployables become apps, one node each,
+// and their dependencies the edges.
+func ParseProjectsJSON(data []byte, opts ProjectsOptions) (*Graph, error) {
+	var projects map[string]project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("failed to parse projects: %w", err)
+	}
+
+	all := &Graph{Nodes: make(map[string]*Node, len(projects))}
+	for name := range projects {
+		all.Nodes[name] = &Node{ID: name, BaseApp: name}
+	}
+	names := make([]string, 0, len(projects))
+	for name := range projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, depName := range projects[name].Dependencies {
+			dep, ok := all.Nodes[depName]
+			if !ok {
+				return nil, fmt.Errorf("validation failed: project '%s' lists unknown dependency '%s'", name, depName)
+			}
+			all.Nodes[name].DependsOn = append(all.Nodes[name].DependsOn, dep)
+		}
+	}
+	if cyclePath, ok := detectCycle(all); ok {
+		return nil, fmt.Errorf("validation failed: dependency cycle detected: %s", strings.Join(cyclePath, " -> "))
+	}
+	if opts.Libraries {
+		return all, nil
+	}
+
+	graph := &Graph{Nodes: make(map[string]*Node)}
+	for _, name := range names {
+		if projects[name].Deployable {
+			graph.Nodes[name] = &Node{ID: name, BaseApp: name}
+		}
+	}
+	for _, name := range names {
+		node, ok := graph.Nodes[name]
+		if !ok {
+			continue
+		}
+		// the deployables reached through libraries, each once
+		seen := make(map[string]bool)
+		var reach func(n *Node)
+		reach = func(n *Node) {
+			for _, dep := range n.DependsOn {
+				if seen[dep.ID] {
+					continue
+				}
+				seen[dep.ID] = true
+				if d, ok := graph.Nodes[dep.ID]; ok {
+					node.DependsOn = append(node.DependsOn, d)
+				} else {
+					reach(dep)
+				}
+			}
+		}
+		reach(all.Nodes[name])
+		sort.Slice(node.DependsOn, func(i, j int) bool { return node.DependsOn[i].ID < node.DependsOn[j].ID })
+	}
+	return graph, nil
+}
+
+// END FILE: projects.go
+
+// ------------------------------------------------------------------
+
+// FILE: execute.go
+// This new file contains the execution engine that carries out a plan.
+package topology
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NodeStatus is where a node stands while a plan is executed.
+type NodeStatus string
+
+const (
+	StatusPending NodeStatus = "pending"
+	StatusWaiting NodeStatus = "waiting" // out its dependencies' delays
+	StatusRunning NodeStatus = "running"
+	StatusHealthy NodeStatus = "healthy"
+	StatusFailed  NodeStatus = "failed"
+)
+
+// ExecutionEvent reports a node, or with Hook set a layer hook, changing
+// status or, when Line is set, a line of its output.
+type ExecutionEvent struct {
+	Time   time.Time
+	Layer  int   // zero-based index into the plan; for a hook, of the layer it follows
+	Node   *Node // nil for a hook
+	Hook   string
+	Status NodeStatus
+	Line   string
+	Err    error         // why the node or hook failed
+	Wait   time.Duration // with StatusWaiting, how long the node waits
+}
+
+// NodeRunner brings one node to the state the plan wants, writing its
+// output to out. It returns nil once the node is healthy.
+type NodeRunner func(ctx context.Context, node *Node, out io.Writer) error
+
+// Barrier is the point between two layers of a plan where hooks run.
+type Barrier struct {
+	Layer int     // zero-based index of the layer that has just become healthy
+	Done  []*Node // its nodes
+	Next  []*Node // the nodes of the layer about to start
+}
+
+// HookRunner runs hook at barrier b, writing its output to out. An error
+// stops the plan before the next layer.
+type HookRunner func(ctx context.Context, hook LayerHook, b Barrier, out io.Writer) error
+
+// HooksFor is the graph's hooks that run in mode: startup, shutdown or
+// restart.
+func (g *Graph) HooksFor(mode string) []LayerHook {
+	var hooks []LayerHook
+	for _, hook := range g.Hooks {
+		if len(hook.Modes) == 0 || slices.Contains(hook.Modes, mode) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// Follows reports whether the hook runs after the layer at zero-based
+// index layer.
+func (h LayerHook) Follows(layer int) bool {
+	return len(h.After) == 0 || slices.Contains(h.After, layer+1)
+}
+
+// ExecutePlan runs a plan from GetStartupOrder, GetShutdownOrder or a
+// subgraph: the nodes of a layer run concurrently, and the next layer starts
+// once all of them are healthy. A node with a delay after a dependency that
+// became healthy earlier in the plan waits it out before starting. After a
+// layer with failures it stops, leaving later nodes pending. report is
+// called for every event, one at a time.
+func ExecutePlan(ctx context.Context, plan [][]*Node, run NodeRunner, report func(ExecutionEvent)) error {
+	return ExecutePlanWithHooks(ctx, plan, nil, run, nil, report)
+}
+
+// ExecutePlanWithHooks is ExecutePlan with hooks run at the barriers between
+// layers: once a layer is healthy, the hooks that follow it run one at a
+// time, in the order given, before the next layer starts. A failing hook
+// stops the plan like a failing node does. Nothing runs after the last layer.
+func ExecutePlanWithHooks(ctx context.Context, plan [][]*Node, hooks []LayerHook, run NodeRunner, runHook HookRunner, report func(ExecutionEvent)) error {
+	var mu sync.Mutex
+	emit := func(e ExecutionEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		e.Time = time.Now()
+		report(e)
+	}
+
+	var healthyMu sync.Mutex
+	healthyAt := make(map[string]time.Time) // when each node became healthy
+
+	for i, layer := range plan {
+		var wg sync.WaitGroup
+		var failedMu sync.Mutex
+		var failed []string
+		for _, node := range layer {
+			wg.Add(1)
+			go func(node *Node) {
+				defer wg.Done()
+				healthyMu.Lock()
+				wait := time.Until(startAfter(node, healthyAt))
+				healthyMu.Unlock()
+				if wait > 0 {
+					emit(ExecutionEvent{Layer: i, Node: node, Status: StatusWaiting, Wait: wait})
+					timer := time.NewTimer(wait)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						timer.Stop()
+						failedMu.Lock()
+						failed = append(failed, node.ID)
+						failedMu.Unlock()
+						emit(ExecutionEvent{Layer: i, Node: node, Status: StatusFailed, Err: ctx.Err()})
+						return
+					}
+				}
+				emit(ExecutionEvent{Layer: i, Node: node, Status: StatusRunning})
+				out := &lineWriter{emit: func(line string) {
+					emit(ExecutionEvent{Layer: i, Node: node, Status: StatusRunning, Line: line})
+				}}
+				err := run(ctx, node, out)
+				out.flush()
+				if err != nil {
+					failedMu.Lock()
+					failed = append(failed, node.ID)
+					failedMu.Unlock()
+					emit(ExecutionEvent{Layer: i, Node: node, Status: StatusFailed, Err: err})
+					return
+				}
+				healthyMu.Lock()
+				healthyAt[node.ID] = time.Now()
+				healthyMu.Unlock()
+				emit(ExecutionEvent{Layer: i, Node: node, Status: StatusHealthy})
+			}(node)
+		}
+		wg.Wait()
+		if len(failed) > 0 {
+			sort.Strings(failed)
+			return fmt.Errorf("layer %d: %d node(s) failed: %s", i+1, len(failed), strings.Join(failed, ", "))
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i == len(plan)-1 || runHook == nil {
+			continue
+		}
+		barrier := Barrier{Layer: i, Done: layer, Next: plan[i+1]}
+		for _, hook := range hooks {
+			if !hook.Follows(i) {
+				continue
+			}
+			emit(ExecutionEvent{Layer: i, Hook: hook.Name, Status: StatusRunning})
+			out := &lineWriter{emit: func(line string) {
+				emit(ExecutionEvent{Layer: i, Hook: hook.Name, Status: StatusRunning, Line: line})
+			}}
+			err := runHook(ctx, hook, barrier, out)
+			out.flush()
+			if err != nil {
+				emit(ExecutionEvent{Layer: i, Hook: hook.Name, Status: StatusFailed, Err: err})
+				return fmt.Errorf("after layer %d: hook %q failed: %w", i+1, hook.Name, err)
+			}
+			emit(ExecutionEvent{Layer: i, Hook: hook.Name, Status: StatusHealthy})
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// startAfter is when node may start: the latest of its dependencies'
+// healthy times plus their delays. Dependencies that are not healthy yet,
+// such as those a shutdown plan stops after the node, do not hold it up.
+func startAfter(node *Node, healthyAt map[string]time.Time) time.Time {
+	var at time.Time
+	for id, delay := range node.Delays {
+		if healthy, ok := healthyAt[id]; ok && healthy.Add(delay).After(at) {
+			at = healthy.Add(delay)
+		}
+	}
+	return at
+}
+
+// lineWriter passes each complete line written to it to emit.
+type lineWriter struct {
+	buf  bytes.Buffer
+	emit func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadString('\n')
+		if err != nil {
+			w.buf.WriteString(line) // incomplete, wait for the rest
+			return len(p), nil
+		}
+		w.emit(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+func (w *lineWriter) flush() {
+	if w.buf.Len() > 0 {
+		w.emit(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+// END FILE: execute.go
+
+// ------------------------------------------------------------------
+
+// FILE: audit.go
+// This new file keeps an audit log of executed plans, one JSON object per
+// finished node or hook, and estimates new plans from it.
+package topology
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// AuditEntry is a node or layer hook that finished while a plan executed.
+type AuditEntry struct {
+	Time    time.Time  `json:"time"` // when it finished
+	Mode    string     `json:"mode"` // startup, shutdown or restart
+	Node    string     `json:"node,omitempty"`
+	BaseApp string     `json:"base_app,omitempty"`
+	Hook    string     `json:"hook,omitempty"`
+	Layer   int        `json:"layer"` // from 1; for a hook, the layer it follows
+	Status  NodeStatus `json:"status"`
+	Seconds float64    `json:"seconds"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// AuditLog writes an AuditEntry for every node and hook of an executed plan
+// that finishes. Record is meant to be called from ExecutePlan's report,
+// which is called one event at a time.
+type AuditLog struct {
+	w       io.Writer
+	mode    string
+	started map[string]time.Time
+}
+
+// NewAuditLog returns an AuditLog writing the entries of a plan executed in
+// mode to w.
+func NewAuditLog(w io.Writer, mode string) *AuditLog {
+	return &AuditLog{w: w, mode: mode, started: make(map[string]time.Time)}
+}
+
+// Record notes when a node or hook starts and writes its entry when it ends.
+func (a *AuditLog) Record(e ExecutionEvent) error {
+	if e.Line != "" {
+		return nil
+	}
+	key := fmt.Sprintf("hook %d %s", e.Layer, e.Hook)
+	if e.Node != nil {
+		key = "node " + e.Node.ID
+	}
+	if e.Status == StatusRunning {
+		a.started[key] = e.Time
+		return nil
+	}
+	start, ok := a.started[key]
+	if !ok {
+		return nil
+	}
+	delete(a.started, key)
+
+	entry := AuditEntry{
+		Time:    e.Time.UTC(),
+		Mode:    a.mode,
+		Hook:    e.Hook,
+		Layer:   e.Layer + 1,
+		Status:  e.Status,
+		Seconds: e.Time.Sub(start).Seconds(),
+	}
+	if e.Node != nil {
+		entry.Node, entry.BaseApp = e.Node.ID, e.Node.BaseApp
+	}
+	if e.Err != nil {
+		entry.Error = e.Err.Error()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Write(append(line, '\n'))
+	return err
+}
+
+// ReadAuditLog reads the entries of an audit log.
+func ReadAuditLog(r io.Reader) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("audit log line %d: %w", n, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// DurationStats sums up how long past runs took.
+type DurationStats struct {
+	Runs     int
+	P50, P95 time.Duration
+}
+
+func statsOf(seconds []float64) DurationStats {
+	if len(seconds) == 0 {
+		return DurationStats{}
+	}
+	sorted := append([]float64(nil), seconds...)
+	sort.Float64s(sorted)
+	// nearest rank
+	at := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		return time.Duration(sorted[max(i, 0)] * float64(time.Second))
+	}
+	return DurationStats{Runs: len(sorted), P50: at(0.50), P95: at(0.95)}
+}
+
+// NodeEstimate is how long a node of a plan took before. A node run fewer
+// than once is estimated from the other nodes of its app, e.g. a new shard
+// from the old ones; with no history at all, Runs is 0.
+type NodeEstimate struct {
+	Node *Node
+	DurationStats
+	FromApp bool // the stats are of Node.BaseApp's nodes
+	// Delay is the longest delay after a dependency in an earlier layer,
+	// added to the node's time as if that dependency finished its layer.
+	Delay time.Duration
+}
+
+// HookEstimate is how long a hook that follows a layer took before.
+type HookEstimate struct {
+	Hook string
+	DurationStats
+}
+
+// LayerEstimate is a layer of the plan: its nodes run concurrently, so it
+// takes as long as its slowest node, delay included, and then its hooks one
+// after another.
+type LayerEstimate struct {
+	Nodes    []NodeEstimate
+	Hooks    []HookEstimate
+	P50, P95 time.Duration
+}
+
+// PlanEstimate annotates a plan with past durations. The totals add the
+// layers up, so P95 is the plan taking as long as it did in the slowest
+// runs at every layer at once: a pessimistic bound.
+type PlanEstimate struct {
+	Layers   []LayerEstimate
+	P50, P95 time.Duration
+	Unknown  int // nodes and hooks with no history, left out of the totals
+}
+
+// EstimatePlan estimates plan, with hooks between its layers, from the
+// healthy runs of mode in entries.
+func EstimatePlan(plan [][]*Node, hooks []LayerHook, mode string, entries []AuditEntry) PlanEstimate {
+	byNode := make(map[string][]float64)
+	byApp := make(map[string][]float64)
+	byHook := make(map[string][]float64)
+	for _, e := range entries {
+		if e.Mode != mode || e.Status != StatusHealthy {
+			continue
+		}
+		switch {
+		case e.Hook != "":
+			byHook[e.Hook] = append(byHook[e.Hook], e.Seconds)
+		case e.Node != "":
+			byNode[e.Node] = append(byNode[e.Node], e.Seconds)
+			byApp[e.BaseApp] = append(byApp[e.BaseApp], e.Seconds)
+		}
+	}
+
+	var est PlanEstimate
+	earlier := make(map[string]bool) // the nodes of the layers so far
+	for i, layer := range plan {
+		var le LayerEstimate
+		for _, node := range layer {
+			ne := NodeEstimate{Node: node, DurationStats: statsOf(byNode[node.ID])}
+			if ne.Runs == 0 {
+				ne.DurationStats, ne.FromApp = statsOf(byApp[node.BaseApp]), true
+			}
+			if ne.Runs == 0 {
+				est.Unknown++
+			}
+			for id, delay := range node.Delays {
+				if earlier[id] {
+					ne.Delay = max(ne.Delay, delay)
+				}
+			}
+			le.P50, le.P95 = max(le.P50, ne.Delay+ne.P50), max(le.P95, ne.Delay+ne.P95)
+			le.Nodes = append(le.Nodes, ne)
+		}
+		for _, node := range layer {
+			earlier[node.ID] = true
+		}
+		for _, hook := range hooks {
+			if i == len(plan)-1 || !hook.Follows(i) {
+				continue
+			}
+			he := HookEstimate{Hook: hook.Name, DurationStats: statsOf(byHook[hook.Name])}
+			if he.Runs == 0 {
+				est.Unknown++
+			}
+			le.P50 += he.P50
+			le.P95 += he.P95
+			le.Hooks = append(le.Hooks, he)
+		}
+		est.P50 += le.P50
+		est.P95 += le.P95
+		est.Layers = append(est.Layers, le)
+	}
+	return est
+}
+
+// END FILE: audit.go
+
+// ------------------------------------------------------------------
+
+// FILE: freeze.go
+// This new file freezes nodes for change management: from a freeze file of
+// windows, on top of the topology's frozen apps, so plans leave them alone.
+package topology
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FreezeFile is the freezes change management keeps apart from the
+// topology, e.g. a quarter-end freeze.
+type FreezeFile struct {
+	Freezes []Freeze `yaml:"freezes"`
+}
+
+// Freeze is a window in which plans may not start, stop or restart some
+// apps, nodes or hosts.
+type Freeze struct {
+	Reason     string    `yaml:"reason"`
+	Apps       []string  `yaml:"apps"`
+	Nodes      []string  `yaml:"nodes"`
+	HostGroups []string  `yaml:"host_groups"` // every node on those hosts
+	From       time.Time `yaml:"from"`        // zero means it is in force already
+	Until      time.Time `yaml:"until"`       // zero means until it is removed
+}
+
+// ActiveAt reports whether the freeze is in force at t.
+func (f Freeze) ActiveAt(t time.Time) bool {
+	return (f.From.IsZero() || !t.Before(f.From)) && (f.Until.IsZero() || t.Before(f.Until))
+}
+
+// ParseFreezeYAML reads a freeze file. Every freeze needs a reason, for the
+// plans it holds up to show, and something to freeze.
+func ParseFreezeYAML(data []byte) (FreezeFile, error) {
+	var f FreezeFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil {
+		return FreezeFile{}, fmt.Errorf("freeze file schema validation failed: %w", err)
+	}
+	for i, freeze := range f.Freezes {
+		switch {
+		case strings.TrimSpace(freeze.Reason) == "":
+			return FreezeFile{}, fmt.Errorf("freeze %d has no reason", i+1)
+		case len(freeze.Apps)+len(freeze.Nodes)+len(freeze.HostGroups) == 0:
+			return FreezeFile{}, fmt.Errorf("freeze '%s' freezes nothing", freeze.Reason)
+		case !freeze.From.IsZero() && !freeze.Until.IsZero() && !freeze.Until.After(freeze.From):
+			return FreezeFile{}, fmt.Errorf("freeze '%s' ends before it starts", freeze.Reason)
+		}
+	}
+	return f, nil
+}
+
+// ApplyFreezes freezes the nodes of g that the freezes in force at now
+// name, adding their reasons to any the node has. Apply them to the whole
+// topology, before taking a logical graph or a subgraph: a name matching
+// none of its nodes is an error, as a typo would quietly freeze nothing.
+func (g *Graph) ApplyFreezes(f FreezeFile, now time.Time) error {
+	apps := make(map[string][]*Node)
+	groups := make(map[string][]*Node)
+	for _, node := range g.Nodes {
+		apps[node.BaseApp] = append(apps[node.BaseApp], node)
+		if node.HostGroup != nil {
+			groups[node.HostGroup.ID] = append(groups[node.HostGroup.ID], node)
+		}
+	}
+	var errs []error
+	for _, freeze := range f.Freezes {
+		var frozen []*Node
+		for _, app := range freeze.Apps {
+			if _, ok := apps[app]; !ok {
+				errs = append(errs, fmt.Errorf("freeze '%s' names unknown app '%s'", freeze.Reason, app))
+			}
+			frozen = append(frozen, apps[app]...)
+		}
+		for _, id := range freeze.Nodes {
+			node, ok := g.Nodes[id]
+			if !ok {
+				errs = append(errs, fmt.Errorf("freeze '%s' names unknown node '%s'", freeze.Reason, id))
+				continue
+			}
+			frozen = append(frozen, node)
+		}
+		for _, id := range freeze.HostGroups {
+			if _, ok := groups[id]; !ok {
+				errs = append(errs, fmt.Errorf("freeze '%s' names unknown host group '%s'", freeze.Reason, id))
+			}
+			frozen = append(frozen, groups[id]...)
+		}
+		if !freeze.ActiveAt(now) {
+			continue
+		}
+		for _, node := range frozen {
+			switch {
+			case node.Frozen == "":
+				node.Frozen = freeze.Reason
+			case !strings.Contains(node.Frozen, freeze.Reason):
+				node.Frozen += "; " + freeze.Reason
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FrozenNodes is the frozen nodes of a plan, in plan order.
+func FrozenNodes(plan [][]*Node) []*Node {
+	var frozen []*Node
+	for _, layer := range plan {
+		for _, node := range layer {
+			if node.Frozen != "" {
+				frozen = append(frozen, node)
+			}
+		}
+	}
+	return frozen
+}
+
+// WithoutFrozen is plan without its frozen nodes, which are left as they
+// are. A layer left empty stays, so the layers keep their numbers and the
+// hooks that follow them.
+func WithoutFrozen(plan [][]*Node) [][]*Node {
+	thawed := make([][]*Node, len(plan))
+	for i, layer := range plan {
+		thawed[i] = []*Node{}
+		for _, node := range layer {
+			if node.Frozen == "" {
+				thawed[i] = append(thawed[i], node)
+			}
+		}
+	}
+	return thawed
+}
+
+// END FILE: freeze.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/yaml2dot/main.go
+// This tool is updated to support logical views and co-location clustering.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"yourcorp/topology"
+)
+
+// fileList is a flag that may be given more than once.
+type fileList []string
+
+func (f *fileList) String() string     { return strings.Join(*f, ",") }
+func (f *fileList) Set(v string) error { *f = append(*f, v); return nil }
+
+func main() {
+	var files fileList
+	flag.Var(&files, "f", "Topology YAML (or, with -projects, JSON) file to read; - or none reads stdin.")
+	format := flag.String("T", "dot", "Output format (e.g., dot, svg, png, or graphml and cytoscape for yEd and Cytoscape); defaults to the extension of -o.")
+	outPath := flag.String("o", "", "File to write the output to instead of stdout.")
+	open := flag.Bool("open", false, "Open the output in the default viewer; without -o it goes to a temporary file.")
+	view := flag.String("view", "concrete", "Graph view: 'concrete' (default) or 'logical'.")
+	focus := flag.String("focus", "", "Node (app in the logical view) to highlight, with its dependencies and dependents; the rest is dimmed.")
+	up := flag.Int("up", -1, "With -focus, how many hops of dependencies to highlight; -1 is all.")
+	down := flag.Int("down", -1, "With -focus, how many hops of dependents to highlight; -1 is all.")
+	layers := flag.Bool("layers", false, "Draw each startup layer on its own rank, in boot order from the top.")
+	projects := flag.Bool("projects", false, "Read depgraph's projects.json instead of a topology: the deployables and their dependencies.")
+	libraries := flag.Bool("libraries", false, "With -projects, draw the libraries too rather than only the deployables.")
+	maxShards := flag.Int("max-shards", topology.DefaultMaxShards, "Most shards an app may have.")
+	flag.Parse()
+
+	formatSet := false
+	flag.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "T" })
+	if ext := strings.TrimPrefix(filepath.Ext(*outPath), "."); !formatSet && ext != "" {
+		*format = ext
+		if ext == "json" {
+			*format = "cytoscape"
+		}
+	}
+	editorFormat := *format == "graphml" || *format == "cytoscape"
+	if editorFormat && (*focus != "" || *layers) {
+		fmt.Fprintf(os.Stderr, "Error: -focus and -layers only apply to Graphviz output, not %s.\n", *format)
+		os.Exit(1)
+	}
+
+	data, err := readTopology(files)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var graph *topology.Graph
+	if *projects {
+		graph, err = topology.ParseProjectsJSON(data, topology.ProjectsOptions{Libraries: *libraries})
+	} else {
+		graph, err = topology.ParseYAMLWithOptions(data, topology.ParseOptions{MaxShards: *maxShards})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing topology: %v\n", err)
+		os.Exit(1)
+	}
+	opts := topology.DOTOptions{ShowCoLocation: true, Focus: *focus, Up: *up, Down: *down, RankByLayer: *layers}
+	if *view == "logical" {
+		graph, err = graph.LogicalGraph()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating logical graph: %v\n", err)
+			os.Exit(1)
+		}
+		opts.ShowCoLocation = false
+	}
+	exportOpts := topology.ExportOptions{ShowCoLocation: opts.ShowCoLocation}
+	var output, outputName string
+	switch *format {
+	case "graphml":
+		output, err = graph.GraphML(exportOpts)
+		outputName = "GraphML"
+	case "cytoscape":
+		output, err = graph.CytoscapeJSON(exportOpts)
+		outputName = "Cytoscape"
+	default:
+		output, err = graph.DOT(opts)
+		outputName = "DOT"
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering %s graph: %v\n", outputName, err)
+		os.Exit(1)
+	}
+
+	rendered := []byte(output)
+	if *format != "dot" && !editorFormat {
+		cmd := exec.Command("dot", "-T"+*format)
+		cmd.Stdin = strings.NewReader(output)
+		cmd.Stderr = os.Stderr
+		if rendered, err = cmd.Output(); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				fmt.Fprintln(os.Stderr, "Error: 'dot' command not found. Please install Graphviz.")
+			} else {
+				fmt.Fprintf(os.Stderr, "Error executing 'dot' command: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	}
+
+	if *outPath == "" && !*open {
+		os.Stdout.Write(rendered)
+		return
+	}
+	path := *outPath
+	if path == "" {
+		tmp, err := os.CreateTemp("", "yaml2dot-*."+*format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating temporary file: %v\n", err)
+			os.Exit(1)
+		}
+		tmp.Close()
+		path = tmp.Name()
+	}
+	if err := os.WriteFile(path, rendered, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if *open {
+		if err := openFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// readTopology reads the topology from files, or stdin for none or "-".
+// Topologies are one file until includes or overlays exist to combine
+// several.
+func readTopology(files []string) ([]byte, error) {
+	switch {
+	case len(files) > 1:
+		return nil, fmt.Errorf("got %d -f files, but a topology is read from one", len(files))
+	case len(files) == 0 || files[0] == "-":
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading from stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		return nil, fmt.Errorf("reading file %s: %w", files[0], err)
+	}
+	return data, nil
+}
+
+// openFile opens path in the desktop's default application for it, without
+// waiting for the viewer to exit.
+func openFile(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return cmd.Start()
+}
+
+// END FILE: cmd/yaml2dot/main.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/orchestrator/main.go
+// This tool is updated to support logical views.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+	"yourcorp/topology"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serve(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	filePath := flag.String("file", "topology.yaml", "Path to the topology YAML file.")
+	mode := flag.String("mode", "startup", "Orchestration mode: startup, shutdown, restart, or estimate to annotate the -plan with past durations from the -audit-log.")
+	plan := flag.String("plan", "startup", "With -mode estimate, the plan to estimate: startup, shutdown, or restart.")
+	target := flag.String("target", "", "The target node ID for restart mode (e.g., 'sor-01').")
+	view := flag.String("view", "concrete", "Plan view: 'concrete' (default) or 'logical'.")
+	execCmd := flag.String("exec", "", "Execute the plan: shell command run for each node with MODE, NODE_ID, BASE_APP, SHARD, HOST_GROUP and OWNER set; exit status 0 means healthy.")
+	useTUI := flag.Bool("tui", false, "With -exec, show a live dashboard instead of plain logs (only when stdout is a terminal).")
+	skipHooks := flag.Bool("skip-hooks", false, "With -exec, do not run the topology's hooks between layers.")
+	maxShards := flag.Int("max-shards", topology.DefaultMaxShards, "Most shards an app may have.")
+	auditLog := flag.String("audit-log", "orchestrator-audit.jsonl", "File -exec appends every node's and hook's duration to, and -mode estimate reads; empty disables it.")
+	freezeFile := flag.String("freeze-file", "", "YAML file of change freezes, on top of the topology's frozen apps.")
+	skipFrozen := flag.Bool("skip-frozen", false, "Leave frozen nodes out of the plan.")
+	overrideFreeze := flag.Bool("override-freeze", false, "Let -exec start, stop or restart frozen nodes.")
+	flag.Parse()
+	if *skipFrozen && *overrideFreeze {
+		fmt.Fprintln(os.Stderr, "Error: -skip-frozen and -override-freeze are mutually exclusive.")
+		os.Exit(1)
+	}
+	planMode := *mode
+	if *mode == "estimate" {
+		if *execCmd != "" {
+			fmt.Fprintln(os.Stderr, "Error: -mode estimate only reads the audit log; run -exec with the plan's mode.")
+			os.Exit(1)
+		}
+		planMode = *plan
+	}
+	yamlData, err := os.ReadFile(*filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", *filePath, err)
+		os.Exit(1)
+	}
+	graph, err := topology.ParseYAMLWithOptions(yamlData, topology.ParseOptions{MaxShards: *maxShards})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing topology: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyFreezeFile(graph, *freezeFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying freeze file %s: %v\n", *freezeFile, err)
+		os.Exit(1)
+	}
+	if *view == "logical" {
+		if planMode == "restart" {
+			fmt.Fprintln(os.Stderr, "Error: restart mode is not compatible with logical view.")
+			os.Exit(1)
+		}
+		graph, err = graph.LogicalGraph()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating logical graph: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	verb := "Generating"
+	if *mode == "estimate" {
+		verb = "Estimating"
+	}
+	planName, order, err := buildPlan(graph, planMode, *target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *skipFrozen {
+		order = topology.WithoutFrozen(order)
+	}
+	if planMode == "restart" {
+		fmt.Printf("--- %s Targeted Restart Plan for Host Group of: %s ---\n", verb, *target)
+	} else {
+		fmt.Printf("--- %s %s %s Plan ---\n", verb, strings.Title(*view), planName)
+	}
+	hooks := graph.HooksFor(planMode)
+	if *mode == "estimate" {
+		entries, err := readAuditLog(*auditLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading audit log: %v\n", err)
+			os.Exit(1)
+		}
+		printEstimate(planName, topology.EstimatePlan(order, hooks, planMode, entries))
+		return
+	}
+	printOrder(planName, order, hooks)
+
+	if *execCmd == "" {
+		return
+	}
+	if frozen := topology.FrozenNodes(order); len(frozen) > 0 {
+		if !*overrideFreeze {
+			fmt.Fprintf(os.Stderr, "Error: the plan touches %d frozen node(s); pass -skip-frozen to leave them out or -override-freeze to go ahead.\n", len(frozen))
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: overriding the freeze of %d node(s).\n", len(frozen))
+	}
+	if *skipHooks {
+		hooks = nil
+	}
+	record, err := openAuditLog(*auditLog, *mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening audit log: %v\n", err)
+		os.Exit(1)
+	}
+	run, runHook := commandRunner(*execCmd, *mode), hookRunner(*mode)
+	if *useTUI && isTerminal(os.Stdout) {
+		err = runDashboard(planName, order, hooks, run, runHook, record)
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err = topology.ExecutePlanWithHooks(ctx, order, hooks, run, runHook, func(e topology.ExecutionEvent) {
+			record(e)
+			logEvent(e)
+		})
+		stop()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error executing plan: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// buildPlan is the plan for mode over graph: startup, shutdown, or restart
+// of target's host group.
+func buildPlan(graph *topology.Graph, mode, target string) (string, [][]*topology.Node, error) {
+	switch mode {
+	case "startup":
+		return "Startup", topology.GetStartupOrder(graph), nil
+	case "shutdown":
+		return "Shutdown", topology.GetShutdownOrder(graph), nil
+	case "restart":
+		if target == "" {
+			return "", nil, errors.New("a target is required for restart mode")
+		}
+		subgraph, err := topology.GetSubgraphFor(graph, target)
+		if err != nil {
+			return "", nil, fmt.Errorf("generating subgraph: %w", err)
+		}
+		return "Restart", topology.GetStartupOrder(subgraph), nil
+	}
+	return "", nil, fmt.Errorf("invalid mode %q", mode)
+}
+
+// applyFreezeFile freezes the nodes of graph that the freeze file at path
+// has in force now; it does nothing for no path.
+func applyFreezeFile(graph *topology.Graph, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	freezes, err := topology.ParseFreezeYAML(data)
+	if err != nil {
+		return err
+	}
+	return graph.ApplyFreezes(freezes, time.Now())
+}
+
+// commandRunner runs command through sh for each node, telling it which node
+// through the environment.
+func commandRunner(command, mode string) topology.NodeRunner {
+	return func(ctx context.Context, node *topology.Node, out io.Writer) error {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Env = append(os.Environ(),
+			"MODE="+mode,
+			"NODE_ID="+node.ID,
+			"BASE_APP="+node.BaseApp,
+			fmt.Sprintf("SHARD=%d", node.Shard),
+			"HOST_GROUP="+node.HostGroupID(),
+			"OWNER="+node.Owner,
+		)
+		cmd.Stdout, cmd.Stderr = out, out
+		return cmd.Run()
+	}
+}
+
+// hookRunner runs a layer hook through sh. Like -exec it gets MODE, and
+// the barrier as LAYER (the one just finished, from 1), LAYER_NODES and
+// NEXT_NODES (space-separated node IDs), plus HOOK; the same as a JSON
+// hookInput on stdin.
+func hookRunner(mode string) topology.HookRunner {
+	return func(ctx context.Context, hook topology.LayerHook, b topology.Barrier, out io.Writer) error {
+		input, err := json.Marshal(hookInput{
+			Mode:  mode,
+			Hook:  hook.Name,
+			Layer: b.Layer + 1,
+			Done:  describeNodes(b.Done),
+			Next:  describeNodes(b.Next),
+		})
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, "sh", "-c", hook.Run)
+		cmd.Env = append(os.Environ(),
+			"MODE="+mode,
+			"HOOK="+hook.Name,
+			fmt.Sprintf("LAYER=%d", b.Layer+1),
+			"LAYER_NODES="+nodeIDs(b.Done),
+			"NEXT_NODES="+nodeIDs(b.Next),
+		)
+		cmd.Stdin = bytes.NewReader(append(input, '\n'))
+		cmd.Stdout, cmd.Stderr = out, out
+		return cmd.Run()
+	}
+}
+
+// hookInput is what a hook reads on stdin.
+type hookInput struct {
+	Mode  string     `json:"mode"`
+	Hook  string     `json:"hook"`
+	Layer int        `json:"layer"` // the layer just finished, from 1
+	Done  []hookNode `json:"layer_nodes"`
+	Next  []hookNode `json:"next_nodes"`
+}
+
+type hookNode struct {
+	ID        string `json:"id"`
+	BaseApp   string `json:"base_app"`
+	Shard     int    `json:"shard"`
+	HostGroup string `json:"host_group,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Frozen    string `json:"frozen,omitempty"` // why it is frozen
+}
+
+func describeNodes(nodes []*topology.Node) []hookNode {
+	described := make([]hookNode, len(nodes))
+	for i, node := range nodes {
+		described[i] = hookNode{ID: node.ID, BaseApp: node.BaseApp, Shard: node.Shard, HostGroup: node.HostGroupID(), Owner: node.Owner, Frozen: node.Frozen}
+	}
+	return described
+}
+
+func nodeIDs(nodes []*topology.Node) string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	return strings.Join(ids, " ")
+}
+
+// openAuditLog returns a report function appending the entries of a plan
+// executed in mode to the audit log at path; it does nothing for no path.
+// A failed write is reported once and ends the log, not the plan.
+func openAuditLog(path, mode string) (func(topology.ExecutionEvent), error) {
+	if path == "" {
+		return func(topology.ExecutionEvent) {}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	audit, failed := topology.NewAuditLog(f, mode), false
+	return func(e topology.ExecutionEvent) {
+		if failed {
+			return
+		}
+		if err := audit.Record(e); err != nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "Warning: no longer writing audit log %s: %v\n", path, err)
+		}
+	}, nil
+}
+
+// readAuditLog reads the audit log at path; a log that does not exist yet
+// has no entries.
+func readAuditLog(path string) ([]topology.AuditEntry, error) {
+	if path == "" {
+		return nil, errors.New("-mode estimate needs an -audit-log")
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return topology.ReadAuditLog(f)
+}
+
+// printEstimate prints a plan annotated with how long its nodes and hooks
+// took in past runs.
+func printEstimate(planName string, est topology.PlanEstimate) {
+	if len(est.Layers) == 0 {
+		fmt.Println("  No operations required.")
+		return
+	}
+	for i, layer := range est.Layers {
+		fmt.Printf("  %s Layer %d (Concurrent): %s\n", planName, i+1, formatDurations(layer.P50, layer.P95))
+		for _, n := range layer.Nodes {
+			runs := fmt.Sprintf("(%d runs)", n.Runs)
+			if n.FromApp {
+				runs = fmt.Sprintf("(%d runs of %s)", n.Runs, n.Node.BaseApp)
+			}
+			history := formatHistory(n.DurationStats, runs)
+			if n.Delay > 0 {
+				history = fmt.Sprintf("%s, after a %s delay", history, n.Delay)
+			}
+			fmt.Printf("    %-30s %s\n", n.Node.ID, history)
+		}
+		for _, h := range layer.Hooks {
+			fmt.Printf("    then hook: %-19s %s\n", h.Hook, formatHistory(h.DurationStats, fmt.Sprintf("(%d runs)", h.Runs)))
+		}
+	}
+	fmt.Printf("  Total: %s", formatDurations(est.P50, est.P95))
+	if est.Unknown > 0 {
+		fmt.Printf(", not counting %d node(s) and hook(s) without history", est.Unknown)
+	}
+	fmt.Println()
+}
+
+func formatHistory(s topology.DurationStats, runs string) string {
+	if s.Runs == 0 {
+		return "no history"
+	}
+	return formatDurations(s.P50, s.P95) + " " + runs
+}
+
+func formatDurations(p50, p95 time.Duration) string {
+	return fmt.Sprintf("p50 %s, p95 %s", p50.Round(100*time.Millisecond), p95.Round(100*time.Millisecond))
+}
+
+// logEvent is the plain-log fallback for the dashboard.
+func logEvent(e topology.ExecutionEvent) {
+	ts := e.Time.Format("15:04:05")
+	if e.Hook != "" {
+		switch {
+		case e.Line != "":
+			fmt.Printf("%s [hook %s] %s\n", ts, e.Hook, e.Line)
+		case e.Err != nil:
+			fmt.Printf("%s After layer %d hook %q %s: %v\n", ts, e.Layer+1, e.Hook, e.Status, e.Err)
+		default:
+			fmt.Printf("%s After layer %d hook %q %s\n", ts, e.Layer+1, e.Hook, e.Status)
+		}
+		return
+	}
+	switch {
+	case e.Line != "":
+		fmt.Printf("%s [%s] %s\n", ts, e.Node.ID, e.Line)
+	case e.Err != nil && e.Node.Owner != "":
+		fmt.Printf("%s Layer %d %s %s (owner %s): %v\n", ts, e.Layer+1, e.Node.ID, e.Status, e.Node.Owner, e.Err)
+	case e.Err != nil:
+		fmt.Printf("%s Layer %d %s %s: %v\n", ts, e.Layer+1, e.Node.ID, e.Status, e.Err)
+	case e.Status == topology.StatusWaiting:
+		fmt.Printf("%s Layer %d %s waiting %s for its dependencies to warm up\n", ts, e.Layer+1, e.Node.ID, e.Wait.Round(time.Second))
+	default:
+		fmt.Printf("%s Layer %d %s %s\n", ts, e.Layer+1, e.Node.ID, e.Status)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func printOrder(planName string, order [][]*topology.Node, hooks []topology.LayerHook) {
+	if len(order) == 0 {
+		fmt.Println("  No operations required.")
+		return
+	}
+	for i, layer := range order {
+		var nodeIDs []string
+		for _, node := range layer {
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+		nodes := fmt.Sprintf("[ %s ]", strings.Join(nodeIDs, ", "))
+		if len(layer) == 0 {
+			nodes = "[ ] (all frozen)" // only -skip-frozen empties a layer
+		}
+		fmt.Printf("  %s Layer %d (Concurrent): %s\n", planName, i+1, nodes)
+		if owners := topology.LayerOwners(layer); len(owners) > 0 {
+			fmt.Printf("    owners: %s\n", strings.Join(owners, ", "))
+		}
+		for _, node := range topology.FrozenNodes([][]*topology.Node{layer}) {
+			fmt.Printf("    frozen: %s (%s)\n", node.ID, node.Frozen)
+		}
+		if i == len(order)-1 {
+			continue
+		}
+		for _, hook := range hooks {
+			if hook.Follows(i) {
+				fmt.Printf("    then hook: %s\n", hook.Name)
+			}
+		}
+	}
+	printTeams(topology.PlanByOwner(order))
+}
+
+// printTeams is the plan summary by owner, for whoever runs the plan to know
+// who to call when a layer stalls.
+func printTeams(teams []topology.TeamPlan) {
+	if len(teams) == 0 {
+		return
+	}
+	fmt.Println("--- By Team ---")
+	for _, team := range teams {
+		owner := team.Owner
+		if owner == "" {
+			owner = "no owner"
+		}
+		layers := make([]string, len(team.Layers))
+		for i, layer := range team.Layers {
+			layers[i] = fmt.Sprint(layer + 1)
+		}
+		noun := "layer"
+		if len(layers) > 1 {
+			noun = "layers"
+		}
+		fmt.Printf("  %s in %s %s: [ %s ]\n", owner, noun, strings.Join(layers, ", "), strings.Join(team.Nodes, ", "))
+	}
+}
+
+// END FILE: cmd/orchestrator/main.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/orchestrator/dashboard.go
+// This new file is the live TUI shown while -exec runs a plan with -tui.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"yourcorp/topology"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	headingStyle = lipgloss.NewStyle().Bold(true)
+	helpStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	statusStyles = map[topology.NodeStatus]lipgloss.Style{
+		topology.StatusPending: lipgloss.NewStyle().Foreground(lipgloss.Color("241")),
+		topology.StatusWaiting: lipgloss.NewStyle().Foreground(lipgloss.Color("111")),
+		topology.StatusRunning: lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		topology.StatusHealthy: lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		topology.StatusFailed:  lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
+	}
+	statusIcons = map[topology.NodeStatus]string{
+		topology.StatusPending: "·",
+		topology.StatusWaiting: "◷",
+		topology.StatusRunning: "…",
+		topology.StatusHealthy: "✔",
+		topology.StatusFailed:  "✖",
+	}
+)
+
+type eventMsg topology.ExecutionEvent
+
+type doneMsg struct{ err error }
+
+type tickMsg time.Time
+
+// dashboard shows a plan executing: a progress bar per layer, every node's
+// status and how long it took, and the nodes' output.
+type dashboard struct {
+	planName string
+	plan     [][]*topology.Node
+	status   map[string]topology.NodeStatus
+	started  map[string]time.Time
+	finished map[string]time.Time
+	start    time.Time
+	now      time.Time
+	logs     []string
+	hook     string // the hook running between layers, if any
+	nodeView viewport.Model
+	logView  viewport.Model
+	bar      progress.Model
+	cancel   context.CancelFunc
+	done     bool
+	err      error
+	width    int
+	height   int
+}
+
+// runDashboard executes plan, with hooks between its layers, behind the
+// dashboard and returns the execution's result once the user closes it.
+// record gets every event too, for the audit log.
+func runDashboard(planName string, plan [][]*topology.Node, hooks []topology.LayerHook, run topology.NodeRunner, runHook topology.HookRunner, record func(topology.ExecutionEvent)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := dashboard{
+		planName: planName,
+		plan:     plan,
+		status:   make(map[string]topology.NodeStatus),
+		started:  make(map[string]time.Time),
+		finished: make(map[string]time.Time),
+		start:    time.Now(),
+		now:      time.Now(),
+		nodeView: viewport.New(80, 10),
+		logView:  viewport.New(80, 10),
+		bar:      progress.New(progress.WithDefaultGradient(), progress.WithoutPercentage()),
+		cancel:   cancel,
+	}
+	for _, layer := range plan {
+		for _, node := range layer {
+			d.status[node.ID] = topology.StatusPending
+		}
+	}
+
+	p := tea.NewProgram(d, tea.WithAltScreen())
+	go func() {
+		err := topology.ExecutePlanWithHooks(ctx, plan, hooks, run, runHook, func(e topology.ExecutionEvent) {
+			record(e)
+			p.Send(eventMsg(e))
+		})
+		p.Send(doneMsg{err})
+	}()
+
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	result := final.(dashboard)
+	if !result.done {
+		return errors.New("execution aborted")
+	}
+	return result.err
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (d dashboard) Init() tea.Cmd { return tick() }
+
+func (d dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		d.width, d.height = msg.Width, msg.Height
+		d.bar.Width = min(40, max(d.width-30, 10))
+		rest := max(d.height-len(d.plan)-6, 4)
+		d.nodeView.Width, d.nodeView.Height = d.width, rest/2
+		d.logView.Width, d.logView.Height = d.width, rest-rest/2
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			d.cancel() // stops whatever is still running
+			return d, tea.Quit
+		}
+
+	case tickMsg:
+		d.now = time.Time(msg)
+		if d.done {
+			return d, nil
+		}
+		return d, tick()
+
+	case eventMsg:
+		e := topology.ExecutionEvent(msg)
+		d.now = e.Time
+		if e.Hook != "" {
+			d.hookEvent(e)
+			break
+		}
+		if e.Line != "" {
+			d.appendLog(fmt.Sprintf("%s [%s] %s", e.Time.Format("15:04:05"), e.Node.ID, e.Line))
+			break
+		}
+		d.status[e.Node.ID] = e.Status
+		switch e.Status {
+		case topology.StatusWaiting:
+			d.appendLog(fmt.Sprintf("%s [%s] waiting %s for its dependencies to warm up", e.Time.Format("15:04:05"), e.Node.ID, e.Wait.Round(time.Second)))
+		case topology.StatusRunning:
+			d.started[e.Node.ID] = e.Time
+		case topology.StatusFailed:
+			d.finished[e.Node.ID] = e.Time
+			if e.Node.Owner != "" {
+				d.appendLog(fmt.Sprintf("%s [%s] failed (owner %s): %v", e.Time.Format("15:04:05"), e.Node.ID, e.Node.Owner, e.Err))
+			} else {
+				d.appendLog(fmt.Sprintf("%s [%s] failed: %v", e.Time.Format("15:04:05"), e.Node.ID, e.Err))
+			}
+		case topology.StatusHealthy:
+			d.finished[e.Node.ID] = e.Time
+		}
+
+	case doneMsg:
+		d.done, d.err = true, msg.err
+		d.now = time.Now()
+		if msg.err != nil {
+			d.appendLog("Execution stopped: " + msg.err.Error())
+		} else {
+			d.appendLog("Execution finished.")
+		}
+	}
+
+	d.nodeView.SetContent(d.renderNodes())
+	var cmd tea.Cmd
+	d.nodeView, cmd = d.nodeView.Update(msg)
+	return d, cmd
+}
+
+// hookEvent logs a hook's progress; the title names it while it runs.
+func (d *dashboard) hookEvent(e topology.ExecutionEvent) {
+	ts := e.Time.Format("15:04:05")
+	switch {
+	case e.Line != "":
+		d.appendLog(fmt.Sprintf("%s [hook %s] %s", ts, e.Hook, e.Line))
+	case e.Status == topology.StatusRunning:
+		d.hook = e.Hook
+		d.appendLog(fmt.Sprintf("%s hook %q running after layer %d", ts, e.Hook, e.Layer+1))
+	case e.Status == topology.StatusFailed:
+		d.hook = ""
+		d.appendLog(fmt.Sprintf("%s hook %q failed: %v", ts, e.Hook, e.Err))
+	default:
+		d.hook = ""
+		d.appendLog(fmt.Sprintf("%s hook %q done", ts, e.Hook))
+	}
+}
+
+func (d *dashboard) appendLog(line string) {
+	d.logs = append(d.logs, line)
+	d.logView.SetContent(strings.Join(d.logs, "\n"))
+	d.logView.GotoBottom()
+}
+
+func (d dashboard) View() string {
+	if d.width == 0 {
+		return "Initializing..."
+	}
+
+	counts := make(map[topology.NodeStatus]int)
+	for _, status := range d.status {
+		counts[status]++
+	}
+	state := fmt.Sprintf("%s elapsed", d.now.Sub(d.start).Round(time.Second))
+	if d.done {
+		state = fmt.Sprintf("finished in %s", d.now.Sub(d.start).Round(time.Second))
+	} else if d.hook != "" {
+		state += ", hook " + d.hook
+	}
+	title := titleStyle.Render(fmt.Sprintf("%s plan: %d/%d healthy, %d running, %d failed, %s",
+		d.planName, counts[topology.StatusHealthy], len(d.status),
+		counts[topology.StatusRunning], counts[topology.StatusFailed], state))
+
+	var layers strings.Builder
+	for i, layer := range d.plan {
+		healthy := 0
+		for _, node := range layer {
+			if d.status[node.ID] == topology.StatusHealthy {
+				healthy++
+			}
+		}
+		done := 1.0 // a layer -skip-frozen emptied
+		if len(layer) > 0 {
+			done = float64(healthy) / float64(len(layer))
+		}
+		fmt.Fprintf(&layers, "Layer %-3d %s %d/%d", i+1, d.bar.ViewAs(done), healthy, len(layer))
+		if owners := topology.LayerOwners(layer); len(owners) > 0 {
+			layers.WriteString("  " + strings.Join(owners, ", "))
+		}
+		layers.WriteString("\n")
+	}
+
+	help := "↑/↓ scroll nodes, q abort and quit."
+	if d.done {
+		help = "q quit."
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		layers.String(),
+		d.nodeView.View(),
+		headingStyle.Render("Log"),
+		d.logView.View(),
+		helpStyle.Render(help),
+	)
+}
+
+// renderNodes lists every node in plan order with its status and duration.
+func (d dashboard) renderNodes() string {
+	var b strings.Builder
+	for i, layer := range d.plan {
+		for _, node := range layer {
+			status := d.status[node.ID]
+			line := fmt.Sprintf("%s %-30s L%-3d %-8s", statusIcons[status], node.ID, i+1, status)
+			if started, ok := d.started[node.ID]; ok {
+				end, finished := d.finished[node.ID]
+				if !finished {
+					end = d.now
+				}
+				line += " " + end.Sub(started).Round(100*time.Millisecond).String()
+			}
+			b.WriteString(statusStyles[status].Render(line) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// END FILE: cmd/orchestrator/dashboard.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/orchestrator/serve.go
+// This new file is "orchestrator serve": plan, execute and status over JSON
+// and HTTP, for tools like the release portal that would otherwise shell out
+// to -exec and scrape its logs.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"yourcorp/topology"
+)
+
+// serve runs the HTTP API until interrupted:
+//
+//	GET  /v1/plan?mode=startup&target=&view=concrete&skip_frozen=false  the plan, as JSON
+//	POST /v1/executions {"mode": ..., "target": ..., "view": ..., "skip_frozen": ..., "override_freeze": ...}  start executing one
+//	GET  /v1/executions                               every execution since the server started
+//	GET  /v1/executions/{id}                          one execution's status and nodes
+//	GET  /v1/executions/{id}/events                   its events as NDJSON, replayed then followed
+//	POST /v1/executions/{id}/cancel                   stop it
+//
+// The topology is read again for every request, so edits need no restart.
+// The command run for each node is the server's -exec, never the client's,
+// and one plan executes at a time.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("orchestrator serve", flag.ExitOnError)
+	filePath := fs.String("file", "topology.yaml", "Path to the topology YAML file.")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on.")
+	execCmd := fs.String("exec", "", "Shell command run for each node of an executed plan, as with the -exec of a plan; without one, executions are refused.")
+	skipHooks := fs.Bool("skip-hooks", false, "Do not run the topology's hooks between layers.")
+	maxShards := fs.Int("max-shards", topology.DefaultMaxShards, "Most shards an app may have.")
+	auditLog := fs.String("audit-log", "orchestrator-audit.jsonl", "File every execution appends its nodes' and hooks' durations to; empty disables it.")
+	freezeFile := fs.String("freeze-file", "", "YAML file of change freezes, on top of the topology's frozen apps; read again for every request.")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	s := &server{
+		ctx:        ctx,
+		file:       *filePath,
+		freezeFile: *freezeFile,
+		maxShards:  *maxShards,
+		execCmd:    *execCmd,
+		skipHooks:  *skipHooks,
+		auditLog:   *auditLog,
+		executions: make(map[string]*execution),
+	}
+	srv := &http.Server{Addr: *addr, Handler: s.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	fmt.Printf("Serving %s on http://%s\n", *filePath, *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	s.wait.Wait() // let running plans record their cancellation
+	return nil
+}
+
+type server struct {
+	ctx        context.Context // cancelled when the server stops, and with it every execution
+	file       string
+	freezeFile string
+	maxShards  int
+	execCmd    string
+	skipHooks  bool
+	auditLog   string
+
+	mu         sync.Mutex
+	executions map[string]*execution
+	running    *execution
+	nextID     int
+	wait       sync.WaitGroup
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/plan", s.handlePlan)
+	mux.HandleFunc("POST /v1/executions", s.handleExecute)
+	mux.HandleFunc("GET /v1/executions", s.handleList)
+	mux.HandleFunc("GET /v1/executions/{id}", s.handleStatus)
+	mux.HandleFunc("GET /v1/executions/{id}/events", s.handleEvents)
+	mux.HandleFunc("POST /v1/executions/{id}/cancel", s.handleCancel)
+	return mux
+}
+
+// planRequest picks a plan, as -mode, -target, -view, -skip-frozen and
+// -override-freeze do.
+type planRequest struct {
+	Mode           string `json:"mode"`
+	Target         string `json:"target,omitempty"`
+	View           string `json:"view,omitempty"` // concrete (default) or logical
+	SkipFrozen     bool   `json:"skip_frozen,omitempty"`
+	OverrideFreeze bool   `json:"override_freeze,omitempty"`
+}
+
+type planResponse struct {
+	Name   string      `json:"name"`
+	Mode   string      `json:"mode"`
+	Layers []planLayer `json:"layers"`
+}
+
+type planLayer struct {
+	Nodes  []hookNode `json:"nodes"`
+	Owners []string   `json:"owners,omitempty"`
+	Hooks  []string   `json:"hooks_after,omitempty"` // the hooks run before the next layer
+}
+
+// plan reads the topology and builds the plan req asks for.
+func (s *server) plan(req planRequest) (*topology.Graph, string, [][]*topology.Node, error) {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	graph, err := topology.ParseYAMLWithOptions(data, topology.ParseOptions{MaxShards: s.maxShards})
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("parsing topology: %w", err)
+	}
+	if err := applyFreezeFile(graph, s.freezeFile); err != nil {
+		return nil, "", nil, fmt.Errorf("applying freeze file: %w", err)
+	}
+	switch req.View {
+	case "", "concrete":
+	case "logical":
+		if req.Mode == "restart" {
+			return nil, "", nil, errors.New("restart mode is not compatible with logical view")
+		}
+		if graph, err = graph.LogicalGraph(); err != nil {
+			return nil, "", nil, err
+		}
+	default:
+		return nil, "", nil, fmt.Errorf("invalid view %q", req.View)
+	}
+	name, order, err := buildPlan(graph, req.Mode, req.Target)
+	if err == nil && req.SkipFrozen {
+		order = topology.WithoutFrozen(order)
+	}
+	return graph, name, order, err
+}
+
+func (s *server) handlePlan(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	req := planRequest{Mode: q.Get("mode"), Target: q.Get("target"), View: q.Get("view"), SkipFrozen: q.Get("skip_frozen") == "true"}
+	if req.Mode == "" {
+		req.Mode = "startup"
+	}
+	graph, name, order, err := s.plan(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	hooks := graph.HooksFor(req.Mode)
+	resp := planResponse{Name: name, Mode: req.Mode, Layers: make([]planLayer, len(order))}
+	for i, layer := range order {
+		resp.Layers[i] = planLayer{Nodes: describeNodes(layer), Owners: topology.LayerOwners(layer)}
+		if i < len(order)-1 {
+			for _, hook := range hooks {
+				if hook.Follows(i) {
+					resp.Layers[i].Hooks = append(resp.Layers[i].Hooks, hook.Name)
+				}
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	if s.execCmd == "" {
+		writeError(w, http.StatusForbidden, errors.New("this server was started without -exec, so it only plans"))
+		return
+	}
+	var req planRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+		return
+	}
+	graph, name, order, err := s.plan(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if frozen := topology.FrozenNodes(order); len(frozen) > 0 && !req.OverrideFreeze {
+		writeError(w, http.StatusConflict, fmt.Errorf("the plan touches %d frozen node(s), %s (%s) first; set skip_frozen to leave them out or override_freeze to go ahead", len(frozen), frozen[0].ID, frozen[0].Frozen))
+		return
+	}
+	var hooks []topology.LayerHook
+	if !s.skipHooks {
+		hooks = graph.HooksFor(req.Mode)
+	}
+	record, err := openAuditLog(s.auditLog, req.Mode)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("opening audit log: %w", err))
+		return
+	}
+
+	s.mu.Lock()
+	if s.running != nil {
+		id := s.running.id
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Errorf("execution %s is still running", id))
+		return
+	}
+	s.nextID++
+	ctx, cancel := context.WithCancel(s.ctx)
+	e := &execution{
+		id:      strconv.Itoa(s.nextID),
+		plan:    name,
+		request: req,
+		started: time.Now(),
+		state:   executionRunning,
+		nodes:   make(map[string]topology.NodeStatus),
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+	for _, layer := range order {
+		for _, node := range layer {
+			e.nodes[node.ID] = topology.StatusPending
+		}
+	}
+	s.executions[e.id] = e
+	s.running = e
+	s.wait.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wait.Done()
+		defer cancel()
+		err := topology.ExecutePlanWithHooks(ctx, order, hooks, commandRunner(s.execCmd, req.Mode), hookRunner(req.Mode), func(ev topology.ExecutionEvent) {
+			record(ev)
+			e.add(ev)
+		})
+		e.finish(err, ctx.Err() != nil)
+		s.mu.Lock()
+		s.running = nil
+		s.mu.Unlock()
+	}()
+	writeJSON(w, http.StatusAccepted, e.status())
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]*execution, 0, len(s.executions))
+	for _, e := range s.executions {
+		list = append(list, e)
+	}
+	s.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].started.Before(list[j].started) })
+	statuses := make([]executionStatus, len(list))
+	for i, e := range list {
+		statuses[i] = e.status()
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (s *server) lookup(w http.ResponseWriter, r *http.Request) *execution {
+	s.mu.Lock()
+	e := s.executions[r.PathValue("id")]
+	s.mu.Unlock()
+	if e == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no execution %q", r.PathValue("id")))
+	}
+	return e
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if e := s.lookup(w, r); e != nil {
+		writeJSON(w, http.StatusOK, e.status())
+	}
+}
+
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	if e := s.lookup(w, r); e != nil {
+		e.cancel()
+		writeJSON(w, http.StatusAccepted, e.status())
+	}
+}
+
+// handleEvents streams an execution's events as one JSON object per line:
+// those so far, then each as it happens, until the execution ends or the
+// client goes away.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	e := s.lookup(w, r)
+	if e == nil {
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	sent := 0
+	for {
+		e.mu.Lock()
+		events, done, changed := e.events[sent:], e.state != executionRunning, e.changed
+		e.mu.Unlock()
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+		}
+		sent += len(events)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Where an execution stands.
+const (
+	executionRunning   = "running"
+	executionSucceeded = "succeeded"
+	executionFailed    = "failed"
+	executionCancelled = "cancelled"
+)
+
+// execution is a plan the server is executing or has executed.
+type execution struct {
+	id      string
+	plan    string
+	request planRequest
+	started time.Time
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	state    string
+	err      error
+	finished time.Time
+	nodes    map[string]topology.NodeStatus
+	events   []eventJSON
+	changed  chan struct{} // closed and replaced on every change
+}
+
+// eventJSON is a topology.ExecutionEvent on the wire.
+type eventJSON struct {
+	Time   time.Time           `json:"time"`
+	Layer  int                 `json:"layer"` // from 1; for a hook, the layer it follows
+	Node   string              `json:"node,omitempty"`
+	Hook   string              `json:"hook,omitempty"`
+	Status topology.NodeStatus `json:"status"`
+	Line   string              `json:"line,omitempty"`
+	Error  string              `json:"error,omitempty"`
+	Owner  string              `json:"owner,omitempty"`
+	Wait   float64             `json:"wait_seconds,omitempty"` // with status waiting
+}
+
+type executionStatus struct {
+	ID       string                         `json:"id"`
+	Plan     string                         `json:"plan"`
+	Mode     string                         `json:"mode"`
+	Target   string                         `json:"target,omitempty"`
+	State    string                         `json:"state"`
+	Error    string                         `json:"error,omitempty"`
+	Started  time.Time                      `json:"started"`
+	Finished *time.Time                     `json:"finished,omitempty"`
+	Nodes    map[string]topology.NodeStatus `json:"nodes"`
+}
+
+func (e *execution) add(ev topology.ExecutionEvent) {
+	j := eventJSON{Time: ev.Time, Layer: ev.Layer + 1, Hook: ev.Hook, Status: ev.Status, Line: ev.Line, Wait: ev.Wait.Seconds()}
+	if ev.Node != nil {
+		j.Node, j.Owner = ev.Node.ID, ev.Node.Owner
+	}
+	if ev.Err != nil {
+		j.Error = ev.Err.Error()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, j)
+	if ev.Node != nil && ev.Line == "" {
+		e.nodes[ev.Node.ID] = ev.Status
+	}
+	e.notify()
+}
+
+func (e *execution) finish(err error, cancelled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err, e.finished = err, time.Now()
+	switch {
+	case err == nil:
+		e.state = executionSucceeded
+	case cancelled:
+		e.state = executionCancelled
+	default:
+		e.state = executionFailed
+	}
+	e.notify()
+}
+
+// notify wakes the event streams; e.mu must be held.
+func (e *execution) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+func (e *execution) status() executionStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st := executionStatus{
+		ID:      e.id,
+		Plan:    e.plan,
+		Mode:    e.request.Mode,
+		Target:  e.request.Target,
+		State:   e.state,
+		Started: e.started,
+		Nodes:   make(map[string]topology.NodeStatus, len(e.nodes)),
+	}
+	for id, status := range e.nodes {
+		st.Nodes[id] = status
+	}
+	if e.err != nil {
+		st.Error = e.err.Error()
+	}
+	if !e.finished.IsZero() {
+		finished := e.finished
+		st.Finished = &finished
+	}
+	return st
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// END FILE: cmd/orchestrator/serve.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/topology/main.go
+// This new tool is an interactive browser for the graph and its plans, for
+// when rendering DOT/SVG is too slow (e.g. during an incident).
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"yourcorp/topology"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	paneStyle     = lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(lipgloss.Color("240"))
+	selectedStyle = lipgloss.NewStyle().Background(lipgloss.Color("205")).Foreground(lipgloss.Color("231"))
+	titleStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))
+	headingStyle  = lipgloss.NewStyle().Bold(true)
+	helpStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "tui" {
+		fmt.Fprintln(os.Stderr, "Usage: topology tui [-file topology.yaml] [-view concrete|logical] [-max-shards N]")
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	filePath := flags.String("file", "topology.yaml", "Path to the topology YAML file.")
+	view := flags.String("view", "concrete", "Graph view: 'concrete' (default) or 'logical'.")
+	maxShards := flags.Int("max-shards", topology.DefaultMaxShards, "Most shards an app may have.")
+	flags.Parse(os.Args[2:])
+
+	yamlData, err := os.ReadFile(*filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", *filePath, err)
+		os.Exit(1)
+	}
+	graph, err := topology.ParseYAMLWithOptions(yamlData, topology.ParseOptions{MaxShards: *maxShards})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing topology: %v\n", err)
+		os.Exit(1)
+	}
+	if *view == "logical" {
+		graph, err = graph.LogicalGraph()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating logical graph: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	p := tea.NewProgram(newBrowser(*filePath, graph), tea.WithAltScreen())
+	if _, err := p.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running TUI: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// browser lists the nodes in startup order on the left and details the
+// selected one on the right.
+type browser struct {
+	path       string
+	graph      *topology.Graph
+	layers     [][]*topology.Node
+	layerOf    map[string]int
+	dependents map[string][]*topology.Node
+	items      []*topology.Node // The nodes the search query leaves, in startup order
+	cursor     int
+	offset     int // The first item shown in the left pane
+	width      int
+	height     int
+	detail     viewport.Model
+	search     textinput.Model
+	searching  bool
+	status     string
+}
+
+func newBrowser(path string, graph *topology.Graph) browser {
+	b := browser{
+		path:       path,
+		graph:      graph,
+		layers:     topology.GetStartupOrder(graph),
+		layerOf:    make(map[string]int),
+		dependents: make(map[string][]*topology.Node),
+		detail:     viewport.New(80, 20),
+		search:     textinput.New(),
+	}
+	b.search.Prompt = "/"
+	b.search.Placeholder = "fuzzy search nodes and host groups"
+	for i, layer := range b.layers {
+		for _, node := range layer {
+			b.layerOf[node.ID] = i
+		}
+	}
+	for _, node := range graph.Nodes {
+		for _, dep := range node.DependsOn {
+			b.dependents[dep.ID] = append(b.dependents[dep.ID], node)
+		}
+	}
+	for _, nodes := range b.dependents {
+		sortNodes(nodes)
+	}
+	b.applyFilter()
+	return b
+}
+
+func (b browser) Init() tea.Cmd { return nil }
+
+func (b browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+		b.detail.Width = b.width/2 - 2
+		b.detail.Height = b.listHeight()
+
+	case tea.KeyMsg:
+		if b.searching {
+			switch msg.String() {
+			case "enter":
+				b.searching = false
+				b.search.Blur()
+			case "esc":
+				b.searching = false
+				b.search.Blur()
+				b.search.Reset()
+				b.applyFilter()
+			case "ctrl+c":
+				return b, tea.Quit
+			case "up":
+				b.move(-1)
+			case "down":
+				b.move(1)
+			default:
+				var cmd tea.Cmd
+				b.search, cmd = b.search.Update(msg)
+				b.applyFilter()
+				b.detail.SetContent(b.renderDetail())
+				return b, cmd
+			}
+			b.detail.SetContent(b.renderDetail())
+			return b, nil
+		}
+
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return b, tea.Quit
+		case "up", "k":
+			b.move(-1)
+		case "down", "j":
+			b.move(1)
+		case "/":
+			b.searching = true
+			return b, b.search.Focus()
+		case "esc":
+			b.search.Reset()
+			b.applyFilter()
+		case "x":
+			b.status = b.exportSelected()
+		}
+	}
+
+	b.detail.SetContent(b.renderDetail())
+	var cmd tea.Cmd
+	b.detail, cmd = b.detail.Update(msg)
+	return b, cmd
+}
+
+func (b browser) View() string {
+	if b.width == 0 {
+		return "Initializing..."
+	}
+	half := b.width/2 - 2
+	left := paneStyle.Width(half).Height(b.listHeight()).Render(b.renderList())
+	right := paneStyle.Width(half).Height(b.listHeight()).Render(b.detail.View())
+
+	footer := helpStyle.Render("↑/↓ navigate, / search, esc clear, x export subgraph as DOT, q quit.")
+	if b.searching {
+		footer = b.search.View()
+	} else if b.status != "" {
+		footer = helpStyle.Render(b.status)
+	}
+	return lipgloss.JoinVertical(lipgloss.Top,
+		b.renderTitle(),
+		lipgloss.JoinHorizontal(lipgloss.Top, left, right),
+		footer,
+	)
+}
+
+// listHeight is how many rows the panes have between the title and footer.
+func (b browser) listHeight() int {
+	return max(b.height-4, 1)
+}
+
+// move moves the cursor by delta, scrolling the left pane to keep it shown.
+func (b *browser) move(delta int) {
+	b.cursor = min(max(b.cursor+delta, 0), max(len(b.items)-1, 0))
+	rows := b.listHeight() - 1 // one row is the column header
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	} else if b.cursor >= b.offset+rows {
+		b.offset = b.cursor - rows + 1
+	}
+	b.detail.GotoTop()
+}
+
+// applyFilter narrows the left pane to the nodes whose ID or host group
+// fuzzy-matches the search query.
+func (b *browser) applyFilter() {
+	query := b.search.Value()
+	b.items = nil
+	for _, layer := range b.layers {
+		for _, node := range layer {
+			if query == "" || fuzzyMatch(query, node.ID) || fuzzyMatch(query, node.HostGroupID()) {
+				b.items = append(b.items, node)
+			}
+		}
+	}
+	b.cursor, b.offset = 0, 0
+	b.detail.GotoTop()
+}
+
+func (b browser) selected() *topology.Node {
+	if len(b.items) == 0 {
+		return nil
+	}
+	return b.items[b.cursor]
+}
+
+func (b browser) renderTitle() string {
+	title := fmt.Sprintf("%s: %d nodes in %d startup layers", b.path, len(b.graph.Nodes), len(b.layers))
+	if query := b.search.Value(); query != "" {
+		title += fmt.Sprintf(", %d match /%s", len(b.items), query)
+	}
+	return titleStyle.Render(title)
+}
+
+func (b browser) renderList() string {
+	if len(b.items) == 0 {
+		return "No nodes match."
+	}
+	var s strings.Builder
+	s.WriteString(headingStyle.Render("Layer  Node") + "\n")
+	rows := b.listHeight() - 1
+	for i := b.offset; i < len(b.items) && i < b.offset+rows; i++ {
+		node := b.items[i]
+		line := fmt.Sprintf("%5d  %s", b.layerOf[node.ID]+1, node.ID)
+		if i == b.cursor {
+			line = selectedStyle.Render(line)
+		}
+		s.WriteString(line + "\n")
+	}
+	return s.String()
+}
+
+func (b browser) renderDetail() string {
+	node := b.selected()
+	if node == nil {
+		return ""
+	}
+	var s strings.Builder
+	s.WriteString(headingStyle.Render(node.ID) + "\n")
+	fmt.Fprintf(&s, "  app: %s  shard: %d  startup layer: %d\n\n", node.BaseApp, node.Shard, b.layerOf[node.ID]+1)
+
+	if group := node.HostGroup; group != nil {
+		s.WriteString(headingStyle.Render(fmt.Sprintf("Host group %s (shard %d of %s)", group.ID, group.Shard, group.RootApp)) + "\n")
+		writeNodes(&s, group.Members)
+		s.WriteString("\n")
+	}
+
+	deps := append([]*topology.Node(nil), node.DependsOn...)
+	sortNodes(deps)
+	s.WriteString(headingStyle.Render(fmt.Sprintf("Depends on (%d)", len(deps))) + "\n")
+	writeNodes(&s, deps)
+	s.WriteString("\n")
+
+	s.WriteString(headingStyle.Render(fmt.Sprintf("Dependents (%d)", len(b.dependents[node.ID]))) + "\n")
+	writeNodes(&s, b.dependents[node.ID])
+	s.WriteString("\n")
+
+	if subgraph, err := topology.GetSubgraphFor(b.graph, node.ID); err == nil {
+		s.WriteString(headingStyle.Render("Restart plan") + "\n")
+		for i, layer := range topology.GetStartupOrder(subgraph) {
+			var ids []string
+			for _, n := range layer {
+				ids = append(ids, n.ID)
+			}
+			fmt.Fprintf(&s, "  Layer %d: [ %s ]\n", i+1, strings.Join(ids, ", "))
+		}
+	}
+	return s.String()
+}
+
+// exportSelected writes the selected node's restart subgraph (its host group
+// and everything the group depends on) to <node>.dot in the working
+// directory, returning a status line.
+func (b browser) exportSelected() string {
+	node := b.selected()
+	if node == nil {
+		return "Nothing selected."
+	}
+	subgraph, err := topology.GetSubgraphFor(b.graph, node.ID)
+	if err != nil {
+		return fmt.Sprintf("Error generating subgraph: %v", err)
+	}
+	dotOutput, err := subgraph.DOT(topology.DOTOptions{ShowCoLocation: true})
+	if err != nil {
+		return fmt.Sprintf("Error rendering DOT graph: %v", err)
+	}
+	outPath := node.ID + ".dot"
+	if err := os.WriteFile(outPath, []byte(dotOutput), 0o644); err != nil {
+		return fmt.Sprintf("Error writing %s: %v", outPath, err)
+	}
+	return fmt.Sprintf("Wrote %s (%d nodes).", outPath, len(subgraph.Nodes))
+}
+
+func writeNodes(s *strings.Builder, nodes []*topology.Node) {
+	if len(nodes) == 0 {
+		s.WriteString("  (none)\n")
+	}
+	for _, n := range nodes {
+		fmt.Fprintf(s, "  - %s\n", n.ID)
+	}
+}
+
+func sortNodes(nodes []*topology.Node) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+}
+
+// fuzzyMatch reports whether every character of query appears in s in
+// order, ignoring case, so "sor1" finds "sor-01".
+func fuzzyMatch(query, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(query) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+	return true
+}
+
+// END FILE: cmd/topology/main.go
+
+// ------------------------------------------------------------------
+
+// FILE: freeze_test.go
+// This new test file covers how freezes apply to apps, nodes and hosts.
+package topology_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"yourcorp/topology"
+)
+
+const freezeTopology = `
+version: 1
+shards:
+  sor: 2
+blueprints:
+  stack:
+    apps:
+      muse: {}
+apps:
+  db: {}
+  sor:
+    depends_on: [db]
+    uses:
+      - blueprint: stack
+  admin:
+    frozen: true
+    freeze_reason: CHG-1 audit
+`
+
+func TestParseFreezeYAML(t *testing.T) {
+	cases := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"valid", "freezes:\n  - reason: quarter-end\n    apps: [sor]\n    from: 2026-12-28T00:00:00Z\n    until: 2027-01-04T00:00:00Z\n", ""},
+		{"open-ended", "freezes:\n  - reason: incident\n    nodes: [db]\n", ""},
+		{"no reason", "freezes:\n  - apps: [sor]\n", "freeze 1 has no reason"},
+		{"nothing frozen", "freezes:\n  - reason: quarter-end\n", "freezes nothing"},
+		{"ends before it starts", "freezes:\n  - reason: quarter-end\n    apps: [sor]\n    from: 2027-01-04T00:00:00Z\n    until: 2026-12-28T00:00:00Z\n", "ends before it starts"},
+		{"unknown field", "freezes:\n  - reason: quarter-end\n    app: [sor]\n", "schema validation failed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := topology.ParseFreezeYAML([]byte(c.yaml))
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Fatalf("error = %v, want one containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyFreezes(t *testing.T) {
+	now := time.Date(2026, 12, 30, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		freezes []topology.Freeze
+		want    map[string]string // frozen node ID to reason
+		wantErr []string
+	}{
+		{
+			name: "topology only",
+			want: map[string]string{"admin": "CHG-1 audit"},
+		},
+		{
+			name:    "app",
+			freezes: []topology.Freeze{{Reason: "quarter-end", Apps: []string{"sor"}}},
+			want:    map[string]string{"admin": "CHG-1 audit", "sor-00": "quarter-end", "sor-01": "quarter-end"},
+		},
+		{
+			name:    "node",
+			freezes: []topology.Freeze{{Reason: "disk swap", Nodes: []string{"db"}}},
+			want:    map[string]string{"admin": "CHG-1 audit", "db": "disk swap"},
+		},
+		{
+			name:    "host group",
+			freezes: []topology.Freeze{{Reason: "rack move", HostGroups: []string{"hostgroup-sor-01"}}},
+			want:    map[string]string{"admin": "CHG-1 audit", "sor-01": "rack move", "sor-muse-01": "rack move"},
+		},
+		{
+			name: "inside and outside windows",
+			freezes: []topology.Freeze{
+				{Reason: "open", Nodes: []string{"db"}, From: now.Add(-time.Hour), Until: now.Add(time.Hour)},
+				{Reason: "over", Nodes: []string{"sor-00"}, Until: now.Add(-time.Hour)},
+				{Reason: "not yet", Nodes: []string{"sor-01"}, From: now.Add(time.Hour)},
+				{Reason: "starts now", Nodes: []string{"sor-muse-00"}, From: now},
+				{Reason: "ends now", Nodes: []string{"sor-muse-01"}, Until: now},
+			},
+			want: map[string]string{"admin": "CHG-1 audit", "db": "open", "sor-muse-00": "starts now"},
+		},
+		{
+			name: "reasons add up once each",
+			freezes: []topology.Freeze{
+				{Reason: "quarter-end", Apps: []string{"sor", "admin"}},
+				{Reason: "rack move", HostGroups: []string{"hostgroup-sor-01"}, Nodes: []string{"sor-01"}},
+				{Reason: "quarter-end", Nodes: []string{"sor-00"}},
+			},
+			want: map[string]string{
+				"admin":       "CHG-1 audit; quarter-end",
+				"sor-00":      "quarter-end",
+				"sor-01":      "quarter-end; rack move",
+				"sor-muse-01": "rack move",
+			},
+		},
+		{
+			name: "unknown names, even outside the window",
+			freezes: []topology.Freeze{
+				{Reason: "typos", Apps: []string{"sorr"}, Nodes: []string{"sor-02"}, HostGroups: []string{"hostgroup-sor-02"}, Until: now.Add(-time.Hour)},
+			},
+			want:    map[string]string{"admin": "CHG-1 audit"},
+			wantErr: []string{"unknown app 'sorr'", "unknown node 'sor-02'", "unknown host group 'hostgroup-sor-02'"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph, err := topology.ParseYAML([]byte(freezeTopology))
+			if err != nil {
+				t.Fatalf("Failed to parse test YAML: %v", err)
+			}
+			err = graph.ApplyFreezes(topology.FreezeFile{Freezes: c.freezes}, now)
+			if len(c.wantErr) == 0 && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, want := range c.wantErr {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("error = %v, want one containing %q", err, want)
+				}
+			}
+			got := make(map[string]string)
+			for id, node := range graph.Nodes {
+				if node.Frozen != "" {
+					got[id] = node.Frozen
+				}
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("frozen = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTopologyFreezeCoversBlueprintComponents(t *testing.T) {
+	graph, err := topology.ParseYAML([]byte(strings.Replace(freezeTopology, "    depends_on: [db]\n", "    depends_on: [db]\n    frozen: true\n", 1)))
+	if err != nil {
+		t.Fatalf("Failed to parse test YAML: %v", err)
+	}
+	for _, id := range []string{"sor-00", "sor-01", "sor-muse-00", "sor-muse-01"} {
+		if got := graph.Nodes[id].Frozen; got != "frozen in the topology" {
+			t.Errorf("%s frozen for %q, want the default reason", id, got)
+		}
+	}
+	if got := graph.Nodes["db"].Frozen; got != "" {
+		t.Errorf("db frozen for %q, want it left alone", got)
+	}
+}
+
+func TestWithoutFrozen(t *testing.T) {
+	graph, err := topology.ParseYAML([]byte(freezeTopology))
+	if err != nil {
+		t.Fatalf("Failed to parse test YAML: %v", err)
+	}
+	if err := graph.ApplyFreezes(topology.FreezeFile{Freezes: []topology.Freeze{{Reason: "quarter-end", Apps: []string{"sor"}}}}, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plan := topology.GetStartupOrder(graph)
+	want := [][]string{{"admin", "db", "sor-muse-00", "sor-muse-01"}, {"sor-00", "sor-01"}}
+	if got := orderToIDs(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("startup plan = %v, want %v", got, want)
+	}
+
+	var frozen []string
+	for _, node := range topology.FrozenNodes(plan) {
+		frozen = append(frozen, node.ID)
+	}
+	if want := []string{"admin", "sor-00", "sor-01"}; !reflect.DeepEqual(frozen, want) {
+		t.Errorf("FrozenNodes = %v, want %v", frozen, want)
+	}
+
+	// the emptied layer keeps its place, and the plan itself is left as it was
+	thawed := topology.WithoutFrozen(plan)
+	if got, want := orderToIDs(thawed), [][]string{{"db", "sor-muse-00", "sor-muse-01"}, nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WithoutFrozen = %v, want %v", got, want)
+	}
+	if len(thawed[1]) != 0 || thawed[1] == nil {
+		t.Errorf("WithoutFrozen layer 2 = %#v, want an empty layer", thawed[1])
+	}
+	if got := orderToIDs(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("after WithoutFrozen, plan = %v, want %v", got, want)
+	}
+}
+
+// orderToIDs converts layers of nodes to layers of node IDs for easy comparison.
+func orderToIDs(order [][]*topology.Node) [][]string {
+	var idOrder [][]string
+	for _, layer := range order {
+		var idLayer []string
+		for _, node := range layer {
+			idLayer = append(idLayer, node.ID)
+		}
+		idOrder = append(idOrder, idLayer)
+	}
+	return idOrder
+}
+
+// END FILE: freeze_test.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/orchestrator/freeze_test.go
+// This new test file covers how -freeze-file, -skip-frozen and
+// -override-freeze reject or let through a plan with frozen nodes, and the
+// same in serve's requests.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the tests run the orchestrator as a command, to see its
+// exit status.
+func TestMain(m *testing.M) {
+	if os.Getenv("ORCHESTRATOR_TEST_MAIN") == "1" {
+		os.Args = append([]string{"orchestrator"}, strings.Split(os.Getenv("ORCHESTRATOR_TEST_ARGS"), "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const freezeTopology = `
+version: 1
+apps:
+  db: {}
+  api:
+    depends_on: [db]
+    frozen: true
+    freeze_reason: CHG-1 review
+  web:
+    depends_on: [api]
+`
+
+// freezeFixture writes the topology, a freeze file freezing web on top of
+// it, and an -exec command that records the nodes it runs in the file ran.
+func freezeFixture(t *testing.T) (dir, command string) {
+	t.Helper()
+	dir = t.TempDir()
+	files := map[string]string{
+		"topology.yaml": freezeTopology,
+		"freezes.yaml":  "freezes:\n  - reason: quarter-end\n    nodes: [web]\n",
+		"typo.yaml":     "freezes:\n  - reason: quarter-end\n    apps: [wbe]\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, "echo $NODE_ID >> " + filepath.Join(dir, "ran")
+}
+
+func ranNodes(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "ran"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(string(data))
+}
+
+func TestExecFreezeFlags(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       []string
+		exec       bool
+		wantStatus int
+		wantRan    []string
+		wantStderr string
+	}{
+		{name: "plan only", args: []string{"-freeze-file", "freezes.yaml"}},
+		{name: "topology freeze refused", exec: true, wantStatus: 1, wantStderr: "touches 1 frozen node(s)"},
+		{name: "freeze file refused", args: []string{"-freeze-file", "freezes.yaml"}, exec: true, wantStatus: 1, wantStderr: "touches 2 frozen node(s)"},
+		{name: "skipped", args: []string{"-freeze-file", "freezes.yaml", "-skip-frozen"}, exec: true, wantRan: []string{"db"}},
+		{name: "overridden", args: []string{"-freeze-file", "freezes.yaml", "-override-freeze"}, exec: true, wantRan: []string{"db", "api", "web"}, wantStderr: "overriding the freeze of 2 node(s)"},
+		{name: "skip and override", args: []string{"-skip-frozen", "-override-freeze"}, exec: true, wantStatus: 1, wantStderr: "mutually exclusive"},
+		{name: "unknown app in freeze file", args: []string{"-freeze-file", "typo.yaml", "-override-freeze"}, exec: true, wantStatus: 1, wantStderr: "unknown app 'wbe'"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, command := freezeFixture(t)
+			args := append([]string{"-audit-log="}, c.args...)
+			if c.exec {
+				args = append(args, "-exec", command)
+			}
+			cmd := exec.Command(os.Args[0])
+			cmd.Dir = dir
+			cmd.Env = append(os.Environ(), "ORCHESTRATOR_TEST_MAIN=1", "ORCHESTRATOR_TEST_ARGS="+strings.Join(args, "\n"))
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+			status := 0
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if status != c.wantStatus {
+				t.Errorf("exit status = %d, want %d; stderr:\n%s", status, c.wantStatus, stderr.String())
+			}
+			if !strings.Contains(stderr.String(), c.wantStderr) {
+				t.Errorf("stderr = %q, want it to contain %q", stderr.String(), c.wantStderr)
+			}
+			if got := ranNodes(t, dir); !reflect.DeepEqual(got, c.wantRan) {
+				t.Errorf("ran %v, want %v", got, c.wantRan)
+			}
+		})
+	}
+}
+
+func TestServeFreezeRequests(t *testing.T) {
+	cases := []struct {
+		name       string
+		req        planRequest
+		wantStatus int
+		wantRan    []string
+	}{
+		{name: "refused", req: planRequest{Mode: "startup"}, wantStatus: http.StatusConflict},
+		{name: "skipped", req: planRequest{Mode: "startup", SkipFrozen: true}, wantStatus: http.StatusAccepted, wantRan: []string{"db"}},
+		{name: "overridden", req: planRequest{Mode: "startup", OverrideFreeze: true}, wantStatus: http.StatusAccepted, wantRan: []string{"db", "api", "web"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir, command := freezeFixture(t)
+			s := &server{
+				ctx:        context.Background(),
+				file:       filepath.Join(dir, "topology.yaml"),
+				freezeFile: filepath.Join(dir, "freezes.yaml"),
+				execCmd:    command,
+				executions: make(map[string]*execution),
+			}
+			body, _ := json.Marshal(c.req)
+			rec := httptest.NewRecorder()
+			s.routes().ServeHTTP(rec, httptest.NewRequest("POST", "/v1/executions", bytes.NewReader(body)))
+			s.wait.Wait()
+			if rec.Code != c.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, c.wantStatus, rec.Body)
+			}
+			if got := ranNodes(t, dir); !reflect.DeepEqual(got, c.wantRan) {
+				t.Errorf("ran %v, want %v", got, c.wantRan)
+			}
+		})
+	}
+}
+
+// END FILE: cmd/orchestrator/freeze_test.go
+
+// ------------------------------------------------------------------
+
+// FILE: delay_test.go
+// This new test file covers dependency delays: how they are parsed, how
+// long execution holds a dependent back, and how estimates count them.
+package topology
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDependencyDelays(t *testing.T) {
+	cases := []struct {
+		name    string
+		yaml    string
+		want    map[string]map[string]time.Duration // node ID to its Delays
+		wantErr string
+	}{
+		{
+			name: "names only",
+			yaml: "apps:\n  db: {}\n  api:\n    depends_on: [db]\n",
+			want: map[string]map[string]time.Duration{"api": nil},
+		},
+		{
+			name: "mixed list",
+			yaml: "apps:\n  db: {}\n  cache: {}\n  api:\n    depends_on:\n      - db\n      - app: cache\n        delay: 30s\n",
+			want: map[string]map[string]time.Duration{"api": {"cache": 30 * time.Second}},
+		},
+		{
+			name: "shard to shard",
+			yaml: "shards:\n  sor: 2\n  feed: 2\napps:\n  feed: {}\n  sor:\n    depends_on: [{app: feed, delay: 5s}]\n",
+			want: map[string]map[string]time.Duration{
+				"sor-00": {"feed-00": 5 * time.Second},
+				"sor-01": {"feed-01": 5 * time.Second},
+			},
+		},
+		{
+			name: "fan-in",
+			yaml: "shards:\n  sor: 2\napps:\n  sor: {}\n  bog:\n    depends_on_all_of: [{app: sor, delay: 10s}]\n",
+			want: map[string]map[string]time.Duration{"bog": {"sor-00": 10 * time.Second, "sor-01": 10 * time.Second}},
+		},
+		{
+			name: "listed twice keeps the longest",
+			yaml: "apps:\n  db: {}\n  api:\n    depends_on: [{app: db, delay: 20s}, {app: db, delay: 5s}]\n",
+			want: map[string]map[string]time.Duration{"api": {"db": 20 * time.Second}},
+		},
+		{
+			name:    "no app",
+			yaml:    "apps:\n  db: {}\n  api:\n    depends_on: [{delay: 5s}]\n",
+			wantErr: "dependency has no app",
+		},
+		{
+			name:    "not a list",
+			yaml:    "apps:\n  db: {}\n  api:\n    depends_on: db\n",
+			wantErr: "dependencies must be a list",
+		},
+		{
+			name:    "bad duration",
+			yaml:    "apps:\n  db: {}\n  api:\n    depends_on: [{app: db, delay: soon}]\n",
+			wantErr: "soon",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph, err := ParseYAML([]byte("version: 1\n" + c.yaml))
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for id, want := range c.want {
+				if got := graph.Nodes[id].Delays; !reflect.DeepEqual(got, want) {
+					t.Errorf("%s delays = %v, want %v", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAddDelay(t *testing.T) {
+	db, api := &Node{ID: "db"}, &Node{ID: "api"}
+	api.addDelay(db, 0)
+	if api.Delays != nil {
+		t.Errorf("a zero delay made Delays %v, want nil", api.Delays)
+	}
+	api.addDelay(db, 20*time.Second)
+	api.addDelay(db, 5*time.Second)
+	if got := api.DelayAfter(db); got != 20*time.Second {
+		t.Errorf("DelayAfter(db) = %v, want the longer 20s", got)
+	}
+	if got := db.DelayAfter(api); got != 0 {
+		t.Errorf("DelayAfter without a delay = %v, want 0", got)
+	}
+}
+
+func TestStartAfter(t *testing.T) {
+	t0 := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	node := &Node{ID: "api", Delays: map[string]time.Duration{"db": 10 * time.Second, "cache": 30 * time.Second}}
+	cases := []struct {
+		name      string
+		healthyAt map[string]time.Time
+		want      time.Time
+	}{
+		{"none healthy", map[string]time.Time{}, time.Time{}},
+		{"one healthy", map[string]time.Time{"db": t0}, t0.Add(10 * time.Second)},
+		{"latest of both", map[string]time.Time{"db": t0.Add(25 * time.Second), "cache": t0}, t0.Add(35 * time.Second)},
+		{"dependencies without delay", map[string]time.Time{"queue": t0.Add(time.Hour)}, time.Time{}},
+	}
+	for _, c := range cases {
+		if got := startAfter(node, c.healthyAt); !got.Equal(c.want) {
+			t.Errorf("%s: startAfter = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+// stubRunner records when each node ran instead of running anything. It
+// returns at once, so that is also when the node became healthy.
+type stubRunner struct {
+	mu  sync.Mutex
+	ran map[string]time.Time
+}
+
+func (s *stubRunner) run(ctx context.Context, node *Node, out io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ran == nil {
+		s.ran = make(map[string]time.Time)
+	}
+	s.ran[node.ID] = time.Now()
+	return nil
+}
+
+const delayTopology = `
+version: 1
+apps:
+  db: {}
+  api:
+    depends_on: [{app: db, delay: DELAY}]
+  web:
+    depends_on: [db]
+`
+
+func parseDelayTopology(t *testing.T, delay time.Duration) *Graph {
+	t.Helper()
+	graph, err := ParseYAML([]byte(strings.Replace(delayTopology, "DELAY", delay.String(), 1)))
+	if err != nil {
+		t.Fatalf("Failed to parse test YAML: %v", err)
+	}
+	return graph
+}
+
+func TestExecutePlanWaitsOutDelays(t *testing.T) {
+	const delay = 60 * time.Millisecond
+	graph := parseDelayTopology(t, delay)
+	stub := &stubRunner{}
+	waits := make(map[string]time.Duration)
+	err := ExecutePlan(context.Background(), GetStartupOrder(graph), stub.run, func(e ExecutionEvent) {
+		if e.Status == StatusWaiting {
+			waits[e.Node.ID] = e.Wait
+		}
+	})
+	if err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	if held := stub.ran["api"].Sub(stub.ran["db"]); held < delay {
+		t.Errorf("api started %v after db was healthy, want at least %v", held, delay)
+	}
+	if w, ok := waits["api"]; !ok || w <= 0 || w > delay {
+		t.Errorf("api waiting event for %v, want one of at most %v", w, delay)
+	}
+	if w, ok := waits["web"]; ok {
+		t.Errorf("web, with no delay, waited %v", w)
+	}
+}
+
+func TestExecutePlanCancelledWhileWaiting(t *testing.T) {
+	graph := parseDelayTopology(t, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stub := &stubRunner{}
+	var apiErr error
+	err := ExecutePlan(ctx, GetStartupOrder(graph), stub.run, func(e ExecutionEvent) {
+		switch {
+		case e.Node.ID == "api" && e.Status == StatusWaiting:
+			cancel()
+		case e.Node.ID == "api" && e.Status == StatusFailed:
+			apiErr = e.Err
+		}
+	})
+	if err == nil || !strings.Contains(err.Error(), "api") {
+		t.Errorf("ExecutePlan = %v, want api to have failed", err)
 	}
-	opts := topology.DOTOptions{ShowCoLocation: true}
-	if *view == "logical" {
-		graph, err = graph.LogicalGraph()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating logical graph: %v\n", err)
-			os.Exit(1)
+	if !errors.Is(apiErr, context.Canceled) {
+		t.Errorf("api failed with %v, want the cancellation", apiErr)
+	}
+	if _, ran := stub.ran["api"]; ran {
+		t.Error("api ran after the plan was cancelled")
+	}
+}
+
+func TestShutdownIgnoresDelays(t *testing.T) {
+	graph := parseDelayTopology(t, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stub := &stubRunner{}
+	err := ExecutePlan(ctx, GetShutdownOrder(graph), stub.run, func(e ExecutionEvent) {
+		if e.Status == StatusWaiting {
+			t.Errorf("%s waited %v in a shutdown", e.Node.ID, e.Wait)
 		}
-		opts.ShowCoLocation = false
+	})
+	if err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+}
+
+func TestEstimatePlanAddsDelays(t *testing.T) {
+	graph := parseDelayTopology(t, 30*time.Second)
+	entries := []AuditEntry{
+		{Mode: "startup", Node: "db", BaseApp: "db", Status: StatusHealthy, Seconds: 10},
+		{Mode: "startup", Node: "api", BaseApp: "api", Status: StatusHealthy, Seconds: 5},
+		{Mode: "startup", Node: "web", BaseApp: "web", Status: StatusHealthy, Seconds: 20},
+	}
+	est := EstimatePlan(GetStartupOrder(graph), nil, "startup", entries)
+	if len(est.Layers) != 2 {
+		t.Fatalf("got %d layers, want 2", len(est.Layers))
+	}
+	delays := make(map[string]time.Duration)
+	for _, ne := range est.Layers[1].Nodes {
+		delays[ne.Node.ID] = ne.Delay
+	}
+	if want := map[string]time.Duration{"api": 30 * time.Second, "web": 0}; !reflect.DeepEqual(delays, want) {
+		t.Errorf("layer 2 delays = %v, want %v", delays, want)
+	}
+	// api's 30s wait and 5s run outlast web's 20s
+	if got, want := est.Layers[1].P50, 35*time.Second; got != want {
+		t.Errorf("layer 2 P50 = %v, want %v", got, want)
+	}
+	if got, want := est.P50, 45*time.Second; got != want {
+		t.Errorf("plan P50 = %v, want %v", got, want)
+	}
+
+	// a restart of api alone has db up already: nothing to wait for
+	est = EstimatePlan([][]*Node{{graph.Nodes["api"]}}, nil, "startup", entries)
+	if got := est.Layers[0].Nodes[0].Delay; got != 0 {
+		t.Errorf("delay after a dependency outside the plan = %v, want 0", got)
+	}
+}
+
+// END FILE: delay_test.go
+
+// ------------------------------------------------------------------
+
+// FILE: parser_test.go
+// This new test file covers ParseYAML's validation with table tests.
+package topology_test
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"yourcorp/topology"
+)
+
+// parseCase is a topology and either the error ParseYAML should give for
+// it or nothing.
+type parseCase struct {
+	name    string
+	yaml    string
+	wantErr string
+}
+
+// parse parses c.yaml and checks its error, returning the graph if there
+// was none to expect.
+func (c parseCase) parse(t *testing.T, opts topology.ParseOptions) *topology.Graph {
+	t.Helper()
+	graph, err := topology.ParseYAMLWithOptions([]byte("version: 1\n"+c.yaml), opts)
+	switch {
+	case c.wantErr == "" && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case c.wantErr != "" && err == nil:
+		t.Fatalf("no error, want one containing %q", c.wantErr)
+	case c.wantErr != "" && !strings.Contains(err.Error(), c.wantErr):
+		t.Fatalf("error = %v, want one containing %q", err, c.wantErr)
+	}
+	return graph
+}
+
+func dependencyIDs(node *topology.Node) []string {
+	ids := []string{}
+	for _, dep := range node.DependsOn {
+		ids = append(ids, dep.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestShardPins(t *testing.T) {
+	const sharded = "shards:\n  sor: 2\n  feed: 2\napps:\n  db: {}\n  sor: {}\n  feed: {}\n"
+	cases := []struct {
+		parseCase
+		hostGroups map[string]string   // node ID to host group ID
+		deps       map[string][]string // node ID to its dependencies
+	}{
+		{
+			parseCase: parseCase{name: "pinned with its components", yaml: `
+shards:
+  sor: 2
+blueprints:
+  console:
+    apps:
+      ui: {}
+apps:
+  db: {}
+  sor: {}
+  admin:
+    same_host_as: sor
+    pin_to_shard: 1
+    depends_on: [sor, db]
+    uses:
+      - blueprint: console
+`},
+			hostGroups: map[string]string{"admin": "hostgroup-sor-01", "admin-ui": "hostgroup-sor-01", "sor-01": "hostgroup-sor-01", "sor-00": "hostgroup-sor-00", "db": ""},
+			deps:       map[string][]string{"admin": {"db", "sor-01"}},
+		},
+		{
+			parseCase: parseCase{name: "two apps pinned to one shard", yaml: sharded + `
+  admin:
+    same_host_as: sor
+    pin_to_shard: 0
+  audit:
+    same_host_as: sor
+    pin_to_shard: 0
+    depends_on: [admin]
+`},
+			hostGroups: map[string]string{"admin": "hostgroup-sor-00", "audit": "hostgroup-sor-00"},
+			deps:       map[string][]string{"audit": {"admin"}},
+		},
+		{
+			parseCase: parseCase{name: "no same_host_as", yaml: sharded + "  admin:\n    pin_to_shard: 0\n",
+				wantErr: "app 'admin' has pin_to_shard but no same_host_as"},
+		},
+		{
+			parseCase: parseCase{name: "pinned app sharded", yaml: "shards:\n  sor: 2\n  admin: 2\napps:\n  sor: {}\n  admin:\n    same_host_as: sor\n    pin_to_shard: 0\n",
+				wantErr: "must be a singleton, but it has 2 shards"},
+		},
+		{
+			parseCase: parseCase{name: "shard too high", yaml: sharded + "  admin:\n    same_host_as: sor\n    pin_to_shard: 2\n",
+				wantErr: "pinned to shard 2 of 'sor', which has shards 0 to 1"},
+		},
+		{
+			parseCase: parseCase{name: "negative shard", yaml: sharded + "  admin:\n    same_host_as: sor\n    pin_to_shard: -1\n",
+				wantErr: "pinned to shard -1"},
+		},
+		{
+			parseCase: parseCase{name: "pinned to a singleton", yaml: sharded + "  admin:\n    same_host_as: db\n    pin_to_shard: 1\n",
+				wantErr: "pinned to shard 1 of 'db', which has shards 0 to 0"},
+		},
+		{
+			parseCase: parseCase{name: "groups not co-located", yaml: sharded + "  admin:\n    same_host_as: [sor, feed]\n    pin_to_shard: 0\n",
+				wantErr: "pinned to a shard of both 'sor' and 'feed', which are not co-located"},
+		},
+		{
+			parseCase: parseCase{name: "co-located apps pinned apart", yaml: sharded + `
+  admin:
+    same_host_as: sor
+    pin_to_shard: 0
+  audit:
+    same_host_as: sor
+    pin_to_shard: 1
+  tool:
+    same_host_as: [admin, audit]
+`, wantErr: "co-located but pinned to different shards"},
+		},
+		{
+			parseCase: parseCase{name: "chained pins", yaml: sharded + `
+  admin:
+    same_host_as: sor
+    pin_to_shard: 0
+  audit:
+    same_host_as: admin
+    pin_to_shard: 0
+`, wantErr: "which is itself pinned; pin it to 'sor' directly"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := c.parse(t, topology.ParseOptions{})
+			if graph == nil {
+				return
+			}
+			for id, want := range c.hostGroups {
+				if got := graph.Nodes[id].HostGroupID(); got != want {
+					t.Errorf("%s host group = %q, want %q", id, got, want)
+				}
+			}
+			for id, want := range c.deps {
+				if got := dependencyIDs(graph.Nodes[id]); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s depends on %v, want %v", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShardCountsAndNodeIDs(t *testing.T) {
+	cases := []struct {
+		parseCase
+		opts  topology.ParseOptions
+		nodes []string // nodes that must exist
+	}{
+		{
+			parseCase: parseCase{name: "default limit", yaml: "shards:\n  sor: 100\napps:\n  sor: {}\n"},
+			nodes:     []string{"sor-00", "sor-99"},
+		},
+		{
+			parseCase: parseCase{name: "over the default limit", yaml: "shards:\n  sor: 101\napps:\n  sor: {}\n",
+				wantErr: "app 'sor' has 101 shards, but must have 1 to 100"},
+		},
+		{
+			parseCase: parseCase{name: "non-positive limit means the default", yaml: "shards:\n  sor: 101\napps:\n  sor: {}\n",
+				wantErr: "must have 1 to 100"},
+			opts: topology.ParseOptions{MaxShards: -1},
+		},
+		{
+			parseCase: parseCase{name: "raised limit", yaml: "shards:\n  sor: 150\napps:\n  sor: {}\n"},
+			opts:      topology.ParseOptions{MaxShards: 200},
+			nodes:     []string{"sor-00", "sor-149"},
+		},
+		{
+			parseCase: parseCase{name: "lowered limit", yaml: "shards:\n  sor: 5\napps:\n  sor: {}\n",
+				wantErr: "app 'sor' has 5 shards, but must have 1 to 4"},
+			opts: topology.ParseOptions{MaxShards: 4},
+		},
+		{
+			parseCase: parseCase{name: "zero shards", yaml: "shards:\n  sor: 0\napps:\n  sor: {}\n",
+				wantErr: "app 'sor' has 0 shards"},
+		},
+		{
+			parseCase: parseCase{name: "shards of no app", yaml: "shards:\n  sorr: 2\napps:\n  sor: {}\n",
+				wantErr: "shard count defined for non-existent app 'sorr'"},
+		},
+		{
+			parseCase: parseCase{name: "co-located apps sharded apart", yaml: "shards:\n  sor: 2\n  bog: 3\napps:\n  sor: {}\n  bog:\n    same_host_as: sor\n",
+				wantErr: "conflicting shard counts"},
+		},
+		{
+			parseCase: parseCase{name: "app named like a shard", yaml: "shards:\n  sor: 2\napps:\n  sor: {}\n  sor-01: {}\n",
+				wantErr: "shard 1 of app 'sor' is node 'sor-01', which is also the name of an app"},
+		},
+		{
+			parseCase: parseCase{name: "app named like a component's shard", yaml: `
+shards:
+  sor: 2
+blueprints:
+  stack:
+    apps:
+      muse: {}
+apps:
+  sor:
+    uses:
+      - blueprint: stack
+  sor-muse-00: {}
+`, wantErr: "shard 0 of app 'sor-muse' is node 'sor-muse-00'"},
+		},
+		{
+			parseCase: parseCase{name: "app named like a shard of a singleton", yaml: "apps:\n  sor: {}\n  sor-01: {}\n"},
+			nodes:     []string{"sor", "sor-01"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := c.parse(t, c.opts)
+			for _, id := range c.nodes {
+				if _, ok := graph.Nodes[id]; !ok {
+					t.Errorf("no node %s", id)
+				}
+			}
+		})
+	}
+}
+
+func TestHookValidation(t *testing.T) {
+	const apps = "apps:\n  db: {}\nhooks:\n"
+	cases := []parseCase{
+		{name: "valid", yaml: apps + "  - name: pause replay\n    run: ./replay.sh pause\n    after: [1, 2]\n    modes: [startup, restart]\n  - name: notify\n    run: ./noc.sh\n"},
+		{name: "no name", yaml: apps + "  - run: ./noc.sh\n", wantErr: "hook 1 has no name"},
+		{name: "defined twice", yaml: apps + "  - name: notify\n    run: ./noc.sh\n  - name: notify\n    run: ./noc.sh again\n", wantErr: "hook 'notify' is defined twice"},
+		{name: "nothing to run", yaml: apps + "  - name: notify\n    run: '  '\n", wantErr: "hook 'notify' has nothing to run"},
+		{name: "layer zero", yaml: apps + "  - name: notify\n    run: ./noc.sh\n    after: [0]\n", wantErr: "layers are numbered from 1"},
+		{name: "unknown mode", yaml: apps + "  - name: notify\n    run: ./noc.sh\n    modes: [deploy]\n", wantErr: "hook 'notify' has unknown mode 'deploy'"},
+		{name: "unknown field", yaml: apps + "  - name: notify\n    command: ./noc.sh\n", wantErr: "schema validation failed"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			graph := c.parse(t, topology.ParseOptions{})
+			if graph == nil {
+				return
+			}
+			for mode, want := range map[string][]string{
+				"startup":  {"pause replay", "notify"},
+				"shutdown": {"notify"},
+				"restart":  {"pause replay", "notify"},
+			} {
+				var got []string
+				for _, hook := range graph.HooksFor(mode) {
+					got = append(got, hook.Name)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("HooksFor(%s) = %v, want %v", mode, got, want)
+				}
+			}
+		})
+	}
+}
+
+// END FILE: parser_test.go
+
+// ------------------------------------------------------------------
+
+// FILE: hooks_test.go
+// This new test file covers the hooks run at the barriers between layers.
+package topology_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"yourcorp/topology"
+)
+
+// barrierLog records, in order, the nodes that start and the hooks that run
+// with the layers around them.
+type barrierLog struct {
+	mu      sync.Mutex
+	steps   []string
+	failing string // the hook that fails
+}
+
+func (l *barrierLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+}
+
+func (l *barrierLog) run(ctx context.Context, node *topology.Node, out io.Writer) error {
+	l.add(node.ID)
+	return nil
+}
+
+func (l *barrierLog) runHook(ctx context.Context, hook topology.LayerHook, b topology.Barrier, out io.Writer) error {
+	l.add(fmt.Sprintf("%s after %d %v before %v", hook.Name, b.Layer+1, orderToIDs([][]*topology.Node{b.Done})[0], orderToIDs([][]*topology.Node{b.Next})[0]))
+	fmt.Fprintln(out, "ran", hook.Name)
+	if hook.Name == l.failing {
+		return errors.New("exit status 1")
 	}
-	dotOutput, err := graph.DOT(opts)
+	return nil
+}
+
+func TestExecutePlanRunsHooksAtBarriers(t *testing.T) {
+	graph, err := topology.ParseYAML([]byte(`
+version: 1
+apps:
+  db: {}
+  api:
+    depends_on: [db]
+  web:
+    depends_on: [api]
+hooks:
+  - name: notify
+    run: ./noc.sh
+  - name: pause
+    run: ./replay.sh pause
+    after: [1]
+  - name: last
+    run: ./never.sh
+    after: [3]
+`))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error rendering DOT graph: %v\n", err)
-		os.Exit(1)
+		t.Fatalf("Failed to parse test YAML: %v", err)
 	}
-	if *format == "dot" {
-		fmt.Print(dotOutput)
-		return
+	plan := topology.GetStartupOrder(graph)
+
+	cases := []struct {
+		name    string
+		failing string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "all pass",
+			want: []string{
+				"db",
+				"notify after 1 [db] before [api]",
+				"pause after 1 [db] before [api]",
+				"api",
+				"notify after 2 [api] before [web]",
+				"web",
+			},
+		},
+		{
+			name:    "failing hook stops the plan",
+			failing: "pause",
+			want: []string{
+				"db",
+				"notify after 1 [db] before [api]",
+				"pause after 1 [db] before [api]",
+			},
+			wantErr: `after layer 1: hook "pause" failed: exit status 1`,
+		},
 	}
-	cmd := exec.Command("dot", "-T"+*format)
-	cmd.Stdin = strings.NewReader(dotOutput)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		if errors.Is(err, exec.ErrNotFound) {
-			fmt.Fprintln(os.Stderr, "Error: 'dot' command not found. Please install Graphviz.")
-		} else {
-			fmt.Fprintf(os.Stderr, "Error executing 'dot' command: %v\n", err)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			log := &barrierLog{failing: c.failing}
+			var lines []string
+			err := topology.ExecutePlanWithHooks(context.Background(), plan, graph.HooksFor("startup"), log.run, log.runHook, func(e topology.ExecutionEvent) {
+				if e.Hook != "" && e.Line != "" {
+					lines = append(lines, e.Line)
+				}
+			})
+			switch {
+			case c.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)):
+				t.Fatalf("error = %v, want one containing %q", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(log.steps, c.want) {
+				t.Errorf("steps = %q, want %q", log.steps, c.want)
+			}
+			if len(lines) == 0 || lines[0] != "ran notify" {
+				t.Errorf("hook output lines = %q, want them reported", lines)
+			}
+		})
+	}
+}
+
+func TestExecutePlanWithoutHookRunner(t *testing.T) {
+	graph, err := topology.ParseYAML([]byte("version: 1\napps:\n  db: {}\n  api:\n    depends_on: [db]\nhooks:\n  - name: notify\n    run: ./noc.sh\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse test YAML: %v", err)
+	}
+	log := &barrierLog{}
+	if err := topology.ExecutePlan(context.Background(), topology.GetStartupOrder(graph), log.run, func(topology.ExecutionEvent) {}); err != nil {
+		t.Fatalf("ExecutePlan: %v", err)
+	}
+	if want := []string{"db", "api"}; !reflect.DeepEqual(log.steps, want) {
+		t.Errorf("steps = %q, want %q", log.steps, want)
+	}
+}
+
+// END FILE: hooks_test.go
+
+// ------------------------------------------------------------------
+
+// FILE: cmd/orchestrator/hooks_test.go
+// This new test file covers what a hook's command is told about its barrier.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"yourcorp/topology"
+)
+
+func TestHookRunnerEnvironmentAndInput(t *testing.T) {
+	graph, err := topology.ParseYAML([]byte("version: 1\nshards:\n  sor: 2\napps:\n  db: {}\n  sor:\n    depends_on: [db]\n    owner: trading\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse test YAML: %v", err)
+	}
+	plan := topology.GetStartupOrder(graph)
+	hook := topology.LayerHook{Name: "notify", Run: `echo "$MODE|$HOOK|$LAYER|$LAYER_NODES|$NEXT_NODES"; cat`}
+
+	var out bytes.Buffer
+	err = hookRunner("startup")(context.Background(), hook, topology.Barrier{Layer: 0, Done: plan[0], Next: plan[1]}, &out)
+	if err != nil {
+		t.Fatalf("hook failed: %v\n%s", err, out.String())
+	}
+	env, stdin, _ := strings.Cut(out.String(), "\n")
+	if want := "startup|notify|1|db|sor-00 sor-01"; env != want {
+		t.Errorf("environment = %q, want %q", env, want)
+	}
+	var input hookInput
+	if err := json.Unmarshal([]byte(stdin), &input); err != nil {
+		t.Fatalf("stdin %q: %v", stdin, err)
+	}
+	want := hookInput{
+		Mode:  "startup",
+		Hook:  "notify",
+		Layer: 1,
+		Done:  []hookNode{{ID: "db", BaseApp: "db"}},
+		Next: []hookNode{
+			{ID: "sor-00", BaseApp: "sor", Shard: 0, Owner: "trading"},
+			{ID: "sor-01", BaseApp: "sor", Shard: 1, Owner: "trading"},
+		},
+	}
+	if !reflect.DeepEqual(input, want) {
+		t.Errorf("stdin = %+v, want %+v", input, want)
+	}
+
+	hook.Run = "exit 3"
+	if err := hookRunner("startup")(context.Background(), hook, topology.Barrier{Done: plan[0], Next: plan[1]}, &out); err == nil {
+		t.Error("a failing hook command returned nil")
+	}
+}
+
+// END FILE: cmd/orchestrator/hooks_test.go
+
+// ------------------------------------------------------------------
+
+// FILE: audit_test.go
+// This new test file covers the audit log and the estimates made from it.
+package topology
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLogRoundTrip(t *testing.T) {
+	t0 := time.Date(2026, 10, 14, 19, 20, 0, 0, time.UTC)
+	db := &Node{ID: "db", BaseApp: "db"}
+	sor := &Node{ID: "sor-01", BaseApp: "sor", Shard: 1}
+	var buf bytes.Buffer
+	audit := NewAuditLog(&buf, "startup")
+	for _, e := range []ExecutionEvent{
+		{Time: t0, Layer: 0, Node: db, Status: StatusRunning},
+		{Time: t0.Add(time.Second), Layer: 0, Node: db, Status: StatusRunning, Line: "listening"},
+		{Time: t0.Add(1500 * time.Millisecond), Layer: 0, Node: db, Status: StatusHealthy},
+		{Time: t0.Add(2 * time.Second), Layer: 0, Hook: "notify", Status: StatusRunning},
+		{Time: t0.Add(3 * time.Second), Layer: 0, Hook: "notify", Status: StatusHealthy},
+		{Time: t0.Add(3 * time.Second), Layer: 1, Node: sor, Status: StatusRunning},
+		{Time: t0.Add(7 * time.Second), Layer: 1, Node: sor, Status: StatusFailed, Err: errors.New("exit status 1")},
+		{Time: t0.Add(8 * time.Second), Layer: 1, Node: db, Status: StatusFailed}, // never started again
+	} {
+		if err := audit.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
 		}
-		os.Exit(1)
+	}
+
+	entries, err := ReadAuditLog(strings.NewReader(buf.String() + "\n"))
+	if err != nil {
+		t.Fatalf("ReadAuditLog: %v", err)
+	}
+	want := []AuditEntry{
+		{Time: t0.Add(1500 * time.Millisecond), Mode: "startup", Node: "db", BaseApp: "db", Layer: 1, Status: StatusHealthy, Seconds: 1.5},
+		{Time: t0.Add(3 * time.Second), Mode: "startup", Hook: "notify", Layer: 1, Status: StatusHealthy, Seconds: 1},
+		{Time: t0.Add(7 * time.Second), Mode: "startup", Node: "sor-01", BaseApp: "sor", Layer: 2, Status: StatusFailed, Seconds: 4, Error: "exit status 1"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+
+	if _, err := ReadAuditLog(strings.NewReader(buf.String() + "{not json\n")); err == nil || !strings.Contains(err.Error(), "audit log line 4") {
+		t.Errorf("ReadAuditLog of a broken line = %v, want an error naming line 4", err)
 	}
 }
 
-// END FILE: cmd/yaml2dot/main.go
+func TestStatsOf(t *testing.T) {
+	var oneToTwenty []float64
+	for i := 1; i <= 20; i++ {
+		oneToTwenty = append(oneToTwenty, float64(i))
+	}
+	cases := []struct {
+		name    string
+		seconds []float64
+		want    DurationStats
+	}{
+		{"none", nil, DurationStats{}},
+		{"one", []float64{2.5}, DurationStats{Runs: 1, P50: 2500 * time.Millisecond, P95: 2500 * time.Millisecond}},
+		{"nearest rank", oneToTwenty, DurationStats{Runs: 20, P50: 10 * time.Second, P95: 19 * time.Second}},
+		{"unsorted", []float64{9, 1, 5}, DurationStats{Runs: 3, P50: 5 * time.Second, P95: 9 * time.Second}},
+	}
+	for _, c := range cases {
+		if got := statsOf(c.seconds); got != c.want {
+			t.Errorf("%s: statsOf = %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestEstimatePlan(t *testing.T) {
+	db := &Node{ID: "db", BaseApp: "db"}
+	sor0 := &Node{ID: "sor-00", BaseApp: "sor"}
+	sor2 := &Node{ID: "sor-02", BaseApp: "sor", Shard: 2} // a new shard
+	web := &Node{ID: "web", BaseApp: "web"}               // never run
+	plan := [][]*Node{{db}, {sor0, sor2, web}}
+	hooks := []LayerHook{{Name: "notify"}, {Name: "never", After: []int{2}}}
+	entries := []AuditEntry{
+		{Mode: "startup", Node: "db", BaseApp: "db", Status: StatusHealthy, Seconds: 4},
+		{Mode: "startup", Node: "db", BaseApp: "db", Status: StatusHealthy, Seconds: 6},
+		{Mode: "startup", Node: "db", BaseApp: "db", Status: StatusFailed, Seconds: 600},
+		{Mode: "shutdown", Node: "db", BaseApp: "db", Status: StatusHealthy, Seconds: 300},
+		{Mode: "startup", Node: "sor-00", BaseApp: "sor", Status: StatusHealthy, Seconds: 10},
+		{Mode: "startup", Node: "sor-01", BaseApp: "sor", Status: StatusHealthy, Seconds: 20},
+		{Mode: "startup", Hook: "notify", Layer: 1, Status: StatusHealthy, Seconds: 1},
+	}
+	est := EstimatePlan(plan, hooks, "startup", entries)
+
+	type nodeWant struct {
+		stats   DurationStats
+		fromApp bool
+	}
+	want := map[string]nodeWant{
+		"db":     {DurationStats{Runs: 2, P50: 4 * time.Second, P95: 6 * time.Second}, false},
+		"sor-00": {DurationStats{Runs: 1, P50: 10 * time.Second, P95: 10 * time.Second}, false},
+		"sor-02": {DurationStats{Runs: 2, P50: 10 * time.Second, P95: 20 * time.Second}, true},
+		"web":    {DurationStats{}, true},
+	}
+	for _, layer := range est.Layers {
+		for _, ne := range layer.Nodes {
+			if got := (nodeWant{ne.DurationStats, ne.FromApp}); got != want[ne.Node.ID] {
+				t.Errorf("%s estimate = %+v, want %+v", ne.Node.ID, got, want[ne.Node.ID])
+			}
+		}
+	}
+	if got := len(est.Layers[0].Hooks); got != 1 || est.Layers[0].Hooks[0].Hook != "notify" {
+		t.Errorf("hooks after layer 1 = %+v, want notify alone", est.Layers[0].Hooks)
+	}
+	if got := len(est.Layers[1].Hooks); got != 0 {
+		t.Errorf("%d hook(s) estimated after the last layer, want none", got)
+	}
+	// layer 1 is db and then notify; layer 2 its slowest node, sor-02
+	if est.Layers[0].P50 != 5*time.Second || est.Layers[0].P95 != 7*time.Second {
+		t.Errorf("layer 1 = p50 %v, p95 %v, want 5s and 7s", est.Layers[0].P50, est.Layers[0].P95)
+	}
+	if est.P50 != 15*time.Second || est.P95 != 27*time.Second {
+		t.Errorf("plan = p50 %v, p95 %v, want 15s and 27s", est.P50, est.P95)
+	}
+	if est.Unknown != 1 {
+		t.Errorf("Unknown = %d, want 1 for web", est.Unknown)
+	}
+}
+
+// END FILE: audit_test.go
 
 // ------------------------------------------------------------------
 
-// FILE: cmd/orchestrator/main.go
-// This tool is updated to support logical views.
+// FILE: cmd/orchestrator/serve_test.go
+// This new test file covers the HTTP API of orchestrator serve.
 package main
 
 import (
-	"flag"
-	"fmt"
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"testing"
 	"yourcorp/topology"
 )
 
-func main() {
-	filePath := flag.String("file", "topology.yaml", "Path to the topology YAML file.")
-	mode := flag.String("mode", "startup", "Orchestration mode: startup, shutdown, or restart.")
-	target := flag.String("target", "", "The target node ID for restart mode (e.g., 'sor-01').")
-	view := flag.String("view", "concrete", "Plan view: 'concrete' (default) or 'logical'.")
-	flag.Parse()
-	yamlData, err := os.ReadFile(*filePath)
+const serveTopology = `
+version: 1
+shards:
+  sor: 2
+apps:
+  db:
+    owner: platform
+  sor:
+    depends_on: [db]
+    owner: trading
+hooks:
+  - name: notify
+    run: "true"
+`
+
+// newTestServer serves serveTopology, executing plans with execCmd.
+func newTestServer(t *testing.T, execCmd string) (*server, *httptest.Server) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "topology.yaml")
+	if err := os.WriteFile(file, []byte(serveTopology), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &server{
+		ctx:        context.Background(),
+		file:       file,
+		execCmd:    execCmd,
+		executions: make(map[string]*execution),
+	}
+	srv := httptest.NewServer(s.routes())
+	t.Cleanup(func() {
+		srv.Close()
+		s.wait.Wait()
+	})
+	return s, srv
+}
+
+// call sends a request and decodes the JSON answer into v, returning the
+// status code.
+func call(t *testing.T, method, url, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", *filePath, err)
-		os.Exit(1)
+		t.Fatal(err)
 	}
-	graph, err := topology.ParseYAML(yamlData)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing topology: %v\n", err)
-		os.Exit(1)
+		t.Fatal(err)
 	}
-	if *view == "logical" {
-		if *mode == "restart" {
-			fmt.Fprintln(os.Stderr, "Error: restart mode is not compatible with logical view.")
-			os.Exit(1)
-		}
-		graph, err = graph.LogicalGraph()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating logical graph: %v\n", err)
-			os.Exit(1)
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: decoding answer: %v", method, url, err)
 		}
 	}
-	switch *mode {
-	case "startup":
-		fmt.Printf("--- Generating %s Startup Plan ---\n", strings.Title(*view))
-		order := topology.GetStartupOrder(graph)
-		printOrder("Startup", order)
-	case "shutdown":
-		fmt.Printf("--- Generating %s Shutdown Plan ---\n", strings.Title(*view))
-		order := topology.GetShutdownOrder(graph)
-		printOrder("Shutdown", order)
-	case "restart":
-		if *target == "" {
-			fmt.Fprintln(os.Stderr, "Error: -target flag is required for restart mode.")
-			os.Exit(1)
+	return resp.StatusCode
+}
+
+func TestServePlan(t *testing.T) {
+	_, srv := newTestServer(t, "")
+	cases := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantLayers [][]string
+	}{
+		{name: "startup by default", wantStatus: http.StatusOK, wantLayers: [][]string{{"db"}, {"sor-00", "sor-01"}}},
+		{name: "shutdown", query: "?mode=shutdown", wantStatus: http.StatusOK, wantLayers: [][]string{{"sor-00", "sor-01"}, {"db"}}},
+		{name: "restart", query: "?mode=restart&target=sor-01", wantStatus: http.StatusOK, wantLayers: [][]string{{"db"}, {"sor-01"}}},
+		{name: "logical", query: "?view=logical", wantStatus: http.StatusOK, wantLayers: [][]string{{"db"}, {"sor"}}},
+		{name: "restart is not logical", query: "?mode=restart&target=sor-01&view=logical", wantStatus: http.StatusBadRequest},
+		{name: "unknown view", query: "?view=pretty", wantStatus: http.StatusBadRequest},
+		{name: "unknown target", query: "?mode=restart&target=sor-07", wantStatus: http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var resp planResponse
+			status := call(t, "GET", srv.URL+"/v1/plan"+c.query, "", &resp)
+			if status != c.wantStatus {
+				t.Fatalf("status = %d, want %d", status, c.wantStatus)
+			}
+			if c.wantLayers == nil {
+				return
+			}
+			var layers [][]string
+			for _, layer := range resp.Layers {
+				var ids []string
+				for _, node := range layer.Nodes {
+					ids = append(ids, node.ID)
+				}
+				layers = append(layers, ids)
+			}
+			if !reflect.DeepEqual(layers, c.wantLayers) {
+				t.Errorf("layers = %v, want %v", layers, c.wantLayers)
+			}
+		})
+	}
+
+	var resp planResponse
+	call(t, "GET", srv.URL+"/v1/plan", "", &resp)
+	if got := resp.Layers[0]; !reflect.DeepEqual(got.Owners, []string{"platform"}) || !reflect.DeepEqual(got.Hooks, []string{"notify"}) {
+		t.Errorf("layer 1 owners %v and hooks %v, want [platform] and [notify]", got.Owners, got.Hooks)
+	}
+	if got := resp.Layers[1].Hooks; got != nil {
+		t.Errorf("hooks after the last layer = %v, want none", got)
+	}
+}
+
+func TestServeExecute(t *testing.T) {
+	_, srv := newTestServer(t, "true")
+	var started executionStatus
+	if status := call(t, "POST", srv.URL+"/v1/executions", `{"mode": "startup"}`, &started); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", status, http.StatusAccepted)
+	}
+
+	// the event stream ends with the execution
+	resp, err := http.Get(srv.URL + "/v1/executions/" + started.ID + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var steps []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var ev eventJSON
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("event %q: %v", scanner.Text(), err)
 		}
-		fmt.Printf("--- Generating Targeted Restart Plan for Host Group of: %s ---\n", *target)
-		subgraph, err := topology.GetSubgraphFor(graph, *target)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating subgraph: %v\n", err)
-			os.Exit(1)
+		if ev.Status == topology.StatusHealthy {
+			steps = append(steps, ev.Node+ev.Hook)
 		}
-		order := topology.GetStartupOrder(subgraph)
-		printOrder("Restart", order)
-	default:
-		fmt.Fprintf(os.Stderr, "Error: Invalid mode %q.\n", *mode)
-		os.Exit(1)
+	}
+	if len(steps) == 4 {
+		sort.Strings(steps[2:]) // the shards run concurrently
+	}
+	if want := []string{"db", "notify", "sor-00", "sor-01"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("healthy events %v, want %v", steps, want)
+	}
+
+	var done executionStatus
+	call(t, "GET", srv.URL+"/v1/executions/"+started.ID, "", &done)
+	if done.State != executionSucceeded || done.Finished == nil {
+		t.Errorf("state %q, finished %v, want it succeeded", done.State, done.Finished)
+	}
+	for _, id := range []string{"db", "sor-00", "sor-01"} {
+		if done.Nodes[id] != topology.StatusHealthy {
+			t.Errorf("%s is %s, want healthy", id, done.Nodes[id])
+		}
+	}
+	var list []executionStatus
+	call(t, "GET", srv.URL+"/v1/executions", "", &list)
+	if len(list) != 1 || list[0].ID != started.ID {
+		t.Errorf("executions = %+v, want the one", list)
 	}
 }
 
-func printOrder(planName string, order [][]*topology.Node) {
-	if len(order) == 0 {
-		fmt.Println("  No operations required.")
-		return
+func TestServeExecuteErrors(t *testing.T) {
+	_, planOnly := newTestServer(t, "")
+	if status := call(t, "POST", planOnly.URL+"/v1/executions", `{"mode": "startup"}`, nil); status != http.StatusForbidden {
+		t.Errorf("without -exec: status = %d, want %d", status, http.StatusForbidden)
 	}
-	for i, layer := range order {
-		var nodeIDs []string
-		for _, node := range layer {
-			nodeIDs = append(nodeIDs, node.ID)
+
+	s, srv := newTestServer(t, `[ "$NODE_ID" != sor-01 ]`)
+	for _, c := range []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"mode": `, http.StatusBadRequest},
+		{`{"mode": "restart", "target": "nope"}`, http.StatusBadRequest},
+	} {
+		if status := call(t, "POST", srv.URL+"/v1/executions", c.body, nil); status != c.wantStatus {
+			t.Errorf("%s: status = %d, want %d", c.body, status, c.wantStatus)
 		}
-		fmt.Printf("  %s Layer %d (Concurrent): [ %s ]\n", planName, i+1, strings.Join(nodeIDs, ", "))
+	}
+	if status := call(t, "GET", srv.URL+"/v1/executions/42", "", nil); status != http.StatusNotFound {
+		t.Errorf("unknown execution: status = %d, want %d", status, http.StatusNotFound)
+	}
+
+	var started executionStatus
+	call(t, "POST", srv.URL+"/v1/executions", `{"mode": "startup"}`, &started)
+	s.wait.Wait()
+	var failed executionStatus
+	call(t, "GET", srv.URL+"/v1/executions/"+started.ID, "", &failed)
+	if failed.State != executionFailed || !strings.Contains(failed.Error, "sor-01") || failed.Nodes["sor-01"] != topology.StatusFailed {
+		t.Errorf("execution = %+v, want it failed on sor-01", failed)
 	}
 }
 
-// END FILE: cmd/orchestrator/main.go
+func TestServeOneExecutionAtATime(t *testing.T) {
+	s, srv := newTestServer(t, "exec sleep 60") // exec, so cancelling kills the sleep
+	var first executionStatus
+	if status := call(t, "POST", srv.URL+"/v1/executions", `{"mode": "startup"}`, &first); status != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", status, http.StatusAccepted)
+	}
+	if status := call(t, "POST", srv.URL+"/v1/executions", `{"mode": "shutdown"}`, nil); status != http.StatusConflict {
+		t.Errorf("second execution: status = %d, want %d", status, http.StatusConflict)
+	}
+	if status := call(t, "POST", srv.URL+"/v1/executions/"+first.ID+"/cancel", "", nil); status != http.StatusAccepted {
+		t.Errorf("cancel: status = %d, want %d", status, http.StatusAccepted)
+	}
+	s.wait.Wait()
+	var cancelled executionStatus
+	call(t, "GET", srv.URL+"/v1/executions/"+first.ID, "", &cancelled)
+	if cancelled.State != executionCancelled {
+		t.Errorf("state = %q, want %q", cancelled.State, executionCancelled)
+	}
+	if status := call(t, "POST", srv.URL+"/v1/executions", `{"mode": "shutdown"}`, nil); status != http.StatusAccepted {
+		t.Errorf("after the cancellation: status = %d, want %d", status, http.StatusAccepted)
+	}
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
+	if running != nil {
+		running.cancel()
+	}
+}
+
+// END FILE: cmd/orchestrator/serve_test.go
@@ -47,10 +47,12 @@ package cmd
 import (
     "bufio"
     "context"
+    "encoding/json"
     "errors"
     "fmt"
+    "io"
     "io/fs"
-    "net/url"
+    "net/http"
     "os"
     "os/exec"
     "path/filepath"
@@ -59,40 +61,76 @@ import (
 
     "github.com/fatih/color"
     "github.com/spf13/cobra"
-    "github.com/xanzy/go-gitlab"
 
+    "your-cli/internal/gitlab"
     "your-module/internal/config"
 )
 
 const (
-    repoURL   = "git@gitlab.com:your-group/your-monorepo.git"
-    groupPath = "your-group"
+    repoURL      = "git@gitlab.com:your-group/your-monorepo.git"
+    repoHTTPSURL = "https://gitlab.com/your-group/your-monorepo.git"
+    groupPath    = "your-group"
 )
 
 var (
     logger = config.NewLogger()
+
+    // init flags; see cloneOptions
+    initHTTPS  bool
+    initDepth  int
+    initSparse []string
+
+    // non-interactive init
+    initTokenStdin bool
+    initCloneDir   string
+    initYes        bool
 )
 
 var initCmd = &cobra.Command{
     Use:   "init",
     Short: "Interactively set up Loki for first-time use.",
-    Long: `init performs the following tasks:\n  • Verifies your GitLab Personal Access Token (PAT) and group access.\n  • Checks SSH connectivity to GitLab.\n  • Clones the Asgard monorepo to a directory you choose.\n  • Emits a summary of the actions taken.`,
+    Long: `init performs the following tasks:\n  • Verifies your GitLab Personal Access Token (PAT) and group access.\n  • Checks SSH connectivity to GitLab.\n  • Clones the Asgard monorepo to a directory you choose.\n  • Emits a summary of the actions taken.\n\nThe monorepo is large: --sparse apps/sor,libs/common checks out only those\nsubtrees (you are asked on a fresh clone) and --depth 1 skips the history.\n--https clones with your PAT where SSH is blocked.\n\nFor scripts and devcontainers, run it without prompts:\n\n  echo "$PAT" | loki init --token-stdin --clone-dir /workspace/asgard --yes\n\n--yes takes the default answer to every question, falls back to HTTPS if\nSSH fails, and prints a JSON summary on stdout (progress goes to stderr).`,
     RunE: runInit,
 }
 
 func init() {
+    initCmd.Flags().BoolVar(&initHTTPS, "https", false, "clone over HTTPS with your PAT instead of SSH")
+    initCmd.Flags().IntVar(&initDepth, "depth", 0, "shallow clone of the last N commits (0 = full history)")
+    initCmd.Flags().StringSliceVar(&initSparse, "sparse", nil, "check out only these subtrees, e.g. apps/sor,libs/common")
+    initCmd.Flags().BoolVar(&initTokenStdin, "token-stdin", false, "read the PAT from stdin, replacing any stored one")
+    initCmd.Flags().StringVar(&initCloneDir, "clone-dir", "", "where to clone the monorepo (default ./asgard)")
+    initCmd.Flags().BoolVarP(&initYes, "yes", "y", false, "don't ask: take the defaults and print a JSON summary")
     rootCmd.AddCommand(initCmd)
 }
 
+// initSummary is what init configured, printed as JSON with --yes.
+type initSummary struct {
+    TokenPath   string   `json:"token_path"`
+    TokenSource string   `json:"token_source"` // stored, stdin or prompt
+    Group       string   `json:"group"`
+    Transport   string   `json:"transport"` // ssh or https
+    CloneDir    string   `json:"clone_dir"`
+    Cloned      bool     `json:"cloned"` // false if an existing clone was pulled
+    Depth       int      `json:"depth,omitempty"`
+    Sparse      []string `json:"sparse,omitempty"`
+}
+
 // runInit coordinates the full initialization flow.
 func runInit(cmd *cobra.Command, _ []string) error {
-    green := color.New(color.FgGreen).PrintfFunc()
-    cyan := color.New(color.FgCyan).PrintfFunc()
-    bold := color.New(color.Bold).PrintfFunc()
+    // with --yes stdout is only the summary, so progress goes to stderr
+    out := io.Writer(os.Stdout)
+    if initYes {
+        out = os.Stderr
+    }
+    printer := func(attr color.Attribute) func(string, ...interface{}) {
+        c := color.New(attr)
+        return func(format string, a ...interface{}) { c.Fprintf(out, format, a...) }
+    }
+    green, cyan, bold := printer(color.FgGreen), printer(color.FgCyan), printer(color.Bold)
 
     bold("\n👋  Welcome to Loki! Let’s get you set up.\n\n")
 
-    pat, err := ensureToken(cmd.Context(), green, cyan)
+    pat, source, err := ensureToken(cmd.Context(), green, cyan)
     if err != nil {
         return err
     }
@@ -102,81 +140,136 @@ func runInit(cmd *cobra.Command, _ []string) error {
     }
     green("✔ GitLab access confirmed.\n")
 
-    if err := checkSSHAccess(); err != nil {
-        return err
+    opts := cloneOptions{https: initHTTPS, token: pat, depth: initDepth, sparse: initSparse}
+    if !opts.https {
+        if err := checkSSHAccess(); err != nil {
+            if initYes {
+                logger.Warn("SSH access failed, cloning over HTTPS", "err", err)
+            } else if !promptYes(fmt.Sprintf("%v.\nClone over HTTPS with your token instead? [Y/n] ", err)) {
+                return err
+            }
+            opts.https = true
+        } else {
+            green("✔ SSH access to GitLab OK.\n")
+        }
     }
-    green("✔ SSH access to GitLab OK.\n")
 
-    clonePath, err := promptCloneDir()
-    if err != nil {
-        return err
+    clonePath := initCloneDir
+    switch {
+    case clonePath != "":
+    case initYes:
+        clonePath = defaultCloneDir()
+    default:
+        if clonePath, err = promptCloneDir(); err != nil {
+            return err
+        }
+    }
+    _, err = os.Stat(filepath.Join(clonePath, ".git"))
+    cloned := err != nil
+    if cloned && !initYes && !cmd.Flags().Changed("sparse") {
+        opts.sparse = promptSparse()
     }
 
-    if err := gitCloneOrPull(repoURL, clonePath); err != nil {
+    if err := gitCloneOrPull(clonePath, opts); err != nil {
         return err
     }
-    green("✔ Repository ready at %s\n", clonePath)
+    green("✔ Repository ready at %s%s\n", clonePath, opts.describe())
 
     bold("\n🎉  Loki initialization complete. Happy shipping!\n")
-    return nil
+    if !initYes {
+        return nil
+    }
+    summary := initSummary{
+        TokenPath:   config.TokenPath(),
+        TokenSource: source,
+        Group:       groupPath,
+        Transport:   "ssh",
+        CloneDir:    clonePath,
+        Cloned:      cloned,
+        Depth:       opts.depth,
+        Sparse:      opts.sparse,
+    }
+    if opts.https {
+        summary.Transport = "https"
+    }
+    if abs, err := filepath.Abs(clonePath); err == nil {
+        summary.CloneDir = abs
+    }
+    enc := json.NewEncoder(os.Stdout)
+    enc.SetIndent("", "  ")
+    return enc.Encode(summary)
 }
 
-// ensureToken loads an existing PAT or prompts the user.
-func ensureToken(ctx context.Context, green, cyan func(string, ...interface{})) (string, error) {
+// ensureToken loads an existing PAT, or reads one from stdin with
+// --token-stdin, or prompts the user. It also returns where the token came
+// from: "stored", "stdin" or "prompt".
+func ensureToken(ctx context.Context, green, cyan func(string, ...interface{})) (string, string, error) {
     tokPath := config.TokenPath()
-    if data, err := os.ReadFile(tokPath); err == nil {
+    var tok, source string
+    if initTokenStdin {
+        data, err := io.ReadAll(os.Stdin)
+        if err != nil {
+            return "", "", err
+        }
+        if tok = strings.TrimSpace(string(data)); tok == "" {
+            return "", "", errors.New("--token-stdin: no token on stdin")
+        }
+        source = "stdin"
+        logger.Debug("token read from stdin")
+    } else if data, err := os.ReadFile(tokPath); err == nil {
         logger.Debug("token already present", "path", tokPath)
-        return strings.TrimSpace(string(data)), nil
-    }
-
-    cyan("A GitLab Personal Access Token with \"api\" scope is required.\n")
-    fmt.Print("Paste your PAT: ")
-    scanner := bufio.NewScanner(os.Stdin)
-    if !scanner.Scan() {
-        return "", errors.New("no input received")
+        return strings.TrimSpace(string(data)), "stored", nil
+    } else if initYes {
+        return "", "", errors.New("no stored token: pass it with --token-stdin")
+    } else {
+        cyan("A GitLab Personal Access Token with \"api\" scope is required.\n")
+        fmt.Print("Paste your PAT: ")
+        scanner := bufio.NewScanner(os.Stdin)
+        if !scanner.Scan() {
+            return "", "", errors.New("no input received")
+        }
+        tok, source = strings.TrimSpace(scanner.Text()), "prompt"
+        logger.Debug("user entered token")
     }
-    tok := strings.TrimSpace(scanner.Text())
-    logger.Debug("user entered token")
 
-    client, err := gitlab.NewClient(tok)
+    client, err := gitlab.New(tok)
     if err != nil {
-        return "", err
+        return "", "", err
     }
     ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
     defer cancel()
-    if _, _, err = client.Users.CurrentUser(gitlab.WithContext(ctx)); err != nil {
-        return "", fmt.Errorf("token validation failed: %w", err)
+    if _, err = client.CurrentUser(ctx); err != nil {
+        return "", "", fmt.Errorf("token validation failed: %w", err)
     }
     green("✔ Token validated.\n")
 
     if err := os.MkdirAll(filepath.Dir(tokPath), 0o700); err != nil {
-        return "", err
+        return "", "", err
     }
     if err := os.WriteFile(tokPath, []byte(tok+"\n"), fs.FileMode(0o600)); err != nil {
-        return "", err
+        return "", "", err
     }
     green("✔ Token stored at %s (0600).\n", tokPath)
     logger.Debug("token stored", "path", tokPath)
-    return tok, nil
+    return tok, source, nil
 }
 
 func validateGroupAccess(ctx context.Context, pat string) error {
-    client, err := gitlab.NewClient(pat)
+    client, err := gitlab.New(pat)
     if err != nil {
         return err
     }
     ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
     defer cancel()
-    groups, _, err := client.Groups.ListGroups(&gitlab.ListGroupsOptions{Search: gitlab.String(groupPath)}, gitlab.WithContext(ctx))
-    if err != nil {
-        return fmt.Errorf("unable to list groups: %w", err)
+    // GitLab answers 404 for groups the token cannot see
+    _, err = client.Group(ctx, groupPath)
+    if gitlab.StatusCode(err) == http.StatusNotFound {
+        return fmt.Errorf("token lacks access to group %q", groupPath)
     }
-    for _, g := range groups {
-        if g.Path == groupPath {
-            return nil
-        }
+    if err != nil {
+        return fmt.Errorf("unable to look up group %q: %w", groupPath, err)
     }
-    return fmt.Errorf("token lacks access to group %q", groupPath)
+    return nil
 }
 
 func checkSSHAccess() error {
@@ -190,9 +283,14 @@ func checkSSHAccess() error {
     return nil
 }
 
-func promptCloneDir() (string, error) {
+// defaultCloneDir is ./asgard.
+func defaultCloneDir() string {
     cwd, _ := os.Getwd()
-    def := filepath.Join(cwd, "asgard")
+    return filepath.Join(cwd, "asgard")
+}
+
+func promptCloneDir() (string, error) {
+    def := defaultCloneDir()
     fmt.Printf("\nRepo will be cloned to %s.\nPress Enter to accept or type a new path: ", def)
     scanner := bufio.NewScanner(os.Stdin)
     scanner.Scan()
@@ -203,20 +301,104 @@ func promptCloneDir() (string, error) {
     return input, nil
 }
 
-func gitCloneOrPull(repo, dir string) error {
+// promptYes asks a yes/no question; Enter means yes.
+func promptYes(question string) bool {
+    fmt.Print(question)
+    scanner := bufio.NewScanner(os.Stdin)
+    scanner.Scan()
+    answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+    return answer == "" || answer == "y" || answer == "yes"
+}
+
+// promptSparse asks which subtrees to check out; Enter means all of them.
+func promptSparse() []string {
+    fmt.Print("\nCheck out only some subtrees (e.g. apps/sor,libs/common)?\nPress Enter for the whole repo or list them: ")
+    scanner := bufio.NewScanner(os.Stdin)
+    scanner.Scan()
+    var paths []string
+    for _, p := range strings.Split(scanner.Text(), ",") {
+        if p = strings.Trim(strings.TrimSpace(p), "/"); p != "" {
+            paths = append(paths, p)
+        }
+    }
+    return paths
+}
+
+// cloneOptions are how gitCloneOrPull fetches the monorepo.
+type cloneOptions struct {
+    https  bool     // clone over HTTPS with token instead of SSH
+    token  string   // the PAT, for https
+    depth  int      // shallow clone of this many commits; 0 is the full history
+    sparse []string // check out only these subtrees, e.g. apps/sor
+}
+
+// describe is the summary suffix for the options, e.g. " (HTTPS, depth 1)".
+func (o cloneOptions) describe() string {
+    var parts []string
+    if o.https {
+        parts = append(parts, "HTTPS")
+    }
+    if o.depth > 0 {
+        parts = append(parts, fmt.Sprintf("depth %d", o.depth))
+    }
+    if len(o.sparse) > 0 {
+        parts = append(parts, "sparse: "+strings.Join(o.sparse, ", "))
+    }
+    if len(parts) == 0 {
+        return ""
+    }
+    return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// git runs git with the options' transport settings. Over HTTPS the token is
+// handed to git by a one-off credential helper reading it from the
+// environment, so it never lands in .git/config or the process list.
+func (o cloneOptions) git(args ...string) *exec.Cmd {
+    if o.https {
+        helper := `!f() { echo username=oauth2; echo "password=$LOKI_GIT_TOKEN"; }; f`
+        args = append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + helper}, args...)
+    }
+    cmd := exec.Command("git", args...)
+    if o.https {
+        cmd.Env = append(os.Environ(), "LOKI_GIT_TOKEN="+o.token)
+    }
+    cmd.Stdout = os.Stderr // keep stdout for init's own output
+    cmd.Stderr = os.Stderr
+    return cmd
+}
+
+func gitCloneOrPull(dir string, opts cloneOptions) error {
     if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
-        cmd := exec.Command("git", "-C", dir, "pull", "--ff-only")
-        cmd.Stdout = os.Stdout
-        cmd.Stderr = os.Stderr
-        return cmd.Run()
+        if err := opts.git("-C", dir, "pull", "--ff-only").Run(); err != nil {
+            return err
+        }
+        if len(opts.sparse) == 0 {
+            return nil
+        }
+        return opts.git(append([]string{"-C", dir, "sparse-checkout", "set", "--cone"}, opts.sparse...)...).Run()
     }
     if err := os.MkdirAll(dir, 0o755); err != nil {
         return err
     }
-    u, _ := url.Parse(repo)
-    fmt.Printf("Cloning %s...\n", u.Path)
-    cmd := exec.Command("git", "clone", repo, dir)
-    cmd.Stdout = os.Stdout
-    cmd.Stderr = os.Stderr
-    return cmd.Run()
+
+    repo := repoURL
+    if opts.https {
+        repo = repoHTTPSURL
+    }
+    args := []string{"clone"}
+    if opts.depth > 0 {
+        args = append(args, "--depth", fmt.Sprint(opts.depth))
+    }
+    if len(opts.sparse) > 0 {
+        // fetch the blobs of the checked-out subtrees only
+        args = append(args, "--filter=blob:none", "--sparse")
+    }
+    fmt.Fprintf(os.Stderr, "Cloning %s...\n", repo)
+    if err := opts.git(append(args, repo, dir)...).Run(); err != nil {
+        return err
+    }
+    if len(opts.sparse) == 0 {
+        return nil
+    }
+    return opts.git(append([]string{"-C", dir, "sparse-checkout", "set", "--cone"}, opts.sparse...)...).Run()
 }
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yourorg/tool/affected"
+)
+
+func newAffectedCmd() *cobra.Command {
+	var (
+		opts      affected.Options
+		explain   bool
+		overrides []string
+	)
+	cmd := &cobra.Command{
+		Use:   "affected",
+		Short: "List the deployables your changes affect, as CI will",
+		Long: `List the deployable projects affected by your changes, computed exactly
+as pipeline-gen does in CI: the changed files are mapped to projects in the
+metadata and the dependency graph is walked up to the deployables.
+
+  --mode branch  changes on this branch since it left --base-ref (default)
+  --mode main    changes in the last commit
+  --mode tag     changes since the previous tag
+
+--explain shows, for each deployable, the dependency chain from a changed
+project to it. --output json or yaml prints the changed files and projects
+too, and every chain.
+
+Pass the --deployable-* flags CI passes pipeline-gen, if any, to decide the
+deployables the same way.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if opts.Repo == "" {
+				ws, err := currentWorkspace()
+				if err != nil {
+					return err
+				}
+				opts.Repo = ws.Root
+			}
+			for _, o := range overrides {
+				if err := opts.Deployables.AddOverride(o); err != nil {
+					return err
+				}
+			}
+			if !filepath.IsAbs(opts.Metadata) {
+				opts.Metadata = filepath.Join(opts.Repo, opts.Metadata)
+			}
+			result, err := affected.Run(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+
+			return writeResult(cmd.OutOrStdout(), result, func(out io.Writer) error {
+				if len(result.Affected) == 0 {
+					fmt.Fprintf(os.Stderr, "No deployables affected (%d changed files, %d changed projects).\n",
+						len(result.ChangedFiles), len(result.ChangedProjects))
+					return nil
+				}
+				if !explain {
+					for _, app := range result.Affected {
+						fmt.Fprintln(out, app)
+					}
+					return nil
+				}
+				w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				for _, app := range result.Affected {
+					chain := result.Paths[app]
+					why := strings.Join(chain, " → ")
+					if len(chain) == 1 {
+						why = "changed"
+					}
+					fmt.Fprintf(w, "%s\t%s\n", app, why)
+				}
+				return w.Flush()
+			})
+		},
+	}
+	cmd.Flags().StringVar(&opts.Mode, "mode", "branch", "what to diff: "+strings.Join(affected.Modes, ", "))
+	cmd.Flags().StringVar(&opts.BaseRef, "base-ref", "origin/main", "what a branch is compared with, for --mode branch")
+	cmd.Flags().StringVar(&opts.Repo, "repo", "", "monorepo clone to diff (default the workspace root)")
+	cmd.Flags().StringVar(&opts.Metadata, "metadata", "projects.json", "project metadata JSON, as pipeline-gen reads it, relative to --repo")
+	cmd.Flags().BoolVar(&explain, "explain", false, "show the dependency chain that affects each deployable")
+	cmd.Flags().StringSliceVar(&opts.Deployables.Dirs, "deployable-dir", nil, "projectDir prefix whose projects are deployable, e.g. apps")
+	cmd.Flags().StringSliceVar(&opts.Deployables.Files, "deployable-file", nil, "file that makes its project deployable, e.g. Dockerfile")
+	cmd.Flags().StringSliceVar(&overrides, "deployable-override", nil, "<project>=true|false, winning over every other rule")
+	cmd.RegisterFlagCompletionFunc("mode", cobra.FixedCompletions(affected.Modes, cobra.ShellCompDirectiveNoFileComp))
+	cmd.MarkFlagDirname("repo")
+	cmd.MarkFlagFilename("metadata", "json")
+	return cmd
+}
@@ -1,22 +1,51 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"your-cli/internal/config"
+	"your-cli/internal/gitlab"
 
 	"github.com/spf13/cobra"
-	"gitlab.com/gitlab-org/api/client-go/gitlab"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 func newInitAuthCmd() *cobra.Command {
-	return &cobra.Command{
+	var (
+		rotate bool
+		days   int
+	)
+	cmd := &cobra.Command{
 		Use:   "init-auth",
-		Short: "Authenticate your CLI with GitLab once",
+		Short: "Authenticate your CLI with GitLab once (per --profile)",
+		Long: `Store a GitLab Personal Access Token for the active profile.
+
+--rotate replaces the stored token: GitLab rotates it in place (the old one
+is revoked, the new one valid for --days) if the token has api scope and is
+still valid; otherwise you are asked to paste a new one.`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			fmt.Print("🔑  Paste your GitLab Personal Access Token (read_api scope): ")
+			name, profile, err := config.ActiveProfile()
+			if err != nil {
+				return err
+			}
+			if rotate {
+				err := rotateToken(cmd.Context(), name, profile, days)
+				if err == nil {
+					return nil
+				}
+				if !errors.Is(err, errCannotRotate) {
+					return err
+				}
+				fmt.Printf("%v – paste a new token instead.\n", err)
+			}
+
+			fmt.Printf("🔑  Paste your Personal Access Token for %s (read_api scope): ", profile.BaseURL)
 			byteToken, err := terminal.ReadPassword(int(os.Stdin.Fd()))
 			fmt.Println()
 			if err != nil {
@@ -25,9 +54,9 @@ func newInitAuthCmd() *cobra.Command {
 			token := string(byteToken)
 
 			// quick validation
-			cli, err := gitlab.NewClient(token)
+			cli, err := gitlab.New(token, gitlab.WithBaseURL(profile.BaseURL))
 			if err == nil {
-				_, _, err = cli.Users.CurrentUser()
+				_, err = cli.CurrentUser(cmd.Context())
 			}
 			if err != nil {
 				return fmt.Errorf("token validation failed: %w", err)
@@ -35,8 +64,69 @@ func newInitAuthCmd() *cobra.Command {
 			if err := config.SaveToken(token); err != nil {
 				return err
 			}
-			fmt.Println("✔ Token saved securely – you’re ready to go!")
+			fmt.Printf("✔ Token for profile %s saved securely – you’re ready to go!\n", name)
+			warnNewToken(cmd.Context(), profile, token)
 			return nil
 		},
 	}
+	cmd.Flags().BoolVar(&rotate, "rotate", false, "replace the stored token, rotating it on GitLab if possible")
+	cmd.Flags().IntVar(&days, "days", 90, "days the rotated token is valid for, with --rotate")
+	return cmd
+}
+
+// errCannotRotate is GitLab refusing to rotate the stored token; a new one
+// has to be pasted.
+var errCannotRotate = errors.New("GitLab cannot rotate this token")
+
+// rotateToken swaps the profile's stored token for a new one with the same
+// scopes, valid for days, and revokes the old one.
+func rotateToken(ctx context.Context, name string, profile config.Profile, days int) error {
+	old, err := config.TokenFor(name)
+	if err != nil {
+		return err
+	}
+	cli, err := gitlab.New(old, gitlab.WithBaseURL(profile.BaseURL))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	pat, err := cli.RotateCurrentToken(ctx, time.Now().AddDate(0, 0, days))
+	switch gitlab.StatusCode(err) {
+	case http.StatusUnauthorized:
+		return fmt.Errorf("%w: it is revoked or expired", errCannotRotate)
+	case http.StatusForbidden, http.StatusNotFound:
+		// rotating needs api scope, and GitLab 16.10 or later
+		return fmt.Errorf("%w: it lacks api scope, or %s is older than 16.10", errCannotRotate, profile.BaseURL)
+	}
+	if err != nil {
+		return fmt.Errorf("rotate token: %w", err)
+	}
+	if err := config.SaveToken(pat.Token); err != nil {
+		// the old token is already revoked: don't lose the new one
+		return fmt.Errorf("the token was rotated but could not be saved (%v); the new token is %s", err, pat.Token)
+	}
+	until := "no expiry"
+	if pat.ExpiresAt != nil {
+		until = "valid until " + time.Time(*pat.ExpiresAt).Format(time.DateOnly)
+	}
+	fmt.Printf("✔ Token for profile %s rotated (%s); the old one is revoked.\n", name, until)
+	if os.Getenv("GITLAB_TOKEN") != "" {
+		fmt.Fprintln(os.Stderr, colour(yellow, "Note: $GITLAB_TOKEN is set and overrides the stored token."))
+	}
+	return nil
+}
+
+// warnNewToken tells the user straight away if the token they just saved
+// will soon need replacing, or lacks a scope.
+func warnNewToken(ctx context.Context, profile config.Profile, token string) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	pat, err := inspectToken(ctx, profile.BaseURL, token)
+	if err != nil {
+		return
+	}
+	if problems := tokenProblems(pat, time.Now()); len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, colour(yellow, "Note: "+strings.Join(problems, "; ")+"."))
+	}
 }
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"your-cli/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+	"github.com/your-username/ansible-inventory-go/ansibleinv"
+	"gopkg.in/yaml.v3"
+	"yourcorp/topology"
+)
+
+// Where the monorepo keeps the topology and the per-app inventories,
+// relative to its root, unless its .loki.yaml says otherwise.
+const (
+	defaultTopology    = "topology.yaml"
+	defaultInventories = "ansible/*/inventory.yml"
+)
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Print the shell completion script",
+		Long: `Print the completion script for your shell. App and host names are
+completed from the topology and inventories of the workspace you are in.
+
+  bash:        your-cli completion bash > ~/.local/share/bash-completion/completions/your-cli
+  zsh:         your-cli completion zsh > "${fpath[1]}/_your-cli"
+  fish:        your-cli completion fish > ~/.config/fish/completions/your-cli.fish
+  powershell:  your-cli completion powershell | Out-String | Invoke-Expression`,
+		Args:                  cobra.ExactArgs(1),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, out := cmd.Root(), cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+			return fmt.Errorf("unknown shell %q (want bash, zsh, fish or powershell)", args[0])
+		},
+	}
+}
+
+func newDocsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Generate reference documentation",
+	}
+	var dir string
+	man := &cobra.Command{
+		Use:   "man",
+		Short: "Write a man page for every command",
+		Long: `Write a man page for every command into --dir, e.g.
+
+  your-cli docs man --dir /usr/local/share/man/man1 && man your-cli`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			root := cmd.Root()
+			root.DisableAutoGenTag = true // keep regenerated pages diffable
+			header := &doc.GenManHeader{Title: strings.ToUpper(root.Name()), Section: "1"}
+			if err := doc.GenManTree(root, header, dir); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✔ Man pages written to %s\n", dir)
+			return nil
+		},
+	}
+	man.Flags().StringVar(&dir, "dir", "man", "directory to write the pages to")
+	man.MarkFlagDirname("dir")
+	cmd.AddCommand(man)
+	return cmd
+}
+
+// completeApps completes app names from the workspace's topology. Use it as
+// a ValidArgsFunction or flag completion.
+func completeApps(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	data, err := os.ReadFile(workspaceOrDefault().topology())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	// names only: a topology that does not validate should still complete
+	var raw topology.YAMLTopology
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	apps := make([]string, 0, len(raw.Apps))
+	for name := range raw.Apps {
+		apps = append(apps, name)
+	}
+	return withPrefix(apps, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeHosts completes host names from every per-app inventory in the
+// workspace, skipping inventories that do not parse.
+func completeHosts(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	paths, _ := filepath.Glob(workspaceOrDefault().inventories())
+	seen := map[string]bool{}
+	var hosts []string
+	for _, path := range paths {
+		inv, err := ansibleinv.ParseYAMLFile(path)
+		if err != nil {
+			continue
+		}
+		for name := range inv.Hosts {
+			if !seen[name] {
+				seen[name] = true
+				hosts = append(hosts, name)
+			}
+		}
+	}
+	return withPrefix(hosts, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProfiles completes --profile from the config file.
+func completeProfiles(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	profiles, _ := config.Profiles()
+	names := make([]string, 0, len(profiles))
+	for name, p := range profiles {
+		names = append(names, name+"\t"+p.BaseURL)
+	}
+	return withPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// withPrefix keeps the candidates starting with prefix, sorted.
+func withPrefix(candidates []string, prefix string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
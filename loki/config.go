@@ -2,9 +2,16 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/zalando/go-keyring"
 )
@@ -12,57 +19,445 @@ import (
 const (
 	service   = "your-cli"
 	tokenItem = "gitlab-pat"
+
+	// DefaultProfile is used when no profile is selected. Its keyring item
+	// and the top-level "token" are where tokens lived before profiles.
+	DefaultProfile = "default"
+	envProfile     = "LOKI_PROFILE"            // selects a profile when --profile is not given
+	envNoUpdate    = "YOURCLI_NO_UPDATE_CHECK" // 1 turns the update check off, like update.check: false
+
+	defaultBaseURL = "https://gitlab.com"
 )
 
 type Config struct {
-	Token string `json:"token,omitempty"`
+	Token    string             `json:"token,omitempty"` // the default profile's, from before profiles
+	Update   UpdatePrefs        `json:"update,omitempty"`
+	HTTP     HTTPPrefs          `json:"http,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
 }
 
-func SaveToken(token string) error {
-	// 1. try OS keyring
-	if err := keyring.Set(service, tokenItem, token); err == nil {
-		return nil
+// Profile is one GitLab instance, e.g. gitlab.com and a self-hosted one, and
+// where the monorepo lives on it.
+type Profile struct {
+	BaseURL   string `json:"base_url,omitempty"`   // default https://gitlab.com
+	Token     string `json:"token,omitempty"`      // only when neither the keyring nor tokens.enc is available
+	GroupPath string `json:"group_path,omitempty"` // the group that owns the monorepo
+	RepoURL   string `json:"repo_url,omitempty"`   // SSH clone URL of the monorepo
+}
+
+// ProfileKeys are the settings `config set/get` accept, in list order.
+var ProfileKeys = []string{"base_url", "token", "group_path", "repo_url"}
+
+// UpdatePrefs narrows which releases the update check offers.
+type UpdatePrefs struct {
+	// Pin holds updates to a series: "v1" (any v1.x.y), "v1.4" (any v1.4.x)
+	// or an exact version.
+	Pin string `json:"pin,omitempty"`
+	// Skip lists versions the user declined; newer releases are still offered.
+	Skip []string `json:"skip,omitempty"`
+	// AssetTemplate names the release archive for this platform, e.g.
+	// "your-cli_{{.Version}}_{{.OS}}_{{.Arch}}{{.Ext}}"; empty keeps the default.
+	AssetTemplate string `json:"asset_template,omitempty"`
+	// Check set to false turns the update check off; unset means on.
+	Check *bool `json:"check,omitempty"`
+	// Block is where Strict mode stops a command that is a major version
+	// behind: one of UpdateBlocks, BlockOutsideCI if empty. Elsewhere it only
+	// warns.
+	Block string `json:"block,omitempty"`
+}
+
+// Where Strict mode blocks; see UpdatePrefs.Block.
+const (
+	BlockOutsideCI = "outside-ci" // everywhere but CI, where nobody can run update
+	BlockAlways    = "always"
+	BlockNever     = "never"
+)
+
+// UpdateBlocks are the values UpdatePrefs.Block accepts.
+var UpdateBlocks = []string{BlockOutsideCI, BlockAlways, BlockNever}
+
+// CheckDisabled reports whether the update check is off, by
+// $YOURCLI_NO_UPDATE_CHECK=1 or update.check: false.
+func (p UpdatePrefs) CheckDisabled() bool {
+	if off, err := strconv.ParseBool(os.Getenv(envNoUpdate)); err == nil && off {
+		return true
 	}
-	// 2. fallback to file
-	path, err := filePath()
+	return p.Check != nil && !*p.Check
+}
+
+// HTTPPrefs configures the client used to check for and download updates
+// behind corporate proxies.
+type HTTPPrefs struct {
+	Proxy              string `json:"proxy,omitempty"`     // overrides HTTPS_PROXY
+	CABundle           string `json:"ca_bundle,omitempty"` // extra PEM roots, e.g. a TLS-intercepting proxy's CA
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+var selected string
+
+// SelectProfile makes name the active profile of this process (--profile).
+func SelectProfile(name string) {
+	selected = name
+}
+
+// ProfileName is the active profile: --profile, then $LOKI_PROFILE, then
+// DefaultProfile.
+func ProfileName() string {
+	switch {
+	case selected != "":
+		return selected
+	case os.Getenv(envProfile) != "":
+		return os.Getenv(envProfile)
+	}
+	return DefaultProfile
+}
+
+// ActiveProfile returns the active profile with defaults filled in. Selecting
+// a profile that was never set up is an error; the default always exists.
+func ActiveProfile() (string, Profile, error) {
+	name := ProfileName()
+	cfg, err := load()
+	if err != nil {
+		return name, Profile{}, err
+	}
+	p, err := lookup(cfg, name)
+	return name, p, err
+}
+
+func lookup(cfg Config, name string) (Profile, error) {
+	p, ok := cfg.Profiles[name]
+	if !ok && name != DefaultProfile {
+		return p, fmt.Errorf("unknown profile %q—create it with `your-cli config set --profile %s base_url <url>`", name, name)
+	}
+	if p.BaseURL == "" {
+		p.BaseURL = defaultBaseURL
+	}
+	return p, nil
+}
+
+// Profiles returns every profile in the config file by name, always
+// including the default one. Tokens are not filled in; see TokenFor.
+func Profiles() (map[string]Profile, error) {
+	cfg, err := load()
+	profiles := map[string]Profile{DefaultProfile: {}}
+	for name, p := range cfg.Profiles {
+		profiles[name] = p
+	}
+	for name, p := range profiles {
+		if p.BaseURL == "" {
+			p.BaseURL = defaultBaseURL
+		}
+		p.Token = ""
+		profiles[name] = p
+	}
+	return profiles, err
+}
+
+// Get returns one setting (see ProfileKeys) of a profile.
+func Get(profile, key string) (string, error) {
+	if key == "token" {
+		return TokenFor(profile)
+	}
+	cfg, err := load()
+	if err != nil {
+		return "", err
+	}
+	p, err := lookup(cfg, profile)
+	if err != nil {
+		return "", err
+	}
+	field, err := profileField(&p, key)
+	if err != nil {
+		return "", err
+	}
+	return *field, nil
+}
+
+// Set changes one setting (see ProfileKeys) of a profile, creating the
+// profile if needed. Tokens go to the keyring when there is one, else to
+// the encrypted file.
+func Set(profile, key, value string) error {
+	if key == "token" {
+		return saveTokenFor(profile, value)
+	}
+	cfg, err := load()
 	if err != nil {
 		return err
 	}
-	os.MkdirAll(filepath.Dir(path), 0o700)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	p := cfg.Profiles[profile]
+	field, err := profileField(&p, key)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(Config{Token: token})
+	if key == "base_url" && value != "" {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("base_url %q: want http(s)://host", value)
+		}
+		value = strings.TrimSuffix(value, "/")
+	}
+	*field = value
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	cfg.Profiles[profile] = p
+	return save(cfg)
+}
+
+func profileField(p *Profile, key string) (*string, error) {
+	switch key {
+	case "base_url":
+		return &p.BaseURL, nil
+	case "token":
+		return &p.Token, nil
+	case "group_path":
+		return &p.GroupPath, nil
+	case "repo_url":
+		return &p.RepoURL, nil
+	}
+	return nil, fmt.Errorf("unknown setting %q (want %s)", key, strings.Join(ProfileKeys, ", "))
 }
 
+// SaveToken stores the token of the active profile.
+func SaveToken(token string) error {
+	return saveTokenFor(ProfileName(), token)
+}
+
+func saveTokenFor(profile, token string) error {
+	// 1. try OS keyring, 2. then the encrypted file (see tokenfile.go)
+	stored := keyring.Set(service, keyringItem(profile), token) == nil || sealToken(profile, token) == nil
+	// 3. fallback to plaintext in the config file; other profiles are
+	// recorded there either way, and a stale plaintext copy is dropped
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	plain := token
+	if stored {
+		plain = ""
+	}
+	if profile == DefaultProfile {
+		if cfg.Token == plain {
+			return nil
+		}
+		cfg.Token = plain
+		return save(cfg)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	p := cfg.Profiles[profile]
+	p.Token = plain
+	cfg.Profiles[profile] = p
+	return save(cfg)
+}
+
+// Token returns the token of the active profile; $GITLAB_TOKEN wins over any.
 func Token() (string, error) {
 	// env-var always wins
 	if t := os.Getenv("GITLAB_TOKEN"); t != "" {
 		return t, nil
 	}
-	if t, err := keyring.Get(service, tokenItem); err == nil {
-		return t, nil
+	return TokenFor(ProfileName())
+}
+
+// TokenFor returns a profile's stored token, ignoring $GITLAB_TOKEN.
+func TokenFor(profile string) (string, error) {
+	t, _, err := lookupToken(profile)
+	if err != nil || t != "" {
+		return t, err
+	}
+	if profile == DefaultProfile {
+		return "", fmt.Errorf("no token found—run `your-cli init-auth`")
+	}
+	return "", fmt.Errorf("no token for profile %q—run `your-cli init-auth --profile %s`", profile, profile)
+}
+
+// Where a token is stored, as TokenStore reports it.
+const (
+	StoreKeyring   = "keyring"
+	StoreEncrypted = "encrypted file"
+	StorePlaintext = "config file (plaintext)"
+)
+
+// TokenStore reports where profile's token is stored; "" if nowhere.
+func TokenStore(profile string) (string, error) {
+	_, store, err := lookupToken(profile)
+	return store, err
+}
+
+// lookupToken finds profile's token in the keyring, then tokens.enc, then
+// the config file, whose plaintext tokens move to tokens.enc once there is
+// a key for it. An empty token without an error means there is none.
+//
+// A tokens.enc that cannot be read, say for want of $LOKI_TOKEN_PASSPHRASE,
+// is logged and skipped for a plaintext token; without one its error is
+// returned, as the token is most likely in it.
+func lookupToken(profile string) (token, store string, err error) {
+	if t, err := keyring.Get(service, keyringItem(profile)); err == nil {
+		return t, StoreKeyring, nil
+	}
+	sealed, sealedErr := loadSealed()
+	if t := sealed[profile]; t != "" {
+		return t, StoreEncrypted, nil
 	}
 	// check file fallback
-	path, err := filePath()
+	cfg, err := load()
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	token = cfg.Profiles[profile].Token
+	if token == "" && profile == DefaultProfile {
+		token = cfg.Token
+	}
+	if sealedErr != nil {
+		if token == "" {
+			return "", "", sealedErr
+		}
+		slog.Warn("could not read the encrypted tokens, using the plaintext one in the config file", "profile", profile, "err", sealedErr)
+		return token, StorePlaintext, nil
+	}
+	if token == "" {
+		return "", "", nil
+	}
+	if migratePlaintext(cfg) == nil {
+		return token, StoreEncrypted, nil
+	}
+	return token, StorePlaintext, nil
+}
+
+// keyringItem keeps the default profile on the item used before profiles.
+func keyringItem(profile string) string {
+	if profile == DefaultProfile {
+		return tokenItem
 	}
-	f, err := os.Open(path)
+	return tokenItem + "/" + profile
+}
+
+// Update returns the update preferences; a missing config file means none.
+func Update() (UpdatePrefs, error) {
+	cfg, err := load()
+	if err == nil && cfg.Update.Block != "" && !slices.Contains(UpdateBlocks, cfg.Update.Block) {
+		err = fmt.Errorf("update.block %q is not one of %s", cfg.Update.Block, strings.Join(UpdateBlocks, ", "))
+	}
+	return cfg.Update, err
+}
+
+// HTTP returns the HTTP client preferences; a missing config file means none.
+func HTTP() (HTTPPrefs, error) {
+	cfg, err := load()
+	return cfg.HTTP, err
+}
+
+// SkipVersion stops the update notice from offering version.
+func SkipVersion(version string) error {
+	cfg, err := load()
 	if err != nil {
-		return "", fmt.Errorf("no token found—run `your-cli init-auth`: %w", err)
+		return err
 	}
-	defer f.Close()
-	var cfg Config
-	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+	if !slices.Contains(cfg.Update.Skip, version) {
+		cfg.Update.Skip = append(cfg.Update.Skip, version)
+	}
+	return save(cfg)
+}
+
+// state is what the CLI remembers between runs. Unlike Config nobody edits
+// it and losing it is harmless, so it lives in the cache directory.
+type state struct {
+	TokenChecked map[string]time.Time `json:"token_checked,omitempty"` // by profile
+}
+
+// TokenCheckDue reports whether profile's token was last checked (see
+// MarkTokenChecked) longer than every ago, or never.
+func TokenCheckDue(profile string, every time.Duration) bool {
+	st, _ := loadState()
+	return time.Since(st.TokenChecked[profile]) > every
+}
+
+// MarkTokenChecked records that profile's token was checked just now.
+func MarkTokenChecked(profile string) error {
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	if st.TokenChecked == nil {
+		st.TokenChecked = map[string]time.Time{}
+	}
+	st.TokenChecked[profile] = time.Now()
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	return os.WriteFile(path, raw, 0o600)
+}
+
+func loadState() (state, error) {
+	var st state
+	path, err := statePath()
+	if err != nil {
+		return st, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, err
+	}
+	// a corrupt state file is as good as none
+	json.Unmarshal(raw, &st)
+	return st, nil
+}
+
+func statePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
 		return "", err
 	}
-	if cfg.Token == "" {
-		return "", fmt.Errorf("token empty—run `your-cli init-auth`")
+	return filepath.Join(dir, "your-cli", "state.json"), nil
+}
+
+// Path is the config file, which holds the token when neither the keyring
+// nor the encrypted file is available.
+func Path() (string, error) {
+	return filePath()
+}
+
+func load() (Config, error) {
+	var cfg Config
+	path, err := filePath()
+	if err != nil {
+		return cfg, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
 	}
-	return cfg.Token, nil
+	return cfg, nil
+}
+
+func save(cfg Config) error {
+	path, err := filePath()
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(cfg)
 }
 
 func filePath() (string, error) {
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"your-cli/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show and change the settings of your GitLab profiles",
+		Long: `A profile holds the settings for one GitLab instance: its base_url, your
+token, the group_path that owns the monorepo and the monorepo's repo_url.
+Commands use the profile named by --profile, then $LOKI_PROFILE, then
+"default", e.g.
+
+  your-cli config set --profile corp base_url https://gitlab.corp.example
+  your-cli config set --profile corp repo_url git@gitlab.corp.example:platform/asgard.git
+  your-cli init-auth --profile corp
+  LOKI_PROFILE=corp your-cli doctor`,
+	}
+	cmd.AddCommand(newConfigSetCmd(), newConfigGetCmd(), newConfigListCmd(), newConfigWorkspaceCmd())
+	return cmd
+}
+
+func newConfigSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change a setting of the active profile, creating it if needed",
+		Long: `Change a setting of the active profile, creating it if needed. Keys are
+` + strings.Join(config.ProfileKeys, ", ") + `. Prefer init-auth for the token: it
+validates it and keeps it out of your shell history.`,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: completeProfileKey,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := config.ProfileName()
+			if err := config.Set(name, args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "✔ %s set for profile %s\n", args[0], name)
+			return nil
+		},
+	}
+}
+
+func newConfigGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "get <key>",
+		Short:             "Print a setting of the active profile",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeProfileKey,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, err := config.Get(config.ProfileName(), args[0])
+			if err != nil {
+				return err
+			}
+			return writeResult(cmd.OutOrStdout(), map[string]string{args[0]: value}, func(out io.Writer) error {
+				_, err := fmt.Fprintln(out, value)
+				return err
+			})
+		},
+	}
+}
+
+func newConfigListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the profiles; * marks the active one",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			profiles, err := config.Profiles()
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			active := config.ProfileName()
+			rows := make([]profileRow, len(names))
+			for i, name := range names {
+				p := profiles[name]
+				_, err := config.TokenFor(name)
+				rows[i] = profileRow{Name: name, Active: name == active, BaseURL: p.BaseURL,
+					GroupPath: p.GroupPath, RepoURL: p.RepoURL, Token: err == nil}
+			}
+			return writeResult(cmd.OutOrStdout(), rows, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "\tPROFILE\tBASE URL\tGROUP\tREPO\tTOKEN")
+				for _, r := range rows {
+					mark, token := "", "-"
+					if r.Active {
+						mark = "*"
+					}
+					if r.Token {
+						token = "stored"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", mark, r.Name, r.BaseURL, orDash(r.GroupPath), orDash(r.RepoURL), token)
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
+
+// profileRow is one profile in config list. The token itself is never shown.
+type profileRow struct {
+	Name      string `json:"name"`
+	Active    bool   `json:"active"`
+	BaseURL   string `json:"base_url"`
+	GroupPath string `json:"group_path"`
+	RepoURL   string `json:"repo_url"`
+	Token     bool   `json:"token_stored"`
+}
+
+// completeProfileKey completes the key, the first argument of set and get.
+func completeProfileKey(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return withPrefix(config.ProfileKeys, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,462 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"your-cli/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/ansible-inventory-go/ansibleinv"
+)
+
+// minGitVersion is the oldest git the monorepo tooling is tested with
+// (sparse-checkout and `git switch`).
+const minGitVersion = "2.25"
+
+type doctorStatus int
+
+const (
+	doctorPass doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+func (s doctorStatus) String() string {
+	return [...]string{"PASS", "WARN", "FAIL"}[s]
+}
+
+// MarshalText makes the status PASS, WARN or FAIL in --output json and yaml.
+func (s doctorStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// doctorResult is one line of `your-cli doctor` output.
+type doctorResult struct {
+	Name   string       `json:"name"`
+	Status doctorStatus `json:"status"`
+	Detail string       `json:"detail"`         // what was found
+	Hint   string       `json:"hint,omitempty"` // how to fix it; empty for passes
+}
+
+type doctorOptions struct {
+	sshHost     string
+	repo        string
+	topology    string
+	inventories string
+	stale       time.Duration
+}
+
+func newDoctorCmd() *cobra.Command {
+	var opts doctorOptions
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the token, tools, config and monorepo clone",
+		Long: `doctor checks everything the other commands rely on and prints PASS,
+WARN or FAIL for each, with a hint on how to fix what is wrong:
+
+  • the active profile's token: valid, not about to expire, api or read_api scope
+  • SSH access to that GitLab instance
+  • git (at least ` + minGitVersion + `) and graphviz
+  • permissions on the config file that holds the token
+  • the workspace's .loki.yaml, if there is one
+  • how long ago the monorepo clone was fetched, and how far behind it is
+  • whether the topology and the Ansible inventories in the clone parse
+
+It exits non-zero only when a check fails, whatever the --output; warnings
+are advice.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			results := runDoctor(cmd.Context(), opts)
+			err := writeResult(cmd.OutOrStdout(), results, func(out io.Writer) error {
+				printDoctor(out, results, isTerminal())
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			failed := 0
+			for _, r := range results {
+				if r.Status == doctorFail {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&opts.sshHost, "ssh-host", "", "SSH destination to test (default from the profile's repo_url or base_url)")
+	cmd.Flags().StringVar(&opts.repo, "repo", "", "monorepo clone to check (default the workspace root)")
+	cmd.Flags().StringVar(&opts.topology, "topology", "", "topology file, relative to --repo (default the workspace's, "+defaultTopology+")")
+	cmd.Flags().StringVar(&opts.inventories, "inventories", "", "glob of Ansible inventories, relative to --repo (default the workspace's, "+defaultInventories+")")
+	cmd.Flags().DurationVar(&opts.stale, "stale", 7*24*time.Hour, "warn when the clone was last fetched longer ago than this")
+	cmd.MarkFlagDirname("repo")
+	cmd.MarkFlagFilename("topology", "yaml", "yml")
+	return cmd
+}
+
+// runDoctor runs every check in order. None of them stops the others.
+func runDoctor(ctx context.Context, opts doctorOptions) []doctorResult {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.sshHost == "" {
+		_, profile, _ := config.ActiveProfile()
+		opts.sshHost = sshTarget(profile)
+	}
+	ws := workspaceOrDefault()
+	if opts.repo == "" {
+		opts.repo = ws.Root
+	}
+	if opts.topology == "" {
+		opts.topology = ws.Topology
+	}
+	if opts.inventories == "" {
+		opts.inventories = ws.Inventories
+	}
+	return []doctorResult{
+		checkToken(ctx),
+		checkSSH(ctx, opts.sshHost),
+		checkGit(ctx),
+		checkGraphviz(ctx),
+		checkConfigPerms(),
+		checkWorkspace(),
+		checkClone(ctx, opts.repo, opts.stale),
+		checkTopology(filepath.Join(opts.repo, opts.topology)),
+		checkInventories(filepath.Join(opts.repo, opts.inventories)),
+	}
+}
+
+// printDoctor writes the results as text.
+func printDoctor(w io.Writer, results []doctorResult, useColour bool) {
+	const green = 32
+	codes := map[doctorStatus]int{doctorPass: green, doctorWarn: yellow, doctorFail: red}
+
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Name))
+	}
+	for _, r := range results {
+		status := r.Status.String()
+		if useColour {
+			status = colour(codes[r.Status], status)
+		}
+		fmt.Fprintf(w, "%s  %-*s  %s\n", status, width, r.Name, r.Detail)
+		if r.Hint != "" {
+			fmt.Fprintf(w, "      %*s  → %s\n", width, "", r.Hint)
+		}
+	}
+}
+
+func checkToken(ctx context.Context) doctorResult {
+	r := doctorResult{Name: "GitLab token"}
+	name, profile, err := config.ActiveProfile()
+	if err != nil {
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	}
+	r.Name += " (" + name + ")"
+	token, err := config.Token()
+	if err != nil {
+		r.Status, r.Detail, r.Hint = doctorFail, err.Error(), "run `your-cli init-auth --profile "+name+"`"
+		return r
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	pat, err := inspectToken(ctx, profile.BaseURL, token)
+	switch {
+	case errors.Is(err, errTokenRejected):
+		r.Status, r.Detail, r.Hint = doctorFail, profile.BaseURL+" rejected the token (revoked or expired)", "create a new token and run `your-cli init-auth --profile "+name+"`"
+		return r
+	case err != nil:
+		r.Status, r.Detail, r.Hint = doctorFail, fmt.Sprintf("could not reach GitLab: %v", err), "check your network or the proxy in "+configPathOrDefault()
+		return r
+	}
+
+	r.Detail = "scopes " + strings.Join(pat.Scopes, ", ")
+	switch {
+	case slices.Contains(pat.Scopes, "api"):
+	case canReadAPI(pat):
+		// enough for init-auth and updates
+		r.Detail += " (bootstrap --gitlab-project needs api)"
+	default:
+		r.Status = doctorFail
+		r.Hint = "create a token with read_api (or api) scope and run `your-cli init-auth`"
+	}
+	if pat.ExpiresAt != nil {
+		expires := time.Time(*pat.ExpiresAt)
+		r.Detail += ", expires " + expires.Format(time.DateOnly)
+		if r.Status != doctorFail && time.Until(expires) < tokenExpiryNotice {
+			r.Status = doctorWarn
+			r.Hint = strings.TrimPrefix(r.Hint+"; rotate it with `your-cli init-auth --rotate"+profileFlag(name)+"`", "; ")
+		}
+	}
+	if os.Getenv("GITLAB_TOKEN") == "" {
+		if store, _ := config.TokenStore(name); store != "" {
+			r.Detail += ", in the " + store
+			if store == config.StorePlaintext && r.Status == doctorPass {
+				r.Status = doctorWarn
+				r.Hint = "there is no keyring: set $LOKI_TOKEN_PASSPHRASE to keep the token in an encrypted file instead"
+			}
+		}
+	}
+	return r
+}
+
+func checkSSH(ctx context.Context, host string) doctorResult {
+	r := doctorResult{Name: "SSH"}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "ssh", "-T", "-o", "BatchMode=yes", "-o", "ConnectTimeout=5", host).CombinedOutput()
+	// GitLab greets and exits 0; some servers greet and exit 1
+	if welcome := strings.Contains(string(out), "Welcome"); err == nil || welcome {
+		r.Detail = host + " accepts your key"
+		return r
+	}
+	r.Status = doctorFail
+	r.Detail = fmt.Sprintf("%s: %s", host, firstLine(out, err))
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		r.Hint = "install an OpenSSH client"
+	case strings.Contains(string(out), "Permission denied"):
+		r.Hint = "load your key with `ssh-add` and add the public key under GitLab → Preferences → SSH Keys"
+	default:
+		r.Hint = "check your network or VPN; port 22 to " + host + " must be reachable"
+	}
+	return r
+}
+
+func checkGit(ctx context.Context) doctorResult {
+	r := doctorResult{Name: "git"}
+	out, err := exec.CommandContext(ctx, "git", "--version").Output()
+	if err != nil {
+		r.Status, r.Detail, r.Hint = doctorFail, firstLine(out, err), "install git "+minGitVersion+" or later"
+		return r
+	}
+	// "git version 2.43.0" or "git version 2.39.3 (Apple Git-146)"
+	fields := strings.Fields(string(out))
+	if len(fields) < 3 {
+		r.Status, r.Detail = doctorWarn, "cannot parse "+strings.TrimSpace(string(out))
+		return r
+	}
+	version := fields[2]
+	r.Detail = version
+	if olderThan(version, minGitVersion) {
+		r.Status, r.Hint = doctorWarn, "upgrade git to "+minGitVersion+" or later"
+	}
+	return r
+}
+
+func checkGraphviz(ctx context.Context) doctorResult {
+	r := doctorResult{Name: "graphviz"}
+	if _, err := exec.LookPath("dot"); err != nil {
+		r.Status, r.Detail = doctorWarn, "dot not found on PATH; graphs can only be written as DOT"
+		r.Hint = "install graphviz (brew install graphviz, apt install graphviz)"
+		return r
+	}
+	// dot -V prints its version to stderr
+	out, _ := exec.CommandContext(ctx, "dot", "-V").CombinedOutput()
+	r.Detail = firstLine(out, nil)
+	return r
+}
+
+func checkWorkspace() doctorResult {
+	r := doctorResult{Name: "workspace"}
+	ws, err := currentWorkspace()
+	switch {
+	case err != nil:
+		r.Status, r.Detail = doctorFail, err.Error()
+		r.Hint = "fix " + workspaceFile + ", see `your-cli config workspace --help`"
+	case ws.Config == "":
+		r.Detail = ws.Root + " (no " + workspaceFile + ", defaults)"
+	default:
+		r.Detail = ws.Config
+	}
+	return r
+}
+
+func checkConfigPerms() doctorResult {
+	r := doctorResult{Name: "config file"}
+	path, err := config.Path()
+	if err != nil {
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	}
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		r.Detail = "none yet (" + path + ")"
+		return r
+	case err != nil:
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	case runtime.GOOS == "windows":
+		r.Detail = path + " (permissions not checked on Windows)"
+		return r
+	}
+	r.Detail = fmt.Sprintf("%s %v", path, info.Mode().Perm())
+	// the file may hold the token when the keyring is unavailable
+	if info.Mode().Perm()&0o077 != 0 {
+		r.Status, r.Hint = doctorFail, "chmod 600 "+path
+		return r
+	}
+	if dir, err := os.Stat(filepath.Dir(path)); err == nil && dir.Mode().Perm()&0o077 != 0 {
+		r.Status, r.Hint = doctorWarn, "chmod 700 "+filepath.Dir(path)
+	}
+	return r
+}
+
+func checkClone(ctx context.Context, repo string, stale time.Duration) doctorResult {
+	r := doctorResult{Name: "monorepo clone"}
+	git := func(args ...string) (string, error) {
+		out, err := exec.CommandContext(ctx, "git", append([]string{"-C", repo}, args...)...).Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		r.Status, r.Detail, r.Hint = doctorFail, repo+" is not a git clone", "run doctor from the monorepo or pass --repo"
+		return r
+	}
+	r.Detail = top
+
+	fetchHead, err := git("rev-parse", "--git-path", "FETCH_HEAD")
+	if err == nil && !filepath.IsAbs(fetchHead) {
+		fetchHead = filepath.Join(repo, fetchHead)
+	}
+	info, err := os.Stat(fetchHead)
+	if err != nil {
+		r.Status, r.Hint = doctorWarn, "git -C "+top+" pull --ff-only"
+		r.Detail += ", never fetched"
+		return r
+	}
+	age := time.Since(info.ModTime())
+	r.Detail += ", fetched " + age.Round(time.Hour).String() + " ago"
+	if age > stale {
+		r.Status, r.Hint = doctorWarn, "git -C "+top+" pull --ff-only"
+	}
+
+	// without an upstream there is nothing to be behind
+	if behind, err := git("rev-list", "--count", "HEAD..@{upstream}"); err == nil && behind != "0" {
+		r.Detail += ", " + behind + " commit(s) behind upstream"
+		r.Status, r.Hint = doctorWarn, "git -C "+top+" pull --ff-only"
+	}
+	return r
+}
+
+func checkTopology(path string) doctorResult {
+	r := doctorResult{Name: "topology"}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		r.Status, r.Detail, r.Hint = doctorWarn, path+" not found", "pass --topology if the file lives elsewhere"
+		return r
+	}
+	if err != nil {
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	}
+	graph, err := parseTopology(data)
+	if err != nil {
+		r.Status, r.Detail = doctorFail, fmt.Sprintf("%s: %s", path, firstLine([]byte(err.Error()), nil))
+		r.Hint = "fix the file; nothing that reads the topology will work until it parses"
+		return r
+	}
+	r.Detail = fmt.Sprintf("%s, %d nodes", path, len(graph.Nodes))
+	return r
+}
+
+func checkInventories(pattern string) doctorResult {
+	r := doctorResult{Name: "inventories"}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		r.Status, r.Detail = doctorFail, err.Error()
+		return r
+	}
+	if len(paths) == 0 {
+		r.Status, r.Detail, r.Hint = doctorWarn, "nothing matches "+pattern, "pass --inventories if they live elsewhere"
+		return r
+	}
+
+	var broken []string
+	hosts := 0
+	for _, path := range paths {
+		inv, err := ansibleinv.ParseYAMLFile(path)
+		if err != nil {
+			broken = append(broken, fmt.Sprintf("%s: %s", path, firstLine([]byte(err.Error()), nil)))
+			continue
+		}
+		hosts += len(inv.Hosts)
+	}
+	if len(broken) > 0 {
+		r.Status = doctorFail
+		r.Detail = fmt.Sprintf("%d of %d do not parse: %s", len(broken), len(paths), strings.Join(broken, "; "))
+		r.Hint = "fix the files listed; deploys from them will fail"
+		return r
+	}
+	r.Detail = fmt.Sprintf("%d parsed, %d hosts", len(paths), hosts)
+	return r
+}
+
+// sshTarget is user@host of the profile's clone URL, either
+// git@host:group/repo.git or ssh://git@host/group/repo.git, or else
+// git@ the base URL's host.
+func sshTarget(p config.Profile) string {
+	if u, err := url.Parse(p.RepoURL); err == nil && u.Scheme == "ssh" && u.User != nil {
+		return u.User.Username() + "@" + u.Hostname()
+	}
+	if target, _, ok := strings.Cut(p.RepoURL, ":"); ok && strings.Contains(target, "@") {
+		return target
+	}
+	host := "gitlab.com"
+	if u, err := url.Parse(p.BaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return "git@" + host
+}
+
+// olderThan compares dotted numeric versions, ignoring anything after the
+// numbers (e.g. "2.39.3.windows.1").
+func olderThan(version, min string) bool {
+	have, want := strings.Split(version, "."), strings.Split(min, ".")
+	for i, w := range want {
+		wn, _ := strconv.Atoi(w)
+		hn := 0
+		if i < len(have) {
+			hn, _ = strconv.Atoi(have[i])
+		}
+		if hn != wn {
+			return hn < wn
+		}
+	}
+	return false
+}
+
+// firstLine is the first line of a command's output, or its error.
+func firstLine(out []byte, err error) string {
+	if line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n"); line != "" {
+		return line
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func configPathOrDefault() string {
+	if path, err := config.Path(); err == nil {
+		return path
+	}
+	return "the config file"
+}
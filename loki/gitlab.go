@@ -0,0 +1,290 @@
+// Package gitlab is the one GitLab API client of the CLI: the commands, the
+// updater and the release command all talk to GitLab through it, so they
+// agree on the server, how to authenticate, when to retry and how fast to
+// go.
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	api "gitlab.com/gitlab-org/api/client-go/gitlab"
+	"golang.org/x/time/rate"
+)
+
+// DefaultBaseURL is the GitLab a client talks to unless told otherwise.
+const DefaultBaseURL = "https://gitlab.com"
+
+// maxRetryDelay caps both the exponential backoff and any Retry-After value.
+const maxRetryDelay = time.Minute
+
+// The API objects callers get back.
+type (
+	User                = api.User
+	Group               = api.Group
+	Release             = api.Release
+	PersonalAccessToken = api.PersonalAccessToken
+	Environment         = api.Environment
+)
+
+// Client is a GitLab API client for one server and token.
+type Client struct {
+	api *api.Client
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+type options struct {
+	baseURL    string
+	jobToken   bool
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	limit      rate.Limit // 0 follows GitLab's RateLimit headers
+	burst      int
+	log        *slog.Logger
+}
+
+// WithBaseURL sets the GitLab instance, e.g. https://gitlab.corp.example;
+// empty keeps DefaultBaseURL.
+func WithBaseURL(u string) Option {
+	return func(o *options) {
+		if u != "" {
+			o.baseURL = u
+		}
+	}
+}
+
+// WithJobToken authenticates with a CI job token ($CI_JOB_TOKEN) instead of
+// a personal, project or group access token.
+func WithJobToken() Option { return func(o *options) { o.jobToken = true } }
+
+// WithHTTPClient sends the requests with c, e.g. one set up for a proxy.
+func WithHTTPClient(c *http.Client) Option { return func(o *options) { o.httpClient = c } }
+
+// WithRetries retries network errors, 429 and 5xx responses up to n times,
+// waiting backoff at first and twice as long after every attempt. A
+// Retry-After header overrides the computed delay. 0 disables retries.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(o *options) { o.retries, o.backoff = n, backoff }
+}
+
+// WithRateLimit holds the client to perSecond requests, in bursts of up to
+// burst. Without it the client follows the RateLimit headers GitLab sends.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(o *options) { o.limit, o.burst = rate.Limit(perSecond), max(burst, 1) }
+}
+
+// WithLogger logs retries to l.
+func WithLogger(l *slog.Logger) Option { return func(o *options) { o.log = l } }
+
+// New returns a client authenticating with token.
+func New(token string, opts ...Option) (*Client, error) {
+	o := &options{
+		baseURL: DefaultBaseURL,
+		retries: 4,
+		backoff: time.Second,
+		log:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	clientOpts := []api.ClientOptionFunc{
+		api.WithBaseURL(o.baseURL),
+		api.WithCustomLeveledLogger(o.log),
+	}
+	if o.httpClient != nil {
+		clientOpts = append(clientOpts, api.WithHTTPClient(o.httpClient))
+	}
+	if o.retries > 0 {
+		clientOpts = append(clientOpts,
+			api.WithCustomRetryMax(o.retries),
+			api.WithCustomRetryWaitMinMax(o.backoff, maxRetryDelay),
+			// exponential, and honours Retry-After
+			api.WithCustomBackoff(retryablehttp.DefaultBackoff))
+	} else {
+		clientOpts = append(clientOpts, api.WithoutRetries())
+	}
+	if o.limit > 0 {
+		clientOpts = append(clientOpts, api.WithCustomLimiter(rate.NewLimiter(o.limit, o.burst)))
+	}
+
+	newClient := api.NewClient
+	if o.jobToken {
+		newClient = api.NewJobClient
+	}
+	cli, err := newClient(token, clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("create gitlab client: %w", err)
+	}
+	return &Client{api: cli}, nil
+}
+
+// StatusCode is the HTTP status GitLab answered err with; 0 if err is not
+// an API error.
+func StatusCode(err error) int {
+	var resp *api.ErrorResponse
+	switch {
+	case errors.As(err, &resp) && resp.Response != nil:
+		return resp.Response.StatusCode
+	case errors.Is(err, api.ErrNotFound): // client-go's stand-in for a 404
+		return http.StatusNotFound
+	}
+	return 0
+}
+
+// CurrentUser is the user the token belongs to.
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	u, _, err := c.api.Users.CurrentUser(api.WithContext(ctx))
+	return u, err
+}
+
+// Group is the group at path, e.g. your-group; GitLab answers 404 for
+// groups the token cannot see.
+func (c *Client) Group(ctx context.Context, path string) (*Group, error) {
+	g, _, err := c.api.Groups.GetGroup(path, nil, api.WithContext(ctx))
+	return g, err
+}
+
+// CurrentToken is the token the client authenticates with: its scopes and
+// expiry.
+func (c *Client) CurrentToken(ctx context.Context) (*PersonalAccessToken, error) {
+	pat, _, err := c.api.PersonalAccessTokens.GetSinglePersonalAccessToken(api.WithContext(ctx))
+	return pat, err
+}
+
+// RotateCurrentToken replaces the token the client authenticates with by a
+// new one with the same scopes, valid until expires, and revokes the old
+// one. The client keeps using the old, now revoked, token.
+func (c *Client) RotateCurrentToken(ctx context.Context, expires time.Time) (*PersonalAccessToken, error) {
+	at := api.ISOTime(expires)
+	pat, _, err := c.api.PersonalAccessTokens.RotatePersonalAccessTokenSelf(
+		&api.RotatePersonalAccessTokenOptions{ExpiresAt: &at}, api.WithContext(ctx))
+	return pat, err
+}
+
+// Releases lists the latest n releases of project, a path or numeric ID,
+// newest first.
+func (c *Client) Releases(ctx context.Context, project string, n int) ([]*Release, error) {
+	rels, _, err := c.api.Releases.ListReleases(project, &api.ListReleasesOptions{
+		ListOptions: api.ListOptions{PerPage: int64(n)},
+	}, api.WithContext(ctx))
+	return rels, err
+}
+
+// Release is project's release for tag.
+func (c *Client) Release(ctx context.Context, project, tag string) (*Release, error) {
+	rel, _, err := c.api.Releases.GetRelease(project, tag, api.WithContext(ctx))
+	return rel, err
+}
+
+// Environments lists every available environment of project.
+func (c *Client) Environments(ctx context.Context, project string) ([]*Environment, error) {
+	opt := &api.ListEnvironmentsOptions{
+		States:      api.Ptr("available"),
+		ListOptions: api.ListOptions{PerPage: 100},
+	}
+	var envs []*Environment
+	for {
+		page, resp, err := c.api.Environments.ListEnvironments(project, opt, api.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		envs = append(envs, page...)
+		if resp.NextPage == 0 {
+			return envs, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// EnvironmentURLs maps the name of every available environment of project
+// to its external URL, empty if it has none.
+func (c *Client) EnvironmentURLs(ctx context.Context, project string) (map[string]string, error) {
+	envs, err := c.Environments(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	urls := make(map[string]string, len(envs))
+	for _, env := range envs {
+		urls[env.Name] = env.ExternalURL
+	}
+	return urls, nil
+}
+
+// NewRelease is a release to create.
+type NewRelease struct {
+	Tag         string
+	Name        string
+	Description string
+	ReleasedAt  *time.Time // a future date makes GitLab list the release as upcoming
+	Links       []ReleaseLink
+}
+
+// ReleaseLink is an asset link of a release.
+type ReleaseLink struct {
+	Name string
+	URL  string
+	Type string // other, runbook, image or package
+}
+
+// CreateRelease creates a release in project for rel.Tag.
+func (c *Client) CreateRelease(ctx context.Context, project string, rel NewRelease) error {
+	opt := &api.CreateReleaseOptions{
+		Name:        api.Ptr(rel.Name),
+		TagName:     api.Ptr(rel.Tag),
+		Description: api.Ptr(rel.Description),
+		ReleasedAt:  rel.ReleasedAt,
+	}
+	if len(rel.Links) > 0 {
+		opt.Assets = &api.ReleaseAssetsOptions{}
+		for _, l := range rel.Links {
+			opt.Assets.Links = append(opt.Assets.Links, &api.ReleaseAssetLinkOptions{
+				Name:     api.Ptr(l.Name),
+				URL:      api.Ptr(l.URL),
+				LinkType: api.Ptr(api.LinkTypeValue(l.Type)),
+			})
+		}
+	}
+	_, _, err := c.api.Releases.CreateRelease(project, opt, api.WithContext(ctx))
+	return err
+}
+
+// PackageFileURL is the download URL of a file in project's generic
+// package registry.
+func (c *Client) PackageFileURL(project, pkg, version, file string) string {
+	return strings.TrimSuffix(c.api.BaseURL().String(), "/") + fmt.Sprintf("/projects/%s/packages/generic/%s/%s/%s",
+		url.PathEscape(project), url.PathEscape(pkg), url.PathEscape(version), url.PathEscape(file))
+}
+
+// UploadPackageFile uploads content as a file of project's generic package
+// pkg at version.
+func (c *Client) UploadPackageFile(ctx context.Context, project, pkg, version, file string, content io.Reader) error {
+	_, _, err := c.api.GenericPackages.PublishPackageFile(project, pkg, version, file, content, nil, api.WithContext(ctx))
+	return err
+}
+
+// CreateMergeRequest opens a merge request from source into target, which
+// deletes source once merged, and returns its web URL.
+func (c *Client) CreateMergeRequest(ctx context.Context, project, source, target, title string) (string, error) {
+	mr, _, err := c.api.MergeRequests.CreateMergeRequest(project, &api.CreateMergeRequestOptions{
+		Title:              api.Ptr(title),
+		SourceBranch:       api.Ptr(source),
+		TargetBranch:       api.Ptr(target),
+		RemoveSourceBranch: api.Ptr(true),
+	}, api.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	return mr.WebURL, nil
+}
@@ -0,0 +1,491 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"your-cli/internal/invedit"
+
+	"github.com/spf13/cobra"
+	"github.com/your-username/ansible-inventory-go/ansibleinv"
+	"gopkg.in/yaml.v3"
+)
+
+// invFlags are the inventory selection flags every inv subcommand shares.
+type invFlags struct {
+	apps  []string
+	paths []string
+	limit string
+}
+
+func newInvCmd() *cobra.Command {
+	var f invFlags
+	cmd := &cobra.Command{
+		Use:   "inv",
+		Short: "Browse, compare and edit the Ansible inventories",
+		Long: `Work with the per-app Ansible inventories. --app sor reads
+ansible/sor/inventory.yml under the workspace root (or as the workspace's
+inventories say: see 'your-cli config workspace'), -i reads any inventory
+file, directory or dynamic inventory script; repeat either to combine
+several. With neither, every app's inventory is read, e.g.
+
+  your-cli inv graph --app sor
+  your-cli inv host sor-01 --sources
+  your-cli inv list --limit 'prod:&web' --table
+  your-cli inv list --limit prod --ssh-config
+  your-cli inv diff --app sor --ref origin/main
+  your-cli inv lint --strict
+  your-cli inv edit --app sor --app gateway
+
+These replace the inventory viewer and editor binaries.`,
+	}
+	cmd.PersistentFlags().StringSliceVar(&f.apps, "app", nil, "app whose inventory to use, from the workspace's (default "+defaultInventories+")")
+	cmd.PersistentFlags().StringSliceVarP(&f.paths, "inventory", "i", nil, "inventory file, directory or executable script")
+	cmd.PersistentFlags().StringVar(&f.limit, "limit", "", "only hosts matching an Ansible host pattern, e.g. 'prod:&web:!canary'")
+	cmd.RegisterFlagCompletionFunc("app", completeApps)
+	cmd.MarkPersistentFlagFilename("inventory", "yml", "yaml", "json")
+	cmd.AddCommand(
+		newInvGraphCmd(&f),
+		newInvHostCmd(&f),
+		newInvListCmd(&f),
+		newInvDiffCmd(&f),
+		newInvLintCmd(&f),
+		newInvEditCmd(&f),
+	)
+	return cmd
+}
+
+func newInvGraphCmd(f *invFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "graph",
+		Short: "Print every group and its hosts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			inv, err := f.load(cmd.Context())
+			if err != nil {
+				return err
+			}
+			// each group's own hosts, not its children's
+			groups := map[string][]string{}
+			for name, group := range inv.Groups {
+				groups[name] = append([]string{}, sortedKeys(group.Hosts)...)
+			}
+			return writeResult(cmd.OutOrStdout(), groups, func(out io.Writer) error {
+				for _, name := range sortedKeys(groups) {
+					fmt.Fprintf(out, "@%s:\n", name)
+					if len(groups[name]) == 0 {
+						fmt.Fprintln(out, "  |-- (no hosts in this group directly)")
+					}
+					for _, host := range groups[name] {
+						fmt.Fprintf(out, "  |-- %s\n", host)
+					}
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func newInvHostCmd(f *invFlags) *cobra.Command {
+	var sources bool
+	cmd := &cobra.Command{
+		Use:   "host <name>",
+		Short: "Print the variables ansible resolves for a host",
+		Long: `Print the variables ansible resolves for a host, as YAML. --sources shows
+instead which group set each one (or the host itself) and the groups whose
+value it overrides.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeHosts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inv, err := f.load(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if !sources {
+				vars, err := inv.GetResolvedVariablesForHost(args[0])
+				if err != nil {
+					return err
+				}
+				return writeResult(cmd.OutOrStdout(), vars, func(out io.Writer) error {
+					data, err := yaml.Marshal(vars)
+					if err != nil {
+						return err
+					}
+					_, err = out.Write(data)
+					return err
+				})
+			}
+
+			resolved, err := inv.ResolveHost(args[0], ansibleinv.ResolveOptions{})
+			if err != nil {
+				return err
+			}
+			return writeResult(cmd.OutOrStdout(), resolved, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "VAR\tVALUE\tSET BY\tOVERRIDES")
+				for _, name := range sortedKeys(resolved) {
+					rv := resolved[name]
+					from := rv.Source
+					if from == "" {
+						from = "(host)"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, varValue(rv.Value), from, listOrDash(rv.Overrides))
+				}
+				return w.Flush()
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&sources, "sources", false, "show where each variable comes from")
+	return cmd
+}
+
+func newInvListCmd(f *invFlags) *cobra.Command {
+	var (
+		export, table, csv  bool
+		sshConfig, etcHosts bool
+		vars                []string
+	)
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Print the inventory as ansible-inventory --list does, or as a table",
+		Long: `Print the whole inventory as JSON, exactly as ansible-inventory --list
+would (--export keeps variables on their groups), or as YAML with --output
+yaml. --table prints one row per host instead, with its groups and resolved
+--vars, for audits.
+
+--ssh-config prints a ~/.ssh/config block instead, a Host entry per host with
+HostName, User, Port and IdentityFile from its ansible_host, ansible_user,
+ansible_port and ansible_ssh_private_key_file, and --etc-hosts an /etc/hosts
+snippet of each host's ansible_host address and name, e.g.
+
+  your-cli inv list --app sor --limit prod --ssh-config >> ~/.ssh/config`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			layouts := 0
+			for _, set := range []bool{table, sshConfig, etcHosts} {
+				if set {
+					layouts++
+				}
+			}
+			if layouts > 1 {
+				return fmt.Errorf("--table, --ssh-config and --etc-hosts are different layouts: pick one")
+			}
+			if layouts > 0 && outputFormat != "text" {
+				return fmt.Errorf("--table, --ssh-config and --etc-hosts are text layouts: drop them for --output %s", outputFormat)
+			}
+			inv, err := f.load(cmd.Context())
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			switch {
+			case sshConfig:
+				return inv.WriteSSHConfig(out, nil)
+			case etcHosts:
+				return inv.WriteEtcHosts(out, nil)
+			case table:
+				format := ansibleinv.TableText
+				if csv {
+					format = ansibleinv.TableCSV
+				}
+				return inv.WriteTable(out, nil, vars, format)
+			}
+			data, err := inv.MarshalListJSON(export)
+			if err != nil {
+				return err
+			}
+			return writeResult(out, json.RawMessage(data), func(out io.Writer) error {
+				_, err := out.Write(data)
+				return err
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&export, "export", false, "keep variables on their groups (ansible-inventory --export)")
+	cmd.Flags().BoolVar(&table, "table", false, "one row per host with its groups and --vars")
+	cmd.Flags().StringSliceVar(&vars, "vars", []string{"ansible_host", "ansible_user"}, "resolved variables to show with --table")
+	cmd.Flags().BoolVar(&csv, "csv", false, "write --table as CSV")
+	cmd.Flags().BoolVar(&sshConfig, "ssh-config", false, "a ~/.ssh/config Host entry per host")
+	cmd.Flags().BoolVar(&etcHosts, "etc-hosts", false, "an /etc/hosts line per host")
+	return cmd
+}
+
+func newInvDiffCmd(f *invFlags) *cobra.Command {
+	var ref string
+	cmd := &cobra.Command{
+		Use:   "diff [OLD [NEW]]",
+		Short: "Show how hosts' groups and variables change between two inventories",
+		Long: `Compare two inventories host by host as ansible sees them: hosts added or
+removed, groups joined or left and resolved variables changed, so moving a
+variable from a host to its group is not a change. OLD defaults to the
+inventory (--app or -i, only one) as committed at --ref and NEW to it as it
+is now, e.g. to review a branch:
+
+  your-cli inv diff --app sor --ref origin/main`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var oldName, newName string
+			var oldInv, newInv *ansibleinv.Inventory
+			var err error
+			if len(args) < 2 {
+				paths, err := f.resolve()
+				if err != nil {
+					return err
+				}
+				if len(paths) != 1 {
+					return fmt.Errorf("diff compares one inventory, not %d: pass --app or -i once", len(paths))
+				}
+				newName = paths[0]
+			} else {
+				newName = args[1]
+			}
+			if len(args) == 0 {
+				oldName = ref + ":" + newName
+				data, err := gitShow(ref, newName)
+				if err != nil {
+					return err
+				}
+				oldInv, err = ansibleinv.ParseBytes(data)
+				if err != nil {
+					return fmt.Errorf("%s: %w", oldName, err)
+				}
+			} else {
+				oldName = args[0]
+				if oldInv, err = loadInventories(ctx, []string{oldName}); err != nil {
+					return err
+				}
+			}
+			if newInv, err = loadInventories(ctx, []string{newName}); err != nil {
+				return err
+			}
+			if f.limit != "" {
+				oldInv, newInv = oldInv.Limit(f.limit), newInv.Limit(f.limit)
+			}
+
+			diffs, err := ansibleinv.Diff(oldInv, newInv)
+			if err != nil {
+				return err
+			}
+			if diffs == nil {
+				diffs = []ansibleinv.HostDiff{} // [] rather than null for scripts
+			}
+			return writeResult(cmd.OutOrStdout(), diffs, func(out io.Writer) error {
+				if len(diffs) == 0 {
+					fmt.Fprintf(os.Stderr, "No changes between %s and %s.\n", oldName, newName)
+					return nil
+				}
+				printInvDiff(out, diffs)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&ref, "ref", "HEAD", "git revision OLD is read from when it is not given")
+	return cmd
+}
+
+func newInvLintCmd(f *invFlags) *cobra.Command {
+	var (
+		strict bool
+		envs   []string
+	)
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check the inventories for common mistakes",
+		Long: `Check the inventories with ansibleinv's rules: hosts without ansible_host,
+groups with no hosts, group variables every host gets from somewhere else,
+and hosts in more than one of the --env groups. A host in two environments
+is an error; the rest are warnings.
+
+It exits non-zero when there are errors, or any findings at all with
+--strict, whatever the --output, so CI can run it on every change to the
+inventories:
+
+  your-cli inv lint --strict --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if f.limit != "" {
+				return fmt.Errorf("lint checks whole inventories: drop --limit")
+			}
+			inv, err := f.load(cmd.Context())
+			if err != nil {
+				return err
+			}
+			findings := ansibleinv.Lint(inv, ansibleinv.MissingAnsibleHost, ansibleinv.EmptyGroups,
+				ansibleinv.ShadowedVars, ansibleinv.ExclusiveGroups(envs...))
+			if findings == nil {
+				findings = []ansibleinv.Finding{} // [] rather than null for scripts
+			}
+			err = writeResult(cmd.OutOrStdout(), findings, func(out io.Writer) error {
+				if len(findings) == 0 {
+					fmt.Fprintf(os.Stderr, "No findings in %d hosts and %d groups.\n", len(inv.Hosts), len(inv.Groups))
+				}
+				for _, finding := range findings {
+					fmt.Fprintln(out, finding)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			var errs, warnings int
+			for _, finding := range findings {
+				if finding.Severity == ansibleinv.SeverityError {
+					errs++
+				} else {
+					warnings++
+				}
+			}
+			if errs > 0 || strict && warnings > 0 {
+				return fmt.Errorf("lint failed: %d error(s), %d warning(s)", errs, warnings)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail on warnings too")
+	cmd.Flags().StringSliceVar(&envs, "env", ansibleinv.DefaultEnvironments, "environment groups no host may be in two of")
+	return cmd
+}
+
+func newInvEditCmd(f *invFlags) *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "Browse and edit the inventories in a TUI",
+		Long: `Open the inventory editor on the selected YAML inventories: search groups
+and hosts, add and remove hosts, edit variables and write the files back
+after reviewing the diff. Tab switches between inventories. --limit does not
+apply: the editor always shows whole files.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if !isTerminal() {
+				return errors.New("edit needs a terminal")
+			}
+			paths, err := f.resolve()
+			if err != nil {
+				return err
+			}
+			return invedit.Run(paths)
+		},
+	}
+}
+
+// resolve returns the inventories the flags select: the -i paths and the
+// --app inventories, or every app's inventory if neither is given.
+func (f *invFlags) resolve() ([]string, error) {
+	ws, err := currentWorkspace()
+	if err != nil {
+		return nil, err
+	}
+	paths := append([]string(nil), f.paths...)
+	for _, app := range f.apps {
+		path := ws.inventory(app)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("no inventory for app %s: %w", app, err)
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) > 0 {
+		return paths, nil
+	}
+	matches, _ := filepath.Glob(ws.inventories())
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no inventories match %s: pass --app or -i", ws.inventories())
+	}
+	return matches, nil
+}
+
+// load reads and combines the selected inventories, narrowed to --limit.
+func (f *invFlags) load(ctx context.Context) (*ansibleinv.Inventory, error) {
+	paths, err := f.resolve()
+	if err != nil {
+		return nil, err
+	}
+	inv, err := loadInventories(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	if f.limit == "" {
+		return inv, nil
+	}
+	inv = inv.Limit(f.limit)
+	if len(inv.Hosts) == 0 {
+		return nil, fmt.Errorf("no hosts match --limit %q", f.limit)
+	}
+	return inv, nil
+}
+
+// loadInventories reads and merges inventories. Conflicting variables only
+// warn: the first inventory's value is kept, as the viewer always has.
+func loadInventories(ctx context.Context, paths []string) (*ansibleinv.Inventory, error) {
+	var sources []ansibleinv.Source
+	for _, path := range paths {
+		sources = append(sources, ansibleinv.SourceFor(path))
+	}
+	inv, err := ansibleinv.LoadSources(ctx, sources...)
+	var conflicts *ansibleinv.MergeError
+	if errors.As(err, &conflicts) {
+		slog.Warn("inventories disagree, keeping the first value", "err", err)
+		return inv, nil
+	}
+	return inv, err
+}
+
+func printInvDiff(w io.Writer, diffs []ansibleinv.HostDiff) {
+	for _, d := range diffs {
+		switch {
+		case d.Added:
+			fmt.Fprintf(w, "+ host %s (%s)\n", d.Host, listOrDash(d.JoinedGroups))
+			continue
+		case d.Removed:
+			fmt.Fprintf(w, "- host %s (%s)\n", d.Host, listOrDash(d.LeftGroups))
+			continue
+		}
+		fmt.Fprintf(w, "~ host %s\n", d.Host)
+		for _, group := range d.JoinedGroups {
+			fmt.Fprintf(w, "    + group %s\n", group)
+		}
+		for _, group := range d.LeftGroups {
+			fmt.Fprintf(w, "    - group %s\n", group)
+		}
+		for _, v := range d.Vars {
+			switch {
+			case v.Old == nil:
+				fmt.Fprintf(w, "    + %s: %s\n", v.Name, varValue(v.New))
+			case v.New == nil:
+				fmt.Fprintf(w, "    - %s: %s\n", v.Name, varValue(v.Old))
+			default:
+				fmt.Fprintf(w, "    ~ %s: %s → %s\n", v.Name, varValue(v.Old), varValue(v.New))
+			}
+		}
+	}
+}
+
+// varValue formats a variable on one line: strings as they are, anything
+// else as JSON.
+func varValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
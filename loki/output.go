@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the global --output and --log-format flags.
+var (
+	outputFormats = []string{"text", "json", "yaml"}
+	logFormats    = []string{"text", "json"}
+)
+
+// outputFormat is the global --output flag, checked before any command runs.
+var outputFormat = "text"
+
+// checkOutputFormat rejects an unknown --output.
+func checkOutputFormat() error {
+	if !slices.Contains(outputFormats, outputFormat) {
+		return fmt.Errorf("--output %q: want %s", outputFormat, strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
+// writeResult writes a command's result in the --output format: text with
+// the command's own printer, json and yaml by marshalling v. Both use v's
+// json tags, so scripts see the same fields whichever they pick.
+func writeResult(w io.Writer, v any, text func(io.Writer) error) error {
+	switch outputFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic any
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+	return text(w)
+}
+
+// newLogHandler is the slog handler for --log-format: text, coloured by
+// level on a terminal, or JSON, the default when $CI is set so pipelines
+// can parse the logs.
+func newLogHandler(format string, level slog.Level) (slog.Handler, error) {
+	if format == "" {
+		format = "text"
+		if os.Getenv("CI") != "" {
+			format = "json"
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.NewJSONHandler(os.Stderr, opts), nil
+	case "text":
+		h := slog.NewTextHandler(os.Stderr, opts)
+		if isTerminal() {
+			return colorHandler{h}, nil
+		}
+		return h, nil
+	}
+	return nil, fmt.Errorf("--log-format %q: want %s", format, strings.Join(logFormats, ", "))
+}
+
+// colorHandler colours each log line by its level, as the CI tools do.
+type colorHandler struct{ slog.Handler }
+
+func (h colorHandler) Handle(ctx context.Context, r slog.Record) error {
+	code := 34 // debug: blue
+	switch {
+	case r.Level >= slog.LevelError:
+		code = red
+	case r.Level >= slog.LevelWarn:
+		code = yellow
+	case r.Level >= slog.LevelInfo:
+		code = 32 // green
+	}
+	fmt.Fprintf(os.Stderr, "\033[%dm", code)
+	err := h.Handler.Handle(ctx, r) // delegate actual formatting
+	fmt.Fprint(os.Stderr, "\033[0m")
+	return err
+}
+
+func (h colorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return colorHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h colorHandler) WithGroup(name string) slog.Handler {
+	return colorHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"your-cli/internal/config"
+	"your-cli/internal/gitlab"
+
+	"github.com/spf13/cobra"
+	"github.com/yourorg/tool/releigh"
+)
+
+// defaultGraph is where the Gradle build exports the dependency graph,
+// relative to the workspace root.
+const defaultGraph = "build/dependency-graph.json"
+
+func newReleaseCmd() *cobra.Command {
+	var (
+		cfg                  releigh.Config
+		graphPath, notesFile string
+		provider             string
+		retry                releigh.RetryPolicy
+		rateLimit            float64
+	)
+	cmd := &cobra.Command{
+		Use:   "release <app>... | --all-affected",
+		Short: "Tag and publish app releases, as the release job does",
+		Long: `Release apps exactly as the release job in CI does: find each app's previous
+tag, render the changelog from the conventional commits since, push the new
+tag and create the GitLab or GitHub release, dependencies first. The version
+is --version (or $RELEASE_VERSION), or the next one the commits call for with
+--auto-bump; --dry-run prints everything without tagging or calling the API.
+
+  your-cli release sor --auto-bump --dry-run
+  your-cli release sor gateway --version 1.4.0
+  your-cli release --all-affected --since origin/main --auto-bump --prerelease rc
+
+It runs at the workspace root, so --graph, --assets and --notes-template are
+relative to it. Outside CI the GitLab instance and token are the active
+profile's, and the project is the workspace's (its .loki.yaml project, or the
+path of the origin remote); the CI variables win when they are set.
+
+Projects whose origin is on github.com (or a github.* host), or any with
+--provider github, release on GitHub instead: the repository is
+$GITHUB_REPOSITORY or the origin remote's, the server $GITHUB_SERVER_URL or
+github.com, and the token $GITHUB_TOKEN.`,
+		ValidArgsFunction: completeReleaseApps,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !cfg.AllAffected {
+				return errors.New("name the apps to release, or pass --all-affected")
+			}
+			ws, err := currentWorkspace()
+			if err != nil {
+				return err
+			}
+			// releigh writes apps/<app>/CHANGELOG.md relative to the working directory
+			if err := os.Chdir(ws.Root); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			log := slog.Default()
+
+			cfg.Apps = args
+			if cfg.Version == "" {
+				cfg.Version = os.Getenv("RELEASE_VERSION")
+			}
+			if cfg.Version == "" && !cfg.AutoBump && !cfg.SuggestVersion {
+				return errors.New("no release version: pass --version (or set $RELEASE_VERSION) or --auto-bump")
+			}
+			if notesFile != "" {
+				src, err := os.ReadFile(notesFile)
+				if err != nil {
+					return fmt.Errorf("could not read the release notes template: %w", err)
+				}
+				cfg.NotesTemplate = string(src)
+			}
+			if cfg.Graph, err = releigh.LoadGraph(graphPath); err != nil {
+				return err
+			}
+
+			target := resolveReleaseTarget(ctx, ws, provider)
+			cfg.ProjectURL, cfg.Branch = target.projectURL, target.branch
+			cfg.SigningKey = os.Getenv("RELEASE_GPG_KEY_ID")
+			cfg.PipelineURL = os.Getenv("CI_PIPELINE_URL")
+			cfg.JiraBaseURL = os.Getenv("JIRA_BASE_URL")
+			// GitLab exposes the user who started the pipeline; git config is used otherwise.
+			cfg.AuthorName, cfg.AuthorEmail = os.Getenv("GITLAB_USER_NAME"), os.Getenv("GITLAB_USER_EMAIL")
+
+			// The provider and Jira are only needed when the run publishes something.
+			publishing := !cfg.DryRun && !cfg.SuggestVersion
+			if publishing && cfg.Changelog != "" && cfg.Branch == "" {
+				return errors.New("--changelog needs a branch to push to: check one out, or set $CI_COMMIT_BRANCH")
+			}
+			// Dry runs still build the client so asset links show their real URLs.
+			releases, err := target.provider(publishing, retry, rateLimit, log)
+			if err != nil {
+				return err
+			}
+
+			// a dry run's report would garble --output json or yaml on stdout
+			report := cmd.OutOrStdout()
+			if outputFormat != "text" {
+				report = os.Stderr
+			}
+			opts := []releigh.Option{releigh.WithLogger(log), releigh.WithOutput(report)}
+			if cfg.JiraTransition != "" && publishing {
+				for _, env := range []string{"JIRA_BASE_URL", "JIRA_USER", "JIRA_API_TOKEN"} {
+					if os.Getenv(env) == "" {
+						return fmt.Errorf("--jira-transition needs $%s", env)
+					}
+				}
+				opts = append(opts, releigh.WithJira(releigh.NewJiraClient(cfg.JiraBaseURL, os.Getenv("JIRA_USER"), os.Getenv("JIRA_API_TOKEN"), retry, log)))
+			}
+			if cfg.SignTags && publishing {
+				if key := os.Getenv("RELEASE_GPG_PRIVATE_KEY"); key != "" {
+					fingerprint, cleanup, err := releigh.ImportSigningKey(key)
+					if err != nil {
+						return fmt.Errorf("could not set up tag signing: %w", err)
+					}
+					defer cleanup()
+					if cfg.SigningKey == "" {
+						cfg.SigningKey = fingerprint
+					}
+					log.Info("imported release signing key", "key", cfg.SigningKey)
+				}
+			}
+
+			r, err := releigh.New(cfg, releigh.ExecGit{Log: log}, releases, opts...)
+			if err != nil {
+				return err
+			}
+			results, err := r.Run(ctx)
+			if results == nil {
+				results = []releigh.Result{}
+			}
+			werr := writeResult(cmd.OutOrStdout(), results, func(out io.Writer) error {
+				if cfg.SuggestVersion {
+					for _, res := range results {
+						if res.Version == "" {
+							continue
+						}
+						if len(results) == 1 {
+							fmt.Fprintln(out, res.Version)
+						} else {
+							fmt.Fprintf(out, "%s %s\n", res.App, res.Version)
+						}
+					}
+				} else if len(results) > 1 {
+					releigh.PrintSummary(out, results)
+				}
+				return nil
+			})
+			return errors.Join(err, werr)
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&cfg.Version, "version", "", "version to release every app at (default $RELEASE_VERSION)")
+	f.BoolVar(&cfg.AutoBump, "auto-bump", false, "compute the next version from the conventional commits since the previous tag")
+	f.BoolVar(&cfg.SuggestVersion, "suggest-version", false, "only print the next version of each app")
+	f.BoolVar(&cfg.DryRun, "dry-run", false, "print the release without tagging, pushing or calling GitLab or GitHub")
+	f.StringVar(&cfg.Prerelease, "prerelease", "", `with --auto-bump or --suggest-version, cut the next candidate of this series (e.g. "rc" for 1.4.0-rc.2)`)
+	f.DurationVar(&cfg.UpcomingFor, "upcoming-for", 14*24*time.Hour, "date pre-releases this far ahead so GitLab lists them as upcoming; 0 disables")
+	f.BoolVar(&cfg.AllAffected, "all-affected", false, "release every app affected by the changes since --since instead of naming apps")
+	f.StringVar(&cfg.Since, "since", "", "base ref for --all-affected (default the previous commit)")
+	f.StringVar(&cfg.Assets, "assets", "", "glob of files to attach to each release, or @file listing one per line; {app} expands to the app name")
+	f.StringVar(&cfg.Changelog, "changelog", "", "prepend each release to apps/<app>/CHANGELOG.md and push it: commit or mr")
+	f.StringVar(&notesFile, "notes-template", "", "Go text/template file for the release description (fields: releigh.Notes)")
+	f.StringVar(&cfg.TagMessage, "tag-message", "", "Go text/template for the tag message, with the same fields")
+	f.BoolVar(&cfg.SignTags, "sign-tags", false, "GPG-sign the tags; $RELEASE_GPG_PRIVATE_KEY (and $RELEASE_GPG_KEY_ID) may supply the key")
+	f.StringVar(&cfg.JiraTransition, "jira-transition", "", `move the changelog's Jira issues through this transition (e.g. "Released"); needs $JIRA_BASE_URL, $JIRA_USER, $JIRA_API_TOKEN`)
+	f.StringVar(&graphPath, "graph", defaultGraph, "dependency graph exported by the Gradle build")
+	f.StringVar(&provider, "provider", "", "release on gitlab or github (default: github for an origin remote on GitHub, gitlab otherwise)")
+	f.IntVar(&retry.Retries, "retries", 4, "retry GitLab or GitHub API calls this many times on 429, 5xx or network errors")
+	f.DurationVar(&retry.Backoff, "retry-backoff", 2*time.Second, "first delay between API retries; doubles every attempt")
+	f.Float64Var(&rateLimit, "rate-limit", 0, "at most this many GitLab API requests a second (default: follow GitLab's RateLimit headers)")
+	cmd.MarkFlagsMutuallyExclusive("version", "auto-bump")
+	cmd.RegisterFlagCompletionFunc("changelog", cobra.FixedCompletions([]string{"commit", "mr"}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("provider", cobra.FixedCompletions([]string{releigh.ProviderGitLab, releigh.ProviderGitHub}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.MarkFlagFilename("graph", "json")
+	return cmd
+}
+
+// releaseTarget is where a release goes: the CI variables in a pipeline,
+// the active profile and the workspace otherwise.
+type releaseTarget struct {
+	kind       string // releigh.ProviderGitLab or releigh.ProviderGitHub
+	serverURL  string
+	project    string // ID or path; empty if it cannot be told
+	projectURL string
+	branch     string
+}
+
+// resolveReleaseTarget finds where a release goes on provider, or on the
+// provider the origin remote is on if that is empty.
+func resolveReleaseTarget(ctx context.Context, ws *workspace, provider string) releaseTarget {
+	remote, err := gitOutput(ctx, ws.Root, "remote", "get-url", "origin")
+	if err != nil {
+		slog.Debug("no origin remote to take the project from", "err", err)
+	}
+	host, remotePath := releigh.ParseRemote(remote)
+	onGitHub := releigh.ProviderForHost(host) == releigh.ProviderGitHub
+	if provider == "" {
+		provider = releigh.ProviderForHost(host)
+	}
+
+	t := releaseTarget{kind: provider, branch: os.Getenv("CI_COMMIT_BRANCH")}
+	if provider == releigh.ProviderGitHub {
+		t.serverURL = strings.TrimSuffix(os.Getenv("GITHUB_SERVER_URL"), "/")
+		if t.serverURL == "" {
+			t.serverURL = releigh.DefaultGitHubURL
+		}
+		t.project = os.Getenv("GITHUB_REPOSITORY")
+		if t.project == "" && onGitHub {
+			t.project = remotePath
+		}
+		if t.project != "" {
+			t.projectURL = t.serverURL + "/" + t.project
+		}
+	} else {
+		t.serverURL = os.Getenv("CI_SERVER_URL")
+		t.project = os.Getenv("CI_PROJECT_ID")
+		t.projectURL = os.Getenv("CI_PROJECT_URL")
+		if t.serverURL == "" {
+			_, profile, _ := config.ActiveProfile()
+			t.serverURL = strings.TrimSuffix(profile.BaseURL, "/")
+		}
+		if t.project == "" {
+			t.project = ws.Project
+		}
+		if t.project == "" && !onGitHub {
+			t.project = remotePath
+		}
+		if t.projectURL == "" && t.project != "" && strings.Contains(t.project, "/") {
+			t.projectURL = t.serverURL + "/" + t.project
+		}
+	}
+	if t.branch == "" {
+		t.branch = os.Getenv("CI_DEFAULT_BRANCH")
+	}
+	if t.branch == "" {
+		// empty when detached: there is no branch to push a changelog to
+		t.branch, _ = gitOutput(ctx, ws.Root, "symbolic-ref", "--short", "-q", "HEAD")
+	}
+	return t
+}
+
+// provider builds the client of the target's provider. Publishing needs a
+// project and a token: $GITHUB_TOKEN on GitHub, $GITLAB_API_TOKEN or the
+// active profile's on GitLab.
+func (t releaseTarget) provider(publishing bool, retry releigh.RetryPolicy, rateLimit float64, log *slog.Logger) (releigh.Provider, error) {
+	switch t.kind {
+	case releigh.ProviderGitHub:
+		token := os.Getenv("GITHUB_TOKEN")
+		if publishing {
+			if t.project == "" {
+				return nil, errors.New("cannot tell which GitHub repository to release in: set $GITHUB_REPOSITORY")
+			}
+			if token == "" {
+				return nil, errors.New("no GitHub token: set $GITHUB_TOKEN")
+			}
+		}
+		return releigh.NewGitHubClient(t.serverURL, t.project, token, retry, log), nil
+	case releigh.ProviderGitLab:
+	default:
+		return nil, fmt.Errorf("unknown --provider %q: want gitlab or github", t.kind)
+	}
+
+	token := os.Getenv("GITLAB_API_TOKEN")
+	if publishing {
+		if t.project == "" {
+			return nil, errors.New("cannot tell which GitLab project to release in: set project in " + workspaceFile + ", or $CI_PROJECT_ID")
+		}
+		if token == "" {
+			var err error
+			if token, err = config.Token(); err != nil {
+				return nil, fmt.Errorf("no GitLab token: run 'your-cli init-auth' or set $GITLAB_API_TOKEN: %w", err)
+			}
+		}
+	}
+	glOpts := []gitlab.Option{gitlab.WithBaseURL(t.serverURL), gitlab.WithRetries(retry.Retries, retry.Backoff), gitlab.WithLogger(log)}
+	if rateLimit > 0 {
+		glOpts = append(glOpts, gitlab.WithRateLimit(rateLimit, max(int(rateLimit), 1)))
+	}
+	cli, err := gitlab.New(token, glOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return releaseGitLab{cli: cli, project: t.project, log: log}, nil
+}
+
+// gitOutput runs git in dir and returns its trimmed stdout.
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// completeReleaseApps completes app names from the workspace's dependency
+// graph: the projects under :apps:.
+func completeReleaseApps(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	data, err := os.ReadFile(filepath.Join(workspaceOrDefault().Root, defaultGraph))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var graph map[string]json.RawMessage
+	if err := json.Unmarshal(data, &graph); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var apps []string
+	for name := range graph {
+		if app, ok := strings.CutPrefix(name, ":apps:"); ok && !strings.Contains(app, ":") {
+			apps = append(apps, app)
+		}
+	}
+	return withPrefix(apps, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// releaseGitLab is releigh's GitLab API on the CLI's shared client, for the
+// project the release goes to.
+type releaseGitLab struct {
+	cli     *gitlab.Client
+	project string
+	log     *slog.Logger
+}
+
+func (g releaseGitLab) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	_, err := g.cli.Release(ctx, g.project, tag)
+	switch {
+	case gitlab.StatusCode(err) == http.StatusNotFound:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+func (g releaseGitLab) CreateRelease(ctx context.Context, rel releigh.Release, assets []releigh.Asset) error {
+	for _, a := range assets {
+		g.log.Info("uploading release asset", "name", a.Name)
+		if err := g.uploadPackageFile(ctx, rel.App, rel.Version, a); err != nil {
+			return fmt.Errorf("failed to upload asset %s: %w", a.Name, err)
+		}
+	}
+
+	spec := gitlab.NewRelease{Tag: rel.TagName, Name: rel.Name, Description: rel.Description, ReleasedAt: rel.ReleasedAt}
+	if rel.Assets != nil {
+		for _, l := range rel.Assets.Links {
+			spec.Links = append(spec.Links, gitlab.ReleaseLink{Name: l.Name, URL: l.URL, Type: l.LinkType})
+		}
+	}
+	g.log.Info("creating GitLab release", "project", g.project, "title", rel.Name)
+	err := g.cli.CreateRelease(ctx, g.project, spec)
+	// A retried POST whose first attempt reached GitLab comes back as a conflict.
+	if gitlab.StatusCode(err) == http.StatusConflict {
+		g.log.Warn("GitLab release already exists, treating as created", "tag", rel.TagName)
+		return nil
+	}
+	return err
+}
+
+// AssetURL links the asset in the generic package registry, where the
+// package is named after the app and versioned with the release.
+func (g releaseGitLab) AssetURL(rel releigh.Release, file string) string {
+	return g.cli.PackageFileURL(g.project, rel.App, rel.Version, file)
+}
+
+func (g releaseGitLab) uploadPackageFile(ctx context.Context, pkg, version string, a releigh.Asset) error {
+	// the client buffers the body itself for its retries
+	body, err := a.Open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return g.cli.UploadPackageFile(ctx, g.project, pkg, version, a.Name, body)
+}
+
+func (g releaseGitLab) CreateMergeRequest(ctx context.Context, source, target, title string) (string, error) {
+	return g.cli.CreateMergeRequest(ctx, g.project, source, target, title)
+}
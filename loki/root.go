@@ -27,8 +27,9 @@ var (
 // rootCmd – all global flags & logger setup
 // --------------------------------------------------------------------
 var rootCmd = &cobra.Command{
-	Use:   "your-cli",
-	Short: "Next-gen monorepo controller",
+	Use:     "your-cli",
+	Short:   "Next-gen monorepo controller",
+	Version: version, // `your-cli --version` is how the updater checks a fresh install
 	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
 		// 1) (re)configure slog exactly once
 		if !loggerReady {
@@ -36,24 +37,73 @@ var rootCmd = &cobra.Command{
 			if v, _ := cmd.Flags().GetString("log-level"); v == "debug" {
 				level = slog.LevelDebug
 			}
-			h := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+			format, _ := cmd.Flags().GetString("log-format")
+			h, err := newLogHandler(format, level)
+			if err != nil {
+				return err
+			}
 			slog.SetDefault(slog.New(h))
 			loggerReady = true
 		}
+		if err := checkOutputFormat(); err != nil {
+			return err
+		}
+		// 2) the GitLab profile every command talks to
+		if p, _ := cmd.Flags().GetString("profile"); p != "" {
+			config.SelectProfile(p)
+		}
+		// 3) the monorepo the command works in, found from any subdirectory
+		if needsWorkspace(cmd) {
+			if _, err := currentWorkspace(); err != nil {
+				return err
+			}
+		}
+		// 4) a token about to expire warns before it breaks a command
+		warnTokenProblems(cmd)
 		return nil
 	},
 }
 
 var loggerReady bool
 
+// needsWorkspace reports whether cmd works in the monorepo. update, doctor,
+// completion and docs run anywhere, and with a broken .loki.yaml: update may
+// be what fixes it, and doctor is where it is reported.
+func needsWorkspace(cmd *cobra.Command) bool {
+	top := cmd
+	for top.HasParent() && top.Parent().HasParent() {
+		top = top.Parent()
+	}
+	switch top.Name() {
+	case "update", "doctor", "completion", "docs", "help":
+		return false
+	}
+	return true
+}
+
 func init() {
 	// global flags
 	rootCmd.PersistentFlags().String("log-level", "info", "debug or info")
+	rootCmd.PersistentFlags().String("log-format", "", "log format: text or json (default json when $CI is set, else text)")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "result format: text, json or yaml")
+	rootCmd.PersistentFlags().String("profile", "", "GitLab profile to use (default $LOKI_PROFILE, then \"default\"; see 'your-cli config')")
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	rootCmd.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions(logFormats, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+	rootCmd.CompletionOptions.DisableDefaultCmd = true // ours documents the install
 
 	// sub-commands
 	rootCmd.AddCommand(
 		newInitAuthCmd(),                   // one-time PAT setup
 		newUpdateCmd(version, projectSlug), // explicit update
+		newDoctorCmd(),                     // environment diagnostics
+		newConfigCmd(),                     // profiles for each GitLab instance
+		newAffectedCmd(),                   // what CI will deploy for these changes
+		newTopoCmd(),                       // topology plans, graphs and checks
+		newInvCmd(),                        // inventory views, diffs and the editor
+		newReleaseCmd(),                    // app releases, as the release job cuts them
+		newCompletionCmd(),                 // shell completion scripts
+		newDocsCmd(),                       // man pages
 		// … your create/graph/dev/manage commands here …
 	)
 
@@ -83,12 +133,20 @@ func attachUpdateCheck(root *cobra.Command, ver, project string) {
 			return nil
 		}
 		checked = true
+		prefs, _ := config.Update() // pinned series and declined versions
+		if prefs.CheckDisabled() {  // $YOURCLI_NO_UPDATE_CHECK=1 or update.check: false
+			return nil
+		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 
-		token, _ := config.Token() // env var, keyring, or file
-		info, err := updater.CheckForUpdates(ctx, ver, project, token)
+		// releases live on the default profile's instance, whichever is active
+		token, _ := config.TokenFor(config.DefaultProfile)
+		net, _ := config.HTTP() // proxy / CA bundle
+		info, err := updater.CheckForUpdates(ctx, ver, project, token,
+			updater.WithPin(prefs.Pin), updater.WithSkip(prefs.Skip...), updater.WithAssetTemplate(prefs.AssetTemplate),
+			updater.WithNetwork(updater.Network{Proxy: net.Proxy, CABundle: net.CABundle, InsecureSkipVerify: net.InsecureSkipVerify}))
 		switch {
 		case err == nil:
 			notifyColour(info) // yellow/minor, red/major
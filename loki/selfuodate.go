@@ -19,36 +19,89 @@ type Mode int
 const (
 	// Inform only – print a yellow (minor) or red (major) notice and continue.
 	Notice Mode = iota
-	// Strict – block when a newer *major* is found unless --allow-outdated is set.
+	// Strict – block when a newer *major* is found unless --allow-outdated is
+	// set; update.block in the config says where (by default not in CI).
 	Strict
 )
 
-// Attach wires the update-check to root.PersistentPreRunE or
-// root.PersistentPostRunE depending on chosen Mode.
+// ModeAnnotation overrides the Mode passed to Attach for one command and its
+// sub-commands, e.g. "strict" on destructive commands and "notice" on
+// read-only ones. The nearest annotated ancestor wins. Use SetMode to set it.
+const ModeAnnotation = "selfupdate/mode"
+
+func (m Mode) String() string {
+	if m == Strict {
+		return "strict"
+	}
+	return "notice"
+}
+
+// SetMode makes cmd (and its sub-commands) use m regardless of the CLI-wide mode.
+func SetMode(cmd *cobra.Command, m Mode) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[ModeAnnotation] = m.String()
+	return cmd
+}
+
+// modeFor resolves the mode of cmd: its own annotation, the nearest
+// annotated parent's, or def.
+func modeFor(cmd *cobra.Command, def Mode) Mode {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Annotations[ModeAnnotation] {
+		case "strict":
+			return Strict
+		case "notice":
+			return Notice
+		}
+	}
+	return def
+}
+
+// Attach wires the update-check to root.PersistentPreRunE (commands running
+// in Strict mode) and root.PersistentPostRunE (Notice mode). mode is the
+// default; commands can override it with ModeAnnotation. Neither checks
+// without a terminal, with $YOURCLI_NO_UPDATE_CHECK=1 or with update.check:
+// false in the config.
 // Call this exactly once in main.go **after** you’ve added all sub-commands.
 func Attach(root *cobra.Command, version, project string, mode Mode) {
-	switch mode {
-	case Notice:
-		attachPost(root, version, project)
-	case Strict:
-		attachPre(root, version, project)
-	default:
+	if mode != Notice && mode != Strict {
 		panic("unsupported self-update mode")
 	}
+	attachPost(root, version, project, mode)
+	attachPre(root, version, project, mode)
+}
+
+// anyStrict reports whether some command under root resolves to Strict.
+func anyStrict(root *cobra.Command, def Mode) bool {
+	if modeFor(root, def) == Strict {
+		return true
+	}
+	for _, c := range root.Commands() {
+		if anyStrict(c, def) {
+			return true
+		}
+	}
+	return false
 }
 
 /* ------------------------------------------------------------------------- */
 // Notice mode – just print after the user’s command finishes.
 /* ------------------------------------------------------------------------- */
 
-func attachPost(root *cobra.Command, ver, project string) {
+func attachPost(root *cobra.Command, ver, project string, def Mode) {
 	var done bool
 	root.PersistentPostRunE = func(cmd *cobra.Command, _ []string) error {
-		if done || cmd.Name() == "update" || !isTTY() {
+		if done || cmd.Name() == "update" || !isTTY() || modeFor(cmd, def) != Notice {
 			return nil
 		}
 		done = true
-		checkAndNotify(cmd.Context(), ver, project, false) // never block
+		prefs, _ := config.Update()
+		if prefs.CheckDisabled() {
+			return nil
+		}
+		checkAndNotify(cmd.Context(), ver, project, prefs, false) // never block
 		return nil
 	}
 }
@@ -57,29 +110,55 @@ func attachPost(root *cobra.Command, ver, project string) {
 // Strict mode – block before execution on major mismatch.
 /* ------------------------------------------------------------------------- */
 
-func attachPre(root *cobra.Command, ver, project string) {
+func attachPre(root *cobra.Command, ver, project string, def Mode) {
+	if !anyStrict(root, def) {
+		return // nothing blocks, so no flag to opt out with either
+	}
 	root.PersistentFlags().Bool("allow-outdated", false,
 		"run even when a newer major version is available")
 
 	root.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
-		if cmd.Name() == "update" || !isTTY() {
+		if cmd.Name() == "update" || !isTTY() || modeFor(cmd, def) != Strict {
 			return nil
 		}
+		prefs, err := config.Update()
+		if prefs.CheckDisabled() {
+			return nil
+		}
+		if err != nil {
+			slog.Warn("ignoring the update preferences", "err", err)
+			prefs.Block = ""
+		}
 		allow, _ := cmd.Flags().GetBool("allow-outdated")
-		return checkAndNotify(cmd.Context(), ver, project, allow)
+		return checkAndNotify(cmd.Context(), ver, project, prefs, allow || !blocks(prefs))
+	}
+}
+
+// blocks reports whether Strict mode may stop a command here, per
+// prefs.Block.
+func blocks(prefs config.UpdatePrefs) bool {
+	switch prefs.Block {
+	case config.BlockAlways:
+		return true
+	case config.BlockNever:
+		return false
 	}
+	return os.Getenv("CI") == "" // set by GitLab CI, GitHub Actions and most others
 }
 
 /* ------------------------------------------------------------------------- */
 // shared helper
 /* ------------------------------------------------------------------------- */
 
-func checkAndNotify(ctx context.Context, ver, project string, allow bool) error {
+func checkAndNotify(ctx context.Context, ver, project string, prefs config.UpdatePrefs, allow bool) error {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	token, _ := config.Token()
-	info, err := updater.CheckForUpdates(ctx, ver, project, token)
+	token, _ := config.TokenFor(config.DefaultProfile) // releases live on the default profile's instance
+	net, _ := config.HTTP()
+	info, err := updater.CheckForUpdates(ctx, ver, project, token,
+		updater.WithPin(prefs.Pin), updater.WithSkip(prefs.Skip...), updater.WithAssetTemplate(prefs.AssetTemplate),
+		updater.WithNetwork(updater.Network{Proxy: net.Proxy, CABundle: net.CABundle, InsecureSkipVerify: net.InsecureSkipVerify}))
 	switch {
 	case errors.Is(err, updater.ErrNoUpdate):
 		return nil
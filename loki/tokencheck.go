@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"your-cli/internal/config"
+	"your-cli/internal/gitlab"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	tokenCheckEvery   = 24 * time.Hour      // how often commands look at the token
+	tokenExpiryNotice = 14 * 24 * time.Hour // warn when it expires sooner than this
+)
+
+// errTokenRejected is GitLab answering 401: the token is revoked or expired.
+var errTokenRejected = errors.New("GitLab rejected the token (revoked or expired)")
+
+// inspectToken asks the GitLab at baseURL about token: its scopes and expiry.
+func inspectToken(ctx context.Context, baseURL, token string) (*gitlab.PersonalAccessToken, error) {
+	cli, err := gitlab.New(token, gitlab.WithBaseURL(baseURL))
+	if err != nil {
+		return nil, err
+	}
+	pat, err := cli.CurrentToken(ctx)
+	if gitlab.StatusCode(err) == http.StatusUnauthorized {
+		return nil, errTokenRejected
+	}
+	return pat, err
+}
+
+// tokenProblems lists what needs doing about a token: a missing scope, or
+// an expiry within tokenExpiryNotice. None means it is fine.
+func tokenProblems(pat *gitlab.PersonalAccessToken, now time.Time) []string {
+	var problems []string
+	if !canReadAPI(pat) {
+		problems = append(problems, "it has neither read_api nor api scope")
+	}
+	if pat.ExpiresAt != nil {
+		expires := time.Time(*pat.ExpiresAt)
+		switch left := expires.Sub(now); {
+		case left <= 0:
+			problems = append(problems, "it expired on "+expires.Format(time.DateOnly))
+		case left < tokenExpiryNotice:
+			problems = append(problems, fmt.Sprintf("it expires in %d day(s), on %s", int(left.Hours()/24)+1, expires.Format(time.DateOnly)))
+		}
+	}
+	return problems
+}
+
+// canReadAPI reports whether the token has a scope the CLI can work with.
+func canReadAPI(pat *gitlab.PersonalAccessToken) bool {
+	return slices.Contains(pat.Scopes, "api") || slices.Contains(pat.Scopes, "read_api")
+}
+
+// warnTokenProblems runs before every command: on a terminal, at most once
+// per tokenCheckEvery and profile, it asks GitLab about the active token and
+// warns in yellow about missing scopes and a close expiry, in red if GitLab
+// rejects it, instead of leaving the command to fail with a bare 401.
+func warnTokenProblems(cmd *cobra.Command) {
+	switch cmd.Name() {
+	case "init-auth", "doctor", "completion", "__complete", "man":
+		return // these set up or report on the token themselves
+	}
+	if !isTerminal() {
+		return
+	}
+	name, profile, err := config.ActiveProfile()
+	if err != nil || !config.TokenCheckDue(name, tokenCheckEvery) {
+		return
+	}
+	token, err := config.Token()
+	if err != nil {
+		return // commands that need one say so
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	pat, err := inspectToken(ctx, profile.BaseURL, token)
+	switch {
+	case errors.Is(err, errTokenRejected):
+		// not recorded as checked: this is worth repeating on every run
+		fmt.Fprintln(os.Stderr, colour(red, fmt.Sprintf(
+			"%s rejected your token for profile %s (revoked or expired) – create a new one and run 'your-cli init-auth%s'.",
+			profile.BaseURL, name, profileFlag(name))))
+		return
+	case err != nil:
+		slog.Debug("token check failed", "err", err)
+		return
+	}
+	if err := config.MarkTokenChecked(name); err != nil {
+		slog.Debug("could not record the token check", "err", err)
+	}
+	problems := tokenProblems(pat, time.Now())
+	if len(problems) == 0 {
+		return
+	}
+	// a rotated token keeps its scopes
+	fix := "run 'your-cli init-auth --rotate" + profileFlag(name) + "'"
+	if !canReadAPI(pat) {
+		fix = "create one with read_api scope and run 'your-cli init-auth" + profileFlag(name) + "'"
+	}
+	fmt.Fprintln(os.Stderr, colour(yellow, fmt.Sprintf(
+		"Your GitLab token for profile %s needs attention: %s – %s.", name, strings.Join(problems, "; "), fix)))
+}
+
+// profileFlag is the --profile flag repeating name in a suggested command,
+// or nothing for the default profile.
+func profileFlag(name string) string {
+	if name == config.DefaultProfile {
+		return ""
+	}
+	return " --profile " + name
+}
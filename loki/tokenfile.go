@@ -0,0 +1,221 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Where the keyring is unavailable, as on headless Linux boxes without a
+// Secret Service, tokens are kept in tokens.enc next to the config file,
+// sealed with NaCl secretbox. The key is derived with scrypt from
+// $LOKI_TOKEN_PASSPHRASE or, without one, from the machine ID. The machine
+// key only keeps the tokens out of copies of the file (backups, dotfile
+// repos): anyone who can log in to the box as you can unseal them. Only when
+// neither key exists are tokens written in plaintext to the config file.
+const (
+	envPassphrase = "LOKI_TOKEN_PASSPHRASE"
+
+	keyPassphrase = "passphrase"
+	keyMachine    = "machine"
+)
+
+// machineIDFiles hold the systemd and D-Bus machine IDs.
+var machineIDFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// sealedFile is tokens.enc: the tokens by profile, as sealed JSON.
+type sealedFile struct {
+	Key   string `json:"key"` // keyPassphrase or keyMachine
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Box   []byte `json:"box"`
+}
+
+// errNoSealKey means there is no passphrase or machine ID to seal with.
+var errNoSealKey = errors.New("no passphrase or machine ID to encrypt tokens with")
+
+// sealKind is the key new tokens are sealed with: the passphrase when one
+// is set, else the machine's; "" when there is neither.
+func sealKind() string {
+	if os.Getenv(envPassphrase) != "" {
+		return keyPassphrase
+	}
+	if _, err := machineSecret(); err == nil {
+		return keyMachine
+	}
+	return ""
+}
+
+func machineSecret() ([]byte, error) {
+	for _, path := range machineIDFiles {
+		if id, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(id))) > 0 {
+			// per user, so two accounts on a box do not share a key
+			return []byte(service + "\x00" + strings.TrimSpace(string(id)) + "\x00" + strconv.Itoa(os.Getuid())), nil
+		}
+	}
+	return nil, errNoSealKey
+}
+
+func sealKey(kind string, salt []byte) (*[32]byte, error) {
+	var secret []byte
+	switch kind {
+	case keyPassphrase:
+		p := os.Getenv(envPassphrase)
+		if p == "" {
+			return nil, fmt.Errorf("the tokens are encrypted with a passphrase: set $%s", envPassphrase)
+		}
+		secret = []byte(p)
+	case keyMachine:
+		s, err := machineSecret()
+		if err != nil {
+			return nil, err
+		}
+		secret = s
+	default:
+		return nil, fmt.Errorf("unknown key %q", kind)
+	}
+	raw, err := scrypt.Key(secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], raw)
+	return &key, nil
+}
+
+// loadSealed returns the sealed tokens by profile; no file means none.
+func loadSealed() (map[string]string, error) {
+	path, err := sealedPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f sealedFile
+	if err := json.Unmarshal(raw, &f); err != nil || len(f.Nonce) != 24 {
+		return nil, fmt.Errorf("%s is corrupt: delete it and run `your-cli init-auth` again", path)
+	}
+	key, err := sealKey(f.Key, f.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var nonce [24]byte
+	copy(nonce[:], f.Nonce)
+	plain, ok := secretbox.Open(nil, f.Box, &nonce, key)
+	if !ok {
+		if f.Key == keyPassphrase {
+			return nil, fmt.Errorf("%s: wrong $%s", path, envPassphrase)
+		}
+		return nil, fmt.Errorf("%s was encrypted on another machine or account: delete it and run `your-cli init-auth` again", path)
+	}
+	tokens := map[string]string{}
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	return tokens, nil
+}
+
+// saveSealed seals tokens with a fresh salt and nonce, under sealKind.
+func saveSealed(tokens map[string]string) error {
+	kind := sealKind()
+	if kind == "" {
+		return errNoSealKey
+	}
+	f := sealedFile{Key: kind, Salt: make([]byte, 16), Nonce: make([]byte, 24)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	key, err := sealKey(kind, f.Salt)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	var nonce [24]byte
+	copy(nonce[:], f.Nonce)
+	f.Box = secretbox.Seal(nil, plain, &nonce, key)
+
+	raw, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	path, err := sealedPath()
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	// write then rename: a torn file would lose every profile's token
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// sealToken stores one profile's token in tokens.enc.
+func sealToken(profile, token string) error {
+	if sealKind() == "" {
+		return errNoSealKey
+	}
+	tokens, err := loadSealed()
+	if err != nil {
+		return err
+	}
+	tokens[profile] = token
+	return saveSealed(tokens)
+}
+
+// migratePlaintext moves the plaintext tokens in cfg to tokens.enc, once
+// there is a key to seal them with, and drops them from the config file.
+func migratePlaintext(cfg Config) error {
+	if sealKind() == "" {
+		return errNoSealKey
+	}
+	tokens, err := loadSealed()
+	if err != nil {
+		return err
+	}
+	moved := false
+	if cfg.Token != "" {
+		tokens[DefaultProfile], cfg.Token, moved = cfg.Token, "", true
+	}
+	for name, p := range cfg.Profiles {
+		if p.Token != "" {
+			tokens[name], p.Token, moved = p.Token, "", true
+			cfg.Profiles[name] = p
+		}
+	}
+	if !moved {
+		return nil
+	}
+	if err := saveSealed(tokens); err != nil {
+		return err
+	}
+	return save(cfg)
+}
+
+func sealedPath() (string, error) {
+	path, err := filePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "tokens.enc"), nil
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+// isolate points the config at a temporary directory, the machine ID at
+// the returned file and the keyring at an empty mock, with no passphrase.
+func isolate(t *testing.T) (machineID string) {
+	t.Helper()
+	keyring.MockInit()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv(envPassphrase, "")
+
+	machineID = filepath.Join(dir, "machine-id")
+	if err := os.WriteFile(machineID, []byte("0123456789abcdef\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := machineIDFiles
+	machineIDFiles = []string{machineID}
+	t.Cleanup(func() { machineIDFiles = saved })
+	return machineID
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		passphrase string
+		kind       string
+	}{
+		{"machine key", "", keyMachine},
+		{"passphrase", "correct horse", keyPassphrase},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			isolate(t)
+			t.Setenv(envPassphrase, tc.passphrase)
+
+			if err := sealToken(DefaultProfile, "glpat-default"); err != nil {
+				t.Fatalf("sealToken: %v", err)
+			}
+			if err := sealToken("work", "glpat-work"); err != nil {
+				t.Fatalf("sealToken: %v", err)
+			}
+			if err := sealToken(DefaultProfile, "glpat-rotated"); err != nil {
+				t.Fatalf("sealToken: %v", err)
+			}
+			tokens, err := loadSealed()
+			if err != nil {
+				t.Fatalf("loadSealed: %v", err)
+			}
+			if want := map[string]string{DefaultProfile: "glpat-rotated", "work": "glpat-work"}; !reflect.DeepEqual(tokens, want) {
+				t.Errorf("loadSealed = %v, want %v", tokens, want)
+			}
+
+			path, _ := sealedPath()
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(raw, []byte("glpat-")) {
+				t.Errorf("%s holds a token in the clear: %s", path, raw)
+			}
+			if !bytes.Contains(raw, []byte(`"key":"`+tc.kind+`"`)) {
+				t.Errorf("%s is not sealed with the %s key: %s", path, tc.kind, raw)
+			}
+			if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+				t.Errorf("%s mode = %v, %v, want 0600", path, fi.Mode().Perm(), err)
+			}
+		})
+	}
+}
+
+func TestLoadSealedWithoutFile(t *testing.T) {
+	isolate(t)
+	tokens, err := loadSealed()
+	if err != nil || len(tokens) != 0 {
+		t.Errorf("loadSealed with no tokens.enc = %v, %v, want none", tokens, err)
+	}
+}
+
+func TestLoadSealedErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		seal    string // passphrase tokens.enc is sealed with, "" for the machine key
+		corrupt bool
+		change  func(t *testing.T, machineID string)
+		want    string
+	}{
+		{
+			name:    "corrupt",
+			corrupt: true,
+			want:    "is corrupt",
+		},
+		{
+			name: "wrong passphrase",
+			seal: "correct horse",
+			change: func(t *testing.T, _ string) {
+				t.Setenv(envPassphrase, "battery staple")
+			},
+			want: "wrong $" + envPassphrase,
+		},
+		{
+			name: "passphrase unset",
+			seal: "correct horse",
+			change: func(t *testing.T, _ string) {
+				t.Setenv(envPassphrase, "")
+			},
+			want: "set $" + envPassphrase,
+		},
+		{
+			name: "another machine",
+			change: func(t *testing.T, machineID string) {
+				if err := os.WriteFile(machineID, []byte("fedcba9876543210\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "encrypted on another machine or account",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			machineID := isolate(t)
+			t.Setenv(envPassphrase, tc.seal)
+			if err := sealToken(DefaultProfile, "glpat-default"); err != nil {
+				t.Fatal(err)
+			}
+			if tc.corrupt {
+				path, _ := sealedPath()
+				if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.change != nil {
+				tc.change(t, machineID)
+			}
+			if _, err := loadSealed(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("loadSealed = %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSealWithoutKey(t *testing.T) {
+	isolate(t)
+	machineIDFiles = []string{filepath.Join(t.TempDir(), "missing")}
+
+	if err := sealToken(DefaultProfile, "glpat-default"); !errors.Is(err, errNoSealKey) {
+		t.Errorf("sealToken = %v, want errNoSealKey", err)
+	}
+	if err := migratePlaintext(Config{Token: "glpat-default"}); !errors.Is(err, errNoSealKey) {
+		t.Errorf("migratePlaintext = %v, want errNoSealKey", err)
+	}
+}
+
+func TestMigratePlaintext(t *testing.T) {
+	isolate(t)
+	cfg := Config{
+		Token: "glpat-default",
+		Profiles: map[string]Profile{
+			"work": {BaseURL: "https://gitlab.example.com", Token: "glpat-work"},
+			"oss":  {GroupPath: "oss"},
+		},
+	}
+	if err := save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := sealToken("other", "glpat-other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migratePlaintext(cfg); err != nil {
+		t.Fatalf("migratePlaintext: %v", err)
+	}
+	tokens, err := loadSealed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{DefaultProfile: "glpat-default", "work": "glpat-work", "other": "glpat-other"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("sealed tokens = %v, want %v", tokens, want)
+	}
+	saved, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{Profiles: map[string]Profile{
+		"work": {BaseURL: "https://gitlab.example.com"},
+		"oss":  {GroupPath: "oss"},
+	}}
+	if !reflect.DeepEqual(saved, want) {
+		t.Errorf("config after migrating = %+v, want %+v", saved, want)
+	}
+
+	// nothing left to move: the config file is not rewritten
+	path, _ := filePath()
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := migratePlaintext(want); err != nil {
+		t.Fatalf("migratePlaintext again: %v", err)
+	}
+	if raw, _ := os.ReadFile(path); string(raw) != "{}" {
+		t.Errorf("config file rewritten with nothing to migrate: %s", raw)
+	}
+}
+
+func TestLookupToken(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		plaintext string // the default profile's token in the config file
+		sealed    string // the default profile's token in tokens.enc
+		unseal    string // $LOKI_TOKEN_PASSPHRASE when looking up
+		token     string
+		store     string
+		err       string
+	}{
+		{name: "none"},
+		{name: "sealed", sealed: "glpat-sealed", unseal: "pass", token: "glpat-sealed", store: StoreEncrypted},
+		{name: "plaintext is migrated", plaintext: "glpat-plain", token: "glpat-plain", store: StoreEncrypted},
+		{name: "sealed wins", plaintext: "glpat-plain", sealed: "glpat-sealed", unseal: "pass", token: "glpat-sealed", store: StoreEncrypted},
+		{name: "unreadable tokens.enc falls back", plaintext: "glpat-plain", sealed: "glpat-sealed", unseal: "wrong", token: "glpat-plain", store: StorePlaintext},
+		{name: "unreadable tokens.enc without plaintext", sealed: "glpat-sealed", unseal: "wrong", err: "wrong $" + envPassphrase},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			isolate(t)
+			if tc.sealed != "" {
+				t.Setenv(envPassphrase, "pass")
+				if err := sealToken(DefaultProfile, tc.sealed); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := save(Config{Token: tc.plaintext}); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(envPassphrase, tc.unseal)
+
+			token, store, err := lookupToken(DefaultProfile)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("lookupToken error = %v, want one containing %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil || token != tc.token || store != tc.store {
+				t.Errorf("lookupToken = %q, %q, %v, want %q, %q", token, store, err, tc.token, tc.store)
+			}
+		})
+	}
+}
+
+func TestLookupTokenPrefersKeyring(t *testing.T) {
+	isolate(t)
+	if err := keyring.Set(service, keyringItem("work"), "glpat-keyring"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sealToken("work", "glpat-sealed"); err != nil {
+		t.Fatal(err)
+	}
+	if token, store, err := lookupToken("work"); err != nil || token != "glpat-keyring" || store != StoreKeyring {
+		t.Errorf("lookupToken = %q, %q, %v, want the keyring's", token, store, err)
+	}
+}
@@ -0,0 +1,873 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/yourorg/tool/gitdiff"
+	"yourcorp/topology"
+)
+
+var (
+	topoModes = []string{"startup", "shutdown", "restart"}
+	topoViews = []string{"concrete", "logical"}
+)
+
+func newTopoCmd() *cobra.Command {
+	var flag string
+	cmd := &cobra.Command{
+		Use:   "topo",
+		Short: "Plan, draw and check the service topology",
+		Long: `Work with the service topology (--file, by default the workspace's: see
+'your-cli config workspace'): the startup and restart plans, the dependency
+graph, what a change to it does and what one app or node depends on. These
+replace the orchestrator and yaml2dot binaries.`,
+	}
+	file := func() string {
+		if flag != "" {
+			return flag
+		}
+		return workspaceOrDefault().topology()
+	}
+	cmd.PersistentFlags().StringVarP(&flag, "file", "f", "", "topology file (default "+defaultTopology+" at the workspace root)")
+	cmd.MarkPersistentFlagFilename("file", "yaml", "yml")
+	cmd.AddCommand(
+		newTopoPlanCmd(file),
+		newTopoDotCmd(file),
+		newTopoLintCmd(file),
+		newTopoDiffCmd(file),
+		newTopoImpactCmd(file),
+		newTopoQueryCmd(file),
+	)
+	return cmd
+}
+
+func newTopoPlanCmd(file func() string) *cobra.Command {
+	var mode, target, view string
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Print the startup, shutdown or restart plan, layer by layer",
+		Long: `Print the order the nodes are started or stopped in. The nodes of one layer
+have no dependencies on each other and run concurrently.
+
+  --mode startup   dependencies first (default)
+  --mode shutdown  dependents first
+  --mode restart   --target's host group and everything it depends on`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if mode == "restart" && view == "logical" {
+				return errors.New("--mode restart needs the concrete view: it restarts nodes, not apps")
+			}
+			graph, err := loadTopology(file(), view)
+			if err != nil {
+				return err
+			}
+
+			var name string
+			var order [][]*topology.Node
+			switch mode {
+			case "startup":
+				name, order = "Startup", topology.GetStartupOrder(graph)
+			case "shutdown":
+				name, order = "Shutdown", topology.GetShutdownOrder(graph)
+			case "restart":
+				if target == "" {
+					return errors.New("--mode restart needs --target")
+				}
+				sub, err := topology.GetSubgraphFor(graph, target)
+				if err != nil {
+					return err
+				}
+				name, order = "Restart", topology.GetStartupOrder(sub)
+			default:
+				return fmt.Errorf("--mode %q: want %s", mode, strings.Join(topoModes, ", "))
+			}
+
+			plan := topoPlan{Plan: strings.ToLower(name), Layers: planLayers(order)}
+			return writeResult(cmd.OutOrStdout(), plan, func(out io.Writer) error {
+				printPlan(out, name, order)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&mode, "mode", "startup", "plan to print: "+strings.Join(topoModes, ", "))
+	cmd.Flags().StringVar(&target, "target", "", "node to restart, with its host group, for --mode restart")
+	cmd.Flags().StringVar(&view, "view", "concrete", "concrete (nodes) or logical (apps)")
+	cmd.RegisterFlagCompletionFunc("mode", cobra.FixedCompletions(topoModes, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("target", completeNodes(file))
+	cmd.RegisterFlagCompletionFunc("view", cobra.FixedCompletions(topoViews, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+// topoPlan is topo plan's result: the node IDs of each layer, in order.
+type topoPlan struct {
+	Plan   string     `json:"plan"`
+	Layers [][]string `json:"layers"`
+}
+
+func newTopoDotCmd(file func() string) *cobra.Command {
+	var view, format, out, focus string
+	var up, down int
+	var layers bool
+	cmd := &cobra.Command{
+		Use:   "dot",
+		Short: "Draw the dependency graph with Graphviz",
+		Long: `Print the dependency graph in DOT, or render it with Graphviz's dot for any
+other -T, e.g.
+
+  your-cli topo dot -T svg -o topology.svg
+  your-cli topo dot --view logical | xdot -
+
+--focus draws the whole graph but dims everything except one node, its
+dependencies (--up hops of them) and its dependents (--down hops), and
+colours the paths through it:
+
+  your-cli topo dot --focus sor-01 --down 1 -T svg -o sor-01.svg
+
+--layers draws each startup layer on a rank of its own, in boot order from
+the top, as topo plan lists them.
+
+-T graphml and -T cytoscape write the graph for yEd and for Cytoscape, to
+lay out and annotate by hand; --focus and --layers are Graphviz only:
+
+  your-cli topo dot -T graphml -o topology.graphml
+
+The graph is the result: the global --output does not apply.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			graph, err := loadTopology(file(), view)
+			if err != nil {
+				return err
+			}
+			// host groups only exist between nodes
+			coLocation := view == "concrete"
+			editor := format == "graphml" || format == "cytoscape"
+			var src string
+			switch {
+			case editor && (focus != "" || layers):
+				return fmt.Errorf("--focus and --layers only apply to Graphviz output, not -T %s", format)
+			case format == "graphml":
+				src, err = graph.GraphML(topology.ExportOptions{ShowCoLocation: coLocation})
+			case format == "cytoscape":
+				src, err = graph.CytoscapeJSON(topology.ExportOptions{ShowCoLocation: coLocation})
+			default:
+				src, err = graph.DOT(topology.DOTOptions{ShowCoLocation: coLocation,
+					Focus: focus, Up: up, Down: down, RankByLayer: layers})
+			}
+			if err != nil {
+				return err
+			}
+
+			rendered := []byte(src)
+			if format != "dot" && !editor {
+				var stderr bytes.Buffer
+				render := exec.CommandContext(cmd.Context(), "dot", "-T"+format)
+				render.Stdin, render.Stderr = strings.NewReader(src), &stderr
+				if rendered, err = render.Output(); err != nil {
+					if errors.Is(err, exec.ErrNotFound) {
+						return errors.New("dot not found: install Graphviz, or use -T dot")
+					}
+					return fmt.Errorf("dot -T%s: %v: %s", format, err, strings.TrimSpace(stderr.String()))
+				}
+			}
+			if out != "" {
+				return os.WriteFile(out, rendered, 0o644)
+			}
+			_, err = cmd.OutOrStdout().Write(rendered)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&view, "view", "concrete", "concrete (nodes) or logical (apps)")
+	cmd.Flags().StringVarP(&format, "format", "T", "dot", "output format: dot, graphml, cytoscape, or any dot -T format (svg, png, …)")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "file to write to (default stdout)")
+	cmd.Flags().StringVar(&focus, "focus", "", "node (app with --view logical) to highlight, with its neighbourhood")
+	cmd.Flags().IntVar(&up, "up", -1, "with --focus, hops of dependencies to highlight; -1 is all")
+	cmd.Flags().IntVar(&down, "down", -1, "with --focus, hops of dependents to highlight; -1 is all")
+	cmd.Flags().BoolVar(&layers, "layers", false, "draw each startup layer on its own rank")
+	cmd.RegisterFlagCompletionFunc("view", cobra.FixedCompletions(topoViews, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions([]string{"dot", "svg", "png", "pdf", "graphml", "cytoscape"}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("focus", completeNodes(file))
+	return cmd
+}
+
+func newTopoLintCmd(file func() string) *cobra.Command {
+	var strict bool
+	var maxDepth int
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check that the topology is valid",
+		Long: `Parse and validate the topology as the orchestrator does: unknown
+dependencies, ambiguous shard counts and cycles are errors. Apps that depend
+on nothing and that nothing depends on are warnings, errors with --strict.
+
+So are apps whose longest dependency chain is more than --max-depth apps
+long: each is a startup layer the app waits for. In CI,
+
+  your-cli topo lint --strict --max-depth 6
+
+keeps new chains from slowing startups down further.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if !cmd.Flags().Changed("max-depth") {
+				maxDepth = workspaceOrDefault().MaxDepth
+			}
+			path := file()
+			graph, err := loadTopology(path, "concrete")
+			if err != nil {
+				return err
+			}
+			apps, err := graph.LogicalGraph()
+			if err != nil {
+				return err
+			}
+
+			var warnings []string
+			if len(apps.Nodes) > 1 {
+				needed := map[string]bool{}
+				for _, app := range apps.Nodes {
+					for _, dep := range app.DependsOn {
+						needed[dep.ID] = true
+					}
+				}
+				for _, id := range sortedIDs(apps) {
+					if len(apps.Nodes[id].DependsOn) == 0 && !needed[id] {
+						warnings = append(warnings, fmt.Sprintf("%s: isolated: it depends on nothing and nothing depends on it", id))
+					}
+				}
+			}
+			if maxDepth > 0 {
+				chains := dependencyChains(apps)
+				for _, id := range sortedIDs(apps) {
+					if chain := chains[id]; len(chain) > maxDepth {
+						warnings = append(warnings, fmt.Sprintf("%s: dependency chain of %d apps, more than %d: %s",
+							id, len(chain), maxDepth, strings.Join(chain, " → ")))
+					}
+				}
+			}
+
+			failed := strict && len(warnings) > 0
+			result := topoLint{File: path, Apps: len(apps.Nodes), Nodes: len(graph.Nodes),
+				Layers: len(topology.GetStartupOrder(graph)), Warnings: warnings}
+			err = writeResult(cmd.OutOrStdout(), result, func(out io.Writer) error {
+				for _, w := range warnings {
+					fmt.Fprintf(out, "⚠ %s\n", w)
+				}
+				if !failed {
+					fmt.Fprintf(out, "✔ %s: %d apps, %d nodes, %d startup layers\n",
+						result.File, result.Apps, result.Nodes, result.Layers)
+				}
+				return nil
+			})
+			if err == nil && failed {
+				err = fmt.Errorf("%s: %d warning(s) with --strict", path, len(warnings))
+			}
+			return err
+		},
+	}
+	cmd.Flags().BoolVar(&strict, "strict", false, "fail on warnings too")
+	cmd.Flags().IntVar(&maxDepth, "max-depth", 0, "warn about dependency chains longer than this many apps (default the workspace's max_depth; 0 is no limit)")
+	return cmd
+}
+
+// dependencyChains is, for every node of g, its longest chain of
+// dependencies: the node, what it depends on, and so on down to a node that
+// depends on nothing. Ties go to the dependency that sorts first.
+func dependencyChains(g *topology.Graph) map[string][]string {
+	chains := make(map[string][]string, len(g.Nodes))
+	var chain func(n *topology.Node) []string
+	chain = func(n *topology.Node) []string {
+		if c, ok := chains[n.ID]; ok {
+			return c
+		}
+		var longest []string
+		for _, dep := range n.DependsOn {
+			if c := chain(dep); len(c) > len(longest) || len(c) == len(longest) && c[0] < longest[0] {
+				longest = c
+			}
+		}
+		c := append([]string{n.ID}, longest...)
+		chains[n.ID] = c
+		return c
+	}
+	for _, n := range g.Nodes {
+		chain(n) // the parser has ruled out cycles
+	}
+	return chains
+}
+
+// topoLint is topo lint's result: the topology's size and its warnings.
+type topoLint struct {
+	File     string   `json:"file"`
+	Apps     int      `json:"apps"`
+	Nodes    int      `json:"nodes"`
+	Layers   int      `json:"startup_layers"`
+	Warnings []string `json:"warnings"`
+}
+
+func newTopoDiffCmd(file func() string) *cobra.Command {
+	var ref string
+	cmd := &cobra.Command{
+		Use:   "diff [OLD [NEW]]",
+		Short: "Show what a change to the topology does to the graph",
+		Long: `Compare two topologies node by node: nodes, dependencies and host groups
+added or removed, and nodes that move to another startup layer. OLD defaults
+to --file as committed at --ref and NEW to --file, so on a branch
+
+  your-cli topo diff --ref origin/main
+
+shows what merging it would change.`,
+		Args: cobra.MaximumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := file()
+			oldName, newName := ref+":"+path, path
+			var oldData []byte
+			var err error
+			switch len(args) {
+			case 0:
+				oldData, err = gitShow(ref, path)
+			default:
+				oldName = args[0]
+				oldData, err = os.ReadFile(oldName)
+			}
+			if err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				newName = args[1]
+			}
+			newData, err := os.ReadFile(newName)
+			if err != nil {
+				return err
+			}
+			oldGraph, err := parseTopology(oldData)
+			if err != nil {
+				return fmt.Errorf("%s: %w", oldName, err)
+			}
+			newGraph, err := parseTopology(newData)
+			if err != nil {
+				return fmt.Errorf("%s: %w", newName, err)
+			}
+
+			d := topoDiff{Old: oldName, New: newName,
+				GraphDiff: topology.Diff(oldGraph, newGraph), MovedLayers: layerChanges(oldGraph, newGraph)}
+			return writeResult(cmd.OutOrStdout(), d, func(out io.Writer) error {
+				if d.Empty() && len(d.MovedLayers) == 0 {
+					fmt.Fprintf(os.Stderr, "No changes between %s and %s.\n", oldName, newName)
+					return nil
+				}
+				printTopoDiff(out, d)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&ref, "ref", "HEAD", "git revision OLD is read from when it is not given")
+	return cmd
+}
+
+func printTopoDiff(out io.Writer, d topoDiff) {
+	for _, id := range d.AddedNodes {
+		fmt.Fprintf(out, "+ node %s\n", id)
+	}
+	for _, id := range d.RemovedNodes {
+		fmt.Fprintf(out, "- node %s\n", id)
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(out, "+ dep  %s → %s\n", e.From, e.To)
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(out, "- dep  %s → %s\n", e.From, e.To)
+	}
+	for _, m := range d.MovedNodes {
+		fmt.Fprintf(out, "~ host %s: %s → %s\n", m.ID, orDash(m.From), orDash(m.To))
+	}
+	for _, m := range d.MovedLayers {
+		fmt.Fprintf(out, "~ layer %s: %d → %d\n", m.ID, m.From, m.To)
+	}
+}
+
+// topoDiff is topo diff's result: the graph diff and the startup layers that
+// moved.
+type topoDiff struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+	topology.GraphDiff
+	MovedLayers []layerChange `json:"moved_layers,omitempty"`
+}
+
+// layerChange is a node that moved from startup layer From to To.
+type layerChange struct {
+	ID   string `json:"id"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+func newTopoImpactCmd(file func() string) *cobra.Command {
+	var since string
+	cmd := &cobra.Command{
+		Use:   "impact",
+		Short: "Show what the topology changes since a ref need restarted",
+		Long: `Show what changed in the topology since --since, the lines and the graph as
+topo diff shows it, and the host groups that need restarting to apply it:
+those of nodes added, removed, moved to another host group or whose
+dependencies changed. The topology at the merge base of --since and HEAD is
+compared with --file as it is now, uncommitted changes included, so
+
+  your-cli topo impact --since origin/main
+
+is what pipeline-gen works out for CI, for the person rolling it out.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			path, err := filepath.Abs(file())
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			repo, err := gitdiff.TopLevel(ctx, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			base, err := gitdiff.MergeBase(ctx, repo, since, "HEAD")
+			if err != nil {
+				return err
+			}
+			// the working tree against base: committed and uncommitted changes
+			hunks, err := gitdiff.ChangedHunks(ctx, repo, base)
+			if err != nil {
+				return err
+			}
+			rel, err := repoPath(repo, path)
+			if err != nil {
+				return err
+			}
+			impact := topoImpact{File: file(), Since: since, Base: base}
+			var changed *gitdiff.FileHunks
+			for i, h := range hunks {
+				if h.Path == rel {
+					changed = &hunks[i]
+				}
+			}
+			if changed == nil {
+				return writeResult(cmd.OutOrStdout(), impact, func(io.Writer) error {
+					fmt.Fprintf(os.Stderr, "%s has not changed since %s.\n", impact.File, since)
+					return nil
+				})
+			}
+			impact.Lines = changed.Added
+
+			newData, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			oldPath := path
+			if changed.OldPath != "" && changed.OldPath != changed.Path {
+				oldPath = filepath.Join(repo, filepath.FromSlash(changed.OldPath))
+			}
+			oldGraph := &topology.Graph{Nodes: map[string]*topology.Node{}}
+			oldData, err := gitShow(base, oldPath)
+			switch {
+			case err == nil:
+				if oldGraph, err = parseTopology(oldData); err != nil {
+					return fmt.Errorf("%s at %s: %w", impact.File, since, err)
+				}
+			case len(changed.Removed) > 0 || changed.OldPath != "":
+				return err
+			default:
+				// added since base: everything in it is new
+			}
+			newGraph, err := parseTopology(newData)
+			if err != nil {
+				return fmt.Errorf("%s: %w", impact.File, err)
+			}
+			impact.topoDiff = topoDiff{Old: since + ":" + impact.File, New: impact.File,
+				GraphDiff: topology.Diff(oldGraph, newGraph), MovedLayers: layerChanges(oldGraph, newGraph)}
+			impact.Restart = restartsFor(oldGraph, newGraph, impact.GraphDiff)
+
+			return writeResult(cmd.OutOrStdout(), impact, func(out io.Writer) error {
+				fmt.Fprintf(out, "%s changed since %s (%s): %s\n", impact.File, since, shortSHA(base), lineList(impact.Lines))
+				if impact.Empty() && len(impact.MovedLayers) == 0 {
+					fmt.Fprintln(out, "The graph is the same.")
+					return nil
+				}
+				printTopoDiff(out, impact.topoDiff)
+				if len(impact.Restart) == 0 {
+					return nil
+				}
+				fmt.Fprintln(out, "\nrestart:")
+				w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				for _, r := range impact.Restart {
+					fmt.Fprintf(w, "  %s\t%s\t%s\n", r.HostGroup, listOrDash(r.Nodes), strings.Join(r.Reasons, "; "))
+				}
+				return w.Flush()
+			})
+		},
+	}
+	cmd.Flags().StringVar(&since, "since", "origin/main", "git revision the changes are counted from, through its merge base with HEAD")
+	return cmd
+}
+
+// topoImpact is topo impact's result: the changed lines of the topology,
+// the graph diff and the host groups to restart.
+type topoImpact struct {
+	File  string              `json:"file"`
+	Since string              `json:"since"`
+	Base  string              `json:"merge_base"`
+	Lines []gitdiff.LineRange `json:"lines"` // in the topology as it is now
+	topoDiff
+	Restart []hostGroupRestart `json:"restart"`
+}
+
+// hostGroupRestart is a host group a topology change needs restarted: its
+// nodes after the change (none when they were all removed) and why.
+type hostGroupRestart struct {
+	HostGroup string   `json:"host_group"` // a node not co-located with others is a group of its own
+	Nodes     []string `json:"nodes"`
+	Reasons   []string `json:"reasons"`
+}
+
+// restartsFor lists the host groups of the nodes d adds, removes or moves,
+// and of those whose dependencies it changes, sorted.
+func restartsFor(old, new *topology.Graph, d topology.GraphDiff) []hostGroupRestart {
+	groupOf := func(n *topology.Node) string {
+		if id := n.HostGroupID(); id != "" {
+			return id
+		}
+		return n.ID
+	}
+	reasons := map[string][]string{}
+	add := func(g *topology.Graph, id, reason string) {
+		if n, ok := g.Nodes[id]; ok {
+			reasons[groupOf(n)] = append(reasons[groupOf(n)], reason)
+		}
+	}
+	added := map[string]bool{}
+	for _, id := range d.AddedNodes {
+		added[id] = true
+		add(new, id, id+" added")
+	}
+	for _, id := range d.RemovedNodes {
+		add(old, id, id+" removed")
+	}
+	// a new node starts with its dependencies; "added" says it all
+	for _, e := range d.AddedEdges {
+		if !added[e.From] {
+			add(new, e.From, fmt.Sprintf("%s now depends on %s", e.From, e.To))
+		}
+	}
+	for _, e := range d.RemovedEdges {
+		add(new, e.From, fmt.Sprintf("%s no longer depends on %s", e.From, e.To))
+	}
+	for _, m := range d.MovedNodes {
+		add(old, m.ID, m.ID+" moved out")
+		add(new, m.ID, m.ID+" moved in")
+	}
+
+	members := map[string][]string{}
+	for _, id := range sortedIDs(new) {
+		g := groupOf(new.Nodes[id])
+		members[g] = append(members[g], id)
+	}
+	restarts := make([]hostGroupRestart, 0, len(reasons))
+	for g, why := range reasons {
+		restarts = append(restarts, hostGroupRestart{HostGroup: g, Nodes: append([]string{}, members[g]...), Reasons: dedupe(why)})
+	}
+	sort.Slice(restarts, func(i, j int) bool { return restarts[i].HostGroup < restarts[j].HostGroup })
+	return restarts
+}
+
+// dedupe drops repeated strings, keeping the first of each.
+func dedupe(ss []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// repoPath is path relative to the work tree root repo, with slashes, as
+// git diff prints it.
+func repoPath(repo, path string) (string, error) {
+	if r, err := filepath.EvalSymlinks(repo); err == nil {
+		repo = r
+	}
+	dir, base := filepath.Split(path)
+	if d, err := filepath.EvalSymlinks(dir); err == nil {
+		path = filepath.Join(d, base)
+	}
+	rel, err := filepath.Rel(repo, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// lineList is "lines 3-5, 9", or "no lines" for a change that only renames.
+func lineList(lines []gitdiff.LineRange) string {
+	if len(lines) == 0 {
+		return "no lines"
+	}
+	parts := make([]string, len(lines))
+	for i, l := range lines {
+		parts[i] = fmt.Sprint(l.Start)
+		if l.End != l.Start {
+			parts[i] += fmt.Sprintf("-%d", l.End)
+		}
+	}
+	noun := "lines"
+	if len(lines) == 1 && lines[0].Start == lines[0].End {
+		noun = "line"
+	}
+	return noun + " " + strings.Join(parts, ", ")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+func newTopoQueryCmd(file func() string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "query <app|node>",
+		Short: "Show an app's or a node's dependencies, dependents and host group",
+		Long: `Show where an app or a node sits in the topology: its nodes or its host
+group, what it depends on, what depends on it and, for a node, its restart
+plan.`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeApps,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graph, err := loadTopology(file(), "concrete")
+			if err != nil {
+				return err
+			}
+			id := args[0]
+
+			if node, ok := graph.Nodes[id]; ok {
+				sub, err := topology.GetSubgraphFor(graph, id)
+				if err != nil {
+					return err
+				}
+				q := nodeQuery{Node: node.ID, App: node.BaseApp, HostGroup: node.HostGroupID(),
+					DependsOn: nodeIDs(node.DependsOn), NeededBy: dependents(graph, id)}
+				if node.ID != node.BaseApp {
+					q.Shard = &node.Shard
+				}
+				if node.HostGroup != nil {
+					for _, member := range node.HostGroup.Members {
+						q.Group = append(q.Group, member.ID) // sorted by ID
+					}
+				}
+				restart := topology.GetStartupOrder(sub)
+				q.Restart = planLayers(restart)
+				return writeResult(cmd.OutOrStdout(), q, func(out io.Writer) error {
+					w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+					fmt.Fprintf(w, "node\t%s\n", q.Node)
+					if q.Shard == nil {
+						fmt.Fprintf(w, "app\t%s\n", q.App)
+					} else {
+						fmt.Fprintf(w, "app\t%s (shard %d)\n", q.App, *q.Shard)
+					}
+					if q.HostGroup != "" {
+						fmt.Fprintf(w, "host group\t%s: %s\n", q.HostGroup, strings.Join(q.Group, ", "))
+					}
+					fmt.Fprintf(w, "depends on\t%s\n", listOrDash(q.DependsOn))
+					fmt.Fprintf(w, "needed by\t%s\n", listOrDash(q.NeededBy))
+					if err := w.Flush(); err != nil {
+						return err
+					}
+					fmt.Fprintln(out, "\nrestart plan:")
+					printPlan(out, "Restart", restart)
+					return nil
+				})
+			}
+
+			apps, err := graph.LogicalGraph()
+			if err != nil {
+				return err
+			}
+			app, ok := apps.Nodes[id]
+			if !ok {
+				return fmt.Errorf("no app or node %q in %s", id, file())
+			}
+			q := appQuery{App: app.ID, DependsOn: nodeIDs(app.DependsOn), NeededBy: dependents(apps, id)}
+			for _, node := range graph.Nodes {
+				if node.BaseApp == id {
+					q.Nodes = append(q.Nodes, node.ID)
+				}
+			}
+			sort.Strings(q.Nodes)
+			return writeResult(cmd.OutOrStdout(), q, func(out io.Writer) error {
+				w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+				fmt.Fprintf(w, "app\t%s\n", q.App)
+				fmt.Fprintf(w, "nodes\t%s\n", strings.Join(q.Nodes, ", "))
+				fmt.Fprintf(w, "depends on\t%s\n", listOrDash(q.DependsOn))
+				fmt.Fprintf(w, "needed by\t%s\n", listOrDash(q.NeededBy))
+				return w.Flush()
+			})
+		},
+	}
+}
+
+// nodeQuery is topo query's result for a node.
+type nodeQuery struct {
+	Node      string     `json:"node"`
+	App       string     `json:"app"`
+	Shard     *int       `json:"shard,omitempty"` // nil when the app is not sharded
+	HostGroup string     `json:"host_group,omitempty"`
+	Group     []string   `json:"host_group_nodes,omitempty"`
+	DependsOn []string   `json:"depends_on"`
+	NeededBy  []string   `json:"needed_by"`
+	Restart   [][]string `json:"restart_plan"`
+}
+
+// appQuery is topo query's result for an app.
+type appQuery struct {
+	App       string   `json:"app"`
+	Nodes     []string `json:"nodes"`
+	DependsOn []string `json:"depends_on"`
+	NeededBy  []string `json:"needed_by"`
+}
+
+// parseTopology validates and expands a topology, with the workspace's
+// max_shards.
+func parseTopology(data []byte) (*topology.Graph, error) {
+	return topology.ParseYAMLWithOptions(data, topology.ParseOptions{MaxShards: workspaceOrDefault().maxShards()})
+}
+
+// loadTopology reads and validates the topology at path, in the given view.
+func loadTopology(path, view string) (*topology.Graph, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := parseTopology(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	switch view {
+	case "concrete":
+		return graph, nil
+	case "logical":
+		return graph.LogicalGraph()
+	}
+	return nil, fmt.Errorf("--view %q: want concrete or logical", view)
+}
+
+// gitShow reads path as committed at ref, from the repository path is in.
+func gitShow(ref, path string) ([]byte, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	var stderr bytes.Buffer
+	show := exec.Command("git", "-C", dir, "show", ref+":./"+base)
+	show.Stderr = &stderr
+	data, err := show.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git show %s:%s: %s", ref, path, firstLine(stderr.Bytes(), err))
+	}
+	return data, nil
+}
+
+// layerChanges lists the nodes in both graphs whose startup layer moved.
+func layerChanges(old, new *topology.Graph) []layerChange {
+	oldLayer, newLayer := startupLayers(old), startupLayers(new)
+	var changes []layerChange
+	for _, id := range sortedIDs(new) {
+		before, ok := oldLayer[id]
+		if ok && before != newLayer[id] {
+			changes = append(changes, layerChange{ID: id, From: before, To: newLayer[id]})
+		}
+	}
+	return changes
+}
+
+// startupLayers maps each node to its 1-based startup layer.
+func startupLayers(g *topology.Graph) map[string]int {
+	layers := map[string]int{}
+	for i, layer := range topology.GetStartupOrder(g) {
+		for _, node := range layer {
+			layers[node.ID] = i + 1
+		}
+	}
+	return layers
+}
+
+func dependents(g *topology.Graph, id string) []string {
+	ids := []string{}
+	for _, node := range g.Nodes {
+		for _, dep := range node.DependsOn {
+			if dep.ID == id {
+				ids = append(ids, node.ID)
+				break
+			}
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func printPlan(w io.Writer, name string, order [][]*topology.Node) {
+	if len(order) == 0 {
+		fmt.Fprintln(w, "  No operations required.")
+		return
+	}
+	for i, layer := range order {
+		fmt.Fprintf(w, "  %s Layer %d (Concurrent): [ %s ]\n", name, i+1, strings.Join(nodeIDs(layer), ", "))
+	}
+}
+
+func planLayers(order [][]*topology.Node) [][]string {
+	layers := make([][]string, len(order))
+	for i, layer := range order {
+		layers[i] = nodeIDs(layer)
+	}
+	return layers
+}
+
+func nodeIDs(nodes []*topology.Node) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedIDs(g *topology.Graph) []string {
+	ids := make([]string, 0, len(g.Nodes))
+	for id := range g.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func listOrDash(ids []string) string {
+	return orDash(strings.Join(ids, ", "))
+}
+
+// completeNodes completes node IDs from the topology file returns, which
+// only sees --file once the flags are parsed, as they are when completing.
+func completeNodes(file func() string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		graph, err := loadTopology(file(), "concrete")
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return withPrefix(sortedIDs(graph), toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+}
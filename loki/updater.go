@@ -33,30 +33,32 @@ func diff(cur, next string) Level {
 // ============================================================================
 // File: internal/updater/updater.go
 // ----------------------------------------------------------------------------
-// Core update-checker + downloader. No third-party self-update libs. Only uses
-// GitLab's official Go client.
+// Core update-checker + downloader. No third-party self-update libs; GitLab
+// is reached through internal/gitlab.
 // ============================================================================
 package updater
 
 import (
-    "bufio"
+    "archive/tar"
+    "archive/zip"
     "compress/gzip"
     "context"
-    "crypto/sha256"
-    "encoding/hex"
     "errors"
     "fmt"
     "io"
     "log/slog"
     "net/http"
     "os"
+    "os/exec"
+    "path"
     "path/filepath"
     "runtime"
     "strings"
     "time"
 
-    "gitlab.com/gitlab-org/api/client-go/gitlab"
     "golang.org/x/mod/semver"
+
+    "your-cli/internal/gitlab"
 )
 
 // ---------------------------------------------------------------------------
@@ -67,28 +69,46 @@ var (
     ErrMinorChange      = errors.New("new features available in minor version change")
     ErrNoUpdate         = errors.New("no new version available")
     ErrChecksumMismatch = errors.New("downloaded file checksum does not match expected checksum")
+    ErrUnsigned         = errors.New("release checksums are not signed")
+    ErrBadSignature     = errors.New("release checksums signature does not verify")
+    ErrNoPublicKey      = errors.New("no release signing key built into this binary")
+    ErrVerifyFailed     = errors.New("installed binary does not report the new version")
 )
 
 // ---------------------------------------------------------------------------
 // ReleaseInfo – what a caller needs to decide what message to print.
 // ---------------------------------------------------------------------------
 type ReleaseInfo struct {
-    Version     string
-    BinaryURL   string
-    AssetName   string
-    ChecksumURL string
-    ChangeType  error // one of ErrMajorChange / ErrMinorChange
+    Version        string
+    CurrentVersion string // the running version, recorded in the install manifest
+    BinaryURL      string
+    AssetName      string
+    ChecksumURL    string
+    SignatureURL   string            // detached signature of the checksums file; empty when the release is unsigned
+    ChangeType     error             // one of ErrMajorChange / ErrMinorChange
+    Notes          string            // the release description (Markdown)
+    ManifestURL    string            // binaries.json listing auxiliary binaries; empty when the CLI ships alone
+    Assets         map[string]string // every asset link of the release, name → URL
 }
 
 // ---------------------------------------------------------------------------
 // Functional options – lets tests override baseURL / httpClient easily.
 // ---------------------------------------------------------------------------
-type option func(*opts)
+type Option func(*opts)
 
 type opts struct {
     baseURL    string
     httpClient *http.Client
     logger     *slog.Logger
+    binaryName string // archive entry to install; ".exe" is added on Windows
+    publicKey  []byte // PEM key the checksums signature must verify against
+    executable string // binary to replace; empty means os.Executable (tests point it at a temp file)
+    pin        string // only offer versions in this series, see WithPin
+    skip       map[string]bool
+    verifyArgs []string // run after the swap; the output must mention the new version
+    network    Network  // applied to httpClient's transport on first use
+    netReady   bool
+    assetTmpl  string // release asset name, see WithAssetTemplate
 }
 
 func defaultOpts() *opts {
@@ -96,17 +116,80 @@ func defaultOpts() *opts {
         baseURL:    "https://gitlab.com",
         httpClient: http.DefaultClient,
         logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+        binaryName: "your-cli",
+        publicKey:  embeddedPublicKey(),
+        verifyArgs: []string{"--version"},
+        assetTmpl:  DefaultAssetTemplate,
+    }
+}
+
+func WithBaseURL(u string) Option   { return func(o *opts) { o.baseURL = u } }
+func WithHTTPClient(c *http.Client) Option { return func(o *opts) { o.httpClient = c } }
+func WithNetwork(n Network) Option         { return func(o *opts) { o.network = n } }
+func WithLogger(l *slog.Logger) Option     { return func(o *opts) { o.logger = l } }
+func WithBinaryName(n string) Option       { return func(o *opts) { o.binaryName = n } }
+func WithPublicKey(pem []byte) Option      { return func(o *opts) { o.publicKey = pem } }
+
+// WithVerifyArgs sets the arguments the new binary is run with to confirm the
+// install; its output must contain the new version. No arguments disables the check.
+func WithVerifyArgs(args ...string) Option { return func(o *opts) { o.verifyArgs = args } }
+
+// WithAssetTemplate sets the text/template the release asset for this
+// platform is named by; see assetVars for the fields. Empty keeps the default.
+func WithAssetTemplate(t string) Option {
+    return func(o *opts) {
+        if t != "" {
+            o.assetTmpl = t
+        }
+    }
+}
+
+// WithPin limits updates to a series: "v1" (any v1.x.y), "v1.4" (any v1.4.x)
+// or one exact version. An empty pin allows everything.
+func WithPin(pin string) Option { return func(o *opts) { o.pin = canonical(pin) } }
+
+// WithSkip ignores the given versions; newer releases are still offered.
+func WithSkip(versions ...string) Option {
+    return func(o *opts) {
+        if o.skip == nil {
+            o.skip = make(map[string]bool)
+        }
+        for _, v := range versions {
+            o.skip[canonical(v)] = true
+        }
     }
 }
 
-func WithBaseURL(u string) option   { return func(o *opts) { o.baseURL = u } }
-func WithHTTPClient(c *http.Client) option { return func(o *opts) { o.httpClient = c } }
-func WithLogger(l *slog.Logger) option     { return func(o *opts) { o.logger = l } }
+// canonical adds the "v" prefix semver needs, so "1.4" and "v1.4" agree.
+func canonical(v string) string {
+    if v != "" && !strings.HasPrefix(v, "v") {
+        return "v" + v
+    }
+    return v
+}
+
+// allows reports whether version is acceptable under the pin and skip list.
+func (o *opts) allows(version string) bool {
+    if o.skip[version] {
+        return false
+    }
+    switch strings.Count(o.pin, ".") {
+    case 0:
+        return o.pin == "" || semver.Major(version) == o.pin
+    case 1:
+        return semver.MajorMinor(version) == o.pin
+    default:
+        return semver.Compare(version, o.pin) == 0
+    }
+}
+
+// releasesPerCheck is how many recent releases CheckForUpdates considers.
+const releasesPerCheck = 20
 
 // ---------------------------------------------------------------------------
 // CheckForUpdates – network-calls only.
 // ---------------------------------------------------------------------------
-func CheckForUpdates(ctx context.Context, currentVersion, projectSlug, token string, optFns ...option) (*ReleaseInfo, error) {
+func CheckForUpdates(ctx context.Context, currentVersion, projectSlug, token string, optFns ...Option) (*ReleaseInfo, error) {
     if !semver.IsValid(currentVersion) {
         return nil, fmt.Errorf("current version %q is not valid semver", currentVersion)
     }
@@ -115,43 +198,70 @@ func CheckForUpdates(ctx context.Context, currentVersion, projectSlug, token str
         f(o)
     }
 
-    cli, err := gitlab.NewClient(token, gitlab.WithBaseURL(o.baseURL), gitlab.WithHTTPClient(o.httpClient))
+    hc, err := o.client()
+    if err != nil {
+        return nil, err
+    }
+    cli, err := gitlab.New(token, gitlab.WithBaseURL(o.baseURL), gitlab.WithHTTPClient(hc), gitlab.WithLogger(o.logger))
     if err != nil {
-        return nil, fmt.Errorf("create gitlab client: %w", err)
+        return nil, err
     }
 
-    rels, _, err := cli.Releases.ListReleases(projectSlug, &gitlab.ListReleasesOptions{PerPage: 1})
+    // a skipped or out-of-pin latest release must not hide an older one that
+    // is still allowed, so look at a page of recent releases, not just one
+    rels, err := cli.Releases(ctx, projectSlug, releasesPerCheck)
     if err != nil {
         return nil, fmt.Errorf("fetch releases: %w", err)
     }
-    if len(rels) == 0 {
-        return nil, ErrNoUpdate
+    var latest *gitlab.Release
+    for _, r := range rels {
+        v := r.TagName
+        if !semver.IsValid(v) || semver.Compare(currentVersion, v) >= 0 || !o.allows(v) {
+            continue
+        }
+        if latest == nil || semver.Compare(v, latest.TagName) > 0 {
+            latest = r
+        }
     }
-    latest := rels[0]
-    latestVer := latest.TagName
-    if semver.Compare(currentVersion, latestVer) >= 0 {
+    if latest == nil {
         return nil, ErrNoUpdate
     }
+    latestVer := latest.TagName
 
-    assetName := fmt.Sprintf("your-cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
-    var binURL, cksURL string
+    names, err := assetNames(o.assetTmpl, latestVer, runtime.GOOS, runtime.GOARCH)
+    if err != nil {
+        return nil, err
+    }
+    links := make(map[string]string, len(latest.Assets.Links))
     for _, l := range latest.Assets.Links {
-        switch {
-        case l.Name == assetName:
-            binURL = l.URL
-        case l.Name == "checksums.sha256":
-            cksURL = l.URL
+        links[l.Name] = l.URL
+    }
+    var assetName, binURL string
+    for _, n := range names {
+        if u, ok := links[n]; ok {
+            assetName, binURL = n, u
+            break
         }
     }
+    cksName := checksumsAsset(links)
+    cksURL, sigURL := links[cksName], links[cksName+".sig"]
     if binURL == "" || cksURL == "" {
-        return nil, fmt.Errorf("required assets missing in release %s", latestVer)
+        return nil, fmt.Errorf("required assets missing in release %s (want %s and %s)", latestVer, names[0], checksumsName)
+    }
+    if assetName != names[0] {
+        o.logger.Info("no native build in release, using the amd64 one under Rosetta", "asset", assetName)
     }
 
     info := &ReleaseInfo{
-        Version:     latestVer,
-        BinaryURL:   binURL,
-        AssetName:   assetName,
-        ChecksumURL: cksURL,
+        Version:        latestVer,
+        CurrentVersion: currentVersion,
+        BinaryURL:      binURL,
+        AssetName:      assetName,
+        ChecksumURL:    cksURL,
+        SignatureURL:   sigURL,
+        Notes:          latest.Description,
+        ManifestURL:    links[binariesManifestName],
+        Assets:         links,
     }
     if semver.Major(currentVersion) != semver.Major(latestVer) {
         info.ChangeType = ErrMajorChange
@@ -162,78 +272,186 @@ func CheckForUpdates(ctx context.Context, currentVersion, projectSlug, token str
 }
 
 // ---------------------------------------------------------------------------
-// ApplyUpdate – download, verify checksum, untar+swap.
+// ApplyUpdate – download, verify checksum, extract+swap.
 // ---------------------------------------------------------------------------
-func ApplyUpdate(ctx context.Context, info *ReleaseInfo, token string, optFns ...option) error {
+func ApplyUpdate(ctx context.Context, info *ReleaseInfo, token string, optFns ...Option) error {
     o := defaultOpts()
     for _, f := range optFns {
         f(o)
     }
 
-    // 1. download checksums file first
-    cksMap, err := fetchChecksums(ctx, info.ChecksumURL, token, o)
+    // 0. one update at a time: two shells swapping the same binary would
+    //    leave either one's .old pointing at the wrong version
+    curExe, err := o.exePath()
     if err != nil {
         return err
     }
-    expected, ok := cksMap[info.AssetName]
-    if !ok {
-        return fmt.Errorf("checksum file missing entry for %s", info.AssetName)
+    unlock, err := acquireLock(curExe)
+    if err != nil {
+        return err
     }
+    defer unlock()
 
-    // 2. download binary asset (tgz)
-    tgzPath, err := downloadTemp(ctx, info.BinaryURL, token, o)
+    // 1. download checksums file first and check its signature; nothing below
+    //    is trusted unless it traces back to the embedded release key
+    cksMap, err := fetchChecksums(ctx, info, token, o)
     if err != nil {
         return err
     }
-    defer os.Remove(tgzPath)
 
-    if err := verifySHA256(tgzPath, expected); err != nil {
+    // 2. download, verify and extract every binary (tar.gz / zip) before
+    //    replacing any, so a failed download cannot leave a half-updated install
+    main, err := stageBinary(ctx, info.BinaryURL, info.AssetName, exeName(o.binaryName), curExe, cksMap, token, o)
+    if err != nil {
         return err
     }
-
-    // 3. extract actual binary out of tar.gz
-    binTmp, err := extractBinary(tgzPath)
+    extras, err := stageExtras(ctx, info, curExe, cksMap, token, o)
+    staged := append(extras, main) // the CLI goes last
+    defer func() {
+        for _, b := range staged {
+            os.Remove(b.tmp)
+        }
+    }()
     if err != nil {
         return err
     }
-    defer os.Remove(binTmp)
 
-    // 4. atomic swap
-    curExe, err := os.Executable()
+    // 3. atomic swaps; each replaced binary stays behind as <name>.old for Rollback
+    var installed []stagedBinary
+    for _, b := range staged {
+        if err := b.install(); err != nil {
+            return restoreAll(installed, err, o)
+        }
+        installed = append(installed, b)
+    }
+
+    // 4. make sure the new binary runs and is the version we meant to install;
+    //    otherwise put the old ones back before anyone else runs them
+    if err := verifyInstall(ctx, curExe, info.Version, o.verifyArgs); err != nil {
+        return restoreAll(installed, err, o)
+    }
+    if err := recordInstall(curExe, info.Version, info.CurrentVersion); err != nil {
+        o.logger.Warn("could not update install manifest", "err", err)
+    }
+    return nil
+}
+
+// stagedBinary is a verified binary extracted next to the one it replaces.
+type stagedBinary struct {
+    dest    string
+    tmp     string
+    existed bool // false for a helper installed for the first time
+}
+
+// stageBinary downloads an archive, checks it against sums and extracts
+// binName into dest's directory, so installing it is a same-filesystem rename.
+func stageBinary(ctx context.Context, url, assetName, binName, dest string, sums map[string]string, token string, o *opts) (stagedBinary, error) {
+    expected, ok := sums[assetName]
+    if !ok {
+        return stagedBinary{}, fmt.Errorf("checksum file missing entry for %s", assetName)
+    }
+    archivePath, err := downloadTemp(ctx, url, token, o)
     if err != nil {
-        return err
+        return stagedBinary{}, err
     }
-    if runtime.GOOS == "windows" {
-        return swapWindows(curExe, binTmp)
+    defer os.Remove(archivePath)
+    if err := verifyFile(archivePath, expected); err != nil {
+        return stagedBinary{}, err
+    }
+    if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+        return stagedBinary{}, err
+    }
+    tmp, err := extractBinary(archivePath, assetName, binName, filepath.Dir(dest))
+    if err != nil {
+        return stagedBinary{}, err
+    }
+    return stagedBinary{dest: dest, tmp: tmp}, nil
+}
+
+func (b *stagedBinary) install() error {
+    _, err := os.Lstat(b.dest)
+    b.existed = err == nil
+    return replaceBinary(b.dest, b.tmp)
+}
+
+// restoreAll undoes installs after err, newest first.
+func restoreAll(installed []stagedBinary, err error, o *opts) error {
+    for i := len(installed) - 1; i >= 0; i-- {
+        b := installed[i]
+        rerr := os.Remove(b.dest)
+        if b.existed {
+            rerr = os.Rename(b.dest+".old", b.dest)
+        }
+        if rerr != nil {
+            return fmt.Errorf("%w; restoring %s failed: %v", err, b.dest, rerr)
+        }
+    }
+    o.logger.Warn("update failed, previous binaries restored", "err", err)
+    return err
+}
+
+// exeName adds ".exe" on Windows.
+func exeName(name string) string {
+    if runtime.GOOS == "windows" && !strings.HasSuffix(name, ".exe") {
+        return name + ".exe"
+    }
+    return name
+}
+
+// verifyInstall runs exe with args and checks that it reports version.
+func verifyInstall(ctx context.Context, exe, version string, args []string) error {
+    if len(args) == 0 {
+        return nil
+    }
+    ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+    defer cancel()
+    out, err := exec.CommandContext(ctx, exe, args...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("%w: %s %s: %v", ErrVerifyFailed, exe, strings.Join(args, " "), err)
+    }
+    if !strings.Contains(string(out), version) && !strings.Contains(string(out), strings.TrimPrefix(version, "v")) {
+        return fmt.Errorf("%w: want %s, got %q", ErrVerifyFailed, version, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
+
+// exePath is the binary an update replaces.
+func (o *opts) exePath() (string, error) {
+    if o.executable != "" {
+        return o.executable, nil
     }
-    return os.Rename(binTmp, curExe)
+    return os.Executable()
 }
 
 // ---------------------------------------------------------------------------
 // helpers – download & verify
 // ---------------------------------------------------------------------------
-func fetchChecksums(ctx context.Context, url, token string, o *opts) (map[string]string, error) {
-    tmp, err := downloadTemp(ctx, url, token, o)
+func fetchChecksums(ctx context.Context, info *ReleaseInfo, token string, o *opts) (map[string]string, error) {
+    data, err := downloadBytes(ctx, info.ChecksumURL, token, o)
     if err != nil {
         return nil, err
     }
-    defer os.Remove(tmp)
-
-    f, err := os.Open(tmp)
+    if info.SignatureURL == "" {
+        return nil, fmt.Errorf("%w: release %s has no signature of its checksums", ErrUnsigned, info.Version)
+    }
+    sig, err := downloadBytes(ctx, info.SignatureURL, token, o)
     if err != nil {
         return nil, err
     }
-    defer f.Close()
+    if err := verifySignature(data, sig, o.publicKey); err != nil {
+        return nil, err
+    }
+    return parseChecksums(data)
+}
 
-    m := make(map[string]string)
-    scanner := bufio.NewScanner(f)
-    for scanner.Scan() {
-        parts := strings.Fields(scanner.Text())
-        if len(parts) == 2 {
-            m[parts[1]] = parts[0]
-        }
+// downloadBytes fetches a small asset (checksums, signatures) into memory.
+func downloadBytes(ctx context.Context, url, token string, o *opts) ([]byte, error) {
+    tmp, err := downloadTemp(ctx, url, token, o)
+    if err != nil {
+        return nil, err
     }
-    return m, scanner.Err()
+    defer os.Remove(tmp)
+    return os.ReadFile(tmp)
 }
 
 func downloadTemp(ctx context.Context, url, token string, o *opts) (string, error) {
@@ -241,7 +459,11 @@ func downloadTemp(ctx context.Context, url, token string, o *opts) (string, erro
     if token != "" {
         req.Header.Set("PRIVATE-TOKEN", token)
     }
-    resp, err := o.httpClient.Do(req)
+    hc, err := o.client()
+    if err != nil {
+        return "", err
+    }
+    resp, err := hc.Do(req)
     if err != nil {
         return "", err
     }
@@ -262,194 +484,1654 @@ func downloadTemp(ctx context.Context, url, token string, o *opts) (string, erro
     return tmp.Name(), nil
 }
 
-func verifySHA256(path, expected string) error {
+func verifyFile(path, expected string) error {
     f, err := os.Open(path)
     if err != nil {
         return err
     }
     defer f.Close()
-    h := sha256.New()
-    if _, err := io.Copy(h, f); err != nil {
-        return err
-    }
-    got := hex.EncodeToString(h.Sum(nil))
-    if got != expected {
-        return fmt.Errorf("%w: exp %s got %s", ErrChecksumMismatch, expected, got)
-    }
-    return nil
+    return verifyChecksum(f, expected)
 }
 
 // ---------------------------------------------------------------------------
-// tar extraction
+// archive extraction
 // ---------------------------------------------------------------------------
-func extractBinary(tgz string) (string, error) {
-    f, err := os.Open(tgz)
+
+// ErrBinaryNotFound is returned when the archive has no entry named like the binary.
+var ErrBinaryNotFound = errors.New("binary not found in release archive")
+
+// extractBinary copies the entry whose base name is binName out of the
+// archive into a new file in dir and returns its path. The format follows
+// assetName (.zip, otherwise tar.gz); the entry's permission bits are kept,
+// falling back to 0755 for archives that record none (zips made on Windows).
+func extractBinary(archivePath, assetName, binName, dir string) (string, error) {
+    if strings.HasSuffix(assetName, ".zip") {
+        return extractZip(archivePath, binName, dir)
+    }
+    return extractTarGz(archivePath, binName, dir)
+}
+
+func extractTarGz(archivePath, binName, dir string) (string, error) {
+    f, err := os.Open(archivePath)
     if err != nil {
         return "", err
     }
     defer f.Close()
     gz, err := gzip.NewReader(f)
     if err != nil {
-        return "", err
+        return "", fmt.Errorf("read release archive: %w", err)
     }
     defer gz.Close()
 
-    tr := io.TeeReader(gz, io.Discard)
-    // We only need to copy first file out (goreleaser puts bin at root)
-    // Very small hand-rolled extractor:
-    tmp := filepath.Join(os.TempDir(), "yourcli-new-")
-    out, err := os.CreateTemp("", "yourcli-bin-*")
+    tr := tar.NewReader(gz)
+    for {
+        hdr, err := tr.Next()
+        if err == io.EOF {
+            return "", fmt.Errorf("%w: %s", ErrBinaryNotFound, binName)
+        }
+        if err != nil {
+            return "", fmt.Errorf("read release archive: %w", err)
+        }
+        if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != binName {
+            continue
+        }
+        return writeBinary(tr, hdr.FileInfo().Mode().Perm(), dir)
+    }
+}
+
+func extractZip(archivePath, binName, dir string) (string, error) {
+    zr, err := zip.OpenReader(archivePath)
+    if err != nil {
+        return "", fmt.Errorf("read release archive: %w", err)
+    }
+    defer zr.Close()
+
+    for _, zf := range zr.File {
+        if zf.FileInfo().IsDir() || path.Base(zf.Name) != binName {
+            continue
+        }
+        rc, err := zf.Open()
+        if err != nil {
+            return "", err
+        }
+        defer rc.Close()
+        return writeBinary(rc, zf.Mode().Perm(), dir)
+    }
+    return "", fmt.Errorf("%w: %s", ErrBinaryNotFound, binName)
+}
+
+// writeBinary streams r into a temp file in dir with the given permissions.
+func writeBinary(r io.Reader, perm os.FileMode, dir string) (string, error) {
+    if perm == 0 {
+        perm = 0o755
+    }
+    out, err := os.CreateTemp(dir, ".yourcli-new-*")
     if err != nil {
         return "", err
     }
-    if _, err := io.Copy(out, tr); err != nil {
+    if _, err := io.Copy(out, r); err != nil {
+        out.Close()
+        os.Remove(out.Name())
+        return "", err
+    }
+    if err := out.Chmod(perm); err != nil && runtime.GOOS != "windows" {
         out.Close()
+        os.Remove(out.Name())
+        return "", err
+    }
+    if err := out.Close(); err != nil {
+        os.Remove(out.Name())
         return "", err
     }
-    out.Chmod(0o755)
-    out.Close()
     return out.Name(), nil
 }
 
 // ============================================================================
-// File: internal/updater/swap_windows.go (build tag)
-// +build windows
+// File: internal/updater/asset.go
+// ----------------------------------------------------------------------------
+// Which release asset belongs to this platform. The name is a text/template
+// so releases built by other pipelines (GoReleaser, hand-rolled CI) work too.
 // ============================================================================
-
 package updater
 
 import (
-    "os"
-    "syscall"
+    "fmt"
+    "strings"
+    "text/template"
 )
 
-func swapWindows(dest, src string) error {
-    destBackup := dest + ".old"
-    // remove any stale .old
-    _ = os.Remove(destBackup)
-    if err := os.Rename(dest, destBackup); err != nil {
-        return err
+// DefaultAssetTemplate is how our release pipeline names its archives.
+const DefaultAssetTemplate = "your-cli_{{.OS}}_{{.Arch}}{{.Ext}}"
+
+// assetVars are the fields available to an asset template.
+type assetVars struct {
+    OS      string // GOOS, e.g. "darwin"
+    Arch    string // GOARCH, e.g. "arm64"
+    Tag     string // release tag, e.g. "v1.4.0"
+    Version string // Tag without the leading "v"
+    Ext     string // ".zip" on Windows, ".tar.gz" elsewhere
+}
+
+// assetExt is the archive format published per OS: zip for Windows, tar.gz elsewhere.
+func assetExt(goos string) string {
+    if goos == "windows" {
+        return ".zip"
     }
-    // MOVEFILE_REPLACE_EXISTING
-    return syscall.MoveFileEx(syscall.StringToUTF16Ptr(src), syscall.StringToUTF16Ptr(dest), syscall.MOVEFILE_REPLACE_EXISTING)
+    return ".tar.gz"
 }
 
-// ============================================================================
-// File: internal/updater/swap_unix.go (build tag)
-// +build !windows
-// ============================================================================
+// assetNames renders tmpl for a platform, best match first. Apple silicon
+// Macs fall back to the amd64 build, which runs under Rosetta.
+func assetNames(tmpl, tag, goos, goarch string) ([]string, error) {
+    t, err := template.New("asset").Option("missingkey=error").Parse(tmpl)
+    if err != nil {
+        return nil, fmt.Errorf("parse asset template: %w", err)
+    }
+    render := func(arch string) (string, error) {
+        var b strings.Builder
+        err := t.Execute(&b, assetVars{
+            OS:      goos,
+            Arch:    arch,
+            Tag:     tag,
+            Version: strings.TrimPrefix(tag, "v"),
+            Ext:     assetExt(goos),
+        })
+        if err != nil {
+            return "", fmt.Errorf("render asset template: %w", err)
+        }
+        return b.String(), nil
+    }
 
-// empty – Unix handled by os.Rename in main code
+    native, err := render(goarch)
+    if err != nil {
+        return nil, err
+    }
+    names := []string{native}
+    if goos == "darwin" && goarch == "arm64" {
+        rosetta, err := render("amd64")
+        if err != nil {
+            return nil, err
+        }
+        if rosetta != native {
+            names = append(names, rosetta)
+        }
+    }
+    return names, nil
+}
 
 // ============================================================================
-// File: internal/updater/updater_test.go
+// File: internal/updater/binaries.go
 // ----------------------------------------------------------------------------
-// Basic coverage: no-update, minor, major, checksum mismatch.
+// Releases that ship more than the CLI (e.g. a helper daemon) attach a
+// binaries.json asset listing the extra binaries and where they go:
+//
+//   {"binaries": [{"name": "your-cli-agent",
+//                  "asset": "your-cli-agent_{{.OS}}_{{.Arch}}{{.Ext}}"}]}
+//
+// The manifest must be listed in the checksums file like every other asset,
+// since it decides what gets installed where.
 // ============================================================================
-package updater_test
+package updater
 
 import (
     "bytes"
     "context"
-    "crypto/sha256"
-    "encoding/hex"
+    "encoding/json"
     "fmt"
-    "net/http"
-    "net/http/httptest"
-    "os"
+    "path/filepath"
     "runtime"
-    "strings"
-    "testing"
-
-    "github.com/stretchr/testify/require"
-    "your-cli/internal/updater"
 )
 
-// fakeGitLab spins a minimal GitLab Releases API with one release.
-func fakeGitLab(t *testing.T, tag string, assetBody []byte, goodChecksum bool) *httptest.Server {
-    t.Helper()
-    assetName := fmt.Sprintf("your-cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
-    sum := sha256.Sum256(assetBody)
-    checksum := hex.EncodeToString(sum[:])
-    if !goodChecksum {
-        checksum = strings.Repeat("0", 64)
-    }
-    cksContent := fmt.Sprintf("%s  %s\n", checksum, assetName)
+const binariesManifestName = "binaries.json"
 
-    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        switch {
-        case strings.HasSuffix(r.URL.Path, "/releases"):
-            fmt.Fprintf(w, `[{"tag_name":"%s","assets":{"links":[{"name":"%s","url":"%s/assets/bin"},{"name":"checksums.sha256","url":"%s/assets/cks"}]}}]`, tag, assetName, srv.URL, srv.URL)
-        case strings.HasSuffix(r.URL.Path, "/assets/bin"):
-            w.Write(assetBody)
-        case strings.HasSuffix(r.URL.Path, "/assets/cks"):
-            w.Write([]byte(cksContent))
-        default:
-            http.NotFound(w, r)
-        }
-    }))
-    return srv
+// Binary is one auxiliary binary shipped with a release.
+type Binary struct {
+    Name  string `json:"name"`           // archive entry; ".exe" is added on Windows
+    Asset string `json:"asset"`          // asset name template, with the fields of assetVars
+    Dest  string `json:"dest,omitempty"` // install path; relative paths (and the default, Name) are next to the CLI
 }
 
-func TestNoUpdate(t *testing.T) {
-    srv := fakeGitLab(t, "v1.0.0", []byte("dummy"), true)
-    defer srv.Close()
-
-    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
-    require.ErrorIs(t, err, updater.ErrNoUpdate)
-    require.Nil(t, info)
+func (b Binary) dest(cliExe string) string {
+    d := b.Dest
+    if d == "" {
+        d = exeName(b.Name)
+    }
+    if filepath.IsAbs(d) {
+        return d
+    }
+    return filepath.Join(filepath.Dir(cliExe), d)
 }
 
-func TestMinorAndMajor(t *testing.T) {
-    srv := fakeGitLab(t, "v1.1.0", []byte("dummy"), true)
-    defer srv.Close()
-    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
-    require.NoError(t, err)
-    require.Equal(t, updater.ErrMinorChange, info.ChangeType)
-
-    srv2 := fakeGitLab(t, "v2.0.0", []byte("dummy"), true)
-    defer srv2.Close()
-    info2, err := updater.CheckForUpdates(context.Background(), "v1.1.0", "dummy", "", updater.WithBaseURL(srv2.URL))
-    require.NoError(t, err)
-    require.Equal(t, updater.ErrMajorChange, info2.ChangeType)
-}
+// stageExtras stages every binary in the release's manifest, if it has one.
+// Binaries already staged are returned with the error so the caller can
+// clean them up.
+func stageExtras(ctx context.Context, info *ReleaseInfo, cliExe string, sums map[string]string, token string, o *opts) ([]stagedBinary, error) {
+    if info.ManifestURL == "" {
+        return nil, nil
+    }
+    data, err := downloadBytes(ctx, info.ManifestURL, token, o)
+    if err != nil {
+        return nil, err
+    }
+    expected, ok := sums[binariesManifestName]
+    if !ok {
+        return nil, fmt.Errorf("checksum file missing entry for %s", binariesManifestName)
+    }
+    if err := verifyChecksum(bytes.NewReader(data), expected); err != nil {
+        return nil, fmt.Errorf("%s: %w", binariesManifestName, err)
+    }
+    var m struct {
+        Binaries []Binary `json:"binaries"`
+    }
+    if err := json.Unmarshal(data, &m); err != nil {
+        return nil, fmt.Errorf("parse %s: %w", binariesManifestName, err)
+    }
 
-func TestChecksumMismatch(t *testing.T) {
-    srv := fakeGitLab(t, "v1.1.0", []byte("dummy"), false)
-    defer srv.Close()
-    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
-    require.NoError(t, err)
-    err = updater.ApplyUpdate(context.Background(), info, "", updater.WithBaseURL(srv.URL))
-    require.ErrorIs(t, err, updater.ErrChecksumMismatch)
+    var staged []stagedBinary
+    for _, b := range m.Binaries {
+        names, err := assetNames(b.Asset, info.Version, runtime.GOOS, runtime.GOARCH)
+        if err != nil {
+            return staged, fmt.Errorf("%s: %w", b.Name, err)
+        }
+        var assetName, url string
+        for _, n := range names {
+            if u, ok := info.Assets[n]; ok {
+                assetName, url = n, u
+                break
+            }
+        }
+        if url == "" {
+            return staged, fmt.Errorf("release %s has no %s asset for %s", info.Version, names[0], b.Name)
+        }
+        sb, err := stageBinary(ctx, url, assetName, exeName(b.Name), b.dest(cliExe), sums, token, o)
+        if err != nil {
+            return staged, fmt.Errorf("%s: %w", b.Name, err)
+        }
+        staged = append(staged, sb)
+    }
+    return staged, nil
 }
 
 // ============================================================================
-// File: cmd/update.go (excerpt)
+// File: internal/updater/signature.go
+// ----------------------------------------------------------------------------
+// Detached signature of the checksums file, as written by
+// `cosign sign-blob --key cosign.key --output-signature checksums.sha256.sig`:
+// a base64 ECDSA P-256 (ASN.1) signature over the file's SHA-256. Ed25519
+// keys are accepted too. The public key is baked in at build time:
+//
+//   go build -ldflags "-X your-cli/internal/updater.ReleasePublicKey=$(base64 -w0 cosign.pub)"
 // ============================================================================
-package cmd
+package updater
 
 import (
-    "context"
-    "os"
+    "crypto/ecdsa"
+    "crypto/ed25519"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/pem"
+    "fmt"
+    "strings"
+)
 
-    "github.com/spf13/cobra"
-    "your-cli/internal/updater"
+const (
+    checksumsName    = "checksums.sha256"
+    checksumsSigName = checksumsName + ".sig"
 )
 
-func newUpdateCmd(version, project string) *cobra.Command {
-    return &cobra.Command{
-        Use:   "update",
-        Short: "Download and install the latest version of your-cli",
+// ReleasePublicKey is the PEM public key (optionally base64-encoded, which
+// survives -ldflags -X) that release checksums must be signed with.
+var ReleasePublicKey string
+
+func embeddedPublicKey() []byte {
+    k := strings.TrimSpace(ReleasePublicKey)
+    if k == "" {
+        return nil
+    }
+    if !strings.HasPrefix(k, "-----BEGIN") {
+        if dec, err := base64.StdEncoding.DecodeString(k); err == nil {
+            return dec
+        }
+    }
+    return []byte(k)
+}
+
+// verifySignature checks sig (base64, as cosign writes it) over data with the
+// PEM-encoded public key. A missing key is an error: unsigned updates are
+// never installed.
+func verifySignature(data, sig, pubPEM []byte) error {
+    if len(pubPEM) == 0 {
+        return ErrNoPublicKey
+    }
+    block, _ := pem.Decode(pubPEM)
+    if block == nil {
+        return fmt.Errorf("release public key is not PEM encoded")
+    }
+    pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+    if err != nil {
+        return fmt.Errorf("parse release public key: %w", err)
+    }
+    raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+    if err != nil {
+        return fmt.Errorf("%w: signature is not base64: %v", ErrBadSignature, err)
+    }
+
+    switch k := pub.(type) {
+    case *ecdsa.PublicKey:
+        digest := sha256.Sum256(data)
+        if !ecdsa.VerifyASN1(k, digest[:], raw) {
+            return ErrBadSignature
+        }
+    case ed25519.PublicKey:
+        if !ed25519.Verify(k, data, raw) {
+            return ErrBadSignature
+        }
+    default:
+        return fmt.Errorf("unsupported release public key type %T", pub)
+    }
+    return nil
+}
+
+// ============================================================================
+// File: internal/updater/checksums.go
+// ----------------------------------------------------------------------------
+// The checksums file of a release, in whichever format its pipeline writes:
+//
+//   <sha256 or sha512>  <file>          sha256sum / sha512sum, GoReleaser
+//   SHA256 (<file>) = <hash>            BSD shasum --tag, openssl dgst
+//   {"<file>": "<hash>", ...}           a JSON manifest, or a list like
+//   [{"name": "<file>", "sha256": ...}] GoReleaser's artifacts.json
+//
+// The format is told from the content, the algorithm from the BSD tag or
+// the "sha512:" prefix if there is one and from the hash length otherwise.
+// ============================================================================
+package updater
+
+import (
+    "bufio"
+    "bytes"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "hash"
+    "io"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+// checksumsNames are the checksums assets looked for in a release, in order
+// of preference; any asset ending in _checksums.txt (GoReleaser's default
+// name) comes after them. The signature is the same name plus ".sig".
+var checksumsNames = []string{checksumsName, "checksums.sha512", "checksums.txt", "checksums.json"}
+
+// checksumsAsset is the name of the release's checksums asset, or "".
+func checksumsAsset(links map[string]string) string {
+    for _, n := range checksumsNames {
+        if _, ok := links[n]; ok {
+            return n
+        }
+    }
+    var named []string
+    for n := range links {
+        if strings.HasSuffix(n, "_checksums.txt") {
+            named = append(named, n)
+        }
+    }
+    if len(named) == 0 {
+        return ""
+    }
+    sort.Strings(named)
+    return named[0]
+}
+
+// bsdLine is a line of `shasum --tag` output.
+var bsdLine = regexp.MustCompile(`^(SHA256|SHA512) ?\((.+)\) ?= ?([0-9A-Fa-f]+)$`)
+
+// parseChecksums maps file names to lowercase hex hashes.
+func parseChecksums(data []byte) (map[string]string, error) {
+    trimmed := bytes.TrimSpace(data)
+    if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+        return parseChecksumsJSON(trimmed)
+    }
+
+    m := make(map[string]string)
+    scanner := bufio.NewScanner(bytes.NewReader(data))
+    for n := 1; scanner.Scan(); n++ {
+        line := strings.TrimSpace(scanner.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        var algo, file, sum string
+        if g := bsdLine.FindStringSubmatch(line); g != nil {
+            algo, file, sum = strings.ToLower(g[1]), g[2], g[3]
+        } else if s, f, ok := strings.Cut(line, " "); ok {
+            // "<hash>  <file>", or "<hash> *<file>" for binary mode
+            sum, file = s, strings.TrimPrefix(strings.TrimLeft(f, " "), "*")
+        }
+        if file == "" {
+            return nil, fmt.Errorf("checksums line %d: unrecognised format %q", n, line)
+        }
+        sum, err := normaliseChecksum(algo, sum)
+        if err != nil {
+            return nil, fmt.Errorf("checksums line %d: %s: %w", n, file, err)
+        }
+        m[file] = sum
+    }
+    return m, scanner.Err()
+}
+
+// checksumEntry is an element of a JSON checksums list.
+type checksumEntry struct {
+    Name     string `json:"name"`
+    SHA256   string `json:"sha256"`
+    SHA512   string `json:"sha512"`
+    Checksum string `json:"checksum"` // "<algo>:<hash>" or a bare hash
+    Extra    struct {
+        Checksum string `json:"Checksum"` // GoReleaser's artifacts.json
+    } `json:"extra"`
+}
+
+func parseChecksumsJSON(data []byte) (map[string]string, error) {
+    m := make(map[string]string)
+    if data[0] == '{' {
+        var obj map[string]string
+        if err := json.Unmarshal(data, &obj); err != nil {
+            return nil, fmt.Errorf("parse checksums manifest: %w", err)
+        }
+        for file, s := range obj {
+            sum, err := normaliseChecksum("", s)
+            if err != nil {
+                return nil, fmt.Errorf("checksums manifest: %s: %w", file, err)
+            }
+            m[file] = sum
+        }
+        return m, nil
+    }
+
+    var list []checksumEntry
+    if err := json.Unmarshal(data, &list); err != nil {
+        return nil, fmt.Errorf("parse checksums manifest: %w", err)
+    }
+    for _, e := range list {
+        algo, s := "", e.Checksum
+        switch {
+        case e.SHA512 != "":
+            algo, s = "sha512", e.SHA512
+        case e.SHA256 != "":
+            algo, s = "sha256", e.SHA256
+        case s == "":
+            s = e.Extra.Checksum
+        }
+        if e.Name == "" || s == "" {
+            continue // artifacts.json lists archives and metadata alike
+        }
+        sum, err := normaliseChecksum(algo, s)
+        if err != nil {
+            return nil, fmt.Errorf("checksums manifest: %s: %w", e.Name, err)
+        }
+        m[e.Name] = sum
+    }
+    return m, nil
+}
+
+// normaliseChecksum strips an "<algo>:" prefix from sum, lowercases it and
+// checks it is a hash of algo (any supported one when algo is "").
+func normaliseChecksum(algo, sum string) (string, error) {
+    if a, s, ok := strings.Cut(sum, ":"); ok {
+        if algo != "" && !strings.EqualFold(a, algo) {
+            return "", fmt.Errorf("%s hash labelled %s", a, algo)
+        }
+        algo, sum = strings.ToLower(a), s
+    }
+    sum = strings.ToLower(sum)
+    if _, err := hex.DecodeString(sum); err != nil {
+        return "", fmt.Errorf("hash %q is not hex", sum)
+    }
+    got, err := hashAlgo(sum)
+    if err != nil {
+        return "", err
+    }
+    if algo != "" && algo != got {
+        return "", fmt.Errorf("%s hash is %d hex digits long", algo, len(sum))
+    }
+    return sum, nil
+}
+
+// hashAlgo tells SHA-256 and SHA-512 hashes apart by their length.
+func hashAlgo(sum string) (string, error) {
+    switch len(sum) {
+    case sha256.Size * 2:
+        return "sha256", nil
+    case sha512.Size * 2:
+        return "sha512", nil
+    }
+    return "", fmt.Errorf("hash of %d hex digits is neither SHA-256 nor SHA-512", len(sum))
+}
+
+// verifyChecksum hashes r with the algorithm of expected and compares.
+func verifyChecksum(r io.Reader, expected string) error {
+    algo, err := hashAlgo(expected)
+    if err != nil {
+        return err
+    }
+    var h hash.Hash = sha256.New()
+    if algo == "sha512" {
+        h = sha512.New()
+    }
+    if _, err := io.Copy(h, r); err != nil {
+        return err
+    }
+    if got := hex.EncodeToString(h.Sum(nil)); got != expected {
+        return fmt.Errorf("%w: exp %s got %s", ErrChecksumMismatch, expected, got)
+    }
+    return nil
+}
+
+// ============================================================================
+// File: internal/updater/rollback.go
+// ----------------------------------------------------------------------------
+// Every update keeps the binary it replaced as <name>.old and records both
+// versions in .<name>.versions.json next to the binary. Rollback swaps the
+// two back, so a bad release never leaves users without a working CLI.
+// ============================================================================
+package updater
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+)
+
+// ErrNoRollback is returned when there is no previous binary to restore.
+var ErrNoRollback = errors.New("no previous version to roll back to")
+
+// maxInstalls bounds the history kept in the manifest.
+const maxInstalls = 10
+
+// Install is one entry in the install manifest.
+type Install struct {
+    Version     string    `json:"version"`
+    InstalledAt time.Time `json:"installed_at"`
+    Rollback    bool      `json:"rollback,omitempty"`
+}
+
+// manifest lists installs newest first: Installs[0] is the running binary
+// and Installs[1] the one kept as <name>.old.
+type manifest struct {
+    Installs []Install `json:"installs"`
+}
+
+func manifestPath(exe string) string {
+    return filepath.Join(filepath.Dir(exe), "."+filepath.Base(exe)+".versions.json")
+}
+
+func readManifest(exe string) (*manifest, error) {
+    m := &manifest{}
+    raw, err := os.ReadFile(manifestPath(exe))
+    if errors.Is(err, os.ErrNotExist) {
+        return m, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    if err := json.Unmarshal(raw, m); err != nil {
+        return nil, fmt.Errorf("parse %s: %w", manifestPath(exe), err)
+    }
+    return m, nil
+}
+
+func (m *manifest) push(in Install) {
+    m.Installs = append([]Install{in}, m.Installs...)
+    if len(m.Installs) > maxInstalls {
+        m.Installs = m.Installs[:maxInstalls]
+    }
+}
+
+func (m *manifest) write(exe string) error {
+    raw, err := json.MarshalIndent(m, "", "  ")
+    if err != nil {
+        return err
+    }
+    return os.WriteFile(manifestPath(exe), append(raw, '\n'), 0o644)
+}
+
+// recordInstall notes that version replaced previous. The first update of a
+// binary installed by hand has no manifest yet, so previous is added for it.
+func recordInstall(exe, version, previous string) error {
+    m, err := readManifest(exe)
+    if err != nil {
+        return err
+    }
+    if len(m.Installs) == 0 && previous != "" {
+        m.push(Install{Version: previous})
+    }
+    m.push(Install{Version: version, InstalledAt: time.Now().UTC()})
+    return m.write(exe)
+}
+
+// Installed returns the install history of the running binary, newest first.
+func Installed(optFns ...Option) ([]Install, error) {
+    o := defaultOpts()
+    for _, f := range optFns {
+        f(o)
+    }
+    exe, err := o.exePath()
+    if err != nil {
+        return nil, err
+    }
+    m, err := readManifest(exe)
+    if err != nil {
+        return nil, err
+    }
+    return m.Installs, nil
+}
+
+// Rollback restores <name>.old and returns the version it restored, or ""
+// when the manifest does not know it. The binary being rolled back from
+// becomes the new <name>.old, so a second Rollback undoes the first.
+func Rollback(optFns ...Option) (string, error) {
+    o := defaultOpts()
+    for _, f := range optFns {
+        f(o)
+    }
+    exe, err := o.exePath()
+    if err != nil {
+        return "", err
+    }
+    unlock, err := acquireLock(exe)
+    if err != nil {
+        return "", err
+    }
+    defer unlock()
+
+    backup := exe + ".old"
+    if _, err := os.Stat(backup); err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return "", ErrNoRollback
+        }
+        return "", err
+    }
+
+    // move the backup aside first: replaceBinary writes a fresh <name>.old
+    staged := exe + ".rollback"
+    _ = os.Remove(staged)
+    if err := os.Rename(backup, staged); err != nil {
+        return "", err
+    }
+    if err := replaceBinary(exe, staged); err != nil {
+        _ = os.Rename(staged, backup)
+        return "", fmt.Errorf("restore %s: %w", backup, err)
+    }
+
+    m, err := readManifest(exe)
+    if err != nil {
+        o.logger.Warn("could not read install manifest", "err", err)
+        return "", nil
+    }
+    var restored string
+    if len(m.Installs) > 1 {
+        restored = m.Installs[1].Version
+    }
+    m.push(Install{Version: restored, InstalledAt: time.Now().UTC(), Rollback: true})
+    if err := m.write(exe); err != nil {
+        o.logger.Warn("could not update install manifest", "err", err)
+    }
+    return restored, nil
+}
+
+// ============================================================================
+// File: internal/updater/network.go
+// ----------------------------------------------------------------------------
+// HTTP client settings for corporate networks: an explicit proxy, an extra CA
+// bundle for TLS-intercepting proxies, and skip-verify for lab setups.
+// ============================================================================
+package updater
+
+import (
+    "crypto/tls"
+    "crypto/x509"
+    "fmt"
+    "net/http"
+    "net/url"
+    "os"
+)
+
+// Network configures the client used for both the release check and the
+// download. The zero value keeps the defaults, which already honour
+// HTTPS_PROXY / NO_PROXY.
+type Network struct {
+    Proxy              string // proxy URL; overrides HTTPS_PROXY
+    CABundle           string // PEM file trusted in addition to the system roots
+    InsecureSkipVerify bool   // do not verify TLS certificates at all
+}
+
+// client returns httpClient with the Network settings applied to a copy of
+// its transport.
+func (o *opts) client() (*http.Client, error) {
+    if o.netReady || o.network == (Network{}) {
+        return o.httpClient, nil
+    }
+    base := http.DefaultTransport
+    if o.httpClient.Transport != nil {
+        base = o.httpClient.Transport
+    }
+    t, ok := base.(*http.Transport)
+    if !ok {
+        return nil, fmt.Errorf("network options need an *http.Transport, have %T", base)
+    }
+    t = t.Clone()
+
+    n := o.network
+    if n.Proxy != "" {
+        u, err := url.Parse(n.Proxy)
+        if err != nil {
+            return nil, fmt.Errorf("invalid proxy %q: %w", n.Proxy, err)
+        }
+        t.Proxy = http.ProxyURL(u)
+    }
+    if n.CABundle != "" || n.InsecureSkipVerify {
+        if t.TLSClientConfig == nil {
+            t.TLSClientConfig = &tls.Config{}
+        }
+        if n.CABundle != "" {
+            pem, err := os.ReadFile(n.CABundle)
+            if err != nil {
+                return nil, fmt.Errorf("read CA bundle: %w", err)
+            }
+            pool, err := x509.SystemCertPool()
+            if err != nil {
+                pool = x509.NewCertPool()
+            }
+            if !pool.AppendCertsFromPEM(pem) {
+                return nil, fmt.Errorf("CA bundle %s has no PEM certificates", n.CABundle)
+            }
+            t.TLSClientConfig.RootCAs = pool
+        }
+        if n.InsecureSkipVerify {
+            o.logger.Warn("TLS certificate verification is disabled for updates")
+            t.TLSClientConfig.InsecureSkipVerify = true
+        }
+    }
+
+    c := *o.httpClient
+    c.Transport = t
+    o.httpClient, o.netReady = &c, true
+    return o.httpClient, nil
+}
+
+// ============================================================================
+// File: internal/updater/lock.go
+// ----------------------------------------------------------------------------
+// A lock file next to the binary keeps two shells from updating (or rolling
+// back) the same install at once. O_EXCL works the same on every platform.
+// ============================================================================
+package updater
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// ErrUpdateInProgress is returned when another process holds the update lock.
+var ErrUpdateInProgress = errors.New("another update is in progress")
+
+// lockStaleAfter is when a lock left behind by a crashed update is ignored.
+const lockStaleAfter = 10 * time.Minute
+
+func lockPath(exe string) string {
+    return filepath.Join(filepath.Dir(exe), "."+filepath.Base(exe)+".update.lock")
+}
+
+// acquireLock takes the update lock for exe and returns its release func.
+func acquireLock(exe string) (func(), error) {
+    path := lockPath(exe)
+    for attempt := 0; ; attempt++ {
+        f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+        if err == nil {
+            fmt.Fprintf(f, "%d\n", os.Getpid())
+            f.Close()
+            return func() { os.Remove(path) }, nil
+        }
+        if !errors.Is(err, os.ErrExist) {
+            return nil, fmt.Errorf("create update lock: %w", err)
+        }
+        fi, statErr := os.Stat(path)
+        if attempt > 0 || statErr != nil || time.Since(fi.ModTime()) < lockStaleAfter {
+            holder, _ := os.ReadFile(path)
+            return nil, fmt.Errorf("%w (pid %s, lock %s)", ErrUpdateInProgress, strings.TrimSpace(string(holder)), path)
+        }
+        // stale: the process that wrote it is long gone
+        os.Remove(path)
+    }
+}
+
+// ============================================================================
+// File: internal/updater/swap_windows.go (build tag)
+// +build windows
+// ============================================================================
+
+//go:build windows
+
+package updater
+
+import (
+    "errors"
+    "os"
+)
+
+// replaceBinary moves src over dest and keeps the old dest as dest.old. A
+// running executable cannot be overwritten on Windows, but it can be renamed.
+func replaceBinary(dest, src string) error {
+    if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+        return os.Rename(src, dest) // first install, nothing to keep
+    }
+    destBackup := dest + ".old"
+    // remove any stale .old
+    _ = os.Remove(destBackup)
+    if err := os.Rename(dest, destBackup); err != nil {
+        return err
+    }
+    // os.Rename is MoveFileEx with MOVEFILE_REPLACE_EXISTING
+    return os.Rename(src, dest)
+}
+
+// ============================================================================
+// File: internal/updater/swap_unix.go (build tag)
+// +build !windows
+// ============================================================================
+
+//go:build !windows
+
+package updater
+
+import (
+    "errors"
+    "io"
+    "os"
+)
+
+// replaceBinary moves src over dest and keeps the old dest as dest.old. The
+// backup is a hard link where possible so dest is never missing; the final
+// rename is atomic.
+func replaceBinary(dest, src string) error {
+    if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+        return os.Rename(src, dest) // first install, nothing to keep
+    }
+    destBackup := dest + ".old"
+    _ = os.Remove(destBackup)
+    if err := os.Link(dest, destBackup); err != nil {
+        if err := copyFile(dest, destBackup); err != nil {
+            return err
+        }
+    }
+    return os.Rename(src, dest)
+}
+
+func copyFile(src, dst string) error {
+    in, err := os.Open(src)
+    if err != nil {
+        return err
+    }
+    defer in.Close()
+    fi, err := in.Stat()
+    if err != nil {
+        return err
+    }
+    out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(out, in); err != nil {
+        out.Close()
+        return err
+    }
+    return out.Close()
+}
+
+// ============================================================================
+// File: internal/updater/binaries_test.go
+// ----------------------------------------------------------------------------
+// A release with an auxiliary binary listed in binaries.json.
+// ============================================================================
+package updater
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/pem"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestApplyUpdateInstallsAuxiliaryBinaries(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("fixtures are tar.gz archives without .exe entries")
+    }
+    cliAsset := fmt.Sprintf("your-cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+    agentAsset := fmt.Sprintf("your-cli-agent_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+    files := map[string][]byte{
+        cliAsset:             mustRead(t, writeTarGzFixture(t, []fixtureEntry{{"your-cli_1.1.0/your-cli", "new-cli", 0o755}})),
+        agentAsset:           mustRead(t, writeTarGzFixture(t, []fixtureEntry{{"your-cli_1.1.0/your-cli-agent", "new-agent", 0o755}})),
+        binariesManifestName: []byte(`{"binaries":[{"name":"your-cli-agent","asset":"your-cli-agent_{{.OS}}_{{.Arch}}{{.Ext}}","dest":"libexec/your-cli-agent"}]}`),
+    }
+    var sums strings.Builder
+    for name, body := range files {
+        sum := sha256.Sum256(body)
+        fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+    }
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    require.NoError(t, err)
+    digest := sha256.Sum256([]byte(sums.String()))
+    sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+    require.NoError(t, err)
+    der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+    require.NoError(t, err)
+    pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+    files[checksumsName] = []byte(sums.String())
+    files[checksumsSigName] = []byte(base64.StdEncoding.EncodeToString(sig))
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Write(files[strings.TrimPrefix(r.URL.Path, "/")])
+    }))
+    defer srv.Close()
+    assets := make(map[string]string)
+    for name := range files {
+        assets[name] = srv.URL + "/" + name
+    }
+    info := &ReleaseInfo{
+        Version:        "v1.1.0",
+        CurrentVersion: "v1.0.0",
+        BinaryURL:      assets[cliAsset],
+        AssetName:      cliAsset,
+        ChecksumURL:    assets[checksumsName],
+        SignatureURL:   assets[checksumsSigName],
+        ManifestURL:    assets[binariesManifestName],
+        Assets:         assets,
+    }
+
+    dir := t.TempDir()
+    exe := filepath.Join(dir, "your-cli")
+    require.NoError(t, os.WriteFile(exe, []byte("old-cli"), 0o755))
+    err = ApplyUpdate(context.Background(), info, "", WithPublicKey(pub), WithVerifyArgs(), func(o *opts) { o.executable = exe })
+    require.NoError(t, err)
+
+    require.Equal(t, "new-cli", string(mustRead(t, exe)))
+    require.Equal(t, "old-cli", string(mustRead(t, exe+".old")))
+    require.Equal(t, "new-agent", string(mustRead(t, filepath.Join(dir, "libexec", "your-cli-agent"))))
+}
+
+func mustRead(t *testing.T, path string) []byte {
+    t.Helper()
+    b, err := os.ReadFile(path)
+    require.NoError(t, err)
+    return b
+}
+
+// ============================================================================
+// File: internal/updater/checksums_test.go
+// ----------------------------------------------------------------------------
+// The checksums file formats, and picking the checksums asset of a release.
+// ============================================================================
+package updater
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "crypto/sha512"
+    "encoding/hex"
+    "errors"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestParseChecksums(t *testing.T) {
+    s256 := sha256.Sum256([]byte("cli"))
+    s512 := sha512.Sum512([]byte("cli"))
+    h256, h512 := hex.EncodeToString(s256[:]), hex.EncodeToString(s512[:])
+
+    cases := []struct {
+        name, data string
+        want       map[string]string
+        err        string
+    }{
+        {
+            name: "gnu",
+            data: h256 + "  your-cli_linux_amd64.tar.gz\n" + h512 + " *binaries.json\n\n",
+            want: map[string]string{"your-cli_linux_amd64.tar.gz": h256, "binaries.json": h512},
+        },
+        {
+            name: "bsd",
+            data: "SHA256 (your-cli_linux_amd64.tar.gz) = " + strings.ToUpper(h256) + "\nSHA512 (binaries.json) = " + h512 + "\n",
+            want: map[string]string{"your-cli_linux_amd64.tar.gz": h256, "binaries.json": h512},
+        },
+        {
+            name: "json object",
+            data: `{"your-cli_linux_amd64.tar.gz": "sha512:` + h512 + `"}`,
+            want: map[string]string{"your-cli_linux_amd64.tar.gz": h512},
+        },
+        {
+            name: "json list",
+            data: `[{"name": "a.tar.gz", "sha256": "` + h256 + `"},
+                    {"name": "b.tar.gz", "extra": {"Checksum": "sha256:` + h256 + `"}},
+                    {"name": "metadata.json", "extra": {}}]`,
+            want: map[string]string{"a.tar.gz": h256, "b.tar.gz": h256},
+        },
+        {name: "bsd length mismatch", data: "SHA512 (a.tar.gz) = " + h256 + "\n", err: "sha512 hash is 64 hex digits long"},
+        {name: "unknown hash", data: strings.Repeat("a", 40) + "  a.tar.gz\n", err: "neither SHA-256 nor SHA-512"},
+        {name: "garbage", data: h256 + "\n", err: "checksums line 1: unrecognised format"},
+    }
+    for _, c := range cases {
+        t.Run(c.name, func(t *testing.T) {
+            got, err := parseChecksums([]byte(c.data))
+            if c.err != "" {
+                require.ErrorContains(t, err, c.err)
+                return
+            }
+            require.NoError(t, err)
+            require.Equal(t, c.want, got)
+        })
+    }
+}
+
+func TestVerifyChecksumUsesTheHashOfTheEntry(t *testing.T) {
+    s512 := sha512.Sum512([]byte("cli"))
+    require.NoError(t, verifyChecksum(bytes.NewReader([]byte("cli")), hex.EncodeToString(s512[:])))
+    err := verifyChecksum(bytes.NewReader([]byte("other")), hex.EncodeToString(s512[:]))
+    require.True(t, errors.Is(err, ErrChecksumMismatch), err)
+}
+
+func TestChecksumsAsset(t *testing.T) {
+    links := func(names ...string) map[string]string {
+        m := make(map[string]string)
+        for _, n := range names {
+            m[n] = "https://example.com/" + n
+        }
+        return m
+    }
+    require.Equal(t, "checksums.sha256", checksumsAsset(links("checksums.sha512", "checksums.sha256")))
+    require.Equal(t, "checksums.json", checksumsAsset(links("checksums.json", "your-cli_1.2.0_checksums.txt")))
+    require.Equal(t, "your-cli_1.2.0_checksums.txt", checksumsAsset(links("your-cli_1.2.0_checksums.txt")))
+    require.Equal(t, "", checksumsAsset(links("your-cli_linux_amd64.tar.gz")))
+}
+
+// ============================================================================
+// File: internal/updater/extract_test.go
+// ----------------------------------------------------------------------------
+// Archive extraction against small fixture archives built in the test, and
+// asset name templates.
+// ============================================================================
+package updater
+
+import (
+    "archive/tar"
+    "archive/zip"
+    "bytes"
+    "compress/gzip"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+)
+
+type fixtureEntry struct {
+    name string
+    body string
+    mode int64
+}
+
+// fixtureEntries mimics a goreleaser archive: docs next to the binary in a
+// versioned directory.
+var fixtureEntries = []fixtureEntry{
+    {"your-cli_1.2.0/README.md", "readme", 0o644},
+    {"your-cli_1.2.0/LICENSE", "license", 0o644},
+    {"your-cli_1.2.0/your-cli", "#!binary", 0o755},
+}
+
+func writeTarGzFixture(t *testing.T, entries []fixtureEntry) string {
+    t.Helper()
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+    tw := tar.NewWriter(gz)
+    require.NoError(t, tw.WriteHeader(&tar.Header{Name: "your-cli_1.2.0/", Typeflag: tar.TypeDir, Mode: 0o755}))
+    for _, e := range entries {
+        require.NoError(t, tw.WriteHeader(&tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(e.body)), Typeflag: tar.TypeReg}))
+        _, err := tw.Write([]byte(e.body))
+        require.NoError(t, err)
+    }
+    require.NoError(t, tw.Close())
+    require.NoError(t, gz.Close())
+    p := filepath.Join(t.TempDir(), "fixture.tar.gz")
+    require.NoError(t, os.WriteFile(p, buf.Bytes(), 0o644))
+    return p
+}
+
+func writeZipFixture(t *testing.T, entries []fixtureEntry) string {
+    t.Helper()
+    var buf bytes.Buffer
+    zw := zip.NewWriter(&buf)
+    for _, e := range entries {
+        w, err := zw.Create(e.name) // no mode bits, like zips made on Windows
+        require.NoError(t, err)
+        _, err = w.Write([]byte(e.body))
+        require.NoError(t, err)
+    }
+    require.NoError(t, zw.Close())
+    p := filepath.Join(t.TempDir(), "fixture.zip")
+    require.NoError(t, os.WriteFile(p, buf.Bytes(), 0o644))
+    return p
+}
+
+func TestExtractTarGzSelectsBinary(t *testing.T) {
+    archive := writeTarGzFixture(t, fixtureEntries)
+    out, err := extractBinary(archive, "your-cli_linux_amd64.tar.gz", "your-cli", t.TempDir())
+    require.NoError(t, err)
+
+    body, err := os.ReadFile(out)
+    require.NoError(t, err)
+    require.Equal(t, "#!binary", string(body))
+    if runtime.GOOS != "windows" {
+        fi, err := os.Stat(out)
+        require.NoError(t, err)
+        require.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+    }
+}
+
+func TestExtractZipSelectsBinary(t *testing.T) {
+    archive := writeZipFixture(t, []fixtureEntry{
+        {"README.md", "readme", 0},
+        {"your-cli.exe", "MZbinary", 0},
+    })
+    out, err := extractBinary(archive, "your-cli_windows_amd64.zip", "your-cli.exe", t.TempDir())
+    require.NoError(t, err)
+
+    body, err := os.ReadFile(out)
+    require.NoError(t, err)
+    require.Equal(t, "MZbinary", string(body))
+}
+
+func TestExtractMissingBinary(t *testing.T) {
+    archive := writeTarGzFixture(t, fixtureEntries[:2])
+    _, err := extractBinary(archive, "your-cli_linux_amd64.tar.gz", "your-cli", t.TempDir())
+    require.ErrorIs(t, err, ErrBinaryNotFound)
+}
+
+func TestAssetNames(t *testing.T) {
+    names, err := assetNames(DefaultAssetTemplate, "v1.4.0", "windows", "amd64")
+    require.NoError(t, err)
+    require.Equal(t, []string{"your-cli_windows_amd64.zip"}, names)
+
+    names, err = assetNames("cli-{{.Version}}-{{.OS}}-{{.Arch}}{{.Ext}}", "v1.4.0", "darwin", "arm64")
+    require.NoError(t, err)
+    require.Equal(t, []string{"cli-1.4.0-darwin-arm64.tar.gz", "cli-1.4.0-darwin-amd64.tar.gz"}, names)
+
+    // a universal binary needs no fallback
+    names, err = assetNames("cli_{{.OS}}_all{{.Ext}}", "v1.4.0", "darwin", "arm64")
+    require.NoError(t, err)
+    require.Equal(t, []string{"cli_darwin_all.tar.gz"}, names)
+
+    _, err = assetNames("{{.Platform}}", "v1.4.0", "linux", "amd64")
+    require.Error(t, err)
+}
+
+// ============================================================================
+// File: internal/updater/rollback_test.go
+// ----------------------------------------------------------------------------
+// Backup, manifest, rollback, locking and post-install verification against
+// a fake executable in a temp dir.
+// ============================================================================
+package updater
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "runtime"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/require"
+)
+
+func TestRollbackRestoresPreviousBinary(t *testing.T) {
+    dir := t.TempDir()
+    exe := filepath.Join(dir, "your-cli")
+    require.NoError(t, os.WriteFile(exe, []byte("v1.0.0"), 0o755))
+    withExe := func(o *opts) { o.executable = exe }
+
+    _, err := Rollback(withExe)
+    require.ErrorIs(t, err, ErrNoRollback)
+
+    next := filepath.Join(dir, "next")
+    require.NoError(t, os.WriteFile(next, []byte("v1.1.0"), 0o755))
+    require.NoError(t, replaceBinary(exe, next))
+    require.NoError(t, recordInstall(exe, "v1.1.0", "v1.0.0"))
+
+    got, err := os.ReadFile(exe + ".old")
+    require.NoError(t, err)
+    require.Equal(t, "v1.0.0", string(got))
+
+    restored, err := Rollback(withExe)
+    require.NoError(t, err)
+    require.Equal(t, "v1.0.0", restored)
+    got, err = os.ReadFile(exe)
+    require.NoError(t, err)
+    require.Equal(t, "v1.0.0", string(got))
+
+    installs, err := Installed(withExe)
+    require.NoError(t, err)
+    require.Equal(t, "v1.0.0", installs[0].Version)
+    require.True(t, installs[0].Rollback)
+    require.Equal(t, "v1.1.0", installs[1].Version)
+
+    // rolling back again returns to the update
+    restored, err = Rollback(withExe)
+    require.NoError(t, err)
+    require.Equal(t, "v1.1.0", restored)
+    got, err = os.ReadFile(exe)
+    require.NoError(t, err)
+    require.Equal(t, "v1.1.0", string(got))
+}
+
+func TestUpdateLock(t *testing.T) {
+    exe := filepath.Join(t.TempDir(), "your-cli")
+
+    unlock, err := acquireLock(exe)
+    require.NoError(t, err)
+    _, err = acquireLock(exe)
+    require.ErrorIs(t, err, ErrUpdateInProgress)
+    _, err = Rollback(func(o *opts) { o.executable = exe })
+    require.ErrorIs(t, err, ErrUpdateInProgress)
+    unlock()
+
+    // a lock left by a crashed update expires
+    require.NoError(t, os.WriteFile(lockPath(exe), []byte("1\n"), 0o644))
+    old := time.Now().Add(-2 * lockStaleAfter)
+    require.NoError(t, os.Chtimes(lockPath(exe), old, old))
+    unlock, err = acquireLock(exe)
+    require.NoError(t, err)
+    unlock()
+}
+
+func TestVerifyInstall(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("uses a shell script as the binary")
+    }
+    exe := filepath.Join(t.TempDir(), "your-cli")
+    require.NoError(t, os.WriteFile(exe, []byte("#!/bin/sh\necho your-cli version v1.1.0\n"), 0o755))
+
+    require.NoError(t, verifyInstall(context.Background(), exe, "v1.1.0", []string{"--version"}))
+    require.ErrorIs(t, verifyInstall(context.Background(), exe, "v1.2.0", []string{"--version"}), ErrVerifyFailed)
+    require.NoError(t, verifyInstall(context.Background(), exe, "v1.2.0", nil))
+}
+
+// ============================================================================
+// File: internal/updater/updater_test.go
+// ----------------------------------------------------------------------------
+// Basic coverage: no-update, minor, major, pin/skip, network options,
+// checksum mismatch, signatures.
+// ============================================================================
+package updater_test
+
+import (
+    "context"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/x509"
+    "encoding/base64"
+    "encoding/hex"
+    "encoding/pem"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/require"
+    "your-cli/internal/updater"
+)
+
+// releaseKey signs the checksums every fake release serves; releaseKeyPEM is
+// what a build would embed.
+var releaseKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+func publicKeyPEM(t *testing.T, k *ecdsa.PrivateKey) []byte {
+    t.Helper()
+    der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+    require.NoError(t, err)
+    return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// fakeGitLab spins a minimal GitLab Releases API with one release.
+func fakeGitLab(t *testing.T, tag string, assetBody []byte, goodChecksum bool) *httptest.Server {
+    t.Helper()
+    assetName := fmt.Sprintf("your-cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+    if runtime.GOOS == "windows" {
+        assetName = fmt.Sprintf("your-cli_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+    }
+    sum := sha256.Sum256(assetBody)
+    checksum := hex.EncodeToString(sum[:])
+    if !goodChecksum {
+        checksum = strings.Repeat("0", 64)
+    }
+    cksContent := fmt.Sprintf("%s  %s\n", checksum, assetName)
+    digest := sha256.Sum256([]byte(cksContent))
+    rawSig, err := ecdsa.SignASN1(rand.Reader, releaseKey, digest[:])
+    require.NoError(t, err)
+    sigContent := base64.StdEncoding.EncodeToString(rawSig)
+
+    var srv *httptest.Server
+    srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch {
+        case strings.HasSuffix(r.URL.Path, "/releases"):
+            fmt.Fprintf(w, `[{"tag_name":"%s","description":"Fixes things.","assets":{"links":[{"name":"%s","url":"%s/assets/bin"},{"name":"checksums.sha256","url":"%s/assets/cks"},{"name":"checksums.sha256.sig","url":"%s/assets/sig"}]}}]`, tag, assetName, srv.URL, srv.URL, srv.URL)
+        case strings.HasSuffix(r.URL.Path, "/assets/bin"):
+            w.Write(assetBody)
+        case strings.HasSuffix(r.URL.Path, "/assets/cks"):
+            w.Write([]byte(cksContent))
+        case strings.HasSuffix(r.URL.Path, "/assets/sig"):
+            w.Write([]byte(sigContent))
+        default:
+            http.NotFound(w, r)
+        }
+    }))
+    return srv
+}
+
+func TestNoUpdate(t *testing.T) {
+    srv := fakeGitLab(t, "v1.0.0", []byte("dummy"), true)
+    defer srv.Close()
+
+    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
+    require.ErrorIs(t, err, updater.ErrNoUpdate)
+    require.Nil(t, info)
+}
+
+func TestMinorAndMajor(t *testing.T) {
+    srv := fakeGitLab(t, "v1.1.0", []byte("dummy"), true)
+    defer srv.Close()
+    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
+    require.NoError(t, err)
+    require.Equal(t, updater.ErrMinorChange, info.ChangeType)
+    require.Equal(t, "Fixes things.", info.Notes)
+
+    srv2 := fakeGitLab(t, "v2.0.0", []byte("dummy"), true)
+    defer srv2.Close()
+    info2, err := updater.CheckForUpdates(context.Background(), "v1.1.0", "dummy", "", updater.WithBaseURL(srv2.URL))
+    require.NoError(t, err)
+    require.Equal(t, updater.ErrMajorChange, info2.ChangeType)
+}
+
+func TestPinAndSkip(t *testing.T) {
+    assetName := fmt.Sprintf("your-cli_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+    if runtime.GOOS == "windows" {
+        assetName = fmt.Sprintf("your-cli_%s_%s.zip", runtime.GOOS, runtime.GOARCH)
+    }
+    var srv *httptest.Server
+    srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var rels []string
+        for _, tag := range []string{"v2.3.0", "v2.2.0", "v1.5.0"} {
+            rels = append(rels, fmt.Sprintf(`{"tag_name":"%s","assets":{"links":[{"name":"%s","url":"%s/bin"},{"name":"checksums.sha256","url":"%s/cks"}]}}`, tag, assetName, srv.URL, srv.URL))
+        }
+        fmt.Fprintf(w, "[%s]", strings.Join(rels, ","))
+    }))
+    defer srv.Close()
+
+    check := func(pin string, skip ...string) (string, error) {
+        info, err := updater.CheckForUpdates(context.Background(), "v1.4.0", "dummy", "",
+            updater.WithBaseURL(srv.URL), updater.WithPin(pin), updater.WithSkip(skip...))
+        if err != nil {
+            return "", err
+        }
+        return info.Version, nil
+    }
+
+    v, err := check("")
+    require.NoError(t, err)
+    require.Equal(t, "v2.3.0", v)
+
+    // declining v2.3.0 still offers the next best release
+    v, err = check("", "2.3.0")
+    require.NoError(t, err)
+    require.Equal(t, "v2.2.0", v)
+
+    // declining an older release does not hide a newer one
+    v, err = check("", "v2.2.0")
+    require.NoError(t, err)
+    require.Equal(t, "v2.3.0", v)
+
+    v, err = check("v1")
+    require.NoError(t, err)
+    require.Equal(t, "v1.5.0", v)
+
+    _, err = check("v1.4")
+    require.ErrorIs(t, err, updater.ErrNoUpdate)
+}
+
+func TestNetworkOptions(t *testing.T) {
+    tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, "[]")
+    }))
+    defer tlsSrv.Close()
+    check := func(n updater.Network) error {
+        _, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "",
+            updater.WithBaseURL(tlsSrv.URL), updater.WithNetwork(n))
+        return err
+    }
+
+    // an intercepting proxy's certificate is not trusted by default
+    err := check(updater.Network{})
+    require.Error(t, err)
+    require.False(t, errors.Is(err, updater.ErrNoUpdate))
+
+    bundle := filepath.Join(t.TempDir(), "ca.pem")
+    require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw}), 0o600))
+    require.ErrorIs(t, check(updater.Network{CABundle: bundle}), updater.ErrNoUpdate)
+    require.ErrorIs(t, check(updater.Network{InsecureSkipVerify: true}), updater.ErrNoUpdate)
+
+    // requests go through an explicit proxy
+    var proxied bool
+    proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        proxied = r.URL.Host == "gitlab.invalid"
+        fmt.Fprint(w, "[]")
+    }))
+    defer proxy.Close()
+    _, err = updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "",
+        updater.WithBaseURL("http://gitlab.invalid"), updater.WithNetwork(updater.Network{Proxy: proxy.URL}))
+    require.ErrorIs(t, err, updater.ErrNoUpdate)
+    require.True(t, proxied)
+}
+
+func TestChecksumMismatch(t *testing.T) {
+    srv := fakeGitLab(t, "v1.1.0", []byte("dummy"), false)
+    defer srv.Close()
+    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
+    require.NoError(t, err)
+    err = updater.ApplyUpdate(context.Background(), info, "", updater.WithBaseURL(srv.URL), updater.WithPublicKey(publicKeyPEM(t, releaseKey)))
+    require.ErrorIs(t, err, updater.ErrChecksumMismatch)
+}
+
+func TestSignatureRequired(t *testing.T) {
+    srv := fakeGitLab(t, "v1.1.0", []byte("dummy"), true)
+    defer srv.Close()
+    info, err := updater.CheckForUpdates(context.Background(), "v1.0.0", "dummy", "", updater.WithBaseURL(srv.URL))
+    require.NoError(t, err)
+
+    // signed by someone else
+    otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    require.NoError(t, err)
+    err = updater.ApplyUpdate(context.Background(), info, "", updater.WithPublicKey(publicKeyPEM(t, otherKey)))
+    require.ErrorIs(t, err, updater.ErrBadSignature)
+
+    // no key built in
+    err = updater.ApplyUpdate(context.Background(), info, "", updater.WithPublicKey(nil))
+    require.ErrorIs(t, err, updater.ErrNoPublicKey)
+
+    // release published without a signature
+    unsigned := *info
+    unsigned.SignatureURL = ""
+    err = updater.ApplyUpdate(context.Background(), &unsigned, "", updater.WithPublicKey(publicKeyPEM(t, releaseKey)))
+    require.ErrorIs(t, err, updater.ErrUnsigned)
+}
+
+// ============================================================================
+// File: cmd/update.go (excerpt)
+// ============================================================================
+package cmd
+
+import (
+    "bufio"
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "os/exec"
+    "strings"
+
+    "github.com/spf13/cobra"
+    "your-cli/internal/config"
+    "your-cli/internal/updater"
+)
+
+func newUpdateCmd(version, project string) *cobra.Command {
+    var (
+        rollback  bool
+        skip      string
+        notesOnly bool
+        yes       bool
+    )
+    cmd := &cobra.Command{
+        Use:   "update",
+        Short: "Download and install the latest version of your-cli",
         RunE: func(cmd *cobra.Command, _ []string) error {
+            if rollback {
+                restored, err := updater.Rollback()
+                if err != nil {
+                    return err
+                }
+                if restored == "" {
+                    restored = "the previous version"
+                }
+                fmt.Fprintf(cmd.OutOrStdout(), "Rolled back to %s.\n", restored)
+                return nil
+            }
+            if skip != "" {
+                if err := config.SkipVersion(skip); err != nil {
+                    return err
+                }
+                fmt.Fprintf(cmd.OutOrStdout(), "No longer offering %s; newer releases will still be announced.\n", skip)
+                return nil
+            }
             ctx := context.Background()
             token := os.Getenv("GITLAB_TOKEN")
-            info, err := updater.CheckForUpdates(ctx, version, project, token)
+            // the pin holds for explicit updates too; skipped versions only silence the notice
+            prefs, _ := config.Update()
+            network := networkOption()
+            info, err := updater.CheckForUpdates(ctx, version, project, token,
+                updater.WithPin(prefs.Pin), updater.WithAssetTemplate(prefs.AssetTemplate), network)
             if err != nil {
                 return err
             }
-            return updater.ApplyUpdate(ctx, info, token)
+
+            // show what is about to be installed; only ask when someone can answer
+            interactive := isCharDevice(os.Stdin) && isCharDevice(os.Stdout)
+            if notesOnly || (interactive && !yes) {
+                showNotes(cmd.OutOrStdout(), info, interactive)
+            }
+            if notesOnly {
+                return nil
+            }
+            if interactive && !yes && !confirm(cmd.OutOrStdout(), fmt.Sprintf("Install %s?", info.Version)) {
+                return nil
+            }
+            return updater.ApplyUpdate(ctx, info, token, network)
         },
     }
+    cmd.Flags().BoolVar(&rollback, "rollback", false, "restore the version replaced by the last update")
+    cmd.Flags().StringVar(&skip, "skip", "", "stop announcing this version (e.g. v2.3.0); newer releases are still announced")
+    cmd.Flags().BoolVar(&notesOnly, "notes-only", false, "print the release notes of the available update and exit")
+    cmd.Flags().BoolVarP(&yes, "yes", "y", false, "install without showing the notes or asking")
+    return cmd
+}
+
+// showNotes prints the release notes, through $PAGER (or less) when paged.
+func showNotes(w io.Writer, info *updater.ReleaseInfo, paged bool) {
+    notes := fmt.Sprintf("your-cli %s\n\n%s\n", info.Version, strings.TrimSpace(info.Notes))
+    if strings.TrimSpace(info.Notes) == "" {
+        notes = fmt.Sprintf("your-cli %s has no release notes.\n", info.Version)
+    }
+    if paged {
+        pager := strings.Fields(os.Getenv("PAGER"))
+        if len(pager) == 0 {
+            if _, err := exec.LookPath("less"); err == nil {
+                pager = []string{"less", "-FRX"} // quit if it fits on one screen
+            }
+        }
+        if len(pager) > 0 {
+            p := exec.Command(pager[0], pager[1:]...)
+            p.Stdin, p.Stdout, p.Stderr = strings.NewReader(notes), w, os.Stderr
+            if p.Run() == nil {
+                return
+            }
+        }
+    }
+    fmt.Fprint(w, notes)
+}
+
+// confirm asks a y/N question on stdin.
+func confirm(w io.Writer, question string) bool {
+    fmt.Fprintf(w, "%s [y/N] ", question)
+    answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+    switch strings.ToLower(strings.TrimSpace(answer)) {
+    case "y", "yes":
+        return true
+    }
+    return false
+}
+
+func isCharDevice(f *os.File) bool {
+    fi, err := f.Stat()
+    return err == nil && (fi.Mode()&os.ModeCharDevice) != 0
+}
+
+// networkOption applies the proxy / CA settings from the config file.
+func networkOption() updater.Option {
+    n, _ := config.HTTP()
+    return updater.WithNetwork(updater.Network{Proxy: n.Proxy, CABundle: n.CABundle, InsecureSkipVerify: n.InsecureSkipVerify})
 }
 
 // ============================================================================
@@ -466,6 +2148,7 @@ import (
     "time"
 
     "github.com/spf13/cobra"
+    "your-cli/internal/config"
     "your-cli/internal/updater"
 )
 
@@ -490,9 +2173,14 @@ func attachUpdateCheck(root *cobra.Command, version, project string) {
             return nil
         }
         updateChecked = true
+        prefs, _ := config.Update()
+        if prefs.CheckDisabled() { // $YOURCLI_NO_UPDATE_CHECK=1 or update.check: false
+            return nil
+        }
         ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
         defer cancel()
-        info, err := updater.CheckForUpdates(ctx, version, project, os.Getenv("GITLAB_TOKEN"))
+        info, err := updater.CheckForUpdates(ctx, version, project, os.Getenv("GITLAB_TOKEN"),
+            updater.WithPin(prefs.Pin), updater.WithSkip(prefs.Skip...), updater.WithAssetTemplate(prefs.AssetTemplate), networkOption())
         switch {
         case errors.Is(err, updater.ErrNoUpdate):
             return nil
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"yourcorp/topology"
+)
+
+// workspaceFile marks the monorepo root and holds its workspace settings.
+const workspaceFile = ".loki.yaml"
+
+// workspace is the monorepo the CLI runs in: the nearest directory at or
+// above the current one with a .loki.yaml or a .git. Commands default to its
+// topology and inventories, so they work from any subdirectory.
+type workspace struct {
+	Root   string `yaml:"-" json:"root"`
+	Config string `yaml:"-" json:"config,omitempty"` // the .loki.yaml, if there is one
+
+	// The settings .loki.yaml may change, relative to Root.
+	Topology    string `yaml:"topology" json:"topology"`
+	Inventories string `yaml:"inventories" json:"inventories"`         // * is the app
+	Project     string `yaml:"project" json:"project,omitempty"`       // GitLab path of the monorepo
+	MaxShards   int    `yaml:"max_shards" json:"max_shards,omitempty"` // 0 is the topology package's default
+	MaxDepth    int    `yaml:"max_depth" json:"max_depth,omitempty"`   // topo lint's --max-depth
+}
+
+// currentWorkspace is the workspace of the current directory, found once.
+// Outside any, it is the current directory with the default settings.
+var currentWorkspace = sync.OnceValues(func() (*workspace, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return findWorkspace(wd)
+})
+
+// findWorkspace walks up from dir to the workspace root and reads its
+// .loki.yaml.
+func findWorkspace(dir string) (*workspace, error) {
+	ws := &workspace{Root: dir, Topology: defaultTopology, Inventories: defaultInventories}
+	for d := dir; ; {
+		config := filepath.Join(d, workspaceFile)
+		if _, err := os.Stat(config); err == nil {
+			ws.Root, ws.Config = d, config
+			return ws, ws.load()
+		}
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			ws.Root = d
+			return ws, nil
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return ws, nil
+		}
+		d = parent
+	}
+}
+
+// load reads ws.Config over the defaults.
+func (ws *workspace) load() error {
+	f, err := os.Open(ws.Config)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(ws); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", ws.Config, err)
+	}
+	for key, path := range map[string]string{"topology": ws.Topology, "inventories": ws.Inventories} {
+		if filepath.IsAbs(path) {
+			return fmt.Errorf("%s: %s %q must be relative to the repo root", ws.Config, key, path)
+		}
+	}
+	if !strings.Contains(ws.Inventories, "*") {
+		return fmt.Errorf("%s: inventories %q needs a * where the app name goes", ws.Config, ws.Inventories)
+	}
+	for key, n := range map[string]int{"max_shards": ws.MaxShards, "max_depth": ws.MaxDepth} {
+		if n < 0 {
+			return fmt.Errorf("%s: %s must be 0 (the default) or more, not %d", ws.Config, key, n)
+		}
+	}
+	return nil
+}
+
+// topology is the path of the workspace's topology.
+func (ws *workspace) topology() string {
+	return filepath.Join(ws.Root, ws.Topology)
+}
+
+// inventories is the glob matching every app's inventory.
+func (ws *workspace) inventories() string {
+	return filepath.Join(ws.Root, ws.Inventories)
+}
+
+// inventory is the path of app's inventory.
+func (ws *workspace) inventory(app string) string {
+	return filepath.Join(ws.Root, strings.Replace(ws.Inventories, "*", app, 1))
+}
+
+// maxShards is the most shards an app of the topology may have.
+func (ws *workspace) maxShards() int {
+	if ws.MaxShards > 0 {
+		return ws.MaxShards
+	}
+	return topology.DefaultMaxShards
+}
+
+// workspaceOrDefault is the current workspace, or the defaults if its
+// .loki.yaml is broken: completions have nowhere to report that, doctor
+// reports it as a check, and the root command already has for everything
+// else.
+func workspaceOrDefault() *workspace {
+	if ws, err := currentWorkspace(); err == nil {
+		return ws
+	}
+	wd, _ := os.Getwd()
+	return &workspace{Root: wd, Topology: defaultTopology, Inventories: defaultInventories}
+}
+
+func newConfigWorkspaceCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "workspace",
+		Short: "Show the workspace the current directory is in",
+		Long: `Show the monorepo root the commands work in, and where they look for the
+topology and the inventories. The root is the nearest directory at or above
+the current one with a ` + workspaceFile + ` or a .git; ` + workspaceFile + ` may set, relative to
+it:
+
+  topology: topology.yaml                # topo's --file
+  inventories: ansible/*/inventory.yml   # * is inv's --app
+  project: platform/asgard               # the monorepo's GitLab path
+  max_shards: 100                        # the most shards an app may have
+  max_depth: 6                           # topo lint's --max-depth`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ws, err := currentWorkspace()
+			if err != nil {
+				return err
+			}
+			return writeResult(cmd.OutOrStdout(), ws, func(out io.Writer) error {
+				fmt.Fprintf(out, "root         %s\n", ws.Root)
+				fmt.Fprintf(out, "config       %s\n", orDash(ws.Config))
+				fmt.Fprintf(out, "topology     %s\n", ws.topology())
+				fmt.Fprintf(out, "inventories  %s\n", ws.inventories())
+				fmt.Fprintf(out, "project      %s\n", orDash(ws.Project))
+				fmt.Fprintf(out, "max shards   %d\n", ws.maxShards())
+				if ws.MaxDepth > 0 {
+					fmt.Fprintf(out, "max depth    %d\n", ws.MaxDepth)
+				} else {
+					fmt.Fprintf(out, "max depth    -\n")
+				}
+				return nil
+			})
+		},
+	}
+}
@@ -1,229 +1,635 @@
-package main
+// -----------------------------------------------------------------------------
+// releigh/releigh.go
+// -----------------------------------------------------------------------------
+// Package releigh tags and publishes per-app releases from the monorepo: it
+// finds an app's previous tag, renders a changelog from the conventional
+// commits that touched the app or its dependencies, pushes the new tag and
+// creates the release on GitLab or GitHub. Git, the release provider and Jira
+// are reached through small interfaces so the release CLI and loki can share
+// the logic and tests can run against fakes instead of a real repository.
+package releigh
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"net/http"
 	"os"
-	"os/exec"
 	"regexp"
 	"strings"
+	"text/tabwriter"
+	"text/template"
 	"time"
+
+	"github.com/yourorg/tool/depgraph"
 )
 
 //
-// ----------------- LOGGER SETUP -----------------
+// ----------------- MODELS & CONFIG -----------------
 //
 
-const (
-	reset = "\033[0m"
-	red   = "\033[31m"
-	yel   = "\033[33m"
-	gre   = "\033[32m"
-	blu   = "\033[34m"
-)
+// Config describes one release run. Everything environment-specific (CI
+// variables, credentials) is resolved by the caller.
+type Config struct {
+	Apps           []string           // apps to release; must be empty with AllAffected
+	AllAffected    bool               // release every app affected by the changes since Since
+	Since          string             // base ref for AllAffected; empty means the last commit
+	Version        string             // version for every app; may be empty with AutoBump or SuggestVersion
+	Graph          map[string]Project // the exported Gradle dependency graph
+	DryRun         bool               // compute and print everything, but never tag, push, or call the provider
+	AutoBump       bool               // derive the version from the commits since the previous tag
+	SuggestVersion bool               // only compute the next version of each app
+	Prerelease     string             // with AutoBump or SuggestVersion, cut "<next>-<Prerelease>.N" candidates (e.g. "rc")
+	UpcomingFor    time.Duration      // pre-releases are dated this far ahead so GitLab lists them as upcoming; zero disables; GitHub marks them as pre-releases instead
+	Assets         string             // glob (or @manifest) of files to upload and link; "{app}" expands to the app name
+	Changelog      string             // "", "commit" or "mr": how apps/<app>/CHANGELOG.md updates reach the repo
+	NotesTemplate  string             // text/template source for the release description; empty uses the built-in changelog
+	TagMessage     string             // text/template source for the tag message; empty uses "Release <version> for <app>"
+	SignTags       bool               // create GPG-signed tags (git tag -s) and verify them before pushing
+	SigningKey     string             // key used for signing; empty uses git's user.signingkey
+	JiraTransition string             // transition applied to the changelog's Jira issues after releasing; empty disables Jira
 
-// colorHandler is a simple slog.Handler that adds color to log levels for console readability.
-type colorHandler struct{ slog.Handler }
+	// Links and identities, normally taken from GitLab CI's predefined variables.
+	ProjectURL  string // web URL of the project, for compare and release links
+	PipelineURL string // pipeline that ran the release
+	JiraBaseURL string // turns Jira keys into links when set
+	Branch      string // branch the changelog commit (or merge request) targets
+	AuthorName  string // changelog commit author; empty uses git config
+	AuthorEmail string
+}
 
-func (h colorHandler) Handle(ctx context.Context, r slog.Record) error {
-	var color string
-	switch r.Level {
-	case slog.LevelError:
-		color = red
-	case slog.LevelWarn:
-		color = yel
-	case slog.LevelInfo:
-		color = gre
-	default: // Debug
-		color = blu
-	}
-	fmt.Fprint(os.Stderr, color)
-	err := h.Handler.Handle(ctx, r) // delegate actual formatting
-	fmt.Fprint(os.Stderr, reset)
-	return err
+// Project represents the structure of a single module from our exported dependency graph.
+type Project struct {
+	ProjectDir   string   `json:"projectDir"`
+	Dependencies []string `json:"dependencies"`
+	Deployable   *bool    `json:"deployable,omitempty"`   // nil means deployable exactly when under :apps:
+	ExcludePaths []string `json:"excludePaths,omitempty"` // relative to ProjectDir; ignored for changelogs and affected apps
 }
 
-var logger *slog.Logger
+// Result records the outcome of releasing a single app. It is also what the
+// release CLI prints for later CI jobs with --output json.
+type Result struct {
+	App         string   `json:"app"`
+	Version     string   `json:"version,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+	PreviousTag string   `json:"previous_tag,omitempty"`
+	Status      string   `json:"status"`
+	URL         string   `json:"url,omitempty"`       // web page of the release, once it exists; empty without Config.ProjectURL
+	Paths       []string `json:"paths,omitempty"`     // the paths the release covers: the app and its libraries
+	Changelog   string   `json:"changelog,omitempty"` // built-in Markdown changelog, also used for CHANGELOG.md
+}
 
-// init initializes a structured logger for the application.
-func init() {
-	base := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	})
-	logger = slog.New(colorHandler{base})
+// Releaser runs releases against one repository.
+type Releaser struct {
+	cfg      Config
+	git      Git
+	provider Provider
+	jira     Jira
+	log      *slog.Logger
+	out      io.Writer
+
+	graph  *depgraph.Graph
+	notes  *template.Template
+	tagMsg *template.Template
 }
 
-//
-// ----------------- MODELS & CONFIG -----------------
-//
+// Option customises a Releaser.
+type Option func(*Releaser)
 
-// Config holds all the necessary configuration derived from environment variables and arguments.
-type Config struct {
-	AppName         string
-	ReleaseVersion  string
-	NewTag          string
-	ProjectID       string
-	GitLabAPIToken  string
-	GraphFile       string
-	DependencyGraph map[string]Project
+// WithJira sets the Jira client used by Config.JiraTransition.
+func WithJira(j Jira) Option { return func(r *Releaser) { r.jira = j } }
+
+// WithLogger sets the logger; the default discards everything.
+func WithLogger(l *slog.Logger) Option { return func(r *Releaser) { r.log = l } }
+
+// WithOutput sets where dry runs print their report; the default is stdout.
+func WithOutput(w io.Writer) Option { return func(r *Releaser) { r.out = w } }
+
+// New validates cfg and returns a Releaser. p may be nil for dry runs and
+// version suggestions, which never talk to the provider.
+func New(cfg Config, git Git, p Provider, opts ...Option) (*Releaser, error) {
+	r := &Releaser{
+		cfg:      cfg,
+		git:      git,
+		provider: p,
+		log:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		out:      os.Stdout,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+
+	switch cfg.Changelog {
+	case "", "commit", "mr":
+	default:
+		return nil, fmt.Errorf("changelog mode must be commit or mr, got %q", cfg.Changelog)
+	}
+	if cfg.AllAffected && len(cfg.Apps) > 0 {
+		return nil, errors.New("all-affected cannot be combined with app names")
+	}
+	if !cfg.AllAffected && len(cfg.Apps) == 0 {
+		return nil, errors.New("no apps to release")
+	}
+	for _, app := range cfg.Apps {
+		if app == "" {
+			return nil, errors.New("app name must not be empty")
+		}
+	}
+	if cfg.Version == "" && !cfg.AutoBump && !cfg.SuggestVersion {
+		return nil, errors.New("no release version given (or enable auto-bump)")
+	}
+	if cfg.Prerelease != "" {
+		if !cfg.AutoBump && !cfg.SuggestVersion {
+			return nil, errors.New("a pre-release identifier needs auto-bump or suggest-version; otherwise put it in the version")
+		}
+		if !prereleaseIDRe.MatchString(cfg.Prerelease) {
+			return nil, fmt.Errorf("pre-release identifier %q must be alphanumeric, like rc or beta", cfg.Prerelease)
+		}
+	}
+	// Collaborators are only needed when the run actually publishes something.
+	if !cfg.DryRun && !cfg.SuggestVersion {
+		if p == nil {
+			return nil, errors.New("a GitLab or GitHub client is required unless dry-running")
+		}
+		if cfg.JiraTransition != "" && r.jira == nil {
+			return nil, errors.New("a Jira client is required for the Jira transition")
+		}
+		if cfg.Changelog != "" && cfg.Branch == "" {
+			return nil, errors.New("a target branch is required to publish changelogs")
+		}
+	}
+
+	funcs := template.FuncMap{
+		"jira": func(id string) string { return jiraLink(cfg.JiraBaseURL, id) },
+		"join": strings.Join,
+	}
+	var err error
+	if cfg.NotesTemplate != "" {
+		if r.notes, err = template.New("release-notes").Funcs(funcs).Parse(cfg.NotesTemplate); err != nil {
+			return nil, fmt.Errorf("could not parse release notes template: %w", err)
+		}
+	}
+	if cfg.TagMessage != "" {
+		if r.tagMsg, err = template.New("tag-message").Funcs(funcs).Parse(cfg.TagMessage); err != nil {
+			return nil, fmt.Errorf("could not parse tag message template: %w", err)
+		}
+	}
+	if r.graph, err = buildGraph(cfg.Graph); err != nil {
+		return nil, fmt.Errorf("could not build dependency graph: %w", err)
+	}
+	return r, nil
 }
 
-// Project represents the structure of a single module from our exported dependency graph.
-type Project struct {
-	ProjectDir   string   `json:"projectDir"`
-	Dependencies []string `json:"dependencies"`
+// prereleaseIDRe matches the label of a release candidate series; the
+// candidate number is appended after a dot.
+var prereleaseIDRe = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// LoadGraph reads and parses the dependency graph JSON file.
+func LoadGraph(path string) (map[string]Project, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dependency graph at %s: %w", path, err)
+	}
+	defer file.Close()
+
+	bytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects map[string]Project
+	if err := json.Unmarshal(bytes, &projects); err != nil {
+		return nil, fmt.Errorf("error parsing JSON from %s: %w", path, err)
+	}
+	return projects, nil
+}
+
+// appPrefix is the Gradle path prefix of every deployable app in the graph.
+const appPrefix = ":apps:"
+
+// buildGraph converts the raw graph file into a depgraph.Graph. Projects
+// without an explicit deployable flag are deployable when under :apps:.
+func buildGraph(raw map[string]Project) (*depgraph.Graph, error) {
+	projects := make([]depgraph.Project, 0, len(raw))
+	for name, p := range raw {
+		deployable := strings.HasPrefix(name, appPrefix)
+		if p.Deployable != nil {
+			deployable = *p.Deployable
+		}
+		projects = append(projects, depgraph.Project{
+			Name:         name,
+			ProjectDir:   p.ProjectDir,
+			Dependencies: p.Dependencies,
+			Deployable:   deployable,
+			ExcludePaths: p.ExcludePaths,
+		})
+	}
+	return depgraph.NewGraph(projects)
 }
 
 //
 // ----------------- MAIN EXECUTION FLOW -----------------
 //
 
-func main() {
-	if err := run(); err != nil {
-		logger.Error("release script failed", "error", err)
-		os.Exit(1)
+// Run releases every configured app in dependency order. Results are returned
+// for every app, including the ones skipped after a failure.
+func (r *Releaser) Run(ctx context.Context) ([]Result, error) {
+	if _, err := r.git.Run(ctx, "fetch", "--tags"); err != nil {
+		return nil, fmt.Errorf("failed to fetch git tags: %w", err)
 	}
-	logger.Info("release script completed successfully")
-}
 
-// run contains the core logic of our application.
-func run() error {
-	// --- 1. Load and Validate Configuration ---
-	cfg, err := loadConfig()
+	apps, err := r.resolveApps(ctx)
 	if err != nil {
-		return fmt.Errorf("configuration error: %w", err)
+		return nil, err
 	}
-	logger.Info("configuration loaded", "app", cfg.AppName, "version", cfg.ReleaseVersion, "tag", cfg.NewTag)
+	if len(apps) == 0 {
+		r.log.Warn("no apps to release")
+		return nil, nil
+	}
+	r.log.Info("configuration loaded", "apps", apps, "version", r.cfg.Version)
 
-	// --- 2. Find Previous Tag ---
-	if _, err := runGitCommand("fetch", "--tags"); err != nil {
-		return fmt.Errorf("failed to fetch git tags: %w", err)
+	// Release in order; once one app fails, its dependents must not go out.
+	results := make([]Result, 0, len(apps))
+	var failed error
+	for _, app := range apps {
+		if failed != nil {
+			results = append(results, Result{App: app, Status: "skipped (earlier release failed)"})
+			continue
+		}
+		res, err := r.releaseApp(ctx, app)
+		if err != nil {
+			res.Status = "failed: " + err.Error()
+			failed = fmt.Errorf("release of %s failed: %w", app, err)
+		}
+		results = append(results, res)
 	}
-	previousTag, err := findPreviousTag(cfg.AppName)
-	if err != nil {
-		return fmt.Errorf("could not determine previous tag: %w", err)
+
+	// Changelogs of the apps that did go out are recorded even if a later one failed.
+	if r.cfg.Changelog != "" && !r.cfg.DryRun && !r.cfg.SuggestVersion {
+		if err := r.publishChangelogs(ctx, results); err != nil {
+			failed = errors.Join(failed, fmt.Errorf("could not publish changelogs: %w", err))
+		}
 	}
-	logger.Info("found previous release tag", "previous_tag", previousTag)
+	return results, failed
+}
 
-	// --- 3. Determine Changed Paths from Dependency Graph ---
-	changedPaths, err := findAppAndDependencyPaths(cfg)
-	if err != nil {
-		return err
+// resolveApps returns the apps to release, dependencies first. With
+// AllAffected the set comes from the files changed since cfg.Since.
+func (r *Releaser) resolveApps(ctx context.Context) ([]string, error) {
+	var modules []string
+	if r.cfg.AllAffected {
+		files, err := r.changedFiles(ctx, r.cfg.Since)
+		if err != nil {
+			return nil, fmt.Errorf("could not list changed files: %w", err)
+		}
+		modules, err = r.graph.AffectedDeployables(r.graph.ProjectsForFiles(files))
+		if err != nil {
+			return nil, fmt.Errorf("could not determine affected apps: %w", err)
+		}
+		r.log.Info("resolved affected apps", "changed_files", len(files), "apps", len(modules))
+	} else {
+		for _, app := range r.cfg.Apps {
+			modules = append(modules, appPrefix+app)
+		}
 	}
-	logger.Info("determined all relevant paths from dependency graph", "count", len(changedPaths))
 
-	// --- 4. Get Changes ---
-	changelog, err := getChangelog(previousTag, "HEAD", changedPaths)
+	ordered, err := r.graph.TopoOrder(modules)
 	if err != nil {
-		return fmt.Errorf("could not generate changelog: %w", err)
+		return nil, fmt.Errorf("could not order apps: %w", err)
 	}
-	if changelog == "" {
-		logger.Warn("no changes detected for this release, aborting")
-		return nil // Not an error, just nothing to release.
+	apps := make([]string, len(ordered))
+	for i, m := range ordered {
+		apps[i] = strings.TrimPrefix(m, appPrefix)
 	}
-	logger.Info("changelog generated", "content", changelog)
+	return apps, nil
+}
+
+// release is the per-app state threaded through releaseApp.
+type release struct {
+	App     string
+	Version string
+	Tag     string
+	Commit  string // full SHA of HEAD, the commit being released
+}
 
-	// --- 5. Create and Push Git Tag ---
-	if _, err := runGitCommand("tag", "-a", cfg.NewTag, "-m", fmt.Sprintf("Release %s for %s", cfg.ReleaseVersion, cfg.AppName)); err != nil {
-		return fmt.Errorf("failed to create git tag %s: %w", cfg.NewTag, err)
+// releaseApp runs the full tag-and-release flow for one app.
+func (r *Releaser) releaseApp(ctx context.Context, app string) (Result, error) {
+	rel := release{App: app, Version: r.cfg.Version}
+	if rel.Version != "" {
+		// The 'v' prefix is removed from the tag.
+		rel.Tag = fmt.Sprintf("%s/%s", app, rel.Version)
 	}
-	logger.Info("successfully created local git tag", "tag", cfg.NewTag)
+	res := Result{App: app, Version: rel.Version, Tag: rel.Tag}
+	log := r.log.With("app", app)
 
-	if _, err := runGitCommand("push", "origin", cfg.NewTag); err != nil {
-		return fmt.Errorf("failed to push git tag %s: %w", cfg.NewTag, err)
+	headSHA, err := r.git.Run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return res, fmt.Errorf("could not resolve HEAD: %w", err)
 	}
-	logger.Info("successfully pushed git tag to remote", "tag", cfg.NewTag)
+	rel.Commit = headSHA
 
-	// --- 6. Create GitLab Release ---
-	if err := createGitLabRelease(cfg, changelog); err != nil {
-		return fmt.Errorf("failed to create GitLab release: %w", err)
+	// A computed version is a candidate exactly when a pre-release label is configured.
+	prerelease := isPrerelease(rel.Version)
+	if r.cfg.AutoBump || r.cfg.SuggestVersion {
+		prerelease = r.cfg.Prerelease != ""
 	}
 
-	return nil
-}
+	// --- 1. Find Previous Tag ---
+	// The release's own tag is skipped so a rerun compares against the release before it.
+	// Final releases skip release candidates, so their changelog covers the whole cycle;
+	// candidates compare against the latest tag of either kind.
+	previousTag, err := r.findPreviousTag(ctx, app, rel.Tag, !prerelease)
+	if err != nil {
+		return res, fmt.Errorf("could not determine previous tag: %w", err)
+	}
 
-//
-// ----------------- CONFIG & SETUP -----------------
-//
+	// An auto-bumped run that died after tagging left its tag on HEAD; pick
+	// that release up again instead of bumping past it.
+	resuming := false
+	if r.cfg.AutoBump && rel.Tag == "" && strings.HasPrefix(previousTag, app+"/") &&
+		isPrerelease(strings.TrimPrefix(previousTag, app+"/")) == prerelease {
+		if sha, err := r.tagCommit(ctx, previousTag); err == nil && sha == headSHA {
+			resuming = true
+			rel.Tag = previousTag
+			rel.Version = strings.TrimPrefix(previousTag, app+"/")
+			res.Tag, res.Version = rel.Tag, rel.Version
+			log.Info("HEAD is already tagged, resuming that release", "tag", rel.Tag)
+			if previousTag, err = r.findPreviousTag(ctx, app, rel.Tag, !prerelease); err != nil {
+				return res, fmt.Errorf("could not determine previous tag: %w", err)
+			}
+		}
+	}
+	res.PreviousTag = previousTag
+	log.Info("found previous release tag", "previous_tag", previousTag)
 
-// loadConfig populates the Config struct from arguments and environment variables.
-func loadConfig() (*Config, error) {
-	if len(os.Args) < 2 {
-		return nil, fmt.Errorf("usage: %s <app-name>", os.Args[0])
+	// --- 2. Determine Changed Paths from Dependency Graph ---
+	scope, err := r.releasePaths(app)
+	if err != nil {
+		return res, fmt.Errorf("could not scope paths from dependency graph: %w", err)
 	}
+	log.Info("determined all relevant paths from dependency graph", "count", len(scope.Include), "excluded", len(scope.Exclude))
+	res.Paths = scope.Include
 
-	cfg := Config{
-		AppName:        os.Args[1],
-		ReleaseVersion: os.Getenv("RELEASE_VERSION"),
-		ProjectID:      os.Getenv("CI_PROJECT_ID"),
-		GitLabAPIToken: os.Getenv("GITLAB_API_TOKEN"),
-		GraphFile:      "build/dependency-graph.json",
+	// --- 3. Get Changes ---
+	commits, err := r.commits(ctx, previousTag, "HEAD", scope)
+	if err != nil {
+		return res, fmt.Errorf("could not generate changelog: %w", err)
+	}
+	if len(commits) == 0 {
+		log.Warn("no changes detected for this release, skipping")
+		res.Version, res.Tag = "", ""
+		res.Status = "skipped (no changes)"
+		return res, nil // Not an error, just nothing to release.
 	}
 
-	// Validate required config
-	if cfg.AppName == "" {
-		return nil, fmt.Errorf("app-name argument is required")
+	if (r.cfg.AutoBump && !resuming) || r.cfg.SuggestVersion {
+		var next string
+		if prerelease {
+			next, err = r.nextPrerelease(ctx, app, previousTag, commits, scope)
+		} else {
+			next, err = nextVersion(app, previousTag, commits)
+		}
+		if err != nil {
+			return res, fmt.Errorf("could not determine next version: %w", err)
+		}
+		res.Version = next
+		if r.cfg.SuggestVersion {
+			res.Status = "suggested"
+			return res, nil
+		}
+		if rel.Version != "" && rel.Version != next {
+			log.Warn("auto-bump overrides the configured version", "version", rel.Version, "computed", next)
+		}
+		rel.Version = next
+		rel.Tag = fmt.Sprintf("%s/%s", app, next)
+		res.Tag = rel.Tag
+		log.Info("computed next version from commits", "version", next, "tag", rel.Tag)
 	}
-	if cfg.ReleaseVersion == "" {
-		return nil, fmt.Errorf("RELEASE_VERSION environment variable is not set")
+
+	res.Changelog = renderChangelog(commits, r.cfg.JiraBaseURL)
+	notes := r.newNotes(rel, previousTag, commits, res.Changelog)
+	description, err := renderTemplate(r.notes, notes, res.Changelog+notes.Footer)
+	if err != nil {
+		return res, fmt.Errorf("could not render release notes: %w", err)
 	}
-	if cfg.ProjectID == "" {
-		return nil, fmt.Errorf("CI_PROJECT_ID environment variable is not set")
+	tagMessage, err := renderTemplate(r.tagMsg, notes, fmt.Sprintf("Release %s for %s", rel.Version, app))
+	if err != nil {
+		return res, fmt.Errorf("could not render tag message: %w", err)
 	}
-	if cfg.GitLabAPIToken == "" {
-		return nil, fmt.Errorf("GITLAB_API_TOKEN environment variable is not set")
+	log.Info("changelog generated", "content", description)
+
+	assets, err := collectAssets(r.cfg.Assets, app)
+	if err != nil {
+		return res, fmt.Errorf("could not collect release assets: %w", err)
 	}
 
-	// The 'v' prefix is removed from the tag.
-	cfg.NewTag = fmt.Sprintf("%s/%s", cfg.AppName, cfg.ReleaseVersion)
+	if r.cfg.DryRun {
+		res.Status = "dry run"
+		return res, r.printDryRun(rel, previousTag, scope, description, assets)
+	}
 
-	// Load the dependency graph
-	graph, err := loadProjects(cfg.GraphFile)
+	// --- 4. Create and Push Git Tag ---
+	// Every step checks whether an earlier, interrupted run already did it.
+	state, err := r.inspectTag(ctx, rel.Tag, headSHA)
 	if err != nil {
-		return nil, fmt.Errorf("could not load project graph: %w", err)
+		return res, err
+	}
+	if state.remote {
+		exists, err := r.provider.ReleaseExists(ctx, rel.Tag)
+		if err != nil {
+			return res, fmt.Errorf("could not check for an existing release: %w", err)
+		}
+		if exists {
+			log.Info("tag and release already exist, nothing to do", "tag", rel.Tag)
+			res.Status = "already released"
+			res.URL = r.releaseURL(rel.Tag)
+			return res, nil
+		}
+		log.Warn("tag already pushed but the release is missing, resuming", "tag", rel.Tag)
+	} else {
+		if state.local {
+			log.Warn("tag exists locally but was never pushed, resuming", "tag", rel.Tag)
+		} else {
+			if _, err := r.git.Run(ctx, r.tagArgs(rel.Tag, tagMessage)...); err != nil {
+				return res, fmt.Errorf("failed to create git tag %s: %w", rel.Tag, err)
+			}
+			log.Info("successfully created local git tag", "tag", rel.Tag, "signed", r.cfg.SignTags)
+		}
+
+		// Never push a tag that is meant to be signed but is not.
+		if r.cfg.SignTags {
+			if _, err := r.git.Run(ctx, "tag", "-v", rel.Tag); err != nil {
+				return res, fmt.Errorf("tag %s does not carry a valid signature: %w", rel.Tag, err)
+			}
+		}
+
+		if _, err := r.git.Run(ctx, "push", "origin", rel.Tag); err != nil {
+			return res, fmt.Errorf("failed to push git tag %s: %w", rel.Tag, err)
+		}
+		log.Info("successfully pushed git tag to remote", "tag", rel.Tag)
+	}
+
+	// --- 5. Create the Release and Upload its Assets ---
+	files := make([]Asset, len(assets))
+	for i, a := range assets {
+		log.Info("release asset", "name", a.Name, "sha256", a.SHA256)
+		files[i] = Asset{Name: a.Name, Open: a.open}
+	}
+	log.Info("creating release", "tag", rel.Tag)
+	if err := r.provider.CreateRelease(ctx, r.releasePayload(rel, description, assets), files); err != nil {
+		return res, fmt.Errorf("failed to create release: %w", err)
 	}
-	cfg.DependencyGraph = graph
+	log.Info("release created successfully", "tag", rel.Tag)
 
-	return &cfg, nil
+	res.Status = "released"
+	res.URL = r.releaseURL(rel.Tag)
+
+	// --- 6. Update Jira ---
+	// The release is out at this point, so Jira problems are reported but do not fail it.
+	// Candidates leave issues alone; the final release references them again.
+	if r.cfg.JiraTransition != "" && !prerelease {
+		if err := r.updateJiraIssues(ctx, rel, commits); err != nil {
+			log.Warn("could not update all Jira issues", "error", err)
+			res.Status = "released (Jira update incomplete)"
+		}
+	}
+	return res, nil
 }
 
-// loadProjects reads and parses the dependency graph JSON file.
-func loadProjects(path string) (map[string]Project, error) {
-	file, err := os.Open(path)
+// nextPrerelease computes the next release candidate: the final version the
+// commits since the last final release call for, with the candidate number
+// one past the highest existing candidate of that version.
+func (r *Releaser) nextPrerelease(ctx context.Context, app, previousTag string, commits []Commit, scope pathScope) (string, error) {
+	stableTag, stableCommits := previousTag, commits
+	if strings.HasPrefix(previousTag, app+"/") && isPrerelease(strings.TrimPrefix(previousTag, app+"/")) {
+		var err error
+		if stableTag, err = r.findPreviousTag(ctx, app, "", true); err != nil {
+			return "", err
+		}
+		if stableCommits, err = r.commits(ctx, stableTag, "HEAD", scope); err != nil {
+			return "", err
+		}
+	}
+	target, err := nextVersion(app, stableTag, stableCommits)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open dependency graph at %s: %w", path, err)
+		return "", err
 	}
-	defer file.Close()
 
-	bytes, err := io.ReadAll(file)
+	tags, err := r.appTags(ctx, app)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	versions := make([]string, len(tags))
+	for i, tag := range tags {
+		versions[i] = strings.TrimPrefix(tag, app+"/")
 	}
+	return nextCandidate(target, r.cfg.Prerelease, versions), nil
+}
 
-	var projects map[string]Project
-	if err := json.Unmarshal(bytes, &projects); err != nil {
-		return nil, fmt.Errorf("error parsing JSON from %s: %w", path, err)
+// releasePaths scopes an app's history using the dependency graph: the app
+// and its dependencies up to (not into) other deployable apps, minus every
+// project's excludePaths.
+func (r *Releaser) releasePaths(app string) (pathScope, error) {
+	include, exclude, err := r.graph.ReleasePaths(appPrefix + app)
+	return pathScope{Include: include, Exclude: exclude}, err
+}
+
+// PrintSummary writes one line per app so a multi-app run can be read at a glance.
+func PrintSummary(w io.Writer, results []Result) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "APP\tTAG\tPREVIOUS\tSTATUS")
+	for _, r := range results {
+		tag := r.Tag
+		if tag == "" {
+			tag = "-"
+		}
+		prev := r.PreviousTag
+		if prev == "" {
+			prev = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.App, tag, prev, r.Status)
 	}
-	return projects, nil
+	tw.Flush()
 }
 
 //
-// ----------------- GIT & CHANGELOG LOGIC -----------------
+// ----------------- DRY RUN -----------------
 //
 
-// runGitCommand executes a git command and returns its output or an error.
-func runGitCommand(args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
+// printDryRun writes everything a real run would act on: the tag that would be
+// created, the comparison range, the scoped paths, and the release payload.
+func (r *Releaser) printDryRun(rel release, previousTag string, scope pathScope, description string, assets []releaseAsset) error {
+	r.log.Info("dry run: skipping git tag, git push, and release API calls")
+	w := r.out
+
+	fmt.Fprintf(w, "Tag to create:  %s\n", rel.Tag)
+	fmt.Fprintf(w, "Previous tag:   %s\n", previousTag)
+	fmt.Fprintf(w, "Changed paths:\n")
+	for _, p := range scope.Include {
+		fmt.Fprintf(w, "  - %s\n", p)
+	}
+	if len(scope.Exclude) > 0 {
+		fmt.Fprintf(w, "Excluded paths:\n")
+		for _, p := range scope.Exclude {
+			fmt.Fprintf(w, "  - %s\n", p)
+		}
+	}
+	if len(assets) > 0 {
+		fmt.Fprintf(w, "Assets to upload:\n")
+		for _, a := range assets {
+			fmt.Fprintf(w, "  - %s  %s\n", a.SHA256, a.Name)
+		}
+	}
+	fmt.Fprintf(w, "Release payload:\n")
+
+	body, err := json.MarshalIndent(r.releasePayload(rel, description, assets), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal release payload: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(body))
+	return err
+}
+
+// -----------------------------------------------------------------------------
+// releigh/git.go
+// -----------------------------------------------------------------------------
+package releigh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Git runs a git command in the repository being released and returns its
+// trimmed stdout.
+type Git interface {
+	Run(ctx context.Context, args ...string) (string, error)
+}
+
+// ExecGit is the Git implementation that shells out to the git binary.
+type ExecGit struct {
+	Dir string       // repository root; empty uses the working directory
+	Log *slog.Logger // optional; commands are logged at debug level
+}
+
+// Run executes a git command and returns its output or an error.
+func (g ExecGit) Run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.Dir
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	logger.Debug("executing git command", "args", args)
+	if g.Log != nil {
+		g.Log.Debug("executing git command", "args", args)
+	}
 
 	if err := cmd.Run(); err != nil {
 		return "", fmt.Errorf("git command failed: %v\n%s", err, stderr.String())
@@ -231,31 +637,43 @@ func runGitCommand(args ...string) (string, error) {
 	return strings.TrimSpace(stdout.String()), nil
 }
 
-// findPreviousTag finds the most recent tag for a specific app based on commit date.
-func findPreviousTag(appName string) (string, error) {
-	tagPrefix := appName + "/*"
+// appTags lists an app's tags by creation date, newest first. creatordate is
+// the tagger date for annotated tags (which committerdate leaves empty) and
+// the commit date for lightweight ones.
+func (r *Releaser) appTags(ctx context.Context, app string) ([]string, error) {
+	out, err := r.git.Run(ctx, "for-each-ref", "--sort=-creatordate", fmt.Sprintf("refs/tags/%s/*", app), "--format=%(refname:short)")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
 
-	// Use for-each-ref to get tags sorted by most recent committer date first.
-	// This finds the latest tag chronologically.
-	out, err := runGitCommand("for-each-ref", "--sort=-committerdate", fmt.Sprintf("refs/tags/%s", tagPrefix), "--format=%(refname:short)", "--count=1")
+// findPreviousTag finds the most recent tag for a specific app based on creation date,
+// ignoring exclude (the tag being released, which may exist from an earlier attempt).
+// With stable set, pre-release tags are skipped too, so a final release is compared
+// against the previous final release rather than its own release candidates.
+func (r *Releaser) findPreviousTag(ctx context.Context, app, exclude string, stable bool) (string, error) {
+	tags, err := r.appTags(ctx, app)
 	if err != nil {
 		return "", err
 	}
-
-	// If the output is empty, no tags were found for this app.
-	if out == "" {
-		logger.Warn("no previous tags found for app, will compare against initial commit", "app", appName)
-		return getFirstCommitForPath("apps/" + appName)
+	for _, tag := range tags {
+		if tag == exclude || (stable && isPrerelease(strings.TrimPrefix(tag, app+"/"))) {
+			continue
+		}
+		return tag, nil
 	}
 
-	return out, nil
+	// No other tags were found for this app.
+	r.log.Warn("no previous tags found for app, will compare against initial commit", "app", app, "stable_only", stable)
+	return r.firstCommitForPath(ctx, "apps/"+app)
 }
 
-// getFirstCommitForPath finds the hash of the very first commit that touched a given path.
-func getFirstCommitForPath(path string) (string, error) {
+// firstCommitForPath finds the hash of the very first commit that touched a given path.
+func (r *Releaser) firstCommitForPath(ctx context.Context, path string) (string, error) {
 	// --diff-filter=A gets the first commit that added files
 	// --reverse lists commits in chronological order
-	out, err := runGitCommand("log", "--reverse", "--diff-filter=A", "--pretty=format:%H", "--", path)
+	out, err := r.git.Run(ctx, "log", "--reverse", "--diff-filter=A", "--pretty=format:%H", "--", path)
 	if err != nil {
 		return "", fmt.Errorf("could not get first commit for path %s: %w", path, err)
 	}
@@ -266,116 +684,2150 @@ func getFirstCommitForPath(path string) (string, error) {
 	return "", fmt.Errorf("no commits found for path %s", path)
 }
 
-// findAppAndDependencyPaths traverses the graph to find all filesystem paths for an app and its dependencies.
-func findAppAndDependencyPaths(cfg *Config) ([]string, error) {
-	appGradlePath := ":apps:" + cfg.AppName
-
-	// Use a map to avoid duplicate paths
-	paths := make(map[string]bool)
-	queue := []string{appGradlePath}
-	processed := make(map[string]bool)
-
-	for len(queue) > 0 {
-		currentModule := queue[0]
-		queue = queue[1:]
-
-		if processed[currentModule] {
-			continue
-		}
-		processed[currentModule] = true
-
-		projectData, ok := cfg.DependencyGraph[currentModule]
-		if !ok {
-			logger.Warn("module not found in dependency graph, skipping", "module", currentModule)
-			continue
-		}
-
-		paths[projectData.ProjectDir] = true
-		queue = append(queue, projectData.Dependencies...)
-	}
+// pathScope is the part of the repository an app's history is read from.
+type pathScope struct {
+	Include []string
+	Exclude []string
+}
 
-	// Convert map keys to a slice
-	pathList := make([]string, 0, len(paths))
-	for path := range paths {
-		pathList = append(pathList, path)
+// pathspecs renders the scope as git pathspecs.
+func (s pathScope) pathspecs() []string {
+	specs := append([]string(nil), s.Include...)
+	for _, p := range s.Exclude {
+		specs = append(specs, ":(exclude)"+p)
 	}
-	return pathList, nil
+	return specs
 }
 
-// getChangelog generates a formatted changelog string from git commits.
-func getChangelog(fromRef, toRef string, paths []string) (string, error) {
-	// The format is: * <short-hash> <commit-subject>
-	gitLogCmd := []string{"log", "--pretty=format:* %h %s", fmt.Sprintf("%s..%s", fromRef, toRef), "--"}
-	gitLogCmd = append(gitLogCmd, paths...)
+// commits lists the commits between two refs that touch the scope.
+func (r *Releaser) commits(ctx context.Context, fromRef, toRef string, scope pathScope) ([]Commit, error) {
+	// Fields are separated by 0x1f and records by 0x1e so bodies may contain newlines.
+	gitLogCmd := []string{"log", "--pretty=format:%h%x1f%s%x1f%b%x1e", fmt.Sprintf("%s..%s", fromRef, toRef), "--"}
+	gitLogCmd = append(gitLogCmd, scope.pathspecs()...)
 
-	out, err := runGitCommand(gitLogCmd...)
+	out, err := r.git.Run(ctx, gitLogCmd...)
 	if err != nil {
-		return "", fmt.Errorf("failed to get git log: %w", err)
+		return nil, fmt.Errorf("failed to get git log: %w", err)
 	}
+	return parseLog(out), nil
+}
 
-	// Extract Jira IDs and format the changelog
-	jiraRegex := regexp.MustCompile(`([A-Z]+-[0-9]+)`)
-	var changelog strings.Builder
-	lines := strings.Split(out, "\n")
+// changedFiles lists the files changed since ref, or by the last commit when
+// ref is empty; these are the same ranges gitdiff uses for pipeline generation.
+func (r *Releaser) changedFiles(ctx context.Context, ref string) ([]string, error) {
+	rangeSpec := "HEAD~1"
+	if ref != "" {
+		rangeSpec = ref + "...HEAD"
+	}
+	out, err := r.git.Run(ctx, "diff", "--name-only", rangeSpec)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
 
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		matches := jiraRegex.FindAllString(line, -1)
-		if len(matches) > 0 {
-			// e.g., "* 7f4d2f8 feat(billing): implement new invoice system [BILL-123]"
-			changelog.WriteString(fmt.Sprintf("%s [%s]\n", line, strings.Join(matches, ", ")))
-		} else {
-			changelog.WriteString(line + "\n")
+// tagArgs builds the git tag command for tag: annotated by default,
+// GPG-signed with SignTags.
+func (r *Releaser) tagArgs(tag, message string) []string {
+	args := []string{"tag", "-a"}
+	if r.cfg.SignTags {
+		args = []string{"tag", "-s"}
+		if r.cfg.SigningKey != "" {
+			args = append(args, "-u", r.cfg.SigningKey)
 		}
 	}
-
-	return changelog.String(), nil
+	return append(args, tag, "-m", message)
 }
 
-//
-// ----------------- GITLAB API INTEGRATION -----------------
-//
-
-// createGitLabRelease posts the new release information to the GitLab API.
-func createGitLabRelease(cfg *Config, changelog string) error {
-	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/releases", os.Getenv("CI_SERVER_URL"), cfg.ProjectID)
+// tagState records which release steps an earlier run already completed for a tag.
+type tagState struct {
+	local  bool // tag exists in this clone
+	remote bool // tag exists on origin
+}
 
-	releaseTitle := fmt.Sprintf("%s %s", cfg.AppName, cfg.ReleaseVersion)
+// tagCommit returns the commit a tag points at.
+func (r *Releaser) tagCommit(ctx context.Context, tag string) (string, error) {
+	return r.git.Run(ctx, "rev-list", "-n", "1", tag)
+}
 
-	payload := map[string]string{
-		"name":        releaseTitle,
-		"tag_name":    cfg.NewTag,
-		"description": changelog,
+// inspectTag reports whether tag already exists locally and on origin. A tag
+// that exists but points anywhere other than HEAD is a version collision, not
+// something to resume, so it is reported as an error.
+func (r *Releaser) inspectTag(ctx context.Context, tag, headSHA string) (tagState, error) {
+	var state tagState
+	if _, err := r.git.Run(ctx, "rev-parse", "-q", "--verify", "refs/tags/"+tag); err == nil {
+		state.local = true
+		sha, err := r.tagCommit(ctx, tag)
+		if err != nil {
+			return state, fmt.Errorf("could not resolve existing tag %s: %w", tag, err)
+		}
+		if sha != headSHA {
+			return state, fmt.Errorf("tag %s already exists at %s, not HEAD (%s)", tag, sha, headSHA)
+		}
 	}
-	body, err := json.Marshal(payload)
+	out, err := r.git.Run(ctx, "ls-remote", "--tags", "origin", "refs/tags/"+tag)
 	if err != nil {
-		return fmt.Errorf("failed to marshal release payload: %w", err)
+		return state, fmt.Errorf("could not list remote tags: %w", err)
 	}
+	state.remote = out != ""
+	return state, nil
+}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(body))
+// ImportSigningKey imports an ASCII-armored secret key into a throwaway
+// GNUPGHOME so CI runners can sign tags without a preconfigured keyring, and
+// returns the key's fingerprint. GNUPGHOME is set for the whole process
+// because git runs gpg as a child. The returned func removes the keyring.
+func ImportSigningKey(armored string) (string, func(), error) {
+	home, err := os.MkdirTemp("", "release-gnupg-")
 	if err != nil {
-		return fmt.Errorf("failed to create http request: %w", err)
+		return "", nil, err
 	}
+	cleanup := func() { os.RemoveAll(home) }
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("PRIVATE-TOKEN", cfg.GitLabAPIToken)
-
-	logger.Info("creating GitLab release", "url", apiURL, "title", releaseTitle)
+	cmd := exec.Command("gpg", "--batch", "--import")
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+home)
+	cmd.Stdin = strings.NewReader(armored)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("gpg import failed: %v\n%s", err, out)
+	}
+	os.Setenv("GNUPGHOME", home)
+
+	out, err := exec.Command("gpg", "--batch", "--with-colons", "--list-secret-keys").Output()
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("could not list imported keys: %w", err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Split(line, ":"); fields[0] == "fpr" && len(fields) > 9 {
+			return fields[9], cleanup, nil
+		}
+	}
+	cleanup()
+	return "", nil, fmt.Errorf("the armored key does not contain a secret key")
+}
+
+// -----------------------------------------------------------------------------
+// releigh/changelog.go
+// -----------------------------------------------------------------------------
+package releigh
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//
+// ----------------- COMMITS & CHANGELOG -----------------
+//
+
+// Commit is a single commit in the release range, parsed as a conventional commit
+// ("type(scope)!: subject") where possible.
+type Commit struct {
+	Hash     string
+	Type     string // feat, fix, chore, … or "" when the subject is not conventional
+	Scope    string
+	Subject  string // description without the type/scope prefix
+	Breaking bool   // "!" after the type/scope or a BREAKING CHANGE footer
+	JiraIDs  []string
+}
+
+var (
+	conventionalRe = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+	jiraRe         = regexp.MustCompile(`([A-Z][A-Z0-9]+-[0-9]+)`)
+	breakingRe     = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:`)
+)
+
+// parseCommit splits a commit subject and body into its conventional-commit parts.
+func parseCommit(hash, subject, body string) Commit {
+	c := Commit{Hash: hash, Subject: subject}
+	if m := conventionalRe.FindStringSubmatch(subject); m != nil {
+		c.Type = strings.ToLower(m[1])
+		c.Scope = m[2]
+		c.Breaking = m[3] == "!"
+		c.Subject = m[4]
+	}
+	if breakingRe.MatchString(body) {
+		c.Breaking = true
+	}
+	seen := make(map[string]bool)
+	for _, id := range jiraRe.FindAllString(subject+"\n"+body, -1) {
+		if !seen[id] {
+			seen[id] = true
+			c.JiraIDs = append(c.JiraIDs, id)
+		}
+	}
+	return c
+}
+
+// parseLog parses `git log --pretty=format:%h%x1f%s%x1f%b%x1e` output.
+func parseLog(out string) []Commit {
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x1f", 3)
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		body := ""
+		if len(fields) == 3 {
+			body = fields[2]
+		}
+		c := parseCommit(fields[0], fields[1], body)
+		// Changelog commits made by publishChangelogs are bookkeeping, not changes to release.
+		if c.Type == "chore" && c.Scope == "release" {
+			continue
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// changelogSection is one heading of the rendered changelog.
+type changelogSection struct {
+	Title   string
+	Commits []Commit
+}
+
+// groupCommits sorts commits into Breaking / Features / Fixes / Other, keeping git log order within each.
+func groupCommits(commits []Commit) []changelogSection {
+	sections := []changelogSection{
+		{Title: "Breaking Changes"},
+		{Title: "Features"},
+		{Title: "Fixes"},
+		{Title: "Other Changes"},
+	}
+	for _, c := range commits {
+		switch {
+		case c.Breaking:
+			sections[0].Commits = append(sections[0].Commits, c)
+		case c.Type == "feat":
+			sections[1].Commits = append(sections[1].Commits, c)
+		case c.Type == "fix":
+			sections[2].Commits = append(sections[2].Commits, c)
+		default:
+			sections[3].Commits = append(sections[3].Commits, c)
+		}
+	}
+	return sections
+}
+
+// jiraLink renders a Jira key as a Markdown link when base is set.
+func jiraLink(base, id string) string {
+	base = strings.TrimSuffix(base, "/")
+	if base == "" {
+		return id
+	}
+	return fmt.Sprintf("[%s](%s/browse/%s)", id, base, id)
+}
+
+// renderChangelog formats grouped commits as Markdown, listing the Jira issues of each section.
+func renderChangelog(commits []Commit, jiraBase string) string {
+	var changelog strings.Builder
+	for _, section := range groupCommits(commits) {
+		if len(section.Commits) == 0 {
+			continue
+		}
+		fmt.Fprintf(&changelog, "### %s\n\n", section.Title)
+
+		var sectionJira []string
+		seen := make(map[string]bool)
+		for _, c := range section.Commits {
+			line := c.Subject
+			if c.Scope != "" {
+				line = fmt.Sprintf("**%s:** %s", c.Scope, c.Subject)
+			}
+			// e.g., "* 7f4d2f8 **billing:** implement new invoice system"
+			fmt.Fprintf(&changelog, "* %s %s\n", c.Hash, line)
+			for _, id := range c.JiraIDs {
+				if !seen[id] {
+					seen[id] = true
+					sectionJira = append(sectionJira, id)
+				}
+			}
+		}
+		if len(sectionJira) > 0 {
+			links := make([]string, len(sectionJira))
+			for i, id := range sectionJira {
+				links[i] = jiraLink(jiraBase, id)
+			}
+			fmt.Fprintf(&changelog, "\nJira: %s\n", strings.Join(links, ", "))
+		}
+		changelog.WriteString("\n")
+	}
+	return changelog.String()
+}
+
+//
+// ----------------- VERSIONING -----------------
+//
+
+// semver is a MAJOR.MINOR.PATCH version with an optional pre-release part
+// ("rc.2" in 1.4.0-rc.2); the optional "v" prefix is kept so bumped versions
+// look like the tags they follow.
+type semver struct {
+	Prefix              string
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+var semverRe = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?$`)
+
+// parseSemver parses "1.2.3", "v1.2.3" or "1.2.3-rc.1".
+func parseSemver(s string) (semver, error) {
+	m := semverRe.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, fmt.Errorf("%q is not a MAJOR.MINOR.PATCH version", s)
+	}
+	v := semver{Prefix: m[1], Pre: m[5]}
+	fmt.Sscan(m[2], &v.Major)
+	fmt.Sscan(m[3], &v.Minor)
+	fmt.Sscan(m[4], &v.Patch)
+	return v, nil
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%s%d.%d.%d", v.Prefix, v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// isPrerelease reports whether version carries a pre-release part. Versions
+// that are not semver count as pre-releases when they contain a "-".
+func isPrerelease(version string) bool {
+	if v, err := parseSemver(version); err == nil {
+		return v.Pre != ""
+	}
+	return strings.Contains(version, "-")
+}
+
+// bump returns the next version for the given commits: any breaking change is
+// a major bump, any feat a minor bump, and everything else a patch bump. A
+// pre-release is bumped to its own final version when that is big enough.
+func (v semver) bump(commits []Commit) semver {
+	level := 0 // 0 = patch, 1 = minor, 2 = major
+	for _, c := range commits {
+		switch {
+		case c.Breaking:
+			level = 2
+		case c.Type == "feat" && level < 1:
+			level = 1
+		}
+	}
+	if v.Pre != "" {
+		final := semver{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+		if level == 0 || (level == 1 && v.Patch == 0) || (v.Minor == 0 && v.Patch == 0) {
+			return final
+		}
+	}
+	switch level {
+	case 2:
+		return semver{Prefix: v.Prefix, Major: v.Major + 1}
+	case 1:
+		return semver{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return semver{Prefix: v.Prefix, Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
+
+// nextVersion works out the version that follows previousTag. When the app has
+// never been tagged (previousTag is a commit hash) the bump starts from 0.0.0.
+func nextVersion(app, previousTag string, commits []Commit) (string, error) {
+	current := semver{}
+	if version, ok := strings.CutPrefix(previousTag, app+"/"); ok {
+		v, err := parseSemver(version)
+		if err != nil {
+			return "", fmt.Errorf("previous tag %s: %w", previousTag, err)
+		}
+		current = v
+	}
+	return current.bump(commits).String(), nil
+}
+
+// nextCandidate returns the release candidate that follows the existing
+// candidates of target: "<target>-<pre>.1" for the first one, otherwise one
+// more than the highest N among the given versions.
+func nextCandidate(target, pre string, existing []string) string {
+	n := 0
+	for _, version := range existing {
+		suffix, ok := strings.CutPrefix(version, target+"-"+pre+".")
+		if !ok {
+			continue
+		}
+		if i, err := strconv.Atoi(suffix); err == nil && i > n {
+			n = i
+		}
+	}
+	return fmt.Sprintf("%s-%s.%d", target, pre, n+1)
+}
+
+//
+// ----------------- RELEASE NOTES -----------------
+//
+
+// Notes is the data available to the release notes and tag message
+// templates. Templates may also call {{jira .}} to link a Jira key and
+// {{join . ", "}} to join strings.
+type Notes struct {
+	App         string
+	Version     string
+	Tag         string
+	PreviousTag string             // tag (or, for a first release, commit) the release is compared against
+	Commits     []Commit           // in git log order
+	Sections    []changelogSection // non-empty Breaking / Features / Fixes / Other groups
+	Changelog   string             // the built-in Markdown rendering of Sections
+	CompareURL  string             // empty without Config.ProjectURL
+	PipelineURL string             // empty without Config.PipelineURL
+	Commit      string             // full SHA of the released commit
+	CommitURL   string             // empty without Config.ProjectURL
+	Footer      string             // compare, pipeline and commit links, appended to the built-in description
+}
+
+// webPrefix is what comes between the project URL and compare or commit in
+// the provider's web links: GitLab's /-/, or GitHub's plain /.
+func (r *Releaser) webPrefix() string {
+	if _, ok := r.provider.(*GitHubClient); ok {
+		return "/"
+	}
+	return "/-/"
+}
+
+// compareURL links the diff between two refs in the project.
+func (r *Releaser) compareURL(from, to string) string {
+	if r.cfg.ProjectURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s%scompare/%s...%s", strings.TrimSuffix(r.cfg.ProjectURL, "/"), r.webPrefix(), from, to)
+}
+
+// releaseURL links the release page of tag in the project.
+func (r *Releaser) releaseURL(tag string) string {
+	if r.cfg.ProjectURL == "" {
+		return ""
+	}
+	base := strings.TrimSuffix(r.cfg.ProjectURL, "/")
+	if _, ok := r.provider.(*GitHubClient); ok {
+		return base + "/releases/tag/" + escapeTag(tag)
+	}
+	return base + "/-/releases/" + url.PathEscape(tag)
+}
+
+// newNotes collects the template data for one release.
+func (r *Releaser) newNotes(rel release, previousTag string, commits []Commit, changelog string) Notes {
+	notes := Notes{
+		App:         rel.App,
+		Version:     rel.Version,
+		Tag:         rel.Tag,
+		PreviousTag: previousTag,
+		Commits:     commits,
+		Changelog:   changelog,
+		CompareURL:  r.compareURL(previousTag, rel.Tag),
+		PipelineURL: r.cfg.PipelineURL,
+		Commit:      rel.Commit,
+	}
+	if r.cfg.ProjectURL != "" && rel.Commit != "" {
+		notes.CommitURL = fmt.Sprintf("%s%scommit/%s", strings.TrimSuffix(r.cfg.ProjectURL, "/"), r.webPrefix(), rel.Commit)
+	}
+	notes.Footer = releaseFooter(notes)
+	for _, section := range groupCommits(commits) {
+		if len(section.Commits) > 0 {
+			notes.Sections = append(notes.Sections, section)
+		}
+	}
+	return notes
+}
+
+// releaseFooter renders the links people used to paste into every release by
+// hand: the compare view, the pipeline and the released commit. Links that
+// cannot be built outside CI are left out, and so is the whole footer when
+// nothing is known.
+func releaseFooter(n Notes) string {
+	var links []string
+	if n.CompareURL != "" {
+		links = append(links, fmt.Sprintf("**Compare:** [%s...%s](%s)", shortRef(n.PreviousTag), n.Tag, n.CompareURL))
+	}
+	if n.PipelineURL != "" {
+		links = append(links, fmt.Sprintf("**Pipeline:** %s", n.PipelineURL))
+	}
+	switch {
+	case n.CommitURL != "":
+		links = append(links, fmt.Sprintf("**Commit:** [%s](%s)", shortRef(n.Commit), n.CommitURL))
+	case n.Commit != "":
+		links = append(links, fmt.Sprintf("**Commit:** %s", n.Commit))
+	}
+	if len(links) == 0 {
+		return ""
+	}
+	return "---\n\n" + strings.Join(links, "  \n") + "\n"
+}
+
+// shortRef abbreviates full commit hashes the way git log --oneline does; tags are kept.
+func shortRef(ref string) string {
+	if len(ref) == 40 && strings.Trim(ref, "0123456789abcdef") == "" {
+		return ref[:8]
+	}
+	return ref
+}
+
+// renderTemplate executes tmpl with notes, or returns fallback when no template was given.
+func renderTemplate(tmpl *template.Template, notes Notes, fallback string) (string, error) {
+	if tmpl == nil {
+		return fallback, nil
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, notes); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+//
+// ----------------- CHANGELOG FILE -----------------
+//
+
+const changelogHeader = "# Changelog\n\n"
+
+// prependChangelog adds a "## <version> (<date>)" section to the top of the
+// changelog file at path, creating it if needed. It reports false when the
+// file already has a section for version, so reruns do not duplicate entries.
+func prependChangelog(path, version, changelog string, date time.Time) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if regexp.MustCompile(`(?m)^## ` + regexp.QuoteMeta(version) + `( |$)`).Match(existing) {
+		return false, nil
+	}
+
+	heading := fmt.Sprintf("## %s (%s)", version, date.Format("2006-01-02"))
+	rest := strings.TrimPrefix(string(existing), strings.TrimSpace(changelogHeader))
+	rest = strings.TrimLeft(rest, "\n")
+	content := changelogHeader + heading + "\n\n" + strings.TrimRight(changelog, "\n") + "\n"
+	if rest != "" {
+		content += "\n" + rest
+	}
+	return true, os.WriteFile(path, []byte(content), 0o644)
+}
+
+// publishChangelogs writes the changelog of every released app, commits all of
+// them together, and either pushes the commit to cfg.Branch or opens a merge
+// request for it. Tags keep pointing at the released commit.
+func (r *Releaser) publishChangelogs(ctx context.Context, results []Result) error {
+	var files, released []string
+	now := time.Now().UTC()
+	for _, res := range results {
+		// Candidates are folded into the final release's entry instead.
+		if !strings.HasPrefix(res.Status, "released") || isPrerelease(res.Version) {
+			continue
+		}
+		path := filepath.Join("apps", res.App, "CHANGELOG.md")
+		changed, err := prependChangelog(path, res.Version, res.Changelog, now)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		if changed {
+			files = append(files, path)
+			released = append(released, fmt.Sprintf("%s %s", res.App, res.Version))
+		}
+	}
+	if len(files) == 0 {
+		r.log.Info("no changelog files to update")
+		return nil
+	}
+
+	if _, err := r.git.Run(ctx, append([]string{"add", "--"}, files...)...); err != nil {
+		return err
+	}
+	title := "chore(release): update changelog for " + strings.Join(released, ", ")
+	commitArgs := []string{"commit", "-m", title + " [skip ci]"}
+	if r.cfg.AuthorName != "" {
+		commitArgs = append([]string{"-c", "user.name=" + r.cfg.AuthorName}, commitArgs...)
+	}
+	if r.cfg.AuthorEmail != "" {
+		commitArgs = append([]string{"-c", "user.email=" + r.cfg.AuthorEmail}, commitArgs...)
+	}
+	if _, err := r.git.Run(ctx, commitArgs...); err != nil {
+		return err
+	}
+
+	if r.cfg.Changelog == "commit" {
+		if _, err := r.git.Run(ctx, "push", "origin", "HEAD:refs/heads/"+r.cfg.Branch); err != nil {
+			return fmt.Errorf("failed to push changelog commit to %s: %w", r.cfg.Branch, err)
+		}
+		r.log.Info("pushed changelog commit", "branch", r.cfg.Branch, "files", files)
+		return nil
+	}
+
+	sha, err := r.git.Run(ctx, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return err
+	}
+	source := "changelog/" + sha
+	if _, err := r.git.Run(ctx, "push", "origin", "HEAD:refs/heads/"+source); err != nil {
+		return fmt.Errorf("failed to push changelog branch %s: %w", source, err)
+	}
+	webURL, err := r.provider.CreateMergeRequest(ctx, source, r.cfg.Branch, title)
+	if err != nil {
+		return err
+	}
+	r.log.Info("opened changelog merge request", "source", source, "target", r.cfg.Branch, "url", webURL)
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// releigh/gitlab.go
+// -----------------------------------------------------------------------------
+package releigh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider is the subset of the GitLab or GitHub API a release needs.
+type Provider interface {
+	// ReleaseExists reports whether a release already exists for tag.
+	ReleaseExists(ctx context.Context, tag string) (bool, error)
+	// CreateRelease uploads assets and creates rel with them attached; a
+	// release or asset that already exists counts as created.
+	CreateRelease(ctx context.Context, rel Release, assets []Asset) error
+	// AssetURL is the download URL file has once attached to rel.
+	AssetURL(rel Release, file string) string
+	// CreateMergeRequest opens a merge (or pull) request and returns its web URL.
+	CreateMergeRequest(ctx context.Context, source, target, title string) (string, error)
+}
+
+// Release is the body sent to the GitLab Releases API; GitHubClient maps it
+// onto GitHub's.
+type Release struct {
+	App         string         `json:"-"`
+	Version     string         `json:"-"`
+	Name        string         `json:"name"`
+	TagName     string         `json:"tag_name"`
+	Description string         `json:"description"`
+	ReleasedAt  *time.Time     `json:"released_at,omitempty"` // a future date makes GitLab list the release as upcoming
+	Assets      *ReleaseAssets `json:"assets,omitempty"`
+}
+
+// Asset is a file to attach to a release. Open is called once per attempt
+// so retries get a fresh body.
+type Asset struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+// ReleaseAssets lists the links attached to a release.
+type ReleaseAssets struct {
+	Links []AssetLink `json:"links"`
+}
+
+// AssetLink is one release asset link.
+type AssetLink struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	LinkType string `json:"link_type"`
+}
+
+// releasePayload builds the release for rel. Assets are linked to where the
+// provider will serve them. Pre-releases are dated Config.UpcomingFor ahead,
+// which is how GitLab marks a release as upcoming.
+func (r *Releaser) releasePayload(rel release, description string, assets []releaseAsset) Release {
+	payload := Release{
+		App:         rel.App,
+		Version:     rel.Version,
+		Name:        fmt.Sprintf("%s %s", rel.App, rel.Version),
+		TagName:     rel.Tag,
+		Description: description,
+	}
+	if r.cfg.UpcomingFor > 0 && isPrerelease(rel.Version) {
+		releasedAt := time.Now().UTC().Add(r.cfg.UpcomingFor).Truncate(time.Second)
+		payload.ReleasedAt = &releasedAt
+	}
+	if len(assets) > 0 {
+		payload.Assets = &ReleaseAssets{Links: make([]AssetLink, len(assets))}
+		for i, a := range assets {
+			link := AssetLink{Name: a.Name, LinkType: "package"}
+			if r.provider != nil {
+				link.URL = r.provider.AssetURL(payload, a.Name)
+			}
+			payload.Assets.Links[i] = link
+		}
+	}
+	return payload
+}
+
+//
+// ----------------- HTTP & RETRIES -----------------
+//
+
+// RetryPolicy controls how API calls are retried.
+type RetryPolicy struct {
+	Retries int           // attempts after the first one
+	Backoff time.Duration // initial delay, doubled after every attempt
+}
+
+// apiError is a non-2xx response from the GitLab, GitHub or Jira API.
+type apiError struct {
+	Status string
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("API returned an error\nStatus: %s\nResponse: %s", e.Status, e.Body)
+}
+
+func newAPIError(resp *http.Response) error {
+	respBody, _ := io.ReadAll(resp.Body)
+	return &apiError{Status: resp.Status, Body: string(respBody)}
+}
+
+// maxRetryDelay caps both the exponential backoff and any Retry-After value.
+const maxRetryDelay = time.Minute
+
+// send sends the request built by newReq, retrying network errors, timeouts,
+// 429 and 5xx responses up to p.Retries times with exponential backoff. A
+// Retry-After header overrides the computed delay. Any other response —
+// including every 4xx but 429 — is returned to the caller immediately, which
+// must close its body.
+func (p RetryPolicy) send(ctx context.Context, client *http.Client, log *slog.Logger, newReq func() (*http.Request, error)) (*http.Response, error) {
+	delay := p.Backoff
+	for attempt := 0; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create http request: %w", err)
+		}
+
+		var wait time.Duration
+		resp, err := client.Do(req.WithContext(ctx))
+		switch {
+		case err != nil:
+			if attempt >= p.Retries || ctx.Err() != nil {
+				return nil, fmt.Errorf("failed to send request after %d attempts: %w", attempt+1, err)
+			}
+			log.Warn("API request failed, retrying", "url", req.URL.String(), "attempt", attempt+1, "error", err)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			if attempt >= p.Retries {
+				defer resp.Body.Close()
+				return nil, fmt.Errorf("giving up after %d attempts: %w", attempt+1, newAPIError(resp))
+			}
+			if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = d
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			log.Warn("API returned a retryable status, retrying", "url", req.URL.String(), "status", resp.Status, "attempt", attempt+1)
+		default:
+			return resp, nil
+		}
+
+		if wait == 0 {
+			wait = delay
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(wait, maxRetryDelay)):
+		}
+		delay *= 2
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+//
+// ----------------- GITLAB API CLIENT -----------------
+//
+
+// GitLabClient is the GitLab implementation backed by the REST API.
+type GitLabClient struct {
+	serverURL string
+	projectID string
+	token     string
+	retry     RetryPolicy
+	log       *slog.Logger
+}
+
+// NewGitLabClient returns a client for one project. log may be nil.
+func NewGitLabClient(serverURL, projectID, token string, retry RetryPolicy, log *slog.Logger) *GitLabClient {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &GitLabClient{
+		serverURL: strings.TrimSuffix(serverURL, "/"),
+		projectID: projectID,
+		token:     token,
+		retry:     retry,
+		log:       log,
+	}
+}
+
+func (c *GitLabClient) projectURL(path string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s%s", c.serverURL, url.PathEscape(c.projectID), path)
+}
+
+// do sends an authenticated request; body is re-read on every attempt.
+func (c *GitLabClient) do(ctx context.Context, client *http.Client, method, apiURL string, body func() (io.ReadCloser, error), contentType string) (*http.Response, error) {
+	return c.retry.sendBody(ctx, client, c.log, method, apiURL, body, contentType, http.Header{"PRIVATE-TOKEN": {c.token}})
+}
+
+// sendBody is send for a request with header set and a body that is
+// re-read on every attempt; body may be nil.
+func (p RetryPolicy) sendBody(ctx context.Context, client *http.Client, log *slog.Logger, method, apiURL string, body func() (io.ReadCloser, error), contentType string, header http.Header) (*http.Response, error) {
+	var current io.ReadCloser
+	defer func() {
+		if current != nil {
+			current.Close()
+		}
+	}()
+	return p.send(ctx, client, log, func() (*http.Request, error) {
+		var reader io.Reader
+		if body != nil {
+			if current != nil {
+				current.Close()
+			}
+			rc, err := body()
+			if err != nil {
+				return nil, err
+			}
+			current, reader = rc, rc
+		}
+		req, err := http.NewRequest(method, apiURL, reader)
+		if err != nil {
+			return nil, err
+		}
+		// Sized bodies are sent with a Content-Length instead of chunked,
+		// which GitHub's asset uploads require.
+		switch b := current.(type) {
+		case byteBody:
+			req.ContentLength = int64(b.Len())
+		case *os.File:
+			if fi, err := b.Stat(); err == nil {
+				req.ContentLength = fi.Size()
+			}
+		}
+		for k, vs := range header {
+			for _, v := range vs {
+				req.Header.Add(k, v)
+			}
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req, nil
+	})
+}
+
+// byteBody is an in-memory request body.
+type byteBody struct{ *bytes.Reader }
+
+func (byteBody) Close() error { return nil }
+
+// jsonBody marshals payload once and returns a body func for do.
+func jsonBody(payload any) (func() (io.ReadCloser, error), error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) { return byteBody{bytes.NewReader(body)}, nil }, nil
+}
+
+// ReleaseExists implements Provider.
+func (c *GitLabClient) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := c.do(ctx, client, "GET", c.projectURL("/releases/"+url.PathEscape(tag)), nil, "")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode >= 300:
+		return false, newAPIError(resp)
+	}
+	return true, nil
+}
+
+// CreateRelease implements Provider. The assets go to the generic package
+// registry first, into a package named after the app and versioned with the
+// release version, so the release's links work from the start.
+func (c *GitLabClient) CreateRelease(ctx context.Context, rel Release, assets []Asset) error {
+	for _, a := range assets {
+		c.log.Info("uploading release asset", "name", a.Name)
+		if err := c.UploadPackageFile(ctx, rel.App, rel.Version, a.Name, a.Open); err != nil {
+			return fmt.Errorf("failed to upload asset %s: %w", a.Name, err)
+		}
+	}
+
+	apiURL := c.projectURL("/releases")
+	body, err := jsonBody(rel)
+	if err != nil {
+		return fmt.Errorf("failed to marshal release payload: %w", err)
+	}
+
+	c.log.Info("creating GitLab release", "url", apiURL, "title", rel.Name)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := c.do(ctx, client, "POST", apiURL, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A retried POST whose first attempt reached GitLab comes back as a conflict.
+	if resp.StatusCode == http.StatusConflict {
+		c.log.Warn("GitLab release already exists, treating as created", "tag", rel.TagName)
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// AssetURL implements Provider.
+func (c *GitLabClient) AssetURL(rel Release, file string) string {
+	return c.PackageFileURL(rel.App, rel.Version, file)
+}
+
+// PackageFileURL is the download URL of a file in the generic package registry.
+func (c *GitLabClient) PackageFileURL(pkg, version, file string) string {
+	return c.projectURL(fmt.Sprintf("/packages/generic/%s/%s/%s",
+		url.PathEscape(pkg), url.PathEscape(version), url.PathEscape(file)))
+}
+
+// UploadPackageFile uploads a file to the generic package registry.
+func (c *GitLabClient) UploadPackageFile(ctx context.Context, pkg, version, file string, open func() (io.ReadCloser, error)) error {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := c.do(ctx, client, "PUT", c.PackageFileURL(pkg, version, file), open, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// CreateMergeRequest implements Provider.
+func (c *GitLabClient) CreateMergeRequest(ctx context.Context, source, target, title string) (string, error) {
+	body, err := jsonBody(map[string]any{
+		"source_branch":        source,
+		"target_branch":        target,
+		"title":                title,
+		"remove_source_branch": true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal merge request payload: %w", err)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := c.do(ctx, client, "POST", c.projectURL("/merge_requests"), body, "application/json")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", newAPIError(resp)
+	}
+	var mr struct {
+		WebURL string `json:"web_url"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&mr)
+	return mr.WebURL, nil
+}
+
+// -----------------------------------------------------------------------------
+// releigh/github.go
+// -----------------------------------------------------------------------------
+package releigh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The providers a project can release on.
+const (
+	ProviderGitLab = "gitlab"
+	ProviderGitHub = "github"
+)
+
+// ParseRemote splits a clone URL (https, ssh:// or scp-like git@host:path)
+// into its host and project path, e.g. github.com and org/repo; both are
+// empty for local remotes.
+func ParseRemote(remote string) (host, path string) {
+	if u, err := url.Parse(remote); err == nil && u.Scheme != "" {
+		host, path = u.Hostname(), u.Path
+	} else if h, p, ok := strings.Cut(remote, ":"); ok && !strings.Contains(h, "/") {
+		if _, afterAt, found := strings.Cut(h, "@"); found {
+			h = afterAt
+		}
+		host, path = h, p
+	}
+	return host, strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+}
+
+// ProviderForHost is the provider a git host is: GitHub for github.com and
+// GitHub Enterprise hosts named github.*, GitLab for every other one.
+func ProviderForHost(host string) string {
+	if host == "github.com" || strings.HasPrefix(host, "github.") {
+		return ProviderGitHub
+	}
+	return ProviderGitLab
+}
+
+//
+// ----------------- GITHUB API CLIENT -----------------
+//
+
+// DefaultGitHubURL is the GitHub a GitHubClient talks to unless told otherwise.
+const DefaultGitHubURL = "https://github.com"
+
+// GitHubClient is the GitHub implementation backed by the REST API. Assets
+// are uploaded to the release itself, and pre-releases are marked as such
+// rather than dated ahead: GitHub has no upcoming releases.
+type GitHubClient struct {
+	apiURL string
+	webURL string
+	repo   string // owner/name
+	token  string
+	retry  RetryPolicy
+	log    *slog.Logger
+}
+
+// NewGitHubClient returns a client for the repository repo ("owner/name")
+// on the GitHub at serverURL: DefaultGitHubURL if empty, or a GitHub
+// Enterprise Server. log may be nil.
+func NewGitHubClient(serverURL, repo, token string, retry RetryPolicy, log *slog.Logger) *GitHubClient {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	webURL := strings.TrimSuffix(serverURL, "/")
+	if webURL == "" {
+		webURL = DefaultGitHubURL
+	}
+	apiURL := webURL + "/api/v3"
+	if webURL == DefaultGitHubURL {
+		apiURL = "https://api.github.com"
+	}
+	return &GitHubClient{
+		apiURL: apiURL,
+		webURL: webURL,
+		repo:   strings.Trim(repo, "/"),
+		token:  token,
+		retry:  retry,
+		log:    log,
+	}
+}
+
+func (c *GitHubClient) repoURL(path string) string {
+	return fmt.Sprintf("%s/repos/%s%s", c.apiURL, c.repo, path)
+}
+
+// do sends an authenticated request; body is re-read on every attempt.
+func (c *GitHubClient) do(ctx context.Context, client *http.Client, method, apiURL string, body func() (io.ReadCloser, error), contentType string) (*http.Response, error) {
+	return c.retry.sendBody(ctx, client, c.log, method, apiURL, body, contentType, http.Header{
+		"Authorization":        {"Bearer " + c.token},
+		"Accept":               {"application/vnd.github+json"},
+		"X-GitHub-Api-Version": {"2022-11-28"},
+	})
+}
+
+// githubRelease is the part of a GitHub release a run reads back.
+type githubRelease struct {
+	UploadURL string `json:"upload_url"` // RFC 6570 template, e.g. …/assets{?name,label}
+	Assets    []struct {
+		Name string `json:"name"`
+	} `json:"assets"`
+}
+
+// escapeTag escapes every segment of a tag like billing/1.4.0, keeping the slashes.
+func escapeTag(tag string) string {
+	segments := strings.Split(tag, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// releaseByTag is the release of tag, or nil if there is none.
+func (c *GitHubClient) releaseByTag(ctx context.Context, tag string) (*githubRelease, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := c.do(ctx, client, "GET", c.repoURL("/releases/tags/"+escapeTag(tag)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil
+	case resp.StatusCode >= 300:
+		return nil, newAPIError(resp)
+	}
+	var rel githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub release: %w", err)
+	}
+	return &rel, nil
+}
+
+// ReleaseExists implements Provider.
+func (c *GitHubClient) ReleaseExists(ctx context.Context, tag string) (bool, error) {
+	rel, err := c.releaseByTag(ctx, tag)
+	return rel != nil, err
+}
+
+// CreateRelease implements Provider. GitHub takes assets only once the
+// release exists, so they are uploaded after creating it; a rerun finds the
+// release and uploads the assets it is still missing.
+func (c *GitHubClient) CreateRelease(ctx context.Context, rel Release, assets []Asset) error {
+	body, err := jsonBody(map[string]any{
+		"tag_name":   rel.TagName,
+		"name":       rel.Name,
+		"body":       rel.Description,
+		"prerelease": isPrerelease(rel.Version),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal release payload: %w", err)
+	}
+
+	c.log.Info("creating GitHub release", "repo", c.repo, "title", rel.Name)
 
 	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := c.do(ctx, client, "POST", c.repoURL("/releases"), body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var created githubRelease
+	switch {
+	// A retried POST whose first attempt reached GitHub fails validation: the tag already has a release.
+	case resp.StatusCode == http.StatusUnprocessableEntity:
+		existing, err := c.releaseByTag(ctx, rel.TagName)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return newAPIError(resp)
+		}
+		c.log.Warn("GitHub release already exists, treating as created", "tag", rel.TagName)
+		created = *existing
+	case resp.StatusCode >= 300:
+		return newAPIError(resp)
+	default:
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			return fmt.Errorf("failed to decode GitHub release: %w", err)
+		}
+	}
+
+	uploaded := make(map[string]bool, len(created.Assets))
+	for _, a := range created.Assets {
+		uploaded[a.Name] = true
+	}
+	for _, a := range assets {
+		if uploaded[a.Name] {
+			c.log.Info("release asset already uploaded", "name", a.Name)
+			continue
+		}
+		c.log.Info("uploading release asset", "name", a.Name)
+		if err := c.uploadAsset(ctx, created.UploadURL, a); err != nil {
+			return fmt.Errorf("failed to upload asset %s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// uploadAsset attaches a to the release whose upload_url is uploadURL.
+func (c *GitHubClient) uploadAsset(ctx context.Context, uploadURL string, a Asset) error {
+	base, _, _ := strings.Cut(uploadURL, "{")
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := c.do(ctx, client, "POST", base+"?name="+url.QueryEscape(a.Name), a.Open, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Uploaded by an earlier attempt that timed out waiting for the answer.
+	if resp.StatusCode == http.StatusUnprocessableEntity {
+		c.log.Warn("release asset already exists, treating as uploaded", "name", a.Name)
+		return nil
+	}
+	if resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// AssetURL implements Provider.
+func (c *GitHubClient) AssetURL(rel Release, file string) string {
+	return fmt.Sprintf("%s/%s/releases/download/%s/%s", c.webURL, c.repo, escapeTag(rel.TagName), url.PathEscape(file))
+}
+
+// CreateMergeRequest implements Provider with a pull request; GitHub leaves
+// deleting the merged branch to the repository's settings.
+func (c *GitHubClient) CreateMergeRequest(ctx context.Context, source, target, title string) (string, error) {
+	body, err := jsonBody(map[string]any{
+		"head":  source,
+		"base":  target,
+		"title": title,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal pull request payload: %w", err)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := c.do(ctx, client, "POST", c.repoURL("/pulls"), body, "application/json")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", newAPIError(resp)
+	}
+	var pr struct {
+		HTMLURL string `json:"html_url"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&pr)
+	return pr.HTMLURL, nil
+}
+
+// -----------------------------------------------------------------------------
+// releigh/assets.go
+// -----------------------------------------------------------------------------
+package releigh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//
+// ----------------- RELEASE ASSETS -----------------
+//
+
+// checksumsAssetName is the asset the updater reads to verify downloads.
+const checksumsAssetName = "checksums.sha256"
+
+// releaseAsset is one file attached to a release. Data is set instead of Path
+// for generated assets such as the checksums file.
+type releaseAsset struct {
+	Name   string
+	Path   string
+	Data   []byte
+	SHA256 string
+}
+
+// open returns a fresh reader over the asset's contents.
+func (a releaseAsset) open() (io.ReadCloser, error) {
+	if a.Path == "" {
+		return byteBody{bytes.NewReader(a.Data)}, nil
+	}
+	f, err := os.Open(a.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open asset: %w", err)
+	}
+	return f, nil
+}
+
+// collectAssets resolves the Assets spec for an app and hashes every file.
+// A checksums.sha256 asset ("<sha256>  <name>" per line) is appended whenever
+// at least one file matched.
+func collectAssets(spec, app string) ([]releaseAsset, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	spec = strings.ReplaceAll(spec, "{app}", app)
+
+	var paths []string
+	if manifest, ok := strings.CutPrefix(spec, "@"); ok {
+		raw, err := os.ReadFile(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read asset manifest: %w", err)
+		}
+		for _, line := range strings.Split(string(raw), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			paths = append(paths, strings.ReplaceAll(line, "{app}", app))
+		}
+	} else {
+		matches, err := filepath.Glob(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset glob %q: %w", spec, err)
+		}
+		paths = matches
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no assets matched %q", spec)
+	}
+	sort.Strings(paths)
+
+	assets := make([]releaseAsset, 0, len(paths)+1)
+	seen := make(map[string]string)
+	var sums strings.Builder
+	for _, p := range paths {
+		name := filepath.Base(p)
+		if other, dup := seen[name]; dup {
+			return nil, fmt.Errorf("assets %s and %s share the file name %s", other, p, name)
+		}
+		seen[name] = p
+
+		sum, err := sha256File(p)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, releaseAsset{Name: name, Path: p, SHA256: sum})
+		fmt.Fprintf(&sums, "%s  %s\n", sum, name)
+	}
+
+	data := []byte(sums.String())
+	digest := sha256.Sum256(data)
+	assets = append(assets, releaseAsset{Name: checksumsAssetName, Data: data, SHA256: hex.EncodeToString(digest[:])})
+	return assets, nil
+}
+
+// sha256File returns the hex-encoded SHA-256 of a file.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open asset: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash asset %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// -----------------------------------------------------------------------------
+// releigh/jira.go
+// -----------------------------------------------------------------------------
+package releigh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//
+// ----------------- JIRA -----------------
+//
+
+// Jira is the subset of the Jira API used to follow up on released issues.
+type Jira interface {
+	// Transition moves an issue through the transition whose name (or target
+	// status) matches name. It reports false when no such transition is
+	// available, which usually means the issue is already there.
+	Transition(ctx context.Context, key, name string) (bool, error)
+	// Comment adds a plain-text comment to an issue.
+	Comment(ctx context.Context, key, text string) error
+}
+
+// JiraClient is the Jira implementation backed by the REST API (v2).
+type JiraClient struct {
+	baseURL string
+	user    string
+	token   string
+	retry   RetryPolicy
+	log     *slog.Logger
+}
+
+// NewJiraClient returns a client authenticating with basic auth. log may be nil.
+func NewJiraClient(baseURL, user, token string, retry RetryPolicy, log *slog.Logger) *JiraClient {
+	if log == nil {
+		log = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &JiraClient{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		user:    user,
+		token:   token,
+		retry:   retry,
+		log:     log,
+	}
+}
+
+// request sends an authenticated Jira REST call, retried like the GitLab ones.
+func (c *JiraClient) request(ctx context.Context, method, path string, payload any) (*http.Response, error) {
+	apiURL := c.baseURL + path
+	var body []byte
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("failed to marshal Jira payload: %w", err)
+		}
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	return c.retry.send(ctx, client, c.log, func() (*http.Request, error) {
+		req, err := http.NewRequest(method, apiURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.user, c.token)
+		req.Header.Set("Accept", "application/json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	})
+}
+
+// Transition implements Jira.
+func (c *JiraClient) Transition(ctx context.Context, key, name string) (bool, error) {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+	resp, err := c.request(ctx, "GET", path, nil)
 	if err != nil {
-		return fmt.Errorf("failed to send request to GitLab API: %w", err)
+		return false, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return false, newAPIError(resp)
+	}
+
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&available); err != nil {
+		return false, fmt.Errorf("failed to decode transitions: %w", err)
+	}
+
+	for _, t := range available.Transitions {
+		if !strings.EqualFold(t.Name, name) && !strings.EqualFold(t.To.Name, name) {
+			continue
+		}
+		payload := map[string]any{"transition": map[string]string{"id": t.ID}}
+		resp, err := c.request(ctx, "POST", path, payload)
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return false, newAPIError(resp)
+		}
+		return true, nil
+	}
+	return false, nil
+}
 
+// Comment implements Jira.
+func (c *JiraClient) Comment(ctx context.Context, key, text string) error {
+	resp, err := c.request(ctx, "POST", "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": text})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("GitLab API returned an error\nStatus: %s\nResponse: %s", resp.Status, string(respBody))
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// updateJiraIssues transitions and comments on every Jira issue referenced by
+// the released commits. Each issue is attempted even if an earlier one failed.
+func (r *Releaser) updateJiraIssues(ctx context.Context, rel release, commits []Commit) error {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, c := range commits {
+		for _, id := range c.JiraIDs {
+			if !seen[id] {
+				seen[id] = true
+				keys = append(keys, id)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	comment := fmt.Sprintf("Released in %s %s.", rel.App, rel.Version)
+	if link := r.releaseURL(rel.Tag); link != "" {
+		comment += " Release notes: " + link
+	}
+
+	var errs []error
+	for _, key := range keys {
+		moved, err := r.jira.Transition(ctx, key, r.cfg.JiraTransition)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: transition: %w", key, err))
+			continue
+		}
+		if !moved {
+			r.log.Info("Jira transition not available, leaving status as is", "issue", key, "transition", r.cfg.JiraTransition)
+		}
+		if err := r.jira.Comment(ctx, key, comment); err != nil {
+			errs = append(errs, fmt.Errorf("%s: comment: %w", key, err))
+			continue
+		}
+		r.log.Info("updated Jira issue", "issue", key, "transitioned", moved)
 	}
+	return errors.Join(errs...)
+}
+
+// -----------------------------------------------------------------------------
+// releigh/releigh_test.go (unit tests)
+// -----------------------------------------------------------------------------
+//go:build unit
+// +build unit
+
+package releigh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTag is a tag in fakeGit; tags are kept oldest first.
+type fakeTag struct{ name, commit string }
+
+// fakeGit answers the handful of git commands a release runs and records the rest.
+type fakeGit struct {
+	tags    []fakeTag
+	remote  map[string]bool
+	log     string
+	head    string
+	created []string
+	pushed  []string
+}
+
+func (g *fakeGit) Run(_ context.Context, args ...string) (string, error) {
+	switch args[0] {
+	case "fetch":
+		return "", nil
+	case "rev-parse":
+		if args[1] == "HEAD" {
+			return g.head, nil
+		}
+		if g.tagCommit(strings.TrimPrefix(args[len(args)-1], "refs/tags/")) != "" {
+			return "", nil
+		}
+		return "", io.EOF
+	case "rev-list":
+		return g.tagCommit(args[len(args)-1]), nil
+	case "for-each-ref":
+		var out []string
+		for i := len(g.tags) - 1; i >= 0; i-- {
+			out = append(out, g.tags[i].name)
+		}
+		return strings.Join(out, "\n"), nil
+	case "log":
+		if args[1] == "--reverse" {
+			return "first", nil
+		}
+		return g.log, nil
+	case "ls-remote":
+		if g.remote[strings.TrimPrefix(args[len(args)-1], "refs/tags/")] {
+			return "sha\trefs/tags/x", nil
+		}
+		return "", nil
+	case "tag":
+		tag := args[2]
+		g.tags = append(g.tags, fakeTag{tag, g.head})
+		g.created = append(g.created, tag)
+		return "", nil
+	case "push":
+		g.pushed = append(g.pushed, args[2])
+		return "", nil
+	}
+	return "", nil
+}
+
+func (g *fakeGit) tagCommit(name string) string {
+	for _, t := range g.tags {
+		if t.name == name {
+			return t.commit
+		}
+	}
+	return ""
+}
+
+// fakeGitLab records the releases it is asked to create.
+type fakeGitLab struct {
+	existing map[string]bool
+	created  []Release
+}
+
+func (f *fakeGitLab) ReleaseExists(_ context.Context, tag string) (bool, error) {
+	return f.existing[tag], nil
+}
 
-	logger.Info("GitLab release created successfully", "status", resp.Status)
+func (f *fakeGitLab) CreateRelease(_ context.Context, rel Release, _ []Asset) error {
+	f.created = append(f.created, rel)
 	return nil
 }
+
+func (f *fakeGitLab) AssetURL(rel Release, file string) string {
+	return "https://gitlab.example/" + rel.App + "/" + rel.Version + "/" + file
+}
+
+func (f *fakeGitLab) CreateMergeRequest(context.Context, string, string, string) (string, error) {
+	return "", nil
+}
+
+var testGraph = map[string]Project{
+	":apps:billing": {ProjectDir: "apps/billing", Dependencies: []string{":libs:common"}},
+	":libs:common":  {ProjectDir: "libs/common"},
+}
+
+func TestReleaseCreatesTagAndRelease(t *testing.T) {
+	git := &fakeGit{
+		tags:   []fakeTag{{"billing/1.0.0", "old"}},
+		remote: map[string]bool{},
+		head:   "abc",
+		log:    "7f4d2f8\x1ffeat(api): add invoices PAY-12\x1f\x1e",
+	}
+	gl := &fakeGitLab{}
+	r, err := New(Config{Apps: []string{"billing"}, Graph: testGraph, AutoBump: true}, git, gl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Status != "released" || results[0].Tag != "billing/1.1.0" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if len(git.pushed) != 1 || git.pushed[0] != "billing/1.1.0" {
+		t.Fatalf("expected billing/1.1.0 to be pushed, got %v", git.pushed)
+	}
+	if len(gl.created) != 1 || !strings.Contains(gl.created[0].Description, "**api:** add invoices") {
+		t.Fatalf("unexpected releases: %+v", gl.created)
+	}
+	if strings.Join(results[0].Paths, " ") != "apps/billing libs/common" {
+		t.Fatalf("expected the app and its library as paths, got %v", results[0].Paths)
+	}
+}
+
+func TestReleaseFooterLinks(t *testing.T) {
+	git := &fakeGit{
+		tags:   []fakeTag{{"billing/1.0.0", "old"}},
+		remote: map[string]bool{},
+		head:   "0123456789abcdef0123456789abcdef01234567",
+		log:    "7f4d2f8\x1ffix: rounding\x1f\x1e",
+	}
+	gl := &fakeGitLab{}
+	cfg := Config{
+		Apps:        []string{"billing"},
+		Graph:       testGraph,
+		Version:     "1.0.1",
+		ProjectURL:  "https://gitlab.example/grp/mono",
+		PipelineURL: "https://gitlab.example/grp/mono/-/pipelines/42",
+	}
+	r, err := New(cfg, git, gl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "https://gitlab.example/grp/mono/-/releases/billing%2F1.0.1"; results[0].URL != want {
+		t.Errorf("got release URL %q, want %q", results[0].URL, want)
+	}
+	for _, want := range []string{
+		"[billing/1.0.0...billing/1.0.1](https://gitlab.example/grp/mono/-/compare/billing/1.0.0...billing/1.0.1)",
+		"**Pipeline:** https://gitlab.example/grp/mono/-/pipelines/42",
+		"[01234567](https://gitlab.example/grp/mono/-/commit/0123456789abcdef0123456789abcdef01234567)",
+	} {
+		if !strings.Contains(gl.created[0].Description, want) {
+			t.Errorf("description is missing %q:\n%s", want, gl.created[0].Description)
+		}
+	}
+}
+
+func TestAlreadyReleasedIsSkipped(t *testing.T) {
+	git := &fakeGit{
+		tags:   []fakeTag{{"billing/1.0.0", "old"}, {"billing/1.1.0", "abc"}},
+		remote: map[string]bool{"billing/1.1.0": true},
+		head:   "abc",
+		log:    "7f4d2f8\x1ffix: rounding\x1f\x1e",
+	}
+	gl := &fakeGitLab{existing: map[string]bool{"billing/1.1.0": true}}
+	r, err := New(Config{Apps: []string{"billing"}, Graph: testGraph, Version: "1.1.0"}, git, gl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Status != "already released" {
+		t.Fatalf("expected already released, got %q", results[0].Status)
+	}
+	if len(git.created)+len(git.pushed)+len(gl.created) != 0 {
+		t.Fatalf("expected no side effects, got tags %v pushes %v releases %v", git.created, git.pushed, gl.created)
+	}
+}
+
+func TestReleaseCandidates(t *testing.T) {
+	git := &fakeGit{
+		tags:   []fakeTag{{"billing/1.0.0", "a"}, {"billing/1.1.0-rc.1", "b"}, {"billing/1.1.0-rc.2", "c"}},
+		remote: map[string]bool{},
+		head:   "d",
+		log:    "7f4d2f8\x1ffeat: add invoices\x1f\x1e",
+	}
+	gl := &fakeGitLab{}
+	r, err := New(Config{Apps: []string{"billing"}, Graph: testGraph, AutoBump: true, Prerelease: "rc", UpcomingFor: time.Hour}, git, gl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	results, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Tag != "billing/1.1.0-rc.3" || results[0].PreviousTag != "billing/1.1.0-rc.2" {
+		t.Fatalf("expected rc.3 after rc.2, got %+v", results[0])
+	}
+	if gl.created[0].ReleasedAt == nil || !gl.created[0].ReleasedAt.After(time.Now()) {
+		t.Fatalf("expected the candidate to be dated in the future, got %v", gl.created[0].ReleasedAt)
+	}
+
+	// The final release skips the candidates and compares against 1.0.0.
+	r, err = New(Config{Apps: []string{"billing"}, Graph: testGraph, AutoBump: true}, git, gl)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	git.head = "e"
+	if results, err = r.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Tag != "billing/1.1.0" || results[0].PreviousTag != "billing/1.0.0" {
+		t.Fatalf("expected final 1.1.0 against 1.0.0, got %+v", results[0])
+	}
+	if gl.created[1].ReleasedAt != nil {
+		t.Fatalf("final releases must not be upcoming, got %v", gl.created[1].ReleasedAt)
+	}
+}
+
+func TestGitHubClientUploadsAssetsToTheRelease(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls = append(calls, req.Method+" "+req.URL.RequestURI())
+		switch {
+		case req.URL.Path == "/api/v3/repos/org/mono/releases":
+			var body map[string]any
+			json.NewDecoder(req.Body).Decode(&body)
+			if body["tag_name"] != "billing/1.1.0-rc.1" || body["prerelease"] != true {
+				t.Errorf("unexpected release payload: %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"upload_url": "http://%s/api/uploads/repos/org/mono/releases/7/assets{?name,label}"}`, req.Host)
+		case strings.HasPrefix(req.URL.Path, "/api/uploads/"):
+			if req.ContentLength != 5 {
+				t.Errorf("expected the asset to be sent with its length, got %d", req.ContentLength)
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewGitHubClient(srv.URL, "org/mono", "token", RetryPolicy{}, nil)
+	rel := Release{App: "billing", Version: "1.1.0-rc.1", Name: "billing 1.1.0-rc.1", TagName: "billing/1.1.0-rc.1"}
+	asset := Asset{Name: "billing.tar.gz", Open: func() (io.ReadCloser, error) {
+		return byteBody{bytes.NewReader([]byte("hello"))}, nil
+	}}
+	if err := c.CreateRelease(context.Background(), rel, []Asset{asset}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		"POST /api/v3/repos/org/mono/releases",
+		"POST /api/uploads/repos/org/mono/releases/7/assets?name=billing.tar.gz",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got calls %q, want %q", calls, want)
+	}
+	if got := c.AssetURL(rel, asset.Name); got != srv.URL+"/org/mono/releases/download/billing/1.1.0-rc.1/billing.tar.gz" {
+		t.Errorf("unexpected asset URL %s", got)
+	}
+}
+
+func TestParseRemote(t *testing.T) {
+	cases := []struct{ remote, host, path, provider string }{
+		{"git@github.com:org/mono.git", "github.com", "org/mono", ProviderGitHub},
+		{"https://github.example.com/org/mono.git", "github.example.com", "org/mono", ProviderGitHub},
+		{"ssh://git@gitlab.example.com:2222/platform/asgard.git", "gitlab.example.com", "platform/asgard", ProviderGitLab},
+		{"/srv/git/mono.git", "", "", ProviderGitLab},
+	}
+	for _, c := range cases {
+		host, path := ParseRemote(c.remote)
+		if host != c.host || path != c.path || ProviderForHost(host) != c.provider {
+			t.Errorf("%s: got %s %s %s, want %s %s %s", c.remote, host, path, ProviderForHost(host), c.host, c.path, c.provider)
+		}
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	cases := []struct {
+		previous string
+		commits  []Commit
+		want     string
+	}{
+		{"billing/1.2.3", []Commit{{Type: "fix"}}, "1.2.4"},
+		{"billing/v1.2.3", []Commit{{Type: "fix"}, {Type: "feat"}}, "v1.3.0"},
+		{"billing/1.2.3", []Commit{{Type: "feat"}, {Breaking: true}}, "2.0.0"},
+		{"0a1b2c3", []Commit{{Type: "feat"}}, "0.1.0"},
+		{"billing/1.1.0-rc.2", []Commit{{Type: "fix"}}, "1.1.0"},
+		{"billing/1.1.0-rc.2", []Commit{{Breaking: true}}, "2.0.0"},
+	}
+	for _, c := range cases {
+		got, err := nextVersion("billing", c.previous, c.commits)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.previous, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: got %s, want %s", c.previous, got, c.want)
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// cmd/release/main.go
+// -----------------------------------------------------------------------------
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourorg/tool/releigh"
+)
+
+//
+// ----------------- LOGGER SETUP -----------------
+//
+
+const (
+	reset = "\033[0m"
+	red   = "\033[31m"
+	yel   = "\033[33m"
+	gre   = "\033[32m"
+	blu   = "\033[34m"
+)
+
+// colorHandler is a simple slog.Handler that adds color to log levels for console readability.
+type colorHandler struct{ slog.Handler }
+
+func (h colorHandler) Handle(ctx context.Context, r slog.Record) error {
+	var color string
+	switch r.Level {
+	case slog.LevelError:
+		color = red
+	case slog.LevelWarn:
+		color = yel
+	case slog.LevelInfo:
+		color = gre
+	default: // Debug
+		color = blu
+	}
+	fmt.Fprint(os.Stderr, color)
+	err := h.Handler.Handle(ctx, r) // delegate actual formatting
+	fmt.Fprint(os.Stderr, reset)
+	return err
+}
+
+var logger *slog.Logger
+
+// init initializes a structured logger for the application.
+func init() {
+	base := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	logger = slog.New(colorHandler{base})
+}
+
+//
+// ----------------- MAIN EXECUTION FLOW -----------------
+//
+
+func main() {
+	if err := run(); err != nil {
+		logger.Error("release script failed", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("release script completed successfully")
+}
+
+// run maps flags and CI variables onto a releigh.Config and releases every app.
+func run() error {
+	dryRun := flag.Bool("dry-run", false, "print the release payload without tagging, pushing, or calling the GitLab or GitHub API")
+	autoBump := flag.Bool("auto-bump", false, "compute the next version from conventional commits instead of reading RELEASE_VERSION")
+	suggest := flag.Bool("suggest-version", false, "print the next version computed from conventional commits and exit")
+	prerelease := flag.String("prerelease", "", `with --auto-bump or --suggest-version, cut the next release candidate of this series (e.g. "rc" for 1.4.0-rc.2)`)
+	upcomingFor := flag.Duration("upcoming-for", 14*24*time.Hour, "date pre-releases this far ahead so GitLab lists them as upcoming releases; 0 disables")
+	allAffected := flag.Bool("all-affected", false, "release every app affected by the changes since --since instead of naming apps")
+	since := flag.String("since", "", "base ref for --all-affected (default: the previous commit)")
+	provider := flag.String("provider", "", "where to create the releases: gitlab or github (default: github for an origin remote on github.com or github.*, gitlab otherwise); github needs GITHUB_TOKEN and takes the repository from GITHUB_REPOSITORY or the remote")
+	retries := flag.Int("retries", 4, "retry GitLab or GitHub API calls this many times on 429, 5xx, or network errors")
+	retryBackoff := flag.Duration("retry-backoff", 2*time.Second, "initial delay between API retries; doubles each attempt")
+	notesTemplate := flag.String("notes-template", "", "Go text/template file for the release description (see releigh.Notes for the available fields)")
+	tagMessage := flag.String("tag-message", "", "Go text/template for the tag message, with the same fields as --notes-template")
+	signTags := flag.Bool("sign-tags", false, "GPG-sign release tags; a key may be supplied via RELEASE_GPG_PRIVATE_KEY (and RELEASE_GPG_KEY_ID)")
+	jiraTransition := flag.String("jira-transition", "", `after releasing, move the changelog's Jira issues through this transition (e.g. "Released") and comment on them; needs JIRA_BASE_URL, JIRA_USER, JIRA_API_TOKEN`)
+	changelogMode := flag.String("changelog", "", "prepend each release to apps/<app>/CHANGELOG.md and push it: commit (to the current branch) or mr (via a merge request)")
+	assets := flag.String("assets", "", "glob of files to attach to each release, or @file listing one path per line; {app} expands to the app name")
+	output := flag.String("output", "text", "text, or json: print every app's tag, release URL, changelog and paths as a JSON array on stdout for later jobs")
+	flag.Parse()
+
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("usage: --output must be text or json, got %q", *output)
+	}
+
+	if flag.NArg() < 1 && !*allAffected {
+		return fmt.Errorf("usage: %s [--dry-run] [--auto-bump | --suggest-version] [--prerelease rc] (--all-affected [--since ref] | <app-name>...)", os.Args[0])
+	}
+
+	branch := os.Getenv("CI_COMMIT_BRANCH")
+	if branch == "" {
+		branch = os.Getenv("CI_DEFAULT_BRANCH")
+	}
+	cfg := releigh.Config{
+		Apps:           flag.Args(),
+		AllAffected:    *allAffected,
+		Since:          *since,
+		Version:        os.Getenv("RELEASE_VERSION"),
+		DryRun:         *dryRun,
+		AutoBump:       *autoBump,
+		SuggestVersion: *suggest,
+		Prerelease:     *prerelease,
+		UpcomingFor:    *upcomingFor,
+		Assets:         *assets,
+		Changelog:      *changelogMode,
+		TagMessage:     *tagMessage,
+		SignTags:       *signTags,
+		SigningKey:     os.Getenv("RELEASE_GPG_KEY_ID"),
+		JiraTransition: *jiraTransition,
+		ProjectURL:     os.Getenv("CI_PROJECT_URL"),
+		PipelineURL:    os.Getenv("CI_PIPELINE_URL"),
+		JiraBaseURL:    os.Getenv("JIRA_BASE_URL"),
+		Branch:         branch,
+		// GitLab exposes the user who started the pipeline; git config is used otherwise.
+		AuthorName:  os.Getenv("GITLAB_USER_NAME"),
+		AuthorEmail: os.Getenv("GITLAB_USER_EMAIL"),
+	}
+	if cfg.Version == "" && !cfg.AutoBump && !cfg.SuggestVersion {
+		return fmt.Errorf("configuration error: RELEASE_VERSION environment variable is not set (or pass --auto-bump)")
+	}
+
+	if *notesTemplate != "" {
+		src, err := os.ReadFile(*notesTemplate)
+		if err != nil {
+			return fmt.Errorf("configuration error: could not read release notes template: %w", err)
+		}
+		cfg.NotesTemplate = string(src)
+	}
+
+	graph, err := releigh.LoadGraph("build/dependency-graph.json")
+	if err != nil {
+		return fmt.Errorf("configuration error: could not load project graph: %w", err)
+	}
+	cfg.Graph = graph
+
+	// The provider and Jira settings are only needed when we actually talk to the APIs.
+	publishing := !cfg.DryRun && !cfg.SuggestVersion
+	retry := releigh.RetryPolicy{Retries: *retries, Backoff: *retryBackoff}
+	if publishing && cfg.Changelog != "" && cfg.Branch == "" {
+		return fmt.Errorf("configuration error: CI_COMMIT_BRANCH (or CI_DEFAULT_BRANCH) must be set to publish changelogs")
+	}
+	// Dry runs still build the client so asset links show their real URLs.
+	releases, err := newProvider(*provider, publishing, retry, &cfg)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+
+	opts := []releigh.Option{releigh.WithLogger(logger)}
+	if *output == "json" {
+		// a dry run's report would garble the JSON on stdout
+		opts = append(opts, releigh.WithOutput(os.Stderr))
+	}
+	if cfg.JiraTransition != "" && publishing {
+		for _, env := range []string{"JIRA_BASE_URL", "JIRA_USER", "JIRA_API_TOKEN"} {
+			if os.Getenv(env) == "" {
+				return fmt.Errorf("configuration error: %s environment variable is not set (required by --jira-transition)", env)
+			}
+		}
+		opts = append(opts, releigh.WithJira(releigh.NewJiraClient(cfg.JiraBaseURL, os.Getenv("JIRA_USER"), os.Getenv("JIRA_API_TOKEN"), retry, logger)))
+	}
+
+	if cfg.SignTags && publishing {
+		if key := os.Getenv("RELEASE_GPG_PRIVATE_KEY"); key != "" {
+			fingerprint, cleanup, err := releigh.ImportSigningKey(key)
+			if err != nil {
+				return fmt.Errorf("could not set up tag signing: %w", err)
+			}
+			defer cleanup()
+			if cfg.SigningKey == "" {
+				cfg.SigningKey = fingerprint
+			}
+			logger.Info("imported release signing key", "key", cfg.SigningKey)
+		}
+	}
+
+	r, err := releigh.New(cfg, releigh.ExecGit{Log: logger}, releases, opts...)
+	if err != nil {
+		return fmt.Errorf("configuration error: %w", err)
+	}
+	results, err := r.Run(context.Background())
+
+	if *output == "json" {
+		if results == nil {
+			results = []releigh.Result{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return errors.Join(err, enc.Encode(results))
+	}
+	if cfg.SuggestVersion {
+		for _, res := range results {
+			if res.Version == "" {
+				continue
+			}
+			if len(results) == 1 {
+				fmt.Println(res.Version)
+			} else {
+				fmt.Printf("%s %s\n", res.App, res.Version)
+			}
+		}
+	} else if len(results) > 1 {
+		releigh.PrintSummary(os.Stdout, results)
+	}
+	return err
+}
+
+// newProvider builds the client of the provider named by the --provider
+// flag, or the one the origin remote is on. A GitHub release links to the
+// GitHub repository, so it replaces cfg's GitLab project URL.
+func newProvider(name string, publishing bool, retry releigh.RetryPolicy, cfg *releigh.Config) (releigh.Provider, error) {
+	remote, err := releigh.ExecGit{Log: logger}.Run(context.Background(), "remote", "get-url", "origin")
+	if err != nil {
+		logger.Debug("no origin remote to tell the provider from", "error", err)
+	}
+	host, path := releigh.ParseRemote(remote)
+	if name == "" {
+		name = releigh.ProviderForHost(host)
+	}
+
+	switch name {
+	case releigh.ProviderGitLab:
+		projectID, token := os.Getenv("CI_PROJECT_ID"), os.Getenv("GITLAB_API_TOKEN")
+		if publishing {
+			if projectID == "" {
+				return nil, fmt.Errorf("CI_PROJECT_ID environment variable is not set")
+			}
+			if token == "" {
+				return nil, fmt.Errorf("GITLAB_API_TOKEN environment variable is not set")
+			}
+		}
+		return releigh.NewGitLabClient(os.Getenv("CI_SERVER_URL"), projectID, token, retry, logger), nil
+	case releigh.ProviderGitHub:
+		repo, token := os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_TOKEN")
+		if repo == "" && releigh.ProviderForHost(host) == releigh.ProviderGitHub {
+			repo = path
+		}
+		if publishing {
+			if repo == "" {
+				return nil, fmt.Errorf("GITHUB_REPOSITORY environment variable is not set and origin is not a GitHub remote")
+			}
+			if token == "" {
+				return nil, fmt.Errorf("GITHUB_TOKEN environment variable is not set")
+			}
+		}
+		server := os.Getenv("GITHUB_SERVER_URL")
+		if server == "" {
+			server = releigh.DefaultGitHubURL
+		}
+		if repo != "" {
+			cfg.ProjectURL = strings.TrimSuffix(server, "/") + "/" + repo
+		}
+		logger.Info("releasing on GitHub", "repository", repo)
+		return releigh.NewGitHubClient(server, repo, token, retry, logger), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q: want gitlab or github", name)
+}
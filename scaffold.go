@@ -0,0 +1,183 @@
+// Package scaffold holds what the create-app forms collect, how it is
+// checked, and the review page the forms end on.
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/charmbracelet/huh/v2"
+)
+
+// Options are the answers of a create-app form.
+type Options struct {
+	Name    string
+	Envs    []string
+	EnvMeta []Env // one per env, in the order of Envs
+}
+
+// Env is how to reach one environment of the app.
+type Env struct {
+	Name   string
+	User   string // ssh user
+	Secret string // vault:<path> or the path of an SSH key
+	Host   string
+}
+
+var (
+	appNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// a vault path is segments of letters, digits, '.', '_' or '-'
+	vaultSecretRe = regexp.MustCompile(`^vault:[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*$`)
+)
+
+// Validate reports the first problem that would stop the scaffold: a bad
+// app name, a repeated environment, an env without meta, a missing ssh user
+// or a secret that is neither vault:<path> nor an absolute or ~/ key path.
+func (o Options) Validate() error {
+	if !appNameRe.MatchString(o.Name) {
+		return fmt.Errorf("app name %q must be letters, digits, '.', '_' or '-'", o.Name)
+	}
+	if len(o.Envs) == 0 {
+		return errors.New("at least one environment is required")
+	}
+	seen := map[string]bool{}
+	for _, env := range o.Envs {
+		if seen[env] {
+			return fmt.Errorf("environment %q is chosen twice", env)
+		}
+		seen[env] = true
+	}
+	metaSeen := map[string]bool{}
+	for _, meta := range o.EnvMeta {
+		if !seen[meta.Name] {
+			return fmt.Errorf("environment %q is configured but not chosen", meta.Name)
+		}
+		if metaSeen[meta.Name] {
+			return fmt.Errorf("environment %q is configured twice", meta.Name)
+		}
+		metaSeen[meta.Name] = true
+		if strings.TrimSpace(meta.User) == "" {
+			return fmt.Errorf("environment %q needs an ssh user", meta.Name)
+		}
+		if err := checkSecret(meta.Secret); err != nil {
+			return fmt.Errorf("environment %q: %w", meta.Name, err)
+		}
+	}
+	for _, env := range o.Envs {
+		if !metaSeen[env] {
+			return fmt.Errorf("environment %q has no ssh user or secret", env)
+		}
+	}
+	return nil
+}
+
+func checkSecret(secret string) error {
+	switch {
+	case strings.HasPrefix(secret, "vault:"):
+		if !vaultSecretRe.MatchString(secret) {
+			return fmt.Errorf("secret %q is not vault:<path>, e.g. vault:apps/foo/dev", secret)
+		}
+	case strings.HasPrefix(secret, "/"), strings.HasPrefix(secret, "~/"):
+	default:
+		return fmt.Errorf("secret %q must be vault:<path> or an absolute or ~/ key path", secret)
+	}
+	return nil
+}
+
+// Review is what the review page shows before anything is written.
+type Review struct {
+	App   string
+	Files []ReviewFile
+	Hosts []ReviewHost
+}
+
+// ReviewFile is a file that will be written: Mark is "+" for a new file,
+// "~" for a change and "=" for no change.
+type ReviewFile struct {
+	Mark string
+	Path string
+}
+
+// ReviewHost is an inventory entry that will be written.
+type ReviewHost struct {
+	Env     string
+	Name    string
+	Address string // ansible_host
+	User    string // ansible_user
+	Secret  string // key path or vault secret, if any
+}
+
+// Review is the review of o scaffolded under rootDir: its inventory and its
+// pipeline, and a host per env.
+func (o Options) Review(rootDir string) Review {
+	r := Review{App: o.Name}
+	for _, path := range []string{
+		filepath.Join("ansible", o.Name, "inventory.yml"),
+		filepath.Join(".gitlab", o.Name+".yml"),
+	} {
+		mark := "+"
+		if _, err := os.Stat(filepath.Join(rootDir, path)); err == nil {
+			mark = "~"
+		}
+		r.Files = append(r.Files, ReviewFile{Mark: mark, Path: filepath.ToSlash(path)})
+	}
+	for _, meta := range o.EnvMeta {
+		r.Hosts = append(r.Hosts, ReviewHost{Env: meta.Name, Name: meta.Host, Address: meta.Host, User: meta.User, Secret: meta.Secret})
+	}
+	return r
+}
+
+var reviewTemplate = template.Must(template.New("review").Parse(`Files for {{.App}}:
+{{- range .Files}}
+  {{.Mark}} {{.Path}}
+{{- else}}
+  (none)
+{{- end}}
+
+Inventory:
+{{- range .Hosts}}
+  [{{.Env}}] {{.Name}}{{if and .Address (ne .Address .Name)}}  ansible_host={{.Address}}{{end}}{{if .User}}  ansible_user={{.User}}{{end}}{{if .Secret}}  secret={{.Secret}}{{end}}
+{{- else}}
+  (no hosts)
+{{- end}}
+`))
+
+// Render is the text of the review page.
+func (r Review) Render() (string, error) {
+	var b strings.Builder
+	if err := reviewTemplate.Execute(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ErrDeclined is returned by ConfirmReview when the answer is no.
+var ErrDeclined = errors.New("declined at the review: nothing was written")
+
+// ConfirmReview shows r and asks whether to go ahead, returning ErrDeclined
+// if not.
+func ConfirmReview(ctx context.Context, r Review) error {
+	text, err := r.Render()
+	if err != nil {
+		return err
+	}
+	ok := true
+	if err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().Title("Review").Description(text),
+			huh.NewConfirm().Title("Write all of this?").Affirmative("Yes").Negative("No").Value(&ok),
+		),
+	).WithContext(ctx).Run(); err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeclined
+	}
+	return nil
+}
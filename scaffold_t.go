@@ -0,0 +1,151 @@
+package scaffold
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dev := Env{Name: "dev", User: "svc_foo", Secret: "vault:apps/foo/dev"}
+	prod := Env{Name: "prod", User: "svc_foo", Secret: "~/.ssh/foo_prod"}
+	for _, tc := range []struct {
+		name string
+		opts Options
+		want string // in the error; "" for none
+	}{
+		{"valid", Options{Name: "foo", Envs: []string{"dev", "prod"}, EnvMeta: []Env{dev, prod}}, ""},
+		{"dotted name", Options{Name: "foo.api_v2-x", Envs: []string{"dev"}, EnvMeta: []Env{dev}}, ""},
+		{"empty name", Options{Envs: []string{"dev"}, EnvMeta: []Env{dev}}, `app name ""`},
+		{"name with a space", Options{Name: "foo bar", Envs: []string{"dev"}, EnvMeta: []Env{dev}}, `app name "foo bar"`},
+		{"name starting with a dash", Options{Name: "-foo", Envs: []string{"dev"}, EnvMeta: []Env{dev}}, `app name "-foo"`},
+		{"no environments", Options{Name: "foo"}, "at least one environment"},
+		{"repeated environment", Options{Name: "foo", Envs: []string{"dev", "dev"}, EnvMeta: []Env{dev}}, `"dev" is chosen twice`},
+		{"meta for an unchosen environment", Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []Env{dev, prod}}, `"prod" is configured but not chosen`},
+		{"repeated meta", Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []Env{dev, dev}}, `"dev" is configured twice`},
+		{"no user", Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []Env{{Name: "dev", User: " ", Secret: dev.Secret}}}, `"dev" needs an ssh user`},
+		{"bad secret", Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []Env{{Name: "dev", User: "svc_foo", Secret: "hunter2"}}}, `environment "dev": secret "hunter2"`},
+		{"no meta", Options{Name: "foo", Envs: []string{"dev", "prod"}, EnvMeta: []Env{dev}}, `"prod" has no ssh user or secret`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			switch {
+			case tc.want == "" && err != nil:
+				t.Errorf("Validate = %v, want nil", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Errorf("Validate = %v, want an error containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, tc := range []struct {
+		secret string
+		ok     bool
+	}{
+		{"vault:apps/foo/dev", true},
+		{"vault:foo", true},
+		{"vault:apps/foo.bar/dev_2-a", true},
+		{"/home/me/.ssh/id_ed25519", true},
+		{"~/.ssh/id_ed25519", true},
+		{"", false},
+		{"vault:", false},
+		{"vault:/apps/foo", false},
+		{"vault:apps//foo", false},
+		{"vault:apps/foo/", false},
+		{"vault:apps/foo dev", false},
+		{"id_ed25519", false},
+		{".ssh/id_ed25519", false},
+		{"~me/.ssh/id_ed25519", false},
+		{"hunter2", false},
+	} {
+		if err := checkSecret(tc.secret); (err == nil) != tc.ok {
+			t.Errorf("checkSecret(%q) = %v, want ok %v", tc.secret, err, tc.ok)
+		}
+	}
+}
+
+func TestReview(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "ansible", "foo", "inventory.yml")
+	if err := os.MkdirAll(filepath.Dir(existing), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(existing, []byte("all: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{Name: "foo", Envs: []string{"dev", "prod"}, EnvMeta: []Env{
+		{Name: "dev", User: "svc_foo", Secret: "vault:apps/foo/dev", Host: "foo-dev-01"},
+		{Name: "prod", User: "svc_foo", Secret: "~/.ssh/foo_prod", Host: "foo-prod-01"},
+	}}
+	got := opts.Review(root)
+	want := Review{
+		App: "foo",
+		Files: []ReviewFile{
+			{Mark: "~", Path: "ansible/foo/inventory.yml"},
+			{Mark: "+", Path: ".gitlab/foo.yml"},
+		},
+		Hosts: []ReviewHost{
+			{Env: "dev", Name: "foo-dev-01", Address: "foo-dev-01", User: "svc_foo", Secret: "vault:apps/foo/dev"},
+			{Env: "prod", Name: "foo-prod-01", Address: "foo-prod-01", User: "svc_foo", Secret: "~/.ssh/foo_prod"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Review =\n%+v\nwant\n%+v", got, want)
+	}
+
+	text, err := got.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantText := `Files for foo:
+  ~ ansible/foo/inventory.yml
+  + .gitlab/foo.yml
+
+Inventory:
+  [dev] foo-dev-01  ansible_user=svc_foo  secret=vault:apps/foo/dev
+  [prod] foo-prod-01  ansible_user=svc_foo  secret=~/.ssh/foo_prod
+`
+	if text != wantText {
+		t.Errorf("Render =\n%s\nwant\n%s", text, wantText)
+	}
+}
+
+func TestReviewRender(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		review Review
+		want   string
+	}{
+		{
+			name:   "empty",
+			review: Review{App: "foo"},
+			want:   "Files for foo:\n  (none)\n\nInventory:\n  (no hosts)\n",
+		},
+		{
+			name: "address differs from the name",
+			review: Review{App: "foo", Hosts: []ReviewHost{
+				{Env: "qa", Name: "foo-qa-01", Address: "10.0.0.7", User: "svc_foo"},
+			}},
+			want: "Files for foo:\n  (none)\n\nInventory:\n  [qa] foo-qa-01  ansible_host=10.0.0.7  ansible_user=svc_foo\n",
+		},
+		{
+			name: "bare host",
+			review: Review{App: "foo", Files: []ReviewFile{{Mark: "=", Path: ".gitlab/foo.yml"}}, Hosts: []ReviewHost{
+				{Env: "qa", Name: "foo-qa-01"},
+			}},
+			want: "Files for foo:\n  = .gitlab/foo.yml\n\nInventory:\n  [qa] foo-qa-01\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.review.Render()
+			if err != nil || got != tc.want {
+				t.Errorf("Render = %q, %v, want %q", got, err, tc.want)
+			}
+		})
+	}
+}
@@ -2,60 +2,160 @@ package tui
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"loki/internal/scaffold"
+	"your-cli/internal/theme"
 
 	"github.com/charmbracelet/huh/v2"
 )
 
-// mockHosts simulates an API/database call.
-func mockHosts(env string) []string {
-	switch env {
-	case "dev":
-		return []string{"dev-01", "dev-02", "dev-03"}
-	case "qa":
-		return []string{"qa-blue", "qa-green"}
-	case "uat":
-		return []string{"uat-canary"}
-	case "staging":
-		return []string{"stage-east", "stage-west"}
-	case "prod":
-		return []string{"prod-a", "prod-b", "prod-c"}
-	default:
-		return []string{"host-x"}
+// DefaultEnvs are the environments an app can be created in.
+var DefaultEnvs = []string{"dev", "qa", "uat", "staging", "canary", "prod", "oat"}
+
+// CreateAppOptions configure RunCreateAppForm.
+type CreateAppOptions struct {
+	Name string
+	// Envs are the environments offered: if empty, those of Hosts, or
+	// DefaultEnvs without one.
+	Envs []string
+	// Hosts, if set, is where the host picker gets each environment's
+	// hosts; without one the host is typed in. Wrap slow sources with
+	// NewCachedHosts.
+	Hosts HostSource
+	// Preset holds answers already given as flags. The form starts from
+	// them; without a terminal they are used as they are.
+	Preset scaffold.Options
+	// Answers, if set, is read as the JSON of a scaffold.Options instead of
+	// showing the form, e.g. {"envs": ["dev"], "envMeta": [{"name": "dev",
+	// "user": "svc_foo", "secret": "vault:apps/foo/dev"}]}; its fields win
+	// over Preset's.
+	Answers io.Reader
+	// Interactive forces the form on or off; by default it is shown when
+	// stdin is a terminal and Answers is not set.
+	Interactive *bool
+	// RootDir is the repo root the review page checks for existing files.
+	RootDir string
+}
+
+// RunCreateAppForm asks for the environments of a new app and the host, ssh
+// user and secret of each, and returns fully-populated, valid
+// scaffold.Options once they are confirmed on the review page. Without a
+// terminal, or with opts.Answers, nothing is asked: the answers come from
+// opts.Preset and opts.Answers, and must be complete.
+func RunCreateAppForm(ctx context.Context, opts CreateAppOptions) (scaffold.Options, error) {
+	if len(opts.Envs) == 0 && opts.Hosts != nil {
+		envs, err := opts.Hosts.Envs(ctx)
+		if err != nil {
+			return scaffold.Options{}, err
+		}
+		opts.Envs = envs
+	}
+	if len(opts.Envs) == 0 {
+		opts.Envs = DefaultEnvs
+	}
+	answers := opts.Preset
+	answers.Name = opts.Name
+	if opts.Answers != nil {
+		if err := json.NewDecoder(opts.Answers).Decode(&answers); err != nil {
+			return scaffold.Options{}, fmt.Errorf("reading answers: %w", err)
+		}
+	}
+	interactive := opts.Answers == nil && stdinIsTerminal()
+	if opts.Interactive != nil {
+		interactive = *opts.Interactive
+	}
+	if !interactive {
+		answers, err := completeAnswers(answers, opts.Envs)
+		if err != nil {
+			return scaffold.Options{}, err
+		}
+		if err := answers.Validate(); err != nil {
+			return scaffold.Options{}, err
+		}
+		return answers, nil
+	}
+	answers, err := runForm(ctx, answers, opts.Envs, opts.Hosts)
+	if err != nil {
+		return scaffold.Options{}, err
+	}
+	if err := answers.Validate(); err != nil {
+		return scaffold.Options{}, err
+	}
+	root := opts.RootDir
+	if root == "" {
+		root = "."
+	}
+	if err := scaffold.ConfirmReview(ctx, answers.Review(root)); err != nil {
+		return scaffold.Options{}, err
+	}
+	return answers, nil
+}
+
+// completeAnswers checks non-interactive answers, filling in Envs from
+// EnvMeta or the other way round when only one is given.
+func completeAnswers(answers scaffold.Options, offered []string) (scaffold.Options, error) {
+	if len(answers.Envs) == 0 {
+		for _, meta := range answers.EnvMeta {
+			answers.Envs = append(answers.Envs, meta.Name)
+		}
+	}
+	if len(answers.Envs) == 0 {
+		return scaffold.Options{}, fmt.Errorf("no environments given, and no terminal to ask for them")
+	}
+	byEnv := map[string]scaffold.Env{}
+	for _, meta := range answers.EnvMeta {
+		byEnv[meta.Name] = meta
+	}
+	meta := make([]scaffold.Env, 0, len(answers.Envs))
+	for _, env := range answers.Envs {
+		if !contains(offered, env) {
+			return scaffold.Options{}, fmt.Errorf("unknown environment %q: want one of %v", env, offered)
+		}
+		m := byEnv[env]
+		m.Name = env
+		if m.User == "" || m.Secret == "" {
+			return scaffold.Options{}, fmt.Errorf("%s needs an ssh user and a secret, and there is no terminal to ask for them", env)
+		}
+		meta = append(meta, m)
 	}
+	answers.EnvMeta = meta
+	return answers, nil
 }
 
-// RunCreateAppForm launches the interactive form and returns
-// fully-populated scaffold.Options.
-func RunCreateAppForm(ctx context.Context, appName string) (scaffold.Options, error) {
+// runForm shows the huh form, pre-filled from answers.
+func runForm(ctx context.Context, answers scaffold.Options, offered []string, hosts HostSource) (scaffold.Options, error) {
 	var (
 		// first page
-		envChoices []string
-		// dynamic page values (one slice per env)
-		hostSel    = map[string]string{}
-		sshUsers   = map[string]string{}
-		sshSecrets = map[string]string{}
+		envChoices = append([]string(nil), answers.Envs...)
+		// dynamic page values, one per env so pointers stay stable
+		perEnv = map[string]*scaffold.Env{}
 	)
+	for _, meta := range answers.EnvMeta {
+		meta := meta
+		perEnv[meta.Name] = &meta
+	}
+	// A broken theme file falls back to the default rather than failing
+	t, _ := theme.Load()
+	formTheme := huh.ThemeCharm(t.Dark)
 
 	/* ───── Page 1 – pick environments ───── */
 
+	envOptions := make([]huh.Option[string], 0, len(offered))
+	for _, env := range offered {
+		envOptions = append(envOptions, huh.NewOption(env, env).Selected(contains(envChoices, env)))
+	}
 	pageEnvs := huh.NewGroup(
 		huh.NewMultiSelect[string]().
 			Title("Select environments").
-			Options(
-				huh.NewOption("dev", "dev"),
-				huh.NewOption("qa", "qa"),
-				huh.NewOption("uat", "uat"),
-				huh.NewOption("staging", "staging"),
-				huh.NewOption("canary", "canary"),
-				huh.NewOption("prod", "prod"),
-			).
+			Options(envOptions...).
 			Value(&envChoices),
 	)
 
-	if err := huh.NewForm(pageEnvs).WithContext(ctx).Run(); err != nil {
+	if err := huh.NewForm(pageEnvs).WithTheme(formTheme).WithContext(ctx).Run(); err != nil {
 		return scaffold.Options{}, err
 	}
 	if len(envChoices) == 0 {
@@ -66,39 +166,30 @@ func RunCreateAppForm(ctx context.Context, appName string) (scaffold.Options, er
 
 	var groups []huh.Group
 	for _, env := range envChoices {
-		// allocate backing vars so pointers stay stable
-		envCopy := env
-		hostSel[env] = ""
-		sshUsers[env] = ""
-		sshSecrets[env] = ""
+		if perEnv[env] == nil {
+			perEnv[env] = &scaffold.Env{}
+		}
+		answer := perEnv[env]
+		answer.Name = env
 
 		groups = append(groups,
 			huh.NewGroup(
-				huh.NewSelect[string]().
-					Title(fmt.Sprintf("%s → pick host", env)).
-					Options(func() []huh.Option[string] {
-						opts := make([]huh.Option[string], 0)
-						for _, h := range mockHosts(envCopy) {
-							opts = append(opts, huh.NewOption(h, h))
-						}
-						return opts
-					}()...).
-					Value(&hostSel[envCopy]),
+				hostField(ctx, env, hosts, &answer.Host),
 				huh.NewInput().
 					Title(fmt.Sprintf("%s → ssh user", env)).
 					Placeholder("svc_user").
-					Value(&sshUsers[envCopy]).
+					Value(&answer.User).
 					Validate(huh.Required[string]("user required")),
 				huh.NewInput().
 					Title(fmt.Sprintf("%s → secret / key path", env)).
 					Placeholder("vault:apps/foo/dev").
-					Value(&sshSecrets[envCopy]).
+					Value(&answer.Secret).
 					Validate(huh.Required[string]("secret required")),
 			),
 		)
 	}
 
-	if err := huh.NewForm(groups...).WithContext(ctx).Run(); err != nil {
+	if err := huh.NewForm(groups...).WithTheme(formTheme).WithContext(ctx).Run(); err != nil {
 		return scaffold.Options{}, err
 	}
 
@@ -106,17 +197,49 @@ func RunCreateAppForm(ctx context.Context, appName string) (scaffold.Options, er
 
 	var meta []scaffold.Env
 	for _, env := range envChoices {
-		meta = append(meta, scaffold.Env{
-			Name:   env,
-			User:   sshUsers[env],
-			Secret: sshSecrets[env],
-			Host:   hostSel[env],
-		})
-	}
-
-	return scaffold.Options{
-		Name:    appName,
-		Envs:    envChoices,
-		EnvMeta: meta,
-	}, nil
+		meta = append(meta, *perEnv[env])
+	}
+
+	answers.Envs = envChoices
+	answers.EnvMeta = meta
+	return answers, nil
+}
+
+// hostField picks env's host from hosts, or has it typed in when there is no
+// source or it has no hosts for env, saying why.
+func hostField(ctx context.Context, env string, hosts HostSource, value *string) huh.Field {
+	var why string
+	if hosts != nil {
+		names, err := hosts.Hosts(ctx, env)
+		switch {
+		case err != nil:
+			why = err.Error()
+		case len(names) == 0:
+			why = "no hosts found"
+		default:
+			return huh.NewSelect[string]().
+				Title(fmt.Sprintf("%s → pick host", env)).
+				Options(huh.NewOptions(names...)...).
+				Value(value)
+		}
+	}
+	return huh.NewInput().
+		Title(fmt.Sprintf("%s → host", env)).
+		Description(why).
+		Placeholder(env + "-01").
+		Value(value)
+}
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && (fi.Mode()&os.ModeCharDevice) != 0
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
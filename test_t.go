@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"loki/internal/scaffold"
+)
+
+func TestCompleteAnswers(t *testing.T) {
+	offered := []string{"dev", "qa", "prod"}
+	dev := scaffold.Env{Name: "dev", User: "svc_foo", Secret: "vault:apps/foo/dev"}
+	prod := scaffold.Env{Name: "prod", User: "svc_foo", Secret: "~/.ssh/foo_prod", Host: "foo-prod-01"}
+	for _, tc := range []struct {
+		name    string
+		answers scaffold.Options
+		want    scaffold.Options
+		err     string
+	}{
+		{
+			name:    "envs from their meta",
+			answers: scaffold.Options{Name: "foo", EnvMeta: []scaffold.Env{prod, dev}},
+			want:    scaffold.Options{Name: "foo", Envs: []string{"prod", "dev"}, EnvMeta: []scaffold.Env{prod, dev}},
+		},
+		{
+			name:    "meta in the order of envs",
+			answers: scaffold.Options{Name: "foo", Envs: []string{"dev", "prod"}, EnvMeta: []scaffold.Env{prod, dev}},
+			want:    scaffold.Options{Name: "foo", Envs: []string{"dev", "prod"}, EnvMeta: []scaffold.Env{dev, prod}},
+		},
+		{
+			name:    "meta of unchosen envs dropped",
+			answers: scaffold.Options{Name: "foo", Envs: []string{"prod"}, EnvMeta: []scaffold.Env{dev, prod}},
+			want:    scaffold.Options{Name: "foo", Envs: []string{"prod"}, EnvMeta: []scaffold.Env{prod}},
+		},
+		{
+			name:    "no envs",
+			answers: scaffold.Options{Name: "foo"},
+			err:     "no environments given",
+		},
+		{
+			name:    "unknown env",
+			answers: scaffold.Options{Name: "foo", Envs: []string{"dev", "uat"}, EnvMeta: []scaffold.Env{dev}},
+			err:     `unknown environment "uat"`,
+		},
+		{
+			name:    "no meta",
+			answers: scaffold.Options{Name: "foo", Envs: []string{"dev", "prod"}, EnvMeta: []scaffold.Env{dev}},
+			err:     "prod needs an ssh user and a secret",
+		},
+		{
+			name:    "no secret",
+			answers: scaffold.Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []scaffold.Env{{Name: "dev", User: "svc_foo"}}},
+			err:     "dev needs an ssh user and a secret",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := completeAnswers(tc.answers, offered)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("completeAnswers error = %v, want one containing %q", err, tc.err)
+				}
+				if !reflect.DeepEqual(got, scaffold.Options{}) {
+					t.Errorf("completeAnswers = %+v with an error, want no options", got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("completeAnswers = %+v, %v, want %+v", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRunCreateAppFormWithoutTerminal(t *testing.T) {
+	off := false
+	for _, tc := range []struct {
+		name    string
+		preset  scaffold.Options
+		answers string
+		want    scaffold.Options
+		err     string
+	}{
+		{
+			name:    "answers",
+			answers: `{"envs": ["dev"], "envMeta": [{"name": "dev", "user": "svc_foo", "secret": "vault:apps/foo/dev"}]}`,
+			want: scaffold.Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []scaffold.Env{
+				{Name: "dev", User: "svc_foo", Secret: "vault:apps/foo/dev"},
+			}},
+		},
+		{
+			name:    "answers win over the preset",
+			preset:  scaffold.Options{Envs: []string{"qa"}, EnvMeta: []scaffold.Env{{Name: "qa", User: "svc_old", Secret: "/keys/qa"}}},
+			answers: `{"envs": ["dev"], "envMeta": [{"name": "dev", "user": "svc_foo", "secret": "/keys/dev"}]}`,
+			want: scaffold.Options{Name: "foo", Envs: []string{"dev"}, EnvMeta: []scaffold.Env{
+				{Name: "dev", User: "svc_foo", Secret: "/keys/dev"},
+			}},
+		},
+		{
+			name:   "preset alone",
+			preset: scaffold.Options{Envs: []string{"qa"}, EnvMeta: []scaffold.Env{{Name: "qa", User: "svc_foo", Secret: "/keys/qa"}}},
+			want: scaffold.Options{Name: "foo", Envs: []string{"qa"}, EnvMeta: []scaffold.Env{
+				{Name: "qa", User: "svc_foo", Secret: "/keys/qa"},
+			}},
+		},
+		{
+			name: "missing env",
+			err:  "no environments given",
+		},
+		{
+			name:    "unknown env",
+			answers: `{"envMeta": [{"name": "uat", "user": "svc_foo", "secret": "/keys/uat"}]}`,
+			err:     `unknown environment "uat"`,
+		},
+		{
+			name:    "bad secret",
+			answers: `{"envMeta": [{"name": "dev", "user": "svc_foo", "secret": "hunter2"}]}`,
+			err:     `environment "dev": secret "hunter2"`,
+		},
+		{
+			name:    "bad JSON",
+			answers: `{"envs": "dev"}`,
+			err:     "reading answers",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := CreateAppOptions{Name: "foo", Envs: []string{"dev", "qa", "prod"}, Preset: tc.preset, Interactive: &off}
+			if tc.answers != "" {
+				opts.Answers = strings.NewReader(tc.answers)
+			}
+			got, err := RunCreateAppForm(context.Background(), opts)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("RunCreateAppForm error = %v, want one containing %q", err, tc.err)
+				}
+				if !reflect.DeepEqual(got, scaffold.Options{}) {
+					t.Errorf("RunCreateAppForm = %+v with an error, want no options", got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("RunCreateAppForm = %+v, %v, want %+v", got, err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package theme holds the colors, styles and key bindings shared by the
+// terminal UIs (the inventory viewer and browser, and loki's create-app
+// flow). They can be tuned in ~/.config/loki/theme.yaml, e.g. for a light
+// terminal:
+//
+//	preset: light   # or dark, the default
+//	colors:
+//	  highlight: "#d7005f"
+//	keys: arrows    # or vim, the default, which adds h/j/k/l to the arrows
+//
+// Colors take anything lipgloss does: ANSI 256 numbers ("205") or hex.
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+)
+
+// Colors are the roles the UIs color things by.
+type Colors struct {
+	Accent      string `yaml:"accent"`       // titles, headers and the outer border
+	Highlight   string `yaml:"highlight"`    // the selection, focused borders and search matches
+	OnHighlight string `yaml:"on_highlight"` // text drawn on Highlight
+	Muted       string `yaml:"muted"`        // help text and unfocused borders
+	Success     string `yaml:"success"`
+	Warning     string `yaml:"warning"`
+	Error       string `yaml:"error"`
+}
+
+// Presets are the built-in color sets a theme file starts from.
+var Presets = map[string]Colors{
+	"dark": {
+		Accent:      "63",
+		Highlight:   "205",
+		OnHighlight: "231",
+		Muted:       "241",
+		Success:     "46",
+		Warning:     "214",
+		Error:       "196",
+	},
+	"light": {
+		Accent:      "25",
+		Highlight:   "161",
+		OnHighlight: "231",
+		Muted:       "243",
+		Success:     "28",
+		Warning:     "130",
+		Error:       "160",
+	},
+}
+
+// Config is the theme.yaml file.
+type Config struct {
+	Preset string `yaml:"preset"` // a key of Presets; dark if empty
+	Colors Colors `yaml:"colors"` // overrides for single roles of the preset
+	Keys   string `yaml:"keys"`   // "vim" (the default) or "arrows"
+}
+
+// KeyMap is the navigation the UIs share. Keys specific to one UI, such as
+// the inventory browser's edit actions, stay with that UI.
+type KeyMap struct {
+	Up      key.Binding
+	Down    key.Binding
+	Left    key.Binding
+	Right   key.Binding
+	Next    key.Binding // next tab or file
+	Prev    key.Binding
+	Search  key.Binding
+	Clear   key.Binding // leave search or drop a filter
+	Confirm key.Binding
+	Quit    key.Binding
+}
+
+// Styles are the lipgloss styles built from Colors.
+type Styles struct {
+	Title         lipgloss.Style // one-line title bars
+	Header        lipgloss.Style // section headers in plain CLI output
+	Help          lipgloss.Style
+	Selected      lipgloss.Style // the item under the cursor
+	Match         lipgloss.Style // search matches, group names
+	Item          lipgloss.Style // list entries such as host names
+	Success       lipgloss.Style
+	Warning       lipgloss.Style
+	Error         lipgloss.Style
+	Container     lipgloss.Style // the border around a whole screen
+	FocusedPane   lipgloss.Style
+	UnfocusedPane lipgloss.Style
+	Modal         lipgloss.Style // forms drawn over the panes
+}
+
+// Theme is a loaded theme.
+type Theme struct {
+	Colors Colors
+	Styles Styles
+	Keys   KeyMap
+	// Dark is false for the light preset, for UIs such as huh forms that
+	// only tell a light terminal from a dark one.
+	Dark bool
+}
+
+// Default is the dark preset with vim and arrow keys.
+func Default() Theme {
+	t, _ := New(Config{})
+	return t
+}
+
+// New builds a theme from a config, filling roles the config leaves empty
+// from its preset.
+func New(cfg Config) (Theme, error) {
+	if cfg.Preset == "" {
+		cfg.Preset = "dark"
+	}
+	colors, ok := Presets[cfg.Preset]
+	if !ok {
+		return Theme{}, fmt.Errorf("unknown preset %q (want dark or light)", cfg.Preset)
+	}
+	override(&colors.Accent, cfg.Colors.Accent)
+	override(&colors.Highlight, cfg.Colors.Highlight)
+	override(&colors.OnHighlight, cfg.Colors.OnHighlight)
+	override(&colors.Muted, cfg.Colors.Muted)
+	override(&colors.Success, cfg.Colors.Success)
+	override(&colors.Warning, cfg.Colors.Warning)
+	override(&colors.Error, cfg.Colors.Error)
+
+	keys, err := newKeyMap(cfg.Keys)
+	if err != nil {
+		return Theme{}, err
+	}
+	return Theme{Colors: colors, Styles: newStyles(colors), Keys: keys, Dark: cfg.Preset != "light"}, nil
+}
+
+// Path is where Load reads the theme from: $XDG_CONFIG_HOME/loki/theme.yaml,
+// or ~/.config/loki/theme.yaml.
+func Path() string {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, _ := os.UserHomeDir()
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "loki", "theme.yaml")
+}
+
+// Load reads the theme file at Path, or returns Default if there is none.
+// On error it still returns Default, so a broken file never stops a UI from
+// starting.
+func Load() (Theme, error) {
+	data, err := os.ReadFile(Path())
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return Default(), err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Default(), fmt.Errorf("%s: %w", Path(), err)
+	}
+	t, err := New(cfg)
+	if err != nil {
+		return Default(), fmt.Errorf("%s: %w", Path(), err)
+	}
+	return t, nil
+}
+
+func override(color *string, with string) {
+	if with != "" {
+		*color = with
+	}
+}
+
+func newKeyMap(style string) (KeyMap, error) {
+	switch style {
+	case "", "vim":
+		return KeyMap{
+			Up:      key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+			Down:    key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+			Left:    key.NewBinding(key.WithKeys("left", "h"), key.WithHelp("←/h", "left")),
+			Right:   key.NewBinding(key.WithKeys("right", "l"), key.WithHelp("→/l", "right")),
+			Next:    key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next")),
+			Prev:    key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "previous")),
+			Search:  key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
+			Clear:   key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear")),
+			Confirm: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "confirm")),
+			Quit:    key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+		}, nil
+	case "arrows":
+		return KeyMap{
+			Up:      key.NewBinding(key.WithKeys("up"), key.WithHelp("↑", "up")),
+			Down:    key.NewBinding(key.WithKeys("down"), key.WithHelp("↓", "down")),
+			Left:    key.NewBinding(key.WithKeys("left"), key.WithHelp("←", "left")),
+			Right:   key.NewBinding(key.WithKeys("right"), key.WithHelp("→", "right")),
+			Next:    key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next")),
+			Prev:    key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "previous")),
+			Search:  key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "search")),
+			Clear:   key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "clear")),
+			Confirm: key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "confirm")),
+			Quit:    key.NewBinding(key.WithKeys("q", "ctrl+c"), key.WithHelp("q", "quit")),
+		}, nil
+	}
+	return KeyMap{}, fmt.Errorf("unknown keys %q (want vim or arrows)", style)
+}
+
+func newStyles(c Colors) Styles {
+	color := func(s string) lipgloss.Color { return lipgloss.Color(s) }
+	return Styles{
+		Title:         lipgloss.NewStyle().Bold(true).Foreground(color(c.Accent)),
+		Header:        lipgloss.NewStyle().Bold(true).Foreground(color(c.Accent)).Underline(true).MarginBottom(1),
+		Help:          lipgloss.NewStyle().Foreground(color(c.Muted)),
+		Selected:      lipgloss.NewStyle().Background(color(c.Highlight)).Foreground(color(c.OnHighlight)),
+		Match:         lipgloss.NewStyle().Bold(true).Foreground(color(c.Highlight)),
+		Item:          lipgloss.NewStyle().Foreground(color(c.Highlight)),
+		Success:       lipgloss.NewStyle().Bold(true).Foreground(color(c.Success)),
+		Warning:       lipgloss.NewStyle().Bold(true).Foreground(color(c.Warning)),
+		Error:         lipgloss.NewStyle().Bold(true).Foreground(color(c.Error)),
+		Container:     lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(color(c.Accent)),
+		FocusedPane:   lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(c.Highlight)),
+		UnfocusedPane: lipgloss.NewStyle().Border(lipgloss.NormalBorder()).BorderForeground(color(c.Muted)),
+		Modal:         lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(color(c.Highlight)).Padding(1, 2),
+	}
+}
@@ -1,67 +1,130 @@
-package main
+// Package invedit is the inventory editor: a TUI to browse, search and edit
+// YAML inventories, writing them back after showing the diff.
+package invedit
 
 import (
+	"bytes"
 	"fmt"
-	"log"
 	"os"
 	"sort"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/your-username/ansible-inventory-go/ansibleinv" // <-- IMPORTANT: Use your module path
+	"gopkg.in/yaml.v3"
+	"your-cli/internal/theme"
 )
 
-// Define some styles using Lipgloss
+// Styles and keys, from the shared theme (see applyTheme)
 var (
-	// Style for the container around the panes
-	containerStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("63")) // Purple
-
-	// Style for an active, focused pane
-	activePaneStyle = lipgloss.NewStyle().
-			Border(lipgloss.NormalBorder()).
-			BorderForeground(lipgloss.Color("205")) // Pink
-
-	// Style for an inactive pane
-	inactivePaneStyle = lipgloss.NewStyle().
-				Border(lipgloss.NormalBorder()).
-				BorderForeground(lipgloss.Color("240")) // Grey
-
-	// Style for a selected item in a list
-	selectedItemStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("205")).
-				Foreground(lipgloss.Color("231"))
-
-	// Help text style
-	helpStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	containerStyle    lipgloss.Style // Around the panes
+	activePaneStyle   lipgloss.Style // The focused pane
+	inactivePaneStyle lipgloss.Style // The other pane
+	selectedItemStyle lipgloss.Style // The group under the cursor
+	matchStyle        lipgloss.Style // Hosts matching the search query
+	titleStyle        lipgloss.Style // The title bar
+	helpStyle         lipgloss.Style // Help and status text
+	modalStyle        lipgloss.Style // The box around an open form
+	addedStyle        lipgloss.Style // Lines the write-back diff adds
+	removedStyle      lipgloss.Style // Lines the write-back diff removes
+
+	keys theme.KeyMap
 )
 
+func init() {
+	applyTheme(theme.Default())
+}
+
+// applyTheme sets the styles and keys the TUI draws and reads with.
+func applyTheme(t theme.Theme) {
+	containerStyle = t.Styles.Container
+	activePaneStyle = t.Styles.FocusedPane
+	inactivePaneStyle = t.Styles.UnfocusedPane
+	selectedItemStyle = t.Styles.Selected
+	matchStyle = t.Styles.Match
+	titleStyle = t.Styles.Title
+	helpStyle = t.Styles.Help
+	modalStyle = t.Styles.Modal
+	addedStyle = t.Styles.Success
+	removedStyle = t.Styles.Error
+	keys = t.Keys
+}
+
+// inventoryFile is one of the loaded inventories tab switches between.
+type inventoryFile struct {
+	inventory *ansibleinv.Inventory // The parsed inventory data
+	path      string                // Where the inventory is read from and written back to
+	saved     []byte                // The file as last read or written, to diff edits against
+	rendered  []byte                // MarshalYAML of the inventory as last read or written
+	groups    []*ansibleinv.Group   // A sorted slice of all groups
+	cursor    int                   // Which visible group we're pointing at in the left pane
+}
+
 // model holds the state of our TUI application.
 type model struct {
-	inventory *ansibleinv.Inventory // The parsed inventory data
-	groups    []*ansibleinv.Group   // A sorted slice of all groups to display
-	cursor    int                   // Which group we're pointing at in the left pane
+	*inventoryFile                  // The active inventory
+	files          []*inventoryFile // Every inventory given on the command line
+	active         int              // Which of files is shown
+
+	visible   []*ansibleinv.Group // The groups the search query leaves in the left pane
 	width     int
 	height    int
-	viewport  viewport.Model // Use a viewport for the right pane to handle scrolling
+	viewport  viewport.Model  // Use a viewport for the right pane to handle scrolling
+	search    textinput.Model // The `/` search query
+	searching bool            // Whether keys go to the search query
+	form      *huh.Form       // The open edit form, drawn as a modal
+	values    *formValues     // What the open form's fields are bound to
+	onSubmit  func(*model) error
+	status    string // The result of the last edit or write
+}
+
+// formValues holds the answers of the open form.
+type formValues struct {
+	host      string
+	vars      string
+	confirmed bool
 }
 
 // initialModel creates the starting state of our application.
-// This is where we parse the inventory file.
-func initialModel() model {
-	// For this example, we'll hardcode the file path.
-	// In a real app, you'd get this from a command-line argument.
-	inventoryFile := "example.yaml"
-	if _, err := os.Stat(inventoryFile); os.IsNotExist(err) {
-		log.Fatalf("Inventory file not found: %s. Please create it.", inventoryFile)
+// This is where we parse the inventory files.
+func initialModel(paths []string) (model, error) {
+	var files []*inventoryFile
+	for _, path := range paths {
+		file, err := loadInventoryFile(path)
+		if err != nil {
+			return model{}, fmt.Errorf("could not load inventory: %w", err)
+		}
+		files = append(files, file)
 	}
 
-	inv, err := ansibleinv.ParseYAMLFile(inventoryFile)
+	search := textinput.New()
+	search.Prompt = "/"
+	search.Placeholder = "fuzzy search groups and hosts"
+
+	return model{
+		inventoryFile: files[0],
+		files:         files,
+		visible:       files[0].groups,
+		viewport:      viewport.New(80, 20), // Initial size, will be updated
+		search:        search,
+	}, nil
+}
+
+// loadInventoryFile parses a YAML inventory and keeps its bytes to diff
+// edits against.
+func loadInventoryFile(path string) (*inventoryFile, error) {
+	saved, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Could not parse inventory: %v", err)
+		return nil, err
+	}
+	inv, err := ansibleinv.ParseBytes(saved)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
 
 	// Get a sorted list of groups for stable ordering
@@ -73,12 +136,70 @@ func initialModel() model {
 		return groups[i].Name < groups[j].Name
 	})
 
-	return model{
-		inventory: inv,
-		groups:    groups,
-		cursor:    0,
-		viewport:  viewport.New(80, 20), // Initial size, will be updated
+	rendered, err := inv.MarshalYAML()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &inventoryFile{inventory: inv, path: path, saved: saved, rendered: rendered, groups: groups}, nil
+}
+
+// switchTo makes files[i] the active inventory, keeping the search query.
+func (m *model) switchTo(i int) {
+	m.active = (i + len(m.files)) % len(m.files)
+	m.inventoryFile = m.files[m.active]
+	m.applyFilter()
+}
+
+// fuzzyMatch reports whether every character of query appears in s in
+// order, ignoring case, so "pw1" finds "prod-web-1".
+func fuzzyMatch(query, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(query) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
 	}
+	return true
+}
+
+// hostMatches reports whether a host is highlighted by the search query.
+func (m model) hostMatches(hostName string) bool {
+	query := m.search.Value()
+	return query != "" && fuzzyMatch(query, hostName)
+}
+
+// applyFilter narrows the left pane to the groups whose name or any of
+// whose hosts fuzzy-match the search query.
+func (m *model) applyFilter() {
+	query := m.search.Value()
+	if query == "" {
+		m.visible = m.groups
+	} else {
+		m.visible = nil
+		for _, group := range m.groups {
+			if fuzzyMatch(query, group.Name) || m.countMatches(group) > 0 {
+				m.visible = append(m.visible, group)
+			}
+		}
+	}
+	if m.cursor >= len(m.visible) {
+		m.cursor = max(len(m.visible)-1, 0)
+	}
+	m.viewport.GotoTop()
+}
+
+// countMatches returns how many of a group's hosts the search query matches.
+func (m model) countMatches(group *ansibleinv.Group) int {
+	n := 0
+	for hostName := range group.Hosts {
+		if m.hostMatches(hostName) {
+			n++
+		}
+	}
+	return n
 }
 
 // Init is the first command that's run when the program starts.
@@ -88,6 +209,11 @@ func (m model) Init() tea.Cmd {
 
 // Update handles all incoming events, like key presses and window resizes.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// An open form gets every event until it is submitted or aborted
+	if m.form != nil {
+		return m.updateForm(msg)
+	}
+
 	switch msg := msg.(type) {
 
 	// Window was resized
@@ -96,31 +222,104 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.height = msg.Height
 		// Recalculate layout
 		containerStyle.Width(m.width - 2)
-		containerStyle.Height(m.height - 4)
+		containerStyle.Height(m.height - 5)
 		paneWidth := (m.width - 6) / 2
 		activePaneStyle.Width(paneWidth)
 		inactivePaneStyle.Width(paneWidth)
-		activePaneStyle.Height(m.height - 6)
-		inactivePaneStyle.Height(m.height - 6)
+		activePaneStyle.Height(m.height - 7)
+		inactivePaneStyle.Height(m.height - 7)
 		m.viewport.Width = paneWidth
-		m.viewport.Height = m.height - 6
+		m.viewport.Height = m.height - 7
 
 	// A key was pressed
 	case tea.KeyMsg:
-		switch msg.String() {
-		// Exit the program
-		case "ctrl+c", "q":
+		// While typing a search query, keys edit the query
+		if m.searching {
+			switch {
+			// Keep the filter and go back to navigating
+			case key.Matches(msg, keys.Confirm):
+				m.searching = false
+				m.search.Blur()
+
+			// Drop the filter
+			case key.Matches(msg, keys.Clear):
+				m.searching = false
+				m.search.Blur()
+				m.search.Reset()
+				m.applyFilter()
+
+			case msg.String() == "ctrl+c":
+				return m, tea.Quit
+
+			// Arrow keys still move, j/k are part of the query
+			case msg.String() == "up":
+				if m.cursor > 0 {
+					m.cursor--
+				}
+			case msg.String() == "down":
+				if m.cursor < len(m.visible)-1 {
+					m.cursor++
+				}
+
+			default:
+				var cmd tea.Cmd
+				m.search, cmd = m.search.Update(msg)
+				m.applyFilter()
+				m.viewport.SetContent(m.renderRightPane())
+				return m, cmd
+			}
+			m.viewport.SetContent(m.renderRightPane())
+			return m, nil
+		}
+
+		switch {
+		// Exit the program, unless there are edits to write back
+		case key.Matches(msg, keys.Quit):
+			if dirty := m.dirtyPaths(); len(dirty) > 0 && msg.String() != "ctrl+c" {
+				m.status = fmt.Sprintf("Unsaved changes in %s: press w to write them, Q to quit without saving.", strings.Join(dirty, ", "))
+				return m, nil
+			}
 			return m, tea.Quit
+		case msg.String() == "Q":
+			return m, tea.Quit
+
+		// Edit the inventory
+		case msg.String() == "a":
+			return m, m.addHostForm()
+		case msg.String() == "d":
+			return m, m.removeHostForm()
+		case msg.String() == "e":
+			return m, m.groupVarsForm()
+		case msg.String() == "v":
+			return m, m.hostVarsForm()
+		case msg.String() == "w":
+			return m, m.writeForm()
+
+		// Switch between the loaded inventories
+		case key.Matches(msg, keys.Next):
+			m.switchTo(m.active + 1)
+		case key.Matches(msg, keys.Prev):
+			m.switchTo(m.active - 1)
+
+		// Start typing a search query
+		case key.Matches(msg, keys.Search):
+			m.searching = true
+			return m, m.search.Focus()
+
+		// Clear the search filter
+		case key.Matches(msg, keys.Clear):
+			m.search.Reset()
+			m.applyFilter()
 
 		// Move the cursor up
-		case "up", "k":
+		case key.Matches(msg, keys.Up):
 			if m.cursor > 0 {
 				m.cursor--
 			}
 
 		// Move the cursor down
-		case "down", "j":
-			if m.cursor < len(m.groups)-1 {
+		case key.Matches(msg, keys.Down):
+			if m.cursor < len(m.visible)-1 {
 				m.cursor++
 			}
 		}
@@ -149,23 +348,71 @@ func (m model) View() string {
 	// Join the panes horizontally
 	panes := lipgloss.JoinHorizontal(lipgloss.Top, left, right)
 
+	// An open form covers the panes
+	if m.form != nil {
+		panes = lipgloss.Place(m.width-4, m.height-7, lipgloss.Center, lipgloss.Center, modalStyle.Render(m.form.View()))
+	}
+
+	// The bottom line is the search query while typing one, or the result
+	// of the last edit
+	footer := helpStyle.Render(fmt.Sprintf("%s %s navigate, tab next file, / search, esc clear, a/d add/remove host, e/v edit group/host vars, w write, q quit.",
+		keys.Up.Help().Key, keys.Down.Help().Key))
+	if m.searching {
+		footer = m.search.View()
+	} else if m.status != "" {
+		footer = helpStyle.Render(m.status)
+	}
+
 	// Final layout
 	ui := lipgloss.JoinVertical(lipgloss.Top,
+		m.renderTitle(),
 		containerStyle.Render(panes),
-		helpStyle.Render("Use ↑/↓ to navigate. Press 'q' to quit."),
+		footer,
 	)
 
 	return ui
 }
 
+// renderTitle builds the title bar: the active file, and match counts while
+// filtering.
+func (m model) renderTitle() string {
+	file := m.path
+	if len(m.files) > 1 {
+		file = fmt.Sprintf("%s [%d/%d]", m.path, m.active+1, len(m.files))
+	}
+	title := fmt.Sprintf("%s: %d groups, %d hosts", file, len(m.groups), len(m.inventory.Hosts))
+	if query := m.search.Value(); query != "" {
+		hosts := 0
+		for hostName := range m.inventory.Hosts {
+			if m.hostMatches(hostName) {
+				hosts++
+			}
+		}
+		title = fmt.Sprintf("%s: %d/%d groups, %d/%d hosts match /%s",
+			file, len(m.visible), len(m.groups), hosts, len(m.inventory.Hosts), query)
+	}
+	if m.dirty() {
+		title += " (modified)"
+	}
+	return titleStyle.Render(title)
+}
+
 // renderLeftPane builds the string content for the groups list.
 func (m model) renderLeftPane() string {
+	if len(m.visible) == 0 {
+		return "No groups match."
+	}
+
 	var b strings.Builder
-	for i, group := range m.groups {
+	for i, group := range m.visible {
+		count := fmt.Sprintf("%d hosts", len(group.Hosts))
+		if m.search.Value() != "" {
+			count = fmt.Sprintf("%d/%d hosts", m.countMatches(group), len(group.Hosts))
+		}
 		if i == m.cursor {
-			b.WriteString(selectedItemStyle.Render(fmt.Sprintf("> %s (%d hosts)", group.Name, len(group.Hosts))))
+			b.WriteString(selectedItemStyle.Render(fmt.Sprintf("> %s (%s)", group.Name, count)))
 		} else {
-			b.WriteString(fmt.Sprintf("  %s (%d hosts)", group.Name, len(group.Hosts)))
+			b.WriteString(fmt.Sprintf("  %s (%s)", group.Name, count))
 		}
 		b.WriteRune('\n')
 	}
@@ -174,53 +421,407 @@ func (m model) renderLeftPane() string {
 
 // renderRightPane builds the string content for the hosts list of the selected group.
 func (m model) renderRightPane() string {
-	if len(m.groups) == 0 {
+	if len(m.visible) == 0 {
 		return "No groups found."
 	}
 
-	selectedGroup := m.groups[m.cursor]
-
-	var hostNames []string
-	for name := range selectedGroup.Hosts {
-		hostNames = append(hostNames, name)
-	}
-	sort.Strings(hostNames)
+	selectedGroup := m.visible[m.cursor]
 
 	var b strings.Builder
 	b.WriteString(lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("Hosts in [%s]", selectedGroup.Name)))
 	b.WriteString("\n\n")
-	for _, hostName := range hostNames {
+	for _, hostName := range sortedHostNames(selectedGroup) {
+		if m.hostMatches(hostName) {
+			b.WriteString(matchStyle.Render(fmt.Sprintf("* %s", hostName)) + "\n")
+			continue
+		}
 		b.WriteString(fmt.Sprintf("- %s\n", hostName))
 	}
 
+	if len(selectedGroup.Vars) > 0 {
+		b.WriteString("\n")
+		b.WriteString(lipgloss.NewStyle().Bold(true).Render("Vars"))
+		b.WriteString("\n\n")
+		b.WriteString(formatVars(selectedGroup.Vars))
+	}
+
 	return b.String()
 }
 
-func main() {
-	// Create the `example.yaml` file if it doesn't exist for a smooth first run.
-	if _, err := os.Stat("example.yaml"); os.IsNotExist(err) {
-		content := `
-all:
-  children:
-    prod:
-      hosts:
-        prod-web-1:
-        prod-web-2:
-        prod-db-1:
-      vars:
-        env: production
-    staging:
-      hosts:
-        staging-web-1:
-        staging-db-1:
-      vars:
-        env: staging
-`
-		os.WriteFile("example.yaml", []byte(content), 0644)
-	}
-
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		log.Fatalf("Alas, there's been an error: %v", err)
+// selectedGroup returns the group under the cursor, or nil if none is shown.
+func (m model) selectedGroup() *ansibleinv.Group {
+	if len(m.visible) == 0 {
+		return nil
+	}
+	return m.visible[m.cursor]
+}
+
+// dirty reports whether the inventory has edits not yet written back. It
+// compares renderings rather than the file itself so that layout MarshalYAML
+// normalises (comments, ordering) does not count as an edit.
+func (f *inventoryFile) dirty() bool {
+	data, err := f.inventory.MarshalYAML()
+	return err == nil && !bytes.Equal(data, f.rendered)
+}
+
+// dirtyPaths lists the loaded inventories with unwritten edits.
+func (m model) dirtyPaths() []string {
+	var paths []string
+	for _, file := range m.files {
+		if file.dirty() {
+			paths = append(paths, file.path)
+		}
+	}
+	return paths
+}
+
+// openForm shows form as a modal; onSubmit applies its answers once the user
+// completes it.
+func (m *model) openForm(form *huh.Form, values *formValues, onSubmit func(*model) error) tea.Cmd {
+	m.form, m.values, m.onSubmit = form, values, onSubmit
+	m.status = ""
+	return m.form.Init()
+}
+
+// updateForm passes an event to the open form and, once it is done, applies
+// or drops its answers.
+func (m model) updateForm(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.width, m.height = size.Width, size.Height
+	}
+
+	updated, cmd := m.form.Update(msg)
+	if form, ok := updated.(*huh.Form); ok {
+		m.form = form
+	}
+
+	switch m.form.State {
+	case huh.StateAborted:
+		m.form = nil
+		m.status = "Cancelled."
+	case huh.StateCompleted:
+		onSubmit := m.onSubmit
+		m.form = nil
+		if err := onSubmit(&m); err != nil {
+			m.status = fmt.Sprintf("Error: %v", err)
+		}
+		m.applyFilter()
+		m.viewport.SetContent(m.renderRightPane())
+		// onSubmit may have opened the next form in a sequence
+		if m.form != nil {
+			return m, m.form.Init()
+		}
+		return m, nil
+	}
+	return m, cmd
+}
+
+// addHostForm asks for a new host and its vars, and adds it to the selected group.
+func (m *model) addHostForm() tea.Cmd {
+	group := m.selectedGroup()
+	if group == nil {
+		return nil
+	}
+	values := &formValues{}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(fmt.Sprintf("New host in [%s]", group.Name)).
+				Value(&values.host).
+				Validate(huh.ValidateNotEmpty()),
+			huh.NewText().
+				Title("Host vars (YAML)").
+				Placeholder("ansible_host: 10.0.0.1").
+				Value(&values.vars),
+		),
+	)
+	return m.openForm(form, values, func(m *model) error {
+		vars, err := parseVars(values.vars)
+		if err != nil {
+			return err
+		}
+		if _, err := m.inventory.AddHost(group.Name, strings.TrimSpace(values.host), vars); err != nil {
+			return err
+		}
+		m.status = fmt.Sprintf("Added %s to [%s].", values.host, group.Name)
+		return nil
+	})
+}
+
+// removeHostForm asks which of the selected group's hosts to remove from the
+// inventory.
+func (m *model) removeHostForm() tea.Cmd {
+	group := m.selectedGroup()
+	if group == nil || len(group.Hosts) == 0 {
+		return nil
+	}
+	values := &formValues{}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(fmt.Sprintf("Remove which host of [%s]?", group.Name)).
+				Description("The host is removed from every group.").
+				Options(huh.NewOptions(sortedHostNames(group)...)...).
+				Value(&values.host),
+			huh.NewConfirm().
+				Title("Remove it?").
+				Value(&values.confirmed),
+		),
+	)
+	return m.openForm(form, values, func(m *model) error {
+		if !values.confirmed {
+			m.status = "Cancelled."
+			return nil
+		}
+		if err := m.inventory.RemoveHost(values.host); err != nil {
+			return err
+		}
+		m.status = fmt.Sprintf("Removed %s.", values.host)
+		return nil
+	})
+}
+
+// groupVarsForm edits the selected group's vars as YAML.
+func (m *model) groupVarsForm() tea.Cmd {
+	group := m.selectedGroup()
+	if group == nil {
+		return nil
+	}
+	values := &formValues{vars: formatVars(group.Vars)}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewText().
+				Title(fmt.Sprintf("Vars of [%s] (YAML)", group.Name)).
+				Value(&values.vars),
+		),
+	)
+	return m.openForm(form, values, func(m *model) error {
+		vars, err := parseVars(values.vars)
+		if err != nil {
+			return err
+		}
+		err = replaceVars(group.Vars, vars, func(k string, v any) error {
+			return m.inventory.SetGroupVar(group.Name, k, v)
+		})
+		if err != nil {
+			return err
+		}
+		m.status = fmt.Sprintf("Updated the vars of [%s].", group.Name)
+		return nil
+	})
+}
+
+// hostVarsForm asks which of the selected group's hosts to edit, then edits
+// its own vars as YAML.
+func (m *model) hostVarsForm() tea.Cmd {
+	group := m.selectedGroup()
+	if group == nil || len(group.Hosts) == 0 {
+		return nil
+	}
+	values := &formValues{}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(fmt.Sprintf("Edit which host of [%s]?", group.Name)).
+				Options(huh.NewOptions(sortedHostNames(group)...)...).
+				Value(&values.host),
+		),
+	)
+	return m.openForm(form, values, func(m *model) error {
+		host := m.inventory.Hosts[values.host]
+		values.vars = formatVars(host.Vars)
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewText().
+					Title(fmt.Sprintf("Vars of %s (YAML)", host.Name)).
+					Value(&values.vars),
+			),
+		)
+		m.openForm(form, values, func(m *model) error {
+			vars, err := parseVars(values.vars)
+			if err != nil {
+				return err
+			}
+			err = replaceVars(host.Vars, vars, func(k string, v any) error {
+				return m.inventory.SetHostVar(host.Name, k, v)
+			})
+			if err != nil {
+				return err
+			}
+			m.status = fmt.Sprintf("Updated the vars of %s.", host.Name)
+			return nil
+		})
+		return nil
+	})
+}
+
+// writeForm shows what writing the inventory back would change and writes
+// it once confirmed.
+func (m *model) writeForm() tea.Cmd {
+	data, err := m.inventory.MarshalYAML()
+	if err != nil {
+		m.status = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+	if bytes.Equal(data, m.rendered) {
+		m.status = "No changes to write."
+		return nil
+	}
+	values := &formValues{}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title(fmt.Sprintf("Changes to %s", m.path)).
+				Description(renderDiff(m.saved, data)),
+			huh.NewConfirm().
+				Title("Write them?").
+				Value(&values.confirmed),
+		),
+	)
+	return m.openForm(form, values, func(m *model) error {
+		if !values.confirmed {
+			m.status = "Not written."
+			return nil
+		}
+		if err := os.WriteFile(m.path, data, 0o644); err != nil {
+			return fmt.Errorf("could not write %s: %w", m.path, err)
+		}
+		m.saved, m.rendered = data, data
+		m.status = fmt.Sprintf("Wrote %s.", m.path)
+		return nil
+	})
+}
+
+// sortedHostNames returns a group's host names in order.
+func sortedHostNames(group *ansibleinv.Group) []string {
+	var hostNames []string
+	for name := range group.Hosts {
+		hostNames = append(hostNames, name)
+	}
+	sort.Strings(hostNames)
+	return hostNames
+}
+
+// formatVars renders vars as the YAML the edit forms show.
+func formatVars(vars map[string]any) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	data, err := yaml.Marshal(vars)
+	if err != nil {
+		return fmt.Sprint(vars)
+	}
+	return string(data)
+}
+
+// parseVars reads the YAML typed into an edit form.
+func parseVars(text string) (map[string]any, error) {
+	vars := make(map[string]any)
+	if err := yaml.Unmarshal([]byte(text), &vars); err != nil {
+		return nil, fmt.Errorf("vars are not valid YAML: %w", err)
+	}
+	return vars, nil
+}
+
+// replaceVars makes current hold exactly next, setting each variable through
+// set so names are validated, and dropping the ones next leaves out.
+func replaceVars(current, next map[string]any, set func(string, any) error) error {
+	for k, v := range next {
+		if err := set(k, v); err != nil {
+			return err
+		}
+	}
+	for k := range current {
+		if _, keep := next[k]; !keep {
+			delete(current, k)
+		}
+	}
+	return nil
+}
+
+// renderDiff shows the lines an edit removes and adds, with unchanged runs
+// folded down to a little context.
+func renderDiff(old, new []byte) string {
+	const context = 2
+	a := strings.Split(strings.TrimSuffix(string(old), "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(string(new), "\n"), "\n")
+
+	// Plain LCS diff; inventories are small enough for O(n·m).
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		kind byte
+		text string
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	folded := false
+	for k, l := range lines {
+		switch l.kind {
+		case '-':
+			out.WriteString(removedStyle.Render("- "+l.text) + "\n")
+		case '+':
+			out.WriteString(addedStyle.Render("+ "+l.text) + "\n")
+		default:
+			near := false
+			for d := max(k-context, 0); d <= min(k+context, len(lines)-1); d++ {
+				near = near || lines[d].kind != ' '
+			}
+			if near {
+				out.WriteString("  " + l.text + "\n")
+				folded = false
+			} else if !folded {
+				out.WriteString(helpStyle.Render("  …") + "\n")
+				folded = true
+			}
+		}
+	}
+	return out.String()
+}
+
+// Run opens the editor on one or more YAML inventories; tab switches between
+// them. It returns when the user quits. A broken theme file only warns.
+func Run(paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("no inventory to edit")
+	}
+	t, err := theme.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Using the default theme: %v\n", err)
+	}
+	applyTheme(t)
+
+	m, err := initialModel(paths)
+	if err != nil {
+		return err
 	}
+	_, err = tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
 }